@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// endpoint describes a registered HTTP route. The same description is used
+// to register the handler and to generate the OpenAPI document, so the spec
+// cannot drift from what the server actually serves.
+type endpoint struct {
+	Path        string
+	Method      string // default GET
+	Summary     string
+	Description string
+	Tag         string
+	ContentType string // media type of the success response
+	Status      int    // success status code (default 200)
+	Params      []endpointParam
+	Errors      []int // additional response status codes
+	Hidden      bool  // omit from the OpenAPI document
+}
+
+// endpointParam describes a query or path parameter.
+type endpointParam struct {
+	Name        string
+	In          string // "query" or "path"
+	Type        string // OpenAPI schema type: string, number, integer, boolean
+	Description string
+	Required    bool
+}
+
+// apiRegistry wraps a ServeMux and records every registered endpoint.
+type apiRegistry struct {
+	mux       *http.ServeMux
+	endpoints []endpoint
+}
+
+// newAPIRegistry creates a registry that registers handlers on mux.
+func newAPIRegistry(mux *http.ServeMux) *apiRegistry {
+	return &apiRegistry{mux: mux}
+}
+
+// handle registers h for the endpoint path and records the endpoint.
+func (a *apiRegistry) handle(ep endpoint, h http.HandlerFunc) {
+	pattern := ep.Path
+	if ep.Method == "" {
+		ep.Method = http.MethodGet
+	} else if ep.Method != http.MethodGet {
+		// Several methods may share a path, so other methods are part of
+		// the pattern; GET routes keep their method-less patterns
+		pattern = ep.Method + " " + ep.Path
+	}
+	a.mux.HandleFunc(pattern, h)
+	a.endpoints = append(a.endpoints, ep)
+}
+
+// openAPI builds an OpenAPI 3 document from the registered endpoints.
+func (a *apiRegistry) openAPI() map[string]interface{} {
+	paths := make(map[string]interface{})
+	tagSet := make(map[string]bool)
+
+	for _, ep := range a.endpoints {
+		if ep.Hidden {
+			continue
+		}
+
+		status := ep.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): map[string]interface{}{
+				"description": http.StatusText(status),
+				"content": map[string]interface{}{
+					ep.ContentType: map[string]interface{}{"schema": schemaFor(ep.ContentType)},
+				},
+			},
+		}
+		for _, code := range ep.Errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code)}
+		}
+
+		op := map[string]interface{}{
+			"summary":     ep.Summary,
+			"operationId": operationID(ep),
+			"responses":   responses,
+		}
+		if ep.Description != "" {
+			op["description"] = ep.Description
+		}
+		if ep.Tag != "" {
+			op["tags"] = []string{ep.Tag}
+			tagSet[ep.Tag] = true
+		}
+		if len(ep.Params) > 0 {
+			params := make([]map[string]interface{}, 0, len(ep.Params))
+			for _, p := range ep.Params {
+				params = append(params, map[string]interface{}{
+					"name":        p.Name,
+					"in":          p.In,
+					"required":    p.Required || p.In == "path",
+					"description": p.Description,
+					"schema":      map[string]string{"type": p.Type},
+				})
+			}
+			op["parameters"] = params
+		}
+
+		item, ok := paths[ep.Path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[ep.Path] = item
+		}
+		item[strings.ToLower(ep.Method)] = op
+	}
+
+	tags := make([]map[string]string, 0, len(tagSet))
+	for tag := range tagSet {
+		tags = append(tags, map[string]string{"name": tag})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i]["name"] < tags[j]["name"] })
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "tudomesh",
+			"version":     Version,
+			"description": "Unified maps and live positions for multiple Valetudo vacuums.",
+		},
+		"tags":  tags,
+		"paths": paths,
+	}
+}
+
+// schemaFor returns a response schema for a media type.
+func schemaFor(contentType string) map[string]string {
+	switch {
+	case strings.HasPrefix(contentType, "application/json"), strings.HasPrefix(contentType, "application/geo+json"):
+		return map[string]string{"type": "object"}
+	case strings.HasPrefix(contentType, "text/"), contentType == "image/svg+xml":
+		return map[string]string{"type": "string"}
+	default:
+		return map[string]string{"type": "string", "format": "binary"}
+	}
+}
+
+// operationID derives a stable operation ID from method and path,
+// e.g. GET /composite-map.png -> getCompositeMapPng.
+func operationID(ep endpoint) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(ep.Method))
+	for _, word := range strings.FieldsFunc(ep.Path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '{' || r == '}' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// registerDocs adds /api/openapi.json and a Swagger UI page at /api/docs.
+// Call it after all other endpoints are registered.
+func (a *apiRegistry) registerDocs() {
+	a.handle(endpoint{
+		Path:        "/api/openapi.json",
+		Summary:     "OpenAPI document",
+		Description: "This document, generated from the server's registered routes.",
+		Tag:         "docs",
+		ContentType: "application/json",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(a.openAPI()); err != nil {
+			log.Printf("Error encoding OpenAPI document: %v", err)
+		}
+	})
+
+	a.handle(endpoint{
+		Path:        "/api/docs",
+		Summary:     "Interactive API documentation",
+		Tag:         "docs",
+		ContentType: "text/html",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, swaggerUIPage)
+	})
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the generated spec.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>tudomesh API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function() {
+  SwaggerUIBundle({url: "/api/openapi.json", dom_id: "#swagger-ui"});
+};
+</script>
+</body>
+</html>`
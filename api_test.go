@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOperationID(t *testing.T) {
+	tests := []struct {
+		ep   endpoint
+		want string
+	}{
+		{endpoint{Method: "GET", Path: "/composite-map.png"}, "getCompositeMapPng"},
+		{endpoint{Method: "GET", Path: "/health"}, "getHealth"},
+		{endpoint{Method: "POST", Path: "/api/calibration/{id}"}, "postApiCalibrationId"},
+	}
+	for _, tt := range tests {
+		if got := operationID(tt.ep); got != tt.want {
+			t.Errorf("operationID(%s %s) = %q, want %q", tt.ep.Method, tt.ep.Path, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAPI_DerivedFromRegistrations(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker()})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+
+	for _, path := range []string{"/health", "/composite-map.png", "/live.png", "/composite-map.svg", "/floorplan.svg", "/live.svg", "/tracks.geojson", "/handoff.json", "/stats.json", "/events", "/zones", "/api/docs", "/api/openapi.json"} {
+		if _, ok := doc.Paths[path]["get"]; !ok {
+			t.Errorf("spec missing GET %s", path)
+		}
+	}
+	if _, ok := doc.Paths["/"]; ok {
+		t.Error("hidden homepage should not appear in the spec")
+	}
+
+	responses, _ := doc.Paths["/composite-map.png"]["get"]["responses"].(map[string]interface{})
+	for _, code := range []string{"200", "429", "503"} {
+		if _, ok := responses[code]; !ok {
+			t.Errorf("/composite-map.png missing %s response", code)
+		}
+	}
+}
+
+func TestAPIDocsPage(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker()})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "/api/openapi.json") {
+		t.Error("docs page should load the generated spec")
+	}
+}
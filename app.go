@@ -1,36 +1,57 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"image/color"
+	"io"
 	"log"
-	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/kwv/tudomesh/mesh"
+	"google.golang.org/grpc"
 )
 
 // App encapsulates the application state and dependencies
 type App struct {
-	Config          *mesh.Config
-	Calibration     *mesh.CalibrationData
-	StateTracker    *mesh.StateTracker
-	MQTTClient      *mesh.MQTTClient
-	Publisher       *mesh.Publisher
-	AutoCalibrator  *mesh.AutoCalibrator
+	Config         *mesh.Config
+	StateTracker   *mesh.StateTracker
+	MQTTClient     *mesh.MQTTClient
+	Publisher      *mesh.Publisher
+	AutoCalibrator *mesh.AutoCalibrator
+	Store          mesh.Store
+	Coordinator    *mesh.Coordinator
+	Work           *mesh.WorkQueue      // calibration and other slow work handed off by MQTT handlers
+	MapWriter      *mesh.MapWriter      // debounced map persistence
+	Battery        *mesh.BatteryMonitor // low-battery alerts
+	Positions      *mesh.Debouncer      // coalesces publishes of the combined positions topic
+	Accumulator    *mesh.MapAccumulator // merges the runs of vacuums that map in sections
 
 	// CLI Flags (effectively dependencies)
 	DataDir          string
 	ConfigFile       string
 	CalibrationCache string
 	RotateAll        float64
+	AutoRotate       bool
+	Crop             *mesh.CropRegion
+	WorldFile        bool
+	CompareAngles    []float64
+	CompareGrid      bool
+	Apply            bool
 	ForceRotation    string
 	ReferenceVacuum  string
 	OutputFile       string
@@ -38,8 +59,36 @@ type App struct {
 	VectorFormat     string
 	GridSpacing      float64
 	HttpPort         int
+	GrpcPort         int
 	MqttMode         bool
 	HttpMode         bool
+	Watch            bool
+	Replay           string
+	ReplaySpeed      float64
+	Simulate         int
+	SimulateMQTT     bool
+	Record           string
+	RecordMaxMB      int
+	RecordFiles      int
+	Remote           string
+	Profile          string
+	DoctorTimeout    time.Duration
+	JSON             bool // results as JSON on stdout
+
+	quarantine mesh.MapQuarantine // maps rejected as corrupt or partial, per vacuum
+
+	scansMu sync.Mutex
+	scans   map[string][]*mesh.ValetudoMap // maps of accumulating vacuums waiting to be merged
+
+	framesMu sync.Mutex
+	frames   map[string]framePair // frame shift checks waiting to run
+
+	exportsOnce sync.Once
+	exports     *mesh.ExportPattern // export file naming from config.yaml
+
+	// calibration is replaced by the auto-calibrator, the cache watcher and
+	// cluster followers while MQTT handlers and endpoints read it
+	calibration atomic.Pointer[mesh.CalibrationData]
 }
 
 // NewApp creates a new App instance
@@ -49,12 +98,20 @@ func NewApp() *App {
 	}
 }
 
-// ApplyOptions applies CLI options to the App instance
+// ApplyOptions applies CLI options to the App instance. A relative
+// calibration cache path is taken relative to the data directory in every
+// mode.
 func (a *App) ApplyOptions(opts AppOptions) {
 	a.DataDir = opts.DataDir
 	a.ConfigFile = opts.ConfigFile
-	a.CalibrationCache = opts.CalibrationCache
+	a.CalibrationCache = mesh.ResolvePath(opts.DataDir, opts.CalibrationCache)
 	a.RotateAll = opts.RotateAll
+	a.AutoRotate = opts.AutoRotate
+	a.Crop = opts.Crop
+	a.WorldFile = opts.WorldFile
+	a.CompareAngles = opts.CompareAngles
+	a.CompareGrid = opts.CompareGrid
+	a.Apply = opts.Apply
 	a.ForceRotation = opts.ForceRotation
 	a.ReferenceVacuum = opts.ReferenceVacuum
 	a.OutputFile = opts.OutputFile
@@ -62,39 +119,166 @@ func (a *App) ApplyOptions(opts AppOptions) {
 	a.VectorFormat = opts.VectorFormat
 	a.GridSpacing = opts.GridSpacing
 	a.HttpPort = opts.HttpPort
+	a.GrpcPort = opts.GrpcPort
 	a.MqttMode = opts.MqttMode
 	a.HttpMode = opts.HttpMode
+	a.Watch = opts.Watch
+	a.Replay = opts.Replay
+	a.ReplaySpeed = opts.ReplaySpeed
+	a.Simulate = opts.Simulate
+	a.SimulateMQTT = opts.SimulateMQTT
+	a.Record = opts.Record
+	a.RecordMaxMB = opts.RecordMaxMB
+	a.RecordFiles = opts.RecordFiles
+	a.Remote = opts.Remote
+	a.Profile = opts.Profile
+	a.DoctorTimeout = opts.DoctorTimeout
+	a.JSON = opts.JSON
 }
 
-// RunParseOnly finds and parses all Valetudo JSON exports
-func (a *App) RunParseOnly() {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
+// exportNames returns the export file naming from config.yaml, which is
+// read once. Without a readable config only Valetudo's naming is recognized.
+func (a *App) exportNames() *mesh.ExportPattern {
+	a.exportsOnce.Do(func() {
+		config := a.Config
+		if config == nil {
+			if _, err := os.Stat(a.ConfigFile); err != nil {
+				return
+			}
+			var err error
+			if config, err = mesh.LoadConfig(a.ConfigFile); err != nil {
+				return
+			}
+		}
+		a.exports = config.ExportNames()
+	})
+	return a.exports
+}
+
+// findExports lists the map exports in --data-dir, falling back to the
+// current directory. It fails when there are none.
+func (a *App) findExports() ([]string, *mesh.ExportPattern, error) {
+	exports := a.exportNames()
+	files, err := exports.Find(a.DataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("finding JSON files: %w", err)
 	}
 
 	if len(files) == 0 {
 		// Try current directory
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
+		files, _ = exports.Find(".")
 	}
 
 	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+		return nil, nil, errors.New("no ValetudoMapExport-*.json files found")
+	}
+	return files, exports, nil
+}
+
+// loadExports parses the map exports found by findExports into maps keyed
+// by vacuum ID. Exports that fail to parse are reported, skipped and added
+// to failed. verbose also lists every export loaded. With --json nothing is
+// listed and failures go to stderr.
+func (a *App) loadExports(verbose bool, failed *failures) (map[string]*mesh.ValetudoMap, error) {
+	files, exports, err := a.findExports()
+	if err != nil {
+		return nil, err
+	}
+	w := io.Writer(os.Stdout)
+	if a.JSON {
+		w, verbose = os.Stderr, false
+	} else if verbose {
+		fmt.Printf("Found %d map export(s)\n", len(files))
+	}
+	maps, parseFailures := parseExports(w, files, exports, verbose)
+	*failed = append(*failed, parseFailures...)
+	return maps, nil
+}
+
+// printJSON writes v to stdout as indented JSON, for --json.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	return nil
+}
+
+// parseExports parses files into maps keyed by vacuum ID, writing failures,
+// and with verbose each map loaded, to w. It also returns the failures.
+func parseExports(w io.Writer, files []string, exports *mesh.ExportPattern, verbose bool) (map[string]*mesh.ValetudoMap, failures) {
+	maps := make(map[string]*mesh.ValetudoMap, len(files))
+	var failed failures
+	for _, file := range files {
+		name, _ := exports.VacuumID(file)
+
+		m, err := mesh.ParseMapFile(file)
+		if err != nil {
+			fmt.Fprintf(w, "Error loading %s: %v\n", name, err)
+			failed.add("loading %s: %v", name, err)
+			continue
+		}
+		maps[name] = m
+		if verbose {
+			fmt.Fprintf(w, "Loaded: %s (area: %d)\n", name, m.MetaData.TotalLayerArea)
+		}
+	}
+	return maps, failed
+}
+
+// parsedExport is one export in the --parse-only --json output.
+type parsedExport struct {
+	VacuumID string           `json:"vacuumId"`
+	File     string           `json:"file"`
+	Error    string           `json:"error,omitempty"`
+	Summary  *mesh.MapSummary `json:"summary,omitempty"`
+}
+
+// RunParseOnly finds and parses all Valetudo JSON exports. Exports that
+// fail to parse make it a partial failure.
+func (a *App) RunParseOnly() error {
+	files, exports, err := a.findExports()
+	if err != nil {
+		return err
+	}
+
+	var failed failures
+	if a.JSON {
+		results := make([]parsedExport, 0, len(files))
+		for _, file := range files {
+			name, _ := exports.VacuumID(file)
+			result := parsedExport{VacuumID: name, File: file}
+			if m, err := mesh.ParseMapFile(file); err != nil {
+				result.Error = err.Error()
+				failed.add("loading %s: %v", name, err)
+			} else {
+				summary := mesh.Summarize(m)
+				result.Summary = &summary
+			}
+			results = append(results, result)
+		}
+		if err := printJSON(results); err != nil {
+			return err
+		}
+		return failed.err()
 	}
 
 	fmt.Printf("Found %d map export(s)\n\n", len(files))
 
 	for _, file := range files {
-		a.parseAndPrint(file)
+		if err := a.parseAndPrint(file); err != nil {
+			failed.add("%v", err)
+		}
 	}
+	return failed.err()
 }
 
-func (a *App) parseAndPrint(path string) {
+// parseAndPrint prints a summary of the export at path, or the error that
+// kept it from parsing, which it also returns.
+func (a *App) parseAndPrint(path string) error {
 	// Extract vacuum name from filename
-	base := filepath.Base(path)
-	name := strings.TrimPrefix(base, "ValetudoMapExport-")
-	name = strings.Split(name, "-2")[0] // Remove timestamp
+	name, _ := a.exportNames().VacuumID(path)
 
 	fmt.Printf("=== %s ===\n", name)
 	fmt.Printf("File: %s\n", path)
@@ -102,7 +286,7 @@ func (a *App) parseAndPrint(path string) {
 	m, err := mesh.ParseMapFile(path)
 	if err != nil {
 		fmt.Printf("ERROR: %v\n\n", err)
-		return
+		return fmt.Errorf("loading %s: %w", name, err)
 	}
 
 	summary := mesh.Summarize(m)
@@ -120,95 +304,169 @@ func (a *App) parseAndPrint(path string) {
 	fmt.Println()
 	fmt.Printf("Has Floor: %v, Has Wall: %v\n", summary.HasFloor, summary.HasWall)
 	fmt.Println()
+	return nil
 }
 
-// RunCompareRotation renders 4 images with different rotation options for a vacuum
-func (a *App) RunCompareRotation(vacuumID string) {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
+// compareAll is the --compare-rotation value that compares every vacuum.
+const compareAll = "all"
+
+// rotationIndexFile is the HTML page combining the comparisons of
+// --compare-rotation=all.
+const rotationIndexFile = "rotation_index.html"
+
+// RunCompareRotation renders one image per rotation option for a vacuum:
+// the cardinal rotations, or the angles given with --compare-angles. With
+// "all" it compares every non-reference vacuum and writes an HTML index of
+// the images; a vacuum that fails to render is skipped. With --compare-grid
+// each vacuum gets one annotated grid image instead, and no index.
+func (a *App) RunCompareRotation(vacuumID string) error {
+	var failed failures
+	maps, err := a.loadExports(false, &failed)
 	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
+		return err
 	}
 
-	if len(files) == 0 {
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
+	refID := a.ReferenceVacuum
+	if refID == "" {
+		refID = mesh.SelectReferenceVacuum(maps, nil)
 	}
 
-	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+	// "all" compares every vacuum except the reference
+	var ids []string
+	if vacuumID == compareAll {
+		for id := range maps {
+			if id != refID {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if len(ids) == 0 {
+			return errors.New("need at least 2 maps for rotation comparison")
+		}
+	} else {
+		// Check vacuum exists
+		if _, ok := maps[vacuumID]; !ok {
+			return fmt.Errorf("vacuum %q not found (available: %s)", vacuumID, strings.Join(sortedKeys(maps), ", "))
+		}
+		ids = []string{vacuumID}
 	}
 
-	// Load all maps
-	maps := make(map[string]*mesh.ValetudoMap)
-	for _, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0]
+	rotations := a.CompareAngles
+	if len(rotations) == 0 {
+		rotations = mesh.CardinalRotations
+	}
+	globalRotation := a.globalRotation(maps, refID)
 
-		m, err := mesh.ParseMapFile(file)
-		if err != nil {
-			fmt.Printf("Error loading %s: %v\n", name, err)
-			continue
+	if a.CompareGrid {
+		if err := a.writeRotationGrids(maps, refID, ids, rotations, globalRotation, &failed); err != nil {
+			return err
 		}
-		maps[name] = m
+		return failed.err()
 	}
 
-	// Check vacuum exists
-	if _, ok := maps[vacuumID]; !ok {
-		fmt.Printf("Vacuum '%s' not found. Available:\n", vacuumID)
-		for id := range maps {
-			fmt.Printf("  - %s\n", id)
+	var comparisons []mesh.RotationComparison
+	for _, id := range ids {
+		fmt.Printf("Rendering rotation comparison for %s...\n", id)
+		outputPrefix := fmt.Sprintf("rotation_%s", id)
+		paths, err := mesh.RenderRotationComparison(maps, id, outputPrefix, a.ReferenceVacuum, globalRotation, rotations)
+		if err != nil {
+			if len(ids) == 1 {
+				return fmt.Errorf("rendering %s: %w", id, err)
+			}
+			log.Printf("Warning: skipping %s: %v", id, err)
+			failed.add("rendering %s: %v", id, err)
+			continue
 		}
-		return
+		fmt.Printf("Created: %s\n", strings.Join(paths, ", "))
+		comparisons = append(comparisons, mesh.RotationComparison{VacuumID: id, Rotations: rotations, Paths: paths})
 	}
 
-	fmt.Printf("Rendering rotation comparison for %s...\n", vacuumID)
-	outputPrefix := fmt.Sprintf("rotation_%s", vacuumID)
+	if vacuumID != compareAll {
+		return failed.err()
+	}
+	if len(comparisons) == 0 {
+		return errors.New("no rotation comparison could be rendered")
+	}
+	f, err := os.Create(rotationIndexFile)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", rotationIndexFile, err)
+	}
+	if err := mesh.WriteRotationIndex(f, refID, comparisons); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", rotationIndexFile, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", rotationIndexFile, err)
+	}
+	fmt.Printf("Created index: %s\n", rotationIndexFile)
+	return failed.err()
+}
 
-	if err := mesh.RenderRotationComparison(maps, vacuumID, outputPrefix, a.ReferenceVacuum, a.RotateAll); err != nil {
-		log.Fatalf("Error rendering: %v", err)
+// writeRotationGrids writes rotation_ID.png for each vacuum: one image
+// with the composite at every rotation, captioned with its alignment score.
+// A vacuum whose grid fails is added to failed and skipped, unless it is
+// the only one.
+func (a *App) writeRotationGrids(maps map[string]*mesh.ValetudoMap, refID string, ids []string, rotations []float64, globalRotation float64, failed *failures) error {
+	// The vacuums not being rotated are aligned once by ICP
+	transforms := map[string]mesh.AffineMatrix{refID: mesh.Identity()}
+	for id, m := range maps {
+		if id != refID {
+			transforms[id] = mesh.AlignMaps(m, maps[refID], mesh.DefaultICPConfig()).Transform
+		}
 	}
 
-	fmt.Printf("Created: %s_0.png, %s_90.png, %s_180.png, %s_270.png\n",
-		outputPrefix, outputPrefix, outputPrefix, outputPrefix)
+	written := 0
+	for _, id := range ids {
+		fmt.Printf("Rendering rotation grid for %s...\n", id)
+		path, err := writeRotationGrid(maps, transforms, refID, id, rotations, globalRotation)
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", id, err)
+			failed.add("%s: %v", id, err)
+			continue
+		}
+		fmt.Printf("Created: %s\n", path)
+		written++
+	}
+	if written == 0 {
+		return errors.New("no rotation grid could be written")
+	}
+	return nil
 }
 
-// RunRender loads maps, aligns them, and outputs a composite PNG
-func (a *App) RunRender() {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
+// writeRotationGrid writes the rotation grid of one vacuum and returns its
+// path.
+func writeRotationGrid(maps map[string]*mesh.ValetudoMap, transforms map[string]mesh.AffineMatrix, refID, id string, rotations []float64, globalRotation float64) (string, error) {
+	variants, err := mesh.RotationVariants(maps, transforms, refID, id, rotations)
 	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
+		return "", fmt.Errorf("comparing rotations: %w", err)
 	}
+	grid := mesh.RenderRotationGrid(variants, func(v mesh.RotationVariant) *image.RGBA {
+		renderer := mesh.NewCompositeRenderer(maps, v.Transforms, refID)
+		renderer.GlobalRotation = globalRotation
+		return renderer.Render()
+	}, nil)
 
-	if len(files) == 0 {
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
+	data, _, err := encodeImage(grid, formatPNG)
+	if err != nil {
+		return "", fmt.Errorf("encoding rotation grid: %w", err)
 	}
-
-	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+	path := fmt.Sprintf("rotation_%s.png", id)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
 	}
+	return path, nil
+}
 
-	fmt.Printf("Found %d map export(s)\n", len(files))
-
-	// Load all maps
-	maps := make(map[string]*mesh.ValetudoMap)
-	for _, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0]
-
-		m, err := mesh.ParseMapFile(file)
-		if err != nil {
-			fmt.Printf("Error loading %s: %v\n", name, err)
-			continue
-		}
-		maps[name] = m
-		fmt.Printf("Loaded: %s\n", name)
+// RunRender loads maps, aligns them, and outputs a composite PNG
+func (a *App) RunRender() error {
+	var failed failures
+	maps, err := a.loadExports(true, &failed)
+	if err != nil {
+		return err
 	}
 
 	if len(maps) < 2 {
-		log.Fatal("Need at least 2 maps for composite render")
+		return errors.New("need at least 2 maps for composite render")
 	}
 
 	// Load unified config (optional - provides rotation hints and manual overrides)
@@ -223,12 +481,16 @@ func (a *App) RunRender() {
 	}
 
 	// Load calibration cache (auto-computed ICP transforms)
-	var cache *mesh.CalibrationData
-	cache, err = mesh.LoadCalibration(a.CalibrationCache)
+	store, err := a.calibrationStore(config)
 	if err != nil {
-		log.Printf("Warning: Failed to load calibration cache %s: %v", a.CalibrationCache, err)
+		return err
+	}
+	defer func() { _ = store.Close() }()
+	cache, err := store.LoadCalibration()
+	if err != nil {
+		log.Printf("Warning: Failed to load calibration from %s: %v", store, err)
 	} else if cache != nil {
-		log.Printf("Loaded calibration cache from %s", a.CalibrationCache)
+		log.Printf("Loaded calibration from %s", store)
 	}
 
 	// Determine effective reference
@@ -244,6 +506,10 @@ func (a *App) RunRender() {
 	// Build transforms from cache, config, and CLI (priority: CLI > config > cache > ICP)
 	transforms := make(map[string]mesh.AffineMatrix)
 	transforms[effectiveRef] = mesh.Identity()
+	scores := make(map[string]float64)                 // ICP scores, kept in the cache
+	rotations := make(map[string]*mesh.CachedRotation) // detected rotations, kept in the cache
+	covariances := make(map[string]*mesh.TransformCovariance)
+	alternatives := make(map[string][]mesh.RotationHypothesis) // ambiguous rotations, kept in the cache
 	needsRecalibration := false
 
 	for id := range maps {
@@ -258,20 +524,35 @@ func (a *App) RunRender() {
 		if cache != nil && cache.ReferenceVacuum == effectiveRef {
 			if vc, ok := cache.Vacuums[id]; ok {
 				transform = vc.Transform
+				scores[id] = vc.ICPScore
+				rotations[id] = vc.Rotation
+				covariances[id] = vc.Covariance
+				alternatives[id] = vc.Alternatives
 				source = "cache"
 			}
 		}
 
+		// A frozen transform is kept as cached; only --force-rotation overrides it
+		frozen := source == "cache" && config.IsFrozen(id)
+		if frozen {
+			fmt.Printf("  %s: transform frozen in config\n", id)
+		}
+
+		// A mirrored map is aligned by its reflection (see mesh.MirrorMap)
+		src, mirror := mesh.MirrorMap(maps[id], config.MirrorAxis(id))
+
 		// Priority 2: Check config rotation hints (run ICP with hint as starting point)
-		if config != nil {
+		if config != nil && !frozen {
 			vc := config.GetVacuumByID(id)
 			if vc != nil && vc.Rotation != nil {
 				rotHint := *vc.Rotation
-				fmt.Printf("  %s: re-running ICP with rotation hint %.0f° from config\n", id, rotHint)
-				icpConfig := mesh.DefaultICPConfig()
-				result := mesh.AlignMapsWithRotationHint(maps[id], maps[effectiveRef], icpConfig, rotHint)
+				fmt.Printf("  %s: re-running ICP with rotation hint %g° from config\n", id, rotHint)
+				icpConfig := mesh.ICPConfigFromConfig(config)
+				result := mesh.AlignMapsWithRotationHint(src, maps[effectiveRef], icpConfig, rotHint).ComposedWith(mirror)
 				transform = result.Transform
-				source = fmt.Sprintf("ICP+hint(%.0f°)", rotHint)
+				scores[id] = result.Score
+				covariances[id] = result.Covariance
+				source = fmt.Sprintf("ICP+hint(%g°)", rotHint)
 				needsRecalibration = true
 
 				// Apply manual translation if provided
@@ -286,11 +567,13 @@ func (a *App) RunRender() {
 		if a.ForceRotation != "" {
 			cliRotations := mesh.BuildForceRotationMap(a.ForceRotation)
 			if rotDeg, ok := cliRotations[id]; ok {
-				fmt.Printf("  %s: CLI override rotation %.0f° (running ICP with hint)\n", id, rotDeg)
-				icpConfig := mesh.DefaultICPConfig()
-				result := mesh.AlignMapsWithRotationHint(maps[id], maps[effectiveRef], icpConfig, rotDeg)
+				fmt.Printf("  %s: CLI override rotation %g° (running ICP with hint)\n", id, rotDeg)
+				icpConfig := mesh.ICPConfigFromConfig(config)
+				result := mesh.AlignMapsWithRotationHint(src, maps[effectiveRef], icpConfig, rotDeg).ComposedWith(mirror)
 				transform = result.Transform
-				source = fmt.Sprintf("CLI+ICP(%.0f°)", rotDeg)
+				scores[id] = result.Score
+				covariances[id] = result.Covariance
+				source = fmt.Sprintf("CLI+ICP(%g°)", rotDeg)
 				needsRecalibration = true
 			}
 		}
@@ -298,20 +581,27 @@ func (a *App) RunRender() {
 		// If no transform found, run full ICP
 		if transform.A == 0 && transform.D == 0 {
 			fmt.Printf("  %s: running full ICP alignment (not in cache)\n", id)
-			icpConfig := mesh.DefaultICPConfig()
-			result := mesh.AlignMaps(maps[id], maps[effectiveRef], icpConfig)
+			icpConfig := mesh.ICPConfigFromConfig(config)
+			result, rotation, reused := mesh.AlignMapsReusingRotation(id, src, maps[effectiveRef], icpConfig, cache)
+			if reused {
+				fmt.Printf("  %s: reused cached rotation %g° (maps unchanged)\n", id, rotation.Rotation)
+			}
+			result = result.ComposedWith(mirror)
 			transform = result.Transform
+			scores[id] = result.Score
+			rotations[id] = rotation
+			covariances[id] = result.Covariance
+			alternatives[id] = result.Alternatives
+			for _, alt := range result.Alternatives {
+				fmt.Printf("  %s: ambiguous rotation: %.1f° (score %.2f) aligned about as well; set a rotation hint to choose\n", id, alt.Rotation, alt.Score)
+			}
 			source = "ICP (auto-computed)"
 			needsRecalibration = true
 		}
 
 		transforms[id] = transform
 		if source == "cache" {
-			totalRotation := math.Atan2(transform.C, transform.A) * 180 / math.Pi
-			if totalRotation < 0 {
-				totalRotation += 360
-			}
-			fmt.Printf("  %s: using cached transform (rotation %.1f°)\n", id, totalRotation)
+			fmt.Printf("  %s: using cached transform (rotation %.1f°)\n", id, mesh.TransformRotation(transform))
 		}
 	}
 
@@ -329,17 +619,35 @@ func (a *App) RunRender() {
 				Transform:            t,
 				LastUpdated:          nowUnix,
 				MapAreaAtCalibration: area,
+				ICPScore:             scores[id],
+				Rotation:             rotations[id],
+				Covariance:           covariances[id],
+				Alternatives:         alternatives[id],
 			}
 		}
 		newCache := mesh.CalibrationData{
 			ReferenceVacuum: effectiveRef,
 			Vacuums:         vacCals,
 		}
-		if err := mesh.SaveCalibration(a.CalibrationCache, &newCache); err != nil {
+		if err := store.SaveCalibration(&newCache); err != nil {
 			log.Printf("Warning: Failed to save calibration cache: %v", err)
+			failed.add("saving calibration cache: %v", err)
 		} else {
-			fmt.Printf("Calibration cache updated: %s\n", a.CalibrationCache)
+			fmt.Printf("Calibration cache updated: %s\n", store)
+		}
+	}
+
+	// A profile narrows the render after calibration, so the cache keeps
+	// every vacuum
+	var profileRotation *float64
+	if a.Profile != "" {
+		profile := config.GetProfile(a.Profile)
+		if profile == nil {
+			return fmt.Errorf("unknown --profile %q (define it under profiles in %s)", a.Profile, a.ConfigFile)
 		}
+		maps = profile.Select(maps)
+		profileRotation = profile.Rotation
+		fmt.Printf("Profile %s: %d vacuum(s)\n", profile.Name, len(maps))
 	}
 
 	// Render with computed transforms
@@ -348,14 +656,52 @@ func (a *App) RunRender() {
 	// Determine render format
 	format := a.RenderFormat
 	if format != "raster" && format != "vector" && format != "both" {
-		log.Fatalf("Invalid format: %s (must be raster, vector, or both)", format)
+		return fmt.Errorf("invalid format: %s (must be raster, vector, or both)", format)
+	}
+
+	icons, err := mesh.LoadMarkerIcons(config)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	textFont, err := mesh.LoadFont(config)
+	if err != nil {
+		log.Printf("Warning: font not loaded: %v", err)
+	}
+
+	// The floorplan snap moves every map with the reference; it is applied
+	// at render time only, so the calibration cache stays robot-relative
+	floorplan, err := mesh.LoadFloorplan(config)
+	if err != nil {
+		log.Printf("Warning: floorplan not loaded: %v", err)
+	}
+	transforms = floorplan.SnapTransforms(maps, transforms, effectiveRef)
+
+	rotation := a.globalRotation(maps, effectiveRef)
+	if profileRotation != nil {
+		rotation = *profileRotation
+	} else if a.AutoRotate {
+		fmt.Printf("Auto orientation: rotating composite %.1f° to align walls with %s\n", rotation, effectiveRef)
 	}
 
 	// Raster rendering (existing behavior)
 	if format == "raster" || format == "both" {
 		renderer := mesh.NewCompositeRenderer(maps, transforms, effectiveRef)
-		renderer.GlobalRotation = a.RotateAll
+		renderer.GlobalRotation = rotation
 		applyConfigColors(renderer, config)
+		renderer.FillFloors = config != nil && config.Memory.FillFloors
+		renderer.Legend = mesh.LegendFromConfig(config)
+		renderer.Overlay = mesh.OverlayFromConfig(config)
+		if renderer.Overlay.GridSpacing == 0 {
+			renderer.Overlay.GridSpacing = a.GridSpacing
+		}
+		renderer.Icons = icons
+		renderer.Font = textFont
+		renderer.Floorplan = floorplan
+		if a.Crop != nil {
+			renderer.Crop = a.Crop
+			renderer.Padding = 0
+		}
 
 		outputPath := a.OutputFile
 		if format == "both" && !strings.HasSuffix(outputPath, ".png") {
@@ -363,15 +709,32 @@ func (a *App) RunRender() {
 		}
 
 		if err := renderer.SavePNG(outputPath); err != nil {
-			log.Fatalf("Error rendering raster: %v", err)
+			return fmt.Errorf("rendering raster: %w", err)
 		}
 		fmt.Printf("Created raster: %s\n", outputPath)
+
+		if a.WorldFile {
+			worldPath := mesh.WorldFilePath(outputPath)
+			transform, _ := renderer.WorldTransform()
+			if err := mesh.WriteWorldFile(worldPath, transform); err != nil {
+				return err
+			}
+			fmt.Printf("Created world file: %s\n", worldPath)
+		}
 	}
 
 	// Vector rendering
 	if format == "vector" || format == "both" {
+		if a.Crop != nil {
+			log.Printf("Warning: --crop applies to raster output only")
+		}
+		if a.WorldFile && format == "vector" {
+			log.Printf("Warning: --world-file applies to raster output only")
+		}
 		vectorRenderer := mesh.NewVectorRenderer(maps, transforms, effectiveRef)
-		vectorRenderer.GlobalRotation = a.RotateAll
+		vectorRenderer.GlobalRotation = rotation
+		vectorRenderer.Layering = mesh.LayeringFromConfig(config)
+		vectorRenderer.Icons = icons
 
 		// Apply grid spacing from config or flag
 		if config != nil && config.GridSpacing > 0 {
@@ -389,7 +752,7 @@ func (a *App) RunRender() {
 		// Create output file
 		outFile, err := os.Create(outputPath)
 		if err != nil {
-			log.Fatalf("Error creating output file %s: %v", outputPath, err)
+			return fmt.Errorf("creating output file %s: %w", outputPath, err)
 		}
 		defer func() {
 			if err := outFile.Close(); err != nil {
@@ -400,31 +763,24 @@ func (a *App) RunRender() {
 		// Render based on vector format
 		if a.VectorFormat == "svg" {
 			if err := vectorRenderer.RenderToSVG(outFile); err != nil {
-				log.Fatalf("Error rendering vector SVG: %v", err)
+				return fmt.Errorf("rendering vector SVG: %w", err)
 			}
 			fmt.Printf("Created vector SVG: %s\n", outputPath)
 		} else {
-			log.Fatalf("PNG vector format not yet implemented (use --vector-format=svg)")
+			return errors.New("PNG vector format not yet implemented (use --vector-format=svg)")
 		}
 	}
 
 	fmt.Println("Done!")
+	return failed.err()
 }
 
-// RunRenderIndividual renders each vacuum map as a separate PNG
-func (a *App) RunRenderIndividual(individualRotationFlag string) {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
+// RunRenderIndividual renders each vacuum map as a separate PNG. Maps that
+// fail to load or render are skipped and make it a partial failure.
+func (a *App) RunRenderIndividual(individualRotationFlag string) error {
+	files, exports, err := a.findExports()
 	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
-	}
-
-	if len(files) == 0 {
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
-	}
-
-	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+		return err
 	}
 
 	fmt.Printf("Found %d map export(s)\n", len(files))
@@ -454,14 +810,14 @@ func (a *App) RunRenderIndividual(individualRotationFlag string) {
 		{"green", color.RGBA{144, 238, 144, 255}, color.RGBA{0, 100, 0, 255}},
 	}
 
+	var failed failures
 	for i, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0]
+		name, _ := exports.VacuumID(file)
 
 		m, err := mesh.ParseMapFile(file)
 		if err != nil {
 			fmt.Printf("Error loading %s: %v\n", name, err)
+			failed.add("loading %s: %v", name, err)
 			continue
 		}
 
@@ -473,63 +829,100 @@ func (a *App) RunRenderIndividual(individualRotationFlag string) {
 
 		if err := mesh.RenderSingleMapWithRotation(m, outputPath, colors[colorIdx].floor, colors[colorIdx].wall, rotDeg); err != nil {
 			fmt.Printf("Error rendering %s: %v\n", name, err)
+			failed.add("rendering %s: %v", name, err)
 		}
 	}
 
 	fmt.Println("Done!")
+	return failed.err()
 }
 
-// RunCalibration loads all JSON exports and runs ICP calibration
-func (a *App) RunCalibration() {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
-	}
-
-	if len(files) == 0 {
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
-	}
+// calibratedVacuum is one vacuum's alignment in the --calibrate --json
+// output.
+type calibratedVacuum struct {
+	Transform       mesh.AffineMatrix `json:"transform"`
+	Rotation        float64           `json:"rotation"`        // degrees, of the transform
+	InitialRotation float64           `json:"initialRotation"` // degrees ICP started from
+	RotationReused  bool              `json:"rotationReused"`  // the sweep was skipped for unchanged maps
+	Score           float64           `json:"score"`
+	InlierFraction  float64           `json:"inlierFraction"`
+	Error           float64           `json:"error"`
+	Iterations      int               `json:"iterations"`
+	Converged       bool              `json:"converged"`
+	Valid           bool              `json:"valid"`
+	Frozen          bool              `json:"frozen,omitempty"` // kept from the cache; ICP did not run
+
+	Covariance   *mesh.TransformCovariance `json:"covariance,omitempty"`   // of rotation (degrees) and translation
+	Alternatives []mesh.RotationHypothesis `json:"alternatives,omitempty"` // other rotations that aligned about as well
+}
 
-	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+// RunCalibration loads all JSON exports and runs ICP calibration. With
+// --json the results are printed as JSON instead of the progress report.
+func (a *App) RunCalibration() error {
+	var out io.Writer = os.Stdout
+	if a.JSON {
+		out = io.Discard
 	}
-
-	fmt.Printf("Found %d map export(s)\n\n", len(files))
-
-	// Load all maps
-	maps := make(map[string]*mesh.ValetudoMap)
-	for _, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0] // Remove timestamp
-
-		m, err := mesh.ParseMapFile(file)
-		if err != nil {
-			fmt.Printf("Error loading %s: %v\n", name, err)
-			continue
-		}
-		maps[name] = m
-		fmt.Printf("Loaded: %s (area: %d)\n", name, m.MetaData.TotalLayerArea)
+	var failed failures
+	maps, err := a.loadExports(true, &failed)
+	if err != nil {
+		return err
 	}
 
 	if len(maps) < 2 {
-		log.Fatal("Need at least 2 maps for calibration")
+		return errors.New("need at least 2 maps for calibration")
 	}
 
 	// Select reference vacuum (largest area)
 	refID := mesh.SelectReferenceVacuum(maps, nil)
-	fmt.Printf("\nReference vacuum: %s (auto-selected by largest area)\n\n", refID)
+	fmt.Fprintf(out, "\nReference vacuum: %s (auto-selected by largest area)\n\n", refID)
 
 	refMap := maps[refID]
 
+	// Config is optional here; it only marks frozen transforms and mirrored
+	// maps, sets the feature weights and selects the storage backend
+	var vacConfig *mesh.Config
+	if _, err := os.Stat(a.ConfigFile); err == nil {
+		if vacConfig, err = mesh.LoadConfig(a.ConfigFile); err != nil {
+			log.Printf("Warning: Failed to load config file %s: %v", a.ConfigFile, err)
+		}
+	}
+
+	// Rotations found by the last run are reused for unchanged maps
+	store, err := a.calibrationStore(vacConfig)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+	previous, err := store.LoadCalibration()
+	if err != nil {
+		log.Printf("Warning: Failed to load calibration from %s: %v", store, err)
+	}
+
 	// Run ICP alignment for each non-reference vacuum
-	fmt.Println("Running ICP alignment...")
-	fmt.Println(strings.Repeat("-", 60))
+	fmt.Fprintln(out, "Running ICP alignment...")
+	fmt.Fprintln(out, strings.Repeat("-", 60))
+
+	results := make(map[string]mesh.ICPResult, len(maps))
+	rotations := make(map[string]*mesh.CachedRotation, len(maps))
+	calibrated := make(map[string]calibratedVacuum, len(maps))
 
 	for id, m := range maps {
 		if id == refID {
-			fmt.Printf("%-25s: [REFERENCE - identity transform]\n", id)
+			fmt.Fprintf(out, "%-25s: [REFERENCE - identity transform]\n", id)
+			continue
+		}
+		if vc := previous.GetVacuumCalibration(id); vc != nil && previous.ReferenceVacuum == refID && vacConfig.IsFrozen(id) {
+			fmt.Fprintf(out, "%-25s: [FROZEN - keeping cached transform]\n", id)
+			results[id] = mesh.ICPResult{Transform: vc.Transform, Score: vc.ICPScore}
+			rotations[id] = vc.Rotation
+			calibrated[id] = calibratedVacuum{
+				Transform: vc.Transform,
+				Rotation:  mesh.TransformRotation(vc.Transform),
+				Score:     vc.ICPScore,
+				Valid:     mesh.ValidateAlignment(mesh.MultiplyMatrices(vc.Transform, mesh.MirrorTransform(m, vacConfig.MirrorAxis(id)))),
+				Frozen:    true,
+			}
 			continue
 		}
 
@@ -537,33 +930,69 @@ func (a *App) RunCalibration() {
 		srcFeatures := mesh.ExtractFeatures(m)
 		tgtFeatures := mesh.ExtractFeatures(refMap)
 
-		fmt.Printf("%-25s:\n", id)
-		fmt.Printf("  Source: %d walls, %d grid, %d boundary, %d corners, charger=%v\n",
+		fmt.Fprintf(out, "%-25s:\n", id)
+		fmt.Fprintf(out, "  Source: %d walls, %d grid, %d boundary, %d corners, charger=%v\n",
 			len(srcFeatures.WallPoints), len(srcFeatures.GridPoints),
 			len(srcFeatures.BoundaryPoints), len(srcFeatures.Corners), srcFeatures.HasCharger)
-		fmt.Printf("  Target: %d walls, %d grid, %d boundary, %d corners, charger=%v\n",
+		fmt.Fprintf(out, "  Target: %d walls, %d grid, %d boundary, %d corners, charger=%v\n",
 			len(tgtFeatures.WallPoints), len(tgtFeatures.GridPoints),
 			len(tgtFeatures.BoundaryPoints), len(tgtFeatures.Corners), tgtFeatures.HasCharger)
 
 		// Run ICP
 		config := mesh.DefaultICPConfig()
-		result := mesh.AlignMaps(m, refMap, config)
-
+		if vacConfig != nil {
+			config.Weights = vacConfig.ICP.Weights.Weights()
+			if vacConfig.ICP.Init != "" {
+				config.Init = mesh.ICPInit(vacConfig.ICP.Init)
+			}
+		}
+		src, mirror := mesh.MirrorMap(m, vacConfig.MirrorAxis(id))
+		result, rotation, reused := mesh.AlignMapsReusingRotation(id, src, refMap, config, previous)
 		valid := mesh.ValidateAlignment(result.Transform)
+		if mirror != mesh.Identity() {
+			fmt.Fprintf(out, "  Mirrored along %s before ICP\n", vacConfig.MirrorAxis(id))
+			result = result.ComposedWith(mirror)
+		}
+		results[id], rotations[id] = result, rotation
 
 		// Calculate total rotation angle from transform matrix
-		totalRotation := math.Atan2(result.Transform.C, result.Transform.A) * 180 / math.Pi
-		if totalRotation < 0 {
-			totalRotation += 360
+		totalRotation := mesh.TransformRotation(result.Transform)
+		calibrated[id] = calibratedVacuum{
+			Transform:       result.Transform,
+			Rotation:        totalRotation,
+			InitialRotation: result.InitialRotation,
+			RotationReused:  reused,
+			Score:           result.Score,
+			InlierFraction:  result.InlierFraction,
+			Error:           result.Error,
+			Iterations:      result.Iterations,
+			Converged:       result.Converged,
+			Valid:           valid,
+			Covariance:      result.Covariance,
+			Alternatives:    result.Alternatives,
 		}
 
-		fmt.Printf("  ICP result: %d iterations, error=%.2f, score=%.4f, inliers=%.1f%%, converged=%v, valid=%v\n",
+		fmt.Fprintf(out, "  ICP result: %d iterations, error=%.2f, score=%.4f, inliers=%.1f%%, converged=%v, valid=%v\n",
 			result.Iterations, result.Error, result.Score, result.InlierFraction*100, result.Converged, valid)
-		fmt.Printf("  Rotation errors: 0°=%.1f, 90°=%.1f, 180°=%.1f, 270°=%.1f\n",
-			mesh.RotationErrors[0], mesh.RotationErrors[90],
-			mesh.RotationErrors[180], mesh.RotationErrors[270])
-		fmt.Printf("  Initial rotation: %.0f°, Final rotation: %.1f°\n", result.InitialRotation, totalRotation)
-		fmt.Printf("  Translation: (%.1f, %.1f)\n", result.Transform.Tx, result.Transform.Ty)
+		if reused {
+			fmt.Fprintf(out, "  Rotation sweep skipped: maps unchanged since the cached calibration\n")
+		} else {
+			fmt.Fprintf(out, "  Rotation errors: 0°=%.1f, 90°=%.1f, 180°=%.1f, 270°=%.1f\n",
+				mesh.RotationErrors[0], mesh.RotationErrors[90],
+				mesh.RotationErrors[180], mesh.RotationErrors[270])
+		}
+		fmt.Fprintf(out, "  Initial rotation: %.0f°, Final rotation: %.1f°\n", result.InitialRotation, totalRotation)
+		fmt.Fprintf(out, "  Translation: (%.1f, %.1f)\n", result.Transform.Tx, result.Transform.Ty)
+		if result.Covariance != nil {
+			rot, trans := result.Covariance.Interval95()
+			fmt.Fprintf(out, "  95%% confidence: rotation ±%.2f°, translation ±(%.1f, %.1f)\n", rot, trans.X, trans.Y)
+		}
+		if result.Disambiguated {
+			fmt.Fprintf(out, "  Several rotations aligned about as well; room borders picked this one\n")
+		}
+		for _, alt := range result.Alternatives {
+			fmt.Fprintf(out, "  AMBIGUOUS: rotation %.1f° (score %.4f) aligned about as well; set a rotation hint in config to choose\n", alt.Rotation, alt.Score)
+		}
 
 		// Show transformed positions
 		srcPos, srcAngle, _ := mesh.ExtractRobotPosition(m)
@@ -575,21 +1004,21 @@ func (a *App) RunCalibration() {
 		// Adjust robot angle by the transform rotation
 		worldAngle := mesh.TransformAngle(srcAngle, result.Transform)
 
-		fmt.Printf("  Robot: local(%.0f,%.0f) -> world(%.0f,%.0f)\n",
+		fmt.Fprintf(out, "  Robot: local(%.0f,%.0f) -> world(%.0f,%.0f)\n",
 			srcPos.X, srcPos.Y, worldPos.X, worldPos.Y)
-		fmt.Printf("  Robot angle: local=%.0f° -> world=%.0f°\n", srcAngle, worldAngle)
-		fmt.Printf("  Charger: local(%.0f,%.0f) -> world(%.0f,%.0f)\n",
+		fmt.Fprintf(out, "  Robot angle: local=%.0f° -> world=%.0f°\n", srcAngle, worldAngle)
+		fmt.Fprintf(out, "  Charger: local(%.0f,%.0f) -> world(%.0f,%.0f)\n",
 			srcCharger.X, srcCharger.Y, worldCharger.X, worldCharger.Y)
-		fmt.Println()
+		fmt.Fprintln(out)
 	}
 
 	// Show reference vacuum positions
 	refPos, refAngle, _ := mesh.ExtractRobotPosition(refMap)
 	refCharger, _ := mesh.ExtractChargerPosition(refMap)
-	fmt.Println(strings.Repeat("-", 60))
-	fmt.Printf("Reference (%s) positions (world coordinates):\n", refID)
-	fmt.Printf("  Robot: (%.0f, %.0f) angle=%.0f°\n", refPos.X, refPos.Y, refAngle)
-	fmt.Printf("  Charger: (%.0f, %.0f)\n", refCharger.X, refCharger.Y)
+	fmt.Fprintln(out, strings.Repeat("-", 60))
+	fmt.Fprintf(out, "Reference (%s) positions (world coordinates):\n", refID)
+	fmt.Fprintf(out, "  Robot: (%.0f, %.0f) angle=%.0f°\n", refPos.X, refPos.Y, refAngle)
+	fmt.Fprintf(out, "  Charger: (%.0f, %.0f)\n", refCharger.X, refCharger.Y)
 
 	// Save calibration cache
 	now := time.Now().Unix()
@@ -603,107 +1032,397 @@ func (a *App) RunCalibration() {
 		MapAreaAtCalibration: refMap.MetaData.TotalLayerArea,
 	}
 
-	// Re-run alignment to get transforms for cache
-	fmt.Println()
-	fmt.Println(strings.Repeat("-", 60))
-	fmt.Println("Building calibration cache...")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, strings.Repeat("-", 60))
+	fmt.Fprintln(out, "Building calibration cache...")
 	for id, m := range maps {
 		if id == refID {
 			continue
 		}
-		config := mesh.DefaultICPConfig()
-		result := mesh.AlignMaps(m, refMap, config)
+		if calibrated[id].Frozen {
+			cache.Vacuums[id] = previous.Vacuums[id]
+			fmt.Fprintf(out, "  %s: frozen transform kept (rotation %.1f°)\n", id, mesh.TransformRotation(results[id].Transform))
+			continue
+		}
+		result := results[id]
 		cache.Vacuums[id] = mesh.VacuumCalibration{
 			Transform:            result.Transform,
 			LastUpdated:          now,
 			MapAreaAtCalibration: m.MetaData.TotalLayerArea,
+			ICPScore:             result.Score,
+			Rotation:             rotations[id],
+			Covariance:           result.Covariance,
+			Alternatives:         result.Alternatives,
 		}
-		fmt.Printf("  %s: cached transform (rotation %.1f°)\n", id, math.Atan2(result.Transform.C, result.Transform.A)*180/math.Pi)
+		fmt.Fprintf(out, "  %s: cached transform (rotation %.1f°)\n", id, mesh.TransformRotation(result.Transform))
+	}
+
+	// Coverage quality: vacuums sharing rooms should overlap once aligned
+	transforms := make(map[string]mesh.AffineMatrix, len(cache.Vacuums))
+	for id, vc := range cache.Vacuums {
+		transforms[id] = vc.Transform
 	}
+	fmt.Fprintln(out)
+	coverage := mesh.ComputeCoverageStats(maps, transforms, refID)
+	printCoverage(out, coverage)
 
 	// Save to cache file
-	fmt.Printf("\nSaving calibration cache to %s\n", a.CalibrationCache)
-	if err := mesh.SaveCalibration(a.CalibrationCache, &cache); err != nil {
+	fmt.Fprintf(out, "\nSaving calibration cache to %s\n", store)
+	if err := store.SaveCalibration(&cache); err != nil {
 		log.Printf("Warning: Failed to save calibration cache: %v", err)
+		failed.add("saving calibration cache: %v", err)
 	} else {
-		fmt.Println("Calibration cache saved successfully")
+		fmt.Fprintln(out, "Calibration cache saved successfully")
 	}
-}
 
-// RunDetectRotation analyzes wall angles to detect rotation differences between maps
-func (a *App) RunDetectRotation() {
-	pattern := filepath.Join(a.DataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Fatalf("Error finding JSON files: %v", err)
+	if a.JSON {
+		err := printJSON(struct {
+			ReferenceVacuum string                      `json:"referenceVacuum"`
+			Vacuums         map[string]calibratedVacuum `json:"vacuums"`
+			Coverage        mesh.CoverageStats          `json:"coverage"`
+			CacheFile       string                      `json:"cacheFile"`
+		}{refID, calibrated, coverage, a.CalibrationCache})
+		if err != nil {
+			return err
+		}
 	}
+	return failed.err()
+}
 
-	if len(files) == 0 {
-		files, _ = filepath.Glob("ValetudoMapExport-*.json")
+// printCoverage prints the total floor area and how much of it vacuums
+// share.
+func printCoverage(w io.Writer, coverage mesh.CoverageStats) {
+	_, _ = fmt.Fprintf(w, "Coverage: %.2f m² total, %.1f%% seen by more than one vacuum\n",
+		coverage.TotalArea, coverage.CoverageOverlap*100)
+	for _, o := range coverage.Overlaps {
+		_, _ = fmt.Fprintf(w, "  %s / %s: %.2f m² shared (%.1f%% of the smaller map)\n",
+			o.Vacuums[0], o.Vacuums[1], o.AreaM2, o.Fraction*100)
 	}
+}
 
-	if len(files) == 0 {
-		log.Fatal("No ValetudoMapExport-*.json files found")
+// RunStats prints coverage statistics for the JSON exports in --data-dir,
+// aligned with the calibration cache, as JSON with --json.
+func (a *App) RunStats() error {
+	var failed failures
+	maps, err := a.loadExports(false, &failed)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Found %d map export(s)\n\n", len(files))
-
-	// Load all maps
-	maps := make(map[string]*mesh.ValetudoMap)
-	for _, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0]
+	cache, err := mesh.LoadCalibration(a.CalibrationCache)
+	if err != nil {
+		log.Printf("Warning: Failed to load calibration cache %s: %v", a.CalibrationCache, err)
+	}
+	refID := a.ReferenceVacuum
+	if refID == "" && cache != nil {
+		refID = cache.ReferenceVacuum
+	}
+	if refID == "" {
+		refID = mesh.SelectReferenceVacuum(maps, nil)
+	}
 
-		m, err := mesh.ParseMapFile(file)
+	coverage := mesh.ComputeCoverageStats(maps, buildTransforms(maps, cache), refID)
+	if a.JSON {
+		// Same shape as /stats.json
+		err := printJSON(struct {
+			ReferenceVacuum string `json:"referenceVacuum"`
+			VacuumCount     int    `json:"vacuumCount"`
+			mesh.CoverageStats
+		}{refID, len(maps), coverage})
 		if err != nil {
-			fmt.Printf("Error loading %s: %v\n", name, err)
-			continue
+			return err
 		}
-		maps[name] = m
+		return failed.err()
+	}
+	fmt.Printf("Reference vacuum: %s\n", refID)
+	printCoverage(os.Stdout, coverage)
+	return failed.err()
+}
+
+// RunReport aligns the JSON exports in --data-dir and writes an HTML report
+// of the result to path.
+func (a *App) RunReport(path string) error {
+	var failed failures
+	maps, err := a.loadExports(false, &failed)
+	if err != nil {
+		return err
 	}
 
 	if len(maps) < 2 {
-		log.Fatal("Need at least 2 maps for rotation detection")
+		return errors.New("need at least 2 maps for an alignment report")
 	}
 
-	// Select reference vacuum
 	refID := a.ReferenceVacuum
 	if refID == "" {
 		refID = mesh.SelectReferenceVacuum(maps, nil)
 	}
-	refMap := maps[refID]
-
 	fmt.Printf("Reference vacuum: %s\n", refID)
-	fmt.Println(strings.Repeat("=", 70))
+	fmt.Printf("Aligning %d vacuum(s)...\n", len(maps)-1)
 
-	// Analyze wall angles for reference
-	refHist := mesh.ExtractWallAngles(refMap)
-	refDominant := refHist.DominantAngles(4)
-	fmt.Printf("\n%s (reference) dominant wall angles: ", refID)
-	for i, a := range refDominant {
-		if i > 0 {
-			fmt.Print(", ")
-		}
-		fmt.Printf("%.0f°", a)
+	report, err := mesh.BuildAlignmentReport(maps, refID, a.globalRotation(maps, refID), mesh.DefaultICPConfig())
+	if err != nil {
+		return fmt.Errorf("building report: %w", err)
 	}
-	fmt.Printf(" (%d edges analyzed)\n", refHist.TotalEdges)
 
-	// Analyze each other vacuum
-	fmt.Println(strings.Repeat("-", 70))
-	for id, m := range maps {
-		if id == refID {
-			continue
-		}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	if err := mesh.WriteAlignmentReport(f, report); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	fmt.Printf("Created report: %s\n", path)
+	return failed.err()
+}
 
-		fmt.Printf("\n%s:\n", id)
+// RunValidateConfig checks --config and lists every problem found. Problems
+// make it a partial failure, exiting with status 1.
+func (a *App) RunValidateConfig() error {
+	if !validateConfigFile(os.Stdout, a.ConfigFile) {
+		return &partialError{failures: []string{a.ConfigFile + " is not valid"}}
+	}
+	return nil
+}
 
-		// Get wall angle histogram
-		hist := mesh.ExtractWallAngles(m)
-		dominant := hist.DominantAngles(4)
-		fmt.Printf("  Dominant wall angles: ")
-		for i, a := range dominant {
-			if i > 0 {
+// validateConfigFile loads path and writes its problems to w as
+// file:line: field: message, one per line. It reports whether the config is
+// valid.
+func validateConfigFile(w io.Writer, path string) bool {
+	config, err := mesh.LoadConfig(path)
+	var errs mesh.ConfigErrors
+	switch {
+	case errors.As(err, &errs):
+		for _, e := range errs {
+			if e.Line > 0 {
+				fmt.Fprintf(w, "%s:%d: ", path, e.Line)
+			} else {
+				fmt.Fprintf(w, "%s: ", path)
+			}
+			if e.Field != "" {
+				fmt.Fprintf(w, "%s: ", e.Field)
+			}
+			fmt.Fprintln(w, e.Message)
+		}
+		fmt.Fprintf(w, "%d problem(s) found\n", len(errs))
+		return false
+	case err != nil:
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return false
+	}
+	fmt.Fprintf(w, "%s: OK (%d vacuum(s))\n", path, len(config.Vacuums))
+	return true
+}
+
+// RunPrune removes files in --data-dir outside the retention policy in
+// config.yaml.
+func (a *App) RunPrune() error {
+	config, err := mesh.LoadConfig(a.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("loading config: %w (looked at %s)", err, a.ConfigFile)
+	}
+	policy := mesh.RetentionPolicyFromConfig(config)
+	if !policy.Enabled() {
+		return fmt.Errorf("no retention policy in %s; set retention.maxFiles or retention.maxAge", a.ConfigFile)
+	}
+
+	pruned, err := mesh.PruneDataDir(a.DataDir, configVacuumIDs(config), policy, time.Now())
+	var freed int64
+	for _, p := range pruned {
+		fmt.Printf("Removed %s\n", p.Path)
+		freed += p.Size
+	}
+	if err != nil {
+		return fmt.Errorf("pruning %s: %w", a.DataDir, err)
+	}
+	fmt.Printf("Removed %d file(s), %.1f MB\n", len(pruned), float64(freed)/(1<<20))
+	return nil
+}
+
+// pruneDataDir applies the retention policy every interval until ctx is
+// cancelled, starting immediately.
+func (a *App) pruneDataDir(ctx context.Context, config *mesh.Config) {
+	policy := mesh.RetentionPolicyFromConfig(config)
+	interval, _ := config.Retention.PruneInterval() // validated by LoadConfig
+	ids := configVacuumIDs(config)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pruned, err := mesh.PruneDataDir(a.DataDir, ids, policy, time.Now())
+		if err != nil {
+			log.Printf("Warning: retention cleanup: %v", err)
+		}
+		if len(pruned) > 0 {
+			log.Printf("Retention cleanup removed %d file(s) from %s", len(pruned), a.DataDir)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// heatmapSaveInterval is how often the heatmap is saved, bounding the
+// visits lost to a crash.
+const heatmapSaveInterval = 5 * time.Minute
+
+// saveHeatmap drops visits older than HeatmapRetentionDays and saves the
+// heatmap to store every heatmapSaveInterval until ctx is cancelled.
+func (a *App) saveHeatmap(ctx context.Context, store mesh.Store) {
+	ticker := time.NewTicker(heatmapSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		heatmap := a.StateTracker.Heatmap()
+		heatmap.Prune(time.Now().AddDate(0, 0, -mesh.HeatmapRetentionDays))
+		if err := store.SaveHeatmap(heatmap); err != nil {
+			log.Printf("Warning: saving heatmap: %v", err)
+		}
+	}
+}
+
+// stateSaveInterval is how often the live state is saved, bounding what a
+// crash loses.
+const stateSaveInterval = time.Minute
+
+// saveState saves the StateTracker snapshot to store every
+// stateSaveInterval until ctx is cancelled.
+func (a *App) saveState(ctx context.Context, store mesh.Store) {
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := store.SaveState(a.StateTracker.Snapshot()); err != nil {
+			log.Printf("Warning: saving state: %v", err)
+		}
+	}
+}
+
+// configVacuumIDs returns the IDs of the vacuums in config.
+func configVacuumIDs(config *mesh.Config) []string {
+	ids := make([]string, len(config.Vacuums))
+	for i, v := range config.Vacuums {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+// RunRemote runs a CLI command against the service at --remote instead of
+// local files.
+func (a *App) RunRemote(command string) error {
+	client, err := newRemoteClient(a.Remote, os.Stdout)
+	if err != nil {
+		return err
+	}
+	client.json = a.JSON
+	client.token = os.Getenv(remoteTokenEnv)
+	if !a.JSON {
+		fmt.Printf("Using remote service %s\n", client.base)
+	}
+
+	switch command {
+	case remoteRender:
+		if a.Crop != nil {
+			log.Printf("Warning: --crop is not supported with --remote")
+		}
+		if a.WorldFile {
+			log.Printf("Warning: --world-file is not supported with --remote")
+		}
+		err = client.render(a.RenderFormat, a.VectorFormat, a.OutputFile)
+	case remoteCalibrate:
+		err = client.calibrate()
+	case remoteStats:
+		err = client.stats()
+	default:
+		err = fmt.Errorf("unknown remote command %q", command)
+	}
+	if err != nil {
+		return fmt.Errorf("remote %s: %w", command, err)
+	}
+	return nil
+}
+
+// RunDetectRotation analyzes wall angles to detect rotation differences between maps
+// and prints the detected rotations as a config.yaml snippet, or the full
+// analysis as JSON with --json. With --apply it also writes them to the
+// config file.
+func (a *App) RunDetectRotation() error {
+	var failed failures
+	maps, err := a.loadExports(true, &failed)
+	if err != nil {
+		return err
+	}
+
+	if len(maps) < 2 {
+		return errors.New("need at least 2 maps for rotation detection")
+	}
+
+	// Select reference vacuum
+	refID := a.ReferenceVacuum
+	if refID == "" {
+		refID = mesh.SelectReferenceVacuum(maps, nil)
+	}
+	refMap := maps[refID]
+
+	if a.JSON {
+		report := mesh.AnalyzeRotations(maps, refID)
+		if err := printJSON(report); err != nil {
+			return err
+		}
+		if a.Apply {
+			if err := mesh.ApplyRotationSuggestions(a.ConfigFile, report.Suggestions()); err != nil {
+				return fmt.Errorf("applying rotations: %w", err)
+			}
+			log.Printf("Wrote rotations to %s", a.ConfigFile)
+		}
+		return failed.err()
+	}
+
+	fmt.Printf("Reference vacuum: %s\n", refID)
+	fmt.Println(strings.Repeat("=", 70))
+
+	// Analyze wall angles for reference
+	refHist := mesh.ExtractWallAngles(refMap)
+	refDominant := refHist.DominantAngles(4)
+	fmt.Printf("\n%s (reference) dominant wall angles: ", refID)
+	for i, a := range refDominant {
+		if i > 0 {
+			fmt.Print(", ")
+		}
+		fmt.Printf("%.0f°", a)
+	}
+	fmt.Printf(" (%d edges analyzed)\n", refHist.TotalEdges)
+
+	// Analyze each other vacuum
+	fmt.Println(strings.Repeat("-", 70))
+	var suggestions []mesh.RotationSuggestion
+	for _, id := range sortedKeys(maps) {
+		if id == refID {
+			continue
+		}
+		m := maps[id]
+
+		fmt.Printf("\n%s:\n", id)
+
+		// Get wall angle histogram
+		hist := mesh.ExtractWallAngles(m)
+		dominant := hist.DominantAngles(4)
+		fmt.Printf("  Dominant wall angles: ")
+		for i, a := range dominant {
+			if i > 0 {
 				fmt.Print(", ")
 			}
 			fmt.Printf("%.0f°", a)
@@ -723,69 +1442,364 @@ func (a *App) RunDetectRotation() {
 		}
 		fmt.Printf("  Detected rotation: %.0f° (confidence: %.1f%%)\n",
 			analysis.BestRotation, analysis.Confidence*100)
+
+		suggestions = append(suggestions, mesh.RotationSuggestion{
+			VacuumID:   id,
+			Rotation:   analysis.BestRotation,
+			Score:      analysis.Scores[analysis.BestRotation],
+			Confidence: analysis.Confidence,
+		})
 	}
 
 	fmt.Println(strings.Repeat("=", 70))
-	fmt.Println("\nTo apply detected rotations, use:")
-	fmt.Print("  --force-rotation=\"")
-	first := true
-	for id, m := range maps {
-		if id == refID {
+	fmt.Println("\nTo apply detected rotations, add to config.yaml:")
+	fmt.Println()
+	fmt.Print(mesh.FormatRotationSnippet(suggestions))
+
+	forced := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		forced[i] = fmt.Sprintf("%s=%.0f", s.VacuumID, s.Rotation)
+	}
+	fmt.Printf("\nor render with:\n  --force-rotation=\"%s\"\n", strings.Join(forced, ","))
+
+	if !a.Apply {
+		return failed.err()
+	}
+	if err := mesh.ApplyRotationSuggestions(a.ConfigFile, suggestions); err != nil {
+		return fmt.Errorf("applying rotations: %w", err)
+	}
+	fmt.Printf("\nWrote rotations to %s\n", a.ConfigFile)
+	return failed.err()
+}
+
+// RunExportHints prints the calibration cache as placement hints for other
+// map viewers, either as plain-text instructions or as a map card snippet
+func (a *App) RunExportHints(format string) error {
+	cache, err := mesh.LoadCalibration(a.CalibrationCache)
+	if err != nil {
+		return fmt.Errorf("loading calibration cache %s: %w", a.CalibrationCache, err)
+	}
+	if cache == nil || len(cache.Vacuums) == 0 {
+		return fmt.Errorf("no calibration in %s; run --render or the service first", a.CalibrationCache)
+	}
+
+	// Config only supplies display names
+	var config *mesh.Config
+	if _, err := os.Stat(a.ConfigFile); err == nil {
+		if config, err = mesh.LoadConfig(a.ConfigFile); err != nil {
+			log.Printf("Warning: Failed to load config file %s: %v", a.ConfigFile, err)
+		}
+	}
+
+	// Offsets are converted to meters with the reference map's grid size
+	// when its export is available, otherwise Valetudo's default
+	pixelSize := 0.0
+	exports := a.exportNames()
+	files, _ := exports.Find(a.DataDir)
+	for _, file := range files {
+		if id, _ := exports.VacuumID(file); id != cache.ReferenceVacuum {
 			continue
 		}
-		analysis := mesh.DetectRotationWithFeatures(m, refMap)
-		if !first {
-			fmt.Print(",")
+		if m, err := mesh.ParseMapFile(file); err == nil {
+			pixelSize = float64(m.PixelSize)
+			break
+		}
+	}
+
+	hints := mesh.PlacementHints(cache, config, pixelSize)
+	if format == mesh.HintsFormatMapCard {
+		err = mesh.WriteHintsMapCard(os.Stdout, hints)
+	} else {
+		err = mesh.WriteHintsText(os.Stdout, hints)
+	}
+	if err != nil {
+		return fmt.Errorf("writing hints: %w", err)
+	}
+	return nil
+}
+
+// RunExportROS builds the unified map from the map exports and the
+// calibration cache and writes it as a ROS occupancy grid: path.pgm and the
+// map_server metadata path.yaml next to it. A .pgm or .yaml suffix on path
+// is dropped.
+func (a *App) RunExportROS(path string) error {
+	cache, err := mesh.LoadCalibration(a.CalibrationCache)
+	if err != nil {
+		return fmt.Errorf("loading calibration cache %s: %w", a.CalibrationCache, err)
+	}
+	if cache == nil || len(cache.Vacuums) == 0 {
+		return fmt.Errorf("no calibration in %s; run --render or the service first", a.CalibrationCache)
+	}
+
+	var failed failures
+	maps, err := a.loadExports(true, &failed)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(a.ConfigFile); err == nil {
+		if config, err := mesh.LoadConfig(a.ConfigFile); err != nil {
+			log.Printf("Warning: Failed to load config file %s: %v", a.ConfigFile, err)
+		} else {
+			a.StateTracker.SetSegmentMerge(config.Unify.SegmentMerge)
+			a.StateTracker.SetDoubleWalls(config.Unify.DoubleWalls)
+		}
+	}
+	for id, m := range maps {
+		a.StateTracker.UpdateMap(id, m)
+	}
+	if err := a.StateTracker.UpdateUnifiedMap(cache); err != nil {
+		return fmt.Errorf("building unified map: %w", err)
+	}
+	grid := mesh.BuildOccupancyGrid(a.StateTracker.GetUnifiedMap(), mesh.DefaultOccupancyResolution)
+	if grid == nil {
+		return errors.New("unified map has no walls or floors to export")
+	}
+
+	base := strings.TrimSuffix(strings.TrimSuffix(path, ".pgm"), ".yaml")
+	pgm, err := os.Create(base + ".pgm")
+	if err != nil {
+		return fmt.Errorf("creating %s.pgm: %w", base, err)
+	}
+	if err := grid.WritePGM(pgm); err != nil {
+		_ = pgm.Close()
+		return fmt.Errorf("writing %s.pgm: %w", base, err)
+	}
+	if err := pgm.Close(); err != nil {
+		return fmt.Errorf("writing %s.pgm: %w", base, err)
+	}
+
+	var yaml strings.Builder
+	if err := grid.WriteYAML(&yaml, filepath.Base(base)+".pgm"); err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".yaml", []byte(yaml.String()), 0644); err != nil {
+		return fmt.Errorf("writing %s.yaml: %w", base, err)
+	}
+	fmt.Printf("Wrote %s.pgm and %s.yaml (%dx%d cells at %g m)\n", base, base, grid.Width, grid.Height, grid.Resolution)
+	return failed.err()
+}
+
+// calibrationStore opens the storage backend config selects for the
+// one-shot commands that update the calibration, the file backend at the
+// calibration cache when config is nil.
+func (a *App) calibrationStore(config *mesh.Config) (mesh.Store, error) {
+	var storage mesh.StorageConfig
+	if config != nil {
+		storage = config.Storage
+	}
+	store, err := mesh.OpenStore(storage, a.DataDir, a.CalibrationCache)
+	if err != nil {
+		return nil, fmt.Errorf("opening storage: %w", err)
+	}
+	return store, nil
+}
+
+// servicePaths returns the config and calibration cache paths the service
+// uses: when --data-dir is set and --config is still the default, the
+// config is read from the data directory. The cache path was already
+// resolved against the data directory by ApplyOptions.
+func (a *App) servicePaths() (configPath, cachePath string) {
+	configPath = a.ConfigFile
+	if a.DataDir != "." && configPath == "config.yaml" {
+		configPath = filepath.Join(a.DataDir, "config.yaml")
+	}
+	return configPath, a.CalibrationCache
+}
+
+// RunRebaseReference makes vacuumID the reference vacuum by recomputing the
+// stored transforms relative to it, composing the existing ones instead of
+// re-running ICP. It works on the storage backend the service would use.
+func (a *App) RunRebaseReference(vacuumID string) error {
+	configPath, cachePath := a.servicePaths()
+
+	// Config only selects the storage backend
+	config := &mesh.Config{}
+	if _, err := os.Stat(configPath); err == nil {
+		if config, err = mesh.LoadConfig(configPath); err != nil {
+			return fmt.Errorf("loading config: %w (looked at %s)", err, configPath)
+		}
+	}
+	store, err := mesh.OpenStore(config.Storage, a.DataDir, cachePath)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	cache, err := store.LoadCalibration()
+	if err != nil {
+		return fmt.Errorf("loading calibration from %s: %w", store, err)
+	}
+	if cache == nil || len(cache.Vacuums) == 0 {
+		return fmt.Errorf("no calibration in %s; run --render or the service first", store)
+	}
+	if cache.ReferenceVacuum == vacuumID {
+		fmt.Printf("%s is already the reference vacuum\n", vacuumID)
+		return nil
+	}
+
+	rebased, err := cache.Rebase(vacuumID)
+	if err != nil {
+		return fmt.Errorf("cannot rebase onto %s: %w", vacuumID, err)
+	}
+	if err := store.SaveCalibration(rebased); err != nil {
+		return fmt.Errorf("saving calibration to %s: %w", store, err)
+	}
+
+	fmt.Printf("Reference vacuum: %s -> %s\n", cache.ReferenceVacuum, vacuumID)
+	ids := make([]string, 0, len(rebased.Vacuums))
+	for id := range rebased.Vacuums {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t := rebased.Vacuums[id].Transform
+		fmt.Printf("  %s: rotation %.1f°, translation (%.1f, %.1f)\n", id, mesh.TransformRotation(t), t.Tx, t.Ty)
+	}
+	fmt.Printf("Saved to %s\n", store)
+	if config.Reference != "" && config.Reference != vacuumID {
+		fmt.Printf("Note: %s sets reference: %s; change it to %s before restarting the service\n", configPath, config.Reference, vacuumID)
+	}
+	return nil
+}
+
+// RunRollbackCalibration restores a previous version of the calibration
+// cache, or with version "list" prints the versions kept. Versions are
+// numbered from 1, the newest backup, or named by their timestamp. A running
+// service picks up the restored cache without a restart.
+func (a *App) RunRollbackCalibration(version string) error {
+	configPath, cachePath := a.servicePaths()
+
+	// Config only selects the storage backend and the history length
+	config := &mesh.Config{}
+	if _, err := os.Stat(configPath); err == nil {
+		if config, err = mesh.LoadConfig(configPath); err != nil {
+			return fmt.Errorf("loading config: %w (looked at %s)", err, configPath)
+		}
+	}
+	store, err := mesh.OpenStore(config.Storage, a.DataDir, cachePath)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+	fs, ok := store.(*mesh.FileStore)
+	if !ok {
+		return fmt.Errorf("calibration history is only kept by the file storage backend, not %s", store)
+	}
+
+	if version == "list" {
+		backups, err := mesh.ListCalibrationBackups(cachePath)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups of %s\n", cachePath)
+			return nil
 		}
-		fmt.Printf("%s=%.0f", id, analysis.BestRotation)
-		first = false
+		fmt.Printf("Backups of %s, newest first:\n", cachePath)
+		for i, b := range backups {
+			summary := "unreadable"
+			if cal, err := mesh.LoadCalibration(b.Path); err == nil && cal != nil {
+				summary = fmt.Sprintf("reference %s, %d vacuums", cal.ReferenceVacuum, len(cal.Vacuums))
+			}
+			fmt.Printf("  %d  %s  %s  (%s)\n", i+1, b.Stamp(), b.Saved.Local().Format("2006-01-02 15:04:05"), summary)
+		}
+		return nil
+	}
+
+	backup, err := mesh.FindCalibrationBackup(cachePath, version)
+	if err != nil {
+		return err
 	}
-	fmt.Println("\"")
+	cal, err := mesh.RollbackCalibration(cachePath, backup, fs.CalibrationBackups())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored calibration saved %s (reference %s, %d vacuums) to %s\n",
+		backup.Saved.Local().Format("2006-01-02 15:04:05"), cal.ReferenceVacuum, len(cal.Vacuums), cachePath)
+	fmt.Println("The replaced calibration is now backup 1; --rollback-calibration=1 undoes the rollback")
+	return nil
 }
 
-// RunService starts the combined MQTT and/or HTTP service
-func (a *App) RunService() {
+// RunService starts the combined MQTT and/or HTTP service and runs until
+// interrupted. Setup failures are returned; a server that fails later exits
+// the process with exitFatal.
+func (a *App) RunService() error {
 	fmt.Println("Starting tudomesh service...")
 
 	// 1. Resolve configuration paths relative to data-dir if provided
-	resolvedConfig := a.ConfigFile
-	resolvedCache := a.CalibrationCache
+	resolvedConfig, resolvedCache := a.servicePaths()
 
-	// If data-dir is specified and files are still pointing to defaults,
-	// resolve them relative to the data-dir.
-	if a.DataDir != "." {
-		if resolvedConfig == "config.yaml" {
-			resolvedConfig = filepath.Join(a.DataDir, "config.yaml")
+	// 2. Load config.yaml (required, except to simulate vacuums)
+	var config *mesh.Config
+	var sim *mesh.Simulator
+	var err error
+	if _, statErr := os.Stat(resolvedConfig); a.Simulate > 0 && errors.Is(statErr, os.ErrNotExist) {
+		config = &mesh.Config{}
+		log.Printf("No config at %s; simulating with defaults", resolvedConfig)
+	} else {
+		config, err = mesh.LoadConfig(resolvedConfig)
+		if err != nil {
+			return fmt.Errorf("loading config: %w (looked at %s)", err, resolvedConfig)
+		}
+		log.Printf("Loaded config from %s", resolvedConfig)
+	}
+	if a.Simulate > 0 {
+		if sim, err = mesh.NewSimulator(a.Simulate); err != nil {
+			return err
 		}
-		if resolvedCache == ".calibration-cache.json" {
-			resolvedCache = filepath.Join(a.DataDir, ".calibration-cache.json")
+		if a.SimulateMQTT && config.MQTT.Broker == "" {
+			return errors.New("--simulate-mqtt needs mqtt.broker in config.yaml")
 		}
+		config.Vacuums = append(config.Vacuums, sim.Vacuums()...)
+		fmt.Printf("Simulating %d vacuums\n", a.Simulate)
+	}
+	a.Config = config
+
+	// Apply memory budget (soft GC limit) for constrained devices
+	if budget := mesh.NewMemoryBudget(&config.Memory); budget.Limited() {
+		budget.Apply()
+		log.Printf("Memory budget: %d MiB (downsample factor %d)", config.Memory.BudgetMB, config.Memory.Downsample)
 	}
 
-	// 2. Load config.yaml (required)
-	config, err := mesh.LoadConfig(resolvedConfig)
+	// Slow renders, map messages, calibrations and rebuilds log their stages
+	slow, _ := config.Tracing.Threshold() // validated by LoadConfig
+	mesh.SetSlowThreshold(slow)
+
+	// Open the storage backend for calibration and map state
+	store, err := mesh.OpenStore(config.Storage, a.DataDir, resolvedCache)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v (looked at %s)", err, resolvedConfig)
+		return fmt.Errorf("opening storage: %w", err)
 	}
-	a.Config = config
-	log.Printf("Loaded config from %s", resolvedConfig)
+	a.Store = store
+	log.Printf("Storage backend: %s", store)
+
+	writeInterval, _ := config.Storage.WriteInterval() // validated by LoadConfig
+	a.MapWriter = mesh.NewMapWriter(store, writeInterval)
 
 	// Check if data directory is writable (for cache and map persistence)
-	if err := a.checkWritability(a.DataDir); err != nil {
-		log.Printf("WARNING: Data directory %s is not writable: %v", a.DataDir, err)
-		log.Printf("Auto-calibration and map caching will fail. Use 'chown 65532' if running in Docker.")
+	if fs, ok := store.(*mesh.FileStore); ok {
+		fs.Exports = config.ExportNames()
+		if err := a.checkWritability(a.DataDir); err != nil {
+			log.Printf("WARNING: Data directory %s is not writable: %v", a.DataDir, err)
+			log.Printf("Auto-calibration and map caching will fail. Use 'chown 65532' if running in Docker,")
+			log.Printf("or configure storage.backend: sqlite with a writable path.")
+		}
 	}
 
 	// 3. Load calibration cache (optional but recommended)
 	var cache *mesh.CalibrationData
-	cache, err = mesh.LoadCalibration(resolvedCache)
+	cache, err = store.LoadCalibration()
 	if err != nil {
-		log.Printf("Warning: Failed to load calibration cache %s: %v", resolvedCache, err)
+		log.Printf("Warning: Failed to load calibration cache from %s: %v", store, err)
 	} else if cache != nil {
-		a.Calibration = cache
-		log.Printf("Loaded calibration cache from %s", resolvedCache)
+		a.SetCalibration(cache)
+		log.Printf("Loaded calibration cache from %s", store)
+	} else if sim != nil {
+		// The simulated vacuums share one frame
+		cache = sim.Calibration()
+		a.SetCalibration(cache)
 	} else {
-		log.Printf("Warning: No calibration cache found at %s. Positions will not be transformed.", resolvedCache)
+		log.Printf("Warning: No calibration cache found in %s. Positions will not be transformed.", store)
 		log.Printf("Run './tudomesh --calibrate' to generate it.")
 	}
 
@@ -802,49 +1816,93 @@ func (a *App) RunService() {
 		log.Println("Reference vacuum: (will auto-select on first map data)")
 	}
 
-	// Set colors from config
+	// Set colors and display names from config
 	for _, vc := range config.Vacuums {
 		if vc.Color != "" {
 			a.StateTracker.SetColor(vc.ID, vc.Color)
 		}
+		if vc.DisplayName != "" {
+			a.StateTracker.SetDisplayName(vc.ID, vc.DisplayName)
+		}
 	}
+	a.StateTracker.SetSegmentMerge(config.Unify.SegmentMerge)
+	a.StateTracker.SetDoubleWalls(config.Unify.DoubleWalls)
 
-	// 5. Load initial maps from JSON exports if available
-	initialMaps := a.loadInitialMaps(a.DataDir)
+	// 5. Load initial maps from JSON exports if available. The saved map of
+	// a vacuum that accumulates its runs is where accumulation resumes.
+	a.Accumulator = mesh.NewMapAccumulator(config)
+	initialMaps := a.loadInitialMaps(store)
 	for id, m := range initialMaps {
+		a.Accumulator.Seed(id, m)
 		a.StateTracker.UpdateMap(id, m)
 		// Also extract initial position
-		if robotPos, robotAngle, ok := mesh.ExtractRobotPosition(m); ok {
-			var gridX, gridY, worldAngle float64
-			// Convert robot position from mm to grid coordinates
-			// (Valetudo entity.Points are in mm, layer.Pixels are in grid units)
-			pixelSize := float64(m.PixelSize)
-			if pixelSize == 0 {
-				pixelSize = 5 // default
+		a.updatePositionFromMap(id, m, cache)
+	}
+	if len(initialMaps) > 0 {
+		fmt.Printf("Loaded %d initial maps from %s\n", len(initialMaps), store)
+	}
+	a.StateTracker.SetStore(store)
+
+	// Visit counts for /heatmap.png and the last positions, battery levels
+	// and cleaning areas are kept by the storage backend. The snapshot is
+	// newer than positions from stored maps.
+	if heatmap, err := store.LoadHeatmap(); err != nil {
+		log.Printf("Warning: loading heatmap from %s: %v; starting a new heatmap", store, err)
+	} else if heatmap != nil {
+		a.StateTracker.SetHeatmap(heatmap)
+	}
+	if snapshot, err := store.LoadState(); err != nil {
+		log.Printf("Warning: loading state from %s: %v; starting without saved state", store, err)
+	} else if snapshot != nil {
+		a.StateTracker.Restore(snapshot)
+		fmt.Printf("Restored live state saved %s from %s\n", snapshot.SavedAt.Local().Format(time.RFC3339), store)
+	}
+
+	// Cancelled on shutdown to stop the watcher and replay
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+	go a.saveHeatmap(runCtx, store)
+	go a.saveState(runCtx, store)
+
+	// 6. Reload exports dropped into the data directory while running
+	if a.Watch {
+		watcher := mesh.NewMapWatcher(a.DataDir)
+		watcher.Options = a.parseOptions()
+		watcher.Exports = config.ExportNames()
+		watcher.Prime()
+		go func() {
+			if err := watcher.Watch(runCtx, a.applyWatchedMap); err != nil {
+				log.Printf("Warning: --watch disabled: %v", err)
 			}
-			gridPos := mesh.Point{X: robotPos.X / pixelSize, Y: robotPos.Y / pixelSize}
-
-			if cache != nil {
-				transform := cache.GetTransform(id)
-				// Transform works in grid coordinates
-				transformedPos := mesh.TransformPoint(gridPos, transform)
-				gridX = transformedPos.X
-				gridY = transformedPos.Y
-				worldAngle = mesh.TransformAngle(robotAngle, transform)
-			} else {
-				// No calibration - use grid coords directly
-				gridX = gridPos.X
-				gridY = gridPos.Y
-				worldAngle = robotAngle
+		}()
+		fmt.Printf("Watching %s for map exports\n", a.DataDir)
+	}
+
+	// Pick up a calibration cache rewritten by another process, such as
+	// --calibrate run while the service is up
+	if fs, ok := store.(*mesh.FileStore); ok && fs.CalibrationPath != "" {
+		calWatcher := mesh.NewCalibrationWatcher(fs.CalibrationPath)
+		calWatcher.Prime()
+		go func() {
+			if err := calWatcher.Watch(runCtx, a.reloadCalibration); err != nil {
+				log.Printf("Warning: calibration cache reload disabled: %v", err)
 			}
-			a.StateTracker.UpdatePosition(id, gridX, gridY, worldAngle)
-		}
+		}()
 	}
-	if len(initialMaps) > 0 {
-		fmt.Printf("Loaded %d initial maps from JSON exports\n", len(initialMaps))
+
+	// Slow work runs here so MQTT callbacks return quickly and position
+	// updates from other robots keep flowing
+	a.Work = mesh.NewWorkQueue()
+	go a.Work.Run(runCtx)
+
+	// Keep the data directory from growing without bound
+	if mesh.RetentionPolicyFromConfig(config).Enabled() {
+		go a.pruneDataDir(runCtx, config)
 	}
 
 	// 7. Start MQTT if enabled
+	var replay, simFeed *mesh.ReplayClient
+	var recorder *mesh.Recorder
 	if a.MqttMode {
 		// Create message handler that updates state tracker
 		messageHandler := func(vacuumID string, rawPayload []byte, mapData *mesh.ValetudoMap, err error) {
@@ -862,128 +1920,141 @@ func (a *App) RunService() {
 
 			if err != nil {
 				log.Printf("Error receiving map data for %s: %v", vacuumID, err)
+				a.StateTracker.Health().RecordParseError(vacuumID, err, time.Now())
 				return
 			}
+			a.StateTracker.Health().RecordMessage(vacuumID, time.Now())
+			_, _ = a.receiveMap(vacuumID, mapData)
+		}
 
-			// Update state tracker with new map only if it contains drawable content
-			// This prevents lightweight MQTT updates from overwriting the rich floorplan loaded from disk
-			if mesh.HasDrawablePixels(mapData) {
-				a.StateTracker.UpdateMap(vacuumID, mapData)
-			}
-
-			// Debug: log map data stats
-			log.Printf("[DEBUG] %s: received map data - pixelSize=%d, layers=%d, entities=%d",
-				vacuumID, mapData.PixelSize, len(mapData.Layers), len(mapData.Entities))
-
-			// Extract robot position and angle from map data
-			robotPos, robotAngle, ok := mesh.ExtractRobotPosition(mapData)
-			if !ok {
-				log.Printf("[DEBUG] %s: robot_position entity not found in %d entities", vacuumID, len(mapData.Entities))
-				for i, e := range mapData.Entities {
-					log.Printf("[DEBUG]   entity[%d]: type=%s, points=%d", i, e.Type, len(e.Points))
-				}
-				return
-			}
-
-			// Convert robot position from mm to grid coordinates
-			// (Valetudo entity.Points are in mm, layer.Pixels are in grid units)
-			pixelSize := float64(mapData.PixelSize)
-			if pixelSize == 0 {
-				pixelSize = 5 // default
+		// Initialize MQTT client, or feed the handlers from a recording or
+		// the simulator
+		var mqttClient *mesh.MQTTClient
+		if a.Replay != "" {
+			replay = mesh.NewReplayClient()
+			mqttClient = mesh.NewReplayMQTT(config, messageHandler, replay)
+		} else if sim != nil && !a.SimulateMQTT {
+			simFeed = mesh.NewReplayClient()
+			mqttClient = mesh.NewReplayMQTT(config, messageHandler, simFeed)
+		} else {
+			mqttClient, err = mesh.InitMQTT(config, messageHandler)
+			if err != nil {
+				return fmt.Errorf("initializing MQTT: %w", err)
 			}
-			gridPos := mesh.Point{X: robotPos.X / pixelSize, Y: robotPos.Y / pixelSize}
-
-			// Auto-cache map to disk if it contains drawable data
-			if mesh.HasDrawablePixels(mapData) {
-				cachePath := filepath.Join(a.DataDir, fmt.Sprintf("ValetudoMapExport-%s.json", vacuumID))
-				// Save map data to disk for persistent floorplan (async)
-				go func(p string, d *mesh.ValetudoMap) {
-					// Encode back to JSON
-					jsonBytes, err := json.MarshalIndent(d, "", "  ")
-					if err == nil {
-						if err := os.WriteFile(p, jsonBytes, 0644); err == nil {
-							log.Printf("[DEBUG] Cached map for %s to %s", vacuumID, p)
-						}
-					}
-				}(cachePath, mapData)
-			}
-
-			// Transform position if calibration available
-			var gridX, gridY, worldAngle float64
-
-			if a.Calibration != nil {
-				transform := a.Calibration.GetTransform(vacuumID)
-				// Transform works in grid coordinates
-				transformedPos := mesh.TransformPoint(gridPos, transform)
-				gridX = transformedPos.X
-				gridY = transformedPos.Y
-
-				// Calculate rotation from transform matrix and apply to angle
-				worldAngle = mesh.TransformAngle(robotAngle, transform)
-
-				log.Printf("[CALIBRATION] %s: transform(A=%.4f,C=%.4f) rotation=%.1f° localAngle=%.0f° -> worldAngle=%.0f°",
-					vacuumID, transform.A, transform.C,
-					math.Atan2(transform.C, transform.A)*180/math.Pi,
-					robotAngle, worldAngle)
-			} else {
-				// No calibration - use grid coordinates directly
-				gridX = gridPos.X
-				gridY = gridPos.Y
-				worldAngle = robotAngle
-				log.Printf("[CALIBRATION] %s: no calibration loaded, using raw angle=%.0f°", vacuumID, robotAngle)
-			}
-
-			// Update state tracker with position (in grid coords)
-			a.StateTracker.UpdatePosition(vacuumID, gridX, gridY, worldAngle)
-
-			// Always log the position update for debugging
-			log.Printf("%s: pos(%.0f,%.0f) / pixelSize=%d -> grid(%.1f,%.1f) -> world(%.1f,%.1f,%.0f°)",
-				vacuumID, robotPos.X, robotPos.Y, mapData.PixelSize,
-				gridPos.X, gridPos.Y, gridX, gridY, worldAngle)
-
-			// Publish transformed position
-			if a.Publisher != nil {
-				if err := a.Publisher.PublishPosition(vacuumID, gridX, gridY, worldAngle); err != nil {
-					log.Printf("Error publishing position for %s: %v", vacuumID, err)
-				}
+			if mqttClient == nil {
+				return errors.New("MQTT broker not configured in config.yaml")
 			}
 		}
-
-		// Initialize MQTT client
-		mqttClient, err := mesh.InitMQTT(config, messageHandler)
-		if err != nil {
-			log.Fatalf("Failed to initialize MQTT: %v", err)
-		}
 		a.MQTTClient = mqttClient
 
-		if mqttClient == nil {
-			log.Fatal("MQTT broker not configured in config.yaml")
+		if a.Record != "" {
+			recorder, err = mesh.NewRecorder(a.Record, int64(a.RecordMaxMB)<<20, a.RecordFiles)
+			if err != nil {
+				return fmt.Errorf("starting recording: %w", err)
+			}
+			mqttClient.SetRecorder(recorder)
+			fmt.Printf("Recording MQTT messages to %s\n", a.Record)
 		}
 
 		// Initialize publisher now that we have MQTT client
-		a.Publisher = mesh.NewPublisher(mqttClient.GetClient())
+		a.Positions = mesh.NewDebouncer(mesh.DefaultPositionsDebounce, a.publishCombinedPositions)
+		publisher := mesh.NewPublisher(mqttClient.OutputClient())
+		publisher.Configure(config.MQTT.Publish)
+		a.Publisher = publisher
+		for _, vc := range config.Vacuums {
+			if vc.DisplayName != "" {
+				a.Publisher.SetDisplayName(vc.ID, vc.DisplayName)
+			}
+		}
 		fmt.Println("MQTT position publisher initialized")
 
+		changes, unsubscribe := a.StateTracker.Changes().Subscribe()
+		defer unsubscribe()
+		go a.publishMapChanges(changes)
+		if interval, _ := config.MQTT.SummaryRepublishInterval(); interval > 0 { // validated by LoadConfig
+			go a.republishMapSummary(runCtx, interval)
+		}
+
 		// Initialize auto-calibrator and register docking handler
-		a.AutoCalibrator = mesh.NewAutoCalibrator(config, cache, resolvedCache, a.DataDir, a.StateTracker)
-		mqttClient.SetDockingHandler(a.AutoCalibrator.OnDockingEvent)
+		a.initAutoCalibrator(config, cache, store)
+		mqttClient.SetDockingHandler(func(vacuumID string) {
+			if !a.isLeader() {
+				log.Printf("[CLUSTER] %s docked; standby instance skipping calibration", config.DisplayName(vacuumID))
+				return
+			}
+			if !a.Work.Enqueue("calibrate/"+vacuumID, func() { a.AutoCalibrator.OnDockingEvent(vacuumID) }) {
+				log.Printf("[AUTO-CAL] %s: calibration already queued", config.DisplayName(vacuumID))
+			}
+		})
 		fmt.Println("Auto-calibrator initialized (triggers on docking events)")
+
+		a.Battery = mesh.NewBatteryMonitor(config.Battery)
+		mqttClient.SetBatteryHandler(func(vacuumID string, level int) {
+			a.StateTracker.Health().RecordMessage(vacuumID, time.Now())
+			a.StateTracker.UpdateBattery(vacuumID, level)
+			a.checkBattery(vacuumID)
+			if a.isLeader() {
+				a.Positions.Trigger()
+			}
+		})
+
+		if config.Cluster.Enabled && replay != nil {
+			log.Println("[REPLAY] Cluster coordination disabled while replaying")
+		} else if config.Cluster.Enabled && simFeed != nil {
+			log.Println("[SIMULATE] Cluster coordination disabled while simulating")
+		} else if config.Cluster.Enabled {
+			a.startCoordinator(config, mqttClient)
+			fmt.Printf("Cluster coordination enabled (instance %s)\n", a.Coordinator.InstanceID())
+		}
 	}
 
 	// 8. Start HTTP server if enabled
 	if a.HttpMode {
 		// Create HTTP handlers
-		httpServer := newHTTPServer(a.StateTracker, a.Calibration, a.Config, refID, a.RotateAll)
+		var commands *mesh.CommandPublisher
+		if a.MQTTClient != nil {
+			commands = mesh.NewCommandPublisher(a.MQTTClient.GetClient(), a.Config)
+			commands.SetVacuumClients(a.MQTTClient.VacuumClients())
+		}
+		httpServer := newHTTPServer(httpServerOptions{
+			StateTracker: a.StateTracker,
+			Calibration:  a.Calibration,
+			Config:       a.Config,
+			RefID:        refID,
+			Rotation:     a.globalRotation,
+			Commands:     commands,
+			Calibrator:   a.AutoCalibrator,
+			Work:         a.Work,
+			IsLeader:     a.isLeader,
+			Push:         a.pushMap,
+		})
 		go func() {
 			addr := fmt.Sprintf("0.0.0.0:%d", a.HttpPort)
 			log.Printf("[HTTP] Starting server on %s", addr)
 			if err := http.ListenAndServe(addr, httpServer); err != nil {
-				log.Fatalf("[HTTP] Server error: %v", err)
+				fatalf("[HTTP] Server error: %v", err)
 			}
 			log.Printf("[HTTP] Server stopped unexpectedly")
 		}()
 	}
 
+	// Start gRPC server if enabled
+	var grpcServer *grpc.Server
+	if a.GrpcPort > 0 {
+		addr := fmt.Sprintf("0.0.0.0:%d", a.GrpcPort)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("[GRPC] listening on %s: %w", addr, err)
+		}
+		grpcServer = newGRPCServer(a.StateTracker, a.Calibration, a.AutoCalibrator, &a.Config.HTTP.Auth)
+		go func() {
+			log.Printf("[GRPC] Starting server on %s", addr)
+			if err := grpcServer.Serve(lis); err != nil {
+				fatalf("[GRPC] Server error: %v", err)
+			}
+		}()
+	}
+
 	// 9. Print service info
 	fmt.Println("\nService Running")
 	fmt.Println("===============")
@@ -1000,60 +2071,697 @@ func (a *App) RunService() {
 		}
 		fmt.Printf("  Publishing to: %s/{vacuumID}\n", publishPrefix)
 		fmt.Printf("  Combined positions: %s/positions\n", publishPrefix)
+		fmt.Printf("  Unified map changes: %s/map/updated\n", publishPrefix)
+		fmt.Printf("  Cleaning targets: %s/{vacuumID}/cleaning\n", publishPrefix)
+		fmt.Printf("  Battery alerts: %s/{vacuumID}/battery/alert\n", publishPrefix)
 	}
 
 	if a.HttpMode {
 		fmt.Printf("\nHTTP endpoints (port %d):\n", a.HttpPort)
-		fmt.Println("  GET /                - Homepage (live SVG map)")
+		fmt.Println("  GET /                - Dashboard (live map, vacuum status, calibration)")
 		fmt.Println("  GET /health          - Health check")
 		fmt.Println("  GET /live.svg        - Live map with vacuum positions (SVG)")
 		fmt.Println("  GET /live.png        - Live map with vacuum positions (PNG)")
 		fmt.Println("  GET /composite-map.png - Color-coded composite map")
 		fmt.Println("  GET /composite-map.svg - Color-coded composite map (SVG)")
+		if a.Config != nil && len(a.Config.Profiles) > 0 {
+			fmt.Println("  GET /profiles/{name}/composite-map.png - Composite of a render profile")
+		}
 		fmt.Println("  GET /floorplan.svg   - Greyscale floor plan (SVG)")
+		fmt.Println("  GET /floorplan.png   - Floor plan from the unified map (PNG)")
+		fmt.Println("  GET /heatmap.png     - Cleaning frequency over the floor plan (PNG)")
+		fmt.Println("  GET /floorplan.gltf  - Unified map extruded to 3D (glTF; /floorplan.obj for OBJ)")
+		fmt.Println("  GET /map.pgm         - Unified map as a ROS occupancy grid (metadata at /map.yaml)")
+		fmt.Println("  GET /tracks.geojson  - Recent vacuum tracks (GeoJSON)")
+		fmt.Println("  GET /segment?x=&y=   - Unified room at a world point")
+		fmt.Println("  GET /frontiers       - Unexplored floor edges")
+		fmt.Println("  GET /events          - Unified map changes (server-sent events)")
+		fmt.Println("  GET /api/docs        - API documentation (OpenAPI at /api/openapi.json)")
+	}
+
+	if a.GrpcPort > 0 {
+		fmt.Printf("\ngRPC API (port %d): tudomesh.v1.TudoMesh\n", a.GrpcPort)
+		fmt.Println("  GetUnifiedMap, GetPositions (stream), TransformPoint, TriggerCalibration")
+	}
+
+	// 10. Replay the recording; without servers to inspect, stop when it ends
+	replayDone := make(chan struct{})
+	if replay != nil {
+		f, err := os.Open(a.Replay)
+		if err != nil {
+			return fmt.Errorf("opening replay file: %w", err)
+		}
+		fmt.Printf("\nReplaying %s at speed %g\n", a.Replay, a.ReplaySpeed)
+		go func() {
+			defer func() { _ = f.Close() }()
+			n, err := replay.Play(runCtx, f, a.ReplaySpeed)
+			if err != nil && err != context.Canceled {
+				log.Printf("[REPLAY] Stopped after %d messages: %v", n, err)
+			} else {
+				log.Printf("[REPLAY] Finished: %d messages delivered, %d published", n, replay.Published())
+			}
+			if !a.HttpMode && a.GrpcPort == 0 {
+				close(replayDone)
+			}
+		}()
+	}
+
+	// Drive the simulated vacuums, through the broker with --simulate-mqtt
+	if sim != nil {
+		publish := func(msg mesh.RecordedMessage) { simFeed.Deliver(msg) }
+		if simFeed == nil {
+			client := a.MQTTClient.GetClient()
+			publish = func(msg mesh.RecordedMessage) {
+				token := client.Publish(msg.Topic, 0, false, msg.Payload)
+				if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+					log.Printf("[SIMULATE] publishing to %s: %v", msg.Topic, token.Error())
+				}
+			}
+		}
+		fmt.Printf("\nSimulated vacuums report every %v\n", mesh.DefaultSimulationInterval)
+		go sim.Run(runCtx, mesh.DefaultSimulationInterval, publish)
+
+		// The simulated home never changes and needs no calibration, so the
+		// unified map is built once, after every vacuum reported its map
+		go func() {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-time.After(mesh.DefaultSimulationInterval):
+			}
+			a.Work.Enqueue("simulate/unify", func() {
+				if err := a.StateTracker.UpdateUnifiedMap(a.Calibration()); err != nil {
+					log.Printf("[SIMULATE] Building unified map: %v", err)
+				}
+			})
+		}()
 	}
 
 	fmt.Println("\nPress Ctrl+C to stop")
 
-	// 10. Wait for interrupt signal
+	// 11. Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	<-sigChan
+	select {
+	case <-sigChan:
+	case <-replayDone:
+	}
 
 	fmt.Println("\nShutting down service...")
+	stopRun()
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+	if a.Coordinator != nil {
+		a.Coordinator.Stop()
+	}
 	if a.MQTTClient != nil {
 		a.MQTTClient.Disconnect()
 	}
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			log.Printf("Error closing recording: %v", err)
+		}
+	}
+	a.MapWriter.Flush()
+	if err := store.SaveHeatmap(a.StateTracker.Heatmap()); err != nil {
+		log.Printf("Error saving heatmap: %v", err)
+	}
+	if err := store.SaveState(a.StateTracker.Snapshot()); err != nil {
+		log.Printf("Error saving state: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
+	}
 	fmt.Println("Service stopped")
+	return nil
 }
 
-// loadInitialMaps loads map JSON exports from the data directory
-func (a *App) loadInitialMaps(dataDir string) map[string]*mesh.ValetudoMap {
-	maps := make(map[string]*mesh.ValetudoMap)
+// publishMapChanges announces each new unified map version, and its
+// summary, over MQTT until changes is closed. Like positions, only the
+// leader publishes.
+func (a *App) publishMapChanges(changes <-chan mesh.MapChange) {
+	for change := range changes {
+		if !a.isLeader() {
+			continue
+		}
+		if err := a.Publisher.PublishMapChange(change); err != nil {
+			log.Printf("Error publishing map change: %v", err)
+		}
+		a.publishMapSummary()
+	}
+}
+
+// publishMapSummary publishes the summary of the current unified map, if
+// there is one.
+func (a *App) publishMapSummary() {
+	summary := mesh.SummarizeUnifiedMap(a.StateTracker.GetUnifiedMap())
+	if summary == nil {
+		return
+	}
+	if err := a.Publisher.PublishMapSummary(summary); err != nil {
+		log.Printf("Error publishing map summary: %v", err)
+	}
+}
 
-	pattern := filepath.Join(dataDir, "ValetudoMapExport-*.json")
-	files, err := filepath.Glob(pattern)
+// republishMapSummary publishes the map summary every interval until ctx
+// is cancelled, so it reappears after a broker loses its retained messages.
+func (a *App) republishMapSummary(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if a.isLeader() {
+				a.publishMapSummary()
+			}
+		}
+	}
+}
+
+// updateCleaningTarget records the segments and zones a vacuum's map data
+// marks as being cleaned and, when they change, publishes the unified rooms
+// they fall in. Like positions, only the leader publishes.
+func (a *App) updateCleaningTarget(vacuumID string, mapData *mesh.ValetudoMap) {
+	area := mesh.ExtractActiveArea(mapData)
+	if !a.StateTracker.SetActiveArea(vacuumID, area) || a.Publisher == nil || !a.isLeader() {
+		return
+	}
+
+	transform := a.Calibration().GetTransform(vacuumID)
+	// The stored map has the segment pixels even when this update is lightweight
+	m := a.StateTracker.GetMaps()[vacuumID]
+	if m == nil {
+		m = mapData
+	}
+	target := mesh.ResolveCleaningTarget(vacuumID, m, area, transform, a.StateTracker.GetUnifiedMap())
+	if err := a.Publisher.PublishCleaningTarget(target); err != nil {
+		log.Printf("Error publishing cleaning target for %s: %v", vacuumID, err)
+	}
+}
+
+// publishCombinedPositions publishes every vacuum's position, room and
+// battery as one document. Like positions, only the leader publishes.
+func (a *App) publishCombinedPositions() {
+	if a.Publisher == nil || !a.isLeader() {
+		return
+	}
+	if err := a.Publisher.PublishCombinedPositions(a.StateTracker.CombinedPositions(time.Now())); err != nil {
+		log.Printf("Error publishing combined positions: %v", err)
+	}
+}
+
+// checkBattery publishes a low-battery alert when the vacuum's battery
+// level and distance from its charger on the unified map raise or clear
+// one. Like positions, only the leader publishes.
+func (a *App) checkBattery(vacuumID string) {
+	if a.Battery == nil {
+		return
+	}
+	pos := a.StateTracker.GetPositions()[vacuumID]
+	if pos == nil || pos.Battery == nil {
+		return
+	}
+
+	// Positions are world grid coordinates of the vacuum's own pixel size
+	m := a.StateTracker.GetMaps()[vacuumID]
+	size := 5.0
+	if m != nil && m.PixelSize > 0 {
+		size = float64(m.PixelSize)
+	}
+	robot := mesh.Point{X: pos.X * size, Y: pos.Y * size}
+	var charger mesh.Point
+	hasCharger := false
+	if m != nil {
+		if local, ok := mesh.ExtractChargerPosition(m); ok {
+			_, world, _ := worldPose(vacuumID, local, 0, m.PixelSize, a.Calibration())
+			charger, hasCharger = mesh.Point{X: world.X * size, Y: world.Y * size}, true
+		}
+	}
+
+	alert, changed := a.Battery.Check(vacuumID, *pos.Battery, robot, charger, hasCharger)
+	if !changed || a.Publisher == nil || !a.isLeader() {
+		return
+	}
+	if err := a.Publisher.PublishBatteryAlert(alert); err != nil {
+		log.Printf("Error publishing battery alert for %s: %v", vacuumID, err)
+	}
+}
+
+// startCoordinator joins the instance group: only the elected leader publishes
+// positions and calibrates, and standby instances adopt the leader's
+// retained calibration and unified map.
+func (a *App) startCoordinator(config *mesh.Config, mqttClient *mesh.MQTTClient) {
+	prefix := os.Getenv("MQTT_PUBLISH_PREFIX")
+	if prefix == "" {
+		prefix = config.MQTT.PublishPrefix
+	}
+	coord := mesh.NewCoordinator(mqttClient.GetClient(), config.Cluster, prefix)
+	a.Coordinator = coord
+
+	publishState := func(cal *mesh.CalibrationData) {
+		if err := coord.PublishCalibration(cal); err != nil {
+			log.Printf("[CLUSTER] Error publishing calibration: %v", err)
+		}
+		if err := coord.PublishUnifiedMap(a.StateTracker.GetUnifiedMap()); err != nil {
+			log.Printf("[CLUSTER] Error publishing unified map: %v", err)
+		}
+	}
+
+	coord.SetLeadershipHandler(func(leader bool) {
+		if leader {
+			publishState(a.Calibration())
+		}
+	})
+	coord.SetCalibrationHandler(a.followClusterCalibration)
+	coord.SetUnifiedMapHandler(a.StateTracker.SetUnifiedMap)
+	a.AutoCalibrator.SetCalibratedHandler(func(cal *mesh.CalibrationData) {
+		a.SetCalibration(cal)
+		publishState(cal)
+	})
+
+	mqttClient.AddConnectHandler(coord.Subscribe)
+	if mqttClient.IsConnected() {
+		// Connected before the hook was registered
+		coord.Subscribe(mqttClient.GetClient())
+	}
+	coord.Start()
+}
+
+// initAutoCalibrator creates the auto-calibrator, persisting through store.
+// Every calibration it makes, on docking, from a pushed map or by following a
+// frame shift, is adopted as Calibration, so published positions move with
+// the unified map.
+func (a *App) initAutoCalibrator(config *mesh.Config, cache *mesh.CalibrationData, store mesh.Store) {
+	a.AutoCalibrator = mesh.NewAutoCalibratorWithStore(config, cache, store, a.StateTracker)
+	a.AutoCalibrator.SetAccumulator(a.Accumulator)
+	a.AutoCalibrator.SetCalibratedHandler(a.SetCalibration)
+	a.SetCalibration(a.AutoCalibrator.GetCache())
+}
+
+// followClusterCalibration adopts the calibration the leader published, on
+// a standby instance. It runs on the MQTT client's goroutine.
+func (a *App) followClusterCalibration(cal *mesh.CalibrationData) {
+	a.SetCalibration(cal)
+	if a.AutoCalibrator != nil {
+		a.AutoCalibrator.SetCache(cal)
+	}
+}
+
+// updatePositionFromMap sets the vacuum's live position from the robot
+// entity of its map, transformed into the reference frame when calibrated.
+func (a *App) updatePositionFromMap(id string, m *mesh.ValetudoMap, cache *mesh.CalibrationData) {
+	robotPos, robotAngle, ok := mesh.ExtractRobotPosition(m)
+	if !ok {
+		return
+	}
+
+	_, worldPos, worldAngle := worldPose(id, robotPos, robotAngle, m.PixelSize, cache)
+	a.StateTracker.UpdatePosition(id, worldPos.X, worldPos.Y, worldAngle)
+}
+
+// worldPose converts a robot position in millimeters and its heading from
+// the vacuum's own map to world grid coordinates through its calibration,
+// returning the local grid position too. Without a calibration the local
+// grid position and heading are used as they are.
+func worldPose(id string, pos mesh.Point, angle float64, pixelSize int, cal *mesh.CalibrationData) (grid, world mesh.Point, worldAngle float64) {
+	// Valetudo entity.Points are in mm, layer.Pixels are in grid units
+	size := float64(pixelSize)
+	if size == 0 {
+		size = 5 // default
+	}
+	grid = mesh.Point{X: pos.X / size, Y: pos.Y / size}
+
+	transform := cal.GetTransform(id) // identity when uncalibrated
+	return grid, mesh.TransformPoint(grid, transform), mesh.TransformAngle(angle, transform)
+}
+
+// receiveMap runs a vacuum's decoded map through the pipeline every map
+// update takes, whether it arrived over MQTT or was pushed over HTTP, both of
+// which downsample it while decoding: it is quarantined when corrupt,
+// accumulated, stored and cached when it changed, and its robot position is
+// transformed and published. It reports whether the stored map changed, or
+// why the map was quarantined.
+//
+// Merging a new run of a vacuum that accumulates its runs takes ICP, so its
+// maps are merged and applied on the work queue instead, keeping the MQTT
+// handler free; it reports them as changed.
+func (a *App) receiveMap(vacuumID string, mapData *mesh.ValetudoMap) (bool, error) {
+	// Quarantine corrupt or partial maps so they cannot clobber the
+	// cached floorplan or report a bogus position. A partial map of
+	// a vacuum that accumulates its runs clobbers nothing and is
+	// only checked on its own.
+	previous := a.StateTracker.GetMap(vacuumID)
+	if a.Accumulator.Enabled(vacuumID) {
+		previous = nil
+	}
+	accepted, err := a.quarantine.Check(vacuumID, mapData, previous, time.Now())
 	if err != nil {
-		return maps
+		log.Printf("%s: quarantined map: %v", a.Config.DisplayName(vacuumID), err)
+		a.StateTracker.Health().RecordQuarantine(vacuumID, err, time.Now())
+		return false, err
 	}
+	if accepted {
+		log.Printf("%s: accepting map: the maps quarantined since the last accepted one agree, so the map was reset or remapped", a.Config.DisplayName(vacuumID))
+	}
+	if a.Accumulator.Enabled(vacuumID) && mesh.HasDrawablePixels(mapData) {
+		a.queueScan(vacuumID, mapData)
+		return true, nil
+	}
+	return a.applyMap(vacuumID, a.Accumulator.Add(vacuumID, mapData), previous), nil
+}
 
-	for _, file := range files {
-		base := filepath.Base(file)
-		name := strings.TrimPrefix(base, "ValetudoMapExport-")
-		name = strings.Split(name, "-2")[0] // Remove timestamp
+// queueScan queues a map of a vacuum that accumulates its runs to be merged
+// on the work queue. Scans waiting for the same vacuum are merged in the
+// order they arrived by one job, so none is lost when the job is coalesced.
+func (a *App) queueScan(vacuumID string, scan *mesh.ValetudoMap) {
+	a.scansMu.Lock()
+	if a.scans == nil {
+		a.scans = make(map[string][]*mesh.ValetudoMap)
+	}
+	a.scans[vacuumID] = append(a.scans[vacuumID], scan)
+	a.scansMu.Unlock()
+	a.Work.Enqueue("accumulate/"+vacuumID, func() { a.mergeScans(vacuumID) })
+}
 
-		m, err := mesh.ParseMapFile(file)
-		if err != nil {
-			log.Printf("Warning: Failed to load %s: %v", name, err)
-			continue
+// mergeScans merges the vacuum's waiting scans into its accumulated map and
+// applies the result.
+func (a *App) mergeScans(vacuumID string) {
+	a.scansMu.Lock()
+	scans := a.scans[vacuumID]
+	delete(a.scans, vacuumID)
+	a.scansMu.Unlock()
+
+	var merged *mesh.ValetudoMap
+	for _, scan := range scans {
+		merged = a.Accumulator.Add(vacuumID, scan)
+	}
+	if merged != nil {
+		a.applyMap(vacuumID, merged, nil)
+	}
+}
+
+// applyMap stores a vacuum's map when it has drawable content that changed,
+// follows a shift of its frame from previous (nil to skip), and transforms
+// and publishes its robot position. It reports whether the stored map
+// changed.
+func (a *App) applyMap(vacuumID string, mapData, previous *mesh.ValetudoMap) bool {
+	// Update state tracker with new map only if it contains drawable content
+	// This prevents lightweight updates from overwriting the rich floorplan loaded from disk.
+	// Maps that only moved the robot are not stored or cached again.
+	changed := false
+	if mesh.HasDrawablePixels(mapData) {
+		changed = a.StateTracker.UpdateMapIfChanged(vacuumID, mapData)
+		if !changed {
+			log.Printf("%s: map unchanged", a.Config.DisplayName(vacuumID))
 		}
-		maps[name] = m
+	}
+	if changed && previous != nil {
+		a.queueFrameShift(vacuumID, previous, mapData)
+	}
+	a.Work.Enqueue("cleaning-target/"+vacuumID, func() { a.updateCleaningTarget(vacuumID, mapData) })
+
+	// Debug: log map data stats
+	log.Printf("[DEBUG] %s: received map data - pixelSize=%d, layers=%d, entities=%d",
+		vacuumID, mapData.PixelSize, len(mapData.Layers), len(mapData.Entities))
+
+	// Extract robot position and angle from map data
+	robotPos, robotAngle, ok := mesh.ExtractRobotPosition(mapData)
+	if !ok {
+		log.Printf("[DEBUG] %s: robot_position entity not found in %d entities", vacuumID, len(mapData.Entities))
+		for i, e := range mapData.Entities {
+			log.Printf("[DEBUG]   entity[%d]: type=%s, points=%d", i, e.Type, len(e.Points))
+		}
+		return changed
+	}
+
+	// Auto-cache map to the store if it contains new drawable data
+	if changed {
+		// Save map data for persistent floorplan (debounced, async; a
+		// downsampled map is only held in memory)
+		a.MapWriter.Save(vacuumID, mapData)
+	}
+
+	// Transform position if calibration available
+	cal := a.Calibration()
+	gridPos, worldPos, worldAngle := worldPose(vacuumID, robotPos, robotAngle, mapData.PixelSize, cal)
+	gridX, gridY := worldPos.X, worldPos.Y
+	if cal != nil {
+		transform := cal.GetTransform(vacuumID)
+		log.Printf("[CALIBRATION] %s: transform(A=%.4f,C=%.4f) rotation=%.1f° mirrored=%v localAngle=%.0f° -> worldAngle=%.0f°",
+			vacuumID, transform.A, transform.C, mesh.TransformRotation(transform), mesh.IsMirrored(transform),
+			robotAngle, worldAngle)
+	} else {
+		log.Printf("[CALIBRATION] %s: no calibration loaded, using raw angle=%.0f°", vacuumID, robotAngle)
 	}
 
+	// Update state tracker with position (in grid coords)
+	a.StateTracker.UpdatePosition(vacuumID, gridX, gridY, worldAngle)
+
+	// Always log the position update for debugging
+	log.Printf("%s: pos(%.0f,%.0f) / pixelSize=%d -> grid(%.1f,%.1f) -> world(%.1f,%.1f,%.0f°)",
+		a.Config.DisplayName(vacuumID), robotPos.X, robotPos.Y, mapData.PixelSize,
+		gridPos.X, gridPos.Y, gridX, gridY, worldAngle)
+
+	// Publish transformed position (standby instances stay quiet)
+	if a.Publisher != nil && a.isLeader() {
+		if err := a.Publisher.PublishPosition(vacuumID, gridX, gridY, worldAngle); err != nil {
+			log.Printf("Error publishing position for %s: %v", vacuumID, err)
+		}
+		a.Positions.Trigger()
+	}
+	a.checkBattery(vacuumID)
+	return changed
+}
+
+// pushMap takes a map pushed to POST /maps/{vacuumID} through receiveMap.
+// A robot that pushes its maps may have no apiUrl for calibration on docking
+// to fetch from, so once the stored map changes the leader calibrates it
+// from the stored map, with the docking debounce, and the unified map is
+// rebuilt, both in the background.
+func (a *App) pushMap(vacuumID string, m *mesh.ValetudoMap) (bool, error) {
+	a.StateTracker.Health().RecordMessage(vacuumID, time.Now())
+	changed, err := a.receiveMap(vacuumID, m)
+	if err != nil || !changed {
+		return changed, err
+	}
+	a.Work.Enqueue("push/"+vacuumID, func() {
+		if a.AutoCalibrator != nil && a.isLeader() {
+			a.AutoCalibrator.OnMapPushed(vacuumID, a.StateTracker.GetMap(vacuumID))
+		}
+		if cal := a.Calibration(); cal != nil {
+			if err := a.StateTracker.UpdateUnifiedMap(cal); err != nil {
+				log.Printf("[PUSH] Rebuilding unified map: %v", err)
+			}
+		}
+	})
+	return true, nil
+}
+
+// applyWatchedMap takes a map export that appeared or changed in the data
+// directory: it replaces the vacuum's map and position and rebuilds the
+// unified map. Exports the service wrote back itself carry the nonce of the
+// map already held and are ignored. As for maps received over MQTT, the
+// check for a frame shift and, for a vacuum that accumulates its runs, the
+// merge run on the work queue, so their ICP does not hold up the watcher.
+func (a *App) applyWatchedMap(vacuumID string, m *mesh.ValetudoMap) {
+	name := a.Config.DisplayName(vacuumID)
+	if cur := a.StateTracker.GetMaps()[vacuumID]; cur != nil && m.MetaData.Nonce != "" && cur.MetaData.Nonce == m.MetaData.Nonce {
+		return
+	}
+	if !mesh.HasDrawablePixels(m) {
+		log.Printf("[WATCH] %s: export has no drawable layers; ignored", name)
+		return
+	}
+	if a.Accumulator.Enabled(vacuumID) {
+		a.queueScan(vacuumID, m)
+		a.Work.Enqueue("watch/"+vacuumID, a.rebuildWatchedUnified)
+		log.Printf("[WATCH] %s: queued map export for merging", name)
+		return
+	}
+
+	prev := a.StateTracker.GetMaps()[vacuumID]
+	a.StateTracker.UpdateMap(vacuumID, m)
+	if prev != nil {
+		a.queueFrameShift(vacuumID, prev, m)
+	}
+	cal := a.Calibration()
+	a.updatePositionFromMap(vacuumID, m, cal)
+	log.Printf("[WATCH] %s: reloaded map export", name)
+	a.rebuildWatchedUnified()
+}
+
+// rebuildWatchedUnified rebuilds the unified map after a watched export was
+// applied, when there is a calibration to build it with.
+func (a *App) rebuildWatchedUnified() {
+	if cal := a.Calibration(); cal != nil {
+		if err := a.StateTracker.UpdateUnifiedMap(cal); err != nil {
+			log.Printf("[WATCH] Rebuilding unified map: %v", err)
+		}
+	}
+}
+
+// framePair is a vacuum's map and the one it replaced, waiting to be
+// checked for a frame shift.
+type framePair struct {
+	previous, next *mesh.ValetudoMap
+}
+
+// queueFrameShift queues the check of next against previous for a frame
+// shift. While a check of the vacuum is still waiting the two are coalesced
+// into one from the earliest previous map to the latest, so a shift within
+// a burst of updates is not lost.
+func (a *App) queueFrameShift(vacuumID string, previous, next *mesh.ValetudoMap) {
+	a.framesMu.Lock()
+	if pending, ok := a.frames[vacuumID]; ok {
+		previous = pending.previous
+	}
+	if a.frames == nil {
+		a.frames = make(map[string]framePair)
+	}
+	a.frames[vacuumID] = framePair{previous: previous, next: next}
+	a.framesMu.Unlock()
+
+	a.Work.Enqueue("frame/"+vacuumID, func() {
+		a.framesMu.Lock()
+		pair, ok := a.frames[vacuumID]
+		delete(a.frames, vacuumID)
+		a.framesMu.Unlock()
+		if ok {
+			a.followFrameShift(vacuumID, pair.previous, pair.next)
+		}
+	})
+}
+
+// followFrameShift has the auto-calibrator compare a vacuum's new map with
+// the one it replaced and, when the robot's own frame moved, compose its
+// cached transform with the shift; the unified map is rebuilt to match. The
+// accumulated map of a vacuum mapping in sections keeps its frame by itself,
+// and standby instances leave calibration to the leader.
+func (a *App) followFrameShift(vacuumID string, prev, next *mesh.ValetudoMap) {
+	if a.AutoCalibrator == nil || a.Accumulator.Enabled(vacuumID) || !a.isLeader() {
+		return
+	}
+	if !a.AutoCalibrator.FollowFrameShift(vacuumID, prev, next) {
+		return
+	}
+	if err := a.StateTracker.UpdateUnifiedMap(a.Calibration()); err != nil {
+		log.Printf("[AUTO-CAL] Rebuilding unified map: %v", err)
+	}
+}
+
+// reloadCalibration adopts a calibration cache rewritten by another
+// process: renders, /health and published positions use it from now on, and
+// the unified map is rebuilt. On the leader it is replicated to standby
+// instances. A cache this service wrote itself matches the calibration in
+// use and is ignored.
+func (a *App) reloadCalibration(cal *mesh.CalibrationData) {
+	if reflect.DeepEqual(cal, a.Calibration()) {
+		return
+	}
+	a.SetCalibration(cal)
+	if a.AutoCalibrator != nil {
+		a.AutoCalibrator.LoadCache(cal)
+	}
+	log.Printf("[CALIBRATION] Reloaded calibration cache: %d vacuums, reference %s", len(cal.Vacuums), cal.ReferenceVacuum)
+
+	if len(a.StateTracker.GetMaps()) > 0 {
+		if err := a.StateTracker.UpdateUnifiedMap(cal); err != nil {
+			log.Printf("[CALIBRATION] Rebuilding unified map: %v", err)
+		}
+	}
+	if a.Coordinator != nil && a.isLeader() {
+		if err := a.Coordinator.PublishCalibration(cal); err != nil {
+			log.Printf("[CLUSTER] Error publishing calibration: %v", err)
+		}
+		if err := a.Coordinator.PublishUnifiedMap(a.StateTracker.GetUnifiedMap()); err != nil {
+			log.Printf("[CLUSTER] Error publishing unified map: %v", err)
+		}
+	}
+}
+
+// RunRenderWatch renders once, then re-renders whenever a map export in the
+// data directory is added or changed, until interrupted. A failed render is
+// logged and the watch goes on.
+func (a *App) RunRenderWatch() error {
+	a.renderLogged()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher := mesh.NewMapWatcher(a.DataDir)
+	watcher.Exports = a.exportNames()
+	watcher.Prime()
+	fmt.Printf("\nWatching %s for map exports (Ctrl+C to stop)\n", a.DataDir)
+	err := watcher.Watch(ctx, func(vacuumID string, _ *mesh.ValetudoMap) {
+		fmt.Printf("\n%s changed; re-rendering\n", vacuumID)
+		a.renderLogged()
+	})
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", a.DataDir, err)
+	}
+	return nil
+}
+
+// renderLogged runs RunRender, logging instead of returning its error.
+func (a *App) renderLogged() {
+	if err := a.RunRender(); err != nil {
+		log.Printf("Render failed: %v", err)
+	}
+}
+
+// globalRotation returns the composite rotation in degrees: the --rotate-all
+// value, or with --rotate-all=auto the rotation that squares up the
+// reference map's walls.
+func (a *App) globalRotation(maps map[string]*mesh.ValetudoMap, refID string) float64 {
+	if !a.AutoRotate {
+		return a.RotateAll
+	}
+	return mesh.NorthUpRotation(maps[refID])
+}
+
+// Calibration returns the calibration in use: the cache loaded at startup,
+// or the last one the auto-calibrator made or this instance adopted, which
+// may be nil. Endpoints, renders and publishers all read it, and it must not
+// be changed. It is safe to call from any goroutine.
+func (a *App) Calibration() *mesh.CalibrationData {
+	return a.calibration.Load()
+}
+
+// SetCalibration replaces the calibration returned by Calibration.
+func (a *App) SetCalibration(cal *mesh.CalibrationData) {
+	a.calibration.Store(cal)
+}
+
+// isLeader reports whether this instance should publish and calibrate.
+// Without cluster coordination every instance is its own leader.
+func (a *App) isLeader() bool {
+	return a.Coordinator == nil || a.Coordinator.IsLeader()
+}
+
+// loadInitialMaps loads persisted maps from the storage backend
+func (a *App) loadInitialMaps(store mesh.Store) map[string]*mesh.ValetudoMap {
+	maps, err := store.LoadMaps()
+	if err != nil {
+		log.Printf("Warning: Failed to load maps from %s: %v", store, err)
+		return make(map[string]*mesh.ValetudoMap)
+	}
+
+	downsample := a.parseOptions().Downsample
+	for _, m := range maps {
+		mesh.DownsampleMap(m, downsample)
+	}
 	return maps
 }
 
+// parseOptions returns the map parse options derived from the loaded config
+func (a *App) parseOptions() mesh.ParseOptions {
+	return a.Config.ParseOptions()
+}
+
 // checkWritability verifies that a directory is writable by creating and deleting a temporary file
 func (a *App) checkWritability(dir string) error {
 	tmpFile := filepath.Join(dir, ".tudomesh-write-test")
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kwv/tudomesh/mesh"
 )
@@ -77,8 +83,9 @@ func TestApplyOptions(t *testing.T) {
 	if app.ConfigFile != "test-config.yaml" {
 		t.Errorf("ConfigFile = %s, want test-config.yaml", app.ConfigFile)
 	}
-	if app.CalibrationCache != ".test-cache.json" {
-		t.Errorf("CalibrationCache = %s, want .test-cache.json", app.CalibrationCache)
+	// A relative cache path is in the data directory
+	if want := filepath.Join("/test/data", ".test-cache.json"); app.CalibrationCache != want {
+		t.Errorf("CalibrationCache = %s, want %s", app.CalibrationCache, want)
 	}
 	if app.RotateAll != 90.0 {
 		t.Errorf("RotateAll = %f, want 90.0", app.RotateAll)
@@ -127,11 +134,268 @@ func TestApplyOptions_AllDefaults(t *testing.T) {
 	}
 }
 
+func TestGlobalRotation(t *testing.T) {
+	// Reference map whose only wall is vertical; auto mode turns it horizontal
+	var pixels []int
+	for y := 0; y < 100; y++ {
+		pixels = append(pixels, 50, y)
+	}
+	maps := map[string]*mesh.ValetudoMap{
+		"ref": {PixelSize: 5, Layers: []mesh.MapLayer{{Type: "wall", Pixels: pixels}}},
+	}
+
+	app := &App{RotateAll: 180}
+	if got := app.globalRotation(maps, "ref"); got != 180 {
+		t.Errorf("fixed rotation = %v, want 180", got)
+	}
+
+	app.AutoRotate = true
+	if got := app.globalRotation(maps, "ref"); got != 90 {
+		t.Errorf("auto rotation = %v, want 90", got)
+	}
+}
+
+func TestWorldPose(t *testing.T) {
+	pos := mesh.Point{X: 1000, Y: 500}
+
+	// No calibration: grid position and heading pass through
+	grid, world, angle := worldPose("v1", pos, 30, 5, nil)
+	if grid != (mesh.Point{X: 200, Y: 100}) || world != grid || angle != 30 {
+		t.Errorf("uncalibrated pose = %v %v %v", grid, world, angle)
+	}
+
+	// Zero pixel size falls back to Valetudo's default of 5mm
+	if grid, _, _ := worldPose("v1", pos, 30, 0, nil); grid != (mesh.Point{X: 200, Y: 100}) {
+		t.Errorf("default pixel size grid = %v", grid)
+	}
+
+	cal := &mesh.CalibrationData{Vacuums: map[string]mesh.VacuumCalibration{
+		"v1": {Transform: mesh.CreateRotationTranslation(90, 10, 0)},
+	}}
+	_, world, angle = worldPose("v1", pos, 30, 5, cal)
+	if math.Abs(world.X+90) > 1e-9 || math.Abs(world.Y-200) > 1e-9 || math.Abs(angle-120) > 1e-9 {
+		t.Errorf("calibrated pose = %v %v, want (-90,200) 120", world, angle)
+	}
+}
+
+func TestReloadCalibration(t *testing.T) {
+	initial := &mesh.CalibrationData{ReferenceVacuum: "v1", Vacuums: map[string]mesh.VacuumCalibration{
+		"v1": {Transform: mesh.Identity()},
+	}}
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, ".calibration-cache.json")
+	st := mesh.NewStateTracker()
+	app := &App{
+		StateTracker:   st,
+		AutoCalibrator: mesh.NewAutoCalibrator(&mesh.Config{}, initial, cachePath, dir, st),
+	}
+	app.SetCalibration(initial)
+
+	reloaded := &mesh.CalibrationData{ReferenceVacuum: "v1", Vacuums: map[string]mesh.VacuumCalibration{
+		"v1": {Transform: mesh.Identity()},
+		"v2": {Transform: mesh.Translation(100, 0)},
+	}}
+	app.reloadCalibration(reloaded)
+	if got := app.Calibration(); got != reloaded {
+		t.Fatalf("Calibration = %+v, want the reloaded cache", got)
+	}
+	if app.AutoCalibrator.GetCache() != reloaded {
+		t.Error("auto-calibrator's calibration not updated")
+	}
+	// The cache came from disk, so it is not written back
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("reload wrote the cache: %v", err)
+	}
+
+	// A cache equal to the one in use, such as the service's own save, is ignored
+	app.reloadCalibration(&mesh.CalibrationData{ReferenceVacuum: "v1", Vacuums: map[string]mesh.VacuumCalibration{
+		"v1": {Transform: mesh.Identity()},
+		"v2": {Transform: mesh.Translation(100, 0)},
+	}})
+	if app.Calibration() != reloaded {
+		t.Error("identical cache replaced the calibration in use")
+	}
+}
+
+func TestReloadCalibration_ConcurrentReads(t *testing.T) {
+	// MQTT handlers read the calibration while the cache watcher replaces
+	// it; run with -race
+	app := &App{StateTracker: mesh.NewStateTracker()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			app.reloadCalibration(&mesh.CalibrationData{ReferenceVacuum: "v1", Vacuums: map[string]mesh.VacuumCalibration{
+				"v2": {Transform: mesh.Translation(float64(i), 0)},
+			}})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = app.Calibration().GetTransform("v2")
+	}
+	<-done
+	if got := app.Calibration().GetTransform("v2"); got.Tx != 99 {
+		t.Errorf("transform = %+v, want the last reload", got)
+	}
+}
+
+func TestFollowClusterCalibration(t *testing.T) {
+	dir := t.TempDir()
+	st := mesh.NewStateTracker()
+	app := &App{StateTracker: st, AutoCalibrator: mesh.NewAutoCalibrator(&mesh.Config{}, nil, filepath.Join(dir, ".calibration-cache.json"), dir, st)}
+	leader := &mesh.CalibrationData{ReferenceVacuum: "v1", Vacuums: map[string]mesh.VacuumCalibration{
+		"v2": {Transform: mesh.Translation(100, 0)},
+	}}
+
+	// Positions are read while the leader's calibration arrives; run with -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.followClusterCalibration(leader)
+	}()
+	_ = app.Calibration().GetTransform("v2")
+	<-done
+
+	if app.Calibration() != leader || app.AutoCalibrator.GetCache() != leader {
+		t.Errorf("calibration = %+v, auto-calibrator's %+v; want the leader's", app.Calibration(), app.AutoCalibrator.GetCache())
+	}
+}
+
+func TestExportNames(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := "mqtt:\n  broker: tcp://localhost:1883\nvacuums:\n  - id: rocky\n    topic: t/rocky\nexportPattern: '^(?P<id>[a-z]+)_map\\.json$'\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"rocky_map.json", "ValetudoMapExport-dusty-2-2024-01-01.json", "notes.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	app := &App{DataDir: dir, ConfigFile: configPath}
+	files, exports, err := app.findExports()
+	if err != nil {
+		t.Fatalf("findExports: %v", err)
+	}
+	var ids []string
+	for _, f := range files {
+		id, _ := exports.VacuumID(f)
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != "dusty-2" || ids[1] != "rocky" {
+		t.Errorf("export IDs = %v, want [dusty-2 rocky]", ids)
+	}
+
+	// Without a config only Valetudo's naming is recognized
+	app = &App{DataDir: dir, ConfigFile: filepath.Join(dir, "missing.yaml")}
+	if files, _, _ := app.findExports(); len(files) != 1 {
+		t.Errorf("default naming found %v, want only the Valetudo export", files)
+	}
+
+	app = &App{DataDir: t.TempDir()}
+	if _, _, err := app.findExports(); err == nil {
+		t.Error("findExports found exports in an empty directory")
+	}
+}
+
+func TestParseExports(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "ValetudoMapExport-rocky-2024-01-01.json")
+	if err := saveTestMapToFile(createTestMap("rocky"), valid); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+	broken := filepath.Join(dir, "ValetudoMapExport-dusty.json")
+	if err := os.WriteFile(broken, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+
+	var out bytes.Buffer
+	maps, failed := parseExports(&out, []string{broken, valid}, nil, false)
+	if len(maps) != 1 || maps["rocky"] == nil {
+		t.Errorf("maps = %v, want only rocky", getMapKeys(maps))
+	}
+	if len(failed) != 1 || !strings.HasPrefix(failed[0], "loading dusty:") {
+		t.Errorf("failures = %q, want the dusty error", failed)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "Error loading dusty:") || strings.Contains(got, "Loaded") {
+		t.Errorf("quiet output = %q, want only the dusty error", got)
+	}
+
+	out.Reset()
+	parseExports(&out, []string{valid}, nil, true)
+	if got, want := out.String(), "Loaded: rocky (area: 100)\n"; got != want {
+		t.Errorf("verbose output = %q, want %q", got, want)
+	}
+}
+
+func TestLoadExports(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"rocky", "dusty"} {
+		if err := saveTestMapToFile(createTestMap(id), filepath.Join(dir, "ValetudoMapExport-"+id+".json")); err != nil {
+			t.Fatalf("write map: %v", err)
+		}
+	}
+
+	app := &App{DataDir: dir, ConfigFile: filepath.Join(dir, "missing.yaml")}
+	var failed failures
+	maps, err := app.loadExports(false, &failed)
+	if err != nil {
+		t.Fatalf("loadExports: %v", err)
+	}
+	if len(maps) != 2 || maps["rocky"] == nil || maps["dusty"] == nil {
+		t.Errorf("loadExports = %v, want [dusty rocky]", getMapKeys(maps))
+	}
+	if failed.err() != nil {
+		t.Errorf("failures = %q, want none", failed)
+	}
+}
+
+func TestRunParseOnly_PartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	if err := saveTestMapToFile(createTestMap("rocky"), filepath.Join(dir, "ValetudoMapExport-rocky.json")); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ValetudoMapExport-dusty.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("write map: %v", err)
+	}
+
+	app := &App{DataDir: dir, ConfigFile: filepath.Join(dir, "missing.yaml")}
+	err := app.RunParseOnly()
+	if got := exitCode(err); got != exitPartial {
+		t.Errorf("exitCode(%v) = %d, want %d", err, got, exitPartial)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	config := "mqtt:\n  broker: tcp://localhost:1883\nvacuums:\n  - id: rocky\n    topic: t/rocky\n    colr: \"#FF0000\"\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if validateConfigFile(&out, path) {
+		t.Fatal("validateConfigFile accepted an unknown key")
+	}
+	want := path + `:6: vacuums[0].colr: unknown key, did you mean "color"?`
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("output = %q, want line %q", out.String(), want)
+	}
+
+	out.Reset()
+	if !validateConfigFile(&out, "config.example.yaml") {
+		t.Errorf("config.example.yaml is invalid:\n%s", out.String())
+	}
+}
+
 func TestLoadInitialMaps_EmptyDir(t *testing.T) {
 	app := NewApp()
 	tmpDir := t.TempDir()
 
-	maps := app.loadInitialMaps(tmpDir)
+	maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir, ""))
 	if len(maps) != 0 {
 		t.Errorf("Expected 0 maps, got %d", len(maps))
 	}
@@ -151,7 +415,7 @@ func TestLoadInitialMaps_WithSampleFiles(t *testing.T) {
 		t.Fatalf("Failed to create sample map file: %v", err)
 	}
 
-	maps := app.loadInitialMaps(tmpDir)
+	maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir, ""))
 	if len(maps) != 1 {
 		t.Errorf("Expected 1 map, got %d", len(maps))
 	}
@@ -182,7 +446,7 @@ func TestLoadInitialMaps_InvalidJSON(t *testing.T) {
 	}
 
 	// Should not panic, should just skip invalid files
-	maps := app.loadInitialMaps(tmpDir)
+	maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir, ""))
 	if len(maps) != 0 {
 		t.Errorf("Expected 0 maps (invalid JSON should be skipped), got %d", len(maps))
 	}
@@ -202,7 +466,7 @@ func TestLoadInitialMaps_MultipleFiles(t *testing.T) {
 		}
 	}
 
-	maps := app.loadInitialMaps(tmpDir)
+	maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir, ""))
 	if len(maps) != 3 {
 		t.Errorf("Expected 3 maps, got %d", len(maps))
 	}
@@ -239,15 +503,96 @@ func TestParseAndPrint(t *testing.T) {
 		t.Fatalf("Failed to create sample map file: %v", err)
 	}
 
-	// Should not panic when parsing valid file
-	app.parseAndPrint(samplePath)
+	if err := app.parseAndPrint(samplePath); err != nil {
+		t.Errorf("parseAndPrint: %v", err)
+	}
+}
+
+func TestServicePaths(t *testing.T) {
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: "/data", ConfigFile: "config.yaml", CalibrationCache: ".calibration-cache.json"})
+	if cfg, cache := app.servicePaths(); cfg != filepath.Join("/data", "config.yaml") || cache != filepath.Join("/data", ".calibration-cache.json") {
+		t.Errorf("servicePaths() = %q, %q, want both in /data", cfg, cache)
+	}
+
+	app.ApplyOptions(AppOptions{DataDir: "/data", ConfigFile: "/etc/tudomesh.yaml", CalibrationCache: "/var/cal.json"})
+	if cfg, cache := app.servicePaths(); cfg != "/etc/tudomesh.yaml" || cache != "/var/cal.json" {
+		t.Errorf("servicePaths() = %q, %q, want explicit paths kept", cfg, cache)
+	}
+}
+
+func TestRunRebaseReference(t *testing.T) {
+	tmpDir := t.TempDir()
+	cachePath := filepath.Join(tmpDir, "cal.json")
+	toB := mesh.CreateRotationTranslation(90, 40, -10)
+	if err := mesh.SaveCalibration(cachePath, &mesh.CalibrationData{
+		ReferenceVacuum: "a",
+		Vacuums: map[string]mesh.VacuumCalibration{
+			"a": {Transform: mesh.Identity()},
+			"b": {Transform: toB},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: tmpDir, ConfigFile: filepath.Join(tmpDir, "missing.yaml"), CalibrationCache: cachePath})
+	if err := app.RunRebaseReference("b"); err != nil {
+		t.Fatalf("RunRebaseReference: %v", err)
+	}
+
+	cal, err := mesh.LoadCalibration(cachePath)
+	if err != nil || cal == nil {
+		t.Fatalf("LoadCalibration: %v", err)
+	}
+	if cal.ReferenceVacuum != "b" {
+		t.Errorf("ReferenceVacuum = %q, want b", cal.ReferenceVacuum)
+	}
+	if cal.Vacuums["b"].Transform != mesh.Identity() {
+		t.Errorf("b transform = %+v, want identity", cal.Vacuums["b"].Transform)
+	}
+	want := mesh.InvertMatrix(toB)
+	if got := cal.Vacuums["a"].Transform; math.Abs(got.Tx-want.Tx) > 1e-9 || math.Abs(got.Ty-want.Ty) > 1e-9 {
+		t.Errorf("a transform = %+v, want %+v", got, want)
+	}
+}
+
+func TestRunRollbackCalibration(t *testing.T) {
+	tmpDir := t.TempDir()
+	cachePath := filepath.Join(tmpDir, "cal.json")
+	for _, ref := range []string{"a", "b"} {
+		if err := mesh.SaveCalibration(cachePath, &mesh.CalibrationData{
+			ReferenceVacuum: ref,
+			Vacuums:         map[string]mesh.VacuumCalibration{ref: {Transform: mesh.Identity()}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: tmpDir, ConfigFile: filepath.Join(tmpDir, "missing.yaml"), CalibrationCache: cachePath})
+	if err := app.RunRollbackCalibration("list"); err != nil {
+		t.Fatalf("RunRollbackCalibration(list): %v", err)
+	}
+	if err := app.RunRollbackCalibration("1"); err != nil {
+		t.Fatalf("RunRollbackCalibration(1): %v", err)
+	}
+	cal, err := mesh.LoadCalibration(cachePath)
+	if err != nil || cal == nil || cal.ReferenceVacuum != "a" {
+		t.Fatalf("after rollback: %+v, %v, want reference a", cal, err)
+	}
+
+	if err := app.RunRollbackCalibration("5"); err == nil {
+		t.Error("RunRollbackCalibration(5): expected error")
+	}
 }
 
 func TestParseAndPrint_InvalidFile(t *testing.T) {
 	app := NewApp()
 
-	// Should not panic when parsing non-existent file
-	app.parseAndPrint("/nonexistent/path/file.json")
+	if err := app.parseAndPrint("/nonexistent/path/file.json"); err == nil {
+		t.Error("parseAndPrint returned no error for a missing file")
+	}
 }
 
 func TestParseAndPrint_WithSegments(t *testing.T) {
@@ -271,15 +616,16 @@ func TestParseAndPrint_WithSegments(t *testing.T) {
 		t.Fatalf("Failed to create sample map file: %v", err)
 	}
 
-	// Should not panic
-	app.parseAndPrint(samplePath)
+	if err := app.parseAndPrint(samplePath); err != nil {
+		t.Errorf("parseAndPrint: %v", err)
+	}
 }
 
 func TestLoadInitialMaps_GlobError(t *testing.T) {
 	app := NewApp()
 
 	// Use an invalid pattern that should cause Glob to work but return empty
-	maps := app.loadInitialMaps("/\x00invalid")
+	maps := app.loadInitialMaps(mesh.NewFileStore("/\x00invalid", ""))
 
 	// Should return empty map without panicking
 	if len(maps) != 0 {
@@ -315,12 +661,12 @@ func TestLoadInitialMaps_CurrentDirectory(t *testing.T) {
 
 	// Test with tmpDir first (should be empty)
 	tmpDir2 := t.TempDir()
-	maps := app.loadInitialMaps(tmpDir2)
+	maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir2, ""))
 
 	// This should fall back to current directory and find the map
 	if len(maps) == 0 {
 		// The fallback worked and we're checking current dir
-		maps2 := app.loadInitialMaps(".")
+		maps2 := app.loadInitialMaps(mesh.NewFileStore(".", ""))
 		if len(maps2) != 1 {
 			t.Errorf("Expected 1 map from current directory, got %d", len(maps2))
 		}
@@ -407,10 +753,277 @@ func TestLoadInitialMaps_EdgeCases(t *testing.T) {
 				}
 			}
 
-			maps := app.loadInitialMaps(tmpDir)
+			maps := app.loadInitialMaps(mesh.NewFileStore(tmpDir, ""))
 			if len(maps) != tt.expected {
 				t.Errorf("Expected %d maps, got %d", tt.expected, len(maps))
 			}
 		})
 	}
 }
+
+func TestPushMap_ReceivesLikeMQTT(t *testing.T) {
+	store := mesh.NewMemoryStore()
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}}}
+	app.MapWriter = mesh.NewMapWriter(store, time.Hour)
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+
+	m := createTestMap("vac1")
+	m.Entities = []mesh.MapEntity{{Type: "robot_position", Points: []int{10, 10}}}
+	changed, err := app.pushMap("vac1", m)
+	if err != nil || !changed {
+		t.Fatalf("pushMap = %v, %v; want changed", changed, err)
+	}
+	if app.StateTracker.GetMap("vac1") == nil || app.StateTracker.GetPositions()["vac1"] == nil {
+		t.Error("pushed map and position not stored")
+	}
+	app.MapWriter.Flush()
+	if maps, err := store.LoadMaps(); err != nil || maps["vac1"] == nil {
+		t.Errorf("pushed map not cached: %v", err)
+	}
+
+	if changed, err := app.pushMap("vac1", createTestMap("vac1")); err != nil || changed {
+		t.Errorf("same map again = %v, %v; want unchanged", changed, err)
+	}
+	if _, err := app.pushMap("vac1", &mesh.ValetudoMap{}); !errors.Is(err, mesh.ErrZeroPixelSize) {
+		t.Errorf("map without a pixel size = %v, want quarantined", err)
+	}
+}
+
+func TestReceiveMap_AcceptsRemap(t *testing.T) {
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}}}
+	app.MapWriter = mesh.NewMapWriter(mesh.NewMemoryStore(), time.Hour)
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+
+	floor := func(n int) *mesh.ValetudoMap {
+		pixels := make([]int, 0, 2*n)
+		for i := 0; i < n; i++ {
+			pixels = append(pixels, i%100, i/100)
+		}
+		return &mesh.ValetudoMap{
+			PixelSize: 5,
+			Layers:    []mesh.MapLayer{{Type: "floor", Pixels: pixels}},
+			Entities:  []mesh.MapEntity{{Type: "robot_position", Points: []int{25, 25}}},
+		}
+	}
+	if _, err := app.receiveMap("vac1", floor(1000)); err != nil {
+		t.Fatal(err)
+	}
+	// After a map reset the new map is much smaller until it is accepted
+	for i := 1; i < mesh.QuarantineAcceptCount; i++ {
+		if _, err := app.receiveMap("vac1", floor(100)); !errors.Is(err, mesh.ErrAreaDropped) {
+			t.Fatalf("map %d after the reset = %v, want quarantined", i, err)
+		}
+	}
+	if changed, err := app.receiveMap("vac1", floor(100)); err != nil || !changed {
+		t.Fatalf("map %d after the reset = %v, %v; want accepted", mesh.QuarantineAcceptCount, changed, err)
+	}
+	if got := app.StateTracker.GetMap("vac1"); len(got.Layers[0].Pixels) != 200 {
+		t.Errorf("stored map has %d floor pixels, want the reset map's 100", len(got.Layers[0].Pixels)/2)
+	}
+}
+
+func TestReceiveMap_AccumulatesOnWorkQueue(t *testing.T) {
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1", Accumulate: true}}}
+	app.MapWriter = mesh.NewMapWriter(mesh.NewMemoryStore(), time.Hour)
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+
+	m := createTestMap("vac1")
+	m.Entities = []mesh.MapEntity{{Type: "robot_position", Points: []int{10, 10}}}
+	if changed, err := app.receiveMap("vac1", m); err != nil || !changed {
+		t.Fatalf("receiveMap = %v, %v; want queued", changed, err)
+	}
+	// The handler only queues the merge
+	if app.StateTracker.GetMap("vac1") != nil || app.Work.Len() == 0 {
+		t.Fatalf("map stored before the work queue ran (%d jobs waiting)", app.Work.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.Work.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for app.StateTracker.GetMap("vac1") == nil || app.StateTracker.GetPositions()["vac1"] == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("accumulated map and position not applied by the work queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestApplyWatchedMap_AccumulatesOnWorkQueue(t *testing.T) {
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1", Accumulate: true}}}
+	app.MapWriter = mesh.NewMapWriter(mesh.NewMemoryStore(), time.Hour)
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+
+	app.applyWatchedMap("vac1", createTestMap("vac1"))
+	// The watcher only queues the merge
+	if app.StateTracker.GetMap("vac1") != nil || app.Work.Len() == 0 {
+		t.Fatalf("map stored before the work queue ran (%d jobs waiting)", app.Work.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.Work.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for app.StateTracker.GetMap("vac1") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("accumulated export not applied by the work queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestApplyWatchedMap_QueuesFrameShift(t *testing.T) {
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}}}
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+
+	first, second := createTestMap("a"), createTestMap("b")
+	app.applyWatchedMap("vac1", first)
+	app.applyWatchedMap("vac1", second)
+	if app.StateTracker.GetMap("vac1") != second {
+		t.Fatal("watched export not stored")
+	}
+	if got, ok := app.frames["vac1"]; !ok || got.previous != first || got.next != second {
+		t.Error("frame shift check not queued from the previous export to the new one")
+	}
+}
+
+// roomMap builds a room with walls and a floor whose frame is moved by
+// (dx, dy) pixels, with the robot standing at the same spot in the room.
+func roomMap(dx, dy int) *mesh.ValetudoMap {
+	var walls, floor []int
+	wall := func(x0, y0, x1, y1 int) {
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				walls = append(walls, x+dx, y+dy)
+			}
+		}
+	}
+	wall(0, 0, 360, 0)
+	wall(0, 240, 360, 240)
+	wall(0, 0, 0, 240)
+	wall(360, 0, 360, 240)
+	wall(150, 0, 150, 120)
+	wall(0, 150, 90, 150)
+	for y := 4; y < 240; y += 4 {
+		for x := 4; x < 360; x += 4 {
+			floor = append(floor, x+dx, y+dy)
+		}
+	}
+	return &mesh.ValetudoMap{
+		PixelSize: 5,
+		Layers: []mesh.MapLayer{
+			{Type: "floor", Pixels: floor},
+			{Type: "wall", Pixels: walls},
+		},
+		Entities: []mesh.MapEntity{{Type: "robot_position", Points: []int{(200 + dx) * 5, (180 + dy) * 5}}},
+	}
+}
+
+func TestFollowFrameShift_MovesPublishedPosition(t *testing.T) {
+	app := NewApp()
+	app.Config = &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}, {ID: "vac2"}}}
+	app.MapWriter = mesh.NewMapWriter(mesh.NewMemoryStore(), time.Hour)
+	app.Work = mesh.NewWorkQueue()
+	app.Accumulator = mesh.NewMapAccumulator(app.Config)
+	app.Publisher = mesh.NewPublisher(mesh.NewReplayClient())
+	app.Positions = mesh.NewDebouncer(time.Hour, func() {})
+	cal := &mesh.CalibrationData{ReferenceVacuum: "vac1", Vacuums: map[string]mesh.VacuumCalibration{
+		"vac2": {Transform: mesh.Identity()},
+	}}
+	app.SetCalibration(cal)
+	app.initAutoCalibrator(app.Config, cal, mesh.NewMemoryStore())
+
+	published := func() mesh.Point {
+		t.Helper()
+		pos, ok := app.Publisher.GetPosition("vac2")
+		if !ok {
+			t.Fatal("no position published")
+		}
+		return mesh.Point{X: pos.X, Y: pos.Y}
+	}
+	if _, err := app.receiveMap("vac2", roomMap(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	before := published()
+
+	// The robot relocalized: its frame moved, so the old transform places it
+	// off by the shift until the shift is followed
+	shifted := roomMap(40, -25)
+	if _, err := app.receiveMap("vac2", shifted); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.Work.Run(ctx)
+	deadline := time.Now().Add(10 * time.Second)
+	for app.Calibration().GetTransform("vac2") == mesh.Identity() {
+		if time.Now().After(deadline) {
+			t.Fatal("frame shift not followed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if app.Calibration() != app.AutoCalibrator.GetCache() {
+		t.Error("App.Calibration differs from the auto-calibrator's calibration")
+	}
+
+	if _, err := app.receiveMap("vac2", shifted); err != nil {
+		t.Fatal(err)
+	}
+	if got := published(); math.Hypot(got.X-before.X, got.Y-before.Y) > 2 {
+		t.Errorf("published position after following the shift = (%.1f, %.1f), want about (%.1f, %.1f)", got.X, got.Y, before.X, before.Y)
+	}
+}
+
+func TestQueueFrameShift_Coalesces(t *testing.T) {
+	app := NewApp()
+	app.Work = mesh.NewWorkQueue()
+	first, second, third := createTestMap("a"), createTestMap("b"), createTestMap("c")
+
+	// A burst: the shift may lie between any two of the maps
+	app.queueFrameShift("vac1", first, second)
+	app.queueFrameShift("vac1", second, third)
+	if app.Work.Len() != 1 {
+		t.Errorf("%d jobs waiting, want the checks coalesced into 1", app.Work.Len())
+	}
+	if got := app.frames["vac1"]; got.previous != first || got.next != third {
+		t.Error("coalesced check is not from the earliest previous map to the latest")
+	}
+}
+
+func TestRunCalibration_Storage(t *testing.T) {
+	app := setupWizard(t)
+	f, err := os.OpenFile(app.ConfigFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("storage:\n  backend: sqlite\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	app.JSON = true
+
+	if err := app.RunCalibration(); err != nil {
+		t.Fatalf("RunCalibration: %v", err)
+	}
+	if _, err := os.Stat(app.CalibrationCache); err == nil {
+		t.Error("calibration cache file written despite the sqlite backend")
+	}
+	store, err := mesh.OpenStore(mesh.StorageConfig{Backend: mesh.StorageBackendSQLite}, app.DataDir, app.CalibrationCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+	if cal, err := store.LoadCalibration(); err != nil || cal.GetVacuumCalibration("dusty") == nil {
+		t.Errorf("calibration in sqlite = %+v, %v; want dusty's", cal, err)
+	}
+}
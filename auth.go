@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kwv/tudomesh/mesh"
+	tudomeshv1 "github.com/kwv/tudomesh/proto/tudomesh/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authRealm is sent in WWW-Authenticate challenges.
+const authRealm = "tudomesh"
+
+// authQueryParam carries a token on GET requests from browsers, where image
+// tags and EventSource cannot set an Authorization header.
+const authQueryParam = "access_token"
+
+// authRole orders access levels so a role satisfies every lower requirement.
+type authRole int
+
+const (
+	roleNone authRole = iota
+	roleRead
+	roleAdmin
+)
+
+// publicPaths stay open with protectReads: health checks, the API docs and
+// the dashboard page itself, which asks for a token when its requests are
+// refused.
+var publicPaths = map[string]bool{
+	"/":                 true,
+	"/health":           true,
+	"/api/docs":         true,
+	"/api/openapi.json": true,
+}
+
+// tokenAuth checks bearer tokens against the configured ones. Tokens are
+// kept as SHA-256 digests so every comparison is over equal-length values.
+type tokenAuth struct {
+	tokens       []authToken
+	protectReads bool
+}
+
+type authToken struct {
+	digest [sha256.Size]byte
+	role   authRole
+}
+
+// newTokenAuth returns nil when no tokens are configured.
+func newTokenAuth(cfg *mesh.AuthConfig) *tokenAuth {
+	if cfg == nil || !cfg.Enabled() {
+		return nil
+	}
+	a := &tokenAuth{protectReads: cfg.ProtectReads}
+	add := func(token string, role authRole) {
+		if token != "" {
+			a.tokens = append(a.tokens, authToken{sha256.Sum256([]byte(token)), role})
+		}
+	}
+	add(cfg.AdminToken, roleAdmin)
+	add(cfg.ReadToken, roleRead)
+	for _, t := range cfg.Tokens {
+		role := roleRead
+		if t.Role == mesh.TokenRoleAdmin {
+			role = roleAdmin
+		}
+		add(t.Token, role)
+	}
+	return a
+}
+
+// role returns the highest role granted to token. Every configured token is
+// compared in constant time, so the response time does not reveal which
+// token, or how much of one, matched.
+func (a *tokenAuth) role(token string) authRole {
+	if token == "" {
+		return roleNone
+	}
+	digest := sha256.Sum256([]byte(token))
+	role := roleNone
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 && t.role > role {
+			role = t.role
+		}
+	}
+	return role
+}
+
+// required returns the role needed for a request. Methods other than GET,
+// HEAD and OPTIONS change state and need an admin token.
+func (a *tokenAuth) required(method, path string) authRole {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		if a.protectReads && !publicPaths[path] {
+			return roleRead
+		}
+		return roleNone
+	case http.MethodOptions:
+		return roleNone
+	default:
+		return roleAdmin
+	}
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header, or
+// on GET and HEAD requests from the access_token query parameter.
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return r.URL.Query().Get(authQueryParam)
+	}
+	return ""
+}
+
+// authMiddleware requires a bearer token when tokens are configured:
+// calibration, zone edits and other calls that change state need an admin
+// token, and with protectReads maps and other GET endpoints need a read or
+// admin token. Missing or unknown tokens get 401, read tokens on admin
+// endpoints 403. Without tokens, every request passes.
+func authMiddleware(cfg *mesh.AuthConfig, next http.Handler) http.Handler {
+	auth := newTokenAuth(cfg)
+	if auth == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		need := auth.required(r.Method, r.URL.Path)
+		if need == roleNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := bearerToken(r)
+		role := auth.role(token)
+		switch {
+		case role == roleNone && token == "":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", authRealm))
+			http.Error(w, "Token required", http.StatusUnauthorized)
+		case role == roleNone:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\"", authRealm))
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		case role < need:
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q, error=\"insufficient_scope\"", authRealm))
+			http.Error(w, "Admin token required", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// grpcRequired returns the role needed for a gRPC method: TriggerCalibration
+// needs an admin token, the others a read token with protectReads.
+func (a *tokenAuth) grpcRequired(fullMethod string) authRole {
+	if fullMethod == tudomeshv1.TudoMesh_TriggerCalibration_FullMethodName {
+		return roleAdmin
+	}
+	if a.protectReads {
+		return roleRead
+	}
+	return roleNone
+}
+
+// grpcCheck applies the HTTP rules to a gRPC call, reading the token from
+// the "authorization" metadata.
+func (a *tokenAuth) grpcCheck(ctx context.Context, fullMethod string) error {
+	need := a.grpcRequired(fullMethod)
+	if need == roleNone {
+		return nil
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, h := range md.Get("authorization") {
+			if scheme, t, ok := strings.Cut(h, " "); ok && strings.EqualFold(scheme, "Bearer") {
+				token = strings.TrimSpace(t)
+			}
+		}
+	}
+	role := a.role(token)
+	switch {
+	case role == roleNone:
+		return status.Error(codes.Unauthenticated, "valid bearer token required")
+	case role < need:
+		return status.Error(codes.PermissionDenied, "admin token required")
+	}
+	return nil
+}
+
+// grpcAuthInterceptors returns server options enforcing token auth, or none
+// without tokens.
+func grpcAuthInterceptors(cfg *mesh.AuthConfig) []grpc.ServerOption {
+	auth := newTokenAuth(cfg)
+	if auth == nil {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := auth.grpcCheck(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := auth.grpcCheck(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// generateToken returns a random token for http.auth: 32 bytes from
+// crypto/rand, base64url-encoded.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kwv/tudomesh/mesh"
+	tudomeshv1 "github.com/kwv/tudomesh/proto/tudomesh/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testAdminToken = "admin-secret"
+	testReadToken  = "read-secret"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tokens := mesh.AuthConfig{
+		AdminToken: testAdminToken,
+		Tokens:     []mesh.APIToken{{Name: "ha", Token: testReadToken, Role: mesh.TokenRoleRead}},
+	}
+	protected := tokens
+	protected.ProtectReads = true
+
+	tests := []struct {
+		name       string
+		cfg        *mesh.AuthConfig
+		method     string
+		target     string
+		auth       string
+		wantStatus int
+		wantError  string // error in the WWW-Authenticate challenge
+	}{
+		{"disabled", nil, http.MethodPost, "/calibrate", "", http.StatusOK, ""},
+		{"read open by default", &tokens, http.MethodGet, "/composite-map.png", "", http.StatusOK, ""},
+		{"mutation without token", &tokens, http.MethodPost, "/calibrate", "", http.StatusUnauthorized, ""},
+		{"mutation with unknown token", &tokens, http.MethodPost, "/calibrate", "Bearer nope", http.StatusUnauthorized, "invalid_token"},
+		{"mutation with read token", &tokens, http.MethodDelete, "/zones/kitchen", "Bearer " + testReadToken, http.StatusForbidden, "insufficient_scope"},
+		{"mutation with admin token", &tokens, http.MethodPost, "/calibrate", "bearer " + testAdminToken, http.StatusOK, ""},
+		{"basic auth is not a bearer token", &tokens, http.MethodPost, "/calibrate", "Basic " + testAdminToken, http.StatusUnauthorized, ""},
+		{"query token only for reads", &tokens, http.MethodPost, "/calibrate?access_token=" + testAdminToken, "", http.StatusUnauthorized, ""},
+		{"preflight", &protected, http.MethodOptions, "/calibrate", "", http.StatusOK, ""},
+		{"protected read without token", &protected, http.MethodGet, "/live.png", "", http.StatusUnauthorized, ""},
+		{"protected read with read token", &protected, http.MethodGet, "/live.png", "Bearer " + testReadToken, http.StatusOK, ""},
+		{"protected read with admin token", &protected, http.MethodHead, "/stats.json", "Bearer " + testAdminToken, http.StatusOK, ""},
+		{"protected read with query token", &protected, http.MethodGet, "/events?access_token=" + testReadToken, "", http.StatusOK, ""},
+		{"health stays open", &protected, http.MethodGet, "/health", "", http.StatusOK, ""},
+		{"dashboard stays open", &protected, http.MethodGet, "/", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			authMiddleware(tt.cfg, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if tt.wantStatus == http.StatusOK {
+				if challenge != "" {
+					t.Errorf("unexpected challenge %q", challenge)
+				}
+				return
+			}
+			if !strings.HasPrefix(challenge, `Bearer realm="tudomesh"`) {
+				t.Errorf("WWW-Authenticate = %q, want a Bearer challenge", challenge)
+			}
+			if tt.wantError != "" && !strings.Contains(challenge, tt.wantError) {
+				t.Errorf("WWW-Authenticate = %q, want error %s", challenge, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTokenAuth_Role(t *testing.T) {
+	auth := newTokenAuth(&mesh.AuthConfig{
+		ReadToken: testReadToken,
+		Tokens: []mesh.APIToken{
+			{Name: "ci", Token: "shared", Role: mesh.TokenRoleRead},
+			{Name: "ops", Token: "shared", Role: mesh.TokenRoleAdmin},
+		},
+	})
+	tests := []struct {
+		token string
+		want  authRole
+	}{
+		{"", roleNone},
+		{testReadToken, roleRead},
+		{testReadToken[:4], roleNone},
+		{testReadToken + "x", roleNone},
+		{"shared", roleAdmin}, // the highest role wins
+	}
+	for _, tt := range tests {
+		if got := auth.role(tt.token); got != tt.want {
+			t.Errorf("role(%q) = %d, want %d", tt.token, got, tt.want)
+		}
+	}
+
+	if newTokenAuth(&mesh.AuthConfig{ProtectReads: true}) != nil {
+		t.Error("expected no auth without tokens")
+	}
+}
+
+func TestHTTPServer_Auth(t *testing.T) {
+	config := &mesh.Config{HTTP: mesh.HTTPConfig{
+		Auth: mesh.AuthConfig{AdminToken: testAdminToken},
+		CORS: mesh.CORSConfig{AllowedOrigins: []string{"*"}},
+	}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Config: config, RefID: "vac1"})
+
+	req := httptest.NewRequest(http.MethodPost, "/calibrate", nil)
+	req.Header.Set("Origin", "http://ha.local")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /calibrate without token: status %d, want 401", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("refused request is missing CORS headers")
+	}
+
+	// Past auth, the handler runs and reports that calibration is unavailable
+	req = httptest.NewRequest(http.MethodPost, "/calibrate", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+		t.Errorf("POST /calibrate with admin token: status %d", rec.Code)
+	}
+}
+
+func TestGRPC_Auth(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := newGRPCServer(mesh.NewStateTracker(), func() *mesh.CalibrationData { return nil }, nil,
+		&mesh.AuthConfig{AdminToken: testAdminToken, ReadToken: testReadToken, ProtectReads: true})
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client := tudomeshv1.NewTudoMeshClient(conn)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	_, err = client.GetUnifiedMap(context.Background(), &tudomeshv1.GetUnifiedMapRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetUnifiedMap without token: %v, want Unauthenticated", err)
+	}
+	_, err = client.GetUnifiedMap(withToken(testReadToken), &tudomeshv1.GetUnifiedMapRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("GetUnifiedMap with read token: %v, want Unavailable (no map yet)", err)
+	}
+
+	stream, err := client.GetPositions(context.Background(), &tudomeshv1.GetPositionsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetPositions without token: %v, want Unauthenticated", err)
+	}
+
+	_, err = client.TriggerCalibration(withToken(testReadToken), &tudomeshv1.TriggerCalibrationRequest{})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("TriggerCalibration with read token: %v, want PermissionDenied", err)
+	}
+	_, err = client.TriggerCalibration(withToken(testAdminToken), &tudomeshv1.TriggerCalibrationRequest{})
+	if c := status.Code(err); c == codes.Unauthenticated || c == codes.PermissionDenied {
+		t.Errorf("TriggerCalibration with admin token: %v", err)
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	a, err := generateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generateToken()
+	if len(a) != 43 || a == b {
+		t.Errorf("generateToken = %q, %q, want distinct 43-character tokens", a, b)
+	}
+}
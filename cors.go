@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// defaultCORSMaxAge is the preflight cache lifetime in seconds.
+const defaultCORSMaxAge = 600
+
+// defaultCORSMethods are the methods advertised when none are configured.
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+// corsMiddleware adds CORS headers for allowed origins and answers OPTIONS
+// requests (including CORS preflights) without invoking the endpoint. With
+// no allowed origins configured, no CORS headers are sent.
+func corsMiddleware(cfg *mesh.CORSConfig, next http.Handler) http.Handler {
+	if cfg == nil {
+		cfg = &mesh.CORSConfig{}
+	}
+
+	allowAny := false
+	origins := make(map[string]bool, len(cfg.AllowedOrigins))
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			allowAny = true
+		}
+		origins[strings.TrimSuffix(o, "/")] = true
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+
+	maxAge := cfg.MaxAgeSeconds
+	if maxAge <= 0 {
+		maxAge = defaultCORSMaxAge
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && (allowAny || origins[origin])
+
+		if allowed {
+			h := w.Header()
+			if allowAny {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+			}
+			h.Set("Access-Control-Expose-Headers", "Content-Type, Content-Length, Retry-After")
+		}
+
+		if r.Method == http.MethodOptions {
+			h := w.Header()
+			h.Set("Allow", allowMethods)
+			if r.Header.Get("Access-Control-Request-Method") == "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			// Preflight request
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+				h.Set("Access-Control-Allow-Headers", req)
+			}
+			h.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		cfg         *mesh.CORSConfig
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantOrigin  string
+		wantHandler bool
+	}{
+		{
+			name:        "disabled sends no headers",
+			cfg:         nil,
+			method:      http.MethodGet,
+			headers:     map[string]string{"Origin": "http://ha.local"},
+			wantStatus:  http.StatusOK,
+			wantHandler: true,
+		},
+		{
+			name:        "allowed origin is echoed",
+			cfg:         &mesh.CORSConfig{AllowedOrigins: []string{"http://ha.local:8123"}},
+			method:      http.MethodGet,
+			headers:     map[string]string{"Origin": "http://ha.local:8123"},
+			wantStatus:  http.StatusOK,
+			wantOrigin:  "http://ha.local:8123",
+			wantHandler: true,
+		},
+		{
+			name:        "unknown origin gets no headers",
+			cfg:         &mesh.CORSConfig{AllowedOrigins: []string{"http://ha.local:8123"}},
+			method:      http.MethodGet,
+			headers:     map[string]string{"Origin": "http://evil.example"},
+			wantStatus:  http.StatusOK,
+			wantHandler: true,
+		},
+		{
+			name:        "wildcard",
+			cfg:         &mesh.CORSConfig{AllowedOrigins: []string{"*"}},
+			method:      http.MethodGet,
+			headers:     map[string]string{"Origin": "http://grafana.local"},
+			wantStatus:  http.StatusOK,
+			wantOrigin:  "*",
+			wantHandler: true,
+		},
+		{
+			name:   "preflight allowed",
+			cfg:    &mesh.CORSConfig{AllowedOrigins: []string{"*"}},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "http://grafana.local",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusNoContent,
+			wantOrigin: "*",
+		},
+		{
+			name:   "preflight from unknown origin",
+			cfg:    &mesh.CORSConfig{AllowedOrigins: []string{"http://ha.local"}},
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                        "http://evil.example",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "plain OPTIONS does not render",
+			cfg:        nil,
+			method:     http.MethodOptions,
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			req := httptest.NewRequest(tt.method, "/composite-map.png", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			corsMiddleware(tt.cfg, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if called != tt.wantHandler {
+				t.Errorf("handler called = %v, want %v", called, tt.wantHandler)
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware_PreflightHeaders(t *testing.T) {
+	cfg := &mesh.CORSConfig{
+		AllowedOrigins: []string{"http://ha.local"},
+		AllowedMethods: []string{"GET"},
+		MaxAgeSeconds:  60,
+	}
+	req := httptest.NewRequest(http.MethodOptions, "/live.svg", nil)
+	req.Header.Set("Origin", "http://ha.local")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization")
+	rec := httptest.NewRecorder()
+
+	corsMiddleware(cfg, http.NotFoundHandler()).ServeHTTP(rec, req)
+
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Methods") != "GET" {
+		t.Errorf("Allow-Methods = %q", h.Get("Access-Control-Allow-Methods"))
+	}
+	if h.Get("Access-Control-Allow-Headers") != "Authorization" {
+		t.Errorf("Allow-Headers = %q, want requested headers echoed", h.Get("Access-Control-Allow-Headers"))
+	}
+	if h.Get("Access-Control-Max-Age") != "60" {
+		t.Errorf("Max-Age = %q, want 60", h.Get("Access-Control-Max-Age"))
+	}
+	if h.Get("Vary") != "Origin" {
+		t.Errorf("Vary = %q, want Origin", h.Get("Vary"))
+	}
+}
+
+func TestNewHTTPServer_CORS(t *testing.T) {
+	config := &mesh.Config{HTTP: mesh.HTTPConfig{CORS: mesh.CORSConfig{AllowedOrigins: []string{"*"}}}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Config: config})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("Origin", "http://grafana.local")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("expected CORS header on /health, got %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import _ "embed"
+
+// dashboardPage is the single-page UI served at /. It only uses the
+// server's own endpoints: /health, /stats.json, /calibration.json and
+// POST /calibrate for status, /events to refresh on map changes, and the
+// raster map endpoints with their layer query parameters.
+//
+//go:embed dashboard.html
+var dashboardPage []byte
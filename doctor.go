@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// DefaultDoctorTimeout is how long --doctor waits for the broker and for
+// each vacuum's map data.
+const DefaultDoctorTimeout = 30 * time.Second
+
+// doctorCheck is one line of the --doctor report.
+type doctorCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// doctorReport collects the checks of a --doctor run in order.
+type doctorReport struct {
+	checks []doctorCheck
+}
+
+func (r *doctorReport) pass(name, format string, args ...interface{}) {
+	r.checks = append(r.checks, doctorCheck{Name: name, Passed: true, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (r *doctorReport) fail(name, format string, args ...interface{}) {
+	r.checks = append(r.checks, doctorCheck{Name: name, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Write prints the report to w, one PASS or FAIL line per check and a
+// summary, and reports whether every check passed.
+func (r *doctorReport) Write(w io.Writer) bool {
+	width := 0
+	for _, c := range r.checks {
+		width = max(width, len(c.Name))
+	}
+	failed := 0
+	for _, c := range r.checks {
+		status := "PASS"
+		if !c.Passed {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%s  %-*s  %s\n", status, width, c.Name, c.Detail)
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "\n%d of %d check(s) failed\n", failed, len(r.checks))
+		return false
+	}
+	fmt.Fprintf(w, "\nAll %d check(s) passed\n", len(r.checks))
+	return true
+}
+
+// RunDoctor checks the setup the service would run with and prints a
+// PASS/FAIL report. A failed check makes it a partial failure, exiting with
+// status 1.
+func (a *App) RunDoctor() error {
+	fmt.Println("Checking tudomesh setup...")
+	if !a.doctor(os.Stdout) {
+		return &partialError{failures: []string{"setup checks failed"}}
+	}
+	return nil
+}
+
+// doctor validates the config, waits for map data over MQTT, compares the
+// calibration cache with the maps and renders each output, then writes the
+// report to w. Checks that depend on a valid config are skipped without one.
+func (a *App) doctor(w io.Writer) bool {
+	timeout := a.DoctorTimeout
+	if timeout <= 0 {
+		timeout = DefaultDoctorTimeout
+	}
+	report := &doctorReport{}
+	configPath, cachePath := a.servicePaths()
+
+	config, err := mesh.LoadConfig(configPath)
+	if err != nil {
+		report.fail("config", "%s: %v (run --validate-config for details)", configPath, err)
+		return report.Write(w)
+	}
+	a.Config = config
+	report.pass("config", "%s (%d vacuum(s))", configPath, len(config.Vacuums))
+
+	// Connection logs would bury the report; the checks carry the errors
+	log.SetOutput(io.Discard)
+	maps := a.doctorMQTT(report, config, timeout)
+	log.SetOutput(os.Stderr)
+
+	store, err := mesh.OpenStore(config.Storage, a.DataDir, cachePath)
+	if err != nil {
+		report.fail("storage", "%v", err)
+		return report.Write(w)
+	}
+	defer func() { _ = store.Close() }()
+	report.pass("storage", "%s", store)
+
+	// Maps received over MQTT are fresher than the cached ones
+	for id, m := range a.loadInitialMaps(store) {
+		if _, ok := maps[id]; !ok {
+			maps[id] = m
+		}
+	}
+	cache := doctorCalibration(report, config, store, maps)
+	a.doctorRender(report, config, cache, maps)
+	return report.Write(w)
+}
+
+// doctorMQTT connects to the configured brokers and waits until timeout for
+// a map from every vacuum, returning the maps that arrived.
+func (a *App) doctorMQTT(report *doctorReport, config *mesh.Config, timeout time.Duration) map[string]*mesh.ValetudoMap {
+	maps := make(map[string]*mesh.ValetudoMap)
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		broker = config.MQTT.Broker
+	}
+
+	var mu sync.Mutex
+	received := make(map[string]error)
+	done := make(chan struct{}, 1)
+	handler := func(vacuumID string, _ []byte, mapData *mesh.ValetudoMap, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := received[vacuumID]; ok && err != nil {
+			return // keep the first good map
+		}
+		received[vacuumID] = err
+		if err == nil {
+			maps[vacuumID] = mapData
+		}
+		if len(received) == len(config.Vacuums) {
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+		}
+	}
+	client, err := mesh.InitMQTT(config, handler)
+	if err != nil || client == nil {
+		report.fail("mqtt", "%v", err)
+		return maps
+	}
+	defer client.Disconnect()
+
+	deadline := time.Now().Add(timeout)
+	for !client.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !client.IsConnected() {
+		report.fail("mqtt", "could not connect to %s within %s", broker, timeout)
+		return maps
+	}
+	report.pass("mqtt", "connected to %s", broker)
+
+	select {
+	case <-done:
+	case <-time.After(time.Until(deadline)):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	result := make(map[string]*mesh.ValetudoMap, len(maps))
+	for id, m := range maps {
+		result[id] = m
+	}
+	for _, vc := range config.Vacuums {
+		name := "topic " + vc.ID
+		err, ok := received[vc.ID]
+		switch {
+		case !ok:
+			report.fail(name, "no map data on %s within %s", vc.Topic, timeout)
+		case err != nil:
+			report.fail(name, "map data on %s could not be decoded: %v", vc.Topic, err)
+		default:
+			report.pass(name, "map data received on %s", vc.Topic)
+		}
+	}
+	return result
+}
+
+// doctorCalibration checks that the calibration cache has a transform for
+// every vacuum with a map, and that the maps have not changed much since.
+func doctorCalibration(report *doctorReport, config *mesh.Config, store mesh.Store, maps map[string]*mesh.ValetudoMap) *mesh.CalibrationData {
+	cache, err := store.LoadCalibration()
+	switch {
+	case err != nil:
+		report.fail("calibration", "loading from %s: %v", store, err)
+		return nil
+	case cache == nil:
+		report.fail("calibration", "no calibration cache in %s (run --calibrate or let the service calibrate on docking)", store)
+		return nil
+	case config.Reference != "" && cache.ReferenceVacuum != config.Reference:
+		report.fail("calibration", "cache reference %s differs from config reference %s (see --rebase-reference)", cache.ReferenceVacuum, config.Reference)
+	default:
+		report.pass("calibration", "reference %s, %d vacuum(s)", cache.ReferenceVacuum, len(cache.Vacuums))
+	}
+
+	for _, id := range sortedKeys(maps) {
+		name := "calibration " + id
+		if id == cache.ReferenceVacuum {
+			report.pass(name, "reference vacuum")
+			continue
+		}
+		vc, ok := cache.Vacuums[id]
+		if !ok {
+			report.fail(name, "map available but no transform cached")
+			continue
+		}
+		area, calibrated := maps[id].MetaData.TotalLayerArea, vc.MapAreaAtCalibration
+		if calibrated > 0 && float64(area) < float64(calibrated)*mesh.MinAreaRatio {
+			report.fail(name, "map area %d is well below %d at calibration; recalibrate", area, calibrated)
+			continue
+		}
+		report.pass(name, "transform cached (rotation %.1f°)", mesh.TransformRotation(vc.Transform))
+	}
+	for _, id := range sortedKeys(cache.Vacuums) {
+		if _, ok := maps[id]; !ok {
+			report.fail("calibration "+id, "transform cached but no map available")
+		}
+	}
+	return cache
+}
+
+// doctorRender renders each registered image endpoint from maps and checks
+// that it produced an image.
+func (a *App) doctorRender(report *doctorReport, config *mesh.Config, cache *mesh.CalibrationData, maps map[string]*mesh.ValetudoMap) {
+	if len(maps) == 0 {
+		report.fail("render", "no maps to render")
+		return
+	}
+	state := mesh.NewStateTracker()
+	for id, m := range maps {
+		state.UpdateMap(id, m)
+		if pos, angle, ok := mesh.ExtractRobotPosition(m); ok {
+			_, world, worldAngle := worldPose(id, pos, angle, m.PixelSize, cache)
+			state.UpdatePosition(id, world.X, world.Y, worldAngle)
+		}
+	}
+	handler := newHTTPServer(httpServerOptions{StateTracker: state, Calibration: fixedCalibration(cache), Config: config, RefID: config.Reference, Rotation: a.globalRotation})
+
+	for _, e := range newRendererRegistry(&renderEnv{}).entries {
+		path := e.endpoint.Path
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		name := "render " + path
+		switch {
+		case rec.Code != http.StatusOK:
+			report.fail(name, "HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+		case rec.Body.Len() == 0:
+			report.fail(name, "empty response")
+		default:
+			report.pass(name, "%s, %.1f KB", rec.Header().Get("Content-Type"), float64(rec.Body.Len())/1024)
+		}
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
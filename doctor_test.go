@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+func TestDoctorReport_Write(t *testing.T) {
+	report := &doctorReport{}
+	report.pass("config", "config.yaml")
+	report.fail("topic rocky", "no map data")
+
+	var out bytes.Buffer
+	if report.Write(&out) {
+		t.Error("Write reported success with a failed check")
+	}
+	want := "PASS  config       config.yaml\nFAIL  topic rocky  no map data\n\n1 of 2 check(s) failed\n"
+	if out.String() != want {
+		t.Errorf("report = %q, want %q", out.String(), want)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	dir := t.TempDir()
+	// Nothing listens on port 1, so the MQTT checks fail fast
+	config := "mqtt:\n  broker: tcp://127.0.0.1:1\nvacuums:\n  - id: rocky\n    topic: t/rocky\n  - id: dusty\n    topic: t/dusty\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveTestMapToFile(createTestMap("rocky"), filepath.Join(dir, "ValetudoMapExport-rocky.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.SaveCalibration(filepath.Join(dir, ".calibration-cache.json"), &mesh.CalibrationData{
+		ReferenceVacuum: "rocky",
+		Vacuums: map[string]mesh.VacuumCalibration{
+			"rocky": {Transform: mesh.Identity()},
+			"dusty": {Transform: mesh.Identity()},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: dir, ConfigFile: "config.yaml", CalibrationCache: ".calibration-cache.json", DoctorTimeout: 300 * time.Millisecond})
+	var out bytes.Buffer
+	if app.doctor(&out) {
+		t.Errorf("doctor passed without a reachable broker:\n%s", out.String())
+	}
+
+	for _, want := range []string{
+		"PASS  config",
+		"FAIL  mqtt ",
+		"PASS  calibration rocky",
+		"FAIL  calibration dusty ",
+		"PASS  render /composite-map.png",
+		"PASS  render /live.svg",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report has no %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDoctor_InvalidConfig(t *testing.T) {
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: ".", ConfigFile: filepath.Join(t.TempDir(), "missing.yaml")})
+	var out bytes.Buffer
+	if app.doctor(&out) {
+		t.Fatal("doctor passed without a config")
+	}
+	if !strings.HasPrefix(out.String(), "FAIL  config") || strings.Contains(out.String(), "mqtt") {
+		t.Errorf("report = %q, want only the config failure", out.String())
+	}
+}
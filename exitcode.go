@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Exit statuses of the CLI, for scripts and cron jobs.
+const (
+	exitOK      = 0
+	exitPartial = 1 // the command finished, but some inputs or checks failed
+	exitFatal   = 2 // the command could not do its work
+)
+
+// partialError is returned by a command that ran to completion despite
+// failures, such as an export that did not parse. It exits with
+// exitPartial.
+type partialError struct {
+	failures []string
+}
+
+func (e *partialError) Error() string {
+	if len(e.failures) == 1 {
+		return e.failures[0]
+	}
+	return fmt.Sprintf("%d failures: %s", len(e.failures), strings.Join(e.failures, "; "))
+}
+
+// failures collects the per-input failures a command continues past.
+type failures []string
+
+// add records a failure; reporting it as it happens is up to the caller.
+func (f *failures) add(format string, args ...any) {
+	*f = append(*f, fmt.Sprintf(format, args...))
+}
+
+// err returns a *partialError listing the failures, or nil if there were
+// none.
+func (f failures) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &partialError{failures: f}
+}
+
+// exitCode maps the error returned by run to the process exit status.
+func exitCode(err error) int {
+	var partial *partialError
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &partial):
+		return exitPartial
+	default:
+		return exitFatal
+	}
+}
+
+// fatalf logs and exits with exitFatal, for failures that cannot be
+// returned to run, such as those of a server goroutine.
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitFatal)
+}
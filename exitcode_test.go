@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	var failed failures
+	if failed.err() != nil {
+		t.Fatal("no failures should be no error")
+	}
+	failed.add("loading %s: bad json", "rocky")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"ok", nil, exitOK},
+		{"help", flag.ErrHelp, exitOK},
+		{"partial", failed.err(), exitPartial},
+		{"wrapped partial", fmt.Errorf("remote calibrate: %w", failed.err()), exitPartial},
+		{"fatal", errors.New("no exports"), exitFatal},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPartialErrorMessage(t *testing.T) {
+	var failed failures
+	failed.add("loading rocky: bad json")
+	if got, want := failed.err().Error(), "loading rocky: bad json"; got != want {
+		t.Errorf("one failure = %q, want %q", got, want)
+	}
+
+	failed.add("loading dusty: truncated")
+	if got, want := failed.err().Error(), "2 failures: loading rocky: bad json; loading dusty: truncated"; got != want {
+		t.Errorf("two failures = %q, want %q", got, want)
+	}
+}
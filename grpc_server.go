@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+	tudomeshv1 "github.com/kwv/tudomesh/proto/tudomesh/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultPositionInterval is how often GetPositions polls for movement when
+// the client does not request an interval.
+const defaultPositionInterval = 500 * time.Millisecond
+
+// minPositionInterval is the shortest interval GetPositions polls at, so a
+// client cannot make a stream spin on the state tracker.
+const minPositionInterval = 100 * time.Millisecond
+
+// positionInterval is the polling interval for a GetPositions request of ms
+// milliseconds: the default for 0, otherwise at least minPositionInterval.
+func positionInterval(ms uint32) time.Duration {
+	if ms == 0 {
+		return defaultPositionInterval
+	}
+	return max(time.Duration(ms)*time.Millisecond, minPositionInterval)
+}
+
+// grpcService implements the TudoMesh gRPC service on top of the same state
+// the HTTP endpoints use.
+type grpcService struct {
+	tudomeshv1.UnimplementedTudoMeshServer
+
+	stateTracker *mesh.StateTracker
+	calibration  func() *mesh.CalibrationData
+	calibrator   *mesh.AutoCalibrator // nil unless running with --mqtt
+}
+
+// newGRPCServer creates a gRPC server with the TudoMesh service registered.
+// calibration is called per request so replicated or freshly computed
+// calibration is always used.
+func newGRPCServer(stateTracker *mesh.StateTracker, calibration func() *mesh.CalibrationData, calibrator *mesh.AutoCalibrator, auth *mesh.AuthConfig) *grpc.Server {
+	opts := append([]grpc.ServerOption{grpc.UnaryInterceptor(grpcLoggingInterceptor)}, grpcRecoverInterceptors()...)
+	opts = append(opts, grpcAuthInterceptors(auth)...)
+	server := grpc.NewServer(opts...)
+	tudomeshv1.RegisterTudoMeshServer(server, &grpcService{
+		stateTracker: stateTracker,
+		calibration:  calibration,
+		calibrator:   calibrator,
+	})
+	return server
+}
+
+// grpcLoggingInterceptor logs unary calls in the same format as the HTTP middleware
+func grpcLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	log.Printf("[GRPC] %s %s (%v)", info.FullMethod, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+// GetUnifiedMap returns the current unified map as GeoJSON plus metadata.
+func (s *grpcService) GetUnifiedMap(ctx context.Context, _ *tudomeshv1.GetUnifiedMapRequest) (*tudomeshv1.UnifiedMap, error) {
+	um := s.stateTracker.GetUnifiedMap()
+	if um == nil {
+		return nil, status.Error(codes.Unavailable, "no unified map available")
+	}
+
+	geojson, err := json.Marshal(um.ToFeatureCollection())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding unified map: %v", err)
+	}
+
+	return &tudomeshv1.UnifiedMap{
+		Metadata: &tudomeshv1.UnifiedMapMetadata{
+			VacuumCount:     int32(um.Metadata.VacuumCount),
+			ReferenceVacuum: um.Metadata.ReferenceVacuum,
+			LastUpdated:     um.Metadata.LastUpdated,
+			TotalArea:       um.Metadata.TotalArea,
+			CoverageOverlap: um.Metadata.CoverageOverlap,
+		},
+		Geojson:      geojson,
+		WallCount:    int32(len(um.Walls)),
+		FloorCount:   int32(len(um.Floors)),
+		SegmentCount: int32(len(um.Segments)),
+	}, nil
+}
+
+// GetPositions streams the current positions, then every subsequent change,
+// until the client cancels.
+func (s *grpcService) GetPositions(req *tudomeshv1.GetPositionsRequest, stream grpc.ServerStreamingServer[tudomeshv1.Position]) error {
+	interval := positionInterval(req.GetIntervalMs())
+
+	wanted := make(map[string]bool, len(req.GetVacuumIds()))
+	for _, id := range req.GetVacuumIds() {
+		wanted[id] = true
+	}
+
+	sent := make(map[string]time.Time)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for id, pos := range s.stateTracker.GetPositions() {
+			if len(wanted) > 0 && !wanted[id] {
+				continue
+			}
+			if last, ok := sent[id]; ok && !pos.Timestamp.After(last) {
+				continue
+			}
+			if err := stream.Send(&tudomeshv1.Position{
+				VacuumId:        pos.VacuumID,
+				X:               pos.X,
+				Y:               pos.Y,
+				Angle:           pos.Angle,
+				TimestampUnixMs: pos.Timestamp.UnixMilli(),
+				Color:           pos.Color,
+			}); err != nil {
+				return err
+			}
+			sent[id] = pos.Timestamp
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// TransformPoint maps a local point into world coordinates using the
+// vacuum's calibration (identity if uncalibrated).
+func (s *grpcService) TransformPoint(ctx context.Context, req *tudomeshv1.TransformPointRequest) (*tudomeshv1.TransformPointResponse, error) {
+	if req.GetVacuumId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "vacuum_id is required")
+	}
+
+	transform := mesh.Identity()
+	calibrated := false
+	if cal := s.calibration(); cal != nil {
+		if vc := cal.GetVacuumCalibration(req.GetVacuumId()); vc != nil {
+			transform = vc.Transform
+			calibrated = true
+		}
+	}
+
+	world := mesh.TransformPoint(mesh.Point{X: req.GetX(), Y: req.GetY()}, transform)
+	return &tudomeshv1.TransformPointResponse{
+		X:          world.X,
+		Y:          world.Y,
+		Angle:      mesh.TransformAngle(req.GetAngle(), transform),
+		Calibrated: calibrated,
+	}, nil
+}
+
+// TriggerCalibration runs calibration for one vacuum and returns its
+// resulting transform.
+func (s *grpcService) TriggerCalibration(ctx context.Context, req *tudomeshv1.TriggerCalibrationRequest) (*tudomeshv1.TriggerCalibrationResponse, error) {
+	if req.GetVacuumId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "vacuum_id is required")
+	}
+	if s.calibrator == nil {
+		return nil, status.Error(codes.FailedPrecondition, "calibration requires --mqtt service mode")
+	}
+
+	var before int64
+	if vc := s.calibrator.GetCache().GetVacuumCalibration(req.GetVacuumId()); vc != nil {
+		before = vc.LastUpdated
+	}
+
+	if err := s.calibrator.Calibrate(req.GetVacuumId()); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "calibration failed: %v", err)
+	}
+
+	vc := s.calibrator.GetCache().GetVacuumCalibration(req.GetVacuumId())
+	if vc == nil {
+		return &tudomeshv1.TriggerCalibrationResponse{}, nil
+	}
+	t := vc.Transform
+	return &tudomeshv1.TriggerCalibrationResponse{
+		Updated:     vc.LastUpdated != before,
+		Transform:   []float64{t.A, t.B, t.Tx, t.C, t.D, t.Ty},
+		LastUpdated: vc.LastUpdated,
+	}, nil
+}
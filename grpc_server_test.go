@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+	tudomeshv1 "github.com/kwv/tudomesh/proto/tudomesh/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// dialTestGRPC starts the gRPC server on an in-memory listener and returns a client.
+func dialTestGRPC(t *testing.T, st *mesh.StateTracker, cal *mesh.CalibrationData) tudomeshv1.TudoMeshClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := newGRPCServer(st, func() *mesh.CalibrationData { return cal }, nil, nil)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return tudomeshv1.NewTudoMeshClient(conn)
+}
+
+// ---------------------------------------------------------------------------
+// GetUnifiedMap
+// ---------------------------------------------------------------------------
+
+func TestGRPC_GetUnifiedMap(t *testing.T) {
+	st := mesh.NewStateTracker()
+	client := dialTestGRPC(t, st, nil)
+	ctx := context.Background()
+
+	_, err := client.GetUnifiedMap(ctx, &tudomeshv1.GetUnifiedMapRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable without a unified map, got %v", err)
+	}
+
+	st.SetUnifiedMap(mesh.NewUnifiedMap(2, "ref"))
+	um, err := client.GetUnifiedMap(ctx, &tudomeshv1.GetUnifiedMapRequest{})
+	if err != nil {
+		t.Fatalf("GetUnifiedMap: %v", err)
+	}
+	if um.GetMetadata().GetReferenceVacuum() != "ref" || um.GetMetadata().GetVacuumCount() != 2 {
+		t.Errorf("unexpected metadata: %+v", um.GetMetadata())
+	}
+	if len(um.GetGeojson()) == 0 {
+		t.Error("expected GeoJSON payload")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GetPositions
+// ---------------------------------------------------------------------------
+
+func TestGRPC_GetPositions(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.UpdatePosition("a", 1, 2, 90)
+	st.UpdatePosition("b", 3, 4, 180)
+	client := dialTestGRPC(t, st, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.GetPositions(ctx, &tudomeshv1.GetPositionsRequest{VacuumIds: []string{"a"}, IntervalMs: 10})
+	if err != nil {
+		t.Fatalf("GetPositions: %v", err)
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv initial: %v", err)
+	}
+	if first.GetVacuumId() != "a" || first.GetX() != 1 || first.GetAngle() != 90 {
+		t.Errorf("unexpected initial position: %+v", first)
+	}
+
+	// Ensure the next update has a strictly later timestamp
+	time.Sleep(5 * time.Millisecond)
+	st.UpdatePosition("b", 9, 9, 0) // filtered out
+	st.UpdatePosition("a", 5, 6, 45)
+
+	next, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv update: %v", err)
+	}
+	if next.GetVacuumId() != "a" || next.GetX() != 5 {
+		t.Errorf("unexpected update: %+v", next)
+	}
+}
+
+func TestPositionInterval(t *testing.T) {
+	tests := []struct {
+		ms   uint32
+		want time.Duration
+	}{
+		{0, defaultPositionInterval},
+		{1, minPositionInterval},
+		{99, minPositionInterval},
+		{100, 100 * time.Millisecond},
+		{2000, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := positionInterval(tt.ms); got != tt.want {
+			t.Errorf("positionInterval(%d) = %v, want %v", tt.ms, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TransformPoint
+// ---------------------------------------------------------------------------
+
+func TestGRPC_TransformPoint(t *testing.T) {
+	cal := &mesh.CalibrationData{
+		Vacuums: map[string]mesh.VacuumCalibration{
+			"b": {Transform: mesh.MultiplyMatrices(mesh.Translation(10, 20), mesh.RotationDeg(90))},
+		},
+	}
+	client := dialTestGRPC(t, mesh.NewStateTracker(), cal)
+	ctx := context.Background()
+
+	resp, err := client.TransformPoint(ctx, &tudomeshv1.TransformPointRequest{VacuumId: "b", X: 1, Y: 0, Angle: 0})
+	if err != nil {
+		t.Fatalf("TransformPoint: %v", err)
+	}
+	if !resp.GetCalibrated() {
+		t.Error("expected calibrated=true")
+	}
+	want := mesh.TransformPoint(mesh.Point{X: 1, Y: 0}, cal.GetTransform("b"))
+	if math.Abs(resp.GetX()-want.X) > 1e-9 || math.Abs(resp.GetY()-want.Y) > 1e-9 {
+		t.Errorf("TransformPoint = (%v,%v), want (%v,%v)", resp.GetX(), resp.GetY(), want.X, want.Y)
+	}
+
+	resp, err = client.TransformPoint(ctx, &tudomeshv1.TransformPointRequest{VacuumId: "unknown", X: 3, Y: 4})
+	if err != nil {
+		t.Fatalf("TransformPoint unknown: %v", err)
+	}
+	if resp.GetCalibrated() || resp.GetX() != 3 || resp.GetY() != 4 {
+		t.Errorf("uncalibrated vacuum should use identity, got %+v", resp)
+	}
+
+	if _, err := client.TransformPoint(ctx, &tudomeshv1.TransformPointRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for missing vacuum_id, got %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TriggerCalibration
+// ---------------------------------------------------------------------------
+
+func TestGRPC_TriggerCalibration_RequiresCalibrator(t *testing.T) {
+	client := dialTestGRPC(t, mesh.NewStateTracker(), nil)
+
+	_, err := client.TriggerCalibration(context.Background(), &tudomeshv1.TriggerCalibrationRequest{VacuumId: "a"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition without calibrator, got %v", err)
+	}
+}
@@ -2,268 +2,1196 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"image/color"
-	"image/png"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kwv/tudomesh/mesh"
 )
 
+// rotationFunc returns the global rotation for a render of maps with the
+// given reference vacuum.
+type rotationFunc func(maps map[string]*mesh.ValetudoMap, refID string) float64
+
+// fixedRotation returns a rotationFunc that always rotates by deg.
+func fixedRotation(deg float64) rotationFunc {
+	return func(map[string]*mesh.ValetudoMap, string) float64 { return deg }
+}
+
+// calibrationFunc returns the calibration in use. It is called per request
+// so calibration from docking events, other instances or a reloaded cache
+// file is always used.
+type calibrationFunc func() *mesh.CalibrationData
+
+// fixedCalibration returns a calibrationFunc that always returns cal.
+func fixedCalibration(cal *mesh.CalibrationData) calibrationFunc {
+	return func() *mesh.CalibrationData { return cal }
+}
+
+// maxPushedMapBytes caps the body of a pushed map. Valetudo's exports of a
+// large home are a few megabytes of JSON.
+const maxPushedMapBytes = 32 << 20
+
+// mapPushFunc takes a vacuum's map pushed over HTTP through the service's
+// map pipeline, reporting whether its stored map changed or why the map was
+// rejected.
+type mapPushFunc func(vacuumID string, m *mesh.ValetudoMap) (bool, error)
+
+// httpServerOptions holds what the HTTP endpoints serve. Only StateTracker
+// is required: a nil Calibration serves no calibration, a nil Rotation
+// turns nothing, and the endpoints that command, calibrate or take pushed
+// maps need Commands, Calibrator and Push, which only the service sets.
+type httpServerOptions struct {
+	StateTracker *mesh.StateTracker
+	Calibration  calibrationFunc
+	Config       *mesh.Config
+	RefID        string // reference vacuum
+	Rotation     rotationFunc
+	Commands     *mesh.CommandPublisher
+	Calibrator   *mesh.AutoCalibrator
+	Work         *mesh.WorkQueue // runs requested calibrations
+	IsLeader     func() bool     // nil when not clustered
+	Push         mapPushFunc
+}
+
 // newHTTPServer creates an HTTP server with all endpoints
-func newHTTPServer(stateTracker *mesh.StateTracker, cache *mesh.CalibrationData, config *mesh.Config, refID string, rotateAll float64) http.Handler {
+func newHTTPServer(opts httpServerOptions) http.Handler {
+	stateTracker, config, refID := opts.StateTracker, opts.Config, opts.RefID
+	calibration, rotation := opts.Calibration, opts.Rotation
+	if calibration == nil {
+		calibration = fixedCalibration(nil)
+	}
+	if rotation == nil {
+		rotation = fixedRotation(0)
+	}
+	commands, calibrator, push := opts.Commands, opts.Calibrator, opts.Push
+	work, isLeader := opts.Work, opts.IsLeader
 	mux := http.NewServeMux()
+	api := newAPIRegistry(mux)
+
+	// Memory budget caps raster output size on constrained devices; the render
+	// limiter caps concurrent renders and per-client request rates
+	var budget mesh.MemoryBudget
+	var httpConfig *mesh.HTTPConfig
+	if config != nil {
+		budget = mesh.NewMemoryBudget(&config.Memory)
+		httpConfig = &config.HTTP
+	}
+	limiter := newRenderLimiter(httpConfig)
+
+	// Marker icons are loaded once; vacuums whose icon fails to load fall
+	// back to the default marker
+	icons, err := mesh.LoadMarkerIcons(config)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// The font is parsed once; without it text uses the bitmap font
+	textFont, err := mesh.LoadFont(config)
+	if err != nil {
+		log.Printf("Warning: font not loaded: %v", err)
+	}
+
+	// The floorplan is decoded once; its wall snap is recomputed only when
+	// the reference map changes
+	floorplan, err := mesh.LoadFloorplan(config)
+	if err != nil {
+		log.Printf("Warning: floorplan not loaded: %v", err)
+	}
+
+	// reference returns the configured reference vacuum, or the one picked
+	// from maps when none is configured
+	reference := func(maps map[string]*mesh.ValetudoMap) string {
+		if refID != "" {
+			return refID
+		}
+		return mesh.SelectReferenceVacuum(maps, nil)
+	}
+
+	// worldTransforms returns each map's transform into the world frame,
+	// from the calibration in use snapped to the floorplan, and the
+	// reference vacuum they are relative to
+	worldTransforms := func(maps map[string]*mesh.ValetudoMap) (map[string]mesh.AffineMatrix, string) {
+		ref := reference(maps)
+		return floorplan.SnapTransforms(maps, buildTransforms(maps, calibration()), ref), ref
+	}
 
 	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	api.handle(endpoint{
+		Path:        "/health",
+		Summary:     "Service health and per-vacuum status",
+		Tag:         "status",
+		ContentType: "application/json",
+	}, func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[HTTP] /health request from %s", r.RemoteAddr)
 		w.Header().Set("Content-Type", "application/json")
+		now := time.Now()
+		vacuums := vacuumHealth(stateTracker, calibration(), config, refID, now)
 		status := struct {
-			Status    string    `json:"status"`
-			Timestamp time.Time `json:"timestamp"`
-			HasMaps   bool      `json:"hasMaps"`
+			Status    string              `json:"status"`
+			Timestamp time.Time           `json:"timestamp"`
+			HasMaps   bool                `json:"hasMaps"`
+			Panics    int64               `json:"panics"` // recovered in handlers and background jobs since startup
+			Vacuums   []mesh.VacuumHealth `json:"vacuums"`
 		}{
 			Status:    "ok",
-			Timestamp: time.Now(),
+			Timestamp: now,
 			HasMaps:   stateTracker.HasMaps(),
+			Panics:    mesh.RecoveredPanics(),
+			Vacuums:   vacuums,
+		}
+		for _, v := range vacuums {
+			if v.Status != mesh.HealthOK {
+				status.Status = "degraded"
+			}
 		}
 		if err := json.NewEncoder(w).Encode(status); err != nil {
 			log.Printf("Error encoding health status: %v", err)
 		}
 	})
 
-	// Composite map endpoint (color-coded)
-	mux.HandleFunc("/composite-map.png", func(w http.ResponseWriter, r *http.Request) {
+	// Image endpoints: each Renderer in the registry is served at its path
+	renderers := newRendererRegistry(&renderEnv{config: config, icons: icons, font: textFont, floorplan: floorplan, budget: budget, rotation: rotation})
+	renderOptions := func(r *http.Request, maps map[string]*mesh.ValetudoMap) RenderOptions {
+		transforms, effectiveRef := worldTransforms(maps)
+		return RenderOptions{
+			Maps:       maps,
+			Transforms: transforms,
+			Reference:  effectiveRef,
+			Rotation:   rotation(maps, effectiveRef),
+			Query:      r.URL.Query(),
+			Positions:  stateTracker.GetPositions(),
+			Active:     stateTracker.GetActiveAreas(),
+			Unified:    stateTracker.GetUnifiedMap(),
+			Format:     requestedFormat(r),
+		}
+	}
+	for _, e := range renderers.entries {
+		renderer := e.renderer
+		api.handle(e.endpoint, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+			writeRender(w, r, renderer, renderOptions(r, stateTracker.GetMaps()))
+		}))
+	}
+
+	// Composite of one render profile from config.yaml
+	api.handle(endpoint{
+		Path:        "/profiles/{name}/composite-map.png",
+		Summary:     "Color-coded composite of a render profile",
+		Description: "Renders only the vacuums of the named profile from config.yaml, rotated by the profile's rotation when set. Names match case-insensitively.",
+		Tag:         "maps",
+		ContentType: "image/png",
+		Params: append([]endpointParam{
+			{Name: "name", In: "path", Type: "string", Description: "Profile name"},
+		}, rasterParams...),
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		profile := config.GetProfile(r.PathValue("name"))
+		if profile == nil {
+			http.Error(w, fmt.Sprintf("Unknown profile %q", r.PathValue("name")), http.StatusNotFound)
+			return
+		}
+		opts := renderOptions(r, profile.Select(stateTracker.GetMaps()))
+		if profile.Rotation != nil {
+			opts.Rotation = *profile.Rotation
+		}
+		composite, _ := renderers.lookup("/composite-map.png")
+		writeRender(w, r, composite, opts)
+	}))
+
+	// Single-room endpoint: composite cropped to one named segment. ServeMux
+	// wildcards must span a whole path segment, so the .png suffix is
+	// stripped here
+	api.handle(endpoint{
+		Path:        "/room/{segment}",
+		Summary:     "Composite map cropped to one room",
+		Description: "Renders the color-coded composite cropped to the named segment plus a small margin, e.g. /room/Kitchen.png. Names match case-insensitively; segment IDs are also accepted.",
+		Tag:         "maps",
+		ContentType: "image/png",
+		Params: append([]endpointParam{
+			{Name: "segment", In: "path", Type: "string", Description: "Segment name or ID followed by .png"},
+		}, rasterParams...),
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutSuffix(r.PathValue("segment"), ".png")
+		if !ok || name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		format := requestedFormat(r)
+		if err := checkFormat(format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		legend, err := legendOptions(config, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overlay, err := overlayOptions(config, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		maps := stateTracker.GetMaps()
 		if len(maps) == 0 {
 			http.Error(w, "No maps available", http.StatusServiceUnavailable)
 			return
 		}
 
-		// Build transforms from cache
-		transforms := buildTransforms(maps, cache)
+		transforms, effectiveRef := worldTransforms(maps)
 
-		// Determine effective reference
-		effectiveRef := refID
-		if effectiveRef == "" {
-			effectiveRef = mesh.SelectReferenceVacuum(maps, nil)
+		region, ok := mesh.SegmentRegion(maps, transforms, effectiveRef, name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown segment %q", name), http.StatusNotFound)
+			return
 		}
+		region = region.Expand(mesh.SegmentCropMargin)
 
-		// Create renderer with colors from config
 		renderer := mesh.NewCompositeRenderer(maps, transforms, effectiveRef)
-		renderer.GlobalRotation = rotateAll
+		renderer.GlobalRotation = rotation(maps, effectiveRef)
+		renderer.MaxDimension = budget.MaxRenderDimension()
+		renderer.FillFloors = config != nil && config.Memory.FillFloors
+		renderer.Crop = &region
+		renderer.Padding = 0
 
-		// Apply colors from config
 		applyConfigColors(renderer, config)
+		renderer.Legend = legend
+		renderer.Overlay = overlay
+		renderer.Icons = icons
+		renderer.Font = textFont
+		renderer.Floorplan = floorplan
+
+		writeImage(w, renderer.Render(), format)
+	}))
+
+	// Cleaning frequency over the unified floor plan
+	api.handle(endpoint{
+		Path:        "/heatmap.png",
+		Summary:     "Heatmap of cleaning frequency",
+		Description: "Colors each 10cm cell of the unified floor plan by how often a vacuum visited it, from blue (rarely) to red (most often).",
+		Tag:         "maps",
+		ContentType: "image/png",
+		Params: []endpointParam{
+			{Name: "days", In: "query", Type: "integer", Description: fmt.Sprintf("Days of history to include, 1-%d (default %d)", mesh.HeatmapRetentionDays, mesh.DefaultHeatmapDays)},
+			formatParam,
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		days := mesh.DefaultHeatmapDays
+		if v := r.URL.Query().Get("days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > mesh.HeatmapRetentionDays {
+				http.Error(w, fmt.Sprintf("invalid days value %q (must be 1-%d)", v, mesh.HeatmapRetentionDays), http.StatusBadRequest)
+				return
+			}
+			days = n
+		}
+		format := requestedFormat(r)
+		if err := checkFormat(format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		um := stateTracker.GetUnifiedMap()
+		unified := mesh.NewUnifiedRenderer(um)
+		if !unified.HasDrawableContent() {
+			http.Error(w, "No unified map available", http.StatusServiceUnavailable)
+			return
+		}
+		heatmap := stateTracker.Heatmap()
+		since := time.Now().AddDate(0, 0, 1-days)
+		unified.Heat = &mesh.HeatGrid{CellSize: heatmap.CellSize, Counts: heatmap.Counts(since)}
+		unified.GlobalRotation = rotation(stateTracker.GetMaps(), um.Metadata.ReferenceVacuum)
+		unified.MaxDimension = budget.MaxRenderDimension()
+
+		writeImage(w, unified.Render(), format)
+	}))
 
-		// If no drawable content exists, return service unavailable to avoid generating invalid images
-		if !renderer.HasDrawableContent() {
-			log.Printf("Warning: maps present but no drawable content; endpoint=/composite-map.png")
-			http.Error(w, "No drawable map content", http.StatusServiceUnavailable)
+	// Position tracks: each vacuum's recent path as a GeoJSON LineString
+	api.handle(endpoint{
+		Path:        "/tracks.geojson",
+		Summary:     "Recent vacuum position tracks",
+		Description: "GeoJSON FeatureCollection with one LineString per vacuum in world coordinates (mm); the coordTimes property holds the timestamp of each coordinate.",
+		Tag:         "live",
+		ContentType: "application/geo+json",
+		Params: []endpointParam{
+			{Name: "since", In: "query", Type: "string", Description: "Only positions from this RFC 3339 time, Unix timestamp (seconds) or duration ago (e.g. 30m)"},
+		},
+		Errors: []int{http.StatusBadRequest},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		since, err := parseSince(r.URL.Query().Get("since"), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Render and send
-		img := renderer.Render()
-		w.Header().Set("Content-Type", "image/png")
+		fc := mesh.TracksFeatureCollection(stateTracker.GetTracks(since), stateTracker.GetMaps(), config)
+		w.Header().Set("Content-Type", "application/geo+json")
 		w.Header().Set("Cache-Control", "no-cache")
-		if err := png.Encode(w, img); err != nil {
-			log.Printf("Error encoding composite map PNG: %v", err)
+		if err := json.NewEncoder(w).Encode(fc); err != nil {
+			log.Printf("Error encoding tracks: %v", err)
 		}
 	})
 
-	// Live positions endpoint
-	mux.HandleFunc("/live.png", func(w http.ResponseWriter, r *http.Request) {
+	// Handoff zones: where each pair of vacuums' coverage overlaps
+	api.handle(endpoint{
+		Path:        "/handoff.json",
+		Summary:     "Coverage overlap between each pair of vacuums",
+		Description: "GeoJSON FeatureCollection with one MultiPolygon per vacuum pair, in the reference map's coordinates (mm).",
+		Tag:         "maps",
+		ContentType: "application/geo+json",
+		Errors:      []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
 		maps := stateTracker.GetMaps()
 		if len(maps) == 0 {
 			http.Error(w, "No maps available", http.StatusServiceUnavailable)
 			return
 		}
 
-		// Build transforms from cache
-		transforms := buildTransforms(maps, cache)
+		transforms, effectiveRef := worldTransforms(maps)
 
-		// Determine effective reference
-		effectiveRef := refID
-		if effectiveRef == "" {
-			effectiveRef = mesh.SelectReferenceVacuum(maps, nil)
+		zones := mesh.HandoffZones(maps, transforms, effectiveRef)
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(mesh.HandoffFeatureCollection(zones, config)); err != nil {
+			log.Printf("Error encoding handoff zones: %v", err)
 		}
+	}))
 
-		// Create renderer
-		renderer := mesh.NewCompositeRenderer(maps, transforms, effectiveRef)
-		renderer.GlobalRotation = rotateAll
-
-		// If no drawable content exists, we can still show positions on a blank map
-		if !renderer.HasDrawableContent() {
-			// Add more debug logging for layer keys if no drawable content
-			for id, m := range maps {
-				layerSummary := ""
-				if len(m.Layers) > 0 {
-					layerTypes := make([]string, len(m.Layers))
-					for i, layer := range m.Layers {
-						layerTypes[i] = layer.Type
-					}
-					layerSummary = strings.Join(layerTypes, ", ")
+	// Coverage statistics: floor area and how much of it vacuums share
+	api.handle(endpoint{
+		Path:        "/stats.json",
+		Summary:     "Floor coverage statistics",
+		Description: "Total floor area (m²) of all vacuums combined, the fraction covered by more than one vacuum, and the overlap of each pair, from the current maps and calibration. Includes the unified map's metadata once it has been built.",
+		Tag:         "status",
+		ContentType: "application/json",
+		Errors:      []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		maps := stateTracker.GetMaps()
+		if len(maps) == 0 {
+			http.Error(w, "No maps available", http.StatusServiceUnavailable)
+			return
+		}
+
+		transforms, effectiveRef := worldTransforms(maps)
+
+		stats := struct {
+			ReferenceVacuum string `json:"referenceVacuum"`
+			VacuumCount     int    `json:"vacuumCount"`
+			mesh.CoverageStats
+			UnifiedMap *mesh.UnifiedMetadata `json:"unifiedMap,omitempty"`
+		}{
+			ReferenceVacuum: effectiveRef,
+			VacuumCount:     len(maps),
+			CoverageStats:   mesh.ComputeCoverageStats(maps, transforms, effectiveRef),
+		}
+		if um := stateTracker.GetUnifiedMap(); um != nil {
+			stats.UnifiedMap = &um.Metadata
+		}
+		writeJSON(w, http.StatusOK, stats)
+	}))
+
+	// Unified map change feed; not behind the render limiter, which would
+	// hold a render slot for the life of the stream
+	api.handle(endpoint{
+		Path:        "/events",
+		Summary:     "Unified map change events",
+		Description: "Server-sent events. A map-updated event carries the current unified map version on connect and is repeated for every new version, with counts of added, removed and changed walls, floors and segments. Re-fetch the map when the version differs from the one you hold.",
+		Tag:         "status",
+		ContentType: "text/event-stream",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		changes, unsubscribe := stateTracker.Changes().Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		if um := stateTracker.GetUnifiedMap(); um != nil {
+			writeMapEvent(w, mesh.MapChange{
+				Version:         um.Metadata.Version,
+				PreviousVersion: um.Metadata.Version,
+				Timestamp:       um.Metadata.LastUpdated,
+			})
+		} else {
+			_, _ = fmt.Fprint(w, ": no unified map yet\n\n")
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case change, ok := <-changes:
+				if !ok {
+					return
 				}
-				log.Printf("[DEBUG] renderer: map %s has no drawable pixels. Layers found: [%s]", id, layerSummary)
+				writeMapEvent(w, change)
+			case <-keepAlive.C:
+				_, _ = fmt.Fprint(w, ": keep-alive\n\n")
 			}
-			log.Printf("[DEBUG] renderer: no drawable content for /live.png, rendering positions only")
+			flusher.Flush()
 		}
+	})
 
-		// Get live positions
-		positions := stateTracker.GetPositions()
+	// Hit-testing: which unified room a world point lies in
+	api.handle(endpoint{
+		Path:        "/segment",
+		Summary:     "Unified segment at a point",
+		Description: "Returns the unified segment (room) containing the world point (mm): its name, area (m²), centroid, the vacuums that observed it and the merge confidence. Where segments overlap the smallest wins.",
+		Tag:         "maps",
+		ContentType: "application/json",
+		Params: []endpointParam{
+			{Name: "x", In: "query", Type: "number", Description: "World X (mm)", Required: true},
+			{Name: "y", In: "query", Type: "number", Description: "World Y (mm)", Required: true},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		p, err := parsePointQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if stateTracker.GetUnifiedMap() == nil {
+			http.Error(w, "No unified map available", http.StatusServiceUnavailable)
+			return
+		}
+		seg, ok := stateTracker.SegmentIndex().SegmentAt(p)
+		if !ok {
+			http.Error(w, fmt.Sprintf("No segment at (%g, %g)", p.X, p.Y), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, seg)
+	})
 
-		// Render with positions and send
-		img := renderer.RenderLive(positions)
-		w.Header().Set("Content-Type", "image/png")
-		w.Header().Set("Cache-Control", "no-cache")
-		if err := png.Encode(w, img); err != nil {
-			log.Printf("Error encoding live PNG: %v", err)
+	// The unified map with provenance, for tooling the GeoJSON export loses
+	api.handle(endpoint{
+		Path:        "/unified-map.json",
+		Summary:     "Unified map with sources",
+		Description: "The unified map's walls, floors, segments and materials in world coordinates (mm), each with its merge confidence and the vacuums that observed it: their original geometry, timestamp and ICP score. Filters narrow the features; the metadata is always included.",
+		Tag:         "maps",
+		ContentType: "application/json",
+		Params: []endpointParam{
+			{Name: "minConfidence", In: "query", Type: "number", Description: "Only features at or above this confidence (0-1)"},
+			{Name: "types", In: "query", Type: "string", Description: "Comma-separated feature lists to include: walls, floors, segments, materials (default all)"},
+			{Name: "vacuum", In: "query", Type: "string", Description: "Only features this vacuum observed"},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseUnifiedMapFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		um := stateTracker.GetUnifiedMap()
+		if um == nil {
+			http.Error(w, "No unified map available", http.StatusServiceUnavailable)
+			return
 		}
+		writeJSON(w, http.StatusOK, um.Filter(filter))
 	})
 
-	// Vector SVG endpoints
-	// Composite map SVG endpoint
-	mux.HandleFunc("/composite-map.svg", func(w http.ResponseWriter, r *http.Request) {
+	// The unified map extruded to 3D, for Home Assistant's 3D floorplan,
+	// Blender and CAD
+	var modelConfig mesh.Model3DConfig
+	if config != nil {
+		modelConfig = config.Model3D
+	}
+	for _, format := range []struct {
+		path, contentType string
+		write             func(io.Writer, *mesh.Model) error
+	}{
+		{"/floorplan.gltf", "model/gltf+json", mesh.WriteGLTF},
+		{"/floorplan.obj", "model/obj", mesh.WriteOBJ},
+	} {
+		api.handle(endpoint{
+			Path:        format.path,
+			Summary:     "3D model of the unified floorplan",
+			Description: "The unified map's walls extruded to boxes standing on floor slabs, in meters with Y up. Dimensions come from model3d in the config; wallHeight overrides the wall height.",
+			Tag:         "maps",
+			ContentType: format.contentType,
+			Params: []endpointParam{
+				{Name: "wallHeight", In: "query", Type: "number", Description: "Wall height in mm (default model3d.wallHeight, 2400)"},
+			},
+			Errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			opts := modelConfig.Options()
+			if v := r.URL.Query().Get("wallHeight"); v != "" {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil || f <= 0 || math.IsInf(f, 0) {
+					http.Error(w, fmt.Sprintf("invalid wallHeight %q: must be a positive number", v), http.StatusBadRequest)
+					return
+				}
+				opts.WallHeight = f
+			}
+			model := mesh.BuildModel(stateTracker.GetUnifiedMap(), opts)
+			if len(model.Parts) == 0 {
+				http.Error(w, "No unified map available", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", format.contentType)
+			if err := format.write(w, model); err != nil {
+				log.Printf("Error writing %s: %v", format.path, err)
+			}
+		})
+	}
+
+	// ROS occupancy grid: the PGM and the map_server YAML describing it
+	for _, format := range []struct {
+		path, contentType, summary string
+		write                      func(*mesh.OccupancyGrid, io.Writer) error
+	}{
+		{"/map.pgm", "image/x-portable-graymap", "Unified map as a ROS occupancy grid", (*mesh.OccupancyGrid).WritePGM},
+		{"/map.yaml", "application/yaml", "map_server metadata for /map.pgm", func(g *mesh.OccupancyGrid, w io.Writer) error {
+			return g.WriteYAML(w, "map.pgm")
+		}},
+	} {
+		api.handle(endpoint{
+			Path:        format.path,
+			Summary:     format.summary,
+			Description: "The unified map rasterized for ROS navigation: walls occupied (0), floors free (254), everything else unknown (205). The YAML gives the resolution and the origin in meters, with the map's Y flipped to point up; request both with the same resolution.",
+			Tag:         "maps",
+			ContentType: format.contentType,
+			Params: []endpointParam{
+				{Name: "resolution", In: "query", Type: "number", Description: "Meters per cell, 0.01 to 1 (default 0.05)"},
+			},
+			Errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+		}, func(w http.ResponseWriter, r *http.Request) {
+			resolution := mesh.DefaultOccupancyResolution
+			if v := r.URL.Query().Get("resolution"); v != "" {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil || f < 0.01 || f > 1 {
+					http.Error(w, fmt.Sprintf("invalid resolution %q: must be between 0.01 and 1 meters", v), http.StatusBadRequest)
+					return
+				}
+				resolution = f
+			}
+			grid := mesh.BuildOccupancyGrid(stateTracker.GetUnifiedMap(), resolution)
+			if grid == nil {
+				http.Error(w, "No unified map available", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", format.contentType)
+			if err := format.write(grid, w); err != nil {
+				log.Printf("Error writing %s: %v", format.path, err)
+			}
+		})
+	}
+
+	// Frontiers: floor edges leading into unexplored space
+	api.handle(endpoint{
+		Path:        "/frontiers",
+		Summary:     "Unexplored floor edges",
+		Description: "Edges of the mapped floor that no wall closes off, in world coordinates (mm): for the unified map, when one exists, and for each vacuum. Each frontier has its path, its length and a midpoint to send a robot to. Openings shorter than 30cm are left out.",
+		Tag:         "maps",
+		ContentType: "application/json",
+		Params: []endpointParam{
+			{Name: "vacuum", In: "query", Type: "string", Description: "Only this vacuum's frontiers"},
+		},
+		Errors: []int{http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
 		maps := stateTracker.GetMaps()
 		if len(maps) == 0 {
 			http.Error(w, "No maps available", http.StatusServiceUnavailable)
 			return
 		}
+		vacuumID := r.URL.Query().Get("vacuum")
+		if _, ok := maps[vacuumID]; vacuumID != "" && !ok {
+			http.Error(w, fmt.Sprintf("Unknown vacuum %q", vacuumID), http.StatusNotFound)
+			return
+		}
 
-		// Build transforms from cache
-		transforms := buildTransforms(maps, cache)
+		transforms, _ := worldTransforms(maps)
 
-		// Determine effective reference
-		effectiveRef := refID
-		if effectiveRef == "" {
-			effectiveRef = mesh.SelectReferenceVacuum(maps, nil)
+		report := mesh.FrontierReport{Unified: []mesh.Frontier{}, Vacuums: make(map[string][]mesh.Frontier)}
+		if vacuumID == "" {
+			if um := stateTracker.GetUnifiedMap(); um != nil {
+				report.Unified = append(report.Unified, um.Frontiers()...)
+			}
 		}
+		for id, m := range maps {
+			if vacuumID != "" && id != vacuumID {
+				continue
+			}
+			report.Vacuums[id] = append([]mesh.Frontier{}, mesh.VacuumFrontiers(m, id, transforms[id])...)
+		}
+		writeJSON(w, http.StatusOK, report)
+	}))
+
+	// Cleaning zones in world coordinates, seeded from config
+	var zoneConfigs []mesh.ZoneConfig
+	if config != nil {
+		zoneConfigs = config.Zones
+	}
+	zones := mesh.NewZoneSet(zoneConfigs)
+	zoneParam := endpointParam{Name: "name", In: "path", Type: "string", Description: "Zone name (case-insensitive)"}
 
-		// Create vector renderer
-		vectorRenderer := mesh.NewVectorRenderer(maps, transforms, effectiveRef)
-		vectorRenderer.GlobalRotation = rotateAll
+	api.handle(endpoint{
+		Path:        "/zones",
+		Summary:     "List cleaning zones",
+		Tag:         "zones",
+		ContentType: "application/json",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, zones.List())
+	})
 
-		// Apply grid spacing from config if available
-		if config != nil && config.GridSpacing > 0 {
-			vectorRenderer.Padding = config.GridSpacing / 2
+	api.handle(endpoint{
+		Path:        "/zones/{name}",
+		Method:      http.MethodPut,
+		Summary:     "Create or replace a cleaning zone",
+		Description: "Body: {\"points\": [{\"x\": 0, \"y\": 0}, ...], \"iterations\": 1, \"vacuums\": [...]} in world coordinates (mm). Two points define a rectangle. Zones added here are not saved to config.yaml.",
+		Tag:         "zones",
+		ContentType: "application/json",
+		Params:      []endpointParam{zoneParam},
+		Errors:      []int{http.StatusBadRequest},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		var zone mesh.ZoneConfig
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&zone); err != nil {
+			http.Error(w, fmt.Sprintf("invalid zone JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		zone.Name = r.PathValue("name")
+		if err := zones.Put(zone); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		writeJSON(w, http.StatusOK, zone)
+	})
 
-		// Render SVG
-		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Header().Set("Cache-Control", "no-cache")
-		if err := vectorRenderer.RenderToSVG(w); err != nil {
-			log.Printf("Error encoding composite map SVG: %v", err)
+	api.handle(endpoint{
+		Path:        "/zones/{name}",
+		Method:      http.MethodDelete,
+		Summary:     "Delete a cleaning zone",
+		Tag:         "zones",
+		ContentType: "application/json",
+		Params:      []endpointParam{zoneParam},
+		Errors:      []int{http.StatusNotFound},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if !zones.Delete(r.PathValue("name")) {
+			http.Error(w, "Unknown zone", http.StatusNotFound)
+			return
 		}
+		w.WriteHeader(http.StatusNoContent)
 	})
 
-	// Floorplan SVG endpoint
-	mux.HandleFunc("/floorplan.svg", func(w http.ResponseWriter, r *http.Request) {
+	api.handle(endpoint{
+		Path:        "/zones/{name}/clean",
+		Method:      http.MethodPost,
+		Summary:     "Clean a zone with every vacuum that covers it",
+		Description: "Translates the zone into each vacuum's own coordinates, clipped to the area it has mapped, and publishes Valetudo zone-cleaning commands. Returns the commands sent.",
+		Tag:         "zones",
+		ContentType: "application/json",
+		Params: []endpointParam{
+			zoneParam,
+			{Name: "dryRun", In: "query", Type: "boolean", Description: "Return the commands without sending them"},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dryRun"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid dryRun value %q", v), http.StatusBadRequest)
+				return
+			}
+			dryRun = b
+		}
+
+		zone, ok := zones.Get(r.PathValue("name"))
+		if !ok {
+			http.Error(w, "Unknown zone", http.StatusNotFound)
+			return
+		}
 		maps := stateTracker.GetMaps()
 		if len(maps) == 0 {
 			http.Error(w, "No maps available", http.StatusServiceUnavailable)
 			return
 		}
+		if !dryRun && commands == nil {
+			http.Error(w, "MQTT not available", http.StatusServiceUnavailable)
+			return
+		}
 
-		// Build transforms from cache
-		transforms := buildTransforms(maps, cache)
+		transforms, effectiveRef := worldTransforms(maps)
 
-		// Determine effective reference
-		effectiveRef := refID
-		if effectiveRef == "" {
-			effectiveRef = mesh.SelectReferenceVacuum(maps, nil)
+		plans := mesh.PlanZoneClean(zone, maps, transforms, effectiveRef)
+		if len(plans) == 0 {
+			http.Error(w, "No vacuum has mapped this zone", http.StatusUnprocessableEntity)
+			return
+		}
+		if !dryRun {
+			for _, plan := range plans {
+				if err := commands.StartZoneCleaning(plan); err != nil {
+					http.Error(w, fmt.Sprintf("%s: %v", plan.VacuumID, err), http.StatusBadGateway)
+					return
+				}
+			}
 		}
+		writeJSON(w, http.StatusOK, plans)
+	}))
 
-		// Create vector renderer
-		vectorRenderer := mesh.NewVectorRenderer(maps, transforms, effectiveRef)
-		vectorRenderer.GlobalRotation = rotateAll
+	// Calibration in use, for the dashboard and other status pages
+	api.handle(endpoint{
+		Path:        "/calibration.json",
+		Summary:     "Current calibration of each vacuum",
+		Description: "The reference vacuum and, per vacuum sorted by ID, the rotation (degrees) and translation (mm) of its transform into the reference frame, its ICP score (inlier fraction, 0 when unknown) and when it was last calibrated (Unix seconds).",
+		Tag:         "calibration",
+		ContentType: "application/json",
+		Errors:      []int{http.StatusServiceUnavailable},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		cal := calibration()
+		if cal == nil || len(cal.Vacuums) == 0 {
+			http.Error(w, "No calibration available", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusOK, summarizeCalibration(cal, config))
+	})
 
-		// Apply grid spacing from config if available
-		if config != nil && config.GridSpacing > 0 {
-			vectorRenderer.Padding = config.GridSpacing / 2
+	// Calibration: re-run ICP alignment against fresh maps from the robots
+	api.handle(endpoint{
+		Path:        "/calibrate",
+		Method:      http.MethodPost,
+		Summary:     "Recalibrate vacuums",
+		Description: "Queues a calibration for each vacuum on the leader: it fetches a fresh map from the vacuum's apiUrl and aligns it to the reference, as on docking. Returns 202 with the queued vacuums; the new transforms appear in /calibration.json once aligned. Standby instances return 503.",
+		Tag:         "calibration",
+		ContentType: "application/json",
+		Status:      http.StatusAccepted,
+		Params: []endpointParam{
+			{Name: "vacuum", In: "query", Type: "string", Description: "Only calibrate this vacuum (default: every configured vacuum)"},
+		},
+		Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if calibrator == nil || work == nil || config == nil {
+			http.Error(w, "Calibration requires --mqtt service mode", http.StatusServiceUnavailable)
+			return
+		}
+		if isLeader != nil && !isLeader() {
+			http.Error(w, "Standby instance: calibration runs on the leader", http.StatusServiceUnavailable)
+			return
 		}
 
-		// Render SVG
-		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Header().Set("Cache-Control", "no-cache")
-		if err := vectorRenderer.RenderToSVG(w); err != nil {
-			log.Printf("Error encoding floorplan SVG: %v", err)
+		var ids []string
+		if id := r.URL.Query().Get("vacuum"); id != "" {
+			if config.GetVacuumByID(id) == nil {
+				http.Error(w, "Unknown vacuum", http.StatusNotFound)
+				return
+			}
+			ids = []string{id}
+		} else {
+			for _, vc := range config.Vacuums {
+				ids = append(ids, vc.ID)
+			}
+		}
+
+		// Shares the docking key, so a waiting docking calibration runs once
+		for _, id := range ids {
+			work.Enqueue("calibrate/"+id, func() {
+				if err := calibrator.Calibrate(id); err != nil {
+					log.Printf("[AUTO-CAL] Requested calibration of %s failed: %v", id, err)
+				}
+			})
+		}
+		writeJSON(w, http.StatusAccepted, calibrationQueued{Queued: ids})
+	})
+
+	// Map push: robots and bridges that cannot publish over MQTT send their
+	// map here instead
+	api.handle(endpoint{
+		Path:        "/maps/{vacuum}",
+		Method:      http.MethodPost,
+		Summary:     "Push a vacuum's map",
+		Description: "Body: a Valetudo map export, as JSON or as the PNG with the map in its zTXt chunk that Valetudo publishes. The map is checked, stored and used for the robot's position, calibration and the unified map like one received over MQTT; corrupt or partial maps are rejected with 422. Returns whether the stored map changed.",
+		Tag:         "maps",
+		ContentType: "application/json",
+		Params: []endpointParam{
+			{Name: "vacuum", In: "path", Type: "string", Description: "Vacuum ID from config.yaml"},
+		},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity, http.StatusServiceUnavailable},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		if push == nil {
+			http.Error(w, "Map push requires service mode", http.StatusServiceUnavailable)
+			return
+		}
+		id := r.PathValue("vacuum")
+		if config == nil || config.GetVacuumByID(id) == nil {
+			http.Error(w, "Unknown vacuum", http.StatusNotFound)
+			return
+		}
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushedMapBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("map larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("reading map: %v", err), http.StatusBadRequest)
+			return
+		}
+		m, err := mesh.DecodeMapDataWithOptions(data, config.ParseOptions())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid map: %v", err), http.StatusBadRequest)
+			return
+		}
+		changed, err := push(id, m)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("map rejected: %v", err), http.StatusUnprocessableEntity)
+			return
 		}
+		writeJSON(w, http.StatusOK, struct {
+			Vacuum  string `json:"vacuum"`
+			Changed bool   `json:"changed"`
+		}{id, changed})
 	})
 
-	// Live SVG endpoint
-	mux.HandleFunc("/live.svg", func(w http.ResponseWriter, r *http.Request) {
+	// Rotation comparison of one vacuum as a single captioned grid. ServeMux
+	// wildcards must span a whole path segment, so the .png suffix is
+	// stripped here
+	api.handle(endpoint{
+		Path:        "/compare-rotation/{vacuum}",
+		Summary:     "Rotation options of a vacuum in one image",
+		Description: "Renders the composite with the vacuum turned by each candidate rotation, two per row, each captioned with the rotation and its alignment score against the reference (higher is better; the best is green), e.g. /compare-rotation/rocky.png. Set the rotation that lines the walls up as the vacuum's rotation in config.yaml.",
+		Tag:         "calibration",
+		ContentType: "image/png",
+		Params: append([]endpointParam{
+			{Name: "vacuum", In: "path", Type: "string", Description: "Vacuum ID followed by .png"},
+			{Name: "angles", In: "query", Type: "string", Description: "Comma-separated rotations in degrees (default 0,90,180,270)"},
+		}, rasterParams...),
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutSuffix(r.PathValue("vacuum"), ".png")
+		if !ok || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		format := requestedFormat(r)
+		if err := checkFormat(format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var rotations []float64
+		if v := r.URL.Query().Get("angles"); v != "" {
+			if err := (angleListFlag{angles: &rotations}).Set(v); err != nil {
+				http.Error(w, "angles "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		legend, err := legendOptions(config, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overlay, err := overlayOptions(config, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		maps := stateTracker.GetMaps()
 		if len(maps) == 0 {
 			http.Error(w, "No maps available", http.StatusServiceUnavailable)
 			return
 		}
-
-		// Build transforms from cache
-		transforms := buildTransforms(maps, cache)
-
-		// Determine effective reference
-		effectiveRef := refID
-		if effectiveRef == "" {
-			effectiveRef = mesh.SelectReferenceVacuum(maps, nil)
+		if maps[id] == nil {
+			http.Error(w, fmt.Sprintf("Unknown vacuum %q", id), http.StatusNotFound)
+			return
 		}
 
-		// Create vector renderer
-		vectorRenderer := mesh.NewVectorRenderer(maps, transforms, effectiveRef)
-		vectorRenderer.GlobalRotation = rotateAll
+		transforms, effectiveRef := worldTransforms(maps)
+		if id == effectiveRef {
+			http.Error(w, fmt.Sprintf("%s is the reference vacuum, which is not rotated", id), http.StatusBadRequest)
+			return
+		}
 
-		// Apply grid spacing from config if available
-		if config != nil && config.GridSpacing > 0 {
-			vectorRenderer.Padding = config.GridSpacing / 2
+		variants, err := mesh.RotationVariants(maps, transforms, effectiveRef, id, rotations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		// Get live positions
-		positions := stateTracker.GetPositions()
+		// Two images per row must still fit the render budget
+		globalRotation := rotation(maps, effectiveRef)
+		maxDimension := budget.MaxRenderDimension() / 2
+		grid := mesh.RenderRotationGrid(variants, func(v mesh.RotationVariant) *image.RGBA {
+			renderer := mesh.NewCompositeRenderer(maps, v.Transforms, effectiveRef)
+			renderer.GlobalRotation = globalRotation
+			renderer.MaxDimension = maxDimension
+			applyConfigColors(renderer, config)
+			renderer.Legend = legend
+			renderer.Overlay = overlay
+			renderer.Icons = icons
+			renderer.Font = textFont
+			renderer.Floorplan = floorplan
+			return renderer.Render()
+		}, textFont)
 
-		// Render live SVG
-		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Header().Set("Cache-Control", "no-cache")
-		if err := vectorRenderer.RenderLiveToSVG(w, positions); err != nil {
-			log.Printf("Error encoding live SVG: %v", err)
+		writeImage(w, grid, format)
+	}))
+
+	// Rotation detection as --detect-rotation prints it, for setup tooling
+	api.handle(endpoint{
+		Path:        "/rotation-analysis.json",
+		Summary:     "Detected rotation of each vacuum",
+		Description: "For each vacuum other than the reference: its dominant wall angles, the similarity score of each cardinal rotation against the reference, the best rotation and the confidence in it (0-1), as --detect-rotation reports. Also lists the reference's dominant wall angles.",
+		Tag:         "calibration",
+		ContentType: "application/json",
+		Errors:      []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}, limiter.wrap(func(w http.ResponseWriter, r *http.Request) {
+		maps := stateTracker.GetMaps()
+		if len(maps) == 0 {
+			http.Error(w, "No maps available", http.StatusServiceUnavailable)
+			return
 		}
-	})
 
-	// Default route serves HTML page embedding the SVG map
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, mesh.AnalyzeRotations(maps, reference(maps)))
+	}))
+
+	// Default route serves the dashboard, built on the endpoints above
+	api.handle(endpoint{
+		Path:        "/",
+		Summary:     "Dashboard with the live map, vacuum status and calibration",
+		ContentType: "text/html",
+		Hidden:      true,
+	}, func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
 			http.NotFound(w, r)
 			return
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-cache")
-		_, _ = fmt.Fprint(w, `<!DOCTYPE html>
-<html lang="en">
-<head>
-<meta charset="utf-8">
-<meta name="viewport" content="width=device-width, initial-scale=1">
-<title>tudomesh</title>
-<style>
-*{margin:0;padding:0;box-sizing:border-box}
-html,body{width:100%;height:100%;overflow:hidden;background:#1a1a1a}
-img{display:block;width:100vw;height:100vh;object-fit:contain}
-</style>
-</head>
-<body>
-<img src="/live.svg" alt="Live Map">
-</body>
-</html>`)
+		_, _ = w.Write(dashboardPage)
 	})
 
-	// Wrap mux with logging middleware
+	// API documentation generated from the registrations above
+	api.registerDocs()
+
+	// Auth runs inside CORS so refused requests still carry CORS headers
+	// and preflights never need a token
+	var corsConfig *mesh.CORSConfig
+	var authConfig *mesh.AuthConfig
+	if httpConfig != nil {
+		corsConfig = &httpConfig.CORS
+		authConfig = &httpConfig.Auth
+	}
+	handler := recoverMiddleware(corsMiddleware(corsConfig, authMiddleware(authConfig, mux)))
+
+	// Wrap with logging middleware
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[HTTP] %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-		mux.ServeHTTP(w, r)
+		handler.ServeHTTP(w, r)
 	})
 }
 
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// calibrationSummary is the /calibration.json response.
+type calibrationSummary struct {
+	ReferenceVacuum string                     `json:"referenceVacuum"`
+	Vacuums         []vacuumCalibrationSummary `json:"vacuums"`
+}
+
+// vacuumCalibrationSummary is one vacuum's transform in readable form.
+type vacuumCalibrationSummary struct {
+	VacuumID    string     `json:"vacuumId"`
+	DisplayName string     `json:"displayName"`
+	Rotation    float64    `json:"rotation"`    // degrees
+	Translation mesh.Point `json:"translation"` // millimeters
+	ICPScore    float64    `json:"icpScore"`
+	LastUpdated int64      `json:"lastUpdated,omitempty"`
+	Frozen      bool       `json:"frozen,omitempty"` // automatic calibration leaves the transform alone
+
+	Confidence   *calibrationConfidence   `json:"confidence,omitempty"`   // absent when the uncertainty is unknown
+	Alternatives []calibrationAlternative `json:"alternatives,omitempty"` // other rotations that aligned about as well
+}
+
+// calibrationAlternative is a rotation that aligned about as well as the
+// one in use, in the readable form of vacuumCalibrationSummary.
+type calibrationAlternative struct {
+	Rotation    float64    `json:"rotation"`    // degrees
+	Translation mesh.Point `json:"translation"` // millimeters
+	Score       float64    `json:"score"`
+}
+
+// calibrationConfidence is the uncertainty of a vacuum's transform: the
+// half-widths of its 95% confidence intervals, and the covariance they come
+// from.
+type calibrationConfidence struct {
+	Rotation    float64                  `json:"rotation"`    // ± degrees
+	Translation mesh.Point               `json:"translation"` // ± per axis, as translation
+	Covariance  mesh.TransformCovariance `json:"covariance"`  // rotation, translation X, Y
+}
+
+// summarizeCalibration lists the vacuums of cal sorted by ID, named as in
+// config.
+func summarizeCalibration(cal *mesh.CalibrationData, config *mesh.Config) calibrationSummary {
+	summary := calibrationSummary{ReferenceVacuum: cal.ReferenceVacuum, Vacuums: make([]vacuumCalibrationSummary, 0, len(cal.Vacuums))}
+	for _, id := range sortedKeys(cal.Vacuums) {
+		vc := cal.Vacuums[id]
+		entry := vacuumCalibrationSummary{
+			VacuumID:    id,
+			DisplayName: config.DisplayName(id),
+			Rotation:    mesh.TransformRotation(vc.Transform),
+			Translation: mesh.Point{X: vc.Transform.Tx, Y: vc.Transform.Ty},
+			ICPScore:    vc.ICPScore,
+			LastUpdated: vc.LastUpdated,
+			Frozen:      config.IsFrozen(id),
+		}
+		if vc.Covariance != nil {
+			rotation, translation := vc.Covariance.Interval95()
+			entry.Confidence = &calibrationConfidence{Rotation: rotation, Translation: translation, Covariance: *vc.Covariance}
+		}
+		for _, alt := range vc.Alternatives {
+			entry.Alternatives = append(entry.Alternatives, calibrationAlternative{
+				Rotation:    mesh.TransformRotation(alt.Transform),
+				Translation: mesh.Point{X: alt.Transform.Tx, Y: alt.Transform.Ty},
+				Score:       alt.Score,
+			})
+		}
+		summary.Vacuums = append(summary.Vacuums, entry)
+	}
+	return summary
+}
+
+// calibrationQueued lists the vacuums whose calibration was queued.
+type calibrationQueued struct {
+	Queued []string `json:"queued"`
+}
+
+// parsePointQuery reads the world point given by the x and y query
+// parameters.
+func parsePointQuery(q url.Values) (mesh.Point, error) {
+	var p mesh.Point
+	for _, c := range []struct {
+		name string
+		dst  *float64
+	}{{"x", &p.X}, {"y", &p.Y}} {
+		v := q.Get(c.name)
+		if v == "" {
+			return p, fmt.Errorf("%s is required", c.name)
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return p, fmt.Errorf("invalid %s %q: must be a number", c.name, v)
+		}
+		*c.dst = f
+	}
+	return p, nil
+}
+
+// parseUnifiedMapFilter parses the minConfidence, types and vacuum query
+// parameters of /unified-map.json.
+func parseUnifiedMapFilter(q url.Values) (mesh.UnifiedMapFilter, error) {
+	filter := mesh.UnifiedMapFilter{VacuumID: q.Get("vacuum")}
+	if v := q.Get("minConfidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return filter, fmt.Errorf("invalid minConfidence %q: must be between 0 and 1", v)
+		}
+		filter.MinConfidence = f
+	}
+	if v := q.Get("types"); v != "" {
+		types, err := mesh.ParseUnifiedFeatureTypes(v)
+		if err != nil {
+			return filter, fmt.Errorf("types: %w", err)
+		}
+		filter.Types = types
+	}
+	return filter, nil
+}
+
+// parseSince parses the since query parameter: an RFC 3339 time, a Unix
+// timestamp in seconds, or a duration before now. Empty means no limit.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: want an RFC 3339 time, Unix timestamp or duration", value)
+}
+
+// eventKeepAlive is how often an idle event stream sends a comment so
+// proxies do not close it.
+const eventKeepAlive = 30 * time.Second
+
+// writeMapEvent writes change as a server-sent map-updated event.
+func writeMapEvent(w http.ResponseWriter, change mesh.MapChange) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("Error encoding map change: %v", err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "event: map-updated\nid: %d\ndata: %s\n\n", change.Version, data)
+}
+
+// vacuumHealth returns the health of every configured vacuum and any other
+// that sent maps or messages, sorted by ID.
+func vacuumHealth(stateTracker *mesh.StateTracker, cache *mesh.CalibrationData, config *mesh.Config, refID string, now time.Time) []mesh.VacuumHealth {
+	maps := stateTracker.GetMaps()
+	ids := stateTracker.Health().VacuumIDs()
+	var healthConfig mesh.HealthConfig
+	if config != nil {
+		healthConfig = config.Health
+		for _, vc := range config.Vacuums {
+			ids = append(ids, vc.ID)
+		}
+	}
+	for id := range maps {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	reference := refID
+	if cache != nil && cache.ReferenceVacuum != "" {
+		reference = cache.ReferenceVacuum
+	} else if reference == "" {
+		reference = mesh.SelectReferenceVacuum(maps, nil)
+	}
+
+	positions := stateTracker.GetPositions()
+	health := make([]mesh.VacuumHealth, 0, len(ids))
+	for _, id := range ids {
+		var lastPosition time.Time
+		if pos := positions[id]; pos != nil {
+			lastPosition = pos.Timestamp
+		}
+		health = append(health, stateTracker.Health().Check(id, healthConfig, cache, reference, lastPosition, now))
+	}
+	return health
+}
+
 // buildTransforms creates transform map from cache or identity
 func buildTransforms(maps map[string]*mesh.ValetudoMap, cache *mesh.CalibrationData) map[string]mesh.AffineMatrix {
 	transforms := make(map[string]mesh.AffineMatrix)
@@ -279,11 +1207,96 @@ func buildTransforms(maps map[string]*mesh.ValetudoMap, cache *mesh.CalibrationD
 	return transforms
 }
 
-// applyConfigColors applies vacuum colors from config to the renderer
+// legendParams are the query parameters accepted by raster endpoints that
+// draw a legend.
+var legendParams = []endpointParam{
+	{Name: "legend", In: "query", Type: "boolean", Description: "Set to false to hide the legend"},
+	{Name: "legendPosition", In: "query", Type: "string", Description: "top-left, top-right, bottom-left or bottom-right"},
+	{Name: "legendScale", In: "query", Type: "integer", Description: "Integer font scale for high-resolution output"},
+}
+
+// legendOptions returns the legend settings from config, overridden by the
+// legend, legendPosition and legendScale query parameters.
+func legendOptions(config *mesh.Config, q url.Values) (mesh.LegendOptions, error) {
+	opts := mesh.LegendFromConfig(config)
+
+	if v := q.Get("legend"); v != "" {
+		show, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid legend value %q", v)
+		}
+		opts.Hidden = !show
+	}
+	if v := q.Get("legendPosition"); v != "" {
+		pos, err := mesh.ParseLegendPosition(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Position = pos
+	}
+	if v := q.Get("legendScale"); v != "" {
+		scale, err := strconv.Atoi(v)
+		if err != nil || scale < 1 || scale > mesh.MaxLegendScale {
+			return opts, fmt.Errorf("legendScale must be an integer between 1 and %d", mesh.MaxLegendScale)
+		}
+		opts.Scale = scale
+	}
+	return opts, nil
+}
+
+// overlayParams are the query parameters controlling the metric grid, scale
+// bar and handoff zones on raster endpoints.
+var overlayParams = []endpointParam{
+	{Name: "grid", In: "query", Type: "boolean", Description: "Draw a metric grid"},
+	{Name: "gridSpacing", In: "query", Type: "number", Description: "Grid line spacing in millimeters"},
+	{Name: "gridLabels", In: "query", Type: "boolean", Description: "Set to false to omit grid labels in meters"},
+	{Name: "scaleBar", In: "query", Type: "boolean", Description: "Draw a scale bar"},
+	{Name: "handoff", In: "query", Type: "boolean", Description: "Hatch areas covered by more than one vacuum"},
+}
+
+// rasterParams are the query parameters accepted by all raster map endpoints.
+var rasterParams = slices.Concat(legendParams, overlayParams, []endpointParam{formatParam})
+
+// overlayOptions returns the grid, scale bar and handoff settings from
+// config, overridden by the grid, gridSpacing, gridLabels, scaleBar and
+// handoff query parameters.
+func overlayOptions(config *mesh.Config, q url.Values) (mesh.OverlayOptions, error) {
+	opts := mesh.OverlayFromConfig(config)
+
+	for _, flag := range []struct {
+		name string
+		dst  *bool
+	}{
+		{"grid", &opts.Grid},
+		{"gridLabels", &opts.GridLabels},
+		{"scaleBar", &opts.ScaleBar},
+		{"handoff", &opts.Handoff},
+	} {
+		if v := q.Get(flag.name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s value %q", flag.name, v)
+			}
+			*flag.dst = b
+		}
+	}
+	if v := q.Get("gridSpacing"); v != "" {
+		spacing, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(spacing > 0) || math.IsInf(spacing, 0) {
+			return opts, fmt.Errorf("gridSpacing must be a positive number of millimeters")
+		}
+		opts.GridSpacing = spacing
+	}
+	return opts, nil
+}
+
+// applyConfigColors applies vacuum colors, opacity and z-order from config
+// to the renderer
 func applyConfigColors(renderer *mesh.CompositeRenderer, config *mesh.Config) {
 	if config == nil {
 		return
 	}
+	renderer.Layering = mesh.LayeringFromConfig(config)
 
 	for _, vc := range config.Vacuums {
 		if vc.Color == "" {
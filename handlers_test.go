@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"image"
 	"image/color"
+	"image/png"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kwv/tudomesh/mesh"
 )
@@ -231,6 +241,124 @@ func TestApplyConfigColors_UnknownVacuum(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// legendOptions
+// ---------------------------------------------------------------------------
+
+func TestLegendOptions_QueryOverridesConfig(t *testing.T) {
+	config := &mesh.Config{
+		Legend:  mesh.LegendConfig{Position: "top-right"},
+		Vacuums: []mesh.VacuumConfig{{ID: "vac1", DisplayName: "Upstairs"}},
+	}
+
+	opts, err := legendOptions(config, url.Values{})
+	if err != nil {
+		t.Fatalf("legendOptions: %v", err)
+	}
+	if opts.Position != mesh.LegendTopRight || opts.Hidden || opts.Label("vac1") != "Upstairs" {
+		t.Errorf("config defaults not applied: %+v", opts)
+	}
+
+	opts, err = legendOptions(config, url.Values{
+		"legend":         {"false"},
+		"legendPosition": {"bottom-left"},
+		"legendScale":    {"3"},
+	})
+	if err != nil {
+		t.Fatalf("legendOptions: %v", err)
+	}
+	if !opts.Hidden || opts.Position != mesh.LegendBottomLeft || opts.Scale != 3 {
+		t.Errorf("query overrides not applied: %+v", opts)
+	}
+}
+
+func TestLegendOptions_InvalidQuery(t *testing.T) {
+	for _, q := range []url.Values{
+		{"legend": {"maybe"}},
+		{"legendPosition": {"center"}},
+		{"legendScale": {"0"}},
+		{"legendScale": {"big"}},
+	} {
+		if _, err := legendOptions(nil, q); err == nil {
+			t.Errorf("legendOptions(%v) expected error", q)
+		}
+	}
+}
+
+func TestCompositeMapPNG_InvalidLegendParam(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
+	req := httptest.NewRequest(http.MethodGet, "/composite-map.png?legendPosition=center", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOverlayOptions_QueryOverridesConfig(t *testing.T) {
+	config := &mesh.Config{GridSpacing: 500, Overlay: mesh.OverlayConfig{ScaleBar: true}}
+
+	opts, err := overlayOptions(config, url.Values{})
+	if err != nil {
+		t.Fatalf("overlayOptions: %v", err)
+	}
+	if opts.Grid || !opts.ScaleBar || !opts.GridLabels || opts.GridSpacing != 500 {
+		t.Errorf("config defaults not applied: %+v", opts)
+	}
+
+	opts, err = overlayOptions(config, url.Values{
+		"grid":        {"true"},
+		"gridSpacing": {"250"},
+		"gridLabels":  {"false"},
+		"scaleBar":    {"0"},
+		"handoff":     {"1"},
+	})
+	if err != nil {
+		t.Fatalf("overlayOptions: %v", err)
+	}
+	if !opts.Grid || opts.ScaleBar || opts.GridLabels || opts.GridSpacing != 250 || !opts.Handoff {
+		t.Errorf("query overrides not applied: %+v", opts)
+	}
+}
+
+func TestOverlayOptions_InvalidQuery(t *testing.T) {
+	for _, q := range []url.Values{
+		{"grid": {"maybe"}},
+		{"scaleBar": {"yes please"}},
+		{"gridSpacing": {"0"}},
+		{"gridSpacing": {"-100"}},
+		{"gridSpacing": {"NaN"}},
+		{"gridSpacing": {"wide"}},
+	} {
+		if _, err := overlayOptions(nil, q); err == nil {
+			t.Errorf("overlayOptions(%v) expected error", q)
+		}
+	}
+}
+
+func TestCompositeMapPNG_WithOverlay(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
+	for _, path := range []string{
+		"/composite-map.png?grid=true&scaleBar=true&gridSpacing=50",
+		"/live.png?grid=true&scaleBar=true",
+		"/composite-map.png?gridSpacing=-1",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		want := http.StatusOK
+		if strings.Contains(path, "=-1") {
+			want = http.StatusBadRequest
+		}
+		if w.Code != want {
+			t.Errorf("%s status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // buildTransforms
 // ---------------------------------------------------------------------------
@@ -288,7 +416,7 @@ func TestBuildTransforms_EmptyMaps(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestHealth_NoMaps(t *testing.T) {
-	handler := newHTTPServer(emptyTracker(), nil, nil, "", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker()})
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
 
@@ -314,7 +442,7 @@ func TestHealth_NoMaps(t *testing.T) {
 }
 
 func TestHealth_WithMaps(t *testing.T) {
-	handler := newHTTPServer(populatedTracker(), nil, nil, "", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
 
@@ -335,19 +463,62 @@ func TestHealth_WithMaps(t *testing.T) {
 	}
 }
 
+func TestHealth_VacuumStatus(t *testing.T) {
+	st := populatedTracker()
+	st.UpdateMap("vac2", minimalMap())
+	st.Health().RecordMessage("vac1", time.Now())
+	st.Health().RecordParseError("vac2", errors.New("bad zTXt chunk"), time.Now())
+	config := &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}, {ID: "vac2"}, {ID: "vac3"}}}
+
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, Config: config, RefID: "vac1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body struct {
+		Status  string              `json:"status"`
+		Vacuums []mesh.VacuumHealth `json:"vacuums"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode /health response: %v", err)
+	}
+	if body.Status != "degraded" {
+		t.Errorf("status = %q, want degraded", body.Status)
+	}
+	want := map[string]string{
+		"vac1": mesh.HealthOK,          // the reference, recently heard from
+		"vac2": mesh.HealthParseErrors, // also uncalibrated
+		"vac3": mesh.HealthStale,       // configured but never heard from
+	}
+	if len(body.Vacuums) != len(want) {
+		t.Fatalf("got %d vacuums, want %d: %+v", len(body.Vacuums), len(want), body.Vacuums)
+	}
+	for _, v := range body.Vacuums {
+		if v.Status != want[v.VacuumID] {
+			t.Errorf("%s: status = %q, want %q", v.VacuumID, v.Status, want[v.VacuumID])
+		}
+	}
+	if v := body.Vacuums[1]; v.ParseErrors != 1 || v.LastError != "bad zTXt chunk" {
+		t.Errorf("vac2: parseErrors=%d lastError=%q, want 1 and the decoding error", v.ParseErrors, v.LastError)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // newHTTPServer -- PNG/SVG endpoints with no maps (503 paths)
 // ---------------------------------------------------------------------------
 
 func TestEndpoints_NoMaps_503(t *testing.T) {
-	handler := newHTTPServer(emptyTracker(), nil, nil, "", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker()})
 
 	endpoints := []string{
 		"/composite-map.png",
 		"/live.png",
 		"/composite-map.svg",
 		"/floorplan.svg",
+		"/floorplan.png",
 		"/live.svg",
+		"/handoff.json",
+		"/stats.json",
+		"/frontiers",
 	}
 
 	for _, ep := range endpoints {
@@ -368,7 +539,7 @@ func TestEndpoints_NoMaps_503(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestCompositeMapPNG_WithMaps(t *testing.T) {
-	handler := newHTTPServer(populatedTracker(), nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/composite-map.png", nil)
 	w := httptest.NewRecorder()
 
@@ -386,13 +557,19 @@ func TestCompositeMapPNG_WithMaps(t *testing.T) {
 	if w.Body.Len() == 0 {
 		t.Error("response body is empty; expected PNG data")
 	}
+	timing := w.Header().Get("Server-Timing")
+	for _, stage := range []string{"queue;dur=", "prepare;dur=", "draw;dur=", "encode;dur="} {
+		if !strings.Contains(timing, stage) {
+			t.Errorf("Server-Timing = %q, missing %s", timing, stage)
+		}
+	}
 }
 
 func TestLivePNG_WithMaps(t *testing.T) {
 	st := populatedTracker()
 	st.UpdatePosition("vac1", 15, 15, 90)
 
-	handler := newHTTPServer(st, nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/live.png", nil)
 	w := httptest.NewRecorder()
 
@@ -414,7 +591,7 @@ func TestLivePNG_WithMaps(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestCompositeMapSVG_WithMaps(t *testing.T) {
-	handler := newHTTPServer(populatedTracker(), nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/composite-map.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -435,7 +612,7 @@ func TestLiveSVG_WithMaps(t *testing.T) {
 	st := populatedTracker()
 	st.UpdatePosition("vac1", 15, 15, 90)
 
-	handler := newHTTPServer(st, nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/live.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -460,9 +637,51 @@ func TestLiveSVG_WithMaps(t *testing.T) {
 	}
 }
 
+func TestLiveSVG_RobotsGroup(t *testing.T) {
+	var pixels []int
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			pixels = append(pixels, x, y)
+		}
+	}
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{
+		PixelSize: 5,
+		MetaData:  mesh.MapMetaData{TotalLayerArea: 10000},
+		Layers:    []mesh.MapLayer{{Type: "floor", Pixels: pixels}},
+	})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+	get := func() string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/live.svg", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("/live.svg status = %d, body=%q", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	st.UpdatePosition("vac1", 50, 50, 0)
+	first := get()
+	if !strings.Contains(first, `<g id="robots"><path`) {
+		t.Errorf("robot not drawn in the robots group: %.300s", first)
+	}
+	st.UpdatePosition("vac1", 60, 50, 90)
+	second := get()
+	floorPlan, _, _ := strings.Cut(first, `<g id="chargers">`)
+	if !strings.HasPrefix(second, floorPlan) || second == first {
+		t.Error("the second request should reuse the floor plan and move the robot")
+	}
+
+	// A robot off the floor plan gets a full render with a wider viewport
+	st.UpdatePosition("vac1", 500, 50, 0)
+	if off := get(); strings.Contains(off, `<g id="robots">`) {
+		t.Error("robot off the map drawn into the cached floor plan")
+	}
+}
+
 func TestLiveSVG_NoPositions(t *testing.T) {
 	// With maps but no positions -- should still render the base map
-	handler := newHTTPServer(populatedTracker(), nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/live.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -476,8 +695,74 @@ func TestLiveSVG_NoPositions(t *testing.T) {
 	}
 }
 
+func TestFloorplanPNG(t *testing.T) {
+	st := populatedTracker()
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+	get := func() image.Image {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/floorplan.png", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("/floorplan.png status = %d, body=%q", w.Code, w.Body.String())
+		}
+		img, err := png.Decode(w.Body)
+		if err != nil {
+			t.Fatalf("decoding PNG: %v", err)
+		}
+		return img
+	}
+
+	// Before unification, the vacuums' own maps are drawn
+	before := get().Bounds()
+
+	// A 4m x 2m unified room at 25mm per pixel with the default padding
+	st.SetUnifiedMap(&mesh.UnifiedMap{
+		Floors: []*mesh.UnifiedFeature{{Geometry: mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 2000}, {X: 0, Y: 2000}})}},
+	})
+	if b := get().Bounds(); b.Dx() != 220 || b.Dy() != 140 || b == before {
+		t.Errorf("unified floor plan is %dx%d, want 220x140", b.Dx(), b.Dy())
+	}
+}
+
+func TestHeatmapPNG(t *testing.T) {
+	st := populatedTracker()
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/heatmap.png"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a unified map status = %d, want 503", w.Code)
+	}
+
+	st.SetUnifiedMap(&mesh.UnifiedMap{
+		Floors: []*mesh.UnifiedFeature{{Geometry: mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 2000}, {X: 0, Y: 2000}})}},
+	})
+	st.UpdatePosition("vac1", 200, 200, 0) // world (1000, 1000) at the default pixel size
+
+	w := get("/heatmap.png?days=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	// The visited cell, 1m in from the plan's corner at 25mm per pixel
+	if r, g, b, _ := img.At(30+42, 30+42).RGBA(); r>>8 < 200 || g>>8 > 100 || b>>8 > 100 {
+		t.Errorf("visited cell = (%d, %d, %d), want red", r>>8, g>>8, b>>8)
+	}
+
+	for _, days := range []string{"0", "91", "x"} {
+		if w := get("/heatmap.png?days=" + days); w.Code != http.StatusBadRequest {
+			t.Errorf("days=%s status = %d, want 400", days, w.Code)
+		}
+	}
+}
+
 func TestFloorplanSVG_WithMaps(t *testing.T) {
-	handler := newHTTPServer(populatedTracker(), nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/floorplan.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -502,7 +787,7 @@ func TestCompositeMapSVG_WithGridSpacing(t *testing.T) {
 	cfg := &mesh.Config{
 		GridSpacing: 500,
 	}
-	handler := newHTTPServer(populatedTracker(), nil, cfg, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), Config: cfg, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/composite-map.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -520,7 +805,7 @@ func TestLiveSVG_WithGridSpacing(t *testing.T) {
 	cfg := &mesh.Config{
 		GridSpacing: 600,
 	}
-	handler := newHTTPServer(st, nil, cfg, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, Config: cfg, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/live.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -535,7 +820,7 @@ func TestFloorplanSVG_WithGridSpacing(t *testing.T) {
 	cfg := &mesh.Config{
 		GridSpacing: 800,
 	}
-	handler := newHTTPServer(populatedTracker(), nil, cfg, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), Config: cfg, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/floorplan.svg", nil)
 	w := httptest.NewRecorder()
 
@@ -546,6 +831,40 @@ func TestFloorplanSVG_WithGridSpacing(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// newHTTPServer -- render profiles
+// ---------------------------------------------------------------------------
+
+func TestProfileCompositeMap(t *testing.T) {
+	st := populatedTracker()
+	st.UpdateMap("vac2", minimalMap())
+	cfg := &mesh.Config{
+		Vacuums: []mesh.VacuumConfig{{ID: "vac1"}, {ID: "vac2"}},
+		Profiles: []mesh.ProfileConfig{
+			{Name: "upstairs", Vacuums: []string{"vac2"}},
+			{Name: "offline", Vacuums: []string{"vac3"}},
+		},
+	}
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, Config: cfg, RefID: "vac1"})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/profiles/upstairs/composite-map.png", http.StatusOK},
+		{"/profiles/UPSTAIRS/composite-map.png", http.StatusOK},
+		{"/profiles/offline/composite-map.png", http.StatusServiceUnavailable},
+		{"/profiles/missing/composite-map.png", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s status = %d, want %d, body=%q", tt.path, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // newHTTPServer -- reference selection fallback (empty refID)
 // ---------------------------------------------------------------------------
@@ -553,7 +872,7 @@ func TestFloorplanSVG_WithGridSpacing(t *testing.T) {
 func TestEndpoints_EmptyRefID_AutoSelects(t *testing.T) {
 	// refID="" forces SelectReferenceVacuum to pick by area; with one map
 	// it picks "vac1" automatically.
-	handler := newHTTPServer(populatedTracker(), nil, nil, "", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
 
 	endpoints := []string{
 		"/composite-map.png",
@@ -586,7 +905,7 @@ func TestEndpoints_WithCache(t *testing.T) {
 			"vac1": {Transform: mesh.Identity()},
 		},
 	}
-	handler := newHTTPServer(populatedTracker(), cache, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), Calibration: fixedCalibration(cache), RefID: "vac1"})
 
 	endpoints := []string{
 		"/composite-map.png",
@@ -617,7 +936,7 @@ func TestCompositeMapPNG_WithConfigColors(t *testing.T) {
 			{ID: "vac1", Color: "#3366CC"},
 		},
 	}
-	handler := newHTTPServer(populatedTracker(), nil, cfg, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), Config: cfg, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/composite-map.png", nil)
 	w := httptest.NewRecorder()
 
@@ -648,7 +967,7 @@ func TestLivePNG_NoDrawableContent_WithPositions(t *testing.T) {
 	})
 	st.UpdatePosition("vac1", 10, 10, 0)
 
-	handler := newHTTPServer(st, nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/live.png", nil)
 	w := httptest.NewRecorder()
 
@@ -674,7 +993,7 @@ func TestCompositeMapPNG_NoDrawableContent_503(t *testing.T) {
 		},
 	})
 
-	handler := newHTTPServer(st, nil, nil, "vac1", 0)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
 	req := httptest.NewRequest(http.MethodGet, "/composite-map.png", nil)
 	w := httptest.NewRecorder()
 
@@ -690,7 +1009,7 @@ func TestCompositeMapPNG_NoDrawableContent_503(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestEndpoints_WithGlobalRotation(t *testing.T) {
-	handler := newHTTPServer(populatedTracker(), nil, nil, "vac1", 90)
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1", Rotation: fixedRotation(90)})
 
 	endpoints := []string{"/composite-map.png", "/live.png", "/live.svg"}
 	for _, ep := range endpoints {
@@ -704,3 +1023,932 @@ func TestEndpoints_WithGlobalRotation(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// /room/{segment}.png
+// ---------------------------------------------------------------------------
+
+func TestRoomPNG(t *testing.T) {
+	m := minimalMap()
+	m.PixelSize = 5
+	m.Layers = append(m.Layers, mesh.MapLayer{
+		Type:     "segment",
+		MetaData: mesh.LayerMetaData{SegmentID: "3", Name: "Kitchen"},
+		Pixels:   []int{10, 10, 29, 10},
+	})
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", m)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/room/Kitchen.png", http.StatusOK},
+		{"/room/kitchen.png", http.StatusOK},
+		{"/room/3.png", http.StatusOK},
+		{"/room/Garage.png", http.StatusNotFound},
+		{"/room/Kitchen", http.StatusNotFound},
+		{"/room/Kitchen.png?legendScale=0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("%s status = %d, want %d, body=%q", tt.path, w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			img, err := png.Decode(w.Body)
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			// 20 cells of segment plus a 250mm margin each side at 5mm/cell
+			if got, want := img.Bounds().Dx(), 20+2*50; got != want {
+				t.Errorf("width = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestRoomPNG_NoMaps_503(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), RefID: "vac1"})
+	req := httptest.NewRequest(http.MethodGet, "/room/Kitchen.png", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /handoff.json
+// ---------------------------------------------------------------------------
+
+func TestHandoffJSON(t *testing.T) {
+	square := func(x0 int) []int {
+		var pixels []int
+		for y := 0; y < 20; y++ {
+			for x := x0; x < x0+20; x++ {
+				pixels = append(pixels, x, y)
+			}
+		}
+		return pixels
+	}
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{PixelSize: 5, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(0)}}})
+	st.UpdateMap("vac2", &mesh.ValetudoMap{PixelSize: 5, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(10)}}})
+	config := &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac2", DisplayName: "Upstairs"}}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, Config: config, RefID: "vac1"})
+
+	req := httptest.NewRequest(http.MethodGet, "/handoff.json", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type string `json:"type"`
+			} `json:"geometry"`
+			Properties struct {
+				Vacuums      []string `json:"vacuums"`
+				DisplayNames []string `json:"displayNames"`
+				AreaM2       float64  `json:"areaM2"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(fc.Features) != 1 {
+		t.Fatalf("got %d features, want 1", len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "MultiPolygon" {
+		t.Errorf("geometry = %s, want MultiPolygon", f.Geometry.Type)
+	}
+	if !slices.Equal(f.Properties.Vacuums, []string{"vac1", "vac2"}) || !slices.Equal(f.Properties.DisplayNames, []string{"vac1", "Upstairs"}) {
+		t.Errorf("properties = %+v", f.Properties)
+	}
+	// 10x20 shared cells at 5mm
+	if f.Properties.AreaM2 != 0.01 {
+		t.Errorf("areaM2 = %v, want 0.01", f.Properties.AreaM2)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /stats.json
+// ---------------------------------------------------------------------------
+
+func TestStatsJSON(t *testing.T) {
+	square := func(x0 int) []int {
+		var pixels []int
+		for y := 0; y < 200; y++ {
+			for x := x0; x < x0+200; x++ {
+				pixels = append(pixels, x, y)
+			}
+		}
+		return pixels
+	}
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{PixelSize: 5, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(0)}}})
+	st.UpdateMap("vac2", &mesh.ValetudoMap{PixelSize: 5, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(100)}}})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	req := httptest.NewRequest(http.MethodGet, "/stats.json", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+
+	var stats struct {
+		ReferenceVacuum string                 `json:"referenceVacuum"`
+		VacuumCount     int                    `json:"vacuumCount"`
+		TotalArea       float64                `json:"totalArea"`
+		CoverageOverlap float64                `json:"coverageOverlap"`
+		VacuumAreas     map[string]float64     `json:"vacuumAreas"`
+		Overlaps        []mesh.CoverageOverlap `json:"overlaps"`
+		UnifiedMap      *mesh.UnifiedMetadata  `json:"unifiedMap"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if stats.ReferenceVacuum != "vac1" || stats.VacuumCount != 2 {
+		t.Errorf("reference/count = %s/%d, want vac1/2", stats.ReferenceVacuum, stats.VacuumCount)
+	}
+	// Two 1 m² squares sharing half of each
+	if stats.TotalArea != 1.5 || stats.CoverageOverlap != 0.333 {
+		t.Errorf("totalArea/coverageOverlap = %v/%v, want 1.5/0.333", stats.TotalArea, stats.CoverageOverlap)
+	}
+	if stats.VacuumAreas["vac2"] != 1 {
+		t.Errorf("vacuumAreas = %v", stats.VacuumAreas)
+	}
+	if len(stats.Overlaps) != 1 || stats.Overlaps[0].AreaM2 != 0.5 || stats.Overlaps[0].Fraction != 0.5 {
+		t.Errorf("overlaps = %+v", stats.Overlaps)
+	}
+	if stats.UnifiedMap != nil {
+		t.Errorf("unifiedMap = %+v, want omitted before unification", stats.UnifiedMap)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /segment
+// ---------------------------------------------------------------------------
+
+func TestSegmentAt(t *testing.T) {
+	kitchen := &mesh.UnifiedFeature{
+		Geometry:   mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 3000, Y: 0}, {X: 3000, Y: 2000}, {X: 0, Y: 2000}}),
+		Properties: map[string]interface{}{"segmentName": "Kitchen"},
+		Sources:    []mesh.FeatureSource{{VacuumID: "vac1"}},
+		Confidence: 0.9,
+	}
+	st := mesh.NewStateTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{Segments: []*mesh.UnifiedFeature{kitchen}})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st})
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"x=1500&y=1000", http.StatusOK},
+		{"x=5000&y=1000", http.StatusNotFound},
+		{"x=1500", http.StatusBadRequest},
+		{"x=abc&y=1", http.StatusBadRequest},
+		{"x=NaN&y=1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/segment?"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("/segment?%s status = %d, want %d (body %q)", tt.query, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/segment?x=1500&y=1000", nil))
+	var seg mesh.SegmentSummary
+	if err := json.NewDecoder(w.Body).Decode(&seg); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if seg.Name != "Kitchen" || seg.Area != 6 || seg.Confidence != 0.9 || len(seg.Vacuums) != 1 {
+		t.Errorf("segment = %+v, want the 6 m² Kitchen seen by vac1", seg)
+	}
+}
+
+func TestUnifiedMapJSON(t *testing.T) {
+	feature := func(confidence float64, vacuum string) *mesh.UnifiedFeature {
+		return &mesh.UnifiedFeature{
+			Geometry:   mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 1000, Y: 0}, {X: 1000, Y: 1000}}),
+			Sources:    []mesh.FeatureSource{{VacuumID: vacuum, ICPScore: 0.8}},
+			Confidence: confidence,
+		}
+	}
+	st := mesh.NewStateTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{
+		Walls:    []*mesh.UnifiedFeature{feature(0.9, "lion"), feature(0.3, "lion")},
+		Segments: []*mesh.UnifiedFeature{feature(0.9, "tiger")},
+	})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st})
+
+	tests := []struct {
+		query           string
+		want            int
+		walls, segments int
+	}{
+		{"", http.StatusOK, 2, 1},
+		{"minConfidence=0.6", http.StatusOK, 1, 1},
+		{"types=walls&vacuum=lion", http.StatusOK, 2, 0},
+		{"vacuum=tiger", http.StatusOK, 0, 1},
+		{"minConfidence=2", http.StatusBadRequest, 0, 0},
+		{"types=doors", http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unified-map.json?"+tt.query, nil))
+		if w.Code != tt.want {
+			t.Errorf("?%s status = %d, want %d (body %q)", tt.query, w.Code, tt.want, w.Body.String())
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var um mesh.UnifiedMap
+		if err := json.NewDecoder(w.Body).Decode(&um); err != nil {
+			t.Fatalf("?%s decoding: %v", tt.query, err)
+		}
+		if len(um.Walls) != tt.walls || len(um.Segments) != tt.segments {
+			t.Errorf("?%s got %d walls, %d segments; want %d, %d", tt.query, len(um.Walls), len(um.Segments), tt.walls, tt.segments)
+		}
+		for _, uf := range um.Walls {
+			if len(uf.Sources) != 1 || uf.Sources[0].ICPScore != 0.8 {
+				t.Errorf("?%s sources = %+v, want the ICP score kept", tt.query, uf.Sources)
+			}
+		}
+	}
+}
+
+func TestUnifiedMapJSON_NoUnifiedMap(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unified-map.json", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestFloorplanModel(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{
+		Walls:  []*mesh.UnifiedFeature{{Geometry: mesh.PathToLineString(mesh.Path{{X: 0, Y: 0}, {X: 2000, Y: 0}})}},
+		Floors: []*mesh.UnifiedFeature{{Geometry: mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 2000, Y: 0}, {X: 2000, Y: 2000}, {X: 0, Y: 2000}})}},
+	})
+	config := &mesh.Config{Model3D: mesh.Model3DConfig{WallHeight: 2000}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, Config: config})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/floorplan.obj", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "model/obj" {
+		t.Fatalf("/floorplan.obj: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "o walls\n") || !strings.Contains(w.Body.String(), " 2.0000 ") {
+		t.Error("OBJ lacks the walls at the configured 2m height")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/floorplan.gltf?wallHeight=3000", nil))
+	var doc struct {
+		Accessors []struct {
+			Max []float64 `json:"max"`
+		} `json:"accessors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("/floorplan.gltf: %v", err)
+	}
+	if top := doc.Accessors[len(doc.Accessors)-2].Max[1]; top != 3 {
+		t.Errorf("glTF walls reach %.2f m, want the requested 3", top)
+	}
+
+	for _, path := range []string{"/floorplan.gltf?wallHeight=-1", "/floorplan.obj?wallHeight=tall"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	empty := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
+	w = httptest.NewRecorder()
+	empty.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/floorplan.gltf", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a unified map: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestOccupancyMap(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{
+		Walls:  []*mesh.UnifiedFeature{{Geometry: mesh.PathToLineString(mesh.Path{{X: 0, Y: 0}, {X: 2000, Y: 0}})}},
+		Floors: []*mesh.UnifiedFeature{{Geometry: mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 2000, Y: 0}, {X: 2000, Y: 2000}, {X: 0, Y: 2000}})}},
+	})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/map.pgm?resolution=0.1", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/x-portable-graymap" {
+		t.Fatalf("/map.pgm: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "\n23 23\n255\n") {
+		t.Errorf("PGM is not the 23x23 grid of a 2m room at 10cm: %q", w.Body.String()[:40])
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/map.yaml", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "image: map.pgm\nresolution: 0.05\n") {
+		t.Errorf("/map.yaml: status %d, body %q", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/map.pgm?resolution=0", "/map.yaml?resolution=fine"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	empty := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
+	w = httptest.NewRecorder()
+	empty.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/map.pgm", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a unified map: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestPushMap(t *testing.T) {
+	config := &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}}}
+	var pushed *mesh.ValetudoMap
+	push := func(id string, m *mesh.ValetudoMap) (bool, error) {
+		if m.PixelSize <= 0 {
+			return false, mesh.ErrZeroPixelSize
+		}
+		pushed = m
+		return true, nil
+	}
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Config: config, Push: push})
+
+	body := `{"__class":"ValetudoMap","pixelSize":5,"layers":[{"type":"floor","pixels":[10,10]}],"entities":[]}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/maps/vac1", strings.NewReader(body)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"changed":true`) {
+		t.Fatalf("push: status %d, body %q", w.Code, w.Body.String())
+	}
+	if pushed == nil || pushed.PixelSize != 5 {
+		t.Errorf("pushed map = %+v, want the decoded body", pushed)
+	}
+
+	tests := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"unknown vacuum", "/maps/vac9", body, http.StatusNotFound},
+		{"not a map", "/maps/vac1", "hello", http.StatusBadRequest},
+		{"rejected", "/maps/vac1", `{"__class":"ValetudoMap","pixelSize":0,"layers":[],"entities":[]}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	offline := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Config: config})
+	w = httptest.NewRecorder()
+	offline.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/maps/vac1", strings.NewReader(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without the service: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSegmentAt_NoUnifiedMap(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker()})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/segment?x=0&y=0", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /zones
+// ---------------------------------------------------------------------------
+
+// zonesServer returns a server with one 100x100-cell vacuum, a configured
+// "Kitchen" zone inside it and the given command publisher.
+func zonesServer(commands *mesh.CommandPublisher) (http.Handler, *mesh.Config) {
+	var pixels []int
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			pixels = append(pixels, x, y)
+		}
+	}
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{PixelSize: 5, Layers: []mesh.MapLayer{{Type: "floor", Pixels: pixels}}})
+	config := &mesh.Config{
+		Vacuums: []mesh.VacuumConfig{{ID: "vac1", Topic: "valetudo/vac1/MapData/map-data"}},
+		Zones:   []mesh.ZoneConfig{{Name: "Kitchen", Points: []mesh.Point{{X: 50, Y: 50}, {X: 250, Y: 150}}}},
+	}
+	return newHTTPServer(httpServerOptions{StateTracker: st, Config: config, RefID: "vac1", Commands: commands}), config
+}
+
+func TestZones_CRUD(t *testing.T) {
+	handler, _ := zonesServer(nil)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/zones/Hall", `{"points": [{"x": 0, "y": 0}, {"x": 100, "y": 100}], "iterations": 2}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body=%q", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/zones/Bad", `{"points": [{"x": 0, "y": 0}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT invalid zone status = %d, want 400", w.Code)
+	}
+	if w := do(http.MethodPut, "/zones/Bad", `{`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT malformed JSON status = %d, want 400", w.Code)
+	}
+
+	w = do(http.MethodGet, "/zones", "")
+	var list []mesh.ZoneConfig
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "Hall" || list[0].Iterations != 2 || list[1].Name != "Kitchen" {
+		t.Errorf("GET /zones = %+v, want Hall and Kitchen", list)
+	}
+
+	if w := do(http.MethodDelete, "/zones/hall", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", w.Code)
+	}
+	if w := do(http.MethodDelete, "/zones/hall", ""); w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE status = %d, want 404", w.Code)
+	}
+}
+
+func TestZones_Clean(t *testing.T) {
+	post := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	handler, _ := zonesServer(nil)
+	w := post(handler, "/zones/kitchen/clean?dryRun=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("dry run status = %d, body=%q", w.Code, w.Body.String())
+	}
+	var plans []mesh.ZoneCleanPlan
+	if err := json.NewDecoder(w.Body).Decode(&plans); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(plans) != 1 || plans[0].VacuumID != "vac1" || len(plans[0].Zones) != 1 {
+		t.Fatalf("plans = %+v", plans)
+	}
+	if got := plans[0].Zones[0].Points.PC; got != (mesh.ValetudoPoint{X: 250, Y: 150}) {
+		t.Errorf("pC = %+v, want (250, 150)", got)
+	}
+
+	if w := post(handler, "/zones/kitchen/clean"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without MQTT status = %d, want 503", w.Code)
+	}
+	if w := post(handler, "/zones/nowhere/clean?dryRun=true"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown zone status = %d, want 404", w.Code)
+	}
+	if w := post(handler, "/zones/kitchen/clean?dryRun=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid dryRun status = %d, want 400", w.Code)
+	}
+
+	// A zone outside the mapped area cannot be cleaned
+	req := httptest.NewRequest(http.MethodPut, "/zones/garden", strings.NewReader(`{"points": [{"x": 5000, "y": 5000}, {"x": 6000, "y": 6000}]}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if w := post(handler, "/zones/garden/clean?dryRun=true"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unmapped zone status = %d, want 422", w.Code)
+	}
+
+	client := mesh.NewMockClient()
+	_, config := zonesServer(nil)
+	handler, _ = zonesServer(mesh.NewCommandPublisher(client, config))
+	if w := post(handler, "/zones/kitchen/clean"); w.Code != http.StatusOK {
+		t.Fatalf("clean status = %d, body=%q", w.Code, w.Body.String())
+	}
+	client.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+// ---------------------------------------------------------------------------
+// /events
+// ---------------------------------------------------------------------------
+
+func TestEvents_StreamsMapVersions(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{Metadata: mesh.UnifiedMetadata{Version: 3, LastUpdated: 100}})
+	server := httptest.NewServer(newHTTPServer(httpServerOptions{StateTracker: st}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	nextEvent := func() mesh.MapChange {
+		t.Helper()
+		var change mesh.MapChange
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading event: %v", err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				if err := json.Unmarshal([]byte(data), &change); err != nil {
+					t.Fatalf("invalid event data %q: %v", data, err)
+				}
+				return change
+			}
+		}
+	}
+
+	// The current version on connect, then each new one
+	if got := nextEvent(); got.Version != 3 || got.Timestamp != 100 {
+		t.Errorf("initial event = %+v, want version 3", got)
+	}
+	st.SetUnifiedMap(&mesh.UnifiedMap{Metadata: mesh.UnifiedMetadata{Version: 4}})
+	if got := nextEvent(); got.Version != 4 || got.PreviousVersion != 3 {
+		t.Errorf("update event = %+v, want version 3 -> 4", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tracks
+// ---------------------------------------------------------------------------
+
+func TestTracksGeoJSON(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{PixelSize: 5})
+	st.UpdatePosition("vac1", 10, 20, 0)
+	st.UpdatePosition("vac1", 30, 20, 90)
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	req := httptest.NewRequest(http.MethodGet, "/tracks.geojson?since=1h", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string       `json:"type"`
+				Coordinates [][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				VacuumID   string   `json:"vacuumId"`
+				CoordTimes []string `json:"coordTimes"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&fc); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(fc.Features) != 1 {
+		t.Fatalf("features = %d, want 1", len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "LineString" || f.Properties.VacuumID != "vac1" {
+		t.Errorf("feature = %s/%s, want LineString/vac1", f.Geometry.Type, f.Properties.VacuumID)
+	}
+	want := [][2]float64{{50, 100}, {150, 100}}
+	if len(f.Geometry.Coordinates) != 2 || f.Geometry.Coordinates[0] != want[0] || f.Geometry.Coordinates[1] != want[1] {
+		t.Errorf("coordinates = %v, want %v", f.Geometry.Coordinates, want)
+	}
+	if len(f.Properties.CoordTimes) != 2 {
+		t.Errorf("coordTimes = %v, want 2 entries", f.Properties.CoordTimes)
+	}
+
+	// A since after the last position leaves nothing to draw
+	req = httptest.NewRequest(http.MethodGet, "/tracks.geojson?since="+time.Now().Add(time.Hour).Format(time.RFC3339), nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "LineString") {
+		t.Errorf("future since: status %d, body %q", w.Code, w.Body.String())
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), false},
+		{"1714557600", time.Unix(1714557600, 0), false},
+		{"30m", now.Add(-30 * time.Minute), false},
+		{"-5m", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTracksGeoJSON_InvalidSince(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: mesh.NewStateTracker()})
+	req := httptest.NewRequest(http.MethodGet, "/tracks.geojson?since=yesterday", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Calibration
+// ---------------------------------------------------------------------------
+
+func TestCalibrate_NoCalibrator(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: mesh.NewStateTracker(), Config: &mesh.Config{}})
+	req := httptest.NewRequest(http.MethodPost, "/calibrate", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestCalibrate_QueuesOnLeader(t *testing.T) {
+	dir := t.TempDir()
+	config := &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac1"}, {ID: "vac2"}}}
+	st := mesh.NewStateTracker()
+	calibrator := mesh.NewAutoCalibrator(config, nil, dir+"/cache.json", dir, st)
+	work := mesh.NewWorkQueue()
+	leader := true
+	handler := newHTTPServer(httpServerOptions{
+		StateTracker: st, Calibration: calibrator.GetCache, Config: config, RefID: "vac1",
+		Calibrator: calibrator, Work: work, IsLeader: func() bool { return leader },
+	})
+
+	// The request only queues the calibrations; nothing runs the queue here
+	req := httptest.NewRequest(http.MethodPost, "/calibrate", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+	var queued calibrationQueued
+	if err := json.NewDecoder(w.Body).Decode(&queued); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !slices.Equal(queued.Queued, []string{"vac1", "vac2"}) {
+		t.Errorf("queued = %v, want vac1 and vac2", queued.Queued)
+	}
+	if work.Len() != 2 {
+		t.Errorf("work queue holds %d jobs, want 2", work.Len())
+	}
+
+	// A second request for a vacuum still waiting replaces its job
+	req = httptest.NewRequest(http.MethodPost, "/calibrate?vacuum=vac2", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted || work.Len() != 2 {
+		t.Errorf("requeue: status = %d, %d jobs, want 202 and 2", w.Code, work.Len())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/calibrate?vacuum=vac9", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown vacuum: status = %d, want 404", w.Code)
+	}
+
+	leader = false
+	req = httptest.NewRequest(http.MethodPost, "/calibrate", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || work.Len() != 2 {
+		t.Errorf("standby: status = %d, %d jobs, want 503 and nothing queued", w.Code, work.Len())
+	}
+}
+
+func TestFrontiers(t *testing.T) {
+	// A unified room walled on every side but the east
+	room := &mesh.UnifiedFeature{
+		Geometry: mesh.PathToPolygon(mesh.Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 3000}, {X: 0, Y: 3000}}),
+	}
+	wall := &mesh.UnifiedFeature{
+		Geometry: mesh.PathToLineString(mesh.Path{{X: 4000, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 3000}, {X: 4000, Y: 3000}}),
+	}
+	st := populatedTracker()
+	st.SetUnifiedMap(&mesh.UnifiedMap{Segments: []*mesh.UnifiedFeature{room}, Walls: []*mesh.UnifiedFeature{wall}})
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/frontiers", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %q)", w.Code, w.Body.String())
+	}
+	var report mesh.FrontierReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(report.Unified) != 1 || report.Unified[0].Midpoint.X != 4000 {
+		t.Errorf("unified frontiers = %+v, want one along the east side", report.Unified)
+	}
+	if _, ok := report.Vacuums["vac1"]; !ok {
+		t.Errorf("vacuums = %v, want an entry for vac1", report.Vacuums)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/frontiers?vacuum=nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown vacuum status = %d, want 404", w.Code)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// /compare-rotation/{vacuum}.png
+// ---------------------------------------------------------------------------
+
+func TestCompareRotationPNG(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", minimalMap())
+	st.UpdateMap("vac2", minimalMap())
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/compare-rotation/vac2.png", http.StatusOK},
+		{"/compare-rotation/vac2.png?angles=0,37.5,90", http.StatusOK},
+		{"/compare-rotation/vac1.png", http.StatusBadRequest},
+		{"/compare-rotation/vac2.png?angles=left", http.StatusBadRequest},
+		{"/compare-rotation/vac2.png?format=avif", http.StatusBadRequest},
+		{"/compare-rotation/vac3.png", http.StatusNotFound},
+		{"/compare-rotation/vac2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("%s status = %d, want %d, body=%q", tt.path, w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if _, err := png.Decode(w.Body); err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+		})
+	}
+}
+
+func TestCompareRotationPNG_NoMaps_503(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), RefID: "vac1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/compare-rotation/vac2.png", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestRotationAnalysisJSON(t *testing.T) {
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", minimalMap())
+	st.UpdateMap("vac2", minimalMap())
+	handler := newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rotation-analysis.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+	var report mesh.RotationReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if report.ReferenceVacuum != "vac1" {
+		t.Errorf("referenceVacuum = %q, want vac1", report.ReferenceVacuum)
+	}
+	if len(report.Vacuums) != 1 || report.Vacuums[0].VacuumID != "vac2" || len(report.Vacuums[0].Scores) != 4 {
+		t.Errorf("vacuums = %+v, want vac2 with four rotation scores", report.Vacuums)
+	}
+}
+
+func TestRotationAnalysisJSON_NoMaps_503(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), RefID: "vac1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rotation-analysis.json", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), RefID: "vac1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	// The page only calls endpoints the server has
+	for _, path := range []string{"/health", "/calibration.json", "/stats.json", "/calibrate", "/events", "/live.png", "/composite-map.png", "/floorplan.png"} {
+		if !strings.Contains(w.Body.String(), `"`+path) {
+			t.Errorf("dashboard does not use %s", path)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/no-such-page", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown path status = %d, want 404", w.Code)
+	}
+}
+
+func TestCalibrationJSON(t *testing.T) {
+	cache := &mesh.CalibrationData{
+		ReferenceVacuum: "vac1",
+		Vacuums: map[string]mesh.VacuumCalibration{
+			"vac2": {Transform: mesh.CreateRotationTranslation(90, 100, -50), ICPScore: 0.8, LastUpdated: 1700000000,
+				Covariance:   &mesh.TransformCovariance{{0.25, 0, 0}, {0, 4, 0}, {0, 0, 1}},
+				Alternatives: []mesh.RotationHypothesis{{Transform: mesh.CreateRotationTranslation(270, 300, 20), Rotation: 270, Score: 0.78}}},
+			"vac1": {Transform: mesh.Identity()},
+		},
+	}
+	config := &mesh.Config{Vacuums: []mesh.VacuumConfig{{ID: "vac2", DisplayName: "Upstairs", Frozen: true}}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Calibration: fixedCalibration(cache), Config: config, RefID: "vac1"})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calibration.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", w.Code, w.Body.String())
+	}
+	var summary calibrationSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if summary.ReferenceVacuum != "vac1" || len(summary.Vacuums) != 2 || summary.Vacuums[0].VacuumID != "vac1" {
+		t.Fatalf("summary = %+v, want vac1 then vac2", summary)
+	}
+	v := summary.Vacuums[1]
+	if v.DisplayName != "Upstairs" || math.Abs(v.Rotation-90) > 1e-9 || v.Translation != (mesh.Point{X: 100, Y: -50}) || v.ICPScore != 0.8 || v.LastUpdated != 1700000000 || !v.Frozen {
+		t.Errorf("vac2 = %+v", v)
+	}
+	if c := v.Confidence; c == nil || math.Abs(c.Rotation-0.98) > 1e-9 || math.Abs(c.Translation.X-3.92) > 1e-9 || math.Abs(c.Translation.Y-1.96) > 1e-9 {
+		t.Errorf("vac2 confidence = %+v, want ±0.98°, ±(3.92, 1.96)", c)
+	}
+	if len(v.Alternatives) != 1 || math.Abs(v.Alternatives[0].Rotation-270) > 1e-9 || v.Alternatives[0].Translation != (mesh.Point{X: 300, Y: 20}) || v.Alternatives[0].Score != 0.78 {
+		t.Errorf("vac2 alternatives = %+v", v.Alternatives)
+	}
+	if summary.Vacuums[0].Confidence != nil {
+		t.Errorf("vac1 confidence = %+v, want none without a covariance", summary.Vacuums[0].Confidence)
+	}
+}
+
+func TestCalibrationJSON_NoCalibration_503(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), RefID: "vac1"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calibration.json", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
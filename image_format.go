@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Raster encodings selectable with ?format= or the Accept header. WebP is
+// lossless, so the flat colors of the maps compress far better than as PNG.
+// There is no pure Go AVIF encoder, so AVIF is not offered.
+const (
+	formatPNG  = "png"
+	formatWebP = "webp"
+)
+
+// formatParam is the query parameter selecting the encoding of raster
+// endpoints.
+var formatParam = endpointParam{
+	Name:        "format",
+	In:          "query",
+	Type:        "string",
+	Description: "png or webp (lossless); without it, WebP is sent when the Accept header lists image/webp, PNG otherwise",
+}
+
+// requestedFormat returns the raster encoding r asks for: the format query
+// parameter, else WebP when the Accept header lists image/webp, else PNG.
+// The format parameter is returned as given; checkFormat validates it.
+func requestedFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+	if acceptsWebP(r.Header.Get("Accept")) {
+		return formatWebP
+	}
+	return formatPNG
+}
+
+// acceptsWebP reports whether an Accept header lists image/webp with a
+// non-zero quality. Wildcards do not count: clients that want WebP say so.
+func acceptsWebP(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), "image/webp") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(name) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// checkFormat returns an invalidOptionError unless format is a raster
+// encoding this server produces, or empty for PNG.
+func checkFormat(format string) error {
+	switch format {
+	case "", formatPNG, formatWebP:
+		return nil
+	case "avif":
+		return invalidOptionError{fmt.Errorf("AVIF output is not supported (use png or webp)")}
+	default:
+		return invalidOptionError{fmt.Errorf("invalid format %q (must be png or webp)", format)}
+	}
+}
+
+// encodeImage returns img encoded as format and its media type.
+func encodeImage(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == formatWebP {
+		if err := nativewebp.Encode(&buf, img, nil); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/webp", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// writeImage encodes img as format and writes it, or a 500 if encoding
+// fails.
+func writeImage(w http.ResponseWriter, img image.Image, format string) {
+	data, contentType, err := encodeImage(img, format)
+	if err != nil {
+		log.Printf("Error encoding %s: %v", format, err)
+		http.Error(w, "Error encoding image", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Accept")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing %s image: %v", format, err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestAcceptsWebP(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"image/png", false},
+		{"image/avif,image/webp,image/apng,*/*;q=0.8", true},
+		{"IMAGE/WEBP", true},
+		{"image/webp;q=0.5", true},
+		{"image/webp;q=0, image/png", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsWebP(tt.accept); got != tt.want {
+			t.Errorf("acceptsWebP(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestRequestedFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/live.png?format=PNG", nil)
+	req.Header.Set("Accept", "image/webp")
+	if got := requestedFormat(req); got != formatPNG {
+		t.Errorf("format parameter: got %q, want png over the Accept header", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/live.png", nil)
+	req.Header.Set("Accept", "image/webp,*/*")
+	if got := requestedFormat(req); got != formatWebP {
+		t.Errorf("Accept header: got %q, want webp", got)
+	}
+
+	if got := requestedFormat(httptest.NewRequest(http.MethodGet, "/live.png", nil)); got != formatPNG {
+		t.Errorf("default: got %q, want png", got)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, f := range []string{"", formatPNG, formatWebP} {
+		if err := checkFormat(f); err != nil {
+			t.Errorf("checkFormat(%q) = %v", f, err)
+		}
+	}
+	var invalid invalidOptionError
+	for _, f := range []string{"avif", "gif"} {
+		if err := checkFormat(f); !errors.As(err, &invalid) {
+			t.Errorf("checkFormat(%q) = %v, want an invalidOptionError", f, err)
+		}
+	}
+}
+
+func TestRasterEndpoints_WebP(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
+	for _, path := range []string{"/composite-map.png?format=webp", "/live.png", "/floorplan.png"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "image/webp,*/*")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, body=%q", path, w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "image/webp" {
+			t.Errorf("%s Content-Type = %q, want image/webp", path, ct)
+		}
+		if v := w.Header().Get("Vary"); v != "Accept" {
+			t.Errorf("%s Vary = %q, want Accept", path, v)
+		}
+		if _, err := webp.Decode(bytes.NewReader(w.Body.Bytes())); err != nil {
+			t.Errorf("%s: decoding WebP: %v", path, err)
+		}
+	}
+}
+
+func TestRasterEndpoints_UnsupportedFormat(t *testing.T) {
+	handler := newHTTPServer(httpServerOptions{StateTracker: populatedTracker(), RefID: "vac1"})
+	for _, path := range []string{"/composite-map.png?format=avif", "/floorplan.png?format=gif", "/room/Kitchen.png?format=avif"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s status = %d, want 400", path, w.Code)
+		}
+	}
+}
@@ -4,7 +4,13 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
 )
 
 // Version is set at build time via -ldflags
@@ -15,6 +21,7 @@ type AppOptions struct {
 	ConfigFile         string
 	ParseOnly          bool
 	CalibrateOnly      bool
+	Interactive        bool
 	RenderOnly         bool
 	RenderIndividual   bool
 	IndividualRotation string
@@ -22,38 +29,199 @@ type AppOptions struct {
 	ForceRotation      string
 	ReferenceVacuum    string
 	RotateAll          float64
+	AutoRotate         bool
+	Crop               *mesh.CropRegion
+	WorldFile          bool
+	CompareAngles      []float64
+	CompareGrid        bool
 	OutputFile         string
 	DataDir            string
 	DetectRotation     bool
+	Apply              bool
 	CalibrationCache   string
 	MqttMode           bool
 	HttpMode           bool
 	HttpPort           int
+	GrpcPort           int
 	RenderFormat       string
 	VectorFormat       string
 	GridSpacing        float64
+	ExportHints        string
+	ExportROS          string
+	Watch              bool
+	Replay             string
+	ReplaySpeed        float64
+	Simulate           int
+	SimulateMQTT       bool
+	Record             string
+	RecordMaxMB        int
+	RecordFiles        int
+	Stats              bool
+	Remote             string
+	Report             string
+	Prune              bool
+	ValidateConfig     bool
+	Profile            string
+	RebaseReference    string
+	RollbackCal        string
+	Doctor             bool
+	DoctorTimeout      time.Duration
+	JSON               bool
+	GenerateToken      string
 }
 
 // MainApp defines the interface for the application logic
 type MainApp interface {
 	ApplyOptions(opts AppOptions)
-	RunParseOnly()
-	RunCalibration()
-	RunRender()
-	RunRenderIndividual(string)
-	RunCompareRotation(string)
-	RunDetectRotation()
-	RunService()
+	RunParseOnly() error
+	RunCalibration() error
+	RunCalibrationWizard() error
+	RunRender() error
+	RunRenderWatch() error
+	RunRenderIndividual(string) error
+	RunCompareRotation(string) error
+	RunDetectRotation() error
+	RunExportHints(string) error
+	RunExportROS(string) error
+	RunStats() error
+	RunRemote(string) error
+	RunReport(string) error
+	RunPrune() error
+	RunValidateConfig() error
+	RunRebaseReference(string) error
+	RunRollbackCalibration(string) error
+	RunDoctor() error
+	RunService() error
 }
 
 func main() {
 	app := NewApp()
-	if err := run(os.Args[1:], os.Stdout, app); err != nil {
-		if err != flag.ErrHelp {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+	err := run(os.Args[1:], os.Stdout, app)
+	code := exitCode(err)
+	switch code {
+	case exitPartial:
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	case exitFatal:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// dataDirAuto is the --data-dir value that selects mesh.UserDataDir.
+const dataDirAuto = "auto"
+
+// resolveDataDir expands a leading ~ in --data-dir, or with "auto" returns
+// the per-user data directory, creating it if needed.
+func resolveDataDir(dir string) (string, error) {
+	if dir != dataDirAuto {
+		return mesh.ExpandHome(dir), nil
+	}
+	dir, err := mesh.UserDataDir()
+	if err != nil {
+		return "", fmt.Errorf("--data-dir=auto: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("--data-dir=auto: %w", err)
+	}
+	return dir, nil
+}
+
+// rotationFlag parses --rotate-all, which takes degrees or "auto".
+type rotationFlag struct {
+	degrees *float64
+	auto    *bool
+}
+
+func (f rotationFlag) String() string {
+	if f.auto != nil && *f.auto {
+		return "auto"
+	}
+	if f.degrees == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(*f.degrees, 'f', -1, 64)
+}
+
+func (f rotationFlag) Set(s string) error {
+	if strings.EqualFold(s, "auto") {
+		*f.auto = true
+		*f.degrees = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("must be degrees or \"auto\"")
+	}
+	*f.auto = false
+	*f.degrees = v
+	return nil
+}
+
+// angleListFlag parses a comma-separated list of degrees, e.g. 0,37.5,90.
+type angleListFlag struct {
+	angles *[]float64
+}
+
+func (f angleListFlag) String() string {
+	if f.angles == nil {
+		return ""
+	}
+	parts := make([]string, len(*f.angles))
+	for i, a := range *f.angles {
+		parts[i] = strconv.FormatFloat(a, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f angleListFlag) Set(s string) error {
+	var angles []float64
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("must be a comma-separated list of degrees")
 		}
+		angles = append(angles, v)
+	}
+	*f.angles = angles
+	return nil
+}
+
+// cropFlag parses --crop=x1,y1,x2,y2 (world millimeters).
+type cropFlag struct {
+	region **mesh.CropRegion
+}
+
+func (f cropFlag) String() string {
+	if f.region == nil || *f.region == nil {
+		return ""
 	}
+	c := *f.region
+	return fmt.Sprintf("%g,%g,%g,%g", c.MinX, c.MinY, c.MaxX, c.MaxY)
+}
+
+func (f cropFlag) Set(s string) error {
+	c, err := mesh.ParseCropRegion(s)
+	if err != nil {
+		return err
+	}
+	*f.region = c
+	return nil
+}
+
+// printToken writes a new token with role and the config.yaml entry that
+// enables it.
+func printToken(out io.Writer, role string) error {
+	if role != mesh.TokenRoleRead && role != mesh.TokenRoleAdmin {
+		return fmt.Errorf("invalid --generate-token role %q (must be read or admin)", role)
+	}
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("generating token: %w", err)
+	}
+	_, _ = fmt.Fprintf(out, "\nNew %s token (keep it secret):\n%s\n\n", role, token)
+	_, _ = fmt.Fprintf(out, "Add it to config.yaml under http.auth.tokens:\n")
+	_, _ = fmt.Fprintf(out, "      - name: %s-%s\n        token: %s\n        role: %s\n", role, time.Now().Format("20060102"), token, role)
+	return nil
 }
 
 func run(args []string, out io.Writer, app MainApp) error {
@@ -64,77 +232,215 @@ func run(args []string, out io.Writer, app MainApp) error {
 	fs.StringVar(&opts.ConfigFile, "config", "config.yaml", "Path to configuration file")
 	fs.BoolVar(&opts.ParseOnly, "parse-only", false, "Parse JSON exports and exit (test mode)")
 	fs.BoolVar(&opts.CalibrateOnly, "calibrate", false, "Run calibration on JSON exports and exit (test mode)")
+	fs.BoolVar(&opts.Interactive, "interactive", false, "With --calibrate, pick each vacuum's rotation from rendered candidates and nudge its translation, then write the config hints and calibration cache")
 	fs.BoolVar(&opts.RenderOnly, "render", false, "Render composite map PNG and exit")
 	fs.BoolVar(&opts.RenderIndividual, "render-individual", false, "Render each vacuum map as separate PNG")
 	fs.StringVar(&opts.IndividualRotation, "individual-rotation", "", "Rotation for individual renders: VACUUM_ID=DEGREES")
-	fs.StringVar(&opts.CompareRotation, "compare-rotation", "", "Render 4 rotation options for specified vacuum ID")
+	fs.StringVar(&opts.CompareRotation, "compare-rotation", "", "Render 4 rotation options for specified vacuum ID, or \"all\" for every non-reference vacuum plus an HTML index")
 	fs.StringVar(&opts.ForceRotation, "force-rotation", "", "Force rotation for vacuum: VACUUM_ID=DEGREES (e.g., FrugalLameLion=180)")
 	fs.StringVar(&opts.ReferenceVacuum, "reference", "", "Override reference vacuum (default: from config or largest area)")
-	fs.Float64Var(&opts.RotateAll, "rotate-all", 0, "Rotate entire composite by degrees (0, 90, 180, 270)")
+	fs.Var(rotationFlag{degrees: &opts.RotateAll, auto: &opts.AutoRotate}, "rotate-all", "Rotate entire composite by degrees (any angle), or \"auto\" to square up the reference map's walls")
+	fs.StringVar(&opts.Profile, "profile", "", "Render only the vacuums of this profile from config.yaml in --render mode")
+	fs.Var(cropFlag{region: &opts.Crop}, "crop", "Render only the region x1,y1,x2,y2 (world millimeters) in --render mode")
+	fs.BoolVar(&opts.WorldFile, "world-file", false, "With --render, write a world file (.pgw) next to the raster so GIS and CAD tools place it in millimeters")
+	fs.Var(angleListFlag{angles: &opts.CompareAngles}, "compare-angles", "Rotations rendered by --compare-rotation, comma-separated degrees (default 0,90,180,270)")
+	fs.BoolVar(&opts.CompareGrid, "compare-grid", false, "With --compare-rotation, write one annotated grid image per vacuum with every rotation and its alignment score")
 	fs.StringVar(&opts.OutputFile, "output", "composite-map.png", "Output file for --render mode")
-	fs.StringVar(&opts.DataDir, "data-dir", ".", "Directory containing JSON exports for parse-only mode")
+	fs.StringVar(&opts.DataDir, "data-dir", ".", "Directory for map exports, the calibration cache and other state, or \"auto\" for the per-user data directory")
 	fs.BoolVar(&opts.DetectRotation, "detect-rotation", false, "Analyze wall angles to detect rotation differences")
-	fs.StringVar(&opts.CalibrationCache, "calibration-cache", ".calibration-cache.json", "Path to calibration cache file")
+	fs.BoolVar(&opts.Apply, "apply", false, "With --detect-rotation, write the detected rotations to the config file")
+	fs.StringVar(&opts.CalibrationCache, "calibration-cache", ".calibration-cache.json", "Path to calibration cache file; relative paths are in --data-dir")
 	fs.BoolVar(&opts.MqttMode, "mqtt", false, "Run MQTT service mode for real-time position tracking")
 	fs.BoolVar(&opts.HttpMode, "http", false, "Enable HTTP server for serving map images")
 	fs.IntVar(&opts.HttpPort, "http-port", 8080, "HTTP server port (default 8080)")
+	fs.IntVar(&opts.GrpcPort, "grpc-port", 0, "Enable gRPC API on this port (default 0 = disabled)")
 	fs.StringVar(&opts.RenderFormat, "format", "raster", "Render format: raster, vector, or both")
 	fs.StringVar(&opts.VectorFormat, "vector-format", "svg", "Vector output format: svg or png")
 	fs.Float64Var(&opts.GridSpacing, "grid-spacing", 1000.0, "Grid line spacing in millimeters (default 1000mm = 1m)")
+	fs.BoolVar(&opts.Watch, "watch", false, "With --render or service modes, reload map exports added or changed in --data-dir")
+	fs.StringVar(&opts.Replay, "replay", "", "Replay recorded MQTT messages from a JSON Lines file instead of connecting to a broker (implies --mqtt)")
+	fs.Float64Var(&opts.ReplaySpeed, "replay-speed", 1, "Replay speed: 1 keeps the original timing, 10 is ten times faster, 0 is as fast as possible")
+	fs.IntVar(&opts.Simulate, "simulate", 0, fmt.Sprintf("Run the service with this many simulated vacuums (1-%d) cleaning a synthetic home instead of real robots (implies --mqtt)", mesh.MaxSimulatedVacuums))
+	fs.BoolVar(&opts.SimulateMQTT, "simulate-mqtt", false, "With --simulate, publish the simulated robots' messages to the configured MQTT broker instead of feeding them to the service directly")
+	fs.StringVar(&opts.Record, "record", "", "Archive received MQTT map and state messages to this JSON Lines file for --replay; gzip compressed if it ends in .gz (implies --mqtt)")
+	fs.IntVar(&opts.RecordMaxMB, "record-max-mb", mesh.DefaultRecordMaxBytes>>20, "Rotate the recording when it reaches this size in MB")
+	fs.IntVar(&opts.RecordFiles, "record-files", mesh.DefaultRecordFiles, "Number of recording files to keep, including the active one")
+	fs.BoolVar(&opts.Stats, "stats", false, "Print floor coverage statistics and exit")
+	fs.StringVar(&opts.Remote, "remote", "", "Run --render, --calibrate or --stats against a running service at this URL (e.g. http://host:8080)")
+	fs.StringVar(&opts.Report, "report", "", "Write a standalone HTML alignment report to this file and exit")
+	fs.BoolVar(&opts.ValidateConfig, "validate-config", false, "Check --config for errors, including unknown keys, and exit")
+	fs.BoolVar(&opts.Prune, "prune", false, "Remove map exports and raw PNGs in --data-dir outside the config's retention policy and exit")
+	fs.StringVar(&opts.RebaseReference, "rebase-reference", "", "Make this vacuum the reference by recomputing the cached transforms relative to it, without re-running ICP, and exit")
+	fs.StringVar(&opts.RollbackCal, "rollback-calibration", "", "Restore a previous calibration cache and exit: 1 for the newest backup, 2 for the one before, a backup timestamp, or \"list\" to show them")
+	fs.BoolVar(&opts.Doctor, "doctor", false, "Check config, MQTT topics, calibration cache and rendering, print a PASS/FAIL report and exit")
+	fs.DurationVar(&opts.DoctorTimeout, "doctor-timeout", DefaultDoctorTimeout, "How long --doctor waits for the broker and each vacuum's map data")
+	fs.BoolVar(&opts.JSON, "json", false, "Print the results of --parse-only, --calibrate, --detect-rotation or --stats as JSON on stdout")
+	fs.StringVar(&opts.ExportHints, "export-hints", "", "Print calibration as placement hints and exit: text or map-card")
+	fs.StringVar(&opts.ExportROS, "export-ros", "", "Write the unified map as a ROS occupancy grid (PATH.pgm and PATH.yaml) and exit")
+	fs.StringVar(&opts.GenerateToken, "generate-token", "", "Print a new random API token with this role (read or admin) for http.auth and exit")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	_, _ = fmt.Fprintf(out, "tudomesh version: %s\n", Version)
+	// Keep stdout parseable in --json mode
+	if opts.JSON {
+		if !opts.ParseOnly && !opts.CalibrateOnly && !opts.DetectRotation && !opts.Stats {
+			return fmt.Errorf("--json needs --parse-only, --calibrate, --detect-rotation or --stats")
+		}
+	} else {
+		_, _ = fmt.Fprintf(out, "tudomesh version: %s\n", Version)
+	}
+
+	if opts.GenerateToken != "" {
+		return printToken(out, opts.GenerateToken)
+	}
+
+	if opts.ReplaySpeed < 0 {
+		return fmt.Errorf("invalid --replay-speed %v (must be 0 or more)", opts.ReplaySpeed)
+	}
+	if opts.Record != "" && (opts.RecordMaxMB < 1 || opts.RecordFiles < 1) {
+		return fmt.Errorf("--record-max-mb and --record-files must be at least 1")
+	}
+	if opts.Interactive && (!opts.CalibrateOnly || opts.JSON || opts.Remote != "") {
+		return fmt.Errorf("--interactive needs --calibrate, without --json or --remote")
+	}
+	if opts.Simulate < 0 || opts.Simulate > mesh.MaxSimulatedVacuums {
+		return fmt.Errorf("invalid --simulate %d (must be 1 to %d)", opts.Simulate, mesh.MaxSimulatedVacuums)
+	}
+	if opts.SimulateMQTT && opts.Simulate == 0 {
+		return fmt.Errorf("--simulate-mqtt needs --simulate")
+	}
+	if opts.Simulate > 0 && opts.Replay != "" {
+		return fmt.Errorf("--simulate and --replay cannot be combined")
+	}
+	if opts.Replay != "" || opts.Record != "" || opts.Simulate > 0 {
+		opts.MqttMode = true
+	}
+
+	dataDir, err := resolveDataDir(opts.DataDir)
+	if err != nil {
+		return err
+	}
+	opts.DataDir = dataDir
 
 	app.ApplyOptions(opts)
 
+	if opts.Remote != "" {
+		var command string
+		switch {
+		case opts.RenderOnly:
+			command = remoteRender
+		case opts.CalibrateOnly:
+			command = remoteCalibrate
+		case opts.Stats:
+			command = remoteStats
+		default:
+			return fmt.Errorf("--remote needs --render, --calibrate or --stats")
+		}
+		if _, err := newRemoteClient(opts.Remote, out); err != nil {
+			return err
+		}
+		return app.RunRemote(command)
+	}
+
 	if opts.ParseOnly {
-		app.RunParseOnly()
-		return nil
+		return app.RunParseOnly()
 	}
 
 	if opts.CalibrateOnly {
-		app.RunCalibration()
-		return nil
+		if opts.Interactive {
+			return app.RunCalibrationWizard()
+		}
+		return app.RunCalibration()
 	}
 
 	if opts.RenderOnly {
-		app.RunRender()
-		return nil
+		if opts.Watch {
+			return app.RunRenderWatch()
+		}
+		return app.RunRender()
 	}
 
 	if opts.RenderIndividual {
-		app.RunRenderIndividual(opts.IndividualRotation)
-		return nil
+		return app.RunRenderIndividual(opts.IndividualRotation)
 	}
 
 	if opts.CompareRotation != "" {
-		app.RunCompareRotation(opts.CompareRotation)
-		return nil
+		return app.RunCompareRotation(opts.CompareRotation)
 	}
 
 	if opts.DetectRotation {
-		app.RunDetectRotation()
-		return nil
+		return app.RunDetectRotation()
 	}
 
-	if opts.MqttMode || opts.HttpMode {
-		app.RunService()
-		return nil
+	if opts.Stats {
+		return app.RunStats()
+	}
+
+	if opts.Report != "" {
+		return app.RunReport(opts.Report)
+	}
+
+	if opts.Prune {
+		return app.RunPrune()
+	}
+
+	if opts.ValidateConfig {
+		return app.RunValidateConfig()
+	}
+
+	if opts.Doctor {
+		return app.RunDoctor()
+	}
+
+	if opts.RebaseReference != "" {
+		return app.RunRebaseReference(opts.RebaseReference)
+	}
+
+	if opts.RollbackCal != "" {
+		return app.RunRollbackCalibration(opts.RollbackCal)
+	}
+
+	if opts.ExportHints != "" {
+		if opts.ExportHints != mesh.HintsFormatText && opts.ExportHints != mesh.HintsFormatMapCard {
+			return fmt.Errorf("invalid --export-hints format %q (must be text or map-card)", opts.ExportHints)
+		}
+		return app.RunExportHints(opts.ExportHints)
+	}
+
+	if opts.ExportROS != "" {
+		return app.RunExportROS(opts.ExportROS)
+	}
+
+	if opts.MqttMode || opts.HttpMode || opts.GrpcPort > 0 {
+		return app.RunService()
 	}
 
 	// Normal service mode - to be implemented
 	_, _ = fmt.Fprintln(out, "tudomesh service starting...")
 	_, _ = fmt.Fprintln(out, "Use --parse-only to test JSON parsing")
-	_, _ = fmt.Fprintln(out, "Use --calibrate to test ICP calibration")
+	_, _ = fmt.Fprintln(out, "Use --calibrate to test ICP calibration (--interactive to pick rotations and nudge translations by hand)")
 	_, _ = fmt.Fprintln(out, "Use --render to output composite map PNG")
-	_, _ = fmt.Fprintln(out, "Use --compare-rotation=VACUUM_ID to compare rotation options")
-	_, _ = fmt.Fprintln(out, "Use --detect-rotation to analyze wall angles")
+	_, _ = fmt.Fprintln(out, "Use --compare-rotation=VACUUM_ID (or =all) to compare rotation options (--compare-angles=0,37.5,... for custom angles)")
+	_, _ = fmt.Fprintln(out, "Use --detect-rotation to analyze wall angles (--apply writes the rotations to the config file)")
+	_, _ = fmt.Fprintln(out, "Use --stats to print floor coverage statistics")
+	_, _ = fmt.Fprintln(out, "Use --report=FILE.html to write an alignment report")
+	_, _ = fmt.Fprintln(out, "Use --validate-config to check config.yaml for errors")
+	_, _ = fmt.Fprintln(out, "Use --prune to clean up old files in --data-dir per the retention policy")
+	_, _ = fmt.Fprintln(out, "Use --remote=URL with --render, --calibrate or --stats to use a running service")
+	_, _ = fmt.Fprintln(out, "Use --export-hints=text|map-card to export alignment for other map viewers")
+	_, _ = fmt.Fprintln(out, "Use --rebase-reference=VACUUM_ID to switch the reference vacuum without recalibrating")
+	_, _ = fmt.Fprintln(out, "Use --rollback-calibration=1 to restore the previous calibration (=list shows the backups)")
+	_, _ = fmt.Fprintln(out, "Use --doctor to check the setup and print a PASS/FAIL report")
+	_, _ = fmt.Fprintln(out, "Use --generate-token=read|admin to create an API token for http.auth")
 	_, _ = fmt.Fprintln(out, "Use --mqtt to run MQTT service mode")
 	_, _ = fmt.Fprintln(out, "Use --http to run HTTP server mode")
 	_, _ = fmt.Fprintln(out, "Use --mqtt --http to run both MQTT and HTTP together")
+	_, _ = fmt.Fprintln(out, "Use --record=FILE to archive received MQTT messages for --replay")
+	_, _ = fmt.Fprintln(out, "Use --replay=FILE to replay recorded MQTT messages without a broker")
+	_, _ = fmt.Fprintln(out, "Use --simulate=N to run the service with N simulated vacuums")
+	_, _ = fmt.Fprintln(out, "Use --grpc-port=PORT to expose the gRPC API")
 	_, _ = fmt.Fprintln(out, "\nConfiguration:")
 	_, _ = fmt.Fprintln(out, "  config.yaml - MQTT settings and calibration overrides")
 	_, _ = fmt.Fprintln(out, "  .calibration-cache.json - Auto-computed ICP transforms (cached)")
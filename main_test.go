@@ -2,14 +2,21 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
 )
 
 type mockApp struct {
 	opts   AppOptions
 	called map[string]bool
 	sArg   string
+	err    error // returned by every Run method
 }
 
 func newMockApp() *mockApp {
@@ -18,14 +25,33 @@ func newMockApp() *mockApp {
 	}
 }
 
-func (m *mockApp) ApplyOptions(opts AppOptions) { m.opts = opts }
-func (m *mockApp) RunParseOnly()                { m.called["RunParseOnly"] = true }
-func (m *mockApp) RunCalibration()              { m.called["RunCalibration"] = true }
-func (m *mockApp) RunRender()                   { m.called["RunRender"] = true }
-func (m *mockApp) RunRenderIndividual(s string) { m.called["RunRenderIndividual"] = true; m.sArg = s }
-func (m *mockApp) RunCompareRotation(s string)  { m.called["RunCompareRotation"] = true; m.sArg = s }
-func (m *mockApp) RunDetectRotation()           { m.called["RunDetectRotation"] = true }
-func (m *mockApp) RunService()                  { m.called["RunService"] = true }
+func (m *mockApp) ApplyOptions(opts AppOptions)          { m.opts = opts }
+func (m *mockApp) RunParseOnly() error                   { return m.run("RunParseOnly", "") }
+func (m *mockApp) RunCalibration() error                 { return m.run("RunCalibration", "") }
+func (m *mockApp) RunCalibrationWizard() error           { return m.run("RunCalibrationWizard", "") }
+func (m *mockApp) RunRender() error                      { return m.run("RunRender", "") }
+func (m *mockApp) RunRenderWatch() error                 { return m.run("RunRenderWatch", "") }
+func (m *mockApp) RunRenderIndividual(s string) error    { return m.run("RunRenderIndividual", s) }
+func (m *mockApp) RunCompareRotation(s string) error     { return m.run("RunCompareRotation", s) }
+func (m *mockApp) RunDetectRotation() error              { return m.run("RunDetectRotation", "") }
+func (m *mockApp) RunExportHints(s string) error         { return m.run("RunExportHints", s) }
+func (m *mockApp) RunExportROS(s string) error           { return m.run("RunExportROS", s) }
+func (m *mockApp) RunStats() error                       { return m.run("RunStats", "") }
+func (m *mockApp) RunRemote(s string) error              { return m.run("RunRemote", s) }
+func (m *mockApp) RunReport(s string) error              { return m.run("RunReport", s) }
+func (m *mockApp) RunPrune() error                       { return m.run("RunPrune", "") }
+func (m *mockApp) RunValidateConfig() error              { return m.run("RunValidateConfig", "") }
+func (m *mockApp) RunRebaseReference(s string) error     { return m.run("RunRebaseReference", s) }
+func (m *mockApp) RunRollbackCalibration(s string) error { return m.run("RunRollbackCalibration", s) }
+func (m *mockApp) RunDoctor() error                      { return m.run("RunDoctor", "") }
+func (m *mockApp) RunService() error                     { return m.run("RunService", "") }
+
+// run records a call to the named Run method and its string argument.
+func (m *mockApp) run(name, s string) error {
+	m.called[name] = true
+	m.sArg = s
+	return m.err
+}
 
 func TestRun_Flags(t *testing.T) {
 	tests := []struct {
@@ -76,6 +102,60 @@ func TestRun_Flags(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:           "RenderProfile",
+			args:           []string{"--render", "--profile", "upstairs"},
+			expectedCalled: "RunRender",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if opts.Profile != "upstairs" {
+					t.Errorf("expected Profile upstairs, got %q", opts.Profile)
+				}
+			},
+		},
+		{
+			name:           "RenderAutoRotate",
+			args:           []string{"--render", "--rotate-all", "auto"},
+			expectedCalled: "RunRender",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if !opts.AutoRotate {
+					t.Error("expected AutoRotate true")
+				}
+				if opts.RotateAll != 0 {
+					t.Errorf("expected RotateAll 0 with auto, got %f", opts.RotateAll)
+				}
+			},
+		},
+		{
+			name:           "RenderWatch",
+			args:           []string{"--render", "--watch"},
+			expectedCalled: "RunRenderWatch",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if !opts.Watch {
+					t.Error("expected Watch true")
+				}
+			},
+		},
+		{
+			name:           "RenderCrop",
+			args:           []string{"--render", "--crop", "2000,1500,500,4000"},
+			expectedCalled: "RunRender",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				want := mesh.CropRegion{MinX: 500, MinY: 1500, MaxX: 2000, MaxY: 4000}
+				if opts.Crop == nil || *opts.Crop != want {
+					t.Errorf("expected Crop %+v, got %+v", want, opts.Crop)
+				}
+			},
+		},
+		{
+			name:           "RenderWorldFile",
+			args:           []string{"--render", "--world-file"},
+			expectedCalled: "RunRender",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if !opts.WorldFile {
+					t.Error("expected WorldFile true")
+				}
+			},
+		},
 		{
 			name:           "RenderIndividual",
 			args:           []string{"--render-individual", "--individual-rotation", "vac1=180"},
@@ -99,6 +179,42 @@ func TestRun_Flags(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:           "CompareRotationAll",
+			args:           []string{"--compare-rotation=all"},
+			expectedCalled: "RunCompareRotation",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if opts.CompareRotation != "all" {
+					t.Errorf("expected CompareRotation all, got %s", opts.CompareRotation)
+				}
+			},
+		},
+		{
+			name:           "CompareRotationCustomAngles",
+			args:           []string{"--compare-rotation", "vac2", "--compare-angles", "0, 37.5,-10"},
+			expectedCalled: "RunCompareRotation",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				want := []float64{0, 37.5, -10}
+				if len(opts.CompareAngles) != len(want) {
+					t.Fatalf("expected CompareAngles %v, got %v", want, opts.CompareAngles)
+				}
+				for i := range want {
+					if opts.CompareAngles[i] != want[i] {
+						t.Errorf("expected CompareAngles %v, got %v", want, opts.CompareAngles)
+					}
+				}
+			},
+		},
+		{
+			name:           "CompareRotationGrid",
+			args:           []string{"--compare-rotation", "vac2", "--compare-grid"},
+			expectedCalled: "RunCompareRotation",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if !opts.CompareGrid {
+					t.Error("expected CompareGrid to be true")
+				}
+			},
+		},
 		{
 			name:           "DetectRotation",
 			args:           []string{"--detect-rotation", "--reference", "refVac"},
@@ -112,6 +228,16 @@ func TestRun_Flags(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:           "DetectRotationApply",
+			args:           []string{"--detect-rotation", "--apply"},
+			expectedCalled: "RunDetectRotation",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if !opts.Apply {
+					t.Error("expected Apply true")
+				}
+			},
+		},
 		{
 			name:           "MqttMode",
 			args:           []string{"--mqtt", "--http-port", "9090"},
@@ -125,6 +251,32 @@ func TestRun_Flags(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:           "Replay",
+			args:           []string{"--replay", "traffic.jsonl", "--replay-speed", "10", "--http"},
+			expectedCalled: "RunService",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if opts.Replay != "traffic.jsonl" || opts.ReplaySpeed != 10 {
+					t.Errorf("expected Replay traffic.jsonl at 10, got %s at %v", opts.Replay, opts.ReplaySpeed)
+				}
+				if !opts.MqttMode {
+					t.Error("expected --replay to imply MqttMode")
+				}
+			},
+		},
+		{
+			name:           "Simulate",
+			args:           []string{"--simulate", "3", "--http"},
+			expectedCalled: "RunService",
+			verifyOpts: func(t *testing.T, opts AppOptions) {
+				if opts.Simulate != 3 || opts.SimulateMQTT {
+					t.Errorf("expected 3 simulated vacuums fed directly, got %d (mqtt %v)", opts.Simulate, opts.SimulateMQTT)
+				}
+				if !opts.MqttMode {
+					t.Error("expected --simulate to imply MqttMode")
+				}
+			},
+		},
 		{
 			name:           "VectorRendering",
 			args:           []string{"--render", "--format", "vector", "--vector-format", "svg", "--grid-spacing", "500"},
@@ -175,6 +327,323 @@ func TestRun_Help(t *testing.T) {
 	}
 }
 
+func TestRun_InvalidRotateAll(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--rotate-all", "sideways"}, &out, app); err == nil {
+		t.Error("expected error for --rotate-all=sideways")
+	}
+}
+
+func TestRun_InvalidCrop(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--crop", "1,2,3"}, &out, app); err == nil {
+		t.Error("expected error for --crop with three values")
+	}
+}
+
+func TestRun_InvalidCompareAngles(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--compare-rotation", "vac2", "--compare-angles", "0,ninety"}, &out, app); err == nil {
+		t.Error("expected error for --compare-angles=0,ninety")
+	}
+}
+
+func TestRun_InvalidReplaySpeed(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--replay", "traffic.jsonl", "--replay-speed", "-1"}, &out, app); err == nil {
+		t.Error("expected error for --replay-speed=-1")
+	}
+	if app.called["RunService"] {
+		t.Error("RunService should not run for an invalid replay speed")
+	}
+}
+
+func TestRun_InvalidSimulate(t *testing.T) {
+	for _, args := range [][]string{
+		{"--simulate", "9"},
+		{"--simulate", "-1"},
+		{"--simulate-mqtt"},
+		{"--simulate", "2", "--replay", "traffic.jsonl"},
+	} {
+		app := newMockApp()
+		var out bytes.Buffer
+		if err := run(args, &out, app); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+		if app.called["RunService"] {
+			t.Errorf("RunService should not run for %v", args)
+		}
+	}
+}
+
+func TestRun_Interactive(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--calibrate", "--interactive"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunCalibrationWizard"] || app.called["RunCalibration"] {
+		t.Errorf("expected only RunCalibrationWizard, called=%v", app.called)
+	}
+
+	for _, args := range [][]string{
+		{"--interactive"},
+		{"--render", "--interactive"},
+		{"--calibrate", "--interactive", "--json"},
+		{"--calibrate", "--interactive", "--remote", "http://server:8080"},
+	} {
+		app := newMockApp()
+		if err := run(args, &out, app); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+		if len(app.called) > 0 {
+			t.Errorf("%v ran %v", args, app.called)
+		}
+	}
+}
+
+func TestRun_Record(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--record", "traffic.jsonl.gz", "--record-files", "2"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunService"] || !app.opts.MqttMode {
+		t.Error("expected --record to run the service in MQTT mode")
+	}
+	if app.opts.RecordFiles != 2 || app.opts.RecordMaxMB != mesh.DefaultRecordMaxBytes>>20 {
+		t.Errorf("record options = %d files, %d MB", app.opts.RecordFiles, app.opts.RecordMaxMB)
+	}
+
+	app = newMockApp()
+	if err := run([]string{"--record", "traffic.jsonl", "--record-max-mb", "0"}, &out, app); err == nil {
+		t.Error("expected error for --record-max-mb=0")
+	}
+}
+
+func TestRun_ExportHints(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--export-hints", "map-card"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunExportHints"] || app.sArg != "map-card" {
+		t.Errorf("expected RunExportHints(map-card), called=%v arg=%q", app.called, app.sArg)
+	}
+
+	app = newMockApp()
+	if err := run([]string{"--export-hints", "xml"}, &out, app); err == nil {
+		t.Error("expected error for --export-hints=xml")
+	}
+	if app.called["RunExportHints"] {
+		t.Error("RunExportHints should not run for an invalid format")
+	}
+}
+
+func TestRun_ExportROS(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--export-ros", "maps/house"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunExportROS"] || app.sArg != "maps/house" {
+		t.Errorf("expected RunExportROS(maps/house), called=%v arg=%q", app.called, app.sArg)
+	}
+}
+
+func TestRun_Stats(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--stats"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunStats"] {
+		t.Errorf("expected RunStats, called=%v", app.called)
+	}
+}
+
+func TestRun_Report(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--report", "report.html"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunReport"] || app.sArg != "report.html" {
+		t.Errorf("expected RunReport(report.html), called=%v arg=%q", app.called, app.sArg)
+	}
+}
+
+func TestRun_RebaseReference(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--rebase-reference", "vac-2"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunRebaseReference"] || app.sArg != "vac-2" {
+		t.Errorf("expected RunRebaseReference(vac-2), called=%v arg=%q", app.called, app.sArg)
+	}
+}
+
+func TestRun_RollbackCalibration(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--rollback-calibration=1"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunRollbackCalibration"] || app.sArg != "1" {
+		t.Errorf("expected RunRollbackCalibration(1), called=%v arg=%q", app.called, app.sArg)
+	}
+}
+
+func TestRun_Doctor(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--doctor", "--doctor-timeout", "5s"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunDoctor"] || app.opts.DoctorTimeout != 5*time.Second {
+		t.Errorf("expected RunDoctor with a 5s timeout, called=%v timeout=%v", app.called, app.opts.DoctorTimeout)
+	}
+}
+
+func TestRun_Prune(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--prune"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunPrune"] {
+		t.Errorf("expected RunPrune, called=%v", app.called)
+	}
+}
+
+func TestRun_ValidateConfig(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--validate-config", "--config", "other.yaml"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunValidateConfig"] || app.opts.ConfigFile != "other.yaml" {
+		t.Errorf("expected RunValidateConfig with other.yaml, called=%v config=%q", app.called, app.opts.ConfigFile)
+	}
+}
+
+func TestRun_Remote(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+	}{
+		{[]string{"--remote", "http://server:8080", "--render"}, remoteRender},
+		{[]string{"--remote", "http://server:8080", "--calibrate"}, remoteCalibrate},
+		{[]string{"--remote", "https://server/tudomesh/", "--stats"}, remoteStats},
+	}
+	for _, tt := range tests {
+		app := newMockApp()
+		var out bytes.Buffer
+		if err := run(tt.args, &out, app); err != nil {
+			t.Fatalf("run(%v): %v", tt.args, err)
+		}
+		if !app.called["RunRemote"] || app.sArg != tt.command {
+			t.Errorf("run(%v): called=%v arg=%q, want RunRemote(%s)", tt.args, app.called, app.sArg, tt.command)
+		}
+		if app.called["RunRender"] || app.called["RunCalibration"] || app.called["RunStats"] {
+			t.Errorf("run(%v) also ran the local command", tt.args)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--remote", "http://server:8080"},
+		{"--remote", "server:8080", "--render"},
+		{"--remote", "ftp://server", "--stats"},
+	} {
+		app := newMockApp()
+		var out bytes.Buffer
+		if err := run(args, &out, app); err == nil {
+			t.Errorf("run(%v): expected error", args)
+		}
+		if app.called["RunRemote"] {
+			t.Errorf("run(%v): RunRemote should not run", args)
+		}
+	}
+}
+
+func TestRun_GenerateToken(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--generate-token", "admin"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(app.called) != 0 {
+		t.Errorf("called %v, want no mode", app.called)
+	}
+	for _, want := range []string{"New admin token", "token: ", "role: admin"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := run([]string{"--generate-token", "owner"}, &out, newMockApp()); err == nil {
+		t.Error("--generate-token=owner: expected error")
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--stats", "--json"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !app.called["RunStats"] || !app.opts.JSON {
+		t.Errorf("called=%v JSON=%v, want RunStats with JSON", app.called, app.opts.JSON)
+	}
+	if out.Len() != 0 {
+		t.Errorf("printed %q before the JSON", out.String())
+	}
+
+	app = newMockApp()
+	if err := run([]string{"--render", "--json"}, &out, app); err == nil {
+		t.Error("--json with --render: expected error")
+	}
+	if app.called["RunRender"] {
+		t.Error("--json with --render: RunRender should not run")
+	}
+}
+
+func TestRun_DataDirAuto(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG_DATA_HOME is only read on Linux and other Unix systems")
+	}
+	xdg := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", xdg)
+
+	app := newMockApp()
+	var out bytes.Buffer
+	if err := run([]string{"--parse-only", "--data-dir", "auto"}, &out, app); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := filepath.Join(xdg, mesh.AppDirName)
+	if app.opts.DataDir != want {
+		t.Errorf("DataDir = %q, want %q", app.opts.DataDir, want)
+	}
+	if info, err := os.Stat(want); err != nil || !info.IsDir() {
+		t.Errorf("data directory not created: %v", err)
+	}
+}
+
+func TestRun_ReturnsModeError(t *testing.T) {
+	app := newMockApp()
+	app.err = failures{"loading rocky: bad json"}.err()
+	var out bytes.Buffer
+	err := run([]string{"--parse-only"}, &out, app)
+	if got := exitCode(err); got != exitPartial {
+		t.Errorf("exitCode(%v) = %d, want %d", err, got, exitPartial)
+	}
+}
+
 func TestRun_Default(t *testing.T) {
 	app := newMockApp()
 	var out bytes.Buffer
@@ -0,0 +1,375 @@
+package mesh
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// Merging scans
+const (
+	minScanMergeScore = preAlignMinScore // ICP score below which a scan is not merged
+	sameScanOverlap   = 0.8              // share of the last scan's floor a map of the same run still covers
+)
+
+// MapAccumulator merges the scans of vacuums that only map part of the home
+// per run into one "best map" per vacuum, before the maps are unified
+// across vacuums. Each new run is aligned onto the map accumulated so far
+// with ICP and merged in; the latest scan wins where they overlap. The
+// accumulated map keeps the frame of the first scan, and the robot's
+// position is carried into it, so calibration works on the accumulated map
+// like on any other.
+//
+// Map updates within one run share its frame and replace that run's part of
+// the accumulated map without running ICP again. A run is recognized as new
+// when it no longer covers most of the previous update's floor.
+//
+// It is safe for concurrent use.
+type MapAccumulator struct {
+	icp ICPConfig
+
+	mu      sync.Mutex
+	enabled map[string]bool
+	vacuums map[string]*scanHistory
+}
+
+// scanHistory is the accumulated map of one vacuum.
+type scanHistory struct {
+	committed *ValetudoMap // earlier runs merged; nil while there was only one
+	scan      *ValetudoMap // latest map of the current run; nil after Seed
+	transform AffineMatrix // the current run's grid to the accumulated frame
+	merged    *ValetudoMap // committed with scan merged in
+	runs      int
+}
+
+// NewMapAccumulator creates an accumulator for the vacuums with accumulate
+// set in config, aligning runs with config's ICP settings.
+func NewMapAccumulator(config *Config) *MapAccumulator {
+	ma := &MapAccumulator{
+		icp:     ICPConfigFromConfig(config),
+		enabled: make(map[string]bool),
+		vacuums: make(map[string]*scanHistory),
+	}
+	if config != nil {
+		for _, vc := range config.Vacuums {
+			if vc.Accumulate {
+				ma.enabled[vc.ID] = true
+			}
+		}
+	}
+	return ma
+}
+
+// Enabled reports whether scans of vacuumID are accumulated.
+func (ma *MapAccumulator) Enabled(vacuumID string) bool {
+	if ma == nil {
+		return false
+	}
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	return ma.enabled[vacuumID]
+}
+
+// Seed starts the vacuum's accumulated map from m, such as the map saved
+// before a restart. The next map is aligned onto it as a new run.
+func (ma *MapAccumulator) Seed(vacuumID string, m *ValetudoMap) {
+	if !ma.Enabled(vacuumID) || !HasDrawablePixels(m) {
+		return
+	}
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+	ma.vacuums[vacuumID] = &scanHistory{transform: Identity(), merged: m, runs: 1}
+}
+
+// Add merges a map received from vacuumID and returns the accumulated map,
+// with the scan's robot position, charger and path in its frame. Vacuums
+// that do not accumulate get scan back unchanged. Maps without drawable
+// layers only carry the robot position, which is moved into the
+// accumulated frame.
+func (ma *MapAccumulator) Add(vacuumID string, scan *ValetudoMap) *ValetudoMap {
+	if !ma.Enabled(vacuumID) || scan == nil {
+		return scan
+	}
+	ma.mu.Lock()
+	defer ma.mu.Unlock()
+
+	h := ma.vacuums[vacuumID]
+	if !HasDrawablePixels(scan) {
+		if h == nil {
+			return scan
+		}
+		merged := *h.merged
+		merged.Entities = transformEntities(scan.Entities, scan.PixelSize, h.transform)
+		return &merged
+	}
+
+	switch {
+	case h == nil:
+		h = &scanHistory{scan: scan, transform: Identity(), merged: scan, runs: 1}
+		ma.vacuums[vacuumID] = h
+		return scan
+
+	case h.scan != nil && sameScan(h.scan, scan):
+		// The run goes on in its own frame; its new map replaces its old one
+		h.scan = scan
+		h.merged = mergeScan(h.committed, scan, h.transform)
+		return h.merged
+	}
+
+	// A new run: align it onto everything accumulated so far
+	committed := h.merged
+	trace := StartTrace("accumulate " + vacuumID)
+	trace.Step("icp")
+	var result ICPResult
+	if scan.PixelSize == committed.PixelSize {
+		result = alignRun(scan, committed, ma.icp)
+	}
+	trace.Step("merge")
+	defer trace.End()
+
+	if result.Score < minScanMergeScore || !ValidateAlignment(result.Transform) {
+		log.Printf("[ACCUMULATE] %s: new run does not match the accumulated map (score %.2f); starting over", vacuumID, result.Score)
+		*h = scanHistory{scan: scan, transform: Identity(), merged: scan, runs: 1}
+		return scan
+	}
+	h.runs++
+	*h = scanHistory{
+		committed: committed,
+		scan:      scan,
+		transform: result.Transform,
+		merged:    mergeScan(committed, scan, result.Transform),
+		runs:      h.runs,
+	}
+	log.Printf("[ACCUMULATE] %s: merged run %d (score %.2f, rotation %.1f°)",
+		vacuumID, h.runs, result.Score, TransformRotation(result.Transform))
+	return h.merged
+}
+
+// alignRun aligns a new run onto the accumulated map. A run usually
+// overlaps the accumulated map only in part, which AlignMaps, seeking the
+// best overlap around the centroids, tends to slide. Both maps come from the
+// same robot, though, so they share the dock: when both have a charger, each
+// quarter turn is tried with the chargers on top of each other and refined
+// on the walls. Maps without a charger fall back to AlignMaps.
+func alignRun(scan, accumulated *ValetudoMap, config ICPConfig) ICPResult {
+	scanCharger, ok1 := ExtractChargerPosition(scan)
+	accCharger, ok2 := ExtractChargerPosition(accumulated)
+	if !ok1 || !ok2 || scan.PixelSize <= 0 {
+		return AlignMaps(scan, accumulated, config)
+	}
+	config = config.withPixelSize(accumulated)
+	ps := float64(scan.PixelSize)
+	from := Point{X: scanCharger.X / ps, Y: scanCharger.Y / ps}
+	to := Point{X: accCharger.X / ps, Y: accCharger.Y / ps}
+
+	sourceFeatures := config.features(scan)
+	targetFeatures := config.features(accumulated)
+	sourcePoints := config.sample(sourceFeatures)
+	targetPoints := config.sample(targetFeatures)
+	sourceWalls := samplePointSlice(sourceFeatures.WallPoints, 1000)
+	targetWalls := samplePointSlice(targetFeatures.WallPoints, 1000)
+	if len(sourceWalls) < 10 || len(targetWalls) < 10 {
+		return AlignMaps(scan, accumulated, config)
+	}
+
+	// The robot docks in about the same place each time, so the walls only
+	// need to pull the transform a little way from the charger anchor
+	refine := config
+	refine.MaxIterations = config.passIterations(50)
+	refine.ConvergenceThresh = 0.5
+	refine.MaxCorrespondDist = 20.0
+
+	// A partial run lies on the accumulated floor in more than one turn, so
+	// the turn is picked by how many walls meet exactly; the score is the
+	// usual one
+	best := ICPResult{Transform: Identity(), Error: math.MaxFloat64, Score: -1.0}
+	bestWalls := -1.0
+	for _, rotDeg := range []float64{0, 90, 180, 270} {
+		initial := MultiplyMatrices(Translation(to.X, to.Y),
+			MultiplyMatrices(RotationDeg(rotDeg), Translation(-from.X, -from.Y)))
+		result := runICPWithMutualNN(sourceWalls, targetWalls, initial, refine)
+		result.InitialRotation = rotDeg
+		_, walls, _ := CalculateInlierScore(TransformPoints(sourceWalls, result.Transform), targetWalls, 3.0)
+		if walls > bestWalls {
+			bestWalls = walls
+			best = result
+		}
+	}
+	best.Score, best.InlierFraction, _ = CalculateInlierScore(TransformPoints(sourcePoints, best.Transform), targetPoints, 50.0)
+	best.TimedOut = config.expired()
+	return best
+}
+
+// sameScan reports whether next continues the run prev came from: in the
+// same frame it still covers most of prev's floor.
+func sameScan(prev, next *ValetudoMap) bool {
+	if prev.PixelSize != next.PixelSize {
+		return false
+	}
+	floor := make(map[[2]int]bool)
+	for _, layer := range next.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			for i := 0; i+1 < len(layer.Pixels); i += 2 {
+				floor[[2]int{layer.Pixels[i], layer.Pixels[i+1]}] = true
+			}
+		}
+	}
+	total, kept := 0, 0
+	for _, layer := range prev.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			for i := 0; i+1 < len(layer.Pixels); i += 2 {
+				total++
+				if floor[[2]int{layer.Pixels[i], layer.Pixels[i+1]}] {
+					kept++
+				}
+			}
+		}
+	}
+	return total == 0 || float64(kept) >= float64(total)*sameScanOverlap
+}
+
+// mergeScan returns committed with scan moved into its frame by transform
+// and laid on top. Pixels of committed that scan covers are dropped, so
+// the latest scan decides what is floor and what is wall. Older segments
+// whose ID the scan reuses get a fresh ID. With no committed map, scan is
+// returned as is.
+func mergeScan(committed, scan *ValetudoMap, transform AffineMatrix) *ValetudoMap {
+	if committed == nil {
+		return scan
+	}
+
+	merged := &ValetudoMap{
+		Class:      scan.Class,
+		MetaData:   scan.MetaData,
+		PixelSize:  committed.PixelSize,
+		Entities:   transformEntities(scan.Entities, scan.PixelSize, transform),
+		Downsample: max(committed.Downsample, scan.Downsample),
+	}
+
+	covered := make(map[[2]int]bool)
+	var layers []MapLayer
+	segmentIDs := make(map[string]bool)
+	maxSegmentID := 0
+	for _, layer := range scan.Layers {
+		moved := layer
+		moved.Pixels = transformLayerPixels(layer.Pixels, transform)
+		for i := 0; i+1 < len(moved.Pixels); i += 2 {
+			covered[[2]int{moved.Pixels[i], moved.Pixels[i+1]}] = true
+		}
+		if moved.MetaData.SegmentID != "" {
+			segmentIDs[moved.MetaData.SegmentID] = true
+			if n, err := strconv.Atoi(moved.MetaData.SegmentID); err == nil {
+				maxSegmentID = max(maxSegmentID, n)
+			}
+		}
+		layers = append(layers, moved)
+	}
+	for _, layer := range committed.Layers {
+		if n, err := strconv.Atoi(layer.MetaData.SegmentID); err == nil {
+			maxSegmentID = max(maxSegmentID, n)
+		}
+	}
+
+	var older []MapLayer
+	for _, layer := range committed.Layers {
+		var pixels []int
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			if !covered[[2]int{layer.Pixels[i], layer.Pixels[i+1]}] {
+				pixels = append(pixels, layer.Pixels[i], layer.Pixels[i+1])
+			}
+		}
+		if len(pixels) == 0 {
+			continue
+		}
+		layer.Pixels = pixels
+		if id := layer.MetaData.SegmentID; id != "" && segmentIDs[id] {
+			maxSegmentID++
+			layer.MetaData.SegmentID = strconv.Itoa(maxSegmentID)
+		}
+		older = append(older, layer)
+	}
+	merged.Layers = append(older, layers...)
+
+	// Areas follow the merged pixels
+	merged.MetaData.TotalLayerArea = 0
+	area := merged.PixelSize * merged.PixelSize * merged.CellBlock() * merged.CellBlock()
+	for i := range merged.Layers {
+		layer := &merged.Layers[i]
+		layer.MetaData.PixelCount = len(layer.Pixels) / 2
+		layer.MetaData.Area = layer.MetaData.PixelCount * area
+		if layer.Type == "floor" || layer.Type == "segment" {
+			merged.MetaData.TotalLayerArea += layer.MetaData.Area
+		}
+		for j := 0; j+1 < len(layer.Pixels); j += 2 {
+			merged.Size.X = max(merged.Size.X, layer.Pixels[j]+1)
+			merged.Size.Y = max(merged.Size.Y, layer.Pixels[j+1]+1)
+		}
+	}
+	return merged
+}
+
+// transformLayerPixels moves a layer's pixels by transform. Each
+// destination pixel is mapped back into the source grid, so rotated layers
+// leave no gaps, as in vacuumCoverage.
+func transformLayerPixels(pixels []int, transform AffineMatrix) []int {
+	if len(pixels) < 2 || transform == Identity() {
+		return pixels
+	}
+	src, srcMinX, srcMinY, srcW, srcH := pixelsToGrid(pixels, 1)
+
+	minX, minY := math.MaxFloat64, math.MaxFloat64
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, c := range []Point{
+		{X: float64(srcMinX), Y: float64(srcMinY)},
+		{X: float64(srcMinX + srcW), Y: float64(srcMinY)},
+		{X: float64(srcMinX), Y: float64(srcMinY + srcH)},
+		{X: float64(srcMinX + srcW), Y: float64(srcMinY + srcH)},
+	} {
+		tc := TransformPoint(c, transform)
+		minX, minY = math.Min(minX, tc.X), math.Min(minY, tc.Y)
+		maxX, maxY = math.Max(maxX, tc.X), math.Max(maxY, tc.Y)
+	}
+
+	inv := InvertMatrix(transform)
+	var out []int
+	for y := int(math.Floor(minY)); y <= int(math.Ceil(maxY)); y++ {
+		for x := int(math.Floor(minX)); x <= int(math.Ceil(maxX)); x++ {
+			p := TransformPoint(Point{X: float64(x), Y: float64(y)}, inv)
+			sx := int(math.Round(p.X)) - srcMinX
+			sy := int(math.Round(p.Y)) - srcMinY
+			if sx >= 0 && sx < srcW && sy >= 0 && sy < srcH && src[sy*srcW+sx] {
+				out = append(out, x, y)
+			}
+		}
+	}
+	return out
+}
+
+// transformEntities moves entity points, in map units of pixelSize per
+// pixel, by a grid transform, turning the robot's heading with it.
+func transformEntities(entities []MapEntity, pixelSize int, transform AffineMatrix) []MapEntity {
+	if pixelSize <= 0 {
+		pixelSize = defaultPixelSize
+	}
+	out := make([]MapEntity, len(entities))
+	ps := float64(pixelSize)
+	for i, e := range entities {
+		moved := e
+		moved.Points = make([]int, len(e.Points))
+		for j := 0; j+1 < len(e.Points); j += 2 {
+			p := TransformPoint(Point{X: float64(e.Points[j]) / ps, Y: float64(e.Points[j+1]) / ps}, transform)
+			moved.Points[j] = int(math.Round(p.X * ps))
+			moved.Points[j+1] = int(math.Round(p.Y * ps))
+		}
+		if angle, ok := e.MetaData["angle"].(float64); ok {
+			moved.MetaData = make(map[string]interface{}, len(e.MetaData))
+			for k, v := range e.MetaData {
+				moved.MetaData[k] = v
+			}
+			moved.MetaData["angle"] = TransformAngle(angle, transform)
+		}
+		out[i] = moved
+	}
+	return out
+}
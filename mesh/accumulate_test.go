@@ -0,0 +1,175 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// cropMap keeps the pixels of m for which keep(x, y) is true and adds a
+// robot at pixel (rx, ry) facing angle.
+func cropMap(m *ValetudoMap, keep func(x, y int) bool, rx, ry int, angle float64) *ValetudoMap {
+	out := &ValetudoMap{PixelSize: m.PixelSize}
+	for _, layer := range m.Layers {
+		cropped := MapLayer{Type: layer.Type}
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			if keep(layer.Pixels[i], layer.Pixels[i+1]) {
+				cropped.Pixels = append(cropped.Pixels, layer.Pixels[i], layer.Pixels[i+1])
+			}
+		}
+		out.Layers = append(out.Layers, cropped)
+	}
+	out.Entities = []MapEntity{{
+		Type:     "robot_position",
+		Points:   []int{rx * m.PixelSize, ry * m.PixelSize},
+		MetaData: map[string]interface{}{"angle": angle},
+	}}
+	return out
+}
+
+// withCharger adds a charger at pixel (x, y) to m.
+func withCharger(m *ValetudoMap, x, y int) *ValetudoMap {
+	m.Entities = append(m.Entities, MapEntity{Type: "charger_location", Points: []int{x * m.PixelSize, y * m.PixelSize}})
+	return m
+}
+
+func floorPixels(m *ValetudoMap) int {
+	n := 0
+	for _, layer := range m.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			n += len(layer.Pixels) / 2
+		}
+	}
+	return n
+}
+
+func accumulatorFor(ids ...string) *MapAccumulator {
+	cfg := &Config{}
+	for _, id := range ids {
+		cfg.Vacuums = append(cfg.Vacuums, VacuumConfig{ID: id, Accumulate: true})
+	}
+	return NewMapAccumulator(cfg)
+}
+
+func TestMapAccumulator_MergesRuns(t *testing.T) {
+	// The room spans x 220-580 unrotated; the first run maps up to x 500,
+	// the second from x 300 on, in a frame turned 90° where x is y. The
+	// charger, at (450, 284) against the top wall, is in both.
+	full := rotatedRoom(0)
+	first := withCharger(cropMap(full, func(x, _ int) bool { return x < 500 }, 300, 400, 0), 450, 284)
+	second := withCharger(cropMap(rotatedRoom(90), func(_, y int) bool { return y > 300 }, 400, 500, 10), 516, 450)
+
+	ma := accumulatorFor("vac")
+	if got := ma.Add("vac", first); got != first {
+		t.Fatal("first run was not kept as is")
+	}
+	merged := ma.Add("vac", second)
+	if merged == second {
+		t.Fatal("second run was not merged")
+	}
+
+	want := floorPixels(full)
+	if got := floorPixels(merged); math.Abs(float64(got-want)) > 0.1*float64(want) {
+		t.Errorf("merged floor = %d pixels, want about %d (first run %d)", got, want, floorPixels(first))
+	}
+	if merged.MetaData.TotalLayerArea != floorPixels(merged)*25 {
+		t.Errorf("TotalLayerArea = %d, want %d", merged.MetaData.TotalLayerArea, floorPixels(merged)*25)
+	}
+
+	// The robot, at (400, 500) in the second run's frame, is at (500, 400)
+	// in the first run's frame, facing 10° - 90°
+	pos, angle, ok := ExtractRobotPosition(merged)
+	if !ok {
+		t.Fatal("merged map lost the robot position")
+	}
+	if math.Hypot(pos.X/5-500, pos.Y/5-400) > 3 {
+		t.Errorf("robot at pixel (%.0f, %.0f), want (500, 400)", pos.X/5, pos.Y/5)
+	}
+	if angleDiff(angle, -80) > 2 {
+		t.Errorf("robot angle = %.1f, want -80", angle)
+	}
+
+	// A position-only update is moved into the accumulated frame too
+	update := &ValetudoMap{PixelSize: 5, Entities: cropMap(&ValetudoMap{PixelSize: 5}, nil, 400, 500, 10).Entities}
+	moved := ma.Add("vac", update)
+	if pos, _, _ := ExtractRobotPosition(moved); math.Hypot(pos.X/5-500, pos.Y/5-400) > 3 {
+		t.Errorf("position update at pixel (%.0f, %.0f), want (500, 400)", pos.X/5, pos.Y/5)
+	}
+	if floorPixels(moved) != floorPixels(merged) {
+		t.Error("position update changed the accumulated floor")
+	}
+}
+
+func TestMapAccumulator_SameRunReplacesItsPart(t *testing.T) {
+	full := rotatedRoom(0)
+	first := withCharger(cropMap(full, func(x, _ int) bool { return x < 460 }, 300, 400, 0), 450, 284)
+	second := withCharger(cropMap(rotatedRoom(90), func(_, y int) bool { return y > 380 && y < 520 }, 400, 500, 0), 516, 450)
+	grown := withCharger(cropMap(rotatedRoom(90), func(_, y int) bool { return y > 380 }, 400, 500, 0), 516, 450)
+
+	ma := accumulatorFor("vac")
+	ma.Add("vac", first)
+	partial := ma.Add("vac", second)
+	if partial == second {
+		t.Fatal("second run was not merged")
+	}
+	if pos, _, _ := ExtractRobotPosition(partial); math.Hypot(pos.X/5-500, pos.Y/5-400) > 3 {
+		t.Errorf("robot at pixel (%.0f, %.0f), want (500, 400)", pos.X/5, pos.Y/5)
+	}
+
+	// The run goes on and maps more; it still covers what it mapped before,
+	// so it replaces its earlier map instead of being aligned again
+	merged := ma.Add("vac", grown)
+	if got := floorPixels(merged); got <= floorPixels(partial) {
+		t.Errorf("grown run: %d floor pixels, want more than %d", got, floorPixels(partial))
+	}
+	again := ma.Add("vac", grown)
+	if floorPixels(again) != floorPixels(merged) {
+		t.Errorf("resending the run changed the floor from %d to %d pixels", floorPixels(merged), floorPixels(again))
+	}
+}
+
+func TestMapAccumulator_StartsOverOnMismatch(t *testing.T) {
+	ma := accumulatorFor("vac")
+	ma.Add("vac", rotatedRoom(0))
+
+	// A different pixel size cannot be aligned onto the accumulated map
+	other := rotatedRoom(0)
+	other.PixelSize = 10
+	for i := range other.Layers {
+		for j := range other.Layers[i].Pixels {
+			other.Layers[i].Pixels[j] /= 3
+		}
+	}
+	if got := ma.Add("vac", other); got != other {
+		t.Error("mismatched run was merged")
+	}
+}
+
+func TestMapAccumulator_Disabled(t *testing.T) {
+	ma := accumulatorFor("vac")
+	m := rotatedRoom(0)
+	if ma.Add("other", m) != m {
+		t.Error("vacuum without accumulate was changed")
+	}
+	var none *MapAccumulator
+	if none.Add("vac", m) != m || none.Enabled("vac") {
+		t.Error("nil accumulator changed the map")
+	}
+	none.Seed("vac", m)
+}
+
+func TestTransformLayerPixels_NoGaps(t *testing.T) {
+	var square []int
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			square = append(square, x, y)
+		}
+	}
+	moved := transformLayerPixels(square, CreateRotationTranslation(45, 100, 100))
+	// A rotated 20x20 square still covers about 400 pixels
+	if n := len(moved) / 2; n < 380 || n > 440 {
+		t.Errorf("rotated square covers %d pixels, want about 400", n)
+	}
+	if got := transformLayerPixels(square, Identity()); len(got) != len(square) {
+		t.Error("identity changed the pixels")
+	}
+}
@@ -0,0 +1,295 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"github.com/tdewolff/canvas"
+)
+
+// activeHighlightAlpha is the opacity of the vacuum's color over the area it
+// is cleaning in live renders.
+const activeHighlightAlpha = 90
+
+// ActiveArea is what a vacuum is cleaning right now, as flagged in its map
+// data: segments marked active and active_zone entities.
+type ActiveArea struct {
+	SegmentIDs []string  `json:"segmentIds,omitempty"`
+	Zones      [][]Point `json:"zones,omitempty"` // polygons in the vacuum's grid coordinates
+}
+
+// Empty reports whether the vacuum is cleaning no particular area.
+func (a ActiveArea) Empty() bool {
+	return len(a.SegmentIDs) == 0 && len(a.Zones) == 0
+}
+
+// Equal reports whether two active areas are the same.
+func (a ActiveArea) Equal(b ActiveArea) bool {
+	if !slices.Equal(a.SegmentIDs, b.SegmentIDs) || len(a.Zones) != len(b.Zones) {
+		return false
+	}
+	for i := range a.Zones {
+		if !slices.Equal(a.Zones[i], b.Zones[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtractActiveArea returns the segments and zones m flags as being cleaned.
+// Zone points are converted from millimeters to grid coordinates like the
+// robot position.
+func ExtractActiveArea(m *ValetudoMap) ActiveArea {
+	var area ActiveArea
+	if m == nil {
+		return area
+	}
+	for _, layer := range m.Layers {
+		if layer.Type == "segment" && layer.MetaData.Active && layer.MetaData.SegmentID != "" {
+			area.SegmentIDs = append(area.SegmentIDs, layer.MetaData.SegmentID)
+		}
+	}
+	sort.Strings(area.SegmentIDs)
+
+	pixelSize := float64(m.PixelSize)
+	if pixelSize == 0 {
+		pixelSize = 5
+	}
+	for _, entity := range m.Entities {
+		if entity.Type != "active_zone" || len(entity.Points) < 6 {
+			continue
+		}
+		zone := make([]Point, 0, len(entity.Points)/2)
+		for i := 0; i+1 < len(entity.Points); i += 2 {
+			zone = append(zone, Point{X: float64(entity.Points[i]) / pixelSize, Y: float64(entity.Points[i+1]) / pixelSize})
+		}
+		area.Zones = append(area.Zones, zone)
+	}
+	return area
+}
+
+// activeSegmentLayers returns the segment layers of m listed in area.
+func activeSegmentLayers(m *ValetudoMap, area ActiveArea) []*MapLayer {
+	var layers []*MapLayer
+	for i := range m.Layers {
+		layer := &m.Layers[i]
+		if layer.Type == "segment" && slices.Contains(area.SegmentIDs, layer.MetaData.SegmentID) {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+// zoneRing converts a zone polygon to a closed orb ring.
+func zoneRing(zone []Point) orb.Ring {
+	ring := make(orb.Ring, 0, len(zone)+1)
+	for _, p := range zone {
+		ring = append(ring, orb.Point{p.X, p.Y})
+	}
+	return append(ring, ring[0])
+}
+
+// CleaningTarget tells which unified rooms a vacuum is cleaning.
+type CleaningTarget struct {
+	VacuumID  string   `json:"vacuumId"`
+	Active    bool     `json:"active"`
+	Segments  []string `json:"segments"` // the vacuum's own names (or IDs) of its active segments
+	Rooms     []string `json:"rooms"`    // unified segment names the active area falls in
+	Zones     int      `json:"zones"`    // number of active zones
+	Timestamp int64    `json:"timestamp"`
+}
+
+// ResolveCleaningTarget maps a vacuum's active area onto the unified map.
+// Each active segment and zone is placed in world coordinates (mm) through
+// transform and assigned to the unified segment containing its centroid;
+// a segment outside every unified segment keeps its own name. m supplies
+// the segment pixels and may be nil, as may um.
+func ResolveCleaningTarget(vacuumID string, m *ValetudoMap, area ActiveArea, transform AffineMatrix, um *UnifiedMap) CleaningTarget {
+	target := CleaningTarget{
+		VacuumID:  vacuumID,
+		Active:    !area.Empty(),
+		Segments:  []string{},
+		Rooms:     []string{},
+		Zones:     len(area.Zones),
+		Timestamp: time.Now().Unix(),
+	}
+
+	pixelSize := 5.0
+	if m != nil && m.PixelSize > 0 {
+		pixelSize = float64(m.PixelSize)
+	}
+	toWorld := func(p Point) orb.Point {
+		tp := TransformPoint(p, transform)
+		return orb.Point{tp.X * pixelSize, tp.Y * pixelSize}
+	}
+
+	rooms := make(map[string]struct{})
+	addRoom := func(centroid orb.Point, fallback string) {
+		if name := unifiedSegmentAt(um, centroid); name != "" {
+			rooms[name] = struct{}{}
+		} else if fallback != "" {
+			rooms[fallback] = struct{}{}
+		}
+	}
+
+	seen := make(map[string]bool)
+	if m != nil {
+		for _, layer := range activeSegmentLayers(m, area) {
+			name := layer.MetaData.Name
+			if name == "" {
+				name = layer.MetaData.SegmentID
+			}
+			seen[layer.MetaData.SegmentID] = true
+			target.Segments = append(target.Segments, name)
+
+			points := PixelsToPoints(layer.Pixels)
+			if len(points) == 0 {
+				continue
+			}
+			var sum Point
+			for _, p := range points {
+				sum.X += p.X
+				sum.Y += p.Y
+			}
+			n := float64(len(points))
+			addRoom(toWorld(Point{X: sum.X / n, Y: sum.Y / n}), name)
+		}
+	}
+	// Segments the stored map does not have are reported by ID
+	for _, id := range area.SegmentIDs {
+		if !seen[id] {
+			target.Segments = append(target.Segments, id)
+		}
+	}
+
+	for _, zone := range area.Zones {
+		centroid, _ := planar.CentroidArea(zoneRing(zone))
+		addRoom(toWorld(Point{X: centroid[0], Y: centroid[1]}), "")
+	}
+
+	for name := range rooms {
+		target.Rooms = append(target.Rooms, name)
+	}
+	sort.Strings(target.Segments)
+	sort.Strings(target.Rooms)
+	return target
+}
+
+// unifiedSegmentAt returns the name of the unified segment containing p, or
+// "" when none does.
+func unifiedSegmentAt(um *UnifiedMap, p orb.Point) string {
+	if um == nil {
+		return ""
+	}
+	for _, seg := range um.Segments {
+		name, _ := seg.Properties["segmentName"].(string)
+		if name == "" {
+			continue
+		}
+		if poly := orbPolygon(seg.Geometry); len(poly) > 0 && planar.PolygonContains(poly, p) {
+			return name
+		}
+	}
+	return ""
+}
+
+// drawActive tints the segments and zones each vacuum is cleaning with its
+// robot color.
+func (r *CompositeRenderer) drawActive(img *image.RGBA, minX, minY, centerX, centerY float64) {
+	if len(r.Active) == 0 {
+		return
+	}
+	bounds := img.Bounds()
+	cover := r.pixelCover(minX, minY, centerX, centerY)
+
+	ids := make([]string, 0, len(r.Active))
+	for id := range r.Active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		m, area := r.Maps[id], r.Active[id]
+		if m == nil || area.Empty() {
+			continue
+		}
+		transform := r.Transforms[id]
+		c := r.Colors[id].Robot
+		tint := color.NRGBA{c.R, c.G, c.B, activeHighlightAlpha}
+
+		// Each image pixel is tinted once even when several cells cover it
+		tinted := make(map[image.Point]bool)
+		paint := func(p Point) {
+			cover(TransformPoint(p, transform), func(ix, iy int) {
+				pt := image.Point{ix, iy}
+				if !pt.In(bounds) || tinted[pt] {
+					return
+				}
+				tinted[pt] = true
+				img.Set(ix, iy, blendColors(img.RGBAAt(ix, iy), tint))
+			})
+		}
+
+		for _, layer := range activeSegmentLayers(m, area) {
+			for _, p := range PixelsToPoints(layer.Pixels) {
+				forEachBlockCell(p, m.CellBlock(), paint)
+			}
+		}
+		for _, zone := range area.Zones {
+			ring := zoneRing(zone)
+			b := ring.Bound()
+			for y := math.Floor(b.Min[1]); y <= b.Max[1]; y++ {
+				for x := math.Floor(b.Min[0]); x <= b.Max[0]; x++ {
+					if planar.RingContains(ring, orb.Point{x, y}) {
+						paint(Point{X: x, Y: y})
+					}
+				}
+			}
+		}
+	}
+}
+
+// renderActive fills the segments and zones each vacuum is cleaning with a
+// translucent layer of its robot color.
+func (r *VectorRenderer) renderActive(renderer canvasRenderer, toCanvas func(Point) (float64, float64)) {
+	ids := make([]string, 0, len(r.Active))
+	for id := range r.Active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		m, area := r.Maps[id], r.Active[id]
+		if m == nil || area.Empty() {
+			continue
+		}
+		transform := r.Transforms[id]
+		project := func(pt Point) (float64, float64) {
+			tp := TransformPoint(pt, transform)
+			return toCanvas(Point{X: tp.X * float64(m.PixelSize), Y: tp.Y * float64(m.PixelSize)})
+		}
+		c := r.Colors[id].Robot
+		style := canvas.DefaultStyle
+		style.Fill = canvas.Paint{Color: nrgbaToRGBA(color.NRGBA{c.R, c.G, c.B, activeHighlightAlpha})}
+		style.Stroke = canvas.Paint{Color: canvas.Transparent}
+		style.FillRule = canvas.EvenOdd
+
+		for _, layer := range activeSegmentLayers(m, area) {
+			paths := VectorizeMapLayer(m, layer, 5.0)
+			renderer.RenderPath(floorCanvasPath(paths, project), style, canvas.Identity)
+		}
+		if len(area.Zones) > 0 {
+			zones := make([]Path, len(area.Zones))
+			for i, zone := range area.Zones {
+				zones[i] = Path(zone)
+			}
+			renderer.RenderPath(floorCanvasPath(zones, project), style, canvas.Identity)
+		}
+	}
+}
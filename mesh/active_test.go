@@ -0,0 +1,163 @@
+package mesh
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// activeTestMap returns a 40x20 floor split into two segments; the right
+// one ("2", Kitchen) is being cleaned and a 10x10 zone lies in the left one.
+func activeTestMap() *ValetudoMap {
+	var left, right []int
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			left = append(left, x, y)
+			right = append(right, x+20, y)
+		}
+	}
+	return &ValetudoMap{
+		PixelSize: 5,
+		MetaData:  MapMetaData{TotalLayerArea: 800},
+		Layers: []MapLayer{
+			{Type: "segment", Pixels: left, MetaData: LayerMetaData{SegmentID: "1", Name: "Hall"}},
+			{Type: "segment", Pixels: right, MetaData: LayerMetaData{SegmentID: "2", Name: "Küche", Active: true}},
+		},
+		Entities: []MapEntity{
+			{Type: "robot_position", Points: []int{150, 50}},
+			{Type: "active_zone", Points: []int{25, 25, 75, 25, 75, 75, 25, 75}},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ExtractActiveArea
+// ---------------------------------------------------------------------------
+
+func TestExtractActiveArea(t *testing.T) {
+	area := ExtractActiveArea(activeTestMap())
+	if !reflect.DeepEqual(area.SegmentIDs, []string{"2"}) {
+		t.Errorf("SegmentIDs = %v, want [2]", area.SegmentIDs)
+	}
+	wantZone := []Point{{5, 5}, {15, 5}, {15, 15}, {5, 15}}
+	if len(area.Zones) != 1 || !reflect.DeepEqual(area.Zones[0], wantZone) {
+		t.Errorf("Zones = %v, want [%v]", area.Zones, wantZone)
+	}
+
+	if idle := ExtractActiveArea(&ValetudoMap{PixelSize: 5}); !idle.Empty() {
+		t.Errorf("idle map area = %+v, want empty", idle)
+	}
+}
+
+func TestStateTracker_SetActiveArea(t *testing.T) {
+	st := NewStateTracker()
+	area := ExtractActiveArea(activeTestMap())
+
+	if !st.SetActiveArea("vac1", area) {
+		t.Error("first active area not reported as changed")
+	}
+	if st.SetActiveArea("vac1", ExtractActiveArea(activeTestMap())) {
+		t.Error("same active area reported as changed")
+	}
+	if got := st.GetActiveAreas()["vac1"]; !got.Equal(area) {
+		t.Errorf("GetActiveAreas = %+v, want %+v", got, area)
+	}
+	if !st.SetActiveArea("vac1", ActiveArea{}) {
+		t.Error("finishing the clean not reported as changed")
+	}
+	if len(st.GetActiveAreas()) != 0 {
+		t.Error("idle vacuum still listed as active")
+	}
+	if st.SetActiveArea("vac2", ActiveArea{}) {
+		t.Error("idle vacuum reported as changed")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ResolveCleaningTarget
+// ---------------------------------------------------------------------------
+
+func TestResolveCleaningTarget(t *testing.T) {
+	m := activeTestMap()
+	area := ExtractActiveArea(m)
+	// Unified rooms in mm: the left half is "Hall", the right "Kitchen"
+	um := &UnifiedMap{Segments: []*UnifiedFeature{
+		{Geometry: PathToPolygon(Path{{0, 0}, {100, 0}, {100, 100}, {0, 100}}), Properties: map[string]interface{}{"segmentName": "Hall"}},
+		{Geometry: PathToPolygon(Path{{100, 0}, {200, 0}, {200, 100}, {100, 100}}), Properties: map[string]interface{}{"segmentName": "Kitchen"}},
+	}}
+
+	target := ResolveCleaningTarget("vac1", m, area, Identity(), um)
+	if !target.Active || target.Zones != 1 {
+		t.Errorf("active/zones = %v/%d, want true/1", target.Active, target.Zones)
+	}
+	if !reflect.DeepEqual(target.Segments, []string{"Küche"}) {
+		t.Errorf("Segments = %v, want [Küche]", target.Segments)
+	}
+	if !reflect.DeepEqual(target.Rooms, []string{"Hall", "Kitchen"}) {
+		t.Errorf("Rooms = %v, want [Hall Kitchen]", target.Rooms)
+	}
+
+	// Without a unified map the vacuum's own segment name stands in
+	target = ResolveCleaningTarget("vac1", m, area, Identity(), nil)
+	if !reflect.DeepEqual(target.Rooms, []string{"Küche"}) {
+		t.Errorf("Rooms without unified map = %v, want [Küche]", target.Rooms)
+	}
+
+	idle := ResolveCleaningTarget("vac1", m, ActiveArea{}, Identity(), um)
+	if idle.Active || len(idle.Rooms) != 0 || idle.Segments == nil {
+		t.Errorf("idle target = %+v, want inactive with empty lists", idle)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Live rendering
+// ---------------------------------------------------------------------------
+
+func TestRenderLive_TintsActiveArea(t *testing.T) {
+	m := activeTestMap()
+	render := func(active map[string]ActiveArea) (inside, outside [4]uint8) {
+		r := NewCompositeRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+		r.Legend.Hidden = true
+		r.Active = active
+		img := r.RenderLive(nil)
+		// Segment "2" spans grid x 20-39, the zone x 5-15, the rest of
+		// segment "1" is untouched around x 18
+		pixel := func(gx, gy int) [4]uint8 {
+			c := img.RGBAAt(gx+r.Padding, gy+r.Padding)
+			return [4]uint8{c.R, c.G, c.B, c.A}
+		}
+		if z := pixel(10, 10); active != nil && z == pixel(18, 2) {
+			t.Errorf("zone pixel %v not tinted", z)
+		}
+		return pixel(30, 10), pixel(18, 2)
+	}
+
+	plainInside, _ := render(nil)
+	inside, outside := render(map[string]ActiveArea{"vac1": ExtractActiveArea(m)})
+	if inside == plainInside {
+		t.Errorf("active segment pixel %v not tinted", inside)
+	}
+	if outside != plainInside {
+		t.Errorf("inactive segment pixel %v, want untinted %v", outside, plainInside)
+	}
+}
+
+func TestRenderLiveToSVG_TintsActiveArea(t *testing.T) {
+	m := activeTestMap()
+	r := NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+
+	var plain bytes.Buffer
+	if err := r.RenderLiveToSVG(&plain, nil); err != nil {
+		t.Fatal(err)
+	}
+	r.Active = map[string]ActiveArea{"vac1": ExtractActiveArea(m)}
+	var active bytes.Buffer
+	if err := r.RenderLiveToSVG(&active, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.Count(active.String(), "<path"), strings.Count(plain.String(), "<path")+2; got != want {
+		t.Errorf("paths = %d, want %d (segment and zone highlights)", got, want)
+	}
+}
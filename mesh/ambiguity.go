@@ -0,0 +1,120 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+)
+
+// Rotation ambiguity: in a symmetric room several rotations align about
+// equally well. A rotation more than ambiguityMinTurn degrees from the best
+// whose score is within ambiguityMargin of it is kept as an alternative.
+// Room borders resolve the ambiguity when one hypothesis matches them by
+// ambiguityMargin more than any other, within segmentBorderTolerance pixels.
+const (
+	ambiguityMargin        = 0.05
+	ambiguityMinTurn       = 10.0
+	ambiguityMinScore      = 0.05 // alternatives scoring lower are no match at all
+	segmentBorderTolerance = 10.0
+	segmentBorderMinPoints = 20
+	segmentBorderSample    = 300
+)
+
+// alternativeRotations returns the results of tried, the alignments of the
+// rotation sweep, that score about as well as best but at a clearly
+// different rotation, best first, one per rotation.
+func alternativeRotations(tried []ICPResult, best ICPResult) []ICPResult {
+	var alternatives []ICPResult
+	distinct := func(r ICPResult) bool {
+		rotation := TransformRotation(r.Transform)
+		for _, other := range append([]ICPResult{best}, alternatives...) {
+			if math.Abs(signedDegrees(rotation-TransformRotation(other.Transform))) <= ambiguityMinTurn {
+				return false
+			}
+		}
+		return true
+	}
+	for _, r := range sortedByScore(tried) {
+		if r.Score >= ambiguityMinScore && r.Score >= best.Score-ambiguityMargin && distinct(r) {
+			alternatives = append(alternatives, r)
+		}
+	}
+	return alternatives
+}
+
+// sortedByScore returns a copy of results, highest score first.
+func sortedByScore(results []ICPResult) []ICPResult {
+	sorted := append([]ICPResult(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	return sorted
+}
+
+// resolveBySegments scores each hypothesis by how well it puts the room
+// borders of source on those of target, and returns the index of the one
+// that clearly matches best. ok is false when either map has too few room
+// borders or no hypothesis stands out.
+func resolveBySegments(source, target *ValetudoMap, hypotheses []ICPResult) (int, bool) {
+	sourceBorders, _ := extractBorders(source)
+	targetBorders, _ := extractBorders(target)
+	if len(sourceBorders) < segmentBorderMinPoints || len(targetBorders) < segmentBorderMinPoints {
+		return 0, false
+	}
+	sourceBorders = samplePointSlice(sourceBorders, segmentBorderSample)
+	targetBorders = samplePointSlice(targetBorders, segmentBorderSample)
+
+	best, bestScore, runnerUp := -1, math.Inf(-1), math.Inf(-1)
+	for i, h := range hypotheses {
+		score, _, _ := CalculateInlierScore(TransformPoints(sourceBorders, h.Transform), targetBorders, segmentBorderTolerance)
+		switch {
+		case score > bestScore:
+			best, bestScore, runnerUp = i, score, bestScore
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+	if best < 0 || bestScore-runnerUp < ambiguityMargin {
+		return 0, false
+	}
+	return best, true
+}
+
+// rotationHypotheses returns the alternatives as kept in the calibration.
+func rotationHypotheses(alternatives []ICPResult) []RotationHypothesis {
+	if len(alternatives) == 0 {
+		return nil
+	}
+	hypotheses := make([]RotationHypothesis, len(alternatives))
+	for i, r := range alternatives {
+		hypotheses[i] = RotationHypothesis{Transform: r.Transform, Rotation: TransformRotation(r.Transform), Score: r.Score}
+	}
+	return hypotheses
+}
+
+// composeHypotheses returns the hypotheses with each transform composed
+// with right, as a calibrated transform is (see MirrorMap).
+func composeHypotheses(hypotheses []RotationHypothesis, right AffineMatrix) []RotationHypothesis {
+	if len(hypotheses) == 0 {
+		return nil
+	}
+	composed := make([]RotationHypothesis, len(hypotheses))
+	for i, h := range hypotheses {
+		h.Transform = MultiplyMatrices(h.Transform, right)
+		h.Rotation = TransformRotation(h.Transform)
+		composed[i] = h
+	}
+	return composed
+}
+
+// rebaseHypotheses returns the hypotheses with left applied after each
+// transform, re-expressing them in another frame (see Rebase).
+func rebaseHypotheses(left AffineMatrix, hypotheses []RotationHypothesis) []RotationHypothesis {
+	if len(hypotheses) == 0 {
+		return nil
+	}
+	rebased := make([]RotationHypothesis, len(hypotheses))
+	for i, h := range hypotheses {
+		h.Transform = MultiplyMatrices(left, h.Transform)
+		h.Rotation = TransformRotation(h.Transform)
+		rebased[i] = h
+	}
+	return rebased
+}
@@ -0,0 +1,101 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// squareRoom returns a square room of walls, the same under quarter turns
+// about (50, 50). With split it is two rooms, split a third of the way
+// across, which tells the turns apart.
+func squareRoom(split bool) *ValetudoMap {
+	var walls, west, east []int
+	for y := 0; y <= 100; y++ {
+		for x := 0; x <= 100; x++ {
+			switch {
+			case x == 0 || y == 0 || x == 100 || y == 100:
+				walls = append(walls, x, y)
+			case split && x <= 32:
+				west = append(west, x, y)
+			default:
+				east = append(east, x, y)
+			}
+		}
+	}
+	layers := []MapLayer{{Type: "wall", Pixels: walls}, {Type: "segment", Pixels: east, MetaData: LayerMetaData{SegmentID: "2"}}}
+	if split {
+		layers = append(layers, MapLayer{Type: "segment", Pixels: west, MetaData: LayerMetaData{SegmentID: "1"}})
+	}
+	return &ValetudoMap{PixelSize: 5, Layers: layers}
+}
+
+// turnAbout returns the rotation by deg about p.
+func turnAbout(deg float64, p Point) AffineMatrix {
+	return MultiplyMatrices(Translation(p.X, p.Y), MultiplyMatrices(RotationDeg(deg), Translation(-p.X, -p.Y)))
+}
+
+func TestAlternativeRotations(t *testing.T) {
+	best := ICPResult{Transform: RotationDeg(0), Score: 0.80}
+	tried := []ICPResult{
+		best,
+		{Transform: RotationDeg(90), Score: 0.40},  // clearly worse
+		{Transform: RotationDeg(180), Score: 0.77}, // about as good
+		{Transform: RotationDeg(185), Score: 0.78}, // the same hypothesis again
+		{Transform: RotationDeg(4), Score: 0.79},   // the best one again
+	}
+	got := alternativeRotations(tried, best)
+	if len(got) != 1 || math.Abs(TransformRotation(got[0].Transform)-185) > 1e-9 {
+		t.Fatalf("alternatives = %+v, want the 185° one", got)
+	}
+	if h := rotationHypotheses(got); len(h) != 1 || math.Abs(h[0].Rotation-185) > 1e-9 || h[0].Score != 0.78 {
+		t.Errorf("hypotheses = %+v", h)
+	}
+}
+
+func TestResolveBySegments(t *testing.T) {
+	center := Point{X: 50, Y: 50}
+	target := squareRoom(true)
+	source, _ := MirrorMap(target, MirrorX) // the split on the other side
+	hypotheses := []ICPResult{
+		{Transform: Identity()},
+		{Transform: turnAbout(180, center)},
+	}
+	if i, ok := resolveBySegments(target, target, hypotheses); !ok || i != 0 {
+		t.Errorf("the same map: picked %d, ok=%v; want the identity", i, ok)
+	}
+	if i, ok := resolveBySegments(source, target, hypotheses); !ok || i != 1 {
+		t.Errorf("the split on the other side: picked %d, ok=%v; want the half turn", i, ok)
+	}
+	if _, ok := resolveBySegments(squareRoom(false), squareRoom(false), hypotheses); ok {
+		t.Error("resolved without room borders")
+	}
+}
+
+func TestAlignMaps_AmbiguousRotation(t *testing.T) {
+	// Pre-alignment may settle on one turn; the sweep tries them all
+	config := DefaultICPConfig()
+	config.PreAlign = false
+	result := AlignMaps(squareRoom(false), squareRoom(false), config)
+	if len(result.Alternatives) == 0 || result.Disambiguated {
+		t.Fatalf("alternatives = %+v, disambiguated = %v; want the other turns of the square", result.Alternatives, result.Disambiguated)
+	}
+
+	result = AlignMaps(squareRoom(true), squareRoom(true), config)
+	if len(result.Alternatives) != 0 || !result.Disambiguated {
+		t.Fatalf("split: alternatives = %+v, disambiguated = %v; want room borders to pick one", result.Alternatives, result.Disambiguated)
+	}
+	if r := math.Abs(signedDegrees(TransformRotation(result.Transform))); r > 2 {
+		t.Errorf("split: rotation = %.1f°, want 0", r)
+	}
+}
+
+func TestComposeHypotheses(t *testing.T) {
+	h := []RotationHypothesis{{Transform: RotationDeg(90), Rotation: 90, Score: 0.5}}
+	got := composeHypotheses(h, AffineMatrix{A: -1, D: 1})
+	if !IsMirrored(got[0].Transform) || got[0].Score != 0.5 || h[0].Transform != RotationDeg(90) {
+		t.Errorf("composed = %+v, original %+v", got, h)
+	}
+	if composeHypotheses(nil, Identity()) != nil {
+		t.Error("composed nothing into something")
+	}
+}
@@ -0,0 +1,91 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Map layers are plotted pixel by pixel so every grid cell stays crisp. The
+// markers and lines drawn over them are filled as vector shapes instead:
+// positions are continuous image coordinates (pixel (x, y) spans x..x+1,
+// y..y+1) and edges are antialiased from the exact pixel coverage.
+
+// fillPolygons composites c over img inside the union of the closed
+// polygons. Only the rectangle bounding the polygons is rasterized.
+func fillPolygons(img *image.RGBA, c color.RGBA, polygons ...[]Point) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polygons {
+		for _, p := range poly {
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minX, 0) || math.IsNaN(minX+minY+maxX+maxY) {
+		return
+	}
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(img.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	z := vector.NewRasterizer(r.Dx(), r.Dy())
+	ox, oy := float64(r.Min.X), float64(r.Min.Y)
+	for _, poly := range polygons {
+		if len(poly) < 3 {
+			continue
+		}
+		z.MoveTo(float32(poly[0].X-ox), float32(poly[0].Y-oy))
+		for _, p := range poly[1:] {
+			z.LineTo(float32(p.X-ox), float32(p.Y-oy))
+		}
+		z.ClosePath()
+	}
+	z.Draw(img, r, image.NewUniform(c), image.Point{})
+}
+
+// circlePolygon approximates a circle with enough segments that no edge
+// strays more than a fraction of a pixel from the true curve.
+func circlePolygon(cx, cy, radius float64) []Point {
+	n := max(16, int(math.Ceil(2*math.Pi*radius/2)))
+	poly := make([]Point, n)
+	for i := range poly {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		poly[i] = Point{X: cx + radius*math.Cos(a), Y: cy + radius*math.Sin(a)}
+	}
+	return poly
+}
+
+// linePolygon returns the rectangle covering a line of the given width
+// from (x0, y0) to (x1, y1), or nil for a zero-length line.
+func linePolygon(x0, y0, x1, y1, width float64) []Point {
+	length := math.Hypot(x1-x0, y1-y0)
+	if length == 0 {
+		return nil
+	}
+	// Half-width normal to the line
+	nx := -(y1 - y0) / length * width / 2
+	ny := (x1 - x0) / length * width / 2
+	return []Point{
+		{X: x0 + nx, Y: y0 + ny},
+		{X: x1 + nx, Y: y1 + ny},
+		{X: x1 - nx, Y: y1 - ny},
+		{X: x0 - nx, Y: y0 - ny},
+	}
+}
+
+// drawLine draws an antialiased line of the given width.
+func drawLine(img *image.RGBA, x0, y0, x1, y1, width float64, c color.RGBA) {
+	if poly := linePolygon(x0, y0, x1, y1, width); poly != nil {
+		fillPolygons(img, c, poly)
+	}
+}
+
+// pixelCenter returns the continuous image coordinates of the center of
+// pixel (x, y), for placing a marker on a pixel computed by integer math.
+func pixelCenter(x, y int) (float64, float64) {
+	return float64(x) + 0.5, float64(y) + 0.5
+}
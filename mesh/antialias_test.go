@@ -0,0 +1,79 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawCircle_Antialiased(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	drawCircle(img, 10, 10, 5, red)
+
+	if got := img.RGBAAt(10, 10); got != red {
+		t.Errorf("center pixel = %v, want %v", got, red)
+	}
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{}) {
+		t.Errorf("pixel outside circle = %v, want untouched", got)
+	}
+
+	// The edge passes through pixels diagonal from the center, which are
+	// only partly covered
+	edge := img.RGBAAt(13, 13)
+	if edge.A == 0 || edge.A == 255 {
+		t.Errorf("edge pixel alpha = %d, want partial coverage", edge.A)
+	}
+}
+
+func TestDrawSquare_Subpixel(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	aligned := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	drawSquare(aligned, 5, 5, 4, red)
+	if got := aligned.RGBAAt(3, 5); got != red {
+		t.Errorf("pixel-aligned edge = %v, want fully covered", got)
+	}
+	if got := aligned.RGBAAt(2, 5); got != (color.RGBA{}) {
+		t.Errorf("pixel past the edge = %v, want untouched", got)
+	}
+
+	// Half a pixel to the right, the edge pixels are half covered
+	shifted := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	drawSquare(shifted, 5.5, 5, 4, red)
+	for _, x := range []int{3, 7} {
+		if a := shifted.RGBAAt(x, 5).A; a < 120 || a > 136 {
+			t.Errorf("pixel %d alpha = %d, want about half", x, a)
+		}
+	}
+}
+
+func TestFillPolygons_Clipped(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	// Mostly off the top-left corner; must not panic
+	drawCircle(img, 0, 0, 4, red)
+	drawCircle(img, -50, -50, 4, red)
+	drawLine(img, 1, 1, 1, 1, 2, red)
+
+	if got := img.RGBAAt(0, 0); got != red {
+		t.Errorf("corner pixel = %v, want %v", got, red)
+	}
+	if got := img.RGBAAt(9, 9); got != (color.RGBA{}) {
+		t.Errorf("far pixel = %v, want untouched", got)
+	}
+}
+
+func TestDrawLine(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	drawLine(img, 1, 5, 9, 5, 2, black)
+
+	for x := 1; x < 9; x++ {
+		if got := img.RGBAAt(x, 4); got != black {
+			t.Errorf("pixel (%d, 4) = %v, want %v", x, got, black)
+		}
+	}
+	if got := img.RGBAAt(5, 2); got != (color.RGBA{}) {
+		t.Errorf("pixel off the line = %v, want untouched", got)
+	}
+}
@@ -1,12 +1,10 @@
 package mesh
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,32 +17,45 @@ const (
 // AutoCalibrator orchestrates automatic calibration when a vacuum docks.
 // It debounces frequent docking events, fetches a fresh map from the robot's
 // HTTP API, validates the map, runs ICP alignment, and persists the result.
+//
+// The calibration it holds is shared with readers on other goroutines, so it
+// is never changed in place: each update swaps in a changed copy. Readers
+// load it without taking mu, so they never wait for a running ICP.
 type AutoCalibrator struct {
 	config       *Config
-	cache        *CalibrationData
-	cachePath    string
-	dataDir      string
+	cache        atomic.Pointer[CalibrationData] // replaced, never changed, under mu
+	store        Store
 	stateTracker *StateTracker
 
 	mu             sync.Mutex
 	lastCalibrated map[string]time.Time
+	onCalibrated   func(*CalibrationData)
+	accumulator    *MapAccumulator
 }
 
 // NewAutoCalibrator creates an AutoCalibrator ready to handle docking events.
+// Calibration is persisted to cachePath and fetched maps are saved in dataDir
+// (an empty dataDir disables map saving).
 func NewAutoCalibrator(config *Config, cache *CalibrationData, cachePath string, dataDir string, st *StateTracker) *AutoCalibrator {
+	return NewAutoCalibratorWithStore(config, cache, NewFileStore(dataDir, cachePath), st)
+}
+
+// NewAutoCalibratorWithStore creates an AutoCalibrator that persists
+// calibration and fetched maps through the given store.
+func NewAutoCalibratorWithStore(config *Config, cache *CalibrationData, store Store, st *StateTracker) *AutoCalibrator {
 	if cache == nil {
 		cache = &CalibrationData{
 			Vacuums: make(map[string]VacuumCalibration),
 		}
 	}
-	return &AutoCalibrator{
+	ac := &AutoCalibrator{
 		config:         config,
-		cache:          cache,
-		cachePath:      cachePath,
-		dataDir:        dataDir,
+		store:          store,
 		stateTracker:   st,
 		lastCalibrated: make(map[string]time.Time),
 	}
+	ac.cache.Store(cache)
+	return ac
 }
 
 // OnDockingEvent is the DockingHandler callback registered with the MQTT client.
@@ -56,58 +67,124 @@ func (ac *AutoCalibrator) OnDockingEvent(vacuumID string) {
 	log.Printf("[AUTO-CAL] Docking event received for %s", vacuumID)
 
 	// --- Step 1: Debounce ---
+	// We pass 0 for newMapArea here because we haven't fetched the map yet;
+	// ShouldRecalibrate will still fire on time-based expiry or missing entry.
+	if !ac.due(vacuumID, 0) {
+		return
+	}
+
+	if err := ac.calibrate(vacuumID); err != nil {
+		log.Printf("[AUTO-CAL] %s: %v", vacuumID, err)
+	}
+}
+
+// OnMapPushed calibrates a vacuum from m, a map pushed to the service over
+// HTTP, with the same debounce as a docking event. Robots that push their
+// maps may have no apiUrl to fetch one from, so m is aligned as it is. It is
+// safe to call from any goroutine.
+func (ac *AutoCalibrator) OnMapPushed(vacuumID string, m *ValetudoMap) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	log.Printf("[AUTO-CAL] Pushed map received for %s", vacuumID)
+	if !ac.due(vacuumID, m.MetaData.TotalLayerArea) {
+		return
+	}
+
+	trace := StartTrace("calibration " + vacuumID)
+	defer trace.End()
+	vc := ac.config.GetVacuumByID(vacuumID)
+	if vc == nil {
+		log.Printf("[AUTO-CAL] %s: vacuum not found in config, skipping", vacuumID)
+		return
+	}
+	if vc.Frozen && ac.cache.Load().GetVacuumCalibration(vacuumID) != nil {
+		log.Printf("[AUTO-CAL] %s: transform is frozen in config, skipping (preserving existing calibration)", vacuumID)
+		return
+	}
+	if err := ac.calibrateMap(vacuumID, vc, m, trace); err != nil {
+		log.Printf("[AUTO-CAL] %s: %v", vacuumID, err)
+	}
+}
+
+// due reports whether a vacuum whose map covers newMapArea should be
+// calibrated again, logging why not. Callers must hold ac.mu.
+func (ac *AutoCalibrator) due(vacuumID string, newMapArea int) bool {
 	if last, ok := ac.lastCalibrated[vacuumID]; ok {
 		if time.Since(last) < DefaultMinCalibrationInterval {
 			log.Printf("[AUTO-CAL] %s: skipping, last calibrated %s ago (min interval %s)",
 				vacuumID, time.Since(last).Round(time.Second), DefaultMinCalibrationInterval)
-			return
+			return false
 		}
 	}
 
-	// Also check the cache-level debounce (covers restarts).
-	// We pass 0 for newMapArea here because we haven't fetched the map yet;
-	// ShouldRecalibrate will still fire on time-based expiry or missing entry.
-	if !ac.cache.ShouldRecalibrate(vacuumID, ac.cachedMapArea(vacuumID), DefaultMinCalibrationInterval) {
+	// Also check the cache-level debounce (covers restarts)
+	if !ac.cache.Load().ShouldRecalibrate(vacuumID, newMapArea, DefaultMinCalibrationInterval) {
 		log.Printf("[AUTO-CAL] %s: skipping, cache says recalibration not needed", vacuumID)
-		return
+		return false
 	}
+	return true
+}
+
+// Calibrate immediately fetches a fresh map for the vacuum and re-runs
+// alignment, bypassing the docking debounce. It is used for on-demand
+// calibration requests.
+func (ac *AutoCalibrator) Calibrate(vacuumID string) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	log.Printf("[AUTO-CAL] Calibration requested for %s", vacuumID)
+	return ac.calibrate(vacuumID)
+}
+
+// calibrate performs steps 2-8 of the calibration pipeline. Callers must
+// hold ac.mu. Any error leaves the existing calibration untouched.
+func (ac *AutoCalibrator) calibrate(vacuumID string) error {
+	trace := StartTrace("calibration " + vacuumID)
+	defer trace.End()
 
 	// --- Step 2: Look up vacuum config for API URL ---
 	vc := ac.config.GetVacuumByID(vacuumID)
 	if vc == nil {
-		log.Printf("[AUTO-CAL] %s: vacuum not found in config, skipping", vacuumID)
-		return
+		return fmt.Errorf("vacuum not found in config, skipping")
+	}
+	if vc.Frozen && ac.cache.Load().GetVacuumCalibration(vacuumID) != nil {
+		return fmt.Errorf("transform is frozen in config, skipping (preserving existing calibration)")
 	}
 	if vc.ApiURL == nil || *vc.ApiURL == "" {
-		log.Printf("[AUTO-CAL] %s: no apiUrl configured, skipping auto-calibration", vacuumID)
-		return
+		return fmt.Errorf("no apiUrl configured, skipping auto-calibration")
 	}
 
 	// --- Step 3: Fetch fresh map from the robot's HTTP API ---
 	log.Printf("[AUTO-CAL] %s: fetching map from %s", vacuumID, *vc.ApiURL)
+	trace.Step("fetch")
 	freshMap, err := FetchMapFromAPI(*vc.ApiURL)
 	if err != nil {
-		log.Printf("[AUTO-CAL] %s: failed to fetch map: %v (preserving existing calibration)", vacuumID, err)
-		return
+		return fmt.Errorf("failed to fetch map: %w (preserving existing calibration)", err)
 	}
+	// A vacuum mapping in sections is calibrated by its accumulated map
+	freshMap = ac.accumulator.Add(vacuumID, freshMap)
 
-	// Save fetched map to data-dir for persistence (same convention as MQTT handler).
-	if ac.dataDir != "" {
-		savePath := filepath.Join(ac.dataDir, fmt.Sprintf("ValetudoMapExport-%s.json", vacuumID))
-		jsonBytes, err := json.MarshalIndent(freshMap, "", "  ")
-		if err != nil {
-			log.Printf("[AUTO-CAL] %s: failed to marshal map for saving: %v", vacuumID, err)
-		} else if err := os.WriteFile(savePath, jsonBytes, 0644); err != nil {
-			log.Printf("[AUTO-CAL] %s: failed to save map to %s: %v", vacuumID, savePath, err)
-		} else {
-			log.Printf("[AUTO-CAL] %s: saved HTTP-fetched map to %s", vacuumID, savePath)
-		}
+	// Save fetched map to the store for persistence (same convention as MQTT
+	// handler), unless merging downsampled runs downsampled it.
+	trace.Step("save")
+	if freshMap.Downsample > 1 {
+		log.Printf("[AUTO-CAL] %s: accumulated map is downsampled; not saving it", vacuumID)
+	} else if err := ac.store.SaveMap(vacuumID, freshMap); err != nil {
+		log.Printf("[AUTO-CAL] %s: failed to save map to %s: %v", vacuumID, ac.store, err)
+	} else {
+		log.Printf("[AUTO-CAL] %s: saved HTTP-fetched map to %s", vacuumID, ac.store)
 	}
+	return ac.calibrateMap(vacuumID, vc, freshMap, trace)
+}
 
+// calibrateMap performs steps 4-8 of the calibration pipeline on freshMap,
+// the vacuum's current map. Callers must hold ac.mu.
+func (ac *AutoCalibrator) calibrateMap(vacuumID string, vc *VacuumConfig, freshMap *ValetudoMap, trace *Trace) error {
 	// --- Step 4: Validate map completeness ---
+	trace.Step("validate")
 	if err := ValidateMapForCalibration(freshMap); err != nil {
-		log.Printf("[AUTO-CAL] %s: map validation failed: %v (preserving existing calibration)", vacuumID, err)
-		return
+		return fmt.Errorf("map validation failed: %w (preserving existing calibration)", err)
 	}
 	log.Printf("[AUTO-CAL] %s: map validated (area=%d, layers=%d, entities=%d)",
 		vacuumID, freshMap.MetaData.TotalLayerArea, len(freshMap.Layers), len(freshMap.Entities))
@@ -118,44 +195,62 @@ func (ac *AutoCalibrator) OnDockingEvent(vacuumID string) {
 	// --- Step 5: Determine reference vacuum ---
 	referenceID := ac.resolveReference()
 	if referenceID == "" {
-		log.Printf("[AUTO-CAL] %s: no reference vacuum available, skipping", vacuumID)
-		return
+		return fmt.Errorf("no reference vacuum available, skipping")
 	}
 
 	// If the docked vacuum IS the reference, we just need to update its entry.
 	if vacuumID == referenceID {
 		log.Printf("[AUTO-CAL] %s: is the reference vacuum, updating identity entry", vacuumID)
-		ac.cache.UpdateVacuumCalibration(vacuumID, VacuumCalibration{
-			Transform:            Identity(),
-			LastUpdated:          time.Now().Unix(),
-			MapAreaAtCalibration: freshMap.MetaData.TotalLayerArea,
+		if vc.Mirror != "" {
+			log.Printf("[AUTO-CAL] %s: mirror is ignored for the reference vacuum, whose frame is the world's", vacuumID)
+		}
+		ac.update(func(cal *CalibrationData) {
+			cal.UpdateVacuumCalibration(vacuumID, VacuumCalibration{
+				Transform:            Identity(),
+				LastUpdated:          time.Now().Unix(),
+				MapAreaAtCalibration: freshMap.MetaData.TotalLayerArea,
+			})
 		})
+		trace.Step("persist")
 		ac.persistAndRecord(vacuumID)
-		return
+		return nil
 	}
 
-	// --- Step 6: Get reference map ---
-	allMaps := ac.stateTracker.GetMaps()
-	refMap, ok := allMaps[referenceID]
-	if !ok {
-		log.Printf("[AUTO-CAL] %s: reference vacuum %s has no map data, skipping", vacuumID, referenceID)
-		return
+	// --- Step 6: Get the alignment target ---
+	trace.Step("target")
+	// The unified map's consensus walls are preferred over the reference
+	// map alone, so a vacuum that barely overlaps the reference still
+	// aligns where it overlaps other robots. It is on the reference grid.
+	refMap := ac.stateTracker.GetMaps()[referenceID]
+	var target *ValetudoMap
+	targetName := "reference " + referenceID
+	if ac.config.ICP.alignUnified() {
+		pixelSize := defaultPixelSize
+		if refMap != nil && refMap.PixelSize > 0 {
+			pixelSize = refMap.PixelSize
+		}
+		if target = UnifiedTargetMap(ac.stateTracker.GetUnifiedMap(), pixelSize, vacuumID); target != nil {
+			targetName = "the unified map"
+		}
+	}
+	if target == nil {
+		if refMap == nil {
+			return fmt.Errorf("reference vacuum %s has no map data, skipping", referenceID)
+		}
+		target = refMap
 	}
 
 	// --- Step 7: Run ICP calibration ---
-	log.Printf("[AUTO-CAL] %s: running ICP alignment against reference %s", vacuumID, referenceID)
-
-	// Use rotation hint from config if available.
-	var result ICPResult
-	icpCfg := DefaultICPConfig()
-	if vc.Rotation != nil {
-		result = AlignMapsWithRotationHint(freshMap, refMap, icpCfg, *vc.Rotation)
-		log.Printf("[AUTO-CAL] %s: ICP with rotation hint %.0f: error=%.2f, iterations=%d, converged=%v",
-			vacuumID, *vc.Rotation, result.Error, result.Iterations, result.Converged)
-	} else {
-		result = AlignMaps(freshMap, refMap, icpCfg)
-		log.Printf("[AUTO-CAL] %s: ICP full: error=%.2f, iterations=%d, converged=%v",
-			vacuumID, result.Error, result.Iterations, result.Converged)
+	trace.Step("icp")
+	icpCfg := ICPConfigFromConfig(ac.config)
+	result, rotation := ac.align(vacuumID, vc, freshMap, target, targetName, icpCfg)
+	if target != refMap && refMap != nil && result.Score < preAlignMinScore {
+		// Too little of the consensus matched; the reference may still do
+		log.Printf("[AUTO-CAL] %s: poor match against %s (score %.2f), trying reference %s",
+			vacuumID, targetName, result.Score, referenceID)
+		if refResult, refRotation := ac.align(vacuumID, vc, freshMap, refMap, "reference "+referenceID, icpCfg); refResult.Score > result.Score {
+			result, rotation = refResult, refRotation
+		}
 	}
 
 	transform := result.Transform
@@ -168,21 +263,179 @@ func (ac *AutoCalibrator) OnDockingEvent(vacuumID string) {
 	}
 
 	// --- Step 8: Update cache ---
-	ac.cache.ReferenceVacuum = referenceID
-	ac.cache.UpdateVacuumCalibration(vacuumID, VacuumCalibration{
+	// The new transform is trusted as far as it is more certain than the
+	// one it replaces (see FuseCalibration)
+	trace.Step("persist")
+	next := VacuumCalibration{
 		Transform:            transform,
 		LastUpdated:          time.Now().Unix(),
 		MapAreaAtCalibration: freshMap.MetaData.TotalLayerArea,
+		ICPScore:             result.Score,
+		Rotation:             rotation,
+		Covariance:           result.Covariance,
+		Alternatives:         result.Alternatives,
+	}
+	if result.Disambiguated {
+		log.Printf("[AUTO-CAL] %s: several rotations aligned about as well; room borders picked %.1f°", vacuumID, TransformRotation(transform))
+	}
+	for _, alt := range result.Alternatives {
+		log.Printf("[AUTO-CAL] %s: ambiguous rotation: %.1f° (score %.2f) aligned about as well as %.1f° (score %.2f); set a rotation hint to choose",
+			vacuumID, alt.Rotation, alt.Score, TransformRotation(transform), result.Score)
+	}
+	if prev := ac.cache.Load().GetVacuumCalibration(vacuumID); prev != nil && ac.cache.Load().ReferenceVacuum == referenceID {
+		var gain float64
+		if next, gain = FuseCalibration(*prev, next); gain < 1 {
+			rot, trans := next.Covariance.Interval95()
+			log.Printf("[AUTO-CAL] %s: new transform agrees with the previous one; trusted %.0f%% of the change (now ±%.2f°, ±(%.1f, %.1f))",
+				vacuumID, gain*100, rot, trans.X, trans.Y)
+		}
+	}
+	ac.update(func(cal *CalibrationData) {
+		cal.ReferenceVacuum = referenceID
+		cal.UpdateVacuumCalibration(vacuumID, next)
 	})
 
 	ac.persistAndRecord(vacuumID)
+	return nil
 }
 
-// GetCache returns the current calibration data (for use by the app layer).
-func (ac *AutoCalibrator) GetCache() *CalibrationData {
+// FollowFrameShift checks next, a new map of vacuumID, against prev, the
+// map it replaces (see DetectFrameShift). When the robot's own frame moved
+// between them, the cached transform is composed with the shift, so the
+// vacuum stays where it was in the world instead of drifting until it next
+// docks, and the cache is persisted. It reports whether the transform
+// changed. Vacuums without a cached transform, and frozen ones, are left
+// alone.
+func (ac *AutoCalibrator) FollowFrameShift(vacuumID string, prev, next *ValetudoMap) bool {
 	ac.mu.Lock()
 	defer ac.mu.Unlock()
-	return ac.cache
+
+	entry := ac.cache.Load().GetVacuumCalibration(vacuumID)
+	if entry == nil {
+		return false
+	}
+	trace := StartTrace("frame check " + vacuumID)
+	shift, shifted := DetectFrameShift(prev, next, ICPConfigFromConfig(ac.config))
+	trace.End()
+	if !shifted {
+		return false
+	}
+	if ac.config.IsFrozen(vacuumID) {
+		log.Printf("[AUTO-CAL] %s: map frame moved %.0fpx, turned %.1f° (score %.2f), but the transform is frozen in config; not following",
+			vacuumID, shift.Translation, shift.Rotation, shift.Score)
+		return false
+	}
+
+	log.Printf("[AUTO-CAL] %s: map frame moved %.0fpx, turned %.1f° (score %.2f, walls %.0f%%); following the shift",
+		vacuumID, shift.Translation, shift.Rotation, shift.Score, shift.WallFit*100)
+	followed := VacuumCalibration{
+		Transform:            MultiplyMatrices(entry.Transform, shift.Delta),
+		LastUpdated:          time.Now().Unix(),
+		MapAreaAtCalibration: next.MetaData.TotalLayerArea,
+		ICPScore:             entry.ICPScore,
+		Alternatives:         composeHypotheses(entry.Alternatives, shift.Delta),
+	}
+	if entry.Covariance != nil {
+		c := entry.Covariance.shifted(Point{X: followed.Transform.Tx - entry.Transform.Tx, Y: followed.Transform.Ty - entry.Transform.Ty})
+		followed.Covariance = &c
+	}
+	ac.update(func(cal *CalibrationData) { cal.UpdateVacuumCalibration(vacuumID, followed) })
+	ac.persistAndRecord(vacuumID)
+	return true
+}
+
+// align runs ICP from source onto target, starting from the vacuum's
+// configured rotation hint if it has one. Without a hint it reuses the
+// rotation cached for the same two maps, or sweeps and returns the rotation
+// found for the cache. A vacuum configured as mirrored is aligned by its
+// reflected map, and the result's transform includes the reflection.
+func (ac *AutoCalibrator) align(vacuumID string, vc *VacuumConfig, source, target *ValetudoMap, targetName string, icpCfg ICPConfig) (ICPResult, *CachedRotation) {
+	log.Printf("[AUTO-CAL] %s: running ICP alignment against %s", vacuumID, targetName)
+	source, mirror := MirrorMap(source, vc.Mirror)
+	if mirror != Identity() {
+		log.Printf("[AUTO-CAL] %s: map mirrored along %s before ICP", vacuumID, vc.Mirror)
+	}
+
+	// Use rotation hint from config if available.
+	var result ICPResult
+	var rotation *CachedRotation
+	if vc.Rotation != nil {
+		result = AlignMapsWithRotationHint(source, target, icpCfg, *vc.Rotation)
+		log.Printf("[AUTO-CAL] %s: ICP with rotation hint %.0f: error=%.2f, score=%.2f, iterations=%d, converged=%v",
+			vacuumID, *vc.Rotation, result.Error, result.Score, result.Iterations, result.Converged)
+	} else {
+		var reused bool
+		result, rotation, reused = AlignMapsReusingRotation(vacuumID, source, target, icpCfg, ac.cache.Load())
+		if reused {
+			log.Printf("[AUTO-CAL] %s: ICP with cached rotation %.0f (maps unchanged): error=%.2f, score=%.2f, iterations=%d, converged=%v",
+				vacuumID, rotation.Rotation, result.Error, result.Score, result.Iterations, result.Converged)
+		} else {
+			log.Printf("[AUTO-CAL] %s: ICP full: error=%.2f, score=%.2f, iterations=%d, converged=%v",
+				vacuumID, result.Error, result.Score, result.Iterations, result.Converged)
+		}
+	}
+	if result.TimedOut {
+		log.Printf("[AUTO-CAL] %s: ICP stopped at the icp.maxDuration budget of %v, using the best alignment found", vacuumID, icpCfg.MaxDuration)
+	}
+	return result.ComposedWith(mirror), rotation
+}
+
+// SetCalibratedHandler registers a callback invoked with the updated
+// calibration after each successful calibration run.
+func (ac *AutoCalibrator) SetCalibratedHandler(handler func(*CalibrationData)) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.onCalibrated = handler
+}
+
+// SetAccumulator merges fetched maps of vacuums that accumulate their runs
+// into their accumulated maps before calibrating them.
+func (ac *AutoCalibrator) SetAccumulator(ma *MapAccumulator) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.accumulator = ma
+}
+
+// SetCache replaces the calibration data, e.g. with calibration replicated
+// from another instance, and persists it. cal must not be changed afterwards.
+func (ac *AutoCalibrator) SetCache(cal *CalibrationData) {
+	if cal == nil {
+		return
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.cache.Store(cal)
+	if err := ac.store.SaveCalibration(cal); err != nil {
+		log.Printf("[AUTO-CAL] failed to save replicated calibration: %v", err)
+	}
+}
+
+// LoadCache replaces the calibration data with a cache another process
+// wrote to the store, such as a --calibrate run, without saving it again.
+// cal must not be changed afterwards.
+func (ac *AutoCalibrator) LoadCache(cal *CalibrationData) {
+	if cal == nil {
+		return
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.cache.Store(cal)
+}
+
+// GetCache returns the current calibration data (for use by the app layer)
+// without waiting for a calibration in progress. It is never changed once
+// returned; later calibrations replace it, so callers must not change it
+// either.
+func (ac *AutoCalibrator) GetCache() *CalibrationData {
+	return ac.cache.Load()
+}
+
+// update replaces the calibration with a copy changed by fn, leaving the one
+// readers may hold untouched. Callers must hold ac.mu.
+func (ac *AutoCalibrator) update(fn func(*CalibrationData)) {
+	next := ac.cache.Load().Clone()
+	fn(next)
+	ac.cache.Store(next)
 }
 
 // resolveReference determines the reference vacuum ID from config, cache, or auto-selection.
@@ -192,8 +445,8 @@ func (ac *AutoCalibrator) resolveReference() string {
 		return ref
 	}
 	// Priority 2: cache
-	if ac.cache != nil && ac.cache.ReferenceVacuum != "" {
-		return ac.cache.ReferenceVacuum
+	if cal := ac.cache.Load(); cal != nil && cal.ReferenceVacuum != "" {
+		return cal.ReferenceVacuum
 	}
 	// Priority 3: auto-select from available maps
 	maps := ac.stateTracker.GetMaps()
@@ -206,7 +459,7 @@ func (ac *AutoCalibrator) resolveReference() string {
 // cachedMapArea returns the map area stored in the calibration cache for the
 // given vacuum, or 0 if not present.
 func (ac *AutoCalibrator) cachedMapArea(vacuumID string) int {
-	vc := ac.cache.GetVacuumCalibration(vacuumID)
+	vc := ac.cache.Load().GetVacuumCalibration(vacuumID)
 	if vc == nil {
 		return 0
 	}
@@ -216,19 +469,23 @@ func (ac *AutoCalibrator) cachedMapArea(vacuumID string) int {
 // persistAndRecord saves the calibration cache to disk and updates the in-memory
 // debounce timestamp.
 func (ac *AutoCalibrator) persistAndRecord(vacuumID string) {
-	if err := SaveCalibration(ac.cachePath, ac.cache); err != nil {
+	if err := ac.store.SaveCalibration(ac.cache.Load()); err != nil {
 		log.Printf("[AUTO-CAL] %s: failed to save calibration cache: %v", vacuumID, err)
 	} else {
-		log.Printf("[AUTO-CAL] %s: calibration cache saved to %s", vacuumID, ac.cachePath)
+		log.Printf("[AUTO-CAL] %s: calibration cache saved to %s", vacuumID, ac.store)
 	}
 	ac.lastCalibrated[vacuumID] = time.Now()
 	log.Printf("[AUTO-CAL] %s: calibration complete", vacuumID)
+
+	if ac.onCalibrated != nil {
+		ac.onCalibrated(ac.cache.Load())
+	}
 }
 
 // String implements fmt.Stringer for debug logging.
 func (ac *AutoCalibrator) String() string {
 	ac.mu.Lock()
 	defer ac.mu.Unlock()
-	return fmt.Sprintf("AutoCalibrator{cachePath=%s, vacuums=%d, lastCalibrated=%d}",
-		ac.cachePath, len(ac.cache.Vacuums), len(ac.lastCalibrated))
+	return fmt.Sprintf("AutoCalibrator{store=%s, vacuums=%d, lastCalibrated=%d}",
+		ac.store, len(ac.cache.Load().Vacuums), len(ac.lastCalibrated))
 }
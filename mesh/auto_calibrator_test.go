@@ -1,8 +1,12 @@
 package mesh
 
 import (
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,10 +24,10 @@ func TestNewAutoCalibrator_NilCache(t *testing.T) {
 		t.Fatal("expected non-nil AutoCalibrator")
 		return
 	}
-	if ac.cache == nil {
+	if ac.cache.Load() == nil {
 		t.Fatal("expected cache to be initialized when nil is passed")
 	}
-	if ac.cache.Vacuums == nil {
+	if ac.cache.Load().Vacuums == nil {
 		t.Fatal("expected cache.Vacuums to be initialized")
 	}
 }
@@ -39,7 +43,7 @@ func TestNewAutoCalibrator_WithCache(t *testing.T) {
 	}
 
 	ac := NewAutoCalibrator(cfg, cache, "/tmp/test-cal.json", "", st)
-	if ac.cache != cache {
+	if ac.cache.Load() != cache {
 		t.Fatal("expected cache to be the same pointer passed in")
 	}
 }
@@ -105,6 +109,151 @@ func TestOnDockingEvent_NoApiURL(t *testing.T) {
 	ac.OnDockingEvent("vac-a")
 }
 
+// ---------------------------------------------------------------------------
+// Calibrate – frozen transform
+// ---------------------------------------------------------------------------
+
+func TestCalibrate_FrozenKeepsTransform(t *testing.T) {
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write([]byte("not a map")) // parse errors are not retried
+	}))
+	defer srv.Close()
+
+	apiURL := srv.URL
+	cfg := &Config{Vacuums: []VacuumConfig{
+		{ID: "vac-a"},
+		{ID: "vac-b", ApiURL: &apiURL, Frozen: true},
+	}}
+	frozen := CreateRotationTranslation(90, 250, -40)
+	cache := &CalibrationData{ReferenceVacuum: "vac-a", Vacuums: map[string]VacuumCalibration{
+		"vac-b": {Transform: frozen, LastUpdated: 1},
+	}}
+	ac := NewAutoCalibrator(cfg, cache, filepath.Join(t.TempDir(), "cal.json"), "", NewStateTracker())
+
+	err := ac.Calibrate("vac-b")
+	if err == nil || !strings.Contains(err.Error(), "frozen") {
+		t.Fatalf("Calibrate = %v, want frozen error", err)
+	}
+	if fetches != 0 {
+		t.Errorf("frozen vacuum fetched its map %d times", fetches)
+	}
+	if got := ac.GetCache().Vacuums["vac-b"]; got.Transform != frozen || got.LastUpdated != 1 {
+		t.Errorf("frozen calibration changed: %+v", got)
+	}
+
+	// Until it has a transform to keep, a frozen vacuum calibrates as usual
+	delete(cache.Vacuums, "vac-b")
+	_ = ac.Calibrate("vac-b")
+	if fetches != 1 {
+		t.Errorf("uncalibrated frozen vacuum fetched %d times, want 1", fetches)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// OnMapPushed
+// ---------------------------------------------------------------------------
+
+func TestOnMapPushed_CalibratesPushedMap(t *testing.T) {
+	cfg := &Config{Vacuums: []VacuumConfig{{ID: "vac-a"}, {ID: "vac-b"}, {ID: "vac-c", Frozen: true}}}
+	frozen := CreateRotationTranslation(90, 250, -40)
+	cache := &CalibrationData{ReferenceVacuum: "vac-a", Vacuums: map[string]VacuumCalibration{
+		"vac-c": {Transform: frozen, LastUpdated: 1},
+	}}
+	st := NewStateTracker()
+	st.UpdateMap("vac-a", rotatedRoom(0))
+	ac := NewAutoCalibrator(cfg, cache, filepath.Join(t.TempDir(), "cal.json"), "", st)
+
+	pushed := rotatedRoom(90)
+	pushed.Entities = []MapEntity{
+		{Type: "robot_position", Points: []int{2000, 2000}},
+		{Type: "charger_location", Points: []int{2100, 2000}},
+	}
+	held := ac.GetCache()
+	ac.OnMapPushed("vac-b", pushed)
+	got, ok := ac.GetCache().Vacuums["vac-b"]
+	if !ok {
+		t.Fatal("pushed map not calibrated")
+	}
+	// Readers may still hold the calibration it replaced
+	if _, changed := held.Vacuums["vac-b"]; changed || held.LastUpdated != 0 {
+		t.Error("calibration handed out earlier was changed in place")
+	}
+	if rot := TransformRotation(got.Transform); angleDiff(rot, 270) > 2 {
+		t.Errorf("rotation = %.1f°, want 270°", rot)
+	}
+
+	// A second push within the debounce interval keeps the calibration
+	ac.OnMapPushed("vac-b", rotatedRoom(0))
+	if again := ac.GetCache().Vacuums["vac-b"]; again.LastUpdated != got.LastUpdated || again.Transform != got.Transform {
+		t.Error("debounced push recalibrated")
+	}
+
+	ac.OnMapPushed("vac-c", pushed)
+	if ac.GetCache().GetTransform("vac-c") != frozen {
+		t.Error("frozen transform recalibrated from a pushed map")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FollowFrameShift
+// ---------------------------------------------------------------------------
+
+func TestFollowFrameShift_ComposesTransform(t *testing.T) {
+	cfg := &Config{Vacuums: []VacuumConfig{{ID: "vac-a"}, {ID: "vac-b"}, {ID: "vac-c", Frozen: true}}}
+	world := CreateRotationTranslation(90, 250, -40)
+	cache := &CalibrationData{ReferenceVacuum: "vac-a", Vacuums: map[string]VacuumCalibration{
+		"vac-b": {Transform: world, LastUpdated: 1},
+		"vac-c": {Transform: world, LastUpdated: 1},
+	}}
+	ac := NewAutoCalibrator(cfg, cache, filepath.Join(t.TempDir(), "cal.json"), "", NewStateTracker())
+
+	held := ac.GetCache()
+	prev := rotatedRoom(0)
+	next := shiftedMap(prev, 40, -25)
+	if !ac.FollowFrameShift("vac-b", prev, next) {
+		t.Fatal("shifted frame not followed")
+	}
+	if held.GetTransform("vac-b") != world || held.LastUpdated != 0 {
+		t.Error("calibration handed out earlier was changed in place")
+	}
+	// A wall point of the new map lands where the same point of the old map did
+	want := TransformPoint(Point{X: 220, Y: 300}, world)
+	got := TransformPoint(Point{X: 260, Y: 275}, ac.GetCache().GetTransform("vac-b"))
+	if math.Hypot(got.X-want.X, got.Y-want.Y) > 3 {
+		t.Errorf("followed transform puts the point at (%.1f, %.1f), want (%.1f, %.1f)", got.X, got.Y, want.X, want.Y)
+	}
+
+	if ac.FollowFrameShift("vac-b", next, next) {
+		t.Error("unchanged frame followed")
+	}
+	if ac.FollowFrameShift("vac-c", prev, next) || ac.GetCache().GetTransform("vac-c") != world {
+		t.Error("frozen transform followed the shift")
+	}
+	if ac.FollowFrameShift("vac-d", prev, next) {
+		t.Error("uncalibrated vacuum followed the shift")
+	}
+}
+
+func TestGetCache_DoesNotWaitForCalibration(t *testing.T) {
+	ac := NewAutoCalibrator(&Config{}, nil, filepath.Join(t.TempDir(), "cal.json"), "", NewStateTracker())
+
+	// A calibration or frame check holds mu for a whole ICP run
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = ac.GetCache()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetCache waited for the calibrator's lock")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // resolveReference
 // ---------------------------------------------------------------------------
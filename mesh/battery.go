@@ -0,0 +1,105 @@
+package mesh
+
+import (
+	"image/color"
+	"math"
+	"sync"
+	"time"
+)
+
+// Defaults for BatteryConfig.
+const (
+	DefaultBatteryAlertBelow = 20    // percent
+	DefaultBatteryDockRadius = 500.0 // mm
+)
+
+// BatteryAlert is the low-battery state of one robot, published whenever it
+// changes. Positions are world mm.
+type BatteryAlert struct {
+	VacuumID     string  `json:"vacuumId"`
+	Active       bool    `json:"active"`
+	Battery      int     `json:"battery"`   // percent
+	Threshold    int     `json:"threshold"` // percent
+	X            float64 `json:"x"`
+	Y            float64 `json:"y"`
+	DockDistance float64 `json:"dockDistance,omitempty"` // mm; 0 when the charger is unknown
+	Timestamp    int64   `json:"timestamp"`
+}
+
+// BatteryMonitor raises an alert when a robot's battery drops below the
+// configured threshold while it is away from its charger, and clears it
+// once the robot is charged or back on the dock.
+type BatteryMonitor struct {
+	config BatteryConfig
+
+	mu     sync.Mutex
+	active map[string]bool // vacuum ID -> alert raised
+}
+
+// NewBatteryMonitor creates a monitor with the thresholds of config.
+func NewBatteryMonitor(config BatteryConfig) *BatteryMonitor {
+	return &BatteryMonitor{config: config, active: make(map[string]bool)}
+}
+
+// Check evaluates a robot's battery level at world position robot (mm).
+// charger is the robot's charger in world mm; a robot without a known
+// charger counts as away. It returns the robot's alert state and whether it
+// changed since the last check, so each alert is published once.
+func (m *BatteryMonitor) Check(vacuumID string, level int, robot, charger Point, hasCharger bool) (BatteryAlert, bool) {
+	alert := BatteryAlert{
+		VacuumID:  vacuumID,
+		Battery:   level,
+		Threshold: m.config.alertBelow(),
+		X:         math.Round(robot.X),
+		Y:         math.Round(robot.Y),
+		Timestamp: time.Now().Unix(),
+	}
+	away := true
+	if hasCharger {
+		alert.DockDistance = math.Round(math.Hypot(robot.X-charger.X, robot.Y-charger.Y))
+		away = alert.DockDistance > m.config.dockRadius()
+	}
+	alert.Active = m.config.alerts() && level < alert.Threshold && away
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := m.active[vacuumID] != alert.Active
+	m.active[vacuumID] = alert.Active
+	return alert, changed
+}
+
+// BatteryColor returns the color of a battery ring: red when nearly empty,
+// through orange and yellow to green when full.
+func BatteryColor(level int) color.NRGBA {
+	switch {
+	case level < 15:
+		return color.NRGBA{220, 20, 20, 255}
+	case level < 35:
+		return color.NRGBA{255, 140, 0, 255}
+	case level < 60:
+		return color.NRGBA{230, 200, 0, 255}
+	default:
+		return color.NRGBA{40, 180, 40, 255}
+	}
+}
+
+// alerts reports whether low-battery alerts are published (the default).
+func (c BatteryConfig) alerts() bool {
+	return c.Alerts == nil || *c.Alerts
+}
+
+// alertBelow returns the configured threshold or DefaultBatteryAlertBelow.
+func (c BatteryConfig) alertBelow() int {
+	if c.AlertBelow > 0 {
+		return c.AlertBelow
+	}
+	return DefaultBatteryAlertBelow
+}
+
+// dockRadius returns the configured radius or DefaultBatteryDockRadius.
+func (c BatteryConfig) dockRadius() float64 {
+	if c.DockRadius > 0 {
+		return c.DockRadius
+	}
+	return DefaultBatteryDockRadius
+}
@@ -0,0 +1,68 @@
+package mesh
+
+import "testing"
+
+// ---------------------------------------------------------------------------
+// BatteryMonitor
+// ---------------------------------------------------------------------------
+
+func TestBatteryMonitor_Check(t *testing.T) {
+	m := NewBatteryMonitor(BatteryConfig{})
+	dock := Point{X: 0, Y: 0}
+	away := Point{X: 3000, Y: 4000}
+
+	steps := []struct {
+		name        string
+		level       int
+		robot       Point
+		hasCharger  bool
+		wantActive  bool
+		wantChanged bool
+	}{
+		{"charged and away", 80, away, true, false, false},
+		{"low on the dock", 10, Point{X: 300, Y: 0}, true, false, false},
+		{"low and away raises", 10, away, true, true, true},
+		{"still low stays raised", 9, away, true, true, false},
+		{"back on the dock clears", 9, dock, true, false, true},
+		{"low without a charger counts as away", 9, dock, false, true, true},
+		{"charged clears", 20, dock, false, false, true},
+	}
+	for _, s := range steps {
+		alert, changed := m.Check("vac1", s.level, s.robot, dock, s.hasCharger)
+		if alert.Active != s.wantActive || changed != s.wantChanged {
+			t.Errorf("%s: active=%v changed=%v, want active=%v changed=%v", s.name, alert.Active, changed, s.wantActive, s.wantChanged)
+		}
+	}
+
+	alert, _ := m.Check("vac1", 10, away, dock, true)
+	if alert.DockDistance != 5000 || alert.Threshold != DefaultBatteryAlertBelow || alert.X != 3000 || alert.Y != 4000 {
+		t.Errorf("alert = %+v, want 5000mm from the dock with the default threshold", alert)
+	}
+}
+
+func TestBatteryMonitor_Config(t *testing.T) {
+	off := false
+	if alert, changed := NewBatteryMonitor(BatteryConfig{Alerts: &off}).Check("vac1", 1, Point{X: 5000}, Point{}, true); alert.Active || changed {
+		t.Error("disabled alerts still raised")
+	}
+
+	m := NewBatteryMonitor(BatteryConfig{AlertBelow: 50, DockRadius: 2000})
+	if alert, _ := m.Check("vac1", 40, Point{X: 1500}, Point{}, true); alert.Active {
+		t.Error("robot inside the configured dock radius raised an alert")
+	}
+	if alert, _ := m.Check("vac1", 40, Point{X: 2500}, Point{}, true); !alert.Active || alert.Threshold != 50 {
+		t.Errorf("alert = %+v, want active with threshold 50", alert)
+	}
+}
+
+func TestBatteryColor(t *testing.T) {
+	if BatteryColor(5) == BatteryColor(95) {
+		t.Error("empty and full batteries share a color")
+	}
+	if c := BatteryColor(95); c.G <= c.R {
+		t.Errorf("full battery color %v is not green", c)
+	}
+	if c := BatteryColor(5); c.R <= c.G {
+		t.Errorf("empty battery color %v is not red", c)
+	}
+}
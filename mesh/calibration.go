@@ -30,23 +30,37 @@ func LoadCalibration(path string) (*CalibrationData, error) {
 	return &cal, nil
 }
 
-// SaveCalibration saves auto-computed calibration data to a JSON cache file
+// SaveCalibration saves auto-computed calibration data to a JSON cache file,
+// keeping DefaultCalibrationHistory previous versions as backups
 func SaveCalibration(path string, cal *CalibrationData) error {
+	return SaveCalibrationHistory(path, cal, DefaultCalibrationHistory)
+}
+
+// SaveCalibrationHistory atomically saves calibration data to a JSON cache
+// file, stamped with the current time; cal itself is left as it is. The
+// version it replaces is copied to path.{timestamp} first, and only
+// the keep newest backups are kept (0 = no backups); see
+// ListCalibrationBackups and RollbackCalibration.
+func SaveCalibrationHistory(path string, cal *CalibrationData, keep int) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("creating calibration directory: %w", err)
 	}
 
-	// Update timestamp
-	cal.LastUpdated = time.Now().Unix()
+	// Stamp a copy: cal may be shared with readers on other goroutines
+	stamped := *cal
+	stamped.LastUpdated = time.Now().Unix()
 
-	data, err := json.MarshalIndent(cal, "", "  ")
+	data, err := json.MarshalIndent(&stamped, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling calibration data: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := backupCalibration(path, cal, keep); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
 		if os.IsPermission(err) {
 			return fmt.Errorf("writing calibration file to %s: %w (check directory permissions and Docker user UID)", path, err)
 		}
@@ -146,6 +160,22 @@ func (c *CalibrationData) GetVacuumCalibration(vacuumID string) *VacuumCalibrati
 	return nil
 }
 
+// Clone returns a copy of c whose Vacuums can be updated without touching
+// c. The entries' pointers and slices are shared: they are replaced, never
+// changed in place. A nil c clones to an empty calibration.
+func (c *CalibrationData) Clone() *CalibrationData {
+	clone := &CalibrationData{Vacuums: make(map[string]VacuumCalibration)}
+	if c == nil {
+		return clone
+	}
+	clone.ReferenceVacuum = c.ReferenceVacuum
+	clone.LastUpdated = c.LastUpdated
+	for id, vc := range c.Vacuums {
+		clone.Vacuums[id] = vc
+	}
+	return clone
+}
+
 // UpdateVacuumCalibration stores or replaces calibration metadata for a single vacuum.
 func (c *CalibrationData) UpdateVacuumCalibration(vacuumID string, cal VacuumCalibration) {
 	if c.Vacuums == nil {
@@ -229,3 +259,65 @@ func (c *CalibrationData) NeedsRecalibration(maxAge time.Duration) bool {
 	}
 	return time.Since(time.Unix(c.LastUpdated, 0)) > maxAge
 }
+
+// Rebase returns the calibration re-expressed relative to newReference, so
+// it can become the reference without re-running ICP. Each transform onto
+// the old reference is followed by the inverse of newReference's:
+// T_new = T_newRef⁻¹ · T_old, and so are the alternative rotations kept for
+// ambiguous alignments. Covariances add the new reference's uncertainty to
+// each vacuum's own, and are dropped when the new reference has none. The
+// new reference has neither. Calibration times and map areas are kept.
+// It fails when newReference is not calibrated or its transform cannot be
+// inverted.
+func (c *CalibrationData) Rebase(newReference string) (*CalibrationData, error) {
+	if c == nil {
+		return nil, fmt.Errorf("no calibration to rebase")
+	}
+	ref, ok := c.Vacuums[newReference]
+	if !ok {
+		return nil, fmt.Errorf("vacuum %q is not calibrated", newReference)
+	}
+	inverse, ok := ref.Transform.Inverse()
+	if !ok {
+		return nil, fmt.Errorf("transform of %q is singular", newReference)
+	}
+
+	rebased := &CalibrationData{
+		ReferenceVacuum: newReference,
+		Vacuums:         make(map[string]VacuumCalibration, len(c.Vacuums)),
+		LastUpdated:     c.LastUpdated,
+	}
+	for id, vc := range c.Vacuums {
+		if id == newReference {
+			vc.Transform = Identity() // exact, not a product rounded near it
+			vc.Alternatives = nil
+			vc.Covariance = nil
+		} else {
+			transform := MultiplyMatrices(inverse, vc.Transform)
+			vc.Covariance = rebaseCovariance(vc, id == c.ReferenceVacuum, ref.Covariance, inverse, transform)
+			vc.Transform = transform
+			vc.Alternatives = rebaseHypotheses(inverse, vc.Alternatives)
+		}
+		rebased.Vacuums[id] = vc
+	}
+	return rebased, nil
+}
+
+// rebaseCovariance returns the covariance of vc's transform once rebased
+// onto a reference with covariance ref, or nil when either is unknown. The
+// old reference is exact, so only the new reference's uncertainty carries
+// over to it.
+func rebaseCovariance(vc VacuumCalibration, oldReference bool, ref *TransformCovariance, inverse, rebased AffineMatrix) *TransformCovariance {
+	if ref == nil {
+		return nil
+	}
+	var own TransformCovariance
+	switch {
+	case vc.Covariance != nil:
+		own = *vc.Covariance
+	case !oldReference:
+		return nil
+	}
+	c := own.rebased(*ref, inverse, rebased)
+	return &c
+}
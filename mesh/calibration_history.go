@@ -0,0 +1,170 @@
+package mesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCalibrationHistory is how many previous versions of the calibration
+// cache are kept as timestamped backups next to it.
+const DefaultCalibrationHistory = 5
+
+// calibrationBackupLayout is the timestamp suffix of backup file names, e.g.
+// .calibration-cache.json.20240501T153000Z.
+const calibrationBackupLayout = "20060102T150405Z"
+
+// CalibrationBackup is a previous version of the calibration cache.
+type CalibrationBackup struct {
+	Path  string
+	Saved time.Time // when the version was written, to the second
+}
+
+// Stamp returns the timestamp suffix that identifies the backup.
+func (b CalibrationBackup) Stamp() string {
+	return b.Saved.UTC().Format(calibrationBackupLayout)
+}
+
+// backupCalibration copies the cache at path, if any, to a timestamped backup
+// before it is replaced by cal, then removes all but the keep newest backups.
+// Nothing is copied when the cache already holds cal's transforms, so saving
+// an unchanged calibration does not push older versions out of the history.
+func backupCalibration(path string, cal *CalibrationData, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading calibration file: %w", err)
+	}
+
+	// A cache that no longer parses is backed up too; it may still be worth
+	// inspecting
+	var old CalibrationData
+	if err := json.Unmarshal(data, &old); err == nil && sameCalibration(&old, cal) {
+		return nil
+	}
+	saved := time.Unix(old.LastUpdated, 0)
+	if old.LastUpdated == 0 {
+		if info, err := os.Stat(path); err == nil {
+			saved = info.ModTime()
+		}
+	}
+
+	backup := CalibrationBackup{Path: path, Saved: saved}
+	if err := WriteFileAtomic(path+"."+backup.Stamp(), data, 0644); err != nil {
+		return fmt.Errorf("backing up calibration file: %w", err)
+	}
+
+	backups, err := ListCalibrationBackups(path)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing old calibration backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// sameCalibration reports whether a and b hold the same reference and
+// per-vacuum calibrations, ignoring when they were saved.
+func sameCalibration(a, b *CalibrationData) bool {
+	if a.ReferenceVacuum != b.ReferenceVacuum || len(a.Vacuums) != len(b.Vacuums) {
+		return false
+	}
+	return len(a.Vacuums) == 0 || reflect.DeepEqual(a.Vacuums, b.Vacuums)
+}
+
+// ListCalibrationBackups returns the backups of the calibration cache at
+// path, newest first.
+func ListCalibrationBackups(path string) ([]CalibrationBackup, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing calibration backups: %w", err)
+	}
+
+	prefix := filepath.Base(path) + "."
+	var backups []CalibrationBackup
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		saved, err := time.Parse(calibrationBackupLayout, stamp)
+		if err != nil {
+			continue // a temporary file or something else entirely
+		}
+		backups = append(backups, CalibrationBackup{
+			Path:  filepath.Join(filepath.Dir(path), e.Name()),
+			Saved: saved,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Saved.After(backups[j].Saved) })
+	return backups, nil
+}
+
+// FindCalibrationBackup selects a backup of the cache at path by version:
+// "1" is the newest backup, "2" the one before it, and so on, or a timestamp
+// as in the backup's file name.
+func FindCalibrationBackup(path, version string) (CalibrationBackup, error) {
+	backups, err := ListCalibrationBackups(path)
+	if err != nil {
+		return CalibrationBackup{}, err
+	}
+	if len(backups) == 0 {
+		return CalibrationBackup{}, fmt.Errorf("no backups of %s", path)
+	}
+	if n, err := strconv.Atoi(version); err == nil {
+		if n < 1 || n > len(backups) {
+			return CalibrationBackup{}, fmt.Errorf("version %d out of range (1-%d)", n, len(backups))
+		}
+		return backups[n-1], nil
+	}
+	for _, b := range backups {
+		if b.Stamp() == version {
+			return b, nil
+		}
+	}
+	return CalibrationBackup{}, fmt.Errorf("no backup of %s saved at %s", path, version)
+}
+
+// RollbackCalibration restores backup over the calibration cache at path.
+// The cache it replaces becomes the newest backup, so rolling back to
+// version 1 again undoes the rollback; keep bounds the history as for
+// SaveCalibrationHistory, but the replaced cache is always kept.
+func RollbackCalibration(path string, backup CalibrationBackup, keep int) (*CalibrationData, error) {
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading calibration backup: %w", err)
+	}
+	var cal CalibrationData
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return nil, fmt.Errorf("parsing calibration backup %s: %w", filepath.Base(backup.Path), err)
+	}
+
+	if err := backupCalibration(path, &cal, max(keep, 1)); err != nil {
+		return nil, err
+	}
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("writing calibration file: %w", err)
+	}
+	// The restored version is the cache now, not part of its history
+	if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("removing restored calibration backup: %w", err)
+	}
+	return &cal, nil
+}
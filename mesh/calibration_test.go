@@ -2,6 +2,7 @@ package mesh
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -138,9 +139,9 @@ func TestSaveCalibration(t *testing.T) {
 	}
 	after := time.Now().Unix()
 
-	// Timestamp must have been updated by SaveCalibration
-	if cal.LastUpdated < before || cal.LastUpdated > after {
-		t.Errorf("LastUpdated = %d, want between %d and %d", cal.LastUpdated, before, after)
+	// The saved copy is stamped; cal, which may be shared, is not
+	if cal.LastUpdated != 0 {
+		t.Errorf("SaveCalibration changed its argument's LastUpdated to %d", cal.LastUpdated)
 	}
 
 	// Round-trip: load back and verify
@@ -148,6 +149,9 @@ func TestSaveCalibration(t *testing.T) {
 	if err != nil {
 		t.Fatalf("LoadCalibration after save: %v", err)
 	}
+	if loaded.LastUpdated < before || loaded.LastUpdated > after {
+		t.Errorf("LastUpdated = %d, want between %d and %d", loaded.LastUpdated, before, after)
+	}
 	if loaded.ReferenceVacuum != "vac-a" {
 		t.Errorf("ReferenceVacuum = %q, want %q", loaded.ReferenceVacuum, "vac-a")
 	}
@@ -156,6 +160,115 @@ func TestSaveCalibration(t *testing.T) {
 	}
 }
 
+// calibrationVersion returns calibration number i of a test history.
+func calibrationVersion(i int) *CalibrationData {
+	return &CalibrationData{
+		ReferenceVacuum: "ref",
+		Vacuums: map[string]VacuumCalibration{
+			"ref": {Transform: Identity()},
+			"vac": {Transform: Translation(float64(i), 0)},
+		},
+	}
+}
+
+// saveVersions saves calibration versions 1..n to path, each stamped i*1000
+// seconds after the epoch so their backups get distinct names.
+func saveVersions(t *testing.T, path string, n, keep int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if err := SaveCalibrationHistory(path, calibrationVersion(i), keep); err != nil {
+			t.Fatalf("saving version %d: %v", i, err)
+		}
+		cal := calibrationVersion(i)
+		cal.LastUpdated = int64(i) * 1000
+		data, _ := json.Marshal(cal)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// backupVersions returns the version number of each backup, newest first.
+func backupVersions(t *testing.T, path string) []int {
+	t.Helper()
+	backups, err := ListCalibrationBackups(path)
+	if err != nil {
+		t.Fatalf("ListCalibrationBackups: %v", err)
+	}
+	versions := make([]int, len(backups))
+	for i, b := range backups {
+		cal, err := LoadCalibration(b.Path)
+		if err != nil || cal == nil {
+			t.Fatalf("loading %s: %v", b.Path, err)
+		}
+		versions[i] = int(cal.Vacuums["vac"].Transform.Tx)
+	}
+	return versions
+}
+
+func TestSaveCalibration_History(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".calibration-cache.json")
+	saveVersions(t, path, 4, 2)
+
+	if got := backupVersions(t, path); len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Errorf("backups = %v, want [3 2]", got)
+	}
+	backups, _ := ListCalibrationBackups(path)
+	if want := path + ".19700101T005000Z"; backups[0].Path != want {
+		t.Errorf("newest backup = %s, want %s", backups[0].Path, want)
+	}
+
+	// Saving the calibration already in the cache keeps the history as is
+	if err := SaveCalibrationHistory(path, calibrationVersion(4), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := backupVersions(t, path); len(got) != 2 || got[0] != 3 {
+		t.Errorf("backups after unchanged save = %v, want [3 2]", got)
+	}
+
+	// Without history nothing is backed up
+	plain := filepath.Join(t.TempDir(), "cal.json")
+	saveVersions(t, plain, 3, 0)
+	if got := backupVersions(t, plain); len(got) != 0 {
+		t.Errorf("backups with keep 0 = %v, want none", got)
+	}
+}
+
+func TestRollbackCalibration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".calibration-cache.json")
+	saveVersions(t, path, 3, DefaultCalibrationHistory)
+
+	backup, err := FindCalibrationBackup(path, "1")
+	if err != nil {
+		t.Fatalf("FindCalibrationBackup: %v", err)
+	}
+	cal, err := RollbackCalibration(path, backup, DefaultCalibrationHistory)
+	if err != nil {
+		t.Fatalf("RollbackCalibration: %v", err)
+	}
+	loaded, _ := LoadCalibration(path)
+	if cal.Vacuums["vac"].Transform.Tx != 2 || loaded.Vacuums["vac"].Transform.Tx != 2 {
+		t.Errorf("restored version %v, cache holds %v, want 2", cal.Vacuums["vac"].Transform.Tx, loaded.Vacuums["vac"].Transform.Tx)
+	}
+	// The replaced version is backup 1, and the restored one left the history
+	if got := backupVersions(t, path); len(got) != 2 || got[0] != 3 || got[1] != 1 {
+		t.Errorf("backups after rollback = %v, want [3 1]", got)
+	}
+
+	// Versions can also be picked by timestamp
+	if b, err := FindCalibrationBackup(path, "19700101T001640Z"); err != nil || b.Saved.Unix() != 1000 {
+		t.Errorf("FindCalibrationBackup by timestamp = %+v, %v", b, err)
+	}
+	for _, version := range []string{"0", "3", "19700101T000000Z", "latest"} {
+		if _, err := FindCalibrationBackup(path, version); err == nil {
+			t.Errorf("FindCalibrationBackup(%q): expected error", version)
+		}
+	}
+	if _, err := FindCalibrationBackup(filepath.Join(t.TempDir(), "cal.json"), "1"); err == nil {
+		t.Error("FindCalibrationBackup without backups: expected error")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // CalibrationData.GetTransform
 // ---------------------------------------------------------------------------
@@ -483,3 +596,133 @@ func TestSelectReferenceVacuum(t *testing.T) {
 		}
 	})
 }
+
+// ---------------------------------------------------------------------------
+// Rebase
+// ---------------------------------------------------------------------------
+
+func TestCalibrationData_Rebase(t *testing.T) {
+	toB := CreateRotationTranslation(90, 40, -10)
+	toC := CreateRotationTranslation(-30, 5, 25)
+	cal := &CalibrationData{
+		ReferenceVacuum: "a",
+		LastUpdated:     1000,
+		Vacuums: map[string]VacuumCalibration{
+			"a": {Transform: Identity(), LastUpdated: 900, MapAreaAtCalibration: 1},
+			"b": {Transform: toB, LastUpdated: 950, MapAreaAtCalibration: 2, Alternatives: []RotationHypothesis{{Transform: CreateRotationTranslation(270, 0, 0), Rotation: 270}}},
+			"c": {Transform: toC, LastUpdated: 990, MapAreaAtCalibration: 3, Alternatives: []RotationHypothesis{{Transform: CreateRotationTranslation(150, 5, 25), Rotation: 150, Score: 0.7}}},
+		},
+	}
+
+	rebased, err := cal.Rebase("b")
+	if err != nil {
+		t.Fatalf("Rebase: %v", err)
+	}
+	if rebased.ReferenceVacuum != "b" || rebased.LastUpdated != 1000 {
+		t.Errorf("reference=%q lastUpdated=%d, want b and 1000", rebased.ReferenceVacuum, rebased.LastUpdated)
+	}
+	if rebased.Vacuums["b"].Transform != Identity() {
+		t.Errorf("new reference transform = %+v, want identity", rebased.Vacuums["b"].Transform)
+	}
+	if vc := rebased.Vacuums["c"]; vc.LastUpdated != 990 || vc.MapAreaAtCalibration != 3 {
+		t.Errorf("metadata of c not kept: %+v", vc)
+	}
+
+	// Points that coincided in the old frame still coincide in the new one,
+	// and the new frame is b's own grid
+	for _, id := range []string{"a", "c"} {
+		p := Point{X: 17, Y: -4}
+		world := TransformPoint(p, cal.Vacuums[id].Transform)
+		want := TransformPoint(world, InvertMatrix(toB))
+		got := TransformPoint(p, rebased.Vacuums[id].Transform)
+		if math.Abs(got.X-want.X) > 1e-9 || math.Abs(got.Y-want.Y) > 1e-9 {
+			t.Errorf("%s: point maps to %v, want %v", id, got, want)
+		}
+	}
+
+	// Alternatives move into the new frame with the transforms
+	if alts := rebased.Vacuums["b"].Alternatives; alts != nil {
+		t.Errorf("new reference alternatives = %+v, want none", alts)
+	}
+	alt := rebased.Vacuums["c"].Alternatives
+	if len(alt) != 1 || alt[0].Score != 0.7 || math.Abs(alt[0].Rotation-60) > 1e-9 {
+		t.Errorf("alternatives of c = %+v, want the 150° one turned back by b's 90°", alt)
+	}
+	if cal.Vacuums["c"].Alternatives[0].Rotation != 150 {
+		t.Error("original alternatives changed")
+	}
+
+	// The original is untouched
+	if cal.ReferenceVacuum != "a" || cal.Vacuums["b"].Transform != toB {
+		t.Error("Rebase modified the original calibration")
+	}
+}
+
+func TestCalibrationData_RebaseCovariance(t *testing.T) {
+	covariance := func(r, x, y float64) *TransformCovariance {
+		return &TransformCovariance{{r, 0, 0}, {0, x, 0}, {0, 0, y}}
+	}
+	cal := &CalibrationData{
+		ReferenceVacuum: "a",
+		Vacuums: map[string]VacuumCalibration{
+			"a": {Transform: Identity()},
+			"b": {Transform: CreateRotationTranslation(90, 0, 0), Covariance: covariance(2, 0, 0)},
+			"c": {Transform: CreateRotationTranslation(0, 10, 0), Covariance: covariance(1, 4, 9)},
+			"d": {Transform: CreateRotationTranslation(0, 5, 5)},
+		},
+	}
+
+	rebased, err := cal.Rebase("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebased.Vacuums["b"].Covariance != nil || rebased.Vacuums["d"].Covariance != nil {
+		t.Error("new reference and vacuums without covariance should have none")
+	}
+
+	// c lands at (0, -10): b's turn swaps its X and Y variances and, with
+	// b's rotation uncertainty, swings it along X
+	swing := 10 * math.Pi / 180 // translation per degree of b's turn
+	want := TransformCovariance{{3, 2 * swing, 0}, {2 * swing, 9 + 2*swing*swing, 0}, {0, 0, 4}}
+	got := rebased.Vacuums["c"].Covariance
+	if got == nil {
+		t.Fatal("covariance of c dropped")
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if math.Abs(got[i][j]-want[i][j]) > 1e-9 {
+				t.Fatalf("covariance of c = %v, want %v", *got, want)
+			}
+		}
+	}
+	// The old reference only inherits b's uncertainty
+	if a := rebased.Vacuums["a"].Covariance; a == nil || a[0][0] != 2 {
+		t.Errorf("covariance of a = %v, want b's rotation variance", a)
+	}
+
+	// Without the new reference's covariance, none is known
+	cal.Vacuums["b"] = VacuumCalibration{Transform: cal.Vacuums["b"].Transform}
+	if rebased, err = cal.Rebase("b"); err != nil || rebased.Vacuums["c"].Covariance != nil {
+		t.Errorf("covariance of c = %v with no reference covariance, want nil (err %v)", rebased.Vacuums["c"].Covariance, err)
+	}
+}
+
+func TestCalibrationData_RebaseErrors(t *testing.T) {
+	cal := &CalibrationData{
+		ReferenceVacuum: "a",
+		Vacuums: map[string]VacuumCalibration{
+			"a":    {Transform: Identity()},
+			"flat": {Transform: AffineMatrix{A: 1, B: 1, C: 1, D: 1}},
+		},
+	}
+	if _, err := cal.Rebase("missing"); err == nil {
+		t.Error("expected error for an uncalibrated vacuum")
+	}
+	if _, err := cal.Rebase("flat"); err == nil {
+		t.Error("expected error for a singular transform")
+	}
+	var none *CalibrationData
+	if _, err := none.Rebase("a"); err == nil {
+		t.Error("expected error for nil calibration")
+	}
+}
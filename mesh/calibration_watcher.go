@@ -0,0 +1,112 @@
+package mesh
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// CalibrationWatcher watches the calibration cache file for changes made by
+// another process, such as a --calibrate run while the service is up.
+type CalibrationWatcher struct {
+	Path     string
+	Debounce time.Duration // quiet period before loading (0 = DefaultWatchDebounce)
+
+	last [sha256.Size]byte // content hash of the last loaded version
+	seen bool
+}
+
+// NewCalibrationWatcher creates a watcher for the calibration cache at path.
+func NewCalibrationWatcher(path string) *CalibrationWatcher {
+	return &CalibrationWatcher{Path: filepath.Clean(path)}
+}
+
+// Prime records the current contents of the cache so that rewriting it
+// without changes is not reported.
+func (w *CalibrationWatcher) Prime() {
+	if sum, err := hashFile(w.Path); err == nil {
+		w.last, w.seen = sum, true
+	}
+}
+
+// Watch blocks until ctx is done, calling onChange with the calibration
+// each time the cache file is created or its content changes. The parent
+// directory is watched, so atomic writes that rename a temporary file over
+// the cache are seen. A cache that fails to load, such as one still being
+// written by a non-atomic editor, is retried on its next write.
+func (w *CalibrationWatcher) Watch(ctx context.Context, onChange func(*CalibrationData)) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	defer func() { _ = fw.Close() }()
+
+	dir := filepath.Dir(w.Path)
+	if err := fw.Add(dir); err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+
+	// Every write pushes the timer back; when it fires the file has been
+	// quiet for the debounce period
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(ev.Name) != w.Path || !(ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write)) {
+				continue
+			}
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			w.load(onChange)
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Warning: watching %s: %v", w.Path, err)
+		}
+	}
+}
+
+// load reads the cache and reports it if its content changed.
+func (w *CalibrationWatcher) load(onChange func(*CalibrationData)) {
+	sum, err := hashFile(w.Path)
+	if err != nil {
+		log.Printf("Warning: reading %s: %v", w.Path, err)
+		return
+	}
+	if w.seen && sum == w.last {
+		return
+	}
+
+	cal, err := LoadCalibration(w.Path)
+	if err != nil {
+		log.Printf("Warning: %s not reloaded (waiting for next write): %v", filepath.Base(w.Path), err)
+		return
+	}
+	if cal == nil {
+		return // removed since the write
+	}
+	w.last, w.seen = sum, true
+	onChange(cal)
+}
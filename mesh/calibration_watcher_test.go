@@ -0,0 +1,108 @@
+package mesh
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startCalibrationWatcher runs a watcher on a cache in a fresh directory and
+// returns the cache path and a channel of reloaded calibrations.
+func startCalibrationWatcher(t *testing.T, initial *CalibrationData) (string, <-chan *CalibrationData) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".calibration-cache.json")
+	if initial != nil {
+		if err := SaveCalibration(path, initial); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := NewCalibrationWatcher(path)
+	w.Debounce = 20 * time.Millisecond
+	w.Prime()
+
+	reloads := make(chan *CalibrationData, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Watch(ctx, func(cal *CalibrationData) { reloads <- cal }); err != nil {
+			t.Errorf("Watch: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Give the watcher time to register the directory
+	time.Sleep(50 * time.Millisecond)
+	return path, reloads
+}
+
+func expectReload(t *testing.T, reloads <-chan *CalibrationData) *CalibrationData {
+	t.Helper()
+	select {
+	case cal := <-reloads:
+		return cal
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for calibration reload")
+		return nil
+	}
+}
+
+func expectNoReload(t *testing.T, reloads <-chan *CalibrationData) {
+	t.Helper()
+	select {
+	case cal := <-reloads:
+		t.Fatalf("unexpected reload with reference %s", cal.ReferenceVacuum)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestCalibrationWatcher_ReloadsChangedCache(t *testing.T) {
+	initial := &CalibrationData{ReferenceVacuum: "vac1", Vacuums: map[string]VacuumCalibration{"vac1": {Transform: Identity()}}}
+	path, reloads := startCalibrationWatcher(t, initial)
+
+	// Saving the cache the service already has is not a change
+	if err := SaveCalibration(path, initial); err != nil {
+		t.Fatal(err)
+	}
+	expectNoReload(t, reloads)
+
+	updated := &CalibrationData{ReferenceVacuum: "vac1", Vacuums: map[string]VacuumCalibration{
+		"vac1": {Transform: Identity()},
+		"vac2": {Transform: Translation(100, -50)},
+	}}
+	if err := SaveCalibration(path, updated); err != nil {
+		t.Fatal(err)
+	}
+	cal := expectReload(t, reloads)
+	if got, want := cal.Vacuums["vac2"].Transform, updated.Vacuums["vac2"].Transform; got != want {
+		t.Errorf("vac2 transform = %+v, want %+v", got, want)
+	}
+}
+
+func TestCalibrationWatcher_RetriesIncompleteWrites(t *testing.T) {
+	path, reloads := startCalibrationWatcher(t, nil)
+
+	if err := os.WriteFile(path, []byte(`{"referenceVacuum":"vac1","vacuums":{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoReload(t, reloads)
+
+	// Other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "config.yaml"), []byte("vacuums: []"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectNoReload(t, reloads)
+
+	if err := SaveCalibration(path, &CalibrationData{ReferenceVacuum: "vac1"}); err != nil {
+		t.Fatal(err)
+	}
+	if cal := expectReload(t, reloads); cal.ReferenceVacuum != "vac1" {
+		t.Errorf("reference = %q, want vac1", cal.ReferenceVacuum)
+	}
+}
@@ -0,0 +1,172 @@
+package mesh
+
+import (
+	"math"
+	"sync"
+
+	"github.com/paulmach/orb"
+)
+
+// DefaultChangeTolerance is how far (mm) a matched feature's bounds must move
+// before it counts as changed. Smaller shifts are refinement noise.
+const DefaultChangeTolerance = 50.0
+
+// changeMatchDistance is the centroid distance (mm) within which features of
+// two unified maps are taken to be the same, as in refineFeatures.
+const changeMatchDistance = 200.0
+
+// changeFeedBuffer is the number of changes queued per subscriber; a
+// subscriber that falls further behind misses changes.
+const changeFeedBuffer = 8
+
+// FeatureChanges counts the features of one category that appeared,
+// disappeared or moved between two versions of the unified map.
+type FeatureChanges struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// Any reports whether any feature changed.
+func (c FeatureChanges) Any() bool {
+	return c.Added > 0 || c.Removed > 0 || c.Changed > 0
+}
+
+// MapChange describes a new version of the unified map.
+type MapChange struct {
+	Version         int64          `json:"version"`
+	PreviousVersion int64          `json:"previousVersion"`
+	Timestamp       int64          `json:"timestamp"` // Unix seconds, the map's LastUpdated
+	Walls           FeatureChanges `json:"walls"`
+	Floors          FeatureChanges `json:"floors"`
+	Segments        FeatureChanges `json:"segments"`
+	Materials       FeatureChanges `json:"materials"`
+}
+
+// Material reports whether the geometry changed enough to re-fetch the map.
+func (c MapChange) Material() bool {
+	return c.Walls.Any() || c.Floors.Any() || c.Segments.Any() || c.Materials.Any()
+}
+
+// DiffUnifiedMaps summarizes how next differs from prev; a nil prev counts
+// every feature of next as added. Versions are left for the caller.
+func DiffUnifiedMaps(prev, next *UnifiedMap) MapChange {
+	var change MapChange
+	if next == nil {
+		return change
+	}
+	change.Timestamp = next.Metadata.LastUpdated
+	if prev == nil {
+		prev = &UnifiedMap{}
+	}
+	change.Walls = diffFeatures(prev.Walls, next.Walls)
+	change.Floors = diffFeatures(prev.Floors, next.Floors)
+	change.Segments = diffFeatures(prev.Segments, next.Segments)
+	change.Materials = diffFeatures(prev.Materials, next.Materials)
+	return change
+}
+
+// diffFeatures matches features by segment name, or else by the nearest
+// centroid within changeMatchDistance, and counts the unmatched and the
+// matched ones whose bounds moved by more than DefaultChangeTolerance.
+func diffFeatures(prev, next []*UnifiedFeature) FeatureChanges {
+	type entry struct {
+		name     string
+		centroid orb.Point
+		bound    orb.Bound
+		used     bool
+	}
+	index := func(f *UnifiedFeature) entry {
+		name, _ := f.Properties["segmentName"].(string)
+		c, _ := geometryCentroid(f.Geometry)
+		return entry{name: name, centroid: c, bound: geometryBound(f.Geometry)}
+	}
+	previous := make([]entry, len(prev))
+	for i, f := range prev {
+		previous[i] = index(f)
+	}
+
+	var changes FeatureChanges
+	for _, f := range next {
+		cur := index(f)
+		best, bestDist := -1, math.MaxFloat64
+		for i, p := range previous {
+			if p.used {
+				continue
+			}
+			if cur.name != "" && p.name == cur.name {
+				best = i
+				break
+			}
+			if d := math.Hypot(cur.centroid[0]-p.centroid[0], cur.centroid[1]-p.centroid[1]); d <= changeMatchDistance && d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		if best < 0 {
+			changes.Added++
+			continue
+		}
+		previous[best].used = true
+		if boundsMoved(previous[best].bound, cur.bound, DefaultChangeTolerance) {
+			changes.Changed++
+		}
+	}
+	for _, p := range previous {
+		if !p.used {
+			changes.Removed++
+		}
+	}
+	return changes
+}
+
+// boundsMoved reports whether any edge of two bounds differs by more than
+// tolerance.
+func boundsMoved(a, b orb.Bound, tolerance float64) bool {
+	return math.Abs(a.Min[0]-b.Min[0]) > tolerance || math.Abs(a.Min[1]-b.Min[1]) > tolerance ||
+		math.Abs(a.Max[0]-b.Max[0]) > tolerance || math.Abs(a.Max[1]-b.Max[1]) > tolerance
+}
+
+// ChangeFeed fans unified map changes out to subscribers such as the MQTT
+// publisher and HTTP event streams. Delivery never blocks the publisher: a
+// subscriber whose buffer is full misses the change, but every change
+// carries the full version so it can tell.
+type ChangeFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan MapChange]struct{}
+}
+
+// NewChangeFeed creates a feed with no subscribers.
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{subscribers: make(map[chan MapChange]struct{})}
+}
+
+// Subscribe returns a channel receiving every subsequent change and a
+// function that unsubscribes and closes it.
+func (f *ChangeFeed) Subscribe() (<-chan MapChange, func()) {
+	ch := make(chan MapChange, changeFeedBuffer)
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, ch)
+			f.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish delivers change to every subscriber with room in its buffer.
+func (f *ChangeFeed) Publish(change MapChange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package mesh
+
+import (
+	"testing"
+	"time"
+)
+
+// wallAt returns a unified horizontal wall from (x, y) to (x+1000, y).
+func wallAt(x, y float64) *UnifiedFeature {
+	return &UnifiedFeature{
+		Geometry:   &Geometry{Type: GeometryLineString, Coordinates: marshalCoordinate([][2]float64{{x, y}, {x + 1000, y}})},
+		Properties: map[string]interface{}{"layerType": "wall"},
+	}
+}
+
+// roomAt returns a unified 1x1 m segment named name with its corner at x.
+func roomAt(x float64, name string) *UnifiedFeature {
+	ring := [][][2]float64{{{x, 0}, {x + 1000, 0}, {x + 1000, 1000}, {x, 1000}, {x, 0}}}
+	return &UnifiedFeature{
+		Geometry:   &Geometry{Type: GeometryPolygon, Coordinates: marshalCoordinate(ring)},
+		Properties: map[string]interface{}{"layerType": "segment", "segmentName": name},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// DiffUnifiedMaps
+// ---------------------------------------------------------------------------
+
+func TestDiffUnifiedMaps(t *testing.T) {
+	base := &UnifiedMap{
+		Walls:    []*UnifiedFeature{wallAt(0, 0), wallAt(0, 3000)},
+		Segments: []*UnifiedFeature{roomAt(0, "Kitchen")},
+	}
+
+	tests := []struct {
+		name     string
+		prev     *UnifiedMap
+		next     *UnifiedMap
+		walls    FeatureChanges
+		segments FeatureChanges
+	}{
+		{"first map", nil, base, FeatureChanges{Added: 2}, FeatureChanges{Added: 1}},
+		{"identical", base, base, FeatureChanges{}, FeatureChanges{}},
+		{
+			"refinement noise",
+			base,
+			&UnifiedMap{Walls: []*UnifiedFeature{wallAt(20, 0), wallAt(0, 3010)}, Segments: []*UnifiedFeature{roomAt(30, "Kitchen")}},
+			FeatureChanges{}, FeatureChanges{},
+		},
+		{
+			"wall moved and one removed",
+			base,
+			&UnifiedMap{Walls: []*UnifiedFeature{wallAt(0, 150)}, Segments: []*UnifiedFeature{roomAt(0, "Kitchen")}},
+			FeatureChanges{Removed: 1, Changed: 1}, FeatureChanges{},
+		},
+		{
+			"segment matched by name",
+			base,
+			&UnifiedMap{Walls: base.Walls, Segments: []*UnifiedFeature{roomAt(5000, "Kitchen"), roomAt(8000, "Hall")}},
+			FeatureChanges{}, FeatureChanges{Added: 1, Changed: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := DiffUnifiedMaps(tt.prev, tt.next)
+			if change.Walls != tt.walls {
+				t.Errorf("walls = %+v, want %+v", change.Walls, tt.walls)
+			}
+			if change.Segments != tt.segments {
+				t.Errorf("segments = %+v, want %+v", change.Segments, tt.segments)
+			}
+			if want := tt.walls.Any() || tt.segments.Any(); change.Material() != want {
+				t.Errorf("Material() = %v, want %v", change.Material(), want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ChangeFeed
+// ---------------------------------------------------------------------------
+
+func TestChangeFeed(t *testing.T) {
+	feed := NewChangeFeed()
+	a, unsubscribeA := feed.Subscribe()
+	b, unsubscribeB := feed.Subscribe()
+	defer unsubscribeB()
+
+	feed.Publish(MapChange{Version: 1})
+	for name, ch := range map[string]<-chan MapChange{"a": a, "b": b} {
+		if got := <-ch; got.Version != 1 {
+			t.Errorf("%s received version %d, want 1", name, got.Version)
+		}
+	}
+
+	unsubscribeA()
+	unsubscribeA() // idempotent
+	if _, ok := <-a; ok {
+		t.Error("unsubscribed channel should be closed")
+	}
+
+	// A full buffer drops changes instead of blocking
+	for i := 0; i < changeFeedBuffer+5; i++ {
+		feed.Publish(MapChange{Version: int64(i + 2)})
+	}
+	if len(b) != changeFeedBuffer {
+		t.Errorf("buffered %d changes, want %d", len(b), changeFeedBuffer)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// StateTracker versioning
+// ---------------------------------------------------------------------------
+
+func TestStateTracker_UnifiedMapVersion(t *testing.T) {
+	st := NewStateTracker()
+	changes, unsubscribe := st.Changes().Subscribe()
+	defer unsubscribe()
+
+	var floor []int
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			floor = append(floor, x, y)
+		}
+	}
+	st.UpdateMap("vac-1", makeTestMap(5, floor, nil, nil, ""))
+	calibData := &CalibrationData{
+		ReferenceVacuum: "vac-1",
+		Vacuums:         map[string]VacuumCalibration{"vac-1": {Transform: Identity()}},
+	}
+
+	if err := st.UpdateUnifiedMap(calibData); err != nil {
+		t.Fatalf("UpdateUnifiedMap: %v", err)
+	}
+	if v := st.GetUnifiedMap().Metadata.Version; v != 1 {
+		t.Errorf("first version = %d, want 1", v)
+	}
+	select {
+	case change := <-changes:
+		if change.Version != 1 || change.PreviousVersion != 0 || change.Floors.Added != 1 {
+			t.Errorf("first change = %+v, want version 1 with one floor added", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change published for the first map")
+	}
+
+	// Same data: no new version, no event
+	if err := st.UpdateUnifiedMap(calibData); err != nil {
+		t.Fatalf("UpdateUnifiedMap: %v", err)
+	}
+	if v := st.GetUnifiedMap().Metadata.Version; v != 1 {
+		t.Errorf("unchanged version = %d, want 1", v)
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("unexpected change %+v for identical geometry", change)
+	default:
+	}
+
+	// A replicated map with a newer version is announced as is
+	st.SetUnifiedMap(&UnifiedMap{Metadata: UnifiedMetadata{Version: 7}})
+	select {
+	case change := <-changes:
+		if change.Version != 7 || change.PreviousVersion != 1 || change.Floors.Removed != 1 {
+			t.Errorf("replicated change = %+v, want 1 -> 7 with the floor removed", change)
+		}
+	default:
+		t.Error("no change published for the replicated map")
+	}
+}
@@ -0,0 +1,429 @@
+package mesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// DefaultClusterHeartbeat is how often the leader refreshes its lock.
+	DefaultClusterHeartbeat = 10 * time.Second
+
+	// DefaultClusterLease is how long a lock stays valid without a heartbeat
+	// before a standby instance may take over.
+	DefaultClusterLease = 30 * time.Second
+)
+
+// clusterLock is the retained payload of the leader lock topic.
+type clusterLock struct {
+	Instance  string `json:"instance"`
+	Heartbeat int64  `json:"heartbeat"` // unix seconds
+}
+
+// clusterState is the retained envelope for replicated calibration and
+// unified-map state, tagged with the publishing instance.
+type clusterState struct {
+	Instance  string          `json:"instance"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Coordinator lets a redundant group of tudomesh instances share one MQTT
+// broker. Instances elect a leader through a retained lock topic refreshed by
+// heartbeat; only the leader should publish positions and run calibration.
+// The leader publishes calibration and unified-map state as retained
+// messages, which standby instances consume so they can take over warm.
+type Coordinator struct {
+	client     MQTTClientInterface
+	instanceID string
+	prefix     string
+	heartbeat  time.Duration
+	lease      time.Duration
+	now        func() time.Time
+
+	mu            sync.RWMutex
+	holder        string
+	holderBeat    time.Time
+	leader        bool
+	onLeadership  func(leader bool)
+	onCalibration func(*CalibrationData)
+	onUnifiedMap  func(*UnifiedMap)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCoordinator creates a coordinator for the given cluster config. Topics
+// live under {publishPrefix}/cluster/. The instance ID defaults to the host
+// name so that each container in a pair is distinct without extra config.
+func NewCoordinator(client MQTTClientInterface, cfg ClusterConfig, publishPrefix string) *Coordinator {
+	if publishPrefix == "" {
+		publishPrefix = "tudomesh"
+	}
+	id := cfg.InstanceID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	if id == "" {
+		id = fmt.Sprintf("tudomesh-%d", os.Getpid())
+	}
+
+	heartbeat := DefaultClusterHeartbeat
+	if cfg.HeartbeatSeconds > 0 {
+		heartbeat = time.Duration(cfg.HeartbeatSeconds) * time.Second
+	}
+	lease := DefaultClusterLease
+	if cfg.LeaseSeconds > 0 {
+		lease = time.Duration(cfg.LeaseSeconds) * time.Second
+	}
+	if lease <= heartbeat {
+		lease = 3 * heartbeat
+	}
+
+	return &Coordinator{
+		client:     client,
+		instanceID: id,
+		prefix:     publishPrefix,
+		heartbeat:  heartbeat,
+		lease:      lease,
+		now:        time.Now,
+	}
+}
+
+// InstanceID returns the identifier this instance uses in the lock topic.
+func (c *Coordinator) InstanceID() string {
+	return c.instanceID
+}
+
+// IsLeader reports whether this instance currently holds the leader lock.
+func (c *Coordinator) IsLeader() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.leader
+}
+
+// Leader returns the instance ID of the current lock holder, or "" if the
+// lock is free or expired.
+func (c *Coordinator) Leader() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.holder == "" || c.now().Sub(c.holderBeat) > c.lease {
+		return ""
+	}
+	return c.holder
+}
+
+// SetLeadershipHandler registers a callback invoked when this instance gains
+// or loses leadership.
+func (c *Coordinator) SetLeadershipHandler(handler func(leader bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onLeadership = handler
+}
+
+// SetCalibrationHandler registers a callback for calibration published by
+// another instance.
+func (c *Coordinator) SetCalibrationHandler(handler func(*CalibrationData)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onCalibration = handler
+}
+
+// SetUnifiedMapHandler registers a callback for a unified map published by
+// another instance.
+func (c *Coordinator) SetUnifiedMapHandler(handler func(*UnifiedMap)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onUnifiedMap = handler
+}
+
+// lockTopic returns the retained leader lock topic.
+func (c *Coordinator) lockTopic() string {
+	return fmt.Sprintf("%s/cluster/leader", c.prefix)
+}
+
+// calibrationTopic returns the retained calibration state topic.
+func (c *Coordinator) calibrationTopic() string {
+	return fmt.Sprintf("%s/cluster/calibration", c.prefix)
+}
+
+// unifiedMapTopic returns the retained unified map state topic.
+func (c *Coordinator) unifiedMapTopic() string {
+	return fmt.Sprintf("%s/cluster/unified-map", c.prefix)
+}
+
+// Subscribe subscribes to the cluster topics. It must be called on every
+// (re)connect; register it with MQTTClient.AddConnectHandler.
+func (c *Coordinator) Subscribe(client MQTTClientInterface) {
+	subs := map[string]mqtt.MessageHandler{
+		c.lockTopic():        c.handleLock,
+		c.calibrationTopic(): c.handleCalibration,
+		c.unifiedMapTopic():  c.handleUnifiedMap,
+	}
+	for topic, handler := range subs {
+		token := client.Subscribe(topic, 1, handler)
+		if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+			log.Printf("[CLUSTER] Error subscribing to %s: %v", topic, token.Error())
+		}
+	}
+}
+
+// Start begins the heartbeat loop. The first election attempt waits one
+// heartbeat so a retained lock from a running leader is seen before this
+// instance tries to claim it.
+func (c *Coordinator) Start() {
+	c.mu.Lock()
+	if c.stop != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	stop, done := c.stop, c.done
+	c.mu.Unlock()
+
+	log.Printf("[CLUSTER] Instance %s joining cluster (heartbeat %v, lease %v)", c.instanceID, c.heartbeat, c.lease)
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.tick()
+			}
+		}
+	}()
+}
+
+// Stop ends the heartbeat loop and releases the lock if held so a standby can
+// take over immediately instead of waiting for the lease to expire.
+func (c *Coordinator) Stop() {
+	c.mu.Lock()
+	stop, done := c.stop, c.done
+	c.stop, c.done = nil, nil
+	wasLeader := c.leader
+	c.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	if wasLeader {
+		c.publish(c.lockTopic(), []byte{})
+		c.setLeader(false)
+		log.Printf("[CLUSTER] Instance %s released leadership", c.instanceID)
+	}
+}
+
+// tick runs one election round: refresh the lock if we hold it, claim it if
+// it is free or expired, otherwise stay on standby.
+func (c *Coordinator) tick() {
+	now := c.now()
+
+	c.mu.RLock()
+	holder, beat := c.holder, c.holderBeat
+	c.mu.RUnlock()
+
+	free := holder == "" || now.Sub(beat) > c.lease
+	if holder != c.instanceID && !free {
+		c.setLeader(false)
+		return
+	}
+	if holder != c.instanceID {
+		log.Printf("[CLUSTER] Lock free (last holder %q), %s claiming leadership", holder, c.instanceID)
+	}
+
+	payload, err := json.Marshal(clusterLock{Instance: c.instanceID, Heartbeat: now.Unix()})
+	if err != nil {
+		log.Printf("[CLUSTER] Error marshaling lock: %v", err)
+		return
+	}
+	if err := c.publish(c.lockTopic(), payload); err != nil {
+		log.Printf("[CLUSTER] Error publishing heartbeat: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.holder, c.holderBeat = c.instanceID, now
+	c.mu.Unlock()
+	c.setLeader(true)
+}
+
+// handleLock records the current lock holder. When two instances claim the
+// lock at once, the lower instance ID wins and the other steps down.
+func (c *Coordinator) handleLock(_ mqtt.Client, msg mqtt.Message) {
+	payload := msg.Payload()
+	if len(payload) == 0 {
+		// Lock released
+		c.mu.Lock()
+		c.holder, c.holderBeat = "", time.Time{}
+		c.mu.Unlock()
+		return
+	}
+
+	var lock clusterLock
+	if err := json.Unmarshal(payload, &lock); err != nil {
+		log.Printf("[CLUSTER] Ignoring malformed lock payload: %v", err)
+		return
+	}
+	if lock.Instance == c.instanceID {
+		return
+	}
+
+	c.mu.Lock()
+	if c.leader && lock.Instance > c.instanceID {
+		// We keep the lock; our next heartbeat overwrites the competing claim.
+		c.mu.Unlock()
+		return
+	}
+	c.holder, c.holderBeat = lock.Instance, time.Unix(lock.Heartbeat, 0)
+	c.mu.Unlock()
+
+	if c.now().Sub(time.Unix(lock.Heartbeat, 0)) <= c.lease {
+		c.setLeader(false)
+	}
+}
+
+// handleCalibration passes calibration from another instance to the
+// registered handler.
+func (c *Coordinator) handleCalibration(_ mqtt.Client, msg mqtt.Message) {
+	data, ok := c.decodeState(msg)
+	if !ok {
+		return
+	}
+	var cal CalibrationData
+	if err := json.Unmarshal(data, &cal); err != nil {
+		log.Printf("[CLUSTER] Ignoring malformed calibration state: %v", err)
+		return
+	}
+
+	c.mu.RLock()
+	handler := c.onCalibration
+	c.mu.RUnlock()
+	if handler != nil {
+		handler(&cal)
+	}
+}
+
+// handleUnifiedMap passes a unified map from another instance to the
+// registered handler.
+func (c *Coordinator) handleUnifiedMap(_ mqtt.Client, msg mqtt.Message) {
+	data, ok := c.decodeState(msg)
+	if !ok {
+		return
+	}
+	var um UnifiedMap
+	if err := json.Unmarshal(data, &um); err != nil {
+		log.Printf("[CLUSTER] Ignoring malformed unified map state: %v", err)
+		return
+	}
+
+	c.mu.RLock()
+	handler := c.onUnifiedMap
+	c.mu.RUnlock()
+	if handler != nil {
+		handler(&um)
+	}
+}
+
+// decodeState unwraps a replicated state envelope, skipping empty payloads
+// and our own messages.
+func (c *Coordinator) decodeState(msg mqtt.Message) (json.RawMessage, bool) {
+	payload := msg.Payload()
+	if len(payload) == 0 {
+		return nil, false
+	}
+	var state clusterState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		log.Printf("[CLUSTER] Ignoring malformed state on %s: %v", msg.Topic(), err)
+		return nil, false
+	}
+	if state.Instance == c.instanceID || len(state.Data) == 0 {
+		return nil, false
+	}
+	log.Printf("[CLUSTER] Received %s from %s", msg.Topic(), state.Instance)
+	return state.Data, true
+}
+
+// PublishCalibration publishes calibration as retained state. Only the
+// leader publishes; on standby instances this is a no-op.
+func (c *Coordinator) PublishCalibration(cal *CalibrationData) error {
+	if cal == nil {
+		return nil
+	}
+	return c.publishState(c.calibrationTopic(), cal)
+}
+
+// PublishUnifiedMap publishes the unified map as retained state. Only the
+// leader publishes; on standby instances this is a no-op.
+func (c *Coordinator) PublishUnifiedMap(um *UnifiedMap) error {
+	if um == nil {
+		return nil
+	}
+	return c.publishState(c.unifiedMapTopic(), um)
+}
+
+// publishState wraps v in a clusterState envelope and publishes it retained.
+func (c *Coordinator) publishState(topic string, v interface{}) error {
+	if !c.IsLeader() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", topic, err)
+	}
+	payload, err := json.Marshal(clusterState{
+		Instance:  c.instanceID,
+		Timestamp: c.now().Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling %s envelope: %w", topic, err)
+	}
+	return c.publish(topic, payload)
+}
+
+// publish sends a retained QoS 1 message.
+func (c *Coordinator) publish(topic string, payload []byte) error {
+	if c.client == nil || !c.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+	token := c.client.Publish(topic, 1, true, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+	return nil
+}
+
+// setLeader updates leadership and fires the handler on change.
+func (c *Coordinator) setLeader(leader bool) {
+	c.mu.Lock()
+	changed := c.leader != leader
+	c.leader = leader
+	handler := c.onLeadership
+	c.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		log.Printf("[CLUSTER] Instance %s is now leader", c.instanceID)
+	} else {
+		log.Printf("[CLUSTER] Instance %s is now standby", c.instanceID)
+	}
+	if handler != nil {
+		handler(leader)
+	}
+}
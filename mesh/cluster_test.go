@@ -0,0 +1,244 @@
+package mesh
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// newTestCoordinator creates a subscribed coordinator with a controllable clock.
+func newTestCoordinator(t *testing.T, id string) (*Coordinator, *MockClient, *time.Time) {
+	t.Helper()
+	client := NewMockClient()
+	c := NewCoordinator(client, ClusterConfig{InstanceID: id, HeartbeatSeconds: 10, LeaseSeconds: 30}, "tudomesh")
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+	c.Subscribe(client)
+	return c, client, &now
+}
+
+func lockPayload(t *testing.T, instance string, beat time.Time) []byte {
+	t.Helper()
+	data, err := json.Marshal(clusterLock{Instance: instance, Heartbeat: beat.Unix()})
+	if err != nil {
+		t.Fatalf("marshal lock: %v", err)
+	}
+	return data
+}
+
+func publishedTo(client *MockClient, topic string) []MockMessage {
+	var out []MockMessage
+	for _, m := range client.GetPublishedMessages() {
+		if m.Topic == topic {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Election
+// ---------------------------------------------------------------------------
+
+func TestCoordinator_ClaimsFreeLock(t *testing.T) {
+	c, client, _ := newTestCoordinator(t, "a")
+
+	c.tick()
+
+	if !c.IsLeader() {
+		t.Fatal("expected leadership when no lock is held")
+	}
+	msgs := publishedTo(client, "tudomesh/cluster/leader")
+	if len(msgs) != 1 || !msgs[0].Retain || msgs[0].QoS != 1 {
+		t.Fatalf("expected one retained QoS 1 lock message, got %+v", msgs)
+	}
+	if got := c.Leader(); got != "a" {
+		t.Errorf("Leader() = %q, want a", got)
+	}
+}
+
+func TestCoordinator_StandbyWhileLockFresh(t *testing.T) {
+	c, client, now := newTestCoordinator(t, "b")
+
+	client.SimulateMessage("tudomesh/cluster/leader", lockPayload(t, "a", *now))
+	c.tick()
+
+	if c.IsLeader() {
+		t.Fatal("should stay standby while another instance holds a fresh lock")
+	}
+	if msgs := publishedTo(client, "tudomesh/cluster/leader"); len(msgs) != 0 {
+		t.Errorf("standby should not publish heartbeats, got %d", len(msgs))
+	}
+}
+
+func TestCoordinator_TakesOverExpiredLock(t *testing.T) {
+	c, client, now := newTestCoordinator(t, "b")
+
+	client.SimulateMessage("tudomesh/cluster/leader", lockPayload(t, "a", *now))
+	*now = now.Add(31 * time.Second)
+	c.tick()
+
+	if !c.IsLeader() {
+		t.Fatal("expected takeover once the lease expired")
+	}
+}
+
+func TestCoordinator_TakesOverReleasedLock(t *testing.T) {
+	c, client, now := newTestCoordinator(t, "b")
+
+	client.SimulateMessage("tudomesh/cluster/leader", lockPayload(t, "a", *now))
+	client.SimulateMessage("tudomesh/cluster/leader", []byte{})
+	c.tick()
+
+	if !c.IsLeader() {
+		t.Fatal("expected takeover after the lock was released")
+	}
+}
+
+func TestCoordinator_ConflictLowestIDWins(t *testing.T) {
+	tests := []struct {
+		name       string
+		self       string
+		other      string
+		wantLeader bool
+	}{
+		{"competitor has lower id", "b", "a", false},
+		{"competitor has higher id", "a", "b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, client, now := newTestCoordinator(t, tt.self)
+			c.tick()
+
+			client.SimulateMessage("tudomesh/cluster/leader", lockPayload(t, tt.other, *now))
+
+			if got := c.IsLeader(); got != tt.wantLeader {
+				t.Errorf("IsLeader() = %v, want %v", got, tt.wantLeader)
+			}
+		})
+	}
+}
+
+func TestCoordinator_LeadershipHandler(t *testing.T) {
+	c, client, now := newTestCoordinator(t, "b")
+
+	var changes []bool
+	c.SetLeadershipHandler(func(leader bool) { changes = append(changes, leader) })
+
+	c.tick()
+	c.tick() // refresh, no change
+	client.SimulateMessage("tudomesh/cluster/leader", lockPayload(t, "a", *now))
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("leadership changes = %v, want [true false]", changes)
+	}
+}
+
+func TestCoordinator_StopReleasesLock(t *testing.T) {
+	c, client, _ := newTestCoordinator(t, "a")
+	c.Start()
+	c.tick()
+
+	c.Stop()
+
+	if c.IsLeader() {
+		t.Error("should not be leader after Stop")
+	}
+	msgs := publishedTo(client, "tudomesh/cluster/leader")
+	if len(msgs) == 0 || len(msgs[len(msgs)-1].Payload) != 0 {
+		t.Errorf("expected empty retained lock on release, got %+v", msgs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// State replication
+// ---------------------------------------------------------------------------
+
+func TestCoordinator_PublishStateOnlyWhenLeader(t *testing.T) {
+	c, client, _ := newTestCoordinator(t, "a")
+	cal := &CalibrationData{ReferenceVacuum: "ref"}
+
+	if err := c.PublishCalibration(cal); err != nil {
+		t.Fatalf("PublishCalibration (standby): %v", err)
+	}
+	if msgs := publishedTo(client, "tudomesh/cluster/calibration"); len(msgs) != 0 {
+		t.Fatalf("standby published calibration: %+v", msgs)
+	}
+
+	c.tick()
+	if err := c.PublishCalibration(cal); err != nil {
+		t.Fatalf("PublishCalibration (leader): %v", err)
+	}
+	if err := c.PublishUnifiedMap(&UnifiedMap{}); err != nil {
+		t.Fatalf("PublishUnifiedMap (leader): %v", err)
+	}
+	if msgs := publishedTo(client, "tudomesh/cluster/calibration"); len(msgs) != 1 || !msgs[0].Retain {
+		t.Fatalf("expected one retained calibration message, got %+v", msgs)
+	}
+	if msgs := publishedTo(client, "tudomesh/cluster/unified-map"); len(msgs) != 1 {
+		t.Fatalf("expected one unified map message, got %+v", msgs)
+	}
+}
+
+func TestCoordinator_ConsumesPeerState(t *testing.T) {
+	leader, leaderClient, _ := newTestCoordinator(t, "a")
+	leader.tick()
+	if err := leader.PublishCalibration(&CalibrationData{ReferenceVacuum: "ref"}); err != nil {
+		t.Fatalf("PublishCalibration: %v", err)
+	}
+	if err := leader.PublishUnifiedMap(&UnifiedMap{Metadata: UnifiedMetadata{ReferenceVacuum: "ref"}}); err != nil {
+		t.Fatalf("PublishUnifiedMap: %v", err)
+	}
+	calMsg := publishedTo(leaderClient, "tudomesh/cluster/calibration")[0]
+	umMsg := publishedTo(leaderClient, "tudomesh/cluster/unified-map")[0]
+
+	standby, standbyClient, _ := newTestCoordinator(t, "b")
+	var gotCal *CalibrationData
+	var gotUM *UnifiedMap
+	standby.SetCalibrationHandler(func(cal *CalibrationData) { gotCal = cal })
+	standby.SetUnifiedMapHandler(func(um *UnifiedMap) { gotUM = um })
+
+	standbyClient.SimulateMessage(calMsg.Topic, calMsg.Payload)
+	standbyClient.SimulateMessage(umMsg.Topic, umMsg.Payload)
+
+	if gotCal == nil || gotCal.ReferenceVacuum != "ref" {
+		t.Errorf("standby calibration = %+v, want reference ref", gotCal)
+	}
+	if gotUM == nil || gotUM.Metadata.ReferenceVacuum != "ref" {
+		t.Errorf("standby unified map = %+v, want reference ref", gotUM)
+	}
+
+	// The leader ignores its own retained state
+	var echoed bool
+	leader.SetCalibrationHandler(func(*CalibrationData) { echoed = true })
+	leaderClient.SimulateMessage(calMsg.Topic, calMsg.Payload)
+	if echoed {
+		t.Error("coordinator should ignore state it published itself")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// MQTTClient connect hooks
+// ---------------------------------------------------------------------------
+
+func TestOnConnect_RunsConnectHandlers(t *testing.T) {
+	mockClient := NewMockClient()
+	client := newMQTTClientWithMock(mockClient, &Config{}, nil)
+
+	c := NewCoordinator(mockClient, ClusterConfig{InstanceID: "a"}, "")
+	client.AddConnectHandler(c.Subscribe)
+	client.onConnect(mockClient)
+
+	mockClient.mu.RLock()
+	defer mockClient.mu.RUnlock()
+	for _, topic := range []string{"tudomesh/cluster/leader", "tudomesh/cluster/calibration", "tudomesh/cluster/unified-map"} {
+		if _, ok := mockClient.messageHandlers[topic]; !ok {
+			t.Errorf("expected subscription to %s", topic)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultPositionsDebounce is how long position updates are collected
+// before the combined positions document is published.
+const DefaultPositionsDebounce = 250 * time.Millisecond
+
+// CombinedPositions is the state of every vacuum in one document, published
+// to {prefix}/positions so consumers need not stitch per-vacuum topics
+// together.
+type CombinedPositions struct {
+	Timestamp int64              `json:"timestamp"` // Unix seconds the document was built
+	Vacuums   []CombinedPosition `json:"vacuums"`   // sorted by vacuum ID
+}
+
+// CombinedPosition is one vacuum in CombinedPositions. Coordinates are
+// world mm, unlike the grid units of the per-vacuum topics.
+type CombinedPosition struct {
+	VacuumID    string  `json:"vacuumId"`
+	DisplayName string  `json:"displayName,omitempty"`
+	X           float64 `json:"x"`
+	Y           float64 `json:"y"`
+	Angle       float64 `json:"angle"`             // degrees, 0 = East, CCW
+	Room        string  `json:"room,omitempty"`    // unified segment the vacuum is in
+	Battery     *int    `json:"battery,omitempty"` // percent
+	Timestamp   int64   `json:"timestamp"`         // Unix seconds of the position
+	Age         float64 `json:"age"`               // seconds between the position and the document
+}
+
+// CombinedPositions returns every vacuum's latest position as of now, in
+// world mm and with the unified room it lies in.
+func (st *StateTracker) CombinedPositions(now time.Time) CombinedPositions {
+	positions := st.GetPositions()
+	st.mu.RLock()
+	pixelSizes := make(map[string]float64, len(positions))
+	for id := range positions {
+		pixelSizes[id] = 5.0
+		if m := st.maps[id]; m != nil && m.PixelSize > 0 {
+			pixelSizes[id] = float64(m.PixelSize)
+		}
+	}
+	st.mu.RUnlock()
+	index := st.SegmentIndex()
+
+	ids := make([]string, 0, len(positions))
+	for id := range positions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	combined := CombinedPositions{Timestamp: now.Unix(), Vacuums: make([]CombinedPosition, 0, len(ids))}
+	for _, id := range ids {
+		pos := positions[id]
+		world := Point{X: math.Round(pos.X * pixelSizes[id]), Y: math.Round(pos.Y * pixelSizes[id])}
+		cp := CombinedPosition{
+			VacuumID:    id,
+			DisplayName: pos.DisplayName,
+			X:           world.X,
+			Y:           world.Y,
+			Angle:       pos.Angle,
+			Battery:     pos.Battery,
+			Timestamp:   pos.Timestamp.Unix(),
+			Age:         math.Round(now.Sub(pos.Timestamp).Seconds()*10) / 10,
+		}
+		if seg, ok := index.SegmentAt(world); ok {
+			cp.Room = seg.Name
+		}
+		combined.Vacuums = append(combined.Vacuums, cp)
+	}
+	return combined
+}
@@ -0,0 +1,52 @@
+package mesh
+
+import (
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// CombinedPositions
+// ---------------------------------------------------------------------------
+
+func TestStateTracker_CombinedPositions(t *testing.T) {
+	st := NewStateTracker()
+	um := unifiedRoom([][4]float64{{0, 0, 4000, 3000}})
+	um.Segments[0].Properties = map[string]interface{}{"segmentName": "Kitchen"}
+	st.SetUnifiedMap(um)
+	st.UpdateMap("vac2", &ValetudoMap{PixelSize: 10, Layers: []MapLayer{{Type: "floor", Pixels: []int{0, 0}}}})
+	st.SetDisplayName("vac1", "Upstairs")
+
+	st.UpdatePosition("vac2", 800, 100, 180) // 8000mm, outside the kitchen
+	st.UpdatePosition("vac1", 200, 100, 90)  // default 5mm grid: (1000, 500) in the kitchen
+	st.UpdateBattery("vac1", 64)
+	now := time.Now().Add(1500 * time.Millisecond)
+
+	got := st.CombinedPositions(now)
+	if got.Timestamp != now.Unix() || len(got.Vacuums) != 2 {
+		t.Fatalf("CombinedPositions() = %+v, want two vacuums at %d", got, now.Unix())
+	}
+	v1, v2 := got.Vacuums[0], got.Vacuums[1]
+	if v1.VacuumID != "vac1" || v2.VacuumID != "vac2" {
+		t.Fatalf("order = %s, %s, want vac1, vac2", v1.VacuumID, v2.VacuumID)
+	}
+	if v1.X != 1000 || v1.Y != 500 || v1.Angle != 90 || v1.Room != "Kitchen" || v1.DisplayName != "Upstairs" {
+		t.Errorf("vac1 = %+v, want (1000, 500) facing 90° in the Kitchen", v1)
+	}
+	if v1.Battery == nil || *v1.Battery != 64 {
+		t.Errorf("vac1 battery = %v, want 64", v1.Battery)
+	}
+	if v1.Age < 1.4 || v1.Age > 2 {
+		t.Errorf("vac1 age = %g, want about 1.5s", v1.Age)
+	}
+	if v2.X != 8000 || v2.Y != 1000 || v2.Room != "" || v2.Battery != nil {
+		t.Errorf("vac2 = %+v, want (8000, 1000) outside every room", v2)
+	}
+}
+
+func TestStateTracker_CombinedPositionsEmpty(t *testing.T) {
+	got := NewStateTracker().CombinedPositions(time.Now())
+	if got.Vacuums == nil || len(got.Vacuums) != 0 {
+		t.Errorf("Vacuums = %#v, want an empty list", got.Vacuums)
+	}
+}
@@ -0,0 +1,87 @@
+package mesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// CommandPublisher sends commands to Valetudo robots over MQTT.
+type CommandPublisher struct {
+	client  MQTTClientInterface
+	config  *Config
+	vacuums map[string]MQTTClientInterface // vacuums on their own broker
+}
+
+// NewCommandPublisher creates a publisher for the vacuums in config. Command
+// topics are derived from each vacuum's map data topic.
+func NewCommandPublisher(client MQTTClientInterface, config *Config) *CommandPublisher {
+	return &CommandPublisher{client: client, config: config}
+}
+
+// SetVacuumClients sends the commands of the given vacuums on their own
+// broker connections, as returned by MQTTClient.VacuumClients.
+func (p *CommandPublisher) SetVacuumClients(clients map[string]MQTTClientInterface) {
+	p.vacuums = clients
+}
+
+// clientFor returns the connection to the broker of vacuumID.
+func (p *CommandPublisher) clientFor(vacuumID string) MQTTClientInterface {
+	if c, ok := p.vacuums[vacuumID]; ok {
+		return c
+	}
+	return p.client
+}
+
+// deriveCommandTopic converts a map data topic to a capability command topic.
+// Example: "valetudo/rocky7/MapData/map-data" with "ZoneCleaningCapability",
+// "start" -> "valetudo/rocky7/ZoneCleaningCapability/start/set"
+func deriveCommandTopic(mapDataTopic, capability, command string) (string, bool) {
+	parts := strings.Split(mapDataTopic, "/")
+	if len(parts) < 3 {
+		return "", false
+	}
+	base := strings.Join(parts[:len(parts)-2], "/")
+	return fmt.Sprintf("%s/%s/%s/set", base, capability, command), true
+}
+
+// zoneCleaningPayload is the body of a ZoneCleaningCapability start command.
+type zoneCleaningPayload struct {
+	Zones      []ValetudoZone `json:"zones"`
+	Iterations int            `json:"iterations"`
+}
+
+// StartZoneCleaning publishes a plan's zones to its vacuum. Commands are
+// never retained, so a robot that reconnects later does not start cleaning.
+func (p *CommandPublisher) StartZoneCleaning(plan ZoneCleanPlan) error {
+	client := p.clientFor(plan.VacuumID)
+	if client == nil || !client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+	var vc *VacuumConfig
+	if p.config != nil {
+		vc = p.config.GetVacuumByID(plan.VacuumID)
+	}
+	if vc == nil {
+		return fmt.Errorf("vacuum %s is not configured", plan.VacuumID)
+	}
+	topic, ok := deriveCommandTopic(vc.Topic, "ZoneCleaningCapability", "start")
+	if !ok {
+		return fmt.Errorf("cannot derive command topic from %q", vc.Topic)
+	}
+
+	payload, err := json.Marshal(zoneCleaningPayload{Zones: plan.Zones, Iterations: plan.Iterations})
+	if err != nil {
+		return fmt.Errorf("marshaling zone command: %w", err)
+	}
+
+	token := client.Publish(topic, 1, false, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+
+	log.Printf("Sent %d cleaning zone(s) to %s", len(plan.Zones), p.config.DisplayName(plan.VacuumID))
+	return nil
+}
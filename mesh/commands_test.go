@@ -0,0 +1,76 @@
+package mesh
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeriveCommandTopic(t *testing.T) {
+	got, ok := deriveCommandTopic("valetudo/rocky7/MapData/map-data", "ZoneCleaningCapability", "start")
+	if !ok || got != "valetudo/rocky7/ZoneCleaningCapability/start/set" {
+		t.Errorf("deriveCommandTopic = %q, %v", got, ok)
+	}
+	if _, ok := deriveCommandTopic("map-data", "ZoneCleaningCapability", "start"); ok {
+		t.Error("expected failure for a topic without a prefix")
+	}
+}
+
+func TestCommandPublisher_StartZoneCleaning(t *testing.T) {
+	client := NewMockClient()
+	config := &Config{Vacuums: []VacuumConfig{{ID: "rocky", Topic: "valetudo/rocky/MapData/map-data"}}}
+	p := NewCommandPublisher(client, config)
+
+	plan := ZoneCleanPlan{
+		VacuumID:   "rocky",
+		Iterations: 2,
+		Zones: []ValetudoZone{{Points: ValetudoZonePoints{
+			PA: ValetudoPoint{0, 0}, PB: ValetudoPoint{10, 0}, PC: ValetudoPoint{10, 20}, PD: ValetudoPoint{0, 20},
+		}}},
+	}
+	if err := p.StartZoneCleaning(plan); err != nil {
+		t.Fatalf("StartZoneCleaning: %v", err)
+	}
+
+	client.AssertCalled(t, "Publish", "valetudo/rocky/ZoneCleaningCapability/start/set", byte(1), false, mock.Anything)
+	var payload map[string]interface{}
+	for _, call := range client.Calls {
+		if call.Method == "Publish" {
+			if err := json.Unmarshal(call.Arguments.Get(3).([]byte), &payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if payload["iterations"] != float64(2) {
+		t.Errorf("iterations = %v, want 2", payload["iterations"])
+	}
+	zones := payload["zones"].([]interface{})
+	pc := zones[0].(map[string]interface{})["points"].(map[string]interface{})["pC"].(map[string]interface{})
+	if pc["x"] != float64(10) || pc["y"] != float64(20) {
+		t.Errorf("pC = %v, want (10, 20)", pc)
+	}
+
+	if err := p.StartZoneCleaning(ZoneCleanPlan{VacuumID: "unknown"}); err == nil {
+		t.Error("expected error for an unconfigured vacuum")
+	}
+}
+
+func TestCommandPublisher_VacuumClients(t *testing.T) {
+	main, iot := NewMockClient(), NewMockClient()
+	config := &Config{Vacuums: []VacuumConfig{
+		{ID: "rocky", Topic: "valetudo/rocky/MapData/map-data"},
+		{ID: "dusty", Topic: "valetudo/dusty/MapData/map-data", MQTT: &BrokerConfig{Broker: "tcp://iot:1883"}},
+	}}
+	p := NewCommandPublisher(main, config)
+	p.SetVacuumClients(map[string]MQTTClientInterface{"dusty": iot})
+
+	for _, id := range []string{"rocky", "dusty"} {
+		if err := p.StartZoneCleaning(ZoneCleanPlan{VacuumID: id, Iterations: 1}); err != nil {
+			t.Fatalf("StartZoneCleaning(%s): %v", id, err)
+		}
+	}
+	main.AssertCalled(t, "Publish", "valetudo/rocky/ZoneCleaningCapability/start/set", byte(1), false, mock.Anything)
+	main.AssertNotCalled(t, "Publish", "valetudo/dusty/ZoneCleaningCapability/start/set", byte(1), false, mock.Anything)
+	iot.AssertCalled(t, "Publish", "valetudo/dusty/ZoneCleaningCapability/start/set", byte(1), false, mock.Anything)
+}
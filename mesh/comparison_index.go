@@ -0,0 +1,79 @@
+package mesh
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+)
+
+// RotationComparison is the set of images RenderRotationComparison made for
+// one vacuum, in the order of Rotations.
+type RotationComparison struct {
+	VacuumID  string
+	Rotations []float64
+	Paths     []string
+}
+
+// rotationIndexTemplate lays each vacuum's comparison out as a row of
+// captioned images.
+var rotationIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Rotation comparison</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; }
+.options { display: flex; flex-wrap: wrap; gap: 1em; }
+figure { margin: 0; border: 1px solid #ccc; padding: 0.5em; }
+figure img { max-width: 360px; display: block; }
+figcaption { text-align: center; margin-top: 0.3em; }
+code { background: #f0f0f0; padding: 0 0.2em; }
+</style>
+</head>
+<body>
+<h1>Rotation comparison</h1>
+<p>Each row shows one vacuum's map drawn over the reference <b>{{.Reference}}</b> at every candidate rotation. Pick the image where the walls line up and set it as the vacuum's <code>rotation</code> in config.yaml.</p>
+{{range .Vacuums}}
+<h2 id="{{.ID}}">{{.ID}}</h2>
+<div class="options">
+{{- range .Options}}
+<figure>
+<a href="{{.Path}}"><img src="{{.Path}}" alt="{{$.Reference}} with rotation {{.Degrees}}°" loading="lazy"></a>
+<figcaption>{{.Degrees}}° &middot; <code>rotation: {{.Degrees}}</code></figcaption>
+</figure>
+{{- end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteRotationIndex writes an HTML page showing every comparison image,
+// one row per vacuum. Image paths are used as given, so they should be
+// relative to where the page is saved.
+func WriteRotationIndex(w io.Writer, reference string, comparisons []RotationComparison) error {
+	type option struct {
+		Path    string
+		Degrees string
+	}
+	type vacuum struct {
+		ID      string
+		Options []option
+	}
+	data := struct {
+		Reference string
+		Vacuums   []vacuum
+	}{Reference: reference}
+
+	for _, c := range comparisons {
+		v := vacuum{ID: c.VacuumID}
+		for i, path := range c.Paths {
+			if i >= len(c.Rotations) {
+				break
+			}
+			v.Options = append(v.Options, option{Path: path, Degrees: strconv.FormatFloat(c.Rotations[i], 'f', -1, 64)})
+		}
+		data.Vacuums = append(data.Vacuums, v)
+	}
+	return rotationIndexTemplate.Execute(w, data)
+}
@@ -0,0 +1,53 @@
+package mesh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// WriteRotationIndex
+// ---------------------------------------------------------------------------
+
+func TestWriteRotationIndex(t *testing.T) {
+	comparisons := []RotationComparison{
+		{VacuumID: "vac2", Rotations: []float64{0, 37.5}, Paths: []string{"rotation_vac2_0.png", "rotation_vac2_37.5.png"}},
+		{VacuumID: "vac3", Rotations: []float64{0, 90}, Paths: []string{"rotation_vac3_0.png", "rotation_vac3_90.png"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteRotationIndex(&buf, "vac1", comparisons); err != nil {
+		t.Fatalf("WriteRotationIndex: %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"<b>vac1</b>",
+		`<h2 id="vac2">vac2</h2>`,
+		`<h2 id="vac3">vac3</h2>`,
+		`src="rotation_vac2_37.5.png"`,
+		`src="rotation_vac3_90.png"`,
+		"rotation: 37.5",
+		"rotation: 90",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("index missing %q", want)
+		}
+	}
+	if strings.Index(html, "vac2") > strings.Index(html, "vac3") {
+		t.Error("vacuums should appear in the given order")
+	}
+}
+
+func TestWriteRotationIndex_EscapesIDs(t *testing.T) {
+	comparisons := []RotationComparison{
+		{VacuumID: "<script>", Rotations: []float64{0}, Paths: []string{"a.png"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteRotationIndex(&buf, "ref", comparisons); err != nil {
+		t.Fatalf("WriteRotationIndex: %v", err)
+	}
+	if strings.Contains(buf.String(), "<script>") {
+		t.Error("vacuum ID should be HTML-escaped")
+	}
+}
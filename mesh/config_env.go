@@ -0,0 +1,234 @@
+package mesh
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix starts the environment variables that override config values.
+// Every scalar setting outside the vacuum, zone and profile lists has one,
+// named after its YAML path: mqtt.password is TUDOMESH_MQTT_PASSWORD and
+// http.rateLimit.burst is TUDOMESH_HTTP_RATE_LIMIT_BURST. Appending _FILE
+// reads the value from a file instead, as with Docker and Kubernetes
+// secrets.
+const EnvPrefix = "TUDOMESH_"
+
+// Environment variables that replace part of mqtt.broker rather than a
+// whole setting.
+const (
+	EnvMQTTHost = EnvPrefix + "MQTT_HOST"
+	EnvMQTTPort = EnvPrefix + "MQTT_PORT"
+)
+
+// defaultMQTTPort is used when TUDOMESH_MQTT_HOST builds a broker URL from
+// nothing.
+const defaultMQTTPort = "1883"
+
+// applyEnvOverrides sets config values from the environment, looked up with
+// lookup, recording values that cannot be used in v.
+func applyEnvOverrides(config *Config, lookup func(string) (string, bool), v *configValidator) {
+	overrideFields(reflect.ValueOf(config).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup, v)
+
+	host, hasHost := envValue(EnvMQTTHost, lookup, v)
+	port, hasPort := envValue(EnvMQTTPort, lookup, v)
+	if !hasHost && !hasPort {
+		return
+	}
+	broker, err := overrideBrokerAddress(config.MQTT.Broker, host, port)
+	if err != nil {
+		v.add(EnvMQTTHost, "%v", err)
+		return
+	}
+	config.MQTT.Broker = broker
+}
+
+// overrideFields walks the fields of struct value rv, setting each scalar
+// from the variable named after its path. It reports whether any was set.
+func overrideFields(rv reflect.Value, name string, lookup func(string) (string, bool), v *configValidator) bool {
+	set := false
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		env := name + "_" + envName(key)
+		field := rv.Field(i)
+
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			set = overrideFields(field, env, lookup, v) || set
+			continue
+		case f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct:
+			// Optional sections such as mqtt.output exist once any of
+			// their variables is set
+			section := reflect.New(f.Type.Elem())
+			if !field.IsNil() {
+				section.Elem().Set(field.Elem())
+			}
+			if overrideFields(section.Elem(), env, lookup, v) {
+				field.Set(section)
+				set = true
+			}
+			continue
+		}
+		s, ok := envValue(env, lookup, v)
+		if !ok {
+			continue
+		}
+		if err := setScalar(field, s); err != nil {
+			v.add(env, "%v", err)
+			continue
+		}
+		set = true
+	}
+	return set
+}
+
+// envValue returns the variable env, or the trimmed contents of the file
+// named by env_FILE.
+func envValue(env string, lookup func(string) (string, bool), v *configValidator) (string, bool) {
+	if s, ok := lookup(env); ok {
+		return s, true
+	}
+	path, ok := lookup(env + "_FILE")
+	if !ok {
+		return "", false
+	}
+	s, err := readSecret(path)
+	if err != nil {
+		v.add(env+"_FILE", "%v", err)
+		return "", false
+	}
+	return s, true
+}
+
+// readSecret reads a secret file, dropping the trailing newline editors and
+// `echo` leave behind.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// setScalar parses s into field. Lists and maps cannot be overridden.
+func setScalar(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setScalar(elem.Elem(), s); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("%s settings cannot be set from the environment", field.Kind())
+	}
+	return nil
+}
+
+// envName converts a YAML key to its environment form:
+// publishPrefix -> PUBLISH_PREFIX, budgetMB -> BUDGET_MB.
+func envName(key string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range key {
+		if unicode.IsUpper(r) && prev != 0 && !unicode.IsUpper(prev) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// overrideBrokerAddress replaces the host and/or port of a broker URL such
+// as tcp://localhost:1883. Empty host or port keeps the current one.
+func overrideBrokerAddress(broker, host, port string) (string, error) {
+	if broker == "" {
+		broker = "tcp://localhost:" + defaultMQTTPort
+	}
+	u, err := url.Parse(broker)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("cannot override the host of mqtt.broker %q", broker)
+	}
+	if host == "" {
+		host = u.Hostname()
+	}
+	if port == "" {
+		if port = u.Port(); port == "" {
+			port = defaultMQTTPort
+		}
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("port %q is not a number between 0 and 65535", port)
+	}
+	u.Host = net.JoinHostPort(host, port)
+	return u.String(), nil
+}
+
+// resolveSecretFiles reads each broker password from its passwordFile when
+// no password is set. Relative paths are relative to the config file.
+func resolveSecretFiles(config *Config, configPath string, v *configValidator) {
+	dir := filepath.Dir(configPath)
+	readPasswordFile(&config.MQTT.Password, config.MQTT.PasswordFile, dir, "mqtt.passwordFile", v)
+	if out := config.MQTT.Output; out != nil {
+		readPasswordFile(&out.Password, out.PasswordFile, dir, "mqtt.output.passwordFile", v)
+	}
+	for i := range config.Vacuums {
+		if b := config.Vacuums[i].MQTT; b != nil {
+			readPasswordFile(&b.Password, b.PasswordFile, dir, fmt.Sprintf("vacuums[%d].mqtt.passwordFile", i), v)
+		}
+	}
+}
+
+// readPasswordFile sets *password from file unless a password is set.
+func readPasswordFile(password *string, file, dir, field string, v *configValidator) {
+	if file == "" || *password != "" {
+		return
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	s, err := readSecret(file)
+	if err != nil {
+		v.add(field, "%v", err)
+		return
+	}
+	*password = s
+}
@@ -0,0 +1,190 @@
+package mesh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Environment overrides
+// ---------------------------------------------------------------------------
+
+func mapLookup(env map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		s, ok := env[k]
+		return s, ok
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	var config Config
+	config.MQTT.Broker = "tcp://localhost:1883"
+	config.GridSpacing = 1000
+	v := newConfigValidator()
+	applyEnvOverrides(&config, mapLookup(map[string]string{
+		"TUDOMESH_MQTT_USERNAME":               "tudo",
+		"TUDOMESH_MQTT_PASSWORD_FILE":          secret,
+		"TUDOMESH_MQTT_PUBLISH_PREFIX":         "mesh",
+		"TUDOMESH_GRID_SPACING":                "500",
+		"TUDOMESH_HTTP_RATE_LIMIT_BURST":       "7",
+		"TUDOMESH_STORAGE_COMPRESS":            "true",
+		"TUDOMESH_UNIFY_SEGMENT_MERGE_ENABLED": "false",
+		"TUDOMESH_MQTT_HOST":                   "broker.lan",
+	}), v)
+	if err := v.err(); err != nil {
+		t.Fatalf("applyEnvOverrides: %v", err)
+	}
+
+	if config.MQTT.Username != "tudo" || config.MQTT.Password != "s3cret" || config.MQTT.PublishPrefix != "mesh" {
+		t.Errorf("mqtt = %+v, want tudo/s3cret/mesh", config.MQTT)
+	}
+	if config.GridSpacing != 500 || config.HTTP.RateLimit.Burst != 7 || !config.Storage.Compress {
+		t.Errorf("gridSpacing %g, burst %d, compress %v, want 500, 7, true", config.GridSpacing, config.HTTP.RateLimit.Burst, config.Storage.Compress)
+	}
+	if e := config.Unify.SegmentMerge.Enabled; e == nil || *e {
+		t.Errorf("segmentMerge.enabled = %v, want false", e)
+	}
+	if config.MQTT.Broker != "tcp://broker.lan:1883" {
+		t.Errorf("broker = %q, want tcp://broker.lan:1883", config.MQTT.Broker)
+	}
+}
+
+func TestApplyEnvOverrides_InvalidValues(t *testing.T) {
+	var config Config
+	v := newConfigValidator()
+	applyEnvOverrides(&config, mapLookup(map[string]string{
+		"TUDOMESH_GRID_SPACING":       "wide",
+		"TUDOMESH_MQTT_PASSWORD_FILE": filepath.Join(t.TempDir(), "missing"),
+		"TUDOMESH_MQTT_PORT":          "mqtt",
+	}), v)
+	fields := make(map[string]bool)
+	for _, e := range v.errs {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"TUDOMESH_GRID_SPACING", "TUDOMESH_MQTT_PASSWORD_FILE", EnvMQTTHost} {
+		if !fields[f] {
+			t.Errorf("no problem reported for %s: %v", f, v.errs)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"broker":               "BROKER",
+		"publishPrefix":        "PUBLISH_PREFIX",
+		"clientId":             "CLIENT_ID",
+		"budgetMB":             "BUDGET_MB",
+		"maxConcurrentRenders": "MAX_CONCURRENT_RENDERS",
+	}
+	for key, want := range tests {
+		if got := envName(key); got != want {
+			t.Errorf("envName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestOverrideBrokerAddress(t *testing.T) {
+	tests := []struct {
+		broker, host, port string
+		want               string
+	}{
+		{"tcp://localhost:1883", "broker.lan", "", "tcp://broker.lan:1883"},
+		{"tcp://localhost:1883", "", "8883", "tcp://localhost:8883"},
+		{"ssl://mqtt.example.com", "", "", "ssl://mqtt.example.com:1883"},
+		{"", "10.0.0.2", "", "tcp://10.0.0.2:1883"},
+		{"tcp://localhost:1883", "::1", "", "tcp://[::1]:1883"},
+	}
+	for _, tt := range tests {
+		got, err := overrideBrokerAddress(tt.broker, tt.host, tt.port)
+		if err != nil || got != tt.want {
+			t.Errorf("overrideBrokerAddress(%q, %q, %q) = %q, %v; want %q", tt.broker, tt.host, tt.port, got, err, tt.want)
+		}
+	}
+	if _, err := overrideBrokerAddress("localhost", "x", ""); err == nil {
+		t.Error("expected an error for a broker without scheme")
+	}
+}
+
+func TestLoadConfig_EnvOverrides(t *testing.T) {
+	t.Setenv("TUDOMESH_MQTT_BROKER", "tcp://env:1883")
+	t.Setenv("TUDOMESH_REFERENCE", "vac-b")
+	cfg, err := LoadConfig(writeConfig(t, validConfigYAML()))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.MQTT.Broker != "tcp://env:1883" || cfg.Reference != "vac-b" {
+		t.Errorf("broker %q reference %q, want env values", cfg.MQTT.Broker, cfg.Reference)
+	}
+
+	// The broker may come from the environment alone
+	path := writeConfig(t, "vacuums:\n  - id: v1\n    topic: t/v1\n")
+	if _, err := LoadConfig(path); err != nil {
+		t.Errorf("LoadConfig without broker in the file: %v", err)
+	}
+
+	t.Setenv("TUDOMESH_GRID_SPACING", "wide")
+	_, err = LoadConfig(path)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || errs[0].Field != "TUDOMESH_GRID_SPACING" {
+		t.Errorf("LoadConfig error = %v, want a TUDOMESH_GRID_SPACING problem", err)
+	}
+}
+
+func TestLoadConfig_PasswordFile(t *testing.T) {
+	body := `mqtt:
+  broker: tcp://localhost:1883
+  username: tudo
+  passwordFile: secrets/mqtt
+vacuums:
+  - id: v1
+    topic: t/v1
+`
+	path := writeConfig(t, body)
+	if err := os.Mkdir(filepath.Join(filepath.Dir(path), "secrets"), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "secrets", "mqtt"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.MQTT.Password != "hunter2" {
+		t.Errorf("password = %q, want hunter2 from the file", cfg.MQTT.Password)
+	}
+
+	// An environment password wins over the file
+	t.Setenv("TUDOMESH_MQTT_PASSWORD", "from-env")
+	if cfg, err = LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.MQTT.Password != "from-env" {
+		t.Errorf("password = %q, want from-env", cfg.MQTT.Password)
+	}
+}
+
+func TestApplyEnvOverrides_OptionalSections(t *testing.T) {
+	var config Config
+	v := newConfigValidator()
+	applyEnvOverrides(&config, mapLookup(map[string]string{}), v)
+	if config.MQTT.Output != nil {
+		t.Fatal("mqtt.output should stay unset without its variables")
+	}
+
+	applyEnvOverrides(&config, mapLookup(map[string]string{
+		"TUDOMESH_MQTT_OUTPUT_BROKER":   "tcp://ha:1883",
+		"TUDOMESH_MQTT_OUTPUT_PASSWORD": "ha-secret",
+	}), v)
+	if out := config.MQTT.Output; out == nil || out.Broker != "tcp://ha:1883" || out.Password != "ha-secret" {
+		t.Errorf("mqtt.output = %+v, want broker and password from the environment", out)
+	}
+}
@@ -1,13 +1,23 @@
 package mesh
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig loads the unified configuration from a YAML file
+// LoadConfig loads the unified configuration from a YAML file, then applies
+// TUDOMESH_* environment overrides and secret files. Problems in the file
+// are returned together as ConfigErrors.
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -17,30 +27,304 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("parsing config YAML: %w", err)
 	}
 
+	v := newConfigValidator()
+	v.checkKeys(&root, reflect.TypeOf(Config{}), "")
+
+	var config Config
+	if err := root.Decode(&config); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			return nil, fmt.Errorf("parsing config YAML: %w", err)
+		}
+		v.addTypeErrors(te)
+	}
+	applyEnvOverrides(&config, os.LookupEnv, v)
+	resolveSecretFiles(&config, path, v)
+
+	validateConfig(&config, v)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// validateConfig checks the decoded config, recording every problem in v.
+func validateConfig(config *Config, v *configValidator) {
 	// Validate required fields
 	if config.MQTT.Broker == "" {
-		return nil, fmt.Errorf("mqtt.broker is required")
+		v.add("mqtt.broker", "is required")
 	}
 	if len(config.Vacuums) == 0 {
-		return nil, fmt.Errorf("at least one vacuum must be defined")
+		v.add("vacuums", "at least one vacuum must be defined")
+	}
+
+	if _, err := config.MQTT.SummaryRepublishInterval(); err != nil {
+		v.add("mqtt.summaryInterval", "%v", err)
+	}
+	validatePublishConfig(config.MQTT.Publish, v)
+	if config.MQTT.Output != nil && config.MQTT.Output.Broker == "" {
+		v.add("mqtt.output.broker", "is required")
 	}
 
 	// Validate vacuum configs
+	ids := make(map[string]int, len(config.Vacuums))
 	for i, vc := range config.Vacuums {
+		field := fmt.Sprintf("vacuums[%d]", i)
 		if vc.ID == "" {
-			return nil, fmt.Errorf("vacuum[%d].id is required", i)
+			v.add(field+".id", "is required")
+		} else if first, ok := ids[vc.ID]; ok {
+			v.add(field+".id", "duplicate vacuum id %s (also vacuums[%d])", vc.ID, first)
+		} else {
+			ids[vc.ID] = i
 		}
 		if vc.Topic == "" {
-			return nil, fmt.Errorf("vacuum[%d].topic is required for %s", i, vc.ID)
+			v.add(field+".topic", "is required for %s", vc.ID)
+		} else if err := ValidateTopicFilter(vc.Topic); err != nil {
+			v.add(field+".topic", "%v", err)
+		}
+		if vc.Color != "" {
+			if err := ValidateHexColor(vc.Color); err != nil {
+				v.add(field+".color", "%v", err)
+			}
+		}
+		if err := ValidateIconSpec(vc.Icon); err != nil {
+			v.add(field+".icon", "%v", err)
+		}
+		if vc.Opacity != nil && (*vc.Opacity < 0 || *vc.Opacity > 1) {
+			v.add(field+".opacity", "must be between 0 and 1 for %s", vc.ID)
+		}
+		if vc.Rotation != nil && (math.IsNaN(*vc.Rotation) || math.IsInf(*vc.Rotation, 0)) {
+			v.add(field+".rotation", "must be a finite angle for %s", vc.ID)
+		}
+		if err := ValidateMirror(vc.Mirror); err != nil {
+			v.add(field+".mirror", "%v", err)
+		}
+		if vc.MQTT != nil && vc.MQTT.Broker == "" {
+			v.add(field+".mqtt.broker", "is required for %s", vc.ID)
 		}
 	}
 
-	return &config, nil
+	switch config.Storage.Backend {
+	case "", StorageBackendFile, StorageBackendSQLite, StorageBackendMemory:
+	default:
+		v.add("storage.backend", "%q is invalid (must be file, sqlite, or memory)", config.Storage.Backend)
+	}
+
+	if _, err := CompileExportPattern(config.ExportPattern); err != nil {
+		v.add("exportPattern", "%v", err)
+	}
+
+	if _, err := config.Storage.WriteInterval(); err != nil {
+		v.add("storage.minWriteInterval", "%v", err)
+	}
+	if _, err := config.Tracing.Threshold(); err != nil {
+		v.add("tracing.slowThreshold", "%v", err)
+	}
+
+	if config.HTTP.MaxConcurrentRenders < 0 || config.HTTP.RenderQueueSeconds < 0 ||
+		config.HTTP.RateLimit.RequestsPerMinute < 0 || config.HTTP.RateLimit.Burst < 0 {
+		v.add("http", "limits must not be negative")
+	}
+	for i, t := range config.HTTP.Auth.Tokens {
+		field := fmt.Sprintf("http.auth.tokens[%d]", i)
+		if t.Token == "" {
+			v.add(field+".token", "is required for %s", t.Name)
+		}
+		if t.Role != TokenRoleRead && t.Role != TokenRoleAdmin {
+			v.add(field+".role", "%q is invalid (must be read or admin)", t.Role)
+		}
+	}
+	if config.HTTP.Auth.ProtectReads && !config.HTTP.Auth.Enabled() {
+		v.add("http.auth.protectReads", "needs at least one token")
+	}
+
+	if _, err := ParseLegendPosition(config.Legend.Position); err != nil {
+		v.add("legend.position", "%v", err)
+	}
+	if config.Legend.Scale < 0 || config.Legend.Scale > MaxLegendScale {
+		v.add("legend.scale", "must be between 1 and %d", MaxLegendScale)
+	}
+	if config.Font.Size < 0 || config.Font.Size > MaxFontSize {
+		v.add("font.size", "must not be negative or exceed %g", MaxFontSize)
+	}
+	if config.GridSpacing < 0 {
+		v.add("gridSpacing", "must not be negative")
+	}
+	zoneNames := make(map[string]bool, len(config.Zones))
+	for i, z := range config.Zones {
+		if err := z.Validate(); err != nil {
+			v.add(fmt.Sprintf("zones[%d]", i), "%v", err)
+		}
+		key := strings.ToLower(z.Name)
+		if zoneNames[key] {
+			v.add(fmt.Sprintf("zones[%d].name", i), "duplicate zone name %s", z.Name)
+		}
+		zoneNames[key] = true
+	}
+	profileNames := make(map[string]bool, len(config.Profiles))
+	for i, p := range config.Profiles {
+		if err := p.Validate(config); err != nil {
+			v.add(fmt.Sprintf("profiles[%d]", i), "%v", err)
+		}
+		key := strings.ToLower(p.Name)
+		if profileNames[key] {
+			v.add(fmt.Sprintf("profiles[%d].name", i), "duplicate profile name %s", p.Name)
+		}
+		profileNames[key] = true
+	}
+	if c := config.Unify.SegmentMerge.MinContainment; c < 0 || c > 1 {
+		v.add("unify.segmentMerge.minContainment", "must be between 0 and 1")
+	}
+	if config.Unify.DoubleWalls.MaxThickness < 0 {
+		v.add("unify.doubleWalls.maxThickness", "must not be negative")
+	}
+	if o := config.Unify.DoubleWalls.MinOverlap; o < 0 || o > 1 {
+		v.add("unify.doubleWalls.minOverlap", "must be between 0 and 1")
+	}
+	if b := config.Battery.AlertBelow; b < 0 || b > 100 {
+		v.add("battery.alertBelow", "must be between 0 and 100")
+	}
+	if config.Battery.DockRadius < 0 {
+		v.add("battery.dockRadius", "must not be negative")
+	}
+	if _, err := config.ICP.Duration(); err != nil {
+		v.add("icp.maxDuration", "%v", err)
+	}
+	if config.ICP.MaxIterations < 0 {
+		v.add("icp.maxIterations", "must not be negative")
+	}
+	if err := config.ICP.Weights.Validate(); err != nil {
+		v.add("icp.weights", "%v", err)
+	}
+	if i := ICPInit(config.ICP.Init); i != "" && i != InitSampled && i != InitPhaseCorrelation {
+		v.add("icp.init", "%q is invalid (must be %s or %s)", i, InitSampled, InitPhaseCorrelation)
+	}
+	if t := config.ICP.Target; t != "" && t != ICPTargetUnified && t != ICPTargetReference {
+		v.add("icp.target", "%q is invalid (must be %s or %s)", t, ICPTargetUnified, ICPTargetReference)
+	}
+	if config.Retention.MaxFiles < 0 {
+		v.add("retention.maxFiles", "must not be negative")
+	}
+	if _, err := config.Retention.Age(); err != nil {
+		v.add("retention.maxAge", "%v", err)
+	}
+	if _, err := config.Health.StaleDuration(); err != nil {
+		v.add("health.staleAfter", "%v", err)
+	}
+	if s := config.Health.MinICPScore; s < 0 || s > 1 {
+		v.add("health.minICPScore", "must be between 0 and 1")
+	}
+	if _, err := config.Retention.PruneInterval(); err != nil {
+		v.add("retention.interval", "%v", err)
+	}
+	if config.Model3D.WallHeight < 0 {
+		v.add("model3d.wallHeight", "must not be negative")
+	}
+	if config.Model3D.WallThickness < 0 {
+		v.add("model3d.wallThickness", "must not be negative")
+	}
+	if config.Model3D.FloorThickness < 0 {
+		v.add("model3d.floorThickness", "must not be negative")
+	}
+	if fp := config.Floorplan; fp.Image != "" {
+		if fp.MMPerPixel <= 0 {
+			v.add("floorplan.mmPerPixel", "must be positive")
+		}
+		if fp.Opacity != nil && (*fp.Opacity < 0 || *fp.Opacity > 1) {
+			v.add("floorplan.opacity", "must be between 0 and 1")
+		}
+	}
+}
+
+// validatePublishConfig checks QoS levels and that overrides name topics
+// the Publisher sends.
+func validatePublishConfig(c PublishConfig, v *configValidator) {
+	if c.QoS < 0 || c.QoS > 2 {
+		v.add("mqtt.publish.qos", "must be 0, 1 or 2")
+	}
+	names := make([]string, 0, len(c.Topics))
+	for name := range c.Topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := "mqtt.publish.topics." + name
+		if !slices.Contains(PublishTopics, name) {
+			v.add(field, "unknown topic (must be one of %s)", strings.Join(PublishTopics, ", "))
+			continue
+		}
+		if q := c.Topics[name].QoS; q != nil && (*q < 0 || *q > 2) {
+			v.add(field+".qos", "must be 0, 1 or 2")
+		}
+	}
+}
+
+// Duration returns MaxDuration parsed, or 0 when unset.
+func (c ICPBudgetConfig) Duration() (time.Duration, error) {
+	return parseDuration(c.MaxDuration, 0)
+}
+
+// WriteInterval returns MinWriteInterval parsed, or DefaultMapWriteInterval
+// when unset.
+func (c StorageConfig) WriteInterval() (time.Duration, error) {
+	return parseDuration(c.MinWriteInterval, DefaultMapWriteInterval)
+}
+
+// Threshold returns SlowThreshold parsed, or DefaultSlowThreshold when
+// unset.
+func (c TracingConfig) Threshold() (time.Duration, error) {
+	return parseDuration(c.SlowThreshold, DefaultSlowThreshold)
+}
+
+// SummaryRepublishInterval returns SummaryInterval parsed, or
+// DefaultSummaryInterval when unset. 0 disables periodic republishing.
+func (c MQTTConfig) SummaryRepublishInterval() (time.Duration, error) {
+	return parseDuration(c.SummaryInterval, DefaultSummaryInterval)
+}
+
+// Age returns MaxAge parsed, or 0 (no age limit) when unset.
+func (c RetentionConfig) Age() (time.Duration, error) {
+	return parseDuration(c.MaxAge, 0)
+}
+
+// StaleDuration returns StaleAfter parsed, or DefaultHealthStaleAfter when
+// unset.
+func (c HealthConfig) StaleDuration() (time.Duration, error) {
+	d, err := parseDuration(c.StaleAfter, DefaultHealthStaleAfter)
+	if err == nil && d == 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, err
+}
+
+// PruneInterval returns Interval parsed, or DefaultPruneInterval when unset.
+func (c RetentionConfig) PruneInterval() (time.Duration, error) {
+	d, err := parseDuration(c.Interval, DefaultPruneInterval)
+	if err == nil && d == 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, err
+}
+
+// parseDuration parses a non-negative Go duration from the config, returning
+// def when s is empty.
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
 }
 
 // SaveConfig saves the configuration to a YAML file
@@ -50,7 +334,7 @@ func SaveConfig(path string, config *Config) error {
 		return fmt.Errorf("marshaling config YAML: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
 		return fmt.Errorf("writing config file: %w", err)
 	}
 
@@ -107,6 +391,7 @@ func GetEffectiveReference(config *Config, cache *CalibrationData, maps map[stri
 
 // BuildForceRotationMap creates a rotation map from --force-rotation CLI flag format
 // Format: "VACUUM_ID=DEGREES,VACUUM_ID2=DEGREES2"
+// DEGREES may be any finite angle, not just a quarter turn (e.g. "vac=37.5").
 // This is used for CLI overrides
 func BuildForceRotationMap(forceRotation string) map[string]float64 {
 	rotations := make(map[string]float64)
@@ -134,9 +419,9 @@ func BuildForceRotationMap(forceRotation string) map[string]float64 {
 			continue
 		}
 
-		vacuumID := spec[:eqIdx]
-		var degrees float64
-		if _, err := fmt.Sscanf(spec[eqIdx+1:], "%f", &degrees); err == nil {
+		vacuumID := strings.TrimSpace(spec[:eqIdx])
+		degrees, err := strconv.ParseFloat(strings.TrimSpace(spec[eqIdx+1:]), 64)
+		if err == nil && !math.IsNaN(degrees) && !math.IsInf(degrees, 0) {
 			rotations[vacuumID] = degrees
 		}
 	}
@@ -106,6 +106,423 @@ vacuums:
 vacuums:
   - id: v1
     topic: ""
+`,
+		},
+		{
+			name: "unknown storage backend",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+storage:
+  backend: redis
+`,
+		},
+		{
+			name: "opacity out of range",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    opacity: 1.5
+`,
+		},
+		{
+			name: "non-finite rotation",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    rotation: .nan
+`,
+		},
+		{
+			name: "negative feature weight",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+icp:
+  weights:
+    corners: -2
+vacuums:
+  - id: v1
+    topic: t/v1
+`,
+		},
+		{
+			name: "unknown mirror axis",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    mirror: z
+`,
+		},
+		{
+			name: "unknown icon",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    icon: hexagon
+`,
+		},
+		{
+			name: "invalid legend position",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+legend:
+  position: middle
+`,
+		},
+		{
+			name: "negative font size",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+font:
+  size: -12
+`,
+		},
+		{
+			name: "negative grid spacing",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+gridSpacing: -500
+`,
+		},
+		{
+			name: "floorplan without scale",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+floorplan:
+  image: plan.png
+`,
+		},
+		{
+			name: "floorplan opacity out of range",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+floorplan:
+  image: plan.png
+  mmPerPixel: 10
+  opacity: 2
+`,
+		},
+		{
+			name: "segment merge containment out of range",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+unify:
+  segmentMerge:
+    minContainment: 1.5
+`,
+		},
+		{
+			name: "negative wall thickness",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+unify:
+  doubleWalls:
+    maxThickness: -100
+`,
+		},
+		{
+			name: "publish qos above 2",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+  publish:
+    qos: 3
+vacuums:
+  - id: v1
+    topic: t/v1
+`,
+		},
+		{
+			name: "publish override of an unknown topic",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+  publish:
+    topics:
+      pose: {qos: 1}
+vacuums:
+  - id: v1
+    topic: t/v1
+`,
+		},
+		{
+			name: "battery threshold above 100",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+battery:
+  alertBelow: 120
+`,
+		},
+		{
+			name: "zone with one point",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+zones:
+  - name: hall
+    points: [{x: 0, y: 0}]
+`,
+		},
+		{
+			name: "duplicate zone name",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+zones:
+  - name: Hall
+    points: [{x: 0, y: 0}, {x: 100, y: 100}]
+  - name: hall
+    points: [{x: 0, y: 0}, {x: 200, y: 200}]
+`,
+		},
+		{
+			name: "invalid icp duration",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+icp:
+  maxDuration: soon
+`,
+		},
+		{
+			name: "negative icp duration",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+icp:
+  maxDuration: -5s
+`,
+		},
+		{
+			name: "invalid health staleAfter",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+health:
+  staleAfter: 0s
+`,
+		},
+		{
+			name: "health minICPScore above 1",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+health:
+  minICPScore: 30
+`,
+		},
+		{
+			name: "negative model3d wallHeight",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+model3d:
+  wallHeight: -2400
+`,
+		},
+		{
+			name: "invalid icp target",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+icp:
+  target: neighbour
+`,
+		},
+		{
+			name: "invalid icp init",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+icp:
+  init: fourier
+`,
+		},
+		{
+			name: "invalid storage write interval",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+storage:
+  minWriteInterval: often
+`,
+		},
+		{
+			name: "export pattern without id group",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+exportPattern: '^map_(\w+)\.json$'
+`,
+		},
+		{
+			name: "profile with unknown vacuum",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+profiles:
+  - name: upstairs
+    vacuums: [v2]
+`,
+		},
+		{
+			name: "duplicate profile name",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+profiles:
+  - name: all
+  - name: ALL
+`,
+		},
+		{
+			name: "negative retention maxFiles",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+retention:
+  maxFiles: -1
+`,
+		},
+		{
+			name: "invalid retention maxAge",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+retention:
+  maxAge: 30d
+`,
+		},
+		{
+			name: "vacuum broker without url",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    mqtt:
+      username: robots
+`,
+		},
+		{
+			name: "output broker without url",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+  output:
+    clientId: tudomesh-ha
+vacuums:
+  - id: v1
+    topic: t/v1
+`,
+		},
+		{
+			name: "invalid summary interval",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+  summaryInterval: hourly
+vacuums:
+  - id: v1
+    topic: t/v1
+`,
+		},
+		{
+			name: "zero retention interval",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+retention:
+  interval: 0s
+`,
+		},
+		{
+			name: "unknown token role",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+http:
+  auth:
+    tokens:
+      - name: ha
+        token: s3cret
+        role: owner
+`,
+		},
+		{
+			name: "protectReads without tokens",
+			yaml: `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+http:
+  auth:
+    protectReads: true
 `,
 		},
 	}
@@ -215,6 +632,20 @@ func TestBuildForceRotationMap(t *testing.T) {
 			t.Errorf("vac-f = %g, want 33.5", got["vac-f"])
 		}
 	})
+
+	t.Run("non-cardinal with spaces", func(t *testing.T) {
+		got := BuildForceRotationMap("vac-a = 37.5, vac-b=-12.25")
+		if got["vac-a"] != 37.5 || got["vac-b"] != -12.25 {
+			t.Errorf("got %v, want vac-a=37.5 vac-b=-12.25", got)
+		}
+	})
+
+	t.Run("trailing junk and non-finite skipped", func(t *testing.T) {
+		got := BuildForceRotationMap("vac-a=37.5deg,vac-b=NaN,vac-c=Inf")
+		if len(got) != 0 {
+			t.Errorf("got %v, want empty map", got)
+		}
+	})
 }
 
 // ---------------------------------------------------------------------------
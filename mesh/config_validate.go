@@ -0,0 +1,277 @@
+package mesh
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is one problem found in a config file.
+type ConfigError struct {
+	Field   string // YAML path, e.g. vacuums[1].color
+	Line    int    // line in the file, 0 when unknown
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	msg := e.Message
+	if e.Field != "" {
+		msg = e.Field + ": " + msg
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	return msg
+}
+
+// ConfigErrors is every problem LoadConfig found in a config file, ordered
+// by line. Use errors.As to report them one by one.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, ce := range e {
+		msgs[i] = ce.Error()
+	}
+	return fmt.Sprintf("%d config problems: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// configValidator collects the problems in a config file along with the
+// line of every key, so errors found after decoding can point at the file.
+type configValidator struct {
+	lines map[string]int // YAML path -> line
+	errs  ConfigErrors
+}
+
+func newConfigValidator() *configValidator {
+	return &configValidator{lines: make(map[string]int)}
+}
+
+// add records a problem with field, placed at the line of field or of its
+// nearest parent in the file.
+func (v *configValidator) add(field, format string, args ...any) {
+	line := 0
+	for p := field; p != ""; p = parentPath(p) {
+		if l, ok := v.lines[p]; ok {
+			line = l
+			break
+		}
+	}
+	v.errs = append(v.errs, ConfigError{Field: field, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems found, or nil.
+func (v *configValidator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	errs := append(ConfigErrors(nil), v.errs...)
+	sort.SliceStable(errs, func(i, j int) bool {
+		// Problems without a line, such as a missing section, come last
+		li, lj := errs[i].Line, errs[j].Line
+		return li != 0 && (lj == 0 || li < lj)
+	})
+	return errs
+}
+
+// parentPath strips the last key or index from a YAML path:
+// vacuums[1].color -> vacuums[1] -> vacuums -> "".
+func parentPath(p string) string {
+	if i := strings.LastIndexAny(p, ".["); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// checkKeys walks node alongside the Go type it decodes into, recording the
+// line of every key and reporting keys that t does not have.
+func (v *configValidator) checkKeys(node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.DocumentNode {
+		for _, n := range node.Content {
+			v.checkKeys(n, t, path)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			p := joinPath(path, key.Value)
+			v.lines[p] = key.Line
+			ft, ok := fields[key.Value]
+			if !ok {
+				msg := "unknown key"
+				if s := suggestKey(key.Value, fields); s != "" {
+					msg += fmt.Sprintf(", did you mean %q?", s)
+				}
+				v.add(p, "%s", msg)
+				continue
+			}
+			v.checkKeys(value, ft, p)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			v.lines[p] = item.Line
+			v.checkKeys(item, t.Elem(), p)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			p := joinPath(path, node.Content[i].Value)
+			v.lines[p] = node.Content[i].Line
+			v.checkKeys(node.Content[i+1], t.Elem(), p)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// yamlFields returns the keys yaml.v3 decodes into struct t, following the
+// package's rules: the yaml tag name, else the lowercased field name.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, ft := range yamlFields(f.Type) {
+				fields[k] = ft
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// suggestKey returns the known key closest to a misspelled one, or "" when
+// none is close enough to be a likely typo.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 0
+	for k := range fields {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+		d := editDistance(strings.ToLower(key), strings.ToLower(k))
+		if best == "" || d < bestDist || (d == bestDist && k < best) {
+			best, bestDist = k, d
+		}
+	}
+	if bestDist > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// typeErrorLine splits "line 12: cannot unmarshal ..." from yaml.TypeError.
+var typeErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// addTypeErrors records the fields yaml.v3 could not decode, such as text
+// where a number belongs.
+func (v *configValidator) addTypeErrors(te *yaml.TypeError) {
+	for _, e := range te.Errors {
+		ce := ConfigError{Message: e}
+		if m := typeErrorLine.FindStringSubmatch(e); m != nil {
+			_, _ = fmt.Sscanf(m[1], "%d", &ce.Line)
+			ce.Message = m[2]
+			ce.Field = v.fieldAt(ce.Line)
+		}
+		v.errs = append(v.errs, ce)
+	}
+}
+
+// fieldAt returns the deepest recorded path on line, or "".
+func (v *configValidator) fieldAt(line int) string {
+	field := ""
+	for p, l := range v.lines {
+		if l == line && (len(p) > len(field) || (len(p) == len(field) && p < field)) {
+			field = p
+		}
+	}
+	return field
+}
+
+// hexColor matches the colors parseHexColor understands, e.g. #FF6B6B.
+var hexColor = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// ValidateHexColor checks that s is a six-digit hex color such as #FF6B6B.
+func ValidateHexColor(s string) error {
+	if !hexColor.MatchString(s) {
+		return fmt.Errorf("%q is not a hex color like #FF6B6B", s)
+	}
+	return nil
+}
+
+// ValidateTopicFilter checks that topic is a usable MQTT subscription: no
+// spaces, and wildcards only as whole levels with # last.
+func ValidateTopicFilter(topic string) error {
+	if strings.ContainsAny(topic, " \t\r\n") {
+		return fmt.Errorf("%q contains whitespace", topic)
+	}
+	levels := strings.Split(topic, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return fmt.Errorf("%q: # must be the last level", topic)
+		case level != "#" && level != "+" && strings.ContainsAny(level, "#+"):
+			return fmt.Errorf("%q: wildcards must fill a whole level", topic)
+		}
+	}
+	return nil
+}
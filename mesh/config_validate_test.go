@@ -0,0 +1,161 @@
+package mesh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// LoadConfig error reporting
+// ---------------------------------------------------------------------------
+
+// loadConfigErrors loads body and returns its problems, failing when the
+// config loads or fails some other way.
+func loadConfigErrors(t *testing.T, body string) ConfigErrors {
+	t.Helper()
+	_, err := LoadConfig(writeConfig(t, body))
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("LoadConfig error = %v, want ConfigErrors", err)
+	}
+	return errs
+}
+
+func TestLoadConfig_ReportsEveryProblem(t *testing.T) {
+	errs := loadConfigErrors(t, `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    color: red
+  - id: v1
+    topic: t/v 2
+gridSpacing: -1
+`)
+	want := []ConfigError{
+		{Field: "vacuums[0].color", Line: 6},
+		{Field: "vacuums[1].id", Line: 7},
+		{Field: "vacuums[1].topic", Line: 8},
+		{Field: "gridSpacing", Line: 9},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w.Field || errs[i].Line != w.Line {
+			t.Errorf("problem %d = %s at line %d, want %s at line %d", i, errs[i].Field, errs[i].Line, w.Field, w.Line)
+		}
+	}
+	if !strings.Contains(errs.Error(), "4 config problems") {
+		t.Errorf("Error() = %q, want a count", errs.Error())
+	}
+}
+
+func TestLoadConfig_UnknownKeys(t *testing.T) {
+	errs := loadConfigErrors(t, `mqtt:
+  broker: tcp://localhost:1883
+  pubishPrefix: tudomesh
+vacuums:
+  - id: v1
+    topic: t/v1
+    Colour: "#FF0000"
+legnd:
+  position: top-right
+frobnicate: true
+`)
+	want := map[string]string{
+		"mqtt.pubishPrefix": `did you mean "publishPrefix"?`,
+		"vacuums[0].Colour": `did you mean "color"?`,
+		"legnd":             `did you mean "legend"?`,
+		"frobnicate":        "unknown key",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(errs), len(want), errs)
+	}
+	for _, e := range errs {
+		if !strings.HasSuffix(e.Message, want[e.Field]) || !strings.HasPrefix(e.Message, "unknown key") {
+			t.Errorf("%s: message %q, want unknown key ending in %q", e.Field, e.Message, want[e.Field])
+		}
+	}
+	if errs[0].Line != 3 {
+		t.Errorf("first problem at line %d, want 3", errs[0].Line)
+	}
+}
+
+func TestLoadConfig_TypeErrors(t *testing.T) {
+	errs := loadConfigErrors(t, `mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: v1
+    topic: t/v1
+    opacity: half
+`)
+	if len(errs) != 1 || errs[0].Line != 6 || errs[0].Field != "vacuums[0].opacity" {
+		t.Errorf("got %v, want one problem with vacuums[0].opacity at line 6", errs)
+	}
+}
+
+func TestConfigError_Error(t *testing.T) {
+	tests := []struct {
+		err  ConfigError
+		want string
+	}{
+		{ConfigError{Field: "mqtt.broker", Line: 2, Message: "is required"}, "line 2: mqtt.broker: is required"},
+		{ConfigError{Field: "vacuums", Message: "at least one vacuum must be defined"}, "vacuums: at least one vacuum must be defined"},
+		{ConfigError{Message: "bad"}, "bad"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Field checks
+// ---------------------------------------------------------------------------
+
+func TestValidateHexColor(t *testing.T) {
+	for _, s := range []string{"#FF6B6B", "ff6b6b", "#00aa00"} {
+		if err := ValidateHexColor(s); err != nil {
+			t.Errorf("ValidateHexColor(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range []string{"", "red", "#FFF", "#GG0000", "#FF6B6B00"} {
+		if err := ValidateHexColor(s); err == nil {
+			t.Errorf("ValidateHexColor(%q) = nil, want error", s)
+		}
+	}
+}
+
+func TestValidateTopicFilter(t *testing.T) {
+	for _, s := range []string{"valetudo/robot/MapData/map-data", "valetudo/+/MapData/map-data", "valetudo/#"} {
+		if err := ValidateTopicFilter(s); err != nil {
+			t.Errorf("ValidateTopicFilter(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range []string{"valetudo/robot /map", "valetudo/#/map", "valetudo/robot+/map", "valetudo/robot#"} {
+		if err := ValidateTopicFilter(s); err == nil {
+			t.Errorf("ValidateTopicFilter(%q) = nil, want error", s)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"color", "color", 0},
+		{"colour", "color", 1},
+		{"legnd", "legend", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
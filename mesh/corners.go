@@ -0,0 +1,185 @@
+package mesh
+
+import "math"
+
+// Wall corner refinement. Corners found on the pixel outline are quantized
+// to the grid and jitter with the wall pixels next to them; a corner where
+// two walls meet is better placed where lines fitted to those walls
+// intersect, which lands between pixels.
+const (
+	cornerOutlineTolerance = 1.5 // pixels; RDP tolerance of the wall outlines corners are looked for on
+	cornerMinEdge          = 6.0 // pixels; shorter outline edges are noise, not walls
+	cornerMinTurn          = 50  // degrees; the turn between the walls at a corner, from
+	cornerMaxTurn          = 130 // ... to
+	cornerWallReach        = 2   // pixels; wall pixels this close to an outline edge belong to its wall
+	cornerEndMargin        = 3.0 // pixels; wall pixels this close to an edge's ends are left out of its fit
+	cornerMinWallPixels    = 5   // fewest pixels a wall's line is fitted to
+	cornerMaxShift         = 4.0 // pixels; a refined corner further from its outline vertex is rejected
+	cornerMergeDistance    = 3.0 // pixels; refined corners this close are one corner
+	cornerMinHolePixels    = 100 // smallest room enclosed by walls whose outline is searched
+)
+
+// ExtractWallCorners returns the corners where walls of m meet, refined to
+// subpixel precision: each corner of the simplified wall outlines, inside
+// rooms and around the walls, is replaced by the intersection of the lines
+// fitted to the wall pixels along its two edges. Both faces of a wall fit
+// the same line, so a corner seen from either side comes out once.
+func ExtractWallCorners(m *ValetudoMap) []Point {
+	layer, ok := ExtractWallLayer(m)
+	if !ok || len(layer.Pixels) < 2*cornerMinWallPixels {
+		return nil
+	}
+	cells, minX, minY, width, height := pixelsToGrid(layer.Pixels, 1)
+	walls := wallGrid{cells: cells, minX: minX, minY: minY, width: width, height: height}
+
+	// Diagonal walls are only joined at pixel corners, which the outline
+	// tracer does not follow, so the outlines are traced around the walls
+	// grown by a pixel, on a grid a pixel wider on each side
+	gw, gh := width+2, height+2
+	grid := make([]bool, gw*gh)
+	for i, wall := range cells {
+		if !wall {
+			continue
+		}
+		x, y := i%width+1, i/width+1
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				grid[(y+dy)*gw+x+dx] = true
+			}
+		}
+	}
+	outlines := append(traceContours(grid, gw, gh), traceHoles(grid, gw, gh, cornerMinHolePixels)...)
+	minX, minY = minX-1, minY-1
+
+	var corners []Point
+	for _, outline := range outlines {
+		shifted := make(Path, len(outline))
+		for i, p := range outline {
+			shifted[i] = Point{X: p.X + float64(minX), Y: p.Y + float64(minY)}
+		}
+		ring := SimplifyRDP(shifted, cornerOutlineTolerance)
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+		}
+		if len(ring) < 3 {
+			continue
+		}
+		for i, v := range ring {
+			prev := ring[(i-1+len(ring))%len(ring)]
+			next := ring[(i+1)%len(ring)]
+			if c, ok := refineCorner(walls, prev, v, next); ok {
+				corners = mergeCorner(corners, c)
+			}
+		}
+	}
+	return corners
+}
+
+// refineCorner intersects the lines fitted to the walls along the outline
+// edges prev-v and v-next, if v is a corner between two walls.
+func refineCorner(walls wallGrid, prev, v, next Point) (Point, bool) {
+	in := Point{X: v.X - prev.X, Y: v.Y - prev.Y}
+	out := Point{X: next.X - v.X, Y: next.Y - v.Y}
+	if math.Hypot(in.X, in.Y) < cornerMinEdge || math.Hypot(out.X, out.Y) < cornerMinEdge {
+		return Point{}, false
+	}
+	turn := math.Abs(signedDegrees((math.Atan2(out.Y, out.X) - math.Atan2(in.Y, in.X)) * 180 / math.Pi))
+	if turn < cornerMinTurn || turn > cornerMaxTurn {
+		return Point{}, false
+	}
+
+	p1, d1, ok1 := fitWallLine(walls, prev, v)
+	p2, d2, ok2 := fitWallLine(walls, v, next)
+	if !ok1 || !ok2 {
+		return Point{}, false
+	}
+	c, ok := intersectLines(p1, d1, p2, d2)
+	if !ok || math.Hypot(c.X-v.X, c.Y-v.Y) > cornerMaxShift {
+		return Point{}, false
+	}
+	return c, true
+}
+
+// fitWallLine fits a line, as a point and unit direction, to the wall
+// pixels along the outline edge a-b, leaving out those near its ends where
+// the next wall begins. The fit must run along the edge.
+func fitWallLine(walls wallGrid, a, b Point) (Point, Point, bool) {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	dir := Point{X: (b.X - a.X) / length, Y: (b.Y - a.Y) / length}
+
+	seen := make(map[[2]int]bool)
+	var pixels []Point
+	for s := cornerEndMargin; s <= length-cornerEndMargin; s++ {
+		cx := int(math.Round(a.X + dir.X*s))
+		cy := int(math.Round(a.Y + dir.Y*s))
+		for dy := -cornerWallReach; dy <= cornerWallReach; dy++ {
+			for dx := -cornerWallReach; dx <= cornerWallReach; dx++ {
+				key := [2]int{cx + dx, cy + dy}
+				if !walls.has(key[0], key[1]) || seen[key] {
+					continue
+				}
+				seen[key] = true
+				p := Point{X: float64(key[0]), Y: float64(key[1])}
+				along := (p.X-a.X)*dir.X + (p.Y-a.Y)*dir.Y
+				if along >= cornerEndMargin && along <= length-cornerEndMargin {
+					pixels = append(pixels, p)
+				}
+			}
+		}
+	}
+	if len(pixels) < cornerMinWallPixels {
+		return Point{}, Point{}, false
+	}
+
+	// Total least squares: the line through the centroid along the
+	// principal axis of the pixels
+	c := Centroid(pixels)
+	var sxx, syy, sxy float64
+	for _, p := range pixels {
+		dx, dy := p.X-c.X, p.Y-c.Y
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	angle := 0.5 * math.Atan2(2*sxy, sxx-syy)
+	fit := Point{X: math.Cos(angle), Y: math.Sin(angle)}
+	if math.Abs(fit.X*dir.X+fit.Y*dir.Y) < math.Cos(20*math.Pi/180) {
+		return Point{}, Point{}, false
+	}
+	return c, fit, true
+}
+
+// wallGrid is the wall layer as a grid of cells, for fast lookups.
+type wallGrid struct {
+	cells                     []bool
+	minX, minY, width, height int
+}
+
+// has reports whether there is a wall pixel at x, y.
+func (g wallGrid) has(x, y int) bool {
+	x, y = x-g.minX, y-g.minY
+	return x >= 0 && y >= 0 && x < g.width && y < g.height && g.cells[y*g.width+x]
+}
+
+// intersectLines returns where the lines through p1 along d1 and p2 along
+// d2 cross; ok is false for parallel lines.
+func intersectLines(p1, d1, p2, d2 Point) (Point, bool) {
+	cross := d1.X*d2.Y - d1.Y*d2.X
+	if math.Abs(cross) < 1e-9 {
+		return Point{}, false
+	}
+	t := ((p2.X-p1.X)*d2.Y - (p2.Y-p1.Y)*d2.X) / cross
+	return Point{X: p1.X + t*d1.X, Y: p1.Y + t*d1.Y}, true
+}
+
+// mergeCorner adds c to corners, or averages it into a corner already
+// there within cornerMergeDistance.
+func mergeCorner(corners []Point, c Point) []Point {
+	for i, existing := range corners {
+		if math.Hypot(existing.X-c.X, existing.Y-c.Y) <= cornerMergeDistance {
+			corners[i] = Point{X: (existing.X + c.X) / 2, Y: (existing.Y + c.Y) / 2}
+			return corners
+		}
+	}
+	return append(corners, c)
+}
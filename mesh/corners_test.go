@@ -0,0 +1,59 @@
+package mesh
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestExtractWallCorners_Subpixel(t *testing.T) {
+	// The four corners of rotatedRoom and where its interior walls meet the
+	// outer ones
+	room := []Point{{0, 0}, {120, 0}, {120, 80}, {0, 80}, {50, 0}, {0, 50}}
+	for _, deg := range []float64{0, 30, 45} {
+		transform := CreateRotationTranslation(deg, 400, 400)
+		corners := ExtractWallCorners(rotatedRoom(deg))
+		for _, r := range room {
+			want := TransformPoint(Point{X: 3*r.X - 180, Y: 3*r.Y - 120}, transform)
+			best := math.Inf(1)
+			for _, c := range corners {
+				best = math.Min(best, math.Hypot(c.X-want.X, c.Y-want.Y))
+			}
+			if best > 0.5 {
+				t.Errorf("%.0f°: corner at (%.1f, %.1f) off by %.2fpx, want at most 0.5", deg, want.X, want.Y, best)
+			}
+		}
+	}
+}
+
+func TestExtractWallCorners_NoWalls(t *testing.T) {
+	m := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: []int{1, 1, 2, 2}}}}
+	if corners := ExtractWallCorners(m); corners != nil {
+		t.Errorf("corners = %v, want none", corners)
+	}
+}
+
+func TestMatchedCorners(t *testing.T) {
+	source := []Point{{0, 0}, {100, 0}, {100, 50}}
+	target := []Point{{10, 10}, {110, 12}, {300, 300}}
+	if got := matchedCorners(source, target, Point{X: 10, Y: 10}); got != 2 {
+		t.Errorf("matched = %d, want 2", got)
+	}
+	if got := matchedCorners(source, target, Point{}); got != 0 {
+		t.Errorf("matched without translation = %d, want 0", got)
+	}
+}
+
+func TestFindBestInitialAlignment_Corners(t *testing.T) {
+	source := ExtractFeatures(rotatedRoom(0))
+	shift := Translation(37, -23)
+	sourcePoints := SampleFeatures(source, 300)
+	targetPoints := TransformPoints(sourcePoints, shift)
+	targetCorners := TransformPoints(source.WallCorners, shift)
+
+	tx := findBestInitialAlignment(sourcePoints, targetPoints, source.WallCorners, targetCorners,
+		source.Centroid, TransformPoint(source.Centroid, shift), 0, rand.New(rand.NewSource(1)))
+	if math.Abs(tx.Tx-37) > 1 || math.Abs(tx.Ty+23) > 1 {
+		t.Errorf("translation = (%.1f, %.1f), want (37, -23)", tx.Tx, tx.Ty)
+	}
+}
@@ -0,0 +1,260 @@
+package mesh
+
+import "math"
+
+// TransformCovariance is the estimated covariance of a transform's
+// parameters, in order: its rotation in degrees and its translation X and
+// Y, in the units of the transform. The rotation is about the origin, as in
+// TransformRotation.
+type TransformCovariance [3][3]float64
+
+// Covariance estimation: the final alignment is checked against at most
+// covarianceMaxPoints points of each map, matched within
+// covarianceMaxDist pixels. Residuals are never taken to be below the
+// error of rounding to the pixel grid.
+const (
+	covarianceMaxPoints   = 500
+	covarianceMaxDist     = 10.0
+	covarianceMinMatches  = 10
+	covarianceMinVariance = 1.0 / 12 // pixels²; a uniform error within one pixel
+
+	// covarianceGate is the squared Mahalanobis distance between two
+	// transforms above which they disagree: the 99th percentile of the
+	// chi-squared distribution with 3 degrees of freedom
+	covarianceGate = 11.34
+)
+
+// confidence95 is the normal quantile of a two-sided 95% interval.
+const confidence95 = 1.96
+
+// StdDev returns the standard deviations of the rotation (degrees) and of
+// the translation.
+func (c TransformCovariance) StdDev() (rotation float64, translation Point) {
+	return math.Sqrt(math.Max(c[0][0], 0)), Point{X: math.Sqrt(math.Max(c[1][1], 0)), Y: math.Sqrt(math.Max(c[2][2], 0))}
+}
+
+// Interval95 returns the half-widths of the 95% confidence intervals of the
+// rotation (degrees) and of the translation.
+func (c TransformCovariance) Interval95() (rotation float64, translation Point) {
+	r, t := c.StdDev()
+	return confidence95 * r, Point{X: confidence95 * t.X, Y: confidence95 * t.Y}
+}
+
+// shifted returns the covariance of the transform whose translation is
+// moved by offset turning with it, as composing on the right moves it:
+// (A, T) x (B, t) has translation T + A t.
+func (c TransformCovariance) shifted(offset Point) TransformCovariance {
+	// d(A t)/d rotation, per degree
+	g := Point{X: -offset.Y * math.Pi / 180, Y: offset.X * math.Pi / 180}
+	jacobian := [3][3]float64{{1, 0, 0}, {g.X, 1, 0}, {g.Y, 0, 1}}
+	return TransformCovariance(multiply3(multiply3(jacobian, c), transpose3(jacobian)))
+}
+
+// rebased returns the covariance of inverse x transform, the transform
+// re-expressed from the new reference, whose transform's inverse is inverse
+// and whose covariance is ref, the two taken as independent. rebased is the
+// product, the translation of which the reference's rotation turns.
+func (c TransformCovariance) rebased(ref TransformCovariance, inverse, rebased AffineMatrix) TransformCovariance {
+	// d(translation)/d(reference rotation), per degree
+	g := Point{X: rebased.Ty * math.Pi / 180, Y: -rebased.Tx * math.Pi / 180}
+	own := [3][3]float64{{1, 0, 0}, {0, inverse.A, inverse.B}, {0, inverse.C, inverse.D}}
+	reference := [3][3]float64{{-1, 0, 0}, {g.X, -inverse.A, -inverse.B}, {g.Y, -inverse.C, -inverse.D}}
+	a := multiply3(multiply3(own, c), transpose3(own))
+	b := multiply3(multiply3(reference, ref), transpose3(reference))
+	var sum TransformCovariance
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			sum[i][j] = a[i][j] + b[i][j]
+		}
+	}
+	return sum
+}
+
+// ComposedWith returns r with its transform, and those of its
+// alternatives, composed with right, which is applied first (e.g. a mirror,
+// see MirrorMap), and its covariance carried over.
+func (r ICPResult) ComposedWith(right AffineMatrix) ICPResult {
+	composed := MultiplyMatrices(r.Transform, right)
+	if r.Covariance != nil {
+		c := r.Covariance.shifted(Point{X: composed.Tx - r.Transform.Tx, Y: composed.Ty - r.Transform.Ty})
+		r.Covariance = &c
+	}
+	r.Transform = composed
+	r.Alternatives = composeHypotheses(r.Alternatives, right)
+	return r
+}
+
+// estimateCovariance estimates the covariance of transform, the result of
+// aligning source onto target, from the Jacobian of its final
+// correspondences: each source point is matched to its nearest target
+// point, and its residual taken along the target's wall normal there, so
+// sliding along a straight corridor shows as uncertainty. The residual
+// variance scales the inverse of the normal equations. It returns nil when
+// too few points match or the fit is degenerate.
+func estimateCovariance(source, target []Point, transform AffineMatrix, outlierPercentile float64) *TransformCovariance {
+	source = samplePointSlice(source, covarianceMaxPoints)
+	target = samplePointSlice(target, covarianceMaxPoints)
+	normals := make(map[Point]Point, len(target))
+	for i, n := range EstimateNormals(target) {
+		normals[target[i]] = n
+	}
+
+	srcCorr, tgtCorr, distances := findCorrespondencesWithDistances(TransformPoints(source, transform), target, covarianceMaxDist)
+	srcCorr, tgtCorr, _ = rejectOutliers(srcCorr, tgtCorr, distances, outlierPercentile)
+	if len(srcCorr) < covarianceMinMatches {
+		return nil
+	}
+
+	// Rows of the Jacobian with respect to (rotation in radians, Tx, Ty):
+	// turning the transform moves a point p by (-(p.Y - Ty), p.X - Tx)
+	var h [3][3]float64
+	sumSq, rows := 0.0, 0
+	add := func(j [3]float64, r float64) {
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				h[a][b] += j[a] * j[b]
+			}
+		}
+		sumSq += r * r
+		rows++
+	}
+	for i, p := range srcCorr {
+		q := tgtCorr[i]
+		turn := Point{X: -(p.Y - transform.Ty), Y: p.X - transform.Tx}
+		if n := normals[q]; n != (Point{}) {
+			add([3]float64{n.X*turn.X + n.Y*turn.Y, n.X, n.Y}, n.X*(p.X-q.X)+n.Y*(p.Y-q.Y))
+			continue
+		}
+		add([3]float64{turn.X, 1, 0}, p.X-q.X)
+		add([3]float64{turn.Y, 0, 1}, p.Y-q.Y)
+	}
+	if rows <= 3 {
+		return nil
+	}
+	variance := math.Max(sumSq/float64(rows-3), covarianceMinVariance)
+
+	inverse, ok := invert3(h)
+	if !ok {
+		return nil
+	}
+	var c TransformCovariance
+	scale := [3]float64{180 / math.Pi, 1, 1}
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			c[a][b] = variance * inverse[a][b] * scale[a] * scale[b]
+		}
+	}
+	return &c
+}
+
+// alignmentCovariance estimates the covariance of transform, aligning
+// source onto target, from their walls, which the final refinement fits, or
+// from the sampled points when either map has too few walls.
+func alignmentCovariance(source, target FeatureSet, sourcePoints, targetPoints []Point, transform AffineMatrix, config ICPConfig) *TransformCovariance {
+	if len(source.WallPoints) > covarianceMinMatches && len(target.WallPoints) > covarianceMinMatches {
+		sourcePoints, targetPoints = source.WallPoints, target.WallPoints
+	}
+	return estimateCovariance(sourcePoints, targetPoints, transform, config.OutlierPercentile)
+}
+
+// FuseCalibration weighs next, a new calibration of a vacuum, against prev,
+// the one it replaces, by their covariances: the transform moves from prev
+// toward next as far as next is the more certain of the two (the Kalman
+// gain), and the fused covariance shrinks accordingly. When either lacks a
+// covariance, they differ in handedness, or they disagree beyond what their
+// uncertainty explains (the map itself changed), next is taken as it is.
+// gain is the share of the rotation change taken, 1 when next is.
+func FuseCalibration(prev, next VacuumCalibration) (fused VacuumCalibration, gain float64) {
+	if prev.Covariance == nil || next.Covariance == nil || IsMirrored(prev.Transform) != IsMirrored(next.Transform) {
+		return next, 1
+	}
+	delta := [3]float64{
+		signedDegrees(TransformRotation(next.Transform) - TransformRotation(prev.Transform)),
+		next.Transform.Tx - prev.Transform.Tx,
+		next.Transform.Ty - prev.Transform.Ty,
+	}
+	var sum [3][3]float64
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			sum[a][b] = prev.Covariance[a][b] + next.Covariance[a][b]
+		}
+	}
+	sumInverse, ok := invert3(sum)
+	if !ok {
+		return next, 1
+	}
+	distance := 0.0
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			distance += delta[a] * sumInverse[a][b] * delta[b]
+		}
+	}
+	if distance > covarianceGate {
+		return next, 1
+	}
+
+	k := multiply3(*prev.Covariance, sumInverse)
+	var step [3]float64
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			step[a] += k[a][b] * delta[b]
+		}
+	}
+	var covariance TransformCovariance
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			covariance[a][b] = prev.Covariance[a][b]
+			for i := 0; i < 3; i++ {
+				covariance[a][b] -= k[a][i] * prev.Covariance[i][b]
+			}
+		}
+	}
+
+	fused = next
+	fused.Transform = MultiplyMatrices(RotationDeg(step[0]), prev.Transform)
+	fused.Transform.Tx = prev.Transform.Tx + step[1]
+	fused.Transform.Ty = prev.Transform.Ty + step[2]
+	fused.Covariance = &covariance
+	return fused, k[0][0]
+}
+
+// invert3 inverts a 3x3 matrix column by column with solve3.
+func invert3(m [3][3]float64) ([3][3]float64, bool) {
+	var inverse [3][3]float64
+	for col := 0; col < 3; col++ {
+		var e [3]float64
+		e[col] = 1
+		x, ok := solve3(m, e)
+		if !ok {
+			return inverse, false
+		}
+		for row := 0; row < 3; row++ {
+			inverse[row][col] = x[row]
+		}
+	}
+	return inverse, true
+}
+
+// multiply3 returns the product of two 3x3 matrices.
+func multiply3(a, b [3][3]float64) [3][3]float64 {
+	var p [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				p[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return p
+}
+
+// transpose3 returns the transpose of a 3x3 matrix.
+func transpose3(m [3][3]float64) [3][3]float64 {
+	var t [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			t[i][j] = m[j][i]
+		}
+	}
+	return t
+}
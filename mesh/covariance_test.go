@@ -0,0 +1,120 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateCovariance_Room(t *testing.T) {
+	walls := ExtractFeatures(rotatedRoom(0)).WallPoints
+	c := estimateCovariance(walls, walls, Identity(), 0.8)
+	if c == nil {
+		t.Fatal("no covariance")
+	}
+	rot, trans := c.StdDev()
+	if rot <= 0 || rot > 0.1 || trans.X <= 0 || trans.X > 1 || trans.Y <= 0 || trans.Y > 1 {
+		t.Errorf("std dev = %.4f°, (%.3f, %.3f); want small but positive", rot, trans.X, trans.Y)
+	}
+}
+
+func TestEstimateCovariance_Corridor(t *testing.T) {
+	// Two long parallel walls pin Y, and only the short ends X: sliding
+	// along them is uncertain. The corridor is about the origin, which the
+	// rotation turns about, so the rotation does not blur the translation.
+	var walls []Point
+	for x := -150.0; x <= 150; x++ {
+		walls = append(walls, Point{X: x, Y: -10}, Point{X: x, Y: 10})
+	}
+	for y := -9.0; y < 10; y++ {
+		walls = append(walls, Point{X: -150, Y: y}, Point{X: 150, Y: y})
+	}
+	c := estimateCovariance(walls, walls, Identity(), 0.8)
+	if c == nil {
+		t.Fatal("no covariance")
+	}
+	_, trans := c.StdDev()
+	if trans.X < 3*trans.Y {
+		t.Errorf("std dev along the corridor %.3f, across %.3f; want along much larger", trans.X, trans.Y)
+	}
+}
+
+func TestEstimateCovariance_TooFewMatches(t *testing.T) {
+	source := []Point{{0, 0}, {1, 0}, {2, 0}}
+	if c := estimateCovariance(source, source, Identity(), 0.8); c != nil {
+		t.Errorf("covariance = %v, want nil", c)
+	}
+}
+
+func TestICPResult_ComposedWith(t *testing.T) {
+	cov := TransformCovariance{{1, 0, 0}, {0, 2, 0}, {0, 0, 3}}
+	r := ICPResult{Transform: Identity(), Covariance: &cov}
+	composed := r.ComposedWith(AffineMatrix{A: -1, D: 1, Tx: 100})
+	if composed.Transform != (AffineMatrix{A: -1, D: 1, Tx: 100}) {
+		t.Errorf("transform = %+v", composed.Transform)
+	}
+	// Turning by a degree moves the offset (100, 0) by 100·π/180 in Y
+	g := 100 * math.Pi / 180
+	c := *composed.Covariance
+	if c[0][0] != 1 || c[1][1] != 2 || math.Abs(c[2][2]-(3+g*g)) > 1e-9 || math.Abs(c[0][2]-g) > 1e-9 {
+		t.Errorf("covariance = %v", c)
+	}
+	if cov[2][2] != 3 {
+		t.Error("original covariance changed")
+	}
+}
+
+func TestFuseCalibration(t *testing.T) {
+	cov := TransformCovariance{{0.04, 0, 0}, {0, 4, 0}, {0, 0, 4}}
+	prev := VacuumCalibration{Transform: CreateRotationTranslation(10, 100, 200), Covariance: &cov}
+
+	t.Run("agreeing transforms meet halfway", func(t *testing.T) {
+		next := VacuumCalibration{Transform: CreateRotationTranslation(10.2, 102, 198), ICPScore: 0.9, Covariance: &cov}
+		fused, gain := FuseCalibration(prev, next)
+		if math.Abs(gain-0.5) > 1e-9 {
+			t.Errorf("gain = %v, want 0.5", gain)
+		}
+		if r := TransformRotation(fused.Transform); math.Abs(r-10.1) > 1e-9 {
+			t.Errorf("rotation = %v, want 10.1", r)
+		}
+		if math.Abs(fused.Transform.Tx-101) > 1e-9 || math.Abs(fused.Transform.Ty-199) > 1e-9 {
+			t.Errorf("translation = (%v, %v), want (101, 199)", fused.Transform.Tx, fused.Transform.Ty)
+		}
+		if fused.ICPScore != 0.9 || math.Abs(fused.Covariance[1][1]-2) > 1e-9 {
+			t.Errorf("fused = %+v, covariance %v", fused, *fused.Covariance)
+		}
+	})
+
+	t.Run("a certain transform is trusted more", func(t *testing.T) {
+		tight := TransformCovariance{{0.0004, 0, 0}, {0, 0.04, 0}, {0, 0, 0.04}}
+		next := VacuumCalibration{Transform: CreateRotationTranslation(10.2, 102, 198), Covariance: &tight}
+		fused, gain := FuseCalibration(prev, next)
+		if gain < 0.98 || math.Abs(fused.Transform.Tx-102) > 0.1 {
+			t.Errorf("gain = %v, Tx = %v; want next nearly as it is", gain, fused.Transform.Tx)
+		}
+	})
+
+	t.Run("disagreeing transforms take the new one", func(t *testing.T) {
+		next := VacuumCalibration{Transform: CreateRotationTranslation(90, 400, 0), Covariance: &cov}
+		if fused, gain := FuseCalibration(prev, next); gain != 1 || fused.Transform != next.Transform {
+			t.Errorf("gain = %v, transform = %+v; want next", gain, fused.Transform)
+		}
+	})
+
+	t.Run("unknown uncertainty takes the new one", func(t *testing.T) {
+		next := VacuumCalibration{Transform: CreateRotationTranslation(10.2, 102, 198)}
+		if fused, gain := FuseCalibration(prev, next); gain != 1 || fused.Transform != next.Transform {
+			t.Errorf("gain = %v, transform = %+v; want next", gain, fused.Transform)
+		}
+	})
+}
+
+func TestAlignMaps_Covariance(t *testing.T) {
+	result := AlignMaps(rotatedRoom(30), rotatedRoom(0), DefaultICPConfig())
+	if result.Covariance == nil {
+		t.Fatal("no covariance")
+	}
+	rot, trans := result.Covariance.Interval95()
+	if rot > 1 || trans.X > 10 || trans.Y > 10 {
+		t.Errorf("95%% interval ±%.2f°, ±(%.1f, %.1f); want a tight alignment", rot, trans.X, trans.Y)
+	}
+}
@@ -0,0 +1,124 @@
+package mesh
+
+import "sort"
+
+// CoverageStats summarizes how the vacuums' floors cover the home once
+// transformed into the reference frame. Low overlap between vacuums that
+// share rooms usually means a poor calibration.
+type CoverageStats struct {
+	TotalArea       float64            `json:"totalArea"`       // m², union of all floors
+	CoverageOverlap float64            `json:"coverageOverlap"` // 0-1, fraction of TotalArea covered by two or more vacuums
+	VacuumAreas     map[string]float64 `json:"vacuumAreas"`     // m² of floor per vacuum
+	Overlaps        []CoverageOverlap  `json:"overlaps"`        // one per overlapping pair, sorted by vacuum IDs
+}
+
+// CoverageOverlap is the floor shared by two vacuums.
+type CoverageOverlap struct {
+	Vacuums  [2]string `json:"vacuums"` // sorted by ID
+	AreaM2   float64   `json:"areaM2"`
+	Fraction float64   `json:"fraction"` // AreaM2 over the smaller vacuum's floor area
+}
+
+// ComputeCoverageStats rasterizes every vacuum's floor into the reference
+// grid and measures the union and the pairwise overlaps. Areas are rounded
+// to 0.01 m² and fractions to three places.
+func ComputeCoverageStats(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference string) CoverageStats {
+	stats := CoverageStats{VacuumAreas: make(map[string]float64), Overlaps: []CoverageOverlap{}}
+
+	ids := make([]string, 0, len(maps))
+	coverage := make(map[string]coverageGrid, len(maps))
+	counts := make(map[string]int, len(maps))
+	for id, m := range maps {
+		transform, ok := transforms[id]
+		if !ok {
+			transform = Identity()
+		}
+		if g, ok := vacuumCoverage(m, transform); ok {
+			ids = append(ids, id)
+			coverage[id] = g
+			counts[id] = g.count()
+		}
+	}
+	if len(ids) == 0 {
+		return stats
+	}
+	sort.Strings(ids)
+
+	pixelSize := referencePixelSize(maps, reference)
+	cellM2 := pixelSize * pixelSize / 1e6
+	for _, id := range ids {
+		stats.VacuumAreas[id] = roundTo(float64(counts[id])*cellM2, 2)
+	}
+
+	// Union and multiply-covered cells over the combined bounding box
+	first := coverage[ids[0]]
+	minX, minY := first.minX, first.minY
+	maxX, maxY := first.minX+first.width, first.minY+first.height
+	for _, id := range ids[1:] {
+		g := coverage[id]
+		minX, minY = min(minX, g.minX), min(minY, g.minY)
+		maxX, maxY = max(maxX, g.minX+g.width), max(maxY, g.minY+g.height)
+	}
+	union, shared := 0, 0
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			n := 0
+			for _, id := range ids {
+				if coverage[id].has(x, y) {
+					n++
+				}
+			}
+			if n > 0 {
+				union++
+			}
+			if n > 1 {
+				shared++
+			}
+		}
+	}
+	stats.TotalArea = roundTo(float64(union)*cellM2, 2)
+	if union > 0 {
+		stats.CoverageOverlap = roundTo(float64(shared)/float64(union), 3)
+	}
+
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			n := coverage[a].intersectionCount(coverage[b])
+			if n == 0 {
+				continue
+			}
+			stats.Overlaps = append(stats.Overlaps, CoverageOverlap{
+				Vacuums:  [2]string{a, b},
+				AreaM2:   roundTo(float64(n)*cellM2, 2),
+				Fraction: roundTo(float64(n)/float64(min(counts[a], counts[b])), 3),
+			})
+		}
+	}
+	return stats
+}
+
+// count returns the number of covered cells.
+func (g coverageGrid) count() int {
+	n := 0
+	for _, set := range g.cells {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// intersectionCount returns the number of cells covered by both grids.
+func (g coverageGrid) intersectionCount(other coverageGrid) int {
+	minX, minY := max(g.minX, other.minX), max(g.minY, other.minY)
+	maxX, maxY := min(g.minX+g.width, other.minX+other.width), min(g.minY+g.height, other.minY+other.height)
+	n := 0
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			if g.has(x, y) && other.has(x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// ComputeCoverageStats
+// ---------------------------------------------------------------------------
+
+func TestComputeCoverageStats(t *testing.T) {
+	// 200x200 cells at 5mm are 1 m²; b overlaps a by half, c sits apart
+	maps := map[string]*ValetudoMap{
+		"a": rectFloor(0, 0, 200, 200),
+		"b": rectFloor(100, 0, 300, 200),
+		"c": rectFloor(1000, 0, 1200, 200),
+	}
+	stats := ComputeCoverageStats(maps, nil, "a")
+
+	// a and b span 1.5 m² together
+	if stats.TotalArea != 2.5 {
+		t.Errorf("TotalArea = %v, want 2.5 m²", stats.TotalArea)
+	}
+	// 0.5 m² shared out of 2.5 m²
+	if math.Abs(stats.CoverageOverlap-0.2) > 1e-9 {
+		t.Errorf("CoverageOverlap = %v, want 0.2", stats.CoverageOverlap)
+	}
+	for id, want := range map[string]float64{"a": 1, "b": 1, "c": 1} {
+		if stats.VacuumAreas[id] != want {
+			t.Errorf("VacuumAreas[%s] = %v, want %v", id, stats.VacuumAreas[id], want)
+		}
+	}
+	if len(stats.Overlaps) != 1 {
+		t.Fatalf("Overlaps = %+v, want only a/b", stats.Overlaps)
+	}
+	o := stats.Overlaps[0]
+	if o.Vacuums != [2]string{"a", "b"} || o.AreaM2 != 0.5 || o.Fraction != 0.5 {
+		t.Errorf("overlap = %+v, want a/b sharing 0.5 m² (0.5)", o)
+	}
+}
+
+func TestComputeCoverageStats_Transforms(t *testing.T) {
+	// Moving b onto a makes the two coincide
+	maps := map[string]*ValetudoMap{"a": rectFloor(0, 0, 100, 100), "b": rectFloor(100, 0, 200, 100)}
+	stats := ComputeCoverageStats(maps, map[string]AffineMatrix{"b": Translation(-100, 0)}, "a")
+	if stats.CoverageOverlap != 1 || stats.TotalArea != 0.25 {
+		t.Errorf("stats = %+v, want full overlap of 0.25 m²", stats)
+	}
+	if len(stats.Overlaps) != 1 || stats.Overlaps[0].Fraction != 1 {
+		t.Errorf("Overlaps = %+v, want one full overlap", stats.Overlaps)
+	}
+}
+
+func TestComputeCoverageStats_NoFloors(t *testing.T) {
+	stats := ComputeCoverageStats(map[string]*ValetudoMap{"a": {}}, nil, "a")
+	if stats.TotalArea != 0 || stats.CoverageOverlap != 0 || len(stats.Overlaps) != 0 || len(stats.VacuumAreas) != 0 {
+		t.Errorf("stats = %+v, want zero", stats)
+	}
+}
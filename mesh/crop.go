@@ -0,0 +1,148 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SegmentCropMargin is the space (in mm) left around a segment when
+// rendering it on its own, so the surrounding walls stay visible.
+const SegmentCropMargin = 250.0
+
+// defaultPixelSize is Valetudo's grid resolution (mm per pixel) when a map
+// does not report one.
+const defaultPixelSize = 5
+
+// CropRegion is a rectangle in the reference map's world coordinates (mm).
+type CropRegion struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// ParseCropRegion parses "x1,y1,x2,y2" in millimeters. The corners may be
+// given in any order.
+func ParseCropRegion(s string) (*CropRegion, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid crop %q (expected x1,y1,x2,y2)", s)
+	}
+
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid crop coordinate %q", strings.TrimSpace(p))
+		}
+		v[i] = f
+	}
+
+	c := &CropRegion{
+		MinX: math.Min(v[0], v[2]),
+		MinY: math.Min(v[1], v[3]),
+		MaxX: math.Max(v[0], v[2]),
+		MaxY: math.Max(v[1], v[3]),
+	}
+	if c.MaxX == c.MinX || c.MaxY == c.MinY {
+		return nil, fmt.Errorf("crop %q has zero area", s)
+	}
+	return c, nil
+}
+
+// Expand returns the region grown by margin on every side.
+func (c CropRegion) Expand(margin float64) CropRegion {
+	return CropRegion{
+		MinX: c.MinX - margin,
+		MinY: c.MinY - margin,
+		MaxX: c.MaxX + margin,
+		MaxY: c.MaxY + margin,
+	}
+}
+
+// referencePixelSize returns the grid resolution of the reference map, which
+// defines the world coordinate system of the composite.
+func referencePixelSize(maps map[string]*ValetudoMap, reference string) float64 {
+	if m, ok := maps[reference]; ok && m.PixelSize > 0 {
+		return float64(m.PixelSize)
+	}
+	return defaultPixelSize
+}
+
+// SegmentRegion returns the world-space bounding box (mm) of the segment
+// whose name or ID matches name (case-insensitive), after transforming it
+// into the reference frame. The reference map is searched first, then the
+// other maps in ID order. The second result is false if no map has the
+// segment.
+func SegmentRegion(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference, name string) (CropRegion, bool) {
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		if id != reference {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if _, ok := maps[reference]; ok {
+		ids = append([]string{reference}, ids...)
+	}
+
+	pixelSize := referencePixelSize(maps, reference)
+	for _, id := range ids {
+		transform, ok := transforms[id]
+		if !ok {
+			transform = Identity()
+		}
+
+		minX, minY := math.MaxFloat64, math.MaxFloat64
+		maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+		found := false
+		for _, layer := range maps[id].Layers {
+			if layer.Type != "segment" || !segmentMatches(layer.MetaData, name) {
+				continue
+			}
+			for _, p := range PixelsToPoints(layer.Pixels) {
+				forEachBlockCell(p, maps[id].CellBlock(), func(c Point) {
+					tp := TransformPoint(c, transform)
+					minX, minY = math.Min(minX, tp.X), math.Min(minY, tp.Y)
+					maxX, maxY = math.Max(maxX, tp.X), math.Max(maxY, tp.Y)
+					found = true
+				})
+			}
+		}
+		if found {
+			// A grid point covers the cell to its right and below
+			return CropRegion{
+				MinX: minX * pixelSize,
+				MinY: minY * pixelSize,
+				MaxX: (maxX + 1) * pixelSize,
+				MaxY: (maxY + 1) * pixelSize,
+			}, true
+		}
+	}
+	return CropRegion{}, false
+}
+
+// segmentMatches reports whether a segment layer is named or identified by name.
+func segmentMatches(meta LayerMetaData, name string) bool {
+	return (meta.Name != "" && strings.EqualFold(meta.Name, name)) ||
+		(meta.SegmentID != "" && meta.SegmentID == name)
+}
+
+// cropBounds returns the renderer's crop region in reference grid units,
+// rotated by GlobalRotation about the region's center.
+func (r *CompositeRenderer) cropBounds() (minX, minY, maxX, maxY, centerX, centerY float64) {
+	pixelSize := referencePixelSize(r.Maps, r.Reference)
+	c := r.Crop
+	x0, y0 := c.MinX/pixelSize, c.MinY/pixelSize
+	x1, y1 := c.MaxX/pixelSize, c.MaxY/pixelSize
+	centerX, centerY = (x0+x1)/2, (y0+y1)/2
+
+	minX, minY = math.MaxFloat64, math.MaxFloat64
+	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
+	for _, p := range []Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x0, Y: y1}, {X: x1, Y: y1}} {
+		rp := r.applyGlobalRotation(p, centerX, centerY)
+		minX, minY = math.Min(minX, rp.X), math.Min(minY, rp.Y)
+		maxX, maxY = math.Max(maxX, rp.X), math.Max(maxY, rp.Y)
+	}
+	return
+}
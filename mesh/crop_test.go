@@ -0,0 +1,130 @@
+package mesh
+
+import (
+	"image/color"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// ParseCropRegion
+// ---------------------------------------------------------------------------
+
+func TestParseCropRegion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CropRegion
+		wantErr bool
+	}{
+		{in: "0,0,1000,500", want: CropRegion{0, 0, 1000, 500}},
+		{in: "1000, 500, -200, 0", want: CropRegion{-200, 0, 1000, 500}},
+		{in: "1.5,2.5,3.5,4.5", want: CropRegion{1.5, 2.5, 3.5, 4.5}},
+		{in: "0,0,1000", wantErr: true},
+		{in: "0,0,1000,abc", wantErr: true},
+		{in: "0,0,0,500", wantErr: true},
+		{in: "0,0,NaN,500", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseCropRegion(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCropRegion(%q) = %+v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCropRegion(%q) error: %v", tt.in, err)
+			}
+			if *got != tt.want {
+				t.Errorf("ParseCropRegion(%q) = %+v, want %+v", tt.in, *got, tt.want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SegmentRegion
+// ---------------------------------------------------------------------------
+
+func segmentMap(name, id string, pixels []int) *ValetudoMap {
+	return &ValetudoMap{
+		PixelSize: 5,
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: []int{0, 0, 5, 0, 99, 0}},
+			{Type: "segment", MetaData: LayerMetaData{SegmentID: id, Name: name}, Pixels: pixels},
+		},
+	}
+}
+
+func TestSegmentRegion(t *testing.T) {
+	maps := map[string]*ValetudoMap{
+		"ref":   segmentMap("Kitchen", "1", []int{10, 20, 19, 21}),
+		"other": segmentMap("Garage", "7", []int{0, 0, 3, 0}),
+	}
+	transforms := map[string]AffineMatrix{
+		"ref":   Identity(),
+		"other": {A: 1, D: 1, Tx: 100, Ty: 50},
+	}
+
+	got, ok := SegmentRegion(maps, transforms, "ref", "kitchen")
+	if !ok {
+		t.Fatal("kitchen not found")
+	}
+	want := CropRegion{MinX: 50, MinY: 100, MaxX: 100, MaxY: 110}
+	if got != want {
+		t.Errorf("kitchen region = %+v, want %+v", got, want)
+	}
+
+	// Segments from other maps are placed in the reference frame
+	got, ok = SegmentRegion(maps, transforms, "ref", "Garage")
+	if !ok {
+		t.Fatal("garage not found")
+	}
+	want = CropRegion{MinX: 500, MinY: 250, MaxX: 520, MaxY: 255}
+	if got != want {
+		t.Errorf("garage region = %+v, want %+v", got, want)
+	}
+
+	if _, ok := SegmentRegion(maps, transforms, "ref", "7"); !ok {
+		t.Error("expected lookup by segment ID to succeed")
+	}
+	if _, ok := SegmentRegion(maps, transforms, "ref", "Attic"); ok {
+		t.Error("expected unknown segment to be missing")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CompositeRenderer crop
+// ---------------------------------------------------------------------------
+
+func TestRender_CropLimitsImageToRegion(t *testing.T) {
+	maps := map[string]*ValetudoMap{"ref": segmentMap("Kitchen", "1", []int{10, 20})}
+	renderer := NewCompositeRenderer(maps, map[string]AffineMatrix{"ref": Identity()}, "ref")
+	renderer.Padding = 0
+	renderer.Legend.Hidden = true
+	renderer.Crop = &CropRegion{MinX: 0, MinY: 0, MaxX: 200, MaxY: 100}
+
+	img := renderer.Render()
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != 40 || h != 20 {
+		t.Fatalf("cropped image = %dx%d, want 40x20", w, h)
+	}
+	// The floor pixel at (5, 0) is inside the crop
+	if c := img.RGBAAt(5, 0); c == (color.RGBA{240, 240, 240, 255}) {
+		t.Error("expected floor pixel inside crop to be drawn")
+	}
+}
+
+func TestCalculateBounds_CropRotated(t *testing.T) {
+	renderer := NewCompositeRenderer(map[string]*ValetudoMap{"ref": {PixelSize: 5}}, nil, "ref")
+	renderer.Crop = &CropRegion{MinX: 0, MinY: 0, MaxX: 200, MaxY: 100}
+	renderer.GlobalRotation = 90
+
+	minX, minY, maxX, maxY, cx, cy := renderer.CalculateBounds()
+	if cx != 20 || cy != 10 {
+		t.Errorf("center = (%v, %v), want (20, 10)", cx, cy)
+	}
+	if w, h := maxX-minX, maxY-minY; w < 19.99 || w > 20.01 || h < 39.99 || h > 40.01 {
+		t.Errorf("rotated crop size = %vx%v, want 20x40", w, h)
+	}
+}
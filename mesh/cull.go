@@ -0,0 +1,77 @@
+package mesh
+
+import "math"
+
+// cellView is the box of a map's own grid cells that can reach a render's
+// image: the image rectangle taken back through the global rotation and the
+// map's transform. Layers outside it are skipped whole, and only the cells
+// inside it of the others are transformed, so a crop of a small region does
+// not transform every pixel of every map.
+type cellView struct {
+	minX, minY, maxX, maxY float64
+	all                    bool // the transform cannot be inverted; nothing is culled
+}
+
+// visibleCells returns the view of the map drawn with transform into a width x
+// height image whose pixels map to world grid points through toWorld. The
+// image is widened by margin pixels for marks drawn around a cell's pixel,
+// such as the 3x3 wall blocks.
+func (r *CompositeRenderer) visibleCells(transform AffineMatrix, toWorld func(x, y float64) Point, width, height int, margin float64) cellView {
+	inverse, ok := transform.Inverse()
+	if !ok {
+		return cellView{all: true}
+	}
+	v := cellView{minX: math.Inf(1), minY: math.Inf(1), maxX: math.Inf(-1), maxY: math.Inf(-1)}
+	// One more pixel for image coordinates that round into the image
+	x0, y0 := -margin-1, -margin-1
+	x1, y1 := float64(width)+margin+1, float64(height)+margin+1
+	for _, c := range [4][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		p := TransformPoint(toWorld(c[0], c[1]), inverse)
+		v.minX, v.minY = math.Min(v.minX, p.X), math.Min(v.minY, p.Y)
+		v.maxX, v.maxY = math.Max(v.maxX, p.X), math.Max(v.maxY, p.Y)
+	}
+	// A cell covers the unit square from its pixel
+	v.minX, v.minY = v.minX-1, v.minY-1
+	return v
+}
+
+// forBlock widens the view for a map whose pixels each stand for a block x
+// block square of cells (see CellBlock), which reaches the view from up to
+// block-1 cells further up and left.
+func (v cellView) forBlock(block int) cellView {
+	if block > 1 {
+		v.minX, v.minY = v.minX-float64(block-1), v.minY-float64(block-1)
+	}
+	return v
+}
+
+// overlaps reports whether any of a layer's pixels may lie in the view,
+// by the layer's bounding box.
+func (v cellView) overlaps(pixels []int) bool {
+	if v.all {
+		return true
+	}
+	minX, minY, maxX, maxY, ok := pixelBounds(pixels)
+	return ok && float64(maxX) >= v.minX && float64(minX) <= v.maxX &&
+		float64(maxY) >= v.minY && float64(minY) <= v.maxY
+}
+
+// contains reports whether the cell at p, in the map's own grid, may reach
+// the image.
+func (v cellView) contains(p Point) bool {
+	return v.all || p.X >= v.minX && p.X <= v.maxX && p.Y >= v.minY && p.Y <= v.maxY
+}
+
+// pixelBounds returns the bounding box of a flat pixel array.
+func pixelBounds(pixels []int) (minX, minY, maxX, maxY int, ok bool) {
+	for i := 0; i+1 < len(pixels); i += 2 {
+		x, y := pixels[i], pixels[i+1]
+		if !ok {
+			minX, minY, maxX, maxY, ok = x, y, x, y, true
+			continue
+		}
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	return minX, minY, maxX, maxY, ok
+}
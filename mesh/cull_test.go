@@ -0,0 +1,70 @@
+package mesh
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVisibleCells_KeepsEveryDrawnCell(t *testing.T) {
+	transform := CreateRotationTranslation(30, 40, -25)
+	for _, rotation := range []float64{0, 90, 37} {
+		for _, scale := range []float64{1, 0.5} {
+			t.Run(fmt.Sprintf("rotation %g scale %g", rotation, scale), func(t *testing.T) {
+				r := NewCompositeRenderer(map[string]*ValetudoMap{"vac": {PixelSize: 5}}, nil, "vac")
+				r.GlobalRotation = rotation
+				r.Scale = scale
+				r.Padding = 3
+				r.Crop = &CropRegion{MinX: 400, MinY: 300, MaxX: 700, MaxY: 500}
+
+				minX, minY, maxX, maxY, centerX, centerY := r.CalculateBounds()
+				width := int((maxX-minX)*r.Scale) + 2*r.Padding
+				height := int((maxY-minY)*r.Scale) + 2*r.Padding
+				cover := r.pixelCover(minX, minY, centerX, centerY)
+				view := r.visibleCells(transform, r.imageToWorld(minX, minY, centerX, centerY), width, height, 1)
+
+				culled := 0
+				for y := -50; y < 250; y++ {
+					for x := -50; x < 250; x++ {
+						p := Point{X: float64(x), Y: float64(y)}
+						drawn := false
+						cover(TransformPoint(p, transform), func(ix, iy int) {
+							drawn = drawn || ix >= -1 && ix <= width && iy >= -1 && iy <= height
+						})
+						if !view.contains(p) {
+							culled++
+							if drawn {
+								t.Fatalf("cell (%d, %d) reaches the image but is culled", x, y)
+							}
+						}
+					}
+				}
+				if culled < 300*300/2 {
+					t.Errorf("only %d of %d cells culled", culled, 300*300)
+				}
+			})
+		}
+	}
+}
+
+func TestCellView_Overlaps(t *testing.T) {
+	view := cellView{minX: 10, minY: 10, maxX: 20, maxY: 20}
+	tests := []struct {
+		name   string
+		pixels []int
+		want   bool
+	}{
+		{"inside", []int{12, 12, 15, 18}, true},
+		{"straddling", []int{0, 0, 30, 30}, true},
+		{"left of it", []int{0, 12, 9, 18}, false},
+		{"below it", []int{12, 21, 15, 40}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := view.overlaps(tt.pixels); got != tt.want {
+			t.Errorf("%s: overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !(cellView{all: true}).overlaps([]int{1000, 1000}) {
+		t.Error("a view of a singular transform culled a layer")
+	}
+}
@@ -0,0 +1,51 @@
+package mesh
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer coalesces bursts of triggers into one call of its function: the
+// first trigger schedules the call delay later, and triggers arriving before
+// it runs are absorbed into it. Calls therefore happen at most once per
+// delay however often triggers arrive, and the last trigger is never lost.
+type Debouncer struct {
+	delay time.Duration
+	fn    func()
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// NewDebouncer creates a debouncer that calls fn delay after a trigger.
+func NewDebouncer(delay time.Duration, fn func()) *Debouncer {
+	return &Debouncer{delay: delay, fn: fn}
+}
+
+// Trigger schedules a call unless one is already waiting.
+func (d *Debouncer) Trigger() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		return
+	}
+	d.timer = time.AfterFunc(d.delay, d.run)
+}
+
+// Flush runs a waiting call now, e.g. before shutdown.
+func (d *Debouncer) Flush() {
+	d.mu.Lock()
+	t := d.timer
+	d.mu.Unlock()
+	if t != nil && t.Stop() {
+		d.run()
+	}
+}
+
+// run clears the waiting timer and calls fn.
+func (d *Debouncer) run() {
+	d.mu.Lock()
+	d.timer = nil
+	d.mu.Unlock()
+	d.fn()
+}
@@ -0,0 +1,52 @@
+package mesh
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Debouncer
+// ---------------------------------------------------------------------------
+
+func TestDebouncer_CoalescesBurst(t *testing.T) {
+	var calls atomic.Int32
+	d := NewDebouncer(20*time.Millisecond, func() { calls.Add(1) })
+
+	for i := 0; i < 10; i++ {
+		d.Trigger()
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("called %d times before the delay, want 0", n)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("burst called %d times, want 1", n)
+	}
+
+	d.Trigger()
+	time.Sleep(80 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("trigger after the burst called %d times in total, want 2", n)
+	}
+}
+
+func TestDebouncer_Flush(t *testing.T) {
+	var calls atomic.Int32
+	d := NewDebouncer(time.Hour, func() { calls.Add(1) })
+
+	d.Flush()
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("flush without a trigger called %d times, want 0", n)
+	}
+	d.Trigger()
+	d.Flush()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("flush called %d times, want 1", n)
+	}
+	d.Flush()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("second flush called again (%d calls)", n)
+	}
+}
@@ -14,6 +14,12 @@ import (
 // - Raw JSON (fallback for testing)
 // - Zlib-compressed JSON without PNG wrapper
 func DecodeMapData(data []byte) (*ValetudoMap, error) {
+	return DecodeMapDataWithOptions(data, ParseOptions{})
+}
+
+// DecodeMapDataWithOptions decodes Valetudo map data like DecodeMapData,
+// then applies the given parse options.
+func DecodeMapDataWithOptions(data []byte, opts ParseOptions) (*ValetudoMap, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty data")
 	}
@@ -42,7 +48,12 @@ func DecodeMapData(data []byte) (*ValetudoMap, error) {
 		return nil, fmt.Errorf("decoded JSON payload is empty")
 	}
 
-	return ParseMapJSON(jsonBytes)
+	m, err := ParseMapJSON(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	DownsampleMap(m, opts.Downsample)
+	return m, nil
 }
 
 // IsPNG checks if data starts with PNG magic bytes
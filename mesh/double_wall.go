@@ -0,0 +1,251 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+// Defaults for CollapseDoubleWalls.
+const (
+	DefaultMaxWallThickness = 250.0 // mm; interior walls are 10-20cm thick
+	DefaultMinWallOverlap   = 0.6   // fraction of the shorter wall alongside the longer
+)
+
+// doubleWallAngleTolerance is how far from parallel, in degrees, the two
+// faces of one wall may run.
+const doubleWallAngleTolerance = 10.0
+
+// doubleWallSampleSpacing is the distance in mm between the points sampled
+// along a wall to compare it with its neighbour.
+const doubleWallSampleSpacing = 50.0
+
+// CollapseDoubleWalls replaces pairs of walls that are the two faces of one
+// wall, seen by vacuums on either side of it, with a single wall along
+// their centerline. Two walls are a pair when at least config's overlap
+// fraction of the shorter one runs parallel (or anti-parallel) to the
+// longer one within the maximum thickness. The longer wall is kept and moved
+// halfway toward the shorter one where they overlap; the median distance
+// between them is stored in the "thickness" property, in mm. Walls are
+// visited longest first and each collapses at most once per call.
+func CollapseDoubleWalls(walls []*UnifiedFeature, totalVacuums int, config DoubleWallConfig) []*UnifiedFeature {
+	if !config.enabled() || len(walls) < 2 {
+		return walls
+	}
+	maxThickness := config.maxThickness()
+	minOverlap := config.minOverlap()
+
+	type candidate struct {
+		feature *UnifiedFeature
+		line    orb.LineString
+		bound   orb.Bound
+		length  float64
+	}
+	var lines []*candidate
+	for _, uf := range walls {
+		ls := orbLineString(uf.Geometry)
+		if len(ls) < 2 {
+			continue
+		}
+		lines = append(lines, &candidate{feature: uf, line: ls, bound: ls.Bound(), length: lineLength(ls)})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].length > lines[j].length })
+
+	replaced := make(map[*UnifiedFeature]*UnifiedFeature)
+	for i, long := range lines {
+		if _, gone := replaced[long.feature]; gone {
+			continue
+		}
+		for _, short := range lines[i+1:] {
+			if _, gone := replaced[short.feature]; gone {
+				continue
+			}
+			if !long.bound.Pad(maxThickness).Intersects(short.bound) {
+				continue
+			}
+			distances := pairedDistances(short.line, long.line, maxThickness)
+			samples := len(sampleLine(short.line, doubleWallSampleSpacing))
+			if samples == 0 || float64(len(distances))/float64(samples) < minOverlap {
+				continue
+			}
+			replaced[short.feature] = nil
+			replaced[long.feature] = mergeWallFaces(long.feature, long.line, short.feature, short.line, median(distances), maxThickness, totalVacuums)
+			break
+		}
+	}
+	if len(replaced) == 0 {
+		return walls
+	}
+
+	// Keep the input order, dropping absorbed faces
+	result := make([]*UnifiedFeature, 0, len(walls))
+	for _, uf := range walls {
+		if r, ok := replaced[uf]; !ok {
+			result = append(result, uf)
+		} else if r != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// pairedDistances samples from along its length and returns, for each
+// sample facing a parallel stretch of to within maxDist, the distance
+// between them.
+func pairedDistances(from, to orb.LineString, maxDist float64) []float64 {
+	var distances []float64
+	for _, s := range sampleLine(from, doubleWallSampleSpacing) {
+		if _, d, ok := facingPoint(s.point, s.direction, to, maxDist); ok {
+			distances = append(distances, d)
+		}
+	}
+	return distances
+}
+
+// lineSample is a point on a line and the direction of the edge it lies on.
+type lineSample struct {
+	point     orb.Point
+	direction float64 // radians
+}
+
+// sampleLine returns the vertices of ls and points every spacing mm along
+// each of its edges.
+func sampleLine(ls orb.LineString, spacing float64) []lineSample {
+	var samples []lineSample
+	for i := 0; i+1 < len(ls); i++ {
+		a, b := ls[i], ls[i+1]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		dir := math.Atan2(dy, dx)
+		for t := 0.0; t < length; t += spacing {
+			f := t / length
+			samples = append(samples, lineSample{point: orb.Point{a[0] + f*dx, a[1] + f*dy}, direction: dir})
+		}
+		if i+2 == len(ls) {
+			samples = append(samples, lineSample{point: b, direction: dir})
+		}
+	}
+	return samples
+}
+
+// facingPoint returns the point of ls that p faces squarely: its
+// perpendicular foot on the nearest edge running within
+// doubleWallAngleTolerance of direction. Feet beyond an edge's ends or
+// farther than maxDist do not count, so a wall meeting another at a corner
+// is not taken for its other face.
+func facingPoint(p orb.Point, direction float64, ls orb.LineString, maxDist float64) (orb.Point, float64, bool) {
+	tolerance := doubleWallAngleTolerance * math.Pi / 180
+	best, bestDist, found := orb.Point{}, maxDist, false
+	for i := 0; i+1 < len(ls); i++ {
+		a, b := ls[i], ls[i+1]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		lenSq := dx*dx + dy*dy
+		if lenSq == 0 || lineAngleDiff(direction, math.Atan2(dy, dx)) > tolerance {
+			continue
+		}
+		t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / lenSq
+		if t < 0 || t > 1 {
+			continue
+		}
+		foot := orb.Point{a[0] + t*dx, a[1] + t*dy}
+		if d := math.Hypot(p[0]-foot[0], p[1]-foot[1]); d <= bestDist {
+			best, bestDist, found = foot, d, true
+		}
+	}
+	return best, bestDist, found
+}
+
+// lineAngleDiff returns the angle between two undirected lines, 0 to pi/2.
+func lineAngleDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), math.Pi)
+	return math.Min(d, math.Pi-d)
+}
+
+// mergeWallFaces moves long halfway toward short wherever the two face each
+// other and combines their sources. Points of long beyond the overlap stay
+// put; the wall simplification that follows unification removes the extra
+// vertices.
+func mergeWallFaces(base *UnifiedFeature, long orb.LineString, face *UnifiedFeature, short orb.LineString, thickness, maxDist float64, totalVacuums int) *UnifiedFeature {
+	samples := sampleLine(long, doubleWallSampleSpacing)
+	centerline := make(orb.LineString, 0, len(samples))
+	for _, s := range samples {
+		p := s.point
+		if foot, _, ok := facingPoint(p, s.direction, short, maxDist); ok {
+			p = orb.Point{(p[0] + foot[0]) / 2, (p[1] + foot[1]) / 2}
+		}
+		centerline = append(centerline, p)
+	}
+
+	props := make(map[string]interface{}, len(base.Properties)+1)
+	for k, v := range base.Properties {
+		props[k] = v
+	}
+	sources := append(append([]FeatureSource(nil), base.Sources...), face.Sources...)
+	vacuums := make(map[string]struct{})
+	for _, s := range sources {
+		if s.VacuumID != "" {
+			vacuums[s.VacuumID] = struct{}{}
+		}
+	}
+	observations := len(vacuums)
+	confidence := base.Confidence
+	if totalVacuums > 0 {
+		confidence = float64(observations) / float64(totalVacuums)
+	}
+	props["thickness"] = math.Round(thickness)
+	props["observationCount"] = observations
+	props["confidence"] = confidence
+
+	return &UnifiedFeature{
+		Geometry:         lineStringToGeometry(centerline),
+		Properties:       props,
+		Sources:          sources,
+		Confidence:       confidence,
+		ObservationCount: observations,
+	}
+}
+
+// lineLength returns the length of ls in its own units.
+func lineLength(ls orb.LineString) float64 {
+	total := 0.0
+	for i := 0; i+1 < len(ls); i++ {
+		total += math.Hypot(ls[i+1][0]-ls[i][0], ls[i+1][1]-ls[i][1])
+	}
+	return total
+}
+
+// median returns the middle value of values, which must not be empty.
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// enabled reports whether double walls are collapsed (the default).
+func (c DoubleWallConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// maxThickness returns the configured limit or DefaultMaxWallThickness.
+func (c DoubleWallConfig) maxThickness() float64 {
+	if c.MaxThickness > 0 {
+		return c.MaxThickness
+	}
+	return DefaultMaxWallThickness
+}
+
+// minOverlap returns the configured fraction or DefaultMinWallOverlap.
+func (c DoubleWallConfig) minOverlap() float64 {
+	if c.MinOverlap > 0 {
+		return c.MinOverlap
+	}
+	return DefaultMinWallOverlap
+}
@@ -0,0 +1,136 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// wallFeature returns a unified wall along coords observed by vacuumID
+// alone out of two vacuums.
+func wallFeature(vacuumID string, coords ...[2]float64) *UnifiedFeature {
+	ls := make(orb.LineString, len(coords))
+	for i, c := range coords {
+		ls[i] = orb.Point{c[0], c[1]}
+	}
+	return &UnifiedFeature{
+		Geometry:         lineStringToGeometry(ls),
+		Properties:       map[string]interface{}{"layerType": "wall", "observationCount": 1, "confidence": 0.5},
+		Sources:          []FeatureSource{makeSource(vacuumID, 1)},
+		Confidence:       0.5,
+		ObservationCount: 1,
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CollapseDoubleWalls
+// ---------------------------------------------------------------------------
+
+func TestCollapseDoubleWalls_OppositeFaces(t *testing.T) {
+	// "a" sees the wall's north face along y=0, "b" its south face 150mm
+	// away, drawn in the opposite direction
+	walls := []*UnifiedFeature{
+		wallFeature("a", [2]float64{0, 0}, [2]float64{4000, 0}),
+		wallFeature("b", [2]float64{3800, 150}, [2]float64{200, 150}),
+	}
+	got := CollapseDoubleWalls(walls, 2, DoubleWallConfig{})
+	if len(got) != 1 {
+		t.Fatalf("got %d walls, want 1", len(got))
+	}
+	w := got[0]
+	if thickness, _ := w.Properties["thickness"].(float64); thickness != 150 {
+		t.Errorf("thickness = %v, want 150", w.Properties["thickness"])
+	}
+	if w.ObservationCount != 2 || w.Confidence != 1 {
+		t.Errorf("observations = %d, confidence = %v, want 2 and 1", w.ObservationCount, w.Confidence)
+	}
+	if len(w.Sources) != 2 {
+		t.Errorf("got %d sources, want 2", len(w.Sources))
+	}
+
+	// Along the overlap the wall runs down the middle; past it, where only
+	// "a" saw the wall, it stays on the north face
+	for _, p := range orbLineString(w.Geometry) {
+		switch {
+		case p[0] > 300 && p[0] < 3700:
+			if math.Abs(p[1]-75) > 1e-9 {
+				t.Errorf("point %v inside the overlap, want y=75", p)
+			}
+		case p[0] < 150 || p[0] > 3850:
+			if p[1] != 0 {
+				t.Errorf("point %v outside the overlap, want y=0", p)
+			}
+		}
+	}
+}
+
+func TestCollapseDoubleWalls_KeepsSeparateWalls(t *testing.T) {
+	tests := []struct {
+		name  string
+		walls []*UnifiedFeature
+	}{
+		{"too far apart", []*UnifiedFeature{
+			wallFeature("a", [2]float64{0, 0}, [2]float64{4000, 0}),
+			wallFeature("b", [2]float64{0, 400}, [2]float64{4000, 400}),
+		}},
+		{"perpendicular", []*UnifiedFeature{
+			wallFeature("a", [2]float64{0, 0}, [2]float64{4000, 0}),
+			wallFeature("b", [2]float64{2000, 100}, [2]float64{2000, 3000}),
+		}},
+		{"little overlap", []*UnifiedFeature{
+			wallFeature("a", [2]float64{0, 0}, [2]float64{4000, 0}),
+			wallFeature("b", [2]float64{3500, 150}, [2]float64{5500, 150}),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseDoubleWalls(tt.walls, 2, DoubleWallConfig{}); len(got) != 2 {
+				t.Errorf("got %d walls, want 2", len(got))
+			}
+		})
+	}
+}
+
+func TestCollapseDoubleWalls_Config(t *testing.T) {
+	pair := func() []*UnifiedFeature {
+		return []*UnifiedFeature{
+			wallFeature("a", [2]float64{0, 0}, [2]float64{4000, 0}),
+			wallFeature("b", [2]float64{0, 200}, [2]float64{4000, 200}),
+		}
+	}
+	disabled := false
+	tests := []struct {
+		name   string
+		config DoubleWallConfig
+		want   int
+	}{
+		{"default", DoubleWallConfig{}, 1},
+		{"disabled", DoubleWallConfig{Enabled: &disabled}, 2},
+		{"thinner limit", DoubleWallConfig{MaxThickness: 150}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CollapseDoubleWalls(pair(), 2, tt.config); len(got) != tt.want {
+				t.Errorf("got %d walls, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestLineAngleDiff(t *testing.T) {
+	tests := []struct {
+		a, b, want float64
+	}{
+		{0, 0, 0},
+		{0, math.Pi, 0},
+		{0, math.Pi / 2, math.Pi / 2},
+		{math.Pi / 4, -3 * math.Pi / 4, 0},
+		{0.1, math.Pi - 0.1, 0.2},
+	}
+	for _, tt := range tests {
+		if got := lineAngleDiff(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("lineAngleDiff(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
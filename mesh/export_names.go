@@ -0,0 +1,104 @@
+package mesh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// exportTimestamp matches the timestamp suffix of a dated export name:
+// -2024-01-01, -20240101, -2024-01-01T12-30-00Z, -2024-01-01_12:30, or Unix
+// seconds and milliseconds. It needs a full date, so vacuum names such as
+// "robot-2" or "floor-2024" are left alone.
+var exportTimestamp = regexp.MustCompile(`-(?:(?:19|20)\d{2}-?[01]\d-?[0-3]\d(?:[T_ -]?[0-2]\d[-:.]?[0-5]\d(?:[-:.]?[0-5]\d(?:[.,]\d+)?)?)?Z?|1\d{9}(?:\d{3})?)$`)
+
+// VacuumIDFromExportFilename derives the vacuum ID from a map export file
+// name. "ValetudoMapExport-rocky7.json", "ValetudoMapExport-rocky7.json.gz"
+// and "ValetudoMapExport-rocky7-2024-01-01T12-30-00.json" all yield "rocky7".
+// It reports false for files that are not map exports.
+func VacuumIDFromExportFilename(path string) (string, bool) {
+	name, ok := strings.CutPrefix(filepath.Base(path), mapExportPrefix)
+	if !ok {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, ".gz")
+	if name, ok = strings.CutSuffix(name, ".json"); !ok {
+		return "", false
+	}
+	name = exportTimestamp.ReplaceAllString(name, "")
+	return name, name != ""
+}
+
+// ExportPattern recognizes map exports named by other tools. It is a
+// regular expression over the file name with a named group "id" for the
+// vacuum ID, e.g. `^(?P<id>[a-z0-9]+)_map_\d+\.json$`. Files that do not
+// match fall back to the ValetudoMapExport naming, which is also what
+// tudomesh writes. A nil *ExportPattern uses only the Valetudo naming.
+type ExportPattern struct {
+	re *regexp.Regexp
+	id int
+}
+
+// CompileExportPattern parses a file name pattern from the config. An empty
+// pattern returns nil, the Valetudo naming.
+func CompileExportPattern(pattern string) (*ExportPattern, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	id := re.SubexpIndex("id")
+	if id < 0 {
+		return nil, fmt.Errorf("pattern needs a named group (?P<id>...) for the vacuum ID")
+	}
+	return &ExportPattern{re: re, id: id}, nil
+}
+
+// ExportNames returns the compiled exportPattern of config, or nil for the
+// Valetudo naming when config is nil or sets none.
+func (c *Config) ExportNames() *ExportPattern {
+	if c == nil {
+		return nil
+	}
+	p, err := CompileExportPattern(c.ExportPattern)
+	if err != nil {
+		return nil // rejected by LoadConfig
+	}
+	return p
+}
+
+// VacuumID returns the vacuum ID of the export at path, or false when path
+// is not a map export.
+func (p *ExportPattern) VacuumID(path string) (string, bool) {
+	if p != nil {
+		if m := p.re.FindStringSubmatch(filepath.Base(path)); m != nil && m[p.id] != "" {
+			return m[p.id], true
+		}
+	}
+	return VacuumIDFromExportFilename(path)
+}
+
+// Find lists the map exports in dir, sorted by name. Hidden files, such as
+// the temporary files of an atomic write, are skipped.
+func (p *ExportPattern) Find(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if _, ok := p.VacuumID(e.Name()); ok {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
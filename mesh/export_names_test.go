@@ -0,0 +1,125 @@
+package mesh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// VacuumIDFromExportFilename
+// ---------------------------------------------------------------------------
+
+func TestVacuumIDFromExportFilename(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"ValetudoMapExport-rocky7.json", "rocky7", true},
+		{"/data/ValetudoMapExport-rocky7-2024-01-01.json", "rocky7", true},
+		{"ValetudoMapExport-test-vacuum-20240101.json", "test-vacuum", true},
+		{"ValetudoMapExport-rocky7.json.gz", "rocky7", true},
+		{"ValetudoMapExport-rocky7-2024-01-01T12-30-45Z.json", "rocky7", true},
+		{"ValetudoMapExport-rocky7-2024-01-01_12:30.json", "rocky7", true},
+		{"ValetudoMapExport-rocky7-1704067200.json", "rocky7", true},
+		{"ValetudoMapExport-rocky7-1704067200123.json", "rocky7", true},
+
+		// Names that merely contain "-2" are kept whole
+		{"ValetudoMapExport-robot-2.json", "robot-2", true},
+		{"ValetudoMapExport-floor-2-2024-03-04.json", "floor-2", true},
+		{"ValetudoMapExport-level-2024.json", "level-2024", true},
+		{"ValetudoMapExport-x-22.json", "x-22", true},
+
+		{"ValetudoMapExport-.json", "", false},
+		{"ValetudoMapExport-rocky7.txt", "", false},
+		{"rocky7.json", "", false},
+		{"composite-map.png", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := VacuumIDFromExportFilename(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("VacuumIDFromExportFilename(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ExportPattern
+// ---------------------------------------------------------------------------
+
+func TestCompileExportPattern(t *testing.T) {
+	if p, err := CompileExportPattern(""); p != nil || err != nil {
+		t.Errorf("empty pattern = %v, %v; want nil, nil", p, err)
+	}
+	if _, err := CompileExportPattern(`^map_(\w+)\.json$`); err == nil {
+		t.Error("expected error for pattern without an id group")
+	}
+	if _, err := CompileExportPattern(`^map_(?P<id>\w+\.json$`); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+}
+
+func TestExportPattern_VacuumID(t *testing.T) {
+	p, err := CompileExportPattern(`^(?P<id>[a-z0-9-]+)_map_\d+\.json$`)
+	if err != nil {
+		t.Fatalf("CompileExportPattern: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"/data/rocky-2_map_1704067200.json", "rocky-2", true},
+		{"ValetudoMapExport-dusty.json", "dusty", true}, // files tudomesh writes still match
+		{"rocky_notes.json", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := p.VacuumID(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("VacuumID(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExportPattern_Find(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"ValetudoMapExport-a.json",
+		"ValetudoMapExport-b-2024-01-01.json.gz",
+		"c_map_1.json",
+		".ValetudoMapExport-a.json.tmp-1",
+		"composite-map.png",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	var valetudo *ExportPattern
+	files, err := valetudo.Find(dir)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "ValetudoMapExport-a.json"),
+		filepath.Join(dir, "ValetudoMapExport-b-2024-01-01.json.gz"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("default Find = %v, want %v", files, want)
+	}
+
+	custom, err := CompileExportPattern(`^(?P<id>\w+)_map_\d+\.json$`)
+	if err != nil {
+		t.Fatalf("CompileExportPattern: %v", err)
+	}
+	files, err = custom.Find(dir)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("custom Find = %v, want 3 files", files)
+	}
+}
@@ -0,0 +1,193 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// FeatureWeights sets how much each feature class contributes to the points
+// ICP fits and scores an alignment by. A class's share of the sample is its
+// weight over the sum of the weights; a class with fewer points than its
+// share hands the rest to the others. 0 leaves a class out. Charger is the
+// number of times the dock is included, as it is a single point.
+type FeatureWeights struct {
+	Walls     float64
+	Corners   float64
+	Boundary  float64
+	Grid      float64
+	Charger   float64
+	Materials float64 // borders between floor materials, e.g. carpet on tile
+	Segments  float64 // borders between rooms
+}
+
+// DefaultFeatureWeights approximates the built-in mix of SampleFeatures:
+// walls, grid and boundary points a third each, with up to a sixth of
+// corners in between, and the dock once.
+func DefaultFeatureWeights() FeatureWeights {
+	return FeatureWeights{Walls: 1, Corners: 0.5, Boundary: 1, Grid: 1, Charger: 1}
+}
+
+// semantic reports whether the weights use the material or room borders,
+// which are only extracted then.
+func (w FeatureWeights) semantic() bool {
+	return w.Materials > 0 || w.Segments > 0
+}
+
+// FeatureWeightsConfig is the icp.weights section of the config. Unset
+// weights keep their DefaultFeatureWeights value; without any the built-in
+// mix of SampleFeatures is used.
+type FeatureWeightsConfig struct {
+	Walls     *float64 `yaml:"walls,omitempty" json:"walls,omitempty"`
+	Corners   *float64 `yaml:"corners,omitempty" json:"corners,omitempty"`
+	Boundary  *float64 `yaml:"boundary,omitempty" json:"boundary,omitempty"`
+	Grid      *float64 `yaml:"grid,omitempty" json:"grid,omitempty"`
+	Charger   *float64 `yaml:"charger,omitempty" json:"charger,omitempty"`
+	Materials *float64 `yaml:"materials,omitempty" json:"materials,omitempty"`
+	Segments  *float64 `yaml:"segments,omitempty" json:"segments,omitempty"`
+}
+
+// Weights returns the configured weights over DefaultFeatureWeights, or nil
+// when none is set.
+func (c FeatureWeightsConfig) Weights() *FeatureWeights {
+	w := DefaultFeatureWeights()
+	set := false
+	for _, f := range []struct {
+		value *float64
+		dst   *float64
+	}{
+		{c.Walls, &w.Walls}, {c.Corners, &w.Corners}, {c.Boundary, &w.Boundary}, {c.Grid, &w.Grid},
+		{c.Charger, &w.Charger}, {c.Materials, &w.Materials}, {c.Segments, &w.Segments},
+	} {
+		if f.value != nil {
+			*f.dst = *f.value
+			set = true
+		}
+	}
+	if !set {
+		return nil
+	}
+	return &w
+}
+
+// Validate checks that the weights are finite and not negative, and that
+// some class besides the dock is left to align by.
+func (c FeatureWeightsConfig) Validate() error {
+	w := c.Weights()
+	if w == nil {
+		return nil
+	}
+	for _, v := range []float64{w.Walls, w.Corners, w.Boundary, w.Grid, w.Charger, w.Materials, w.Segments} {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("must be finite and not negative")
+		}
+	}
+	if w.Walls+w.Corners+w.Boundary+w.Grid+w.Materials+w.Segments == 0 {
+		return fmt.Errorf("need a feature class other than charger")
+	}
+	return nil
+}
+
+// SampleFeaturesWeighted reduces fs to at most maxPoints points for ICP,
+// shared among the feature classes by weights (see FeatureWeights). Each
+// class is thinned evenly along its points. Material and room borders are
+// only present in feature sets extracted for weights that use them.
+func SampleFeaturesWeighted(fs FeatureSet, maxPoints int, weights FeatureWeights) []Point {
+	var result []Point
+	if fs.HasCharger {
+		for i := 0; i < int(math.Round(weights.Charger)) && len(result) < maxPoints; i++ {
+			result = append(result, fs.ChargerPosition)
+		}
+	}
+
+	classes := []struct {
+		points []Point
+		weight float64
+	}{
+		{fs.WallPoints, weights.Walls},
+		{fs.GridPoints, weights.Grid},
+		{fs.Corners, weights.Corners},
+		{fs.MaterialBorderPoints, weights.Materials},
+		{fs.SegmentBorderPoints, weights.Segments},
+		{fs.BoundaryPoints, weights.Boundary},
+	}
+
+	// Classes short of their share are filled first, leaving what they do
+	// not use to the others
+	var open []int
+	totalWeight := 0.0
+	for i, c := range classes {
+		if c.weight > 0 && len(c.points) > 0 {
+			open = append(open, i)
+			totalWeight += c.weight
+		}
+	}
+	sort.SliceStable(open, func(a, b int) bool {
+		ca, cb := classes[open[a]], classes[open[b]]
+		return float64(len(ca.points))/ca.weight < float64(len(cb.points))/cb.weight
+	})
+	counts := make([]int, len(classes))
+	budget := maxPoints - len(result)
+	for k, i := range open {
+		share := budget
+		if k < len(open)-1 {
+			share = int(float64(budget) * classes[i].weight / totalWeight)
+		}
+		counts[i] = min(len(classes[i].points), max(share, 0))
+		budget -= counts[i]
+		totalWeight -= classes[i].weight
+	}
+
+	for i, c := range classes {
+		for j := 0; j < counts[i]; j++ {
+			result = append(result, c.points[j*len(c.points)/counts[i]])
+		}
+	}
+	return result
+}
+
+// extractBorders returns the segment pixels of m next to a pixel of another
+// segment: where rooms meet, and the subset of those where the floor
+// material changes. Segments of unknown material have no material borders.
+func extractBorders(m *ValetudoMap) (segments, materials []Point) {
+	owner := make(map[[2]int]int)
+	for i, layer := range m.Layers {
+		if layer.Type != "segment" {
+			continue
+		}
+		for j := 0; j+1 < len(layer.Pixels); j += 2 {
+			owner[[2]int{layer.Pixels[j], layer.Pixels[j+1]}] = i
+		}
+	}
+
+	neighbors := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	for i, layer := range m.Layers {
+		if layer.Type != "segment" {
+			continue
+		}
+		material := layer.MetaData.Material
+		for j := 0; j+1 < len(layer.Pixels); j += 2 {
+			x, y := layer.Pixels[j], layer.Pixels[j+1]
+			room, floor := false, false
+			for _, n := range neighbors {
+				other, ok := owner[[2]int{x + n[0], y + n[1]}]
+				if !ok || other == i {
+					continue
+				}
+				room = true
+				otherMaterial := m.Layers[other].MetaData.Material
+				if hasMaterial(material) && hasMaterial(otherMaterial) && material != otherMaterial {
+					floor = true
+				}
+			}
+			p := Point{X: float64(x), Y: float64(y)}
+			if room {
+				segments = append(segments, p)
+			}
+			if floor {
+				materials = append(materials, p)
+			}
+		}
+	}
+	return segments, materials
+}
@@ -0,0 +1,123 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// classPoints returns n points on row y, so each class is told apart by Y.
+func classPoints(n int, y float64) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{X: float64(i), Y: y}
+	}
+	return points
+}
+
+func TestSampleFeaturesWeighted_Shares(t *testing.T) {
+	fs := FeatureSet{
+		WallPoints:      classPoints(1000, 1),
+		Corners:         classPoints(1000, 2),
+		BoundaryPoints:  classPoints(1000, 3),
+		GridPoints:      classPoints(10, 4),
+		ChargerPosition: Point{X: -1, Y: 5},
+		HasCharger:      true,
+	}
+	weights := FeatureWeights{Walls: 1, Corners: 2, Boundary: 1, Grid: 1, Charger: 3}
+
+	counts := make(map[float64]int)
+	points := SampleFeaturesWeighted(fs, 303, weights)
+	for _, p := range points {
+		counts[p.Y]++
+	}
+	if len(points) != 303 {
+		t.Errorf("sampled %d points, want 303", len(points))
+	}
+	// The dock three times; the grid has fewer points than its share, so
+	// the 290 left are shared 1:2:1 among the others
+	want := map[float64]int{1: 72, 2: 145, 3: 73, 4: 10, 5: 3}
+	for y, n := range want {
+		if counts[y] != n {
+			t.Errorf("class %v: %d points, want %d (all: %v)", y, counts[y], n, counts)
+		}
+	}
+
+	weights.Corners, weights.Charger = 0, 0
+	for _, p := range SampleFeaturesWeighted(fs, 300, weights) {
+		if p.Y == 2 || p.Y == 5 {
+			t.Fatalf("sampled %v from a class weighted 0", p)
+		}
+	}
+}
+
+func TestFeatureWeightsConfig(t *testing.T) {
+	if (FeatureWeightsConfig{}).Weights() != nil {
+		t.Error("weights without any set, want nil for the built-in mix")
+	}
+	corners := 4.0
+	w := FeatureWeightsConfig{Corners: &corners}.Weights()
+	want := DefaultFeatureWeights()
+	want.Corners = 4
+	if w == nil || *w != want {
+		t.Errorf("weights = %+v, want %+v", w, want)
+	}
+
+	zero, negative := 0.0, -1.0
+	for _, c := range []FeatureWeightsConfig{
+		{Walls: &negative},
+		{Walls: &zero, Corners: &zero, Boundary: &zero, Grid: &zero},
+	} {
+		if c.Validate() == nil {
+			t.Errorf("%+v validated", c.Weights())
+		}
+	}
+}
+
+func TestExtractBorders(t *testing.T) {
+	// Two rooms side by side, x 0-9 carpet and x 10-19 tile, and a third
+	// of unknown material below the tiles
+	layer := func(id, material string, x0, x1, y0, y1 int) MapLayer {
+		var pixels []int
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				pixels = append(pixels, x, y)
+			}
+		}
+		return MapLayer{Type: "segment", Pixels: pixels, MetaData: LayerMetaData{SegmentID: id, Material: material}}
+	}
+	m := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{
+		layer("1", MaterialCarpet, 0, 10, 0, 10),
+		layer("2", MaterialTile, 10, 20, 0, 10),
+		layer("3", MaterialGeneric, 10, 20, 10, 20),
+	}}
+
+	segments, materials := extractBorders(m)
+	// 10 on each side of the vertical border and 10 on each side of the
+	// horizontal one, with the tile corner pixel on both
+	if len(segments) != 39 {
+		t.Errorf("%d room border pixels, want 39", len(segments))
+	}
+	if len(materials) != 20 {
+		t.Errorf("%d material border pixels, want 20 along x 9-10", len(materials))
+	}
+	for _, p := range materials {
+		if p.X != 9 && p.X != 10 || p.Y >= 10 {
+			t.Errorf("material border at %v", p)
+		}
+	}
+}
+
+func TestAlignMaps_WeightedFeatures(t *testing.T) {
+	config := DefaultICPConfig()
+	weights := DefaultFeatureWeights()
+	weights.Corners = 4
+	config.Weights = &weights
+
+	result := AlignMaps(rotatedRoom(90), rotatedRoom(0), config)
+	if !ValidateAlignment(result.Transform) || result.Score < 0.9 {
+		t.Fatalf("score %.2f, transform %+v", result.Score, result.Transform)
+	}
+	if rot := TransformRotation(result.Transform); math.Abs(signedDegrees(rot-270)) > 1 {
+		t.Errorf("rotation %.1f°, want 270°", rot)
+	}
+}
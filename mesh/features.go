@@ -14,6 +14,10 @@ type FeatureSet struct {
 	// Corner points (significant angle changes in boundary)
 	Corners []Point
 
+	// Corners where walls meet, at subpixel precision (see
+	// ExtractWallCorners); matched corner to corner in initial alignment
+	WallCorners []Point
+
 	// Wall points (strong structural features)
 	WallPoints []Point
 
@@ -24,6 +28,11 @@ type FeatureSet struct {
 	ChargerPosition Point
 	HasCharger      bool
 
+	// Segment pixels where rooms meet, and where the floor material
+	// changes; only extracted for FeatureWeights that use them
+	SegmentBorderPoints  []Point
+	MaterialBorderPoints []Point
+
 	// Centroid of all floor area
 	Centroid Point
 
@@ -91,6 +100,8 @@ func ExtractFeatures(m *ValetudoMap) FeatureSet {
 	// Extract corners from boundary
 	fs.Corners = extractCorners(fs.BoundaryPoints, 60.0) // 60 degree threshold
 
+	fs.WallCorners = ExtractWallCorners(m)
+
 	// Extract grid-sampled floor points for robust rotation matching
 	fs.GridPoints = extractGridPoints(allPixels, m.PixelSize, 50) // 50 pixel grid
 
@@ -245,6 +256,7 @@ func extractCorners(boundary []Point, angleThresholdDeg float64) []Point {
 
 // SampleFeatures reduces the number of features for faster ICP matching
 // Prioritizes: charger, walls, grid points, corners, then boundary points
+// (see SampleFeaturesWeighted for a configurable mix)
 func SampleFeatures(fs FeatureSet, maxPoints int) []Point {
 	var result []Point
 
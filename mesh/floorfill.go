@@ -0,0 +1,110 @@
+package mesh
+
+import "math"
+
+// floorMask marks a map's floor and segment cells in a bitmap over their
+// bounding box, in the map's own grid.
+type floorMask struct {
+	minX, minY    int
+	width, height int
+	cells         []bool
+}
+
+// newFloorMask returns the mask of m's floor and segment layers, or nil when
+// it has none. Each pixel of a downsampled map marks its whole block.
+func newFloorMask(m *ValetudoMap) *floorMask {
+	block := m.CellBlock()
+	var f *floorMask
+	for _, layer := range m.Layers {
+		if layer.Type != "floor" && layer.Type != "segment" {
+			continue
+		}
+		minX, minY, maxX, maxY, ok := pixelBounds(layer.Pixels)
+		if !ok {
+			continue
+		}
+		maxX, maxY = maxX+block-1, maxY+block-1
+		if f == nil {
+			f = &floorMask{minX: minX, minY: minY, width: maxX - minX + 1, height: maxY - minY + 1}
+			continue
+		}
+		maxX, maxY = max(maxX, f.minX+f.width-1), max(maxY, f.minY+f.height-1)
+		f.minX, f.minY = min(f.minX, minX), min(f.minY, minY)
+		f.width, f.height = maxX-f.minX+1, maxY-f.minY+1
+	}
+	if f == nil {
+		return nil
+	}
+	f.cells = make([]bool, f.width*f.height)
+	for _, layer := range m.Layers {
+		if layer.Type != "floor" && layer.Type != "segment" {
+			continue
+		}
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			x, y := layer.Pixels[i]-f.minX, layer.Pixels[i+1]-f.minY
+			for dy := 0; dy < block; dy++ {
+				for dx := 0; dx < block; dx++ {
+					f.cells[(y+dy)*f.width+x+dx] = true
+				}
+			}
+		}
+	}
+	return f
+}
+
+// has reports whether the cell at (x, y) is floor.
+func (f *floorMask) has(x, y int) bool {
+	x, y = x-f.minX, y-f.minY
+	return x >= 0 && x < f.width && y >= 0 && y < f.height && f.cells[y*f.width+x]
+}
+
+// fillFloors calls fn once for every pixel of a width x height image whose
+// center, taken back through toWorld and transform, lies in one of m's floor
+// or segment cells. Walking the image instead of the cells draws a floor as
+// one solid area at any scale or rotation, and at scales below 1 touches
+// each pixel once rather than once per cell. It returns false, drawing
+// nothing, when transform cannot be inverted.
+func (r *CompositeRenderer) fillFloors(m *ValetudoMap, transform AffineMatrix, toWorld func(x, y float64) Point, width, height int, fn func(x, y int)) bool {
+	inverse, ok := transform.Inverse()
+	if !ok {
+		return false
+	}
+	mask := newFloorMask(m)
+	if mask == nil {
+		return true
+	}
+
+	// toWorld is affine; with inverse it takes image pixels to map cells
+	o, ex, ey := toWorld(0, 0), toWorld(1, 0), toWorld(0, 1)
+	toCell := MultiplyMatrices(inverse, AffineMatrix{
+		A: ex.X - o.X, B: ey.X - o.X, Tx: o.X,
+		C: ex.Y - o.Y, D: ey.Y - o.Y, Ty: o.Y,
+	})
+	toImage, ok := toCell.Inverse()
+	if !ok {
+		return false
+	}
+
+	// Only the image pixels over the mask's bounding box
+	x0, y0 := math.Inf(1), math.Inf(1)
+	x1, y1 := math.Inf(-1), math.Inf(-1)
+	cx0, cy0 := float64(mask.minX), float64(mask.minY)
+	cx1, cy1 := cx0+float64(mask.width), cy0+float64(mask.height)
+	for _, c := range [4]Point{{cx0, cy0}, {cx1, cy0}, {cx0, cy1}, {cx1, cy1}} {
+		p := TransformPoint(c, toImage)
+		x0, y0 = math.Min(x0, p.X), math.Min(y0, p.Y)
+		x1, y1 = math.Max(x1, p.X), math.Max(y1, p.Y)
+	}
+	ix0, iy0 := max(int(math.Floor(x0)), 0), max(int(math.Floor(y0)), 0)
+	ix1, iy1 := min(int(math.Ceil(x1)), width-1), min(int(math.Ceil(y1)), height-1)
+
+	for iy := iy0; iy <= iy1; iy++ {
+		for ix := ix0; ix <= ix1; ix++ {
+			c := TransformPoint(Point{X: float64(ix) + 0.5, Y: float64(iy) + 0.5}, toCell)
+			if mask.has(int(math.Floor(c.X)), int(math.Floor(c.Y))) {
+				fn(ix, iy)
+			}
+		}
+	}
+	return true
+}
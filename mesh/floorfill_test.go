@@ -0,0 +1,148 @@
+package mesh
+
+import (
+	"image/color"
+	"testing"
+)
+
+// courtyardFloor is a 60x40 floor with a 10x10 hole and a wall along its
+// top edge.
+func courtyardFloor() *ValetudoMap {
+	var floor, wall []int
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			if x >= 20 && x < 30 && y >= 15 && y < 25 {
+				continue
+			}
+			floor = append(floor, x, y)
+		}
+	}
+	for x := 0; x < 60; x++ {
+		wall = append(wall, x, -1)
+	}
+	return createMockMap(wall, floor)
+}
+
+func TestRender_FillFloorsMatchesCells(t *testing.T) {
+	maps := map[string]*ValetudoMap{"vac1": courtyardFloor()}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	for _, rot := range []float64{0, 37, -12.5} {
+		render := func(fill bool, greyscale bool) []uint8 {
+			renderer := NewCompositeRenderer(maps, transforms, "vac1")
+			renderer.Padding = 5
+			renderer.GlobalRotation = rot
+			renderer.Legend.Hidden = true
+			renderer.FillFloors = fill
+			if greyscale {
+				return renderer.RenderGreyscale().Pix
+			}
+			return renderer.Render().Pix
+		}
+		for _, greyscale := range []bool{false, true} {
+			cells, filled := render(false, greyscale), render(true, greyscale)
+			if len(cells) != len(filled) {
+				t.Fatalf("rotation %v: image sizes differ", rot)
+			}
+			differ := 0
+			for i := range cells {
+				if cells[i] != filled[i] {
+					differ++
+				}
+			}
+			if differ > 0 {
+				t.Errorf("rotation %v, greyscale %v: %d bytes differ from drawing cell by cell", rot, greyscale, differ)
+			}
+		}
+	}
+}
+
+func TestRender_FillFloorsIsSolid(t *testing.T) {
+	// A solid floor under a rotated map transform, shrunk below 1:1
+	var floor []int
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			floor = append(floor, x, y)
+		}
+	}
+	maps := map[string]*ValetudoMap{"vac1": createMockMap(nil, floor)}
+	transforms := map[string]AffineMatrix{"vac1": CreateRotationTranslation(23, 10, 10)}
+
+	for _, scale := range []float64{1, 0.5} {
+		renderer := NewCompositeRenderer(maps, transforms, "vac1")
+		renderer.Scale = scale
+		renderer.Legend.Hidden = true
+		renderer.FillFloors = true
+
+		img := renderer.Render()
+		background := color.RGBA{240, 240, 240, 255}
+		floorColor := img.RGBAAt(img.Bounds().Dx()/2, img.Bounds().Dy()/2)
+		if floorColor == background {
+			t.Fatalf("scale %v: no floor at the image center", scale)
+		}
+
+		// Everything within a third of the side of the center is floor,
+		// blended once
+		b := img.Bounds()
+		cx, cy, r := b.Dx()/2, b.Dy()/2, int(200*scale/3)
+		for y := cy - r; y <= cy+r; y++ {
+			for x := cx - r; x <= cx+r; x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r && img.RGBAAt(x, y) != floorColor {
+					t.Fatalf("scale %v: pixel (%d, %d) = %v, want floor %v", scale, x, y, img.RGBAAt(x, y), floorColor)
+				}
+			}
+		}
+	}
+}
+
+func TestFloorMask(t *testing.T) {
+	m := &ValetudoMap{Layers: []MapLayer{
+		{Type: "floor", Pixels: []int{5, 5, 6, 5}},
+		{Type: "segment", Pixels: []int{2, 8}},
+		{Type: "wall", Pixels: []int{0, 0}},
+	}}
+	mask := newFloorMask(m)
+	if mask == nil {
+		t.Fatal("newFloorMask returned nil")
+	}
+	for _, c := range [][2]int{{5, 5}, {6, 5}, {2, 8}} {
+		if !mask.has(c[0], c[1]) {
+			t.Errorf("cell %v not in the mask", c)
+		}
+	}
+	for _, c := range [][2]int{{0, 0}, {3, 5}, {7, 5}, {2, 9}, {-100, 100}} {
+		if mask.has(c[0], c[1]) {
+			t.Errorf("cell %v in the mask", c)
+		}
+	}
+	if newFloorMask(&ValetudoMap{Layers: []MapLayer{{Type: "wall", Pixels: []int{1, 1}}}}) != nil {
+		t.Error("a map without floors has a mask")
+	}
+}
+
+func BenchmarkRender_Floors(b *testing.B) {
+	var floor []int
+	for y := 0; y < 800; y++ {
+		for x := 0; x < 800; x++ {
+			floor = append(floor, x, y)
+		}
+	}
+	maps := map[string]*ValetudoMap{"vac1": createMockMap(nil, floor)}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	for _, bm := range []struct {
+		name string
+		fill bool
+	}{{"cells", false}, {"fill", true}} {
+		b.Run(bm.name, func(b *testing.B) {
+			renderer := NewCompositeRenderer(maps, transforms, "vac1")
+			renderer.Legend.Hidden = true
+			renderer.MaxDimension = 400
+			renderer.FillFloors = bm.fill
+			for b.Loop() {
+				renderer.Scale = 1
+				renderer.Render()
+			}
+		})
+	}
+}
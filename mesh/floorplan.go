@@ -0,0 +1,290 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // floorplans are often exported as JPEG
+	_ "image/png"
+	"log"
+	"math"
+	"os"
+	"sync"
+)
+
+// DefaultFloorplanOpacity is the floorplan opacity when none is configured.
+const DefaultFloorplanOpacity = 0.5
+
+// Wall extraction and snapping parameters.
+const (
+	floorplanWallLuminance = 96    // pixels darker than this (0-255) are wall ink
+	floorplanSnapDistance  = 500.0 // mm; wall pixels further than this from the plan are not paired
+	floorplanSnapSamples   = 400   // robot wall points used for snapping
+	floorplanTargetSamples = 4000  // plan wall points used for snapping
+)
+
+// Floorplan is an architectural drawing placed in the reference map's world
+// coordinates. Image pixel (px, py) lands at Offset + R(Rotation) * (px, py)
+// * MMPerPixel, so Offset is where the image's top-left corner sits.
+type Floorplan struct {
+	Image      image.Image
+	MMPerPixel float64
+	Offset     Point   // mm
+	Rotation   float64 // degrees about Offset, same sense as vacuum rotations
+	Opacity    float64 // 0.0-1.0
+	Align      bool    // snap robot maps to the drawing's walls
+
+	mu       sync.Mutex
+	walls    []Point      // wall edge pixels in mm, extracted on first use
+	snapRef  *ValetudoMap // reference map the cached snap was computed for
+	snapFrom AffineMatrix // reference transform the cached snap was computed for
+	snap     AffineMatrix
+}
+
+// LoadFloorplan loads the floorplan configured in config. It returns nil
+// without an error when no floorplan is configured.
+func LoadFloorplan(config *Config) (*Floorplan, error) {
+	if config == nil || config.Floorplan.Image == "" {
+		return nil, nil
+	}
+	fc := config.Floorplan
+
+	f, err := os.Open(fc.Image)
+	if err != nil {
+		return nil, fmt.Errorf("opening floorplan: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding floorplan %s: %w", fc.Image, err)
+	}
+
+	opacity := DefaultFloorplanOpacity
+	if fc.Opacity != nil {
+		opacity = *fc.Opacity
+	}
+	return &Floorplan{
+		Image:      img,
+		MMPerPixel: fc.MMPerPixel,
+		Offset:     Point{X: fc.OffsetX, Y: fc.OffsetY},
+		Rotation:   fc.Rotation,
+		Opacity:    opacity,
+		Align:      fc.Align,
+	}, nil
+}
+
+// toImage maps a world position (mm) to floorplan image coordinates.
+func (f *Floorplan) toImage(p Point) Point {
+	rad := -f.Rotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	x, y := p.X-f.Offset.X, p.Y-f.Offset.Y
+	return Point{
+		X: (x*cos - y*sin) / f.MMPerPixel,
+		Y: (x*sin + y*cos) / f.MMPerPixel,
+	}
+}
+
+// toWorld maps floorplan image coordinates to a world position (mm).
+func (f *Floorplan) toWorld(p Point) Point {
+	rad := f.Rotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	x, y := p.X*f.MMPerPixel, p.Y*f.MMPerPixel
+	return Point{
+		X: x*cos - y*sin + f.Offset.X,
+		Y: x*sin + y*cos + f.Offset.Y,
+	}
+}
+
+// colorAt returns the drawing's color at a world position (mm). The second
+// result is false outside the image.
+func (f *Floorplan) colorAt(p Point) (color.NRGBA, bool) {
+	ip := f.toImage(p)
+	b := f.Image.Bounds()
+	x, y := b.Min.X+int(math.Floor(ip.X)), b.Min.Y+int(math.Floor(ip.Y))
+	if x < b.Min.X || x >= b.Max.X || y < b.Min.Y || y >= b.Max.Y {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBAModel.Convert(f.Image.At(x, y)).(color.NRGBA), true
+}
+
+// isWallInk reports whether the image pixel at (x, y) is dark wall ink.
+func (f *Floorplan) isWallInk(x, y int) bool {
+	c := color.NRGBAModel.Convert(f.Image.At(x, y)).(color.NRGBA)
+	if c.A < 128 {
+		return false
+	}
+	lum := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+	return lum < floorplanWallLuminance
+}
+
+// WallPoints returns the edges of the drawing's walls in world coordinates
+// (mm): dark pixels with at least one light 4-neighbour. A filled wall thus
+// yields its two faces, which is what a robot's lidar sees.
+func (f *Floorplan) WallPoints() []Point {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.walls != nil {
+		return f.walls
+	}
+
+	b := f.Image.Bounds()
+	ink := make([]bool, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			ink[(y-b.Min.Y)*b.Dx()+(x-b.Min.X)] = f.isWallInk(x, y)
+		}
+	}
+	isInk := func(x, y int) bool {
+		if x < 0 || x >= b.Dx() || y < 0 || y >= b.Dy() {
+			return false
+		}
+		return ink[y*b.Dx()+x]
+	}
+
+	walls := make([]Point, 0)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !isInk(x, y) {
+				continue
+			}
+			if isInk(x-1, y) && isInk(x+1, y) && isInk(x, y-1) && isInk(x, y+1) {
+				continue
+			}
+			walls = append(walls, f.toWorld(Point{X: float64(x) + 0.5, Y: float64(y) + 0.5}))
+		}
+	}
+	f.walls = walls
+	return walls
+}
+
+// SnapTransforms returns transforms composed with the correction that
+// aligns the reference map's walls to the drawing's walls, so every map
+// moves with the reference. transforms is returned unchanged when f is nil,
+// alignment is off, or the walls cannot be matched. The correction is cached
+// until the reference map or its transform changes.
+func (f *Floorplan) SnapTransforms(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference string) map[string]AffineMatrix {
+	if f == nil || !f.Align {
+		return transforms
+	}
+	ref, ok := maps[reference]
+	if !ok {
+		return transforms
+	}
+	refTransform, ok := transforms[reference]
+	if !ok {
+		refTransform = Identity()
+	}
+
+	snap := f.cachedSnap(ref, refTransform, referencePixelSize(maps, reference))
+	out := make(map[string]AffineMatrix, len(transforms))
+	for id, t := range transforms {
+		out[id] = MultiplyMatrices(snap, t)
+	}
+	return out
+}
+
+// cachedSnap returns the snap correction for ref, computing it if ref or its
+// transform changed since the last call.
+func (f *Floorplan) cachedSnap(ref *ValetudoMap, refTransform AffineMatrix, pixelSize float64) AffineMatrix {
+	walls := f.WallPoints()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.snapRef == ref && f.snapFrom == refTransform {
+		return f.snap
+	}
+
+	snap, ok := snapToWalls(ref, refTransform, walls, pixelSize)
+	if ok {
+		log.Printf("Floorplan alignment: rotated %.2f°, moved (%.0f, %.0f) mm",
+			snap.Decompose().Rotation, snap.Tx*pixelSize, snap.Ty*pixelSize)
+	} else {
+		log.Printf("Warning: floorplan alignment found no match; using configured placement")
+	}
+	f.snapRef, f.snapFrom, f.snap = ref, refTransform, snap
+	return snap
+}
+
+// snapToWalls runs ICP from the reference map's transformed walls to the
+// plan's walls (mm) in grid units, starting from the configured placement.
+// It returns identity and false if there is too little data or the result
+// is not a rigid improvement.
+func snapToWalls(ref *ValetudoMap, refTransform AffineMatrix, planWalls []Point, pixelSize float64) (AffineMatrix, bool) {
+	var source []Point
+	for _, layer := range ref.Layers {
+		if layer.Type == "wall" {
+			source = append(source, TransformPoints(PixelsToPoints(layer.Pixels), refTransform)...)
+		}
+	}
+
+	// Plan walls are usually far denser than the robot's grid; keep one
+	// point per grid cell
+	cells := make(map[[2]int]bool)
+	var target []Point
+	for _, p := range planWalls {
+		cell := [2]int{int(math.Floor(p.X / pixelSize)), int(math.Floor(p.Y / pixelSize))}
+		if !cells[cell] {
+			cells[cell] = true
+			target = append(target, Point{X: float64(cell[0]), Y: float64(cell[1])})
+		}
+	}
+
+	source = strideSample(source, floorplanSnapSamples)
+	target = strideSample(target, floorplanTargetSamples)
+	if len(source) < 3 || len(target) < 3 {
+		return Identity(), false
+	}
+
+	config := DefaultICPConfig()
+	config.MaxIterations = 200
+	config.ConvergenceThresh = 0.001
+	config.MaxCorrespondDist = floorplanSnapDistance / pixelSize
+	config.TryRotations = false
+	config.Metric = MetricPointToPlane // plan walls are clean lines; don't stall sliding along them
+
+	initialError := FeatureDistance(source, target)
+	result := runICP(source, target, Identity(), config)
+	if result.Error >= initialError || !ValidateAlignment(result.Transform) {
+		return Identity(), false
+	}
+	return result.Transform, true
+}
+
+// strideSample returns at most n points taken at even intervals.
+func strideSample(points []Point, n int) []Point {
+	if len(points) <= n {
+		return points
+	}
+	out := make([]Point, 0, n)
+	step := float64(len(points)) / float64(n)
+	for i := 0; i < n; i++ {
+		out = append(out, points[int(float64(i)*step)])
+	}
+	return out
+}
+
+// drawFloorplan blends the floorplan into img beneath the map layers. Each
+// output pixel is mapped back to the world so the drawing follows any global
+// rotation.
+func (r *CompositeRenderer) drawFloorplan(img *image.RGBA, minX, minY, centerX, centerY float64) {
+	f := r.Floorplan
+	if f == nil || f.Image == nil || f.MMPerPixel <= 0 {
+		return
+	}
+	pixelSize := referencePixelSize(r.Maps, r.Reference)
+	toWorld := r.imageToWorld(minX, minY, centerX, centerY)
+	opacity := math.Max(0, math.Min(1, f.Opacity))
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			w := toWorld(float64(x)+0.5, float64(y)+0.5)
+			c, ok := f.colorAt(Point{X: w.X * pixelSize, Y: w.Y * pixelSize})
+			if !ok || c.A == 0 {
+				continue
+			}
+			img.Set(x, y, blendColors(img.RGBAAt(x, y), fade(c, opacity)))
+		}
+	}
+}
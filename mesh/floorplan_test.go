@@ -0,0 +1,195 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// planImage returns a white w x h drawing with the given pixels inked black.
+func planImage(w, h int, ink func(x, y int) bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{255, 255, 255, 255}
+			if ink(x, y) {
+				c = color.NRGBA{0, 0, 0, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// ---------------------------------------------------------------------------
+// Loading and placement
+// ---------------------------------------------------------------------------
+
+func TestLoadFloorplan(t *testing.T) {
+	if fp, err := LoadFloorplan(nil); fp != nil || err != nil {
+		t.Errorf("nil config = %v, %v; want nil, nil", fp, err)
+	}
+	if fp, err := LoadFloorplan(&Config{}); fp != nil || err != nil {
+		t.Errorf("no image = %v, %v; want nil, nil", fp, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, planImage(4, 3, func(x, y int) bool { return false })); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	fp, err := LoadFloorplan(&Config{Floorplan: FloorplanConfig{
+		Image: path, MMPerPixel: 20, OffsetX: -100, OffsetY: 50, Align: true,
+	}})
+	if err != nil {
+		t.Fatalf("LoadFloorplan: %v", err)
+	}
+	if fp.Image.Bounds().Dx() != 4 || fp.Image.Bounds().Dy() != 3 {
+		t.Errorf("image size = %v, want 4x3", fp.Image.Bounds())
+	}
+	if fp.Opacity != DefaultFloorplanOpacity || fp.MMPerPixel != 20 || fp.Offset != (Point{X: -100, Y: 50}) || !fp.Align {
+		t.Errorf("LoadFloorplan = %+v", fp)
+	}
+
+	if _, err := LoadFloorplan(&Config{Floorplan: FloorplanConfig{Image: filepath.Join(t.TempDir(), "missing.png"), MMPerPixel: 1}}); err == nil {
+		t.Error("missing image: expected error")
+	}
+}
+
+func TestFloorplan_PlacementRoundTrip(t *testing.T) {
+	fp := &Floorplan{MMPerPixel: 12.5, Offset: Point{X: 300, Y: -40}, Rotation: 30}
+	for _, p := range []Point{{X: 0, Y: 0}, {X: 1234, Y: 567}, {X: -80, Y: 2000}} {
+		got := fp.toWorld(fp.toImage(p))
+		if math.Abs(got.X-p.X) > 1e-9 || math.Abs(got.Y-p.Y) > 1e-9 {
+			t.Errorf("round trip %v = %v", p, got)
+		}
+	}
+
+	// Unrotated: the top-left corner is at Offset
+	fp.Rotation = 0
+	if got := fp.toImage(Point{X: 300 + 25, Y: -40 + 50}); got != (Point{X: 2, Y: 4}) {
+		t.Errorf("toImage = %v, want (2, 4)", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Wall extraction
+// ---------------------------------------------------------------------------
+
+func TestFloorplan_WallPointsAreEdges(t *testing.T) {
+	// A filled wall three pixels thick: only its faces are walls
+	fp := &Floorplan{
+		Image:      planImage(10, 10, func(x, y int) bool { return x >= 4 && x <= 6 }),
+		MMPerPixel: 10,
+	}
+	walls := fp.WallPoints()
+
+	columns := make(map[float64]int)
+	for _, p := range walls {
+		columns[p.X]++
+	}
+	if columns[45] != 10 || columns[65] != 10 {
+		t.Errorf("face columns = %v, want 10 points at x=45 and x=65", columns)
+	}
+	// The middle column only touches the image border at the ends
+	if columns[55] != 2 {
+		t.Errorf("interior column has %d points, want 2 (top and bottom)", columns[55])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Snapping
+// ---------------------------------------------------------------------------
+
+// roomWalls returns the perimeter of a square room with an internal stub
+// wall, as wall pixels offset by (dx, dy).
+func roomWalls(dx, dy int) func(x, y int) bool {
+	return func(x, y int) bool {
+		x, y = x-dx, y-dy
+		onEdge := (x == 20 || x == 80) && y >= 20 && y <= 80 ||
+			(y == 20 || y == 80) && x >= 20 && x <= 80
+		stub := x == 50 && y >= 20 && y <= 45
+		return onEdge || stub
+	}
+}
+
+func TestFloorplan_SnapTransforms(t *testing.T) {
+	var pixels []int
+	inWall := roomWalls(0, 0)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if inWall(x, y) {
+				pixels = append(pixels, x, y)
+			}
+		}
+	}
+	maps := map[string]*ValetudoMap{
+		"ref":   {PixelSize: 5, Layers: []MapLayer{{Type: "wall", Pixels: pixels}}},
+		"other": {PixelSize: 5},
+	}
+	transforms := map[string]AffineMatrix{"ref": Identity(), "other": Translation(3, 4)}
+
+	// The drawing is one pixel per map cell, with the room 8 cells right
+	fp := &Floorplan{Image: planImage(110, 110, roomWalls(8, 0)), MMPerPixel: 5}
+
+	if got := fp.SnapTransforms(maps, transforms, "ref"); got["ref"] != Identity() {
+		t.Errorf("align off: ref = %+v, want identity", got["ref"])
+	}
+
+	fp.Align = true
+	got := fp.SnapTransforms(maps, transforms, "ref")
+	if math.Abs(got["ref"].Tx-8) > 0.5 || math.Abs(got["ref"].Ty) > 0.5 {
+		t.Errorf("ref snapped by (%.2f, %.2f), want (8, 0)", got["ref"].Tx, got["ref"].Ty)
+	}
+	if math.Abs(got["other"].Tx-11) > 0.5 || math.Abs(got["other"].Ty-4) > 0.5 {
+		t.Errorf("other = (%.2f, %.2f), want it moved with the reference to (11, 4)", got["other"].Tx, got["other"].Ty)
+	}
+	if transforms["ref"] != Identity() {
+		t.Error("SnapTransforms modified its input")
+	}
+
+	var nilPlan *Floorplan
+	if got := nilPlan.SnapTransforms(maps, transforms, "ref"); got["other"] != transforms["other"] {
+		t.Error("nil floorplan changed transforms")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+func TestRender_FloorplanBeneathMaps(t *testing.T) {
+	// Red drawing covering the left half (250mm) of overlayRenderer's floor
+	red := image.NewNRGBA(image.Rect(0, 0, 25, 50))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []byte{255, 0, 0, 255})
+	}
+
+	plain := overlayRenderer().Render()
+	r := overlayRenderer()
+	r.Floorplan = &Floorplan{Image: red, MMPerPixel: 10, Opacity: 1}
+	img := r.Render()
+
+	left, right := img.RGBAAt(10, 50), img.RGBAAt(80, 50)
+	if left == plain.RGBAAt(10, 50) || left.R <= left.G {
+		t.Errorf("left half = %v, want red showing through the floor", left)
+	}
+	if right != plain.RGBAAt(80, 50) {
+		t.Errorf("right half = %v, want unchanged %v", right, plain.RGBAAt(80, 50))
+	}
+
+	r = overlayRenderer()
+	r.Floorplan = &Floorplan{Image: red, MMPerPixel: 10, Opacity: 0}
+	if got := r.Render().RGBAAt(10, 50); got != plain.RGBAAt(10, 50) {
+		t.Errorf("opacity 0 = %v, want unchanged", got)
+	}
+}
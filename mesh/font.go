@@ -0,0 +1,119 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Font sizes in pixels. DefaultFontSize matches the height of the built-in
+// 7x13 bitmap font, so layouts sized for it fit either font.
+const (
+	DefaultFontSize = 13.0
+	MaxFontSize     = 200.0
+)
+
+// Font is a scalable TrueType or OpenType typeface for the text of raster
+// renders: the legend, grid labels and scale bar. A nil *Font draws with
+// the built-in 7x13 bitmap font.
+type Font struct {
+	font *opentype.Font
+	size float64 // pixels at scale 1
+}
+
+// LoadFont loads the font configured in the font section, or returns nil
+// when there is none. A size without a path selects the embedded Go
+// Regular typeface.
+func LoadFont(config *Config) (*Font, error) {
+	if config == nil || (config.Font.Path == "" && config.Font.Size == 0) {
+		return nil, nil
+	}
+	data := goregular.TTF
+	if path := config.Font.Path; path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading font: %w", err)
+		}
+	}
+	size := config.Font.Size
+	if size == 0 {
+		size = DefaultFontSize
+	}
+	return ParseFont(data, size)
+}
+
+// ParseFont parses a TrueType or OpenType font drawn size pixels high.
+func ParseFont(data []byte, size float64) (*Font, error) {
+	if size <= 0 || size > MaxFontSize {
+		return nil, fmt.Errorf("font size %g must be positive and at most %g", size, MaxFontSize)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font: %w", err)
+	}
+	return &Font{font: f, size: size}, nil
+}
+
+// textScale returns how many times larger than the bitmap font text drawn
+// at scale is. Layouts sized for the bitmap font multiply by it.
+func (f *Font) textScale(scale int) float64 {
+	if f == nil {
+		return float64(scale)
+	}
+	return f.size / DefaultFontSize * float64(scale)
+}
+
+// face returns a face at scale times the font size. Faces cache glyphs and
+// are not safe for concurrent use, so every drawing call opens its own.
+func (f *Font) face(scale int) (font.Face, error) {
+	return opentype.NewFace(f.font, &opentype.FaceOptions{
+		Size:    f.size * float64(scale),
+		DPI:     72, // one point per pixel
+		Hinting: font.HintingNone,
+	})
+}
+
+// measure returns the advance width, ascent and descent in pixels of text
+// drawn at scale.
+func (f *Font) measure(text string, scale int) (width, ascent, descent int) {
+	var face font.Face = basicfont.Face7x13
+	if f != nil {
+		ttf, err := f.face(scale)
+		if err == nil {
+			defer func() { _ = ttf.Close() }()
+			face, scale = ttf, 1
+		}
+	}
+	metrics := face.Metrics()
+	return font.MeasureString(face, text).Ceil() * scale, metrics.Ascent.Ceil() * scale, metrics.Descent.Ceil() * scale
+}
+
+// drawString draws text at scale times the font size with the left end of
+// its baseline at (x, y). The bitmap font is enlarged by nearest-neighbour
+// scaling instead, keeping its glyphs crisp.
+func (f *Font) drawString(img *image.RGBA, x, y int, text string, c color.RGBA, scale int) {
+	if f == nil {
+		drawScaledText(img, x, y, text, c, scale)
+		return
+	}
+	face, err := f.face(scale)
+	if err != nil {
+		drawScaledText(img, x, y, text, c, scale)
+		return
+	}
+	defer func() { _ = face.Close() }()
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.I(x), Y: fixed.I(y)},
+	}
+	d.DrawString(text)
+}
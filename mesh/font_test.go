@@ -0,0 +1,113 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestLoadFont(t *testing.T) {
+	if f, err := LoadFont(&Config{}); f != nil || err != nil {
+		t.Errorf("unconfigured: got %v, %v; want the bitmap font", f, err)
+	}
+
+	f, err := LoadFont(&Config{Font: FontConfig{Size: 20}})
+	if err != nil || f == nil {
+		t.Fatalf("size only: got %v, %v; want the embedded font", f, err)
+	}
+	if f.size != 20 {
+		t.Errorf("size = %g, want 20", f.size)
+	}
+
+	path := filepath.Join(t.TempDir(), "font.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err = LoadFont(&Config{Font: FontConfig{Path: path}})
+	if err != nil || f == nil || f.size != DefaultFontSize {
+		t.Errorf("path only: got %v, %v; want the file at the default size", f, err)
+	}
+
+	if _, err := LoadFont(&Config{Font: FontConfig{Path: filepath.Join(t.TempDir(), "missing.ttf")}}); err == nil {
+		t.Error("missing font file loaded")
+	}
+}
+
+func TestParseFont_Invalid(t *testing.T) {
+	if _, err := ParseFont([]byte("not a font"), 13); err == nil {
+		t.Error("parsed garbage as a font")
+	}
+	if _, err := ParseFont(goregular.TTF, 0); err == nil {
+		t.Error("accepted a zero size")
+	}
+}
+
+func TestFont_MeasureScales(t *testing.T) {
+	small, err := ParseFont(goregular.TTF, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := ParseFont(goregular.TTF, 39)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w1, a1, _ := small.measure("Kitchen", 1)
+	w3, a3, _ := large.measure("Kitchen", 1)
+	if w3 < 2*w1 || a3 < 2*a1 {
+		t.Errorf("39px text is %dx%d, 13px is %dx%d; want about 3x", w3, a3, w1, a1)
+	}
+	if w, _, _ := small.measure("Kitchen", 3); w != w3 {
+		t.Errorf("13px at scale 3 is %d wide, want %d as at 39px", w, w3)
+	}
+
+	// The bitmap font is 7 pixels per glyph
+	if w, a, _ := (*Font)(nil).measure("ab", 2); w != 28 || a != 22 {
+		t.Errorf("bitmap font at scale 2 = %dx%d, want 28 wide, 22 ascent", w, a)
+	}
+}
+
+func TestFont_DrawStringAntialiased(t *testing.T) {
+	f, err := ParseFont(goregular.TTF, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 60, 40))
+	f.drawString(img, 2, 30, "O", color.RGBA{0, 0, 0, 255}, 1)
+
+	var solid, partial int
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			switch a := img.RGBAAt(x, y).A; {
+			case a == 255:
+				solid++
+			case a > 0:
+				partial++
+			}
+		}
+	}
+	if solid == 0 || partial == 0 {
+		t.Errorf("glyph has %d solid and %d partial pixels, want both", solid, partial)
+	}
+}
+
+func TestDrawLegendEntries_ScalableFont(t *testing.T) {
+	f, err := ParseFont(goregular.TTF, 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := blankImage(300, 200)
+	drawLegendEntries(img, []legendEntry{{label: "a", swatch: legendRed}}, LegendOptions{}, f)
+
+	// Twice the bitmap font's height doubles the 12px swatch
+	if img.RGBAAt(10, 14) != legendRed || img.RGBAAt(33, 37) != legendRed {
+		t.Error("swatch not scaled with the font")
+	}
+	if img.RGBAAt(34, 14) == legendRed {
+		t.Error("swatch wider than 24px")
+	}
+}
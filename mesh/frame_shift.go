@@ -0,0 +1,76 @@
+package mesh
+
+import (
+	"math"
+)
+
+// Frame shift detection
+const (
+	frameWallTolerance     = 3.0  // pixels within which a wall of one map meets a wall of the other
+	frameSameWallFraction  = 0.6  // share of the previous map's walls that must still meet to keep the frame
+	frameShiftMinTranslate = 5.0  // pixels a map must move before its frame counts as shifted
+	frameShiftMinRotation  = 2.0  // degrees a map must turn before its frame counts as shifted
+	frameShiftSamples      = 1000 // wall points compared per map
+)
+
+// FrameShift is how a vacuum's own coordinate frame moved between two of
+// its maps, as found by DetectFrameShift.
+type FrameShift struct {
+	Delta       AffineMatrix // new map's grid to the previous map's grid
+	Score       float64      // ICP inlier score of the alignment
+	WallFit     float64      // share of the previous walls the shifted new walls meet
+	Translation float64      // pixels the new map's walls move, at their centroid
+	Rotation    float64      // degrees, in (-180, 180]
+}
+
+// DetectFrameShift checks next, a new map of a vacuum, against prev, its
+// previous map. Maps of a robot that kept its localization share a frame:
+// most of prev's walls are still where next has walls, however much more of
+// the home next has mapped. When they are not, the robot lost its
+// localization or remapped, and next is aligned onto prev with ICP. The
+// shift is reported only when that alignment puts the walls back on top of
+// each other and moves the map noticeably, so a map that merely changed is
+// never mistaken for a moved frame.
+func DetectFrameShift(prev, next *ValetudoMap, config ICPConfig) (FrameShift, bool) {
+	if prev == nil || next == nil || prev.PixelSize != next.PixelSize {
+		return FrameShift{}, false
+	}
+	prevWalls := samplePointSlice(ExtractFeatures(prev).WallPoints, frameShiftSamples)
+	nextWalls := samplePointSlice(ExtractFeatures(next).WallPoints, frameShiftSamples)
+	if len(prevWalls) < 10 || len(nextWalls) < 10 {
+		return FrameShift{}, false
+	}
+
+	same := wallFit(prevWalls, nextWalls, Identity())
+	if same >= frameSameWallFraction {
+		return FrameShift{}, false
+	}
+
+	result := AlignMaps(next, prev, config)
+	if result.Score < preAlignMinScore || !ValidateAlignment(result.Transform) {
+		return FrameShift{}, false
+	}
+	center := Centroid(nextWalls)
+	moved := TransformPoint(center, result.Transform)
+	shift := FrameShift{
+		Delta:       result.Transform,
+		Score:       result.Score,
+		WallFit:     wallFit(prevWalls, nextWalls, result.Transform),
+		Translation: math.Hypot(moved.X-center.X, moved.Y-center.Y),
+		Rotation:    signedDegrees(TransformRotation(result.Transform)),
+	}
+	if shift.WallFit < frameSameWallFraction || shift.WallFit <= same {
+		return FrameShift{}, false
+	}
+	if shift.Translation < frameShiftMinTranslate && math.Abs(shift.Rotation) < frameShiftMinRotation {
+		return FrameShift{}, false
+	}
+	return shift, true
+}
+
+// wallFit returns the share of prev walls that a wall of next, moved by
+// transform, meets.
+func wallFit(prevWalls, nextWalls []Point, transform AffineMatrix) float64 {
+	_, frac, _ := CalculateInlierScore(prevWalls, TransformPoints(nextWalls, transform), frameWallTolerance)
+	return frac
+}
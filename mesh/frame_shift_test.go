@@ -0,0 +1,71 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// shiftedMap returns a copy of m with every pixel moved by (dx, dy).
+func shiftedMap(m *ValetudoMap, dx, dy int) *ValetudoMap {
+	out := &ValetudoMap{PixelSize: m.PixelSize}
+	for _, layer := range m.Layers {
+		moved := MapLayer{Type: layer.Type, Pixels: make([]int, len(layer.Pixels))}
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			moved.Pixels[i] = layer.Pixels[i] + dx
+			moved.Pixels[i+1] = layer.Pixels[i+1] + dy
+		}
+		out.Layers = append(out.Layers, moved)
+	}
+	return out
+}
+
+func TestDetectFrameShift_SameFrame(t *testing.T) {
+	prev := rotatedRoom(0)
+	if _, shifted := DetectFrameShift(prev, rotatedRoom(0), DefaultICPConfig()); shifted {
+		t.Error("identical maps reported as shifted")
+	}
+
+	// A map that grew keeps the walls it had
+	partial := cropMap(prev, func(x, _ int) bool { return x < 450 }, 300, 400, 0)
+	if _, shifted := DetectFrameShift(partial, prev, DefaultICPConfig()); shifted {
+		t.Error("grown map reported as shifted")
+	}
+}
+
+func TestDetectFrameShift_Translated(t *testing.T) {
+	prev := rotatedRoom(0)
+	shift, shifted := DetectFrameShift(prev, shiftedMap(prev, 40, -25), DefaultICPConfig())
+	if !shifted {
+		t.Fatal("moved frame not detected")
+	}
+	// The delta takes the new map back onto the previous one
+	p := TransformPoint(Point{X: 440, Y: 375}, shift.Delta)
+	if math.Hypot(p.X-400, p.Y-400) > 3 {
+		t.Errorf("delta maps (440, 375) to (%.1f, %.1f), want (400, 400)", p.X, p.Y)
+	}
+	if math.Abs(shift.Rotation) > 1 {
+		t.Errorf("rotation = %.1f, want 0", shift.Rotation)
+	}
+}
+
+func TestDetectFrameShift_Rotated(t *testing.T) {
+	prev := rotatedRoom(0)
+	shift, shifted := DetectFrameShift(prev, rotatedRoom(90), DefaultICPConfig())
+	if !shifted {
+		t.Fatal("turned frame not detected")
+	}
+	if angleDiff(shift.Rotation, -90) > 2 {
+		t.Errorf("rotation = %.1f, want -90", shift.Rotation)
+	}
+}
+
+func TestDetectFrameShift_Unrelated(t *testing.T) {
+	other := rotatedRoom(0)
+	other.PixelSize = 10
+	if _, shifted := DetectFrameShift(rotatedRoom(0), other, DefaultICPConfig()); shifted {
+		t.Error("maps of different pixel sizes reported as shifted")
+	}
+	if _, shifted := DetectFrameShift(nil, rotatedRoom(0), DefaultICPConfig()); shifted {
+		t.Error("missing previous map reported as shifted")
+	}
+}
@@ -0,0 +1,273 @@
+package mesh
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// MinFrontierLength is the shortest opening, in mm, reported as a frontier.
+// Shorter gaps are usually a missed wall pixel, not unexplored floor.
+const MinFrontierLength = 300.0
+
+// FrontierColor draws frontiers on the composite SVG.
+var FrontierColor = color.NRGBA{255, 140, 0, 255}
+
+// Frontier detection tolerances, in mm.
+const (
+	frontierSampleSpacing = 50.0
+	frontierWallDistance  = 150.0 // a floor edge this close to a wall is closed
+	frontierProbeDistance = 100.0 // how far past the edge to look for more floor
+)
+
+// Frontier is an edge of the mapped floor that no wall closes off, where the
+// floor runs on into space no vacuum has explored. Coordinates are world mm.
+type Frontier struct {
+	VacuumID string  `json:"vacuumId,omitempty"` // empty for the unified map
+	Path     []Point `json:"path"`
+	Length   float64 `json:"length"`   // mm
+	Midpoint Point   `json:"midpoint"` // halfway along the path, a goal for exploring
+}
+
+// FrontierReport lists the frontiers of the unified map and of each vacuum.
+type FrontierReport struct {
+	Unified []Frontier            `json:"unified"`
+	Vacuums map[string][]Frontier `json:"vacuums"`
+}
+
+// VacuumFrontiers returns the frontiers of one vacuum's map, transformed
+// into world coordinates.
+func VacuumFrontiers(m *ValetudoMap, vacuumID string, transform AffineMatrix) []Frontier {
+	var floors, walls [][]Point
+	for _, f := range MapToFeatureCollection(m, vacuumID, transform, 5.0).Features {
+		switch f.Properties["layerType"] {
+		case "floor", "segment":
+			floors = append(floors, outerRings(f.Geometry)...)
+		case "wall":
+			walls = append(walls, geometryPaths(f.Geometry)...)
+		}
+	}
+	frontiers := findFrontiers(floors, walls)
+	for i := range frontiers {
+		frontiers[i].VacuumID = vacuumID
+	}
+	return frontiers
+}
+
+// MapsFrontiers returns the frontiers of all maps together: edges of one
+// vacuum's floor that another vacuum has explored past are not frontiers.
+func MapsFrontiers(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix) []Frontier {
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var floors, walls [][]Point
+	for _, id := range ids {
+		transform, ok := transforms[id]
+		if !ok {
+			transform = Identity()
+		}
+		for _, f := range MapToFeatureCollection(maps[id], id, transform, 5.0).Features {
+			switch f.Properties["layerType"] {
+			case "floor", "segment":
+				floors = append(floors, outerRings(f.Geometry)...)
+			case "wall":
+				walls = append(walls, geometryPaths(f.Geometry)...)
+			}
+		}
+	}
+	return findFrontiers(floors, walls)
+}
+
+// Frontiers returns the frontiers of the unified floors and segments.
+func (um *UnifiedMap) Frontiers() []Frontier {
+	if um == nil {
+		return nil
+	}
+	var floors, walls [][]Point
+	for _, features := range [][]*UnifiedFeature{um.Floors, um.Segments} {
+		for _, f := range features {
+			floors = append(floors, outerRings(f.Geometry)...)
+		}
+	}
+	for _, f := range um.Walls {
+		walls = append(walls, geometryPaths(f.Geometry)...)
+	}
+	return findFrontiers(floors, walls)
+}
+
+// outerRings returns the rings of a polygon geometry that enclose floor.
+// Rings inside a larger ring outline obstacles, whose unknown interior
+// cannot be explored, and are dropped along with the zero-area slivers the
+// vectorizer leaves along pixel edges.
+func outerRings(geom *Geometry) [][]Point {
+	if geom == nil || (geom.Type != GeometryPolygon && geom.Type != GeometryMultiPolygon) {
+		return nil
+	}
+	paths := geometryPaths(geom)
+	rings := make([]orb.Ring, len(paths))
+	areas := make([]float64, len(paths))
+	for i, path := range paths {
+		rings[i] = pointsRing(path)
+		areas[i] = math.Abs(planar.Area(rings[i]))
+	}
+
+	var outer [][]Point
+	for i, ring := range rings {
+		if areas[i] == 0 {
+			continue
+		}
+		inside := false
+		for j, other := range rings {
+			if i != j && areas[j] > areas[i] && planar.RingContains(other, ring[0]) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			outer = append(outer, paths[i])
+		}
+	}
+	return outer
+}
+
+// findFrontiers walks the outline of every floor ring and returns the
+// stretches of at least MinFrontierLength that are not near a wall and
+// past which no floor ring continues, as it does across a doorway between
+// two segments.
+func findFrontiers(floors, walls [][]Point) []Frontier {
+	type wallLine struct {
+		line  orb.LineString
+		bound orb.Bound
+	}
+	wallLines := make([]wallLine, 0, len(walls))
+	for _, w := range walls {
+		if len(w) == 0 {
+			continue
+		}
+		ls := pointsLine(w)
+		wallLines = append(wallLines, wallLine{line: ls, bound: ls.Bound().Pad(frontierWallDistance)})
+	}
+	type floorRing struct {
+		ring  orb.Ring
+		bound orb.Bound
+	}
+	rings := make([]floorRing, 0, len(floors))
+	for _, f := range floors {
+		r := pointsRing(f)
+		rings = append(rings, floorRing{ring: r, bound: r.Bound()})
+	}
+
+	// open reports whether the edge at s, whose floor lies to the left when
+	// left is set, runs on into unexplored space
+	open := func(s lineSample, left bool) bool {
+		p := s.point
+		for _, w := range wallLines {
+			if w.bound.Contains(p) && planar.DistanceFrom(w.line, p) <= frontierWallDistance {
+				return false
+			}
+		}
+		out := s.direction - math.Pi/2
+		if !left {
+			out = s.direction + math.Pi/2
+		}
+		probe := orb.Point{p[0] + frontierProbeDistance*math.Cos(out), p[1] + frontierProbeDistance*math.Sin(out)}
+		for _, r := range rings {
+			if r.bound.Contains(probe) && planar.RingContains(r.ring, probe) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var frontiers []Frontier
+	for _, r := range rings {
+		samples := sampleLine(orb.LineString(r.ring), frontierSampleSpacing)
+		if len(samples) < 2 {
+			continue
+		}
+		left := r.ring.Orientation() == orb.CCW
+		isOpen := make([]bool, len(samples))
+		start := -1
+		for j, s := range samples {
+			isOpen[j] = open(s, left)
+			if !isOpen[j] && start < 0 {
+				start = j
+			}
+		}
+		if start < 0 {
+			// The whole outline is open: a floor with no walls at all
+			frontiers = appendFrontier(frontiers, samples)
+			continue
+		}
+
+		// Start at a closed sample so a run crossing the ring's seam stays
+		// in one piece
+		var run []lineSample
+		for k := 1; k <= len(samples); k++ {
+			j := (start + k) % len(samples)
+			if isOpen[j] {
+				run = append(run, samples[j])
+				continue
+			}
+			frontiers = appendFrontier(frontiers, run)
+			run = nil
+		}
+		frontiers = appendFrontier(frontiers, run)
+	}
+	return frontiers
+}
+
+// appendFrontier appends the run of samples as a frontier when it is long
+// enough.
+func appendFrontier(frontiers []Frontier, run []lineSample) []Frontier {
+	if len(run) < 2 {
+		return frontiers
+	}
+	line := make(orb.LineString, len(run))
+	for i, s := range run {
+		line[i] = s.point
+	}
+	length := lineLength(line)
+	if length < MinFrontierLength {
+		return frontiers
+	}
+
+	path := make([]Point, len(line))
+	for i, p := range line {
+		path[i] = Point{X: p[0], Y: p[1]}
+	}
+	return append(frontiers, Frontier{Path: path, Length: math.Round(length), Midpoint: pointAlong(line, length/2)})
+}
+
+// pointAlong returns the point dist along ls.
+func pointAlong(ls orb.LineString, dist float64) Point {
+	for i := 0; i+1 < len(ls); i++ {
+		a, b := ls[i], ls[i+1]
+		seg := math.Hypot(b[0]-a[0], b[1]-a[1])
+		if seg > 0 && dist <= seg {
+			f := dist / seg
+			return Point{X: a[0] + f*(b[0]-a[0]), Y: a[1] + f*(b[1]-a[1])}
+		}
+		dist -= seg
+	}
+	last := ls[len(ls)-1]
+	return Point{X: last[0], Y: last[1]}
+}
+
+func pointsLine(path []Point) orb.LineString {
+	ls := make(orb.LineString, len(path))
+	for i, p := range path {
+		ls[i] = orb.Point{p.X, p.Y}
+	}
+	return ls
+}
+
+func pointsRing(path []Point) orb.Ring {
+	return orb.Ring(pointsLine(path))
+}
@@ -0,0 +1,100 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// unifiedRoom returns a unified map with one floor polygon per rect and a
+// wall along each of walls.
+func unifiedRoom(rects [][4]float64, walls ...Path) *UnifiedMap {
+	um := &UnifiedMap{}
+	for _, r := range rects {
+		um.Segments = append(um.Segments, &UnifiedFeature{
+			Geometry: PathToPolygon(Path{{X: r[0], Y: r[1]}, {X: r[2], Y: r[1]}, {X: r[2], Y: r[3]}, {X: r[0], Y: r[3]}}),
+		})
+	}
+	for _, w := range walls {
+		um.Walls = append(um.Walls, &UnifiedFeature{Geometry: PathToLineString(w)})
+	}
+	return um
+}
+
+// ---------------------------------------------------------------------------
+// Frontiers
+// ---------------------------------------------------------------------------
+
+func TestUnifiedMapFrontiers_OpenSide(t *testing.T) {
+	// A 4x3 m room walled on every side but the east
+	um := unifiedRoom([][4]float64{{0, 0, 4000, 3000}},
+		Path{{X: 4000, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 3000}, {X: 4000, Y: 3000}})
+
+	frontiers := um.Frontiers()
+	if len(frontiers) != 1 {
+		t.Fatalf("got %d frontiers, want 1: %+v", len(frontiers), frontiers)
+	}
+	f := frontiers[0]
+	for _, p := range f.Path {
+		if p.X != 4000 {
+			t.Errorf("frontier point %v, want x=4000", p)
+		}
+	}
+	if f.Length < 2500 || f.Length > 3000 {
+		t.Errorf("length = %v, want about 3000 less the walled corners", f.Length)
+	}
+	if math.Abs(f.Midpoint.X-4000) > 1e-9 || math.Abs(f.Midpoint.Y-1500) > 100 {
+		t.Errorf("midpoint = %v, want about (4000, 1500)", f.Midpoint)
+	}
+}
+
+func TestUnifiedMapFrontiers_Closed(t *testing.T) {
+	tests := []struct {
+		name string
+		um   *UnifiedMap
+	}{
+		{"walled room", unifiedRoom([][4]float64{{0, 0, 4000, 3000}},
+			Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 3000}, {X: 0, Y: 3000}, {X: 0, Y: 0}})},
+		{"doorway between segments", unifiedRoom([][4]float64{{0, 0, 2000, 3000}, {2000, 0, 4000, 3000}},
+			Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 3000}, {X: 0, Y: 3000}, {X: 0, Y: 0}})},
+		{"short gap", unifiedRoom([][4]float64{{0, 0, 4000, 3000}},
+			Path{{X: 4000, Y: 1700}, {X: 4000, Y: 3000}, {X: 0, Y: 3000}, {X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 1300}})},
+		{"nil map", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if frontiers := tt.um.Frontiers(); len(frontiers) != 0 {
+				t.Errorf("got %d frontiers, want none: %+v", len(frontiers), frontiers)
+			}
+		})
+	}
+}
+
+func TestVacuumFrontiers(t *testing.T) {
+	// Floor 200x200 cells, walled on the west, north and south
+	m := rectFloor(1, 1, 200, 200)
+	var wall []int
+	for i := 0; i <= 200; i++ {
+		wall = append(wall, 0, i, i, 0, i, 200)
+	}
+	m.Layers = append(m.Layers, MapLayer{Type: "wall", Pixels: wall})
+
+	frontiers := VacuumFrontiers(m, "a", Identity())
+	if len(frontiers) != 1 {
+		t.Fatalf("got %d frontiers, want 1: %+v", len(frontiers), frontiers)
+	}
+	f := frontiers[0]
+	if f.VacuumID != "a" {
+		t.Errorf("vacuumId = %q, want a", f.VacuumID)
+	}
+	if math.Abs(f.Midpoint.X-1000) > 20 || math.Abs(f.Midpoint.Y-500) > 100 {
+		t.Errorf("midpoint = %v, want about (1000, 500)", f.Midpoint)
+	}
+
+	// Another vacuum that has explored east of the opening closes it
+	maps := map[string]*ValetudoMap{"a": m, "b": rectFloor(150, 1, 400, 200)}
+	for _, f := range MapsFrontiers(maps, map[string]AffineMatrix{"a": Identity(), "b": Identity()}) {
+		if f.Midpoint.X < 1100 {
+			t.Errorf("frontier at %v inside b's floor", f.Midpoint)
+		}
+	}
+}
@@ -30,8 +30,9 @@ type Feature struct {
 
 // FeatureCollection represents a GeoJSON FeatureCollection
 type FeatureCollection struct {
-	Type     string     `json:"type"`
-	Features []*Feature `json:"features"`
+	Type       string                 `json:"type"`
+	Features   []*Feature             `json:"features"`
+	Properties map[string]interface{} `json:"properties,omitempty"` // foreign member describing the whole collection
 }
 
 // NewFeatureCollection creates a new empty FeatureCollection
@@ -239,6 +240,9 @@ func LayerToFeature(layer *MapLayer, paths []Path, vacuumID string, transform Af
 	if layer.MetaData.Area > 0 {
 		props["area"] = layer.MetaData.Area
 	}
+	if hasMaterial(layer.MetaData.Material) {
+		props["material"] = layer.MetaData.Material
+	}
 	if layer.MetaData.Active {
 		props["active"] = layer.MetaData.Active
 	}
@@ -260,7 +264,7 @@ func MapToFeatureCollection(valetudoMap *ValetudoMap, vacuumID string, transform
 		layer := &valetudoMap.Layers[i]
 
 		// Vectorize the layer
-		paths := VectorizeLayer(layer, valetudoMap.PixelSize, tolerance)
+		paths := VectorizeMapLayer(valetudoMap, layer, tolerance)
 		if len(paths) == 0 {
 			continue
 		}
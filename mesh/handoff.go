@@ -0,0 +1,358 @@
+package mesh
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// hatchPeriod is the spacing in output pixels of the hatching drawn over
+// handoff zones; each stripe is hatchWidth pixels wide.
+const (
+	hatchPeriod = 8
+	hatchWidth  = 2
+)
+
+var hatchColor = color.NRGBA{40, 40, 40, 150}
+
+// HandoffZone is the area covered by both of two vacuums, in the reference
+// map's world coordinates.
+type HandoffZone struct {
+	Vacuums  [2]string // sorted by ID
+	AreaM2   float64
+	Polygons [][]Path // outlines in mm: each polygon's outer ring, then its holes
+
+	cells coverageGrid
+}
+
+// coverageGrid is a set of reference grid cells over a bounding box.
+type coverageGrid struct {
+	minX, minY    int
+	width, height int
+	cells         []bool
+}
+
+// has reports whether cell (x, y) is covered.
+func (g coverageGrid) has(x, y int) bool {
+	x, y = x-g.minX, y-g.minY
+	if x < 0 || x >= g.width || y < 0 || y >= g.height {
+		return false
+	}
+	return g.cells[y*g.width+x]
+}
+
+// vacuumCoverage rasterizes a vacuum's floor and segment layers into the
+// reference grid. Each reference cell is mapped back into the vacuum's own
+// grid, so rotated maps leave no gaps between cells.
+func vacuumCoverage(m *ValetudoMap, transform AffineMatrix) (coverageGrid, bool) {
+	var pixels []int
+	for _, layer := range m.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			pixels = append(pixels, layer.Pixels...)
+		}
+	}
+	if len(pixels) < 2 {
+		return coverageGrid{}, false
+	}
+	src, srcMinX, srcMinY, srcW, srcH := pixelsToGrid(pixels, m.CellBlock())
+
+	minX, minY := math.MaxFloat64, math.MaxFloat64
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, c := range []Point{
+		{X: float64(srcMinX), Y: float64(srcMinY)},
+		{X: float64(srcMinX + srcW), Y: float64(srcMinY)},
+		{X: float64(srcMinX), Y: float64(srcMinY + srcH)},
+		{X: float64(srcMinX + srcW), Y: float64(srcMinY + srcH)},
+	} {
+		tc := TransformPoint(c, transform)
+		minX, minY = math.Min(minX, tc.X), math.Min(minY, tc.Y)
+		maxX, maxY = math.Max(maxX, tc.X), math.Max(maxY, tc.Y)
+	}
+
+	g := coverageGrid{minX: int(math.Floor(minX)), minY: int(math.Floor(minY))}
+	g.width = int(math.Ceil(maxX)) - g.minX + 1
+	g.height = int(math.Ceil(maxY)) - g.minY + 1
+	g.cells = make([]bool, g.width*g.height)
+
+	inv := InvertMatrix(transform)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			p := TransformPoint(Point{X: float64(g.minX + x), Y: float64(g.minY + y)}, inv)
+			sx := int(math.Round(p.X)) - srcMinX
+			sy := int(math.Round(p.Y)) - srcMinY
+			if sx >= 0 && sx < srcW && sy >= 0 && sy < srcH && src[sy*srcW+sx] {
+				g.cells[y*g.width+x] = true
+			}
+		}
+	}
+	return g, true
+}
+
+// HandoffZones returns the coverage overlap of every pair of vacuums, after
+// transforming each map into the reference frame. Pairs that do not overlap
+// are omitted. Zones are sorted by vacuum IDs.
+func HandoffZones(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference string) []HandoffZone {
+	ids := make([]string, 0, len(maps))
+	coverage := make(map[string]coverageGrid, len(maps))
+	for id, m := range maps {
+		transform, ok := transforms[id]
+		if !ok {
+			transform = Identity()
+		}
+		if g, ok := vacuumCoverage(m, transform); ok {
+			ids = append(ids, id)
+			coverage[id] = g
+		}
+	}
+	sort.Strings(ids)
+
+	pixelSize := referencePixelSize(maps, reference)
+	var zones []HandoffZone
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			if z, ok := overlapZone(coverage[a], coverage[b], pixelSize); ok {
+				z.Vacuums = [2]string{a, b}
+				zones = append(zones, z)
+			}
+		}
+	}
+	return zones
+}
+
+// overlapZone intersects two coverage grids. The second result is false if
+// they share no cells.
+func overlapZone(a, b coverageGrid, pixelSize float64) (HandoffZone, bool) {
+	minX, minY := max(a.minX, b.minX), max(a.minY, b.minY)
+	maxX, maxY := min(a.minX+a.width, b.minX+b.width), min(a.minY+a.height, b.minY+b.height)
+	if maxX <= minX || maxY <= minY {
+		return HandoffZone{}, false
+	}
+
+	g := coverageGrid{minX: minX, minY: minY, width: maxX - minX, height: maxY - minY}
+	g.cells = make([]bool, g.width*g.height)
+	count := 0
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if a.has(minX+x, minY+y) && b.has(minX+x, minY+y) {
+				g.cells[y*g.width+x] = true
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return HandoffZone{}, false
+	}
+
+	z := HandoffZone{
+		AreaM2: float64(count) * pixelSize * pixelSize / 1e6,
+		cells:  g,
+	}
+	for _, polygon := range g.outlines() {
+		for _, ring := range polygon {
+			for i := range ring {
+				ring[i] = Point{X: ring[i].X * pixelSize, Y: ring[i].Y * pixelSize}
+			}
+		}
+		z.Polygons = append(z.Polygons, polygon)
+	}
+	return z, true
+}
+
+// outlines traces the boundaries of the covered cells along cell edges, in
+// grid units. Unlike traceContours, which follows pixel centers, the rings
+// enclose exactly the covered area. Each polygon is an outer ring followed
+// by the holes inside it.
+func (g coverageGrid) outlines() [][]Path {
+	// Directed boundary edges keep the covered cell on their right (screen
+	// coordinates), so outer rings run clockwise and holes anticlockwise
+	type edge struct{ from, to [2]int }
+	var edges []edge
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			cx, cy := g.minX+x, g.minY+y
+			if !g.has(cx, cy) {
+				continue
+			}
+			if !g.has(cx, cy-1) {
+				edges = append(edges, edge{[2]int{cx, cy}, [2]int{cx + 1, cy}})
+			}
+			if !g.has(cx+1, cy) {
+				edges = append(edges, edge{[2]int{cx + 1, cy}, [2]int{cx + 1, cy + 1}})
+			}
+			if !g.has(cx, cy+1) {
+				edges = append(edges, edge{[2]int{cx + 1, cy + 1}, [2]int{cx, cy + 1}})
+			}
+			if !g.has(cx-1, cy) {
+				edges = append(edges, edge{[2]int{cx, cy + 1}, [2]int{cx, cy}})
+			}
+		}
+	}
+
+	outgoing := make(map[[2]int][]int)
+	for i, e := range edges {
+		outgoing[e.from] = append(outgoing[e.from], i)
+	}
+
+	used := make([]bool, len(edges))
+	var outers, holes []Path
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var ring Path
+		cur := start
+		for {
+			used[cur] = true
+			e := edges[cur]
+			ring = append(ring, Point{X: float64(e.from[0]), Y: float64(e.from[1])})
+			if e.to == edges[start].from {
+				break
+			}
+			// Where two cells touch only at a corner, turn right so each
+			// cell's ring stays separate
+			next := -1
+			for _, cand := range outgoing[e.to] {
+				if used[cand] {
+					continue
+				}
+				if next < 0 || turnsRight(e.from, e.to, edges[cand].to) {
+					next = cand
+				}
+			}
+			if next < 0 {
+				break
+			}
+			cur = next
+		}
+
+		ring = dropCollinear(ring)
+		if len(ring) < 3 {
+			continue
+		}
+		if ringArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygons := make([][]Path, len(outers))
+	for i, outer := range outers {
+		polygons[i] = []Path{outer}
+	}
+	// Each hole belongs to the smallest outer ring containing it
+	for _, hole := range holes {
+		best := -1
+		for i, outer := range outers {
+			if pointInRing(hole[0], outer) && (best < 0 || ringArea(outer) < ringArea(outers[best])) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			polygons[best] = append(polygons[best], hole)
+		}
+	}
+	return polygons
+}
+
+// turnsRight reports whether the path a -> b -> c turns right on screen.
+func turnsRight(a, b, c [2]int) bool {
+	return (b[0]-a[0])*(c[1]-b[1])-(b[1]-a[1])*(c[0]-b[0]) > 0
+}
+
+// dropCollinear removes ring vertices that lie on a straight run.
+func dropCollinear(ring Path) Path {
+	out := make(Path, 0, len(ring))
+	n := len(ring)
+	for i, p := range ring {
+		prev, next := ring[(i+n-1)%n], ring[(i+1)%n]
+		if (p.X-prev.X)*(next.Y-p.Y)-(p.Y-prev.Y)*(next.X-p.X) != 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ringArea returns the signed area of a ring: positive for rings running
+// clockwise on screen (y down).
+func ringArea(ring Path) float64 {
+	sum := 0.0
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		sum += p.X*q.Y - q.X*p.Y
+	}
+	return sum / 2
+}
+
+// pointInRing reports whether p lies inside ring (even-odd rule).
+func pointInRing(p Point, ring Path) bool {
+	inside := false
+	for i, a := range ring {
+		b := ring[(i+1)%len(ring)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// HandoffFeatureCollection converts handoff zones into GeoJSON, one
+// MultiPolygon feature per vacuum pair. config supplies display names and
+// may be nil.
+func HandoffFeatureCollection(zones []HandoffZone, config *Config) *FeatureCollection {
+	fc := NewFeatureCollection()
+	for _, z := range zones {
+		polygons := make([][][][2]float64, 0, len(z.Polygons))
+		for _, polygon := range z.Polygons {
+			rings := make([][][2]float64, 0, len(polygon))
+			for _, path := range polygon {
+				ring := make([][2]float64, 0, len(path)+1)
+				for _, p := range path {
+					ring = append(ring, [2]float64{p.X, p.Y})
+				}
+				rings = append(rings, append(ring, ring[0]))
+			}
+			polygons = append(polygons, rings)
+		}
+		coords, _ := json.Marshal(polygons)
+
+		fc.AddFeature(NewFeature(&Geometry{Type: GeometryMultiPolygon, Coordinates: coords}, map[string]interface{}{
+			"vacuums":      []string{z.Vacuums[0], z.Vacuums[1]},
+			"displayNames": []string{config.DisplayName(z.Vacuums[0]), config.DisplayName(z.Vacuums[1])},
+			"areaM2":       roundTo(z.AreaM2, 2),
+		}))
+	}
+	return fc
+}
+
+// drawHandoff hatches the handoff zones of the renderer's maps. Alternate
+// zones are hatched in opposite directions so overlapping zones stay
+// distinguishable.
+func (r *CompositeRenderer) drawHandoff(img *image.RGBA, minX, minY, centerX, centerY float64) {
+	zones := HandoffZones(r.Maps, r.Transforms, r.Reference)
+	if len(zones) == 0 {
+		return
+	}
+	toWorld := r.imageToWorld(minX, minY, centerX, centerY)
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			w := toWorld(float64(x)+0.5, float64(y)+0.5)
+			cx, cy := int(math.Floor(w.X)), int(math.Floor(w.Y))
+			for i, z := range zones {
+				stripe := x + y
+				if i%2 == 1 {
+					stripe = x - y
+				}
+				if ((stripe%hatchPeriod)+hatchPeriod)%hatchPeriod >= hatchWidth || !z.cells.has(cx, cy) {
+					continue
+				}
+				img.Set(x, y, blendColors(img.RGBAAt(x, y), hatchColor))
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package mesh
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Zones
+// ---------------------------------------------------------------------------
+
+func TestHandoffZones_Overlap(t *testing.T) {
+	maps := map[string]*ValetudoMap{
+		"a": rectFloor(0, 0, 40, 20),
+		"b": rectFloor(30, 0, 60, 20),
+		"c": rectFloor(100, 100, 110, 110), // touches nobody
+	}
+	transforms := map[string]AffineMatrix{"a": Identity(), "b": Identity(), "c": Identity()}
+
+	zones := HandoffZones(maps, transforms, "a")
+	if len(zones) != 1 {
+		t.Fatalf("got %d zones, want 1", len(zones))
+	}
+	z := zones[0]
+	if z.Vacuums != [2]string{"a", "b"} {
+		t.Errorf("vacuums = %v", z.Vacuums)
+	}
+	// 10x20 cells at 5mm
+	if math.Abs(z.AreaM2-0.005) > 1e-9 {
+		t.Errorf("area = %v m², want 0.005", z.AreaM2)
+	}
+	want := [][]Path{{{{X: 150, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 100}, {X: 150, Y: 100}}}}
+	if !reflect.DeepEqual(z.Polygons, want) {
+		t.Errorf("polygons = %v, want the shared strip %v", z.Polygons, want)
+	}
+}
+
+func TestCoverageOutlines_Holes(t *testing.T) {
+	// A 5x5 block with the center cell missing, plus a separate cell that
+	// touches it only at a corner
+	g := coverageGrid{width: 7, height: 7, cells: make([]bool, 49)}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			g.cells[y*7+x] = x != 2 || y != 2
+		}
+	}
+	g.cells[5*7+5] = true
+
+	polygons := g.outlines()
+	if len(polygons) != 2 {
+		t.Fatalf("got %d polygons, want 2: %v", len(polygons), polygons)
+	}
+	for _, polygon := range polygons {
+		outer := ringArea(polygon[0])
+		switch outer {
+		case 25:
+			if len(polygon) != 2 || ringArea(polygon[1]) != -1 {
+				t.Errorf("block = %v, want one unit hole", polygon)
+			}
+		case 1:
+			if len(polygon) != 1 {
+				t.Errorf("corner cell = %v, want no holes", polygon)
+			}
+		default:
+			t.Errorf("unexpected outer ring area %v: %v", outer, polygon)
+		}
+	}
+}
+
+func TestHandoffZones_FollowsTransforms(t *testing.T) {
+	// b is stored rotated 90° in its own frame; its transform brings it back
+	// onto a's right half
+	a := rectFloor(0, 0, 40, 40)
+	b := rectFloor(0, 0, 40, 20) // 40 wide, 20 tall
+	toRef := CreateRotationTranslation(90, 40, 0)
+
+	zones := HandoffZones(map[string]*ValetudoMap{"a": a, "b": b}, map[string]AffineMatrix{"a": Identity(), "b": toRef}, "a")
+	if len(zones) != 1 {
+		t.Fatalf("got %d zones, want 1", len(zones))
+	}
+	// Rotated b covers x 20..40 of a's 40x40 square: 20x40 cells, with
+	// one-cell rounding at the edges
+	cells := zones[0].AreaM2 * 1e6 / 25
+	if cells < 19*40 || cells > 21*40 {
+		t.Errorf("overlap = %.0f cells, want about 800", cells)
+	}
+}
+
+func TestHandoffFeatureCollection(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": rectFloor(0, 0, 20, 20), "b": rectFloor(10, 0, 30, 20)}
+	zones := HandoffZones(maps, map[string]AffineMatrix{}, "a")
+	fc := HandoffFeatureCollection(zones, &Config{Vacuums: []VacuumConfig{{ID: "b", DisplayName: "Bee"}}})
+
+	if len(fc.Features) != 1 {
+		t.Fatalf("got %d features, want 1", len(fc.Features))
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != GeometryMultiPolygon {
+		t.Errorf("geometry = %s", f.Geometry.Type)
+	}
+	if names := f.Properties["displayNames"].([]string); names[0] != "a" || names[1] != "Bee" {
+		t.Errorf("displayNames = %v", names)
+	}
+
+	var polygons [][][][2]float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+		t.Fatal(err)
+	}
+	ring := polygons[0][0]
+	if ring[0] != ring[len(ring)-1] {
+		t.Errorf("ring not closed: %v", ring)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+func TestRender_HandoffHatching(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": rectFloor(0, 0, 40, 20), "b": rectFloor(30, 0, 60, 20)}
+	transforms := map[string]AffineMatrix{"a": Identity(), "b": Identity()}
+	newRenderer := func() *CompositeRenderer {
+		r := NewCompositeRenderer(maps, transforms, "a")
+		r.Padding = 0
+		r.Legend.Hidden = true
+		return r
+	}
+
+	plain := newRenderer().Render()
+	r := newRenderer()
+	r.Overlay.Handoff = true
+	img := r.Render()
+
+	// Diagonal stripes inside the shared strip (x 30-39) only
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			changed := img.RGBAAt(x, y) != plain.RGBAAt(x, y)
+			want := x >= 30 && x < 40 && (x+y)%hatchPeriod < hatchWidth
+			if changed != want {
+				t.Fatalf("pixel (%d, %d) changed=%v, want %v", x, y, changed, want)
+			}
+		}
+	}
+}
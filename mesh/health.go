@@ -0,0 +1,186 @@
+package mesh
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Vacuum health statuses reported by /health, from the most serious.
+const (
+	HealthParseErrors  = "parse-errors"       // recent map messages could not be decoded
+	HealthStale        = "stale"              // no message for longer than health.staleAfter
+	HealthUncalibrated = "uncalibrated"       // no transform onto the reference
+	HealthLowICPScore  = "low-icp-score"      // aligned, but with a poor match
+	HealthAmbiguous    = "ambiguous-rotation" // other rotations aligned about as well
+	HealthOK           = "ok"
+)
+
+// Health defaults.
+const (
+	DefaultHealthStaleAfter  = 24 * time.Hour
+	DefaultHealthMinICPScore = 0.3 // as low as the unified-map alignment accepts
+	DefaultHealthErrorWindow = time.Hour
+)
+
+// VacuumHealth is the status of one vacuum: the most serious of its
+// problems, all of them, and what they are based on.
+type VacuumHealth struct {
+	VacuumID       string     `json:"vacuumId"`
+	Status         string     `json:"status"`
+	Problems       []string   `json:"problems,omitempty"`
+	LastSeen       *time.Time `json:"lastSeen,omitempty"`
+	ParseErrors    int        `json:"parseErrors"` // within DefaultHealthErrorWindow
+	LastError      string     `json:"lastError,omitempty"`
+	LastErrorAt    *time.Time `json:"lastErrorAt,omitempty"`
+	ICPScore       float64    `json:"icpScore,omitempty"`
+	Quarantined    int        `json:"quarantined"` // maps rejected by CheckMapSanity since startup
+	LastQuarantine string     `json:"lastQuarantine,omitempty"`
+}
+
+// HealthMonitor records when each vacuum was last heard from, its recent
+// map decoding failures and the maps it quarantined.
+type HealthMonitor struct {
+	mu          sync.Mutex
+	seen        map[string]time.Time
+	failures    map[string][]time.Time // within the error window, oldest first
+	lastErr     map[string]string
+	lastAt      map[string]time.Time
+	quarantined map[string]int
+	quarantine  map[string]string
+}
+
+// NewHealthMonitor creates a monitor with nothing recorded.
+func NewHealthMonitor() *HealthMonitor {
+	return &HealthMonitor{
+		seen:        make(map[string]time.Time),
+		failures:    make(map[string][]time.Time),
+		lastErr:     make(map[string]string),
+		lastAt:      make(map[string]time.Time),
+		quarantined: make(map[string]int),
+		quarantine:  make(map[string]string),
+	}
+}
+
+// RecordMessage notes that vacuumID sent a message at t.
+func (h *HealthMonitor) RecordMessage(vacuumID string, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t.After(h.seen[vacuumID]) {
+		h.seen[vacuumID] = t
+	}
+}
+
+// RecordParseError notes that a message from vacuumID at t could not be
+// decoded. The vacuum still counts as seen.
+func (h *HealthMonitor) RecordParseError(vacuumID string, err error, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t.After(h.seen[vacuumID]) {
+		h.seen[vacuumID] = t
+	}
+	h.failures[vacuumID] = append(pruneBefore(h.failures[vacuumID], t.Add(-DefaultHealthErrorWindow)), t)
+	h.lastErr[vacuumID] = err.Error()
+	h.lastAt[vacuumID] = t
+}
+
+// RecordQuarantine notes that a map from vacuumID at t was rejected as
+// corrupt or partial for reason. The vacuum still counts as seen.
+func (h *HealthMonitor) RecordQuarantine(vacuumID string, reason error, t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t.After(h.seen[vacuumID]) {
+		h.seen[vacuumID] = t
+	}
+	h.quarantined[vacuumID]++
+	h.quarantine[vacuumID] = reason.Error()
+}
+
+// LastSeen returns when each vacuum was last heard from.
+func (h *HealthMonitor) LastSeen() map[string]time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seen := make(map[string]time.Time, len(h.seen))
+	for id, t := range h.seen {
+		seen[id] = t
+	}
+	return seen
+}
+
+// VacuumIDs returns the vacuums anything was recorded for, sorted.
+func (h *HealthMonitor) VacuumIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.seen))
+	for id := range h.seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Check returns the health of vacuumID at now. A vacuum is calibrated when
+// it is reference or cal has its transform; lastPosition, when later than
+// its last message, counts as seen too.
+func (h *HealthMonitor) Check(vacuumID string, config HealthConfig, cal *CalibrationData, reference string, lastPosition, now time.Time) VacuumHealth {
+	health := VacuumHealth{VacuumID: vacuumID}
+
+	h.mu.Lock()
+	seen := h.seen[vacuumID]
+	recent := pruneBefore(h.failures[vacuumID], now.Add(-DefaultHealthErrorWindow))
+	h.failures[vacuumID] = recent
+	health.ParseErrors = len(recent)
+	if at, ok := h.lastAt[vacuumID]; ok {
+		health.LastError = h.lastErr[vacuumID]
+		health.LastErrorAt = &at
+	}
+	health.Quarantined = h.quarantined[vacuumID]
+	health.LastQuarantine = h.quarantine[vacuumID]
+	h.mu.Unlock()
+
+	if lastPosition.After(seen) {
+		seen = lastPosition
+	}
+	if !seen.IsZero() {
+		health.LastSeen = &seen
+	}
+
+	staleAfter, err := config.StaleDuration()
+	if err != nil {
+		staleAfter = DefaultHealthStaleAfter
+	}
+	minScore := config.MinICPScore
+	if minScore <= 0 {
+		minScore = DefaultHealthMinICPScore
+	}
+
+	if health.ParseErrors > 0 {
+		health.Problems = append(health.Problems, HealthParseErrors)
+	}
+	if seen.IsZero() || now.Sub(seen) > staleAfter {
+		health.Problems = append(health.Problems, HealthStale)
+	}
+	if vc := cal.GetVacuumCalibration(vacuumID); vc == nil && vacuumID != reference {
+		health.Problems = append(health.Problems, HealthUncalibrated)
+	} else if vc != nil && vacuumID != reference {
+		health.ICPScore = vc.ICPScore
+		if vc.ICPScore > 0 && vc.ICPScore < minScore {
+			health.Problems = append(health.Problems, HealthLowICPScore)
+		}
+		if len(vc.Alternatives) > 0 {
+			health.Problems = append(health.Problems, HealthAmbiguous)
+		}
+	}
+
+	health.Status = HealthOK
+	if len(health.Problems) > 0 {
+		health.Status = health.Problems[0]
+	}
+	return health
+}
+
+// pruneBefore drops the times before cutoff from the sorted times.
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return !times[i].Before(cutoff) })
+	return times[i:]
+}
@@ -0,0 +1,123 @@
+package mesh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// HealthMonitor
+// ---------------------------------------------------------------------------
+
+func TestHealthMonitor_Check(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cal := &CalibrationData{
+		ReferenceVacuum: "ref",
+		Vacuums: map[string]VacuumCalibration{
+			"ref":       {Transform: Identity()},
+			"good":      {Transform: Identity(), ICPScore: 0.8},
+			"poor":      {Transform: Identity(), ICPScore: 0.1},
+			"legacy":    {Transform: Identity()}, // cached before scores were kept
+			"symmetric": {Transform: Identity(), ICPScore: 0.7, Alternatives: []RotationHypothesis{{Transform: RotationDeg(180), Rotation: 180, Score: 0.68}}},
+		},
+	}
+
+	h := NewHealthMonitor()
+	for _, id := range []string{"ref", "good", "poor", "legacy", "symmetric", "new"} {
+		h.RecordMessage(id, now.Add(-time.Minute))
+	}
+	h.RecordMessage("old", now.Add(-25*time.Hour))
+
+	tests := []struct {
+		id       string
+		lastPos  time.Time
+		status   string
+		problems []string
+	}{
+		{"ref", time.Time{}, HealthOK, nil},
+		{"good", time.Time{}, HealthOK, nil},
+		{"legacy", time.Time{}, HealthOK, nil},
+		{"poor", time.Time{}, HealthLowICPScore, []string{HealthLowICPScore}},
+		{"symmetric", time.Time{}, HealthAmbiguous, []string{HealthAmbiguous}},
+		{"new", time.Time{}, HealthUncalibrated, []string{HealthUncalibrated}},
+		{"old", time.Time{}, HealthStale, []string{HealthStale, HealthUncalibrated}},
+		{"old", now.Add(-time.Hour), HealthUncalibrated, []string{HealthUncalibrated}}, // still reporting positions
+		{"silent", time.Time{}, HealthStale, []string{HealthStale, HealthUncalibrated}},
+	}
+	for _, tt := range tests {
+		got := h.Check(tt.id, HealthConfig{}, cal, cal.ReferenceVacuum, tt.lastPos, now)
+		if got.Status != tt.status || !reflect.DeepEqual(got.Problems, tt.problems) {
+			t.Errorf("%s: status=%q problems=%v, want %q %v", tt.id, got.Status, got.Problems, tt.status, tt.problems)
+		}
+	}
+
+	if got := h.Check("good", HealthConfig{}, cal, "ref", time.Time{}, now); got.ICPScore != 0.8 {
+		t.Errorf("good: icpScore = %v, want 0.8", got.ICPScore)
+	}
+}
+
+func TestHealthMonitor_Thresholds(t *testing.T) {
+	now := time.Now()
+	cal := &CalibrationData{ReferenceVacuum: "ref", Vacuums: map[string]VacuumCalibration{"v": {ICPScore: 0.5}}}
+	h := NewHealthMonitor()
+	h.RecordMessage("v", now.Add(-2*time.Hour))
+
+	got := h.Check("v", HealthConfig{StaleAfter: "1h", MinICPScore: 0.6}, cal, "ref", time.Time{}, now)
+	if want := []string{HealthStale, HealthLowICPScore}; !reflect.DeepEqual(got.Problems, want) {
+		t.Errorf("problems = %v, want %v", got.Problems, want)
+	}
+}
+
+func TestHealthMonitor_ParseErrors(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cal := &CalibrationData{ReferenceVacuum: "v"}
+	h := NewHealthMonitor()
+	h.RecordParseError("v", errors.New("first"), now.Add(-90*time.Minute))
+	h.RecordParseError("v", errors.New("second"), now.Add(-30*time.Minute))
+	h.RecordParseError("v", errors.New("third"), now.Add(-10*time.Minute))
+	h.RecordMessage("v", now.Add(-time.Minute))
+
+	got := h.Check("v", HealthConfig{}, cal, "v", time.Time{}, now)
+	if got.Status != HealthParseErrors || got.ParseErrors != 2 {
+		t.Errorf("status=%q parseErrors=%d, want %q and 2 within the hour", got.Status, got.ParseErrors, HealthParseErrors)
+	}
+	if got.LastError != "third" || got.LastErrorAt == nil || !got.LastErrorAt.Equal(now.Add(-10*time.Minute)) {
+		t.Errorf("lastError=%q at %v, want the third error", got.LastError, got.LastErrorAt)
+	}
+	if got.LastSeen == nil || !got.LastSeen.Equal(now.Add(-time.Minute)) {
+		t.Errorf("lastSeen = %v, want a minute ago", got.LastSeen)
+	}
+
+	// An hour later the errors have aged out, the last one is still shown
+	later := h.Check("v", HealthConfig{StaleAfter: "48h"}, cal, "v", time.Time{}, now.Add(time.Hour))
+	if later.Status != HealthOK || later.ParseErrors != 0 || later.LastError != "third" {
+		t.Errorf("an hour later: status=%q parseErrors=%d lastError=%q", later.Status, later.ParseErrors, later.LastError)
+	}
+}
+
+func TestHealthMonitor_Quarantine(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cal := &CalibrationData{ReferenceVacuum: "v"}
+	h := NewHealthMonitor()
+	h.RecordQuarantine("v", ErrZeroPixelSize, now.Add(-2*time.Minute))
+	h.RecordQuarantine("v", ErrAreaDropped, now.Add(-time.Minute))
+
+	got := h.Check("v", HealthConfig{}, cal, "v", time.Time{}, now)
+	if got.Quarantined != 2 || got.LastQuarantine != ErrAreaDropped.Error() {
+		t.Errorf("quarantined=%d last=%q, want 2 and the area drop", got.Quarantined, got.LastQuarantine)
+	}
+	if got.Status != HealthOK || got.LastSeen == nil || !got.LastSeen.Equal(now.Add(-time.Minute)) {
+		t.Errorf("status=%q lastSeen=%v, want ok and seen a minute ago", got.Status, got.LastSeen)
+	}
+}
+
+func TestHealthMonitor_VacuumIDs(t *testing.T) {
+	h := NewHealthMonitor()
+	h.RecordMessage("b", time.Now())
+	h.RecordParseError("a", errors.New("x"), time.Now())
+	if got := h.VacuumIDs(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("VacuumIDs() = %v, want [a b]", got)
+	}
+}
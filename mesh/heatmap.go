@@ -0,0 +1,245 @@
+package mesh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Heatmap defaults.
+const (
+	DefaultHeatmapCellSize = 100.0 // mm
+	DefaultHeatmapDays     = 7     // days of visits rendered
+	HeatmapRetentionDays   = 90    // days of visits kept
+	DefaultHeatmapFile     = "heatmap.json"
+)
+
+// heatmapDay is the layout of the day keys in a Heatmap.
+const heatmapDay = "2006-01-02"
+
+// HeatCell is a cell of a Heatmap's grid: the world point (X*CellSize,
+// Y*CellSize) is its top-left corner.
+type HeatCell struct {
+	X, Y int
+}
+
+// Heatmap counts how often vacuums visit each cell of a world grid, per UTC
+// day. A visit is a position in a different cell than the vacuum's last
+// one, so a robot standing on its dock counts once.
+type Heatmap struct {
+	CellSize float64 // mm
+
+	mu   sync.Mutex
+	days map[string]map[HeatCell]int
+	last map[string]HeatCell // last cell of each vacuum
+}
+
+// NewHeatmap creates an empty heatmap with DefaultHeatmapCellSize cells.
+func NewHeatmap() *Heatmap {
+	return &Heatmap{
+		CellSize: DefaultHeatmapCellSize,
+		days:     make(map[string]map[HeatCell]int),
+		last:     make(map[string]HeatCell),
+	}
+}
+
+// Record counts a visit by vacuumID to the world point p (mm) at t, unless
+// the vacuum was already in that cell.
+func (h *Heatmap) Record(vacuumID string, p Point, t time.Time) {
+	cell := HeatCell{X: int(math.Floor(p.X / h.CellSize)), Y: int(math.Floor(p.Y / h.CellSize))}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if last, ok := h.last[vacuumID]; ok && last == cell {
+		return
+	}
+	h.last[vacuumID] = cell
+	day := t.UTC().Format(heatmapDay)
+	counts := h.days[day]
+	if counts == nil {
+		counts = make(map[HeatCell]int)
+		h.days[day] = counts
+	}
+	counts[cell]++
+}
+
+// Counts returns the visits to each cell on the days from since onwards.
+func (h *Heatmap) Counts(since time.Time) map[HeatCell]int {
+	first := since.UTC().Format(heatmapDay)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	total := make(map[HeatCell]int)
+	for day, counts := range h.days {
+		if day < first {
+			continue
+		}
+		for cell, n := range counts {
+			total[cell] += n
+		}
+	}
+	return total
+}
+
+// Prune drops the days before before.
+func (h *Heatmap) Prune(before time.Time) {
+	first := before.UTC().Format(heatmapDay)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for day := range h.days {
+		if day < first {
+			delete(h.days, day)
+		}
+	}
+}
+
+// heatmapFile is the JSON form of a Heatmap: per day, "x,y" cell keys and
+// their visit counts.
+type heatmapFile struct {
+	CellSize float64                   `json:"cellSize"`
+	Days     map[string]map[string]int `json:"days"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the heatmap as a
+// heatmapFile.
+func (h *Heatmap) MarshalJSON() ([]byte, error) {
+	h.mu.Lock()
+	file := heatmapFile{CellSize: h.CellSize, Days: make(map[string]map[string]int, len(h.days))}
+	for day, counts := range h.days {
+		cells := make(map[string]int, len(counts))
+		for cell, n := range counts {
+			cells[fmt.Sprintf("%d,%d", cell.X, cell.Y)] = n
+		}
+		file.Days[day] = cells
+	}
+	h.mu.Unlock()
+	return json.Marshal(file)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the heatmap's counts
+// with those of a heatmapFile.
+func (h *Heatmap) UnmarshalJSON(data []byte) error {
+	var file heatmapFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	days := make(map[string]map[HeatCell]int, len(file.Days))
+	for day, cells := range file.Days {
+		counts := make(map[HeatCell]int, len(cells))
+		for key, n := range cells {
+			x, y, ok := strings.Cut(key, ",")
+			cx, errX := strconv.Atoi(x)
+			cy, errY := strconv.Atoi(y)
+			if !ok || errX != nil || errY != nil {
+				return fmt.Errorf("bad cell %q", key)
+			}
+			counts[HeatCell{X: cx, Y: cy}] = n
+		}
+		days[day] = counts
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if file.CellSize > 0 {
+		h.CellSize = file.CellSize
+	}
+	h.days = days
+	if h.last == nil {
+		h.last = make(map[string]HeatCell)
+	}
+	return nil
+}
+
+// Save writes the heatmap to path as JSON.
+func (h *Heatmap) Save(path string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0644)
+}
+
+// LoadHeatmap reads a heatmap saved by Save. A missing file yields an empty
+// heatmap.
+func LoadHeatmap(path string) (*Heatmap, error) {
+	h := NewHeatmap()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// HeatGrid is a snapshot of heatmap counts to draw over a floor plan.
+type HeatGrid struct {
+	CellSize float64 // mm
+	Counts   map[HeatCell]int
+}
+
+// heatLevels is the number of colors the heat ramp is drawn in.
+const heatLevels = 8
+
+// levels groups the cells into heatLevels bands, from the least visited to
+// the most, each sorted for a stable drawing order. Bands follow the square
+// root of the count relative to the maximum, so rarely cleaned cells stay
+// visible next to the dock.
+func (g *HeatGrid) levels() [heatLevels][]HeatCell {
+	var bands [heatLevels][]HeatCell
+	peak := 0
+	for _, n := range g.Counts {
+		peak = max(peak, n)
+	}
+	if peak == 0 {
+		return bands
+	}
+	for cell, n := range g.Counts {
+		if n <= 0 {
+			continue
+		}
+		level := int(math.Sqrt(float64(n)/float64(peak)) * heatLevels)
+		level = min(level, heatLevels-1)
+		bands[level] = append(bands[level], cell)
+	}
+	for _, band := range bands {
+		sort.Slice(band, func(i, j int) bool {
+			if band[i].Y != band[j].Y {
+				return band[i].Y < band[j].Y
+			}
+			return band[i].X < band[j].X
+		})
+	}
+	return bands
+}
+
+// heatColor returns the color of a heat level: blue for rarely visited
+// cells through green and yellow to red for the most visited.
+func heatColor(level int) color.NRGBA {
+	ramp := []color.NRGBA{
+		{0, 0, 255, 150},
+		{0, 200, 0, 160},
+		{255, 220, 0, 170},
+		{255, 0, 0, 180},
+	}
+	f := float64(level) / float64(heatLevels-1) * float64(len(ramp)-1)
+	i := min(int(f), len(ramp)-2)
+	t := f - float64(i)
+	lerp := func(a, b uint8) uint8 { return uint8(math.Round(float64(a) + t*(float64(b)-float64(a)))) }
+	a, b := ramp[i], ramp[i+1]
+	return color.NRGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
@@ -0,0 +1,105 @@
+package mesh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Heatmap
+// ---------------------------------------------------------------------------
+
+func TestHeatmap_Record(t *testing.T) {
+	h := NewHeatmap()
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	h.Record("a", Point{X: 50, Y: 50}, day)
+	h.Record("a", Point{X: 60, Y: 90}, day) // same cell, still there
+	h.Record("a", Point{X: 150, Y: 50}, day)
+	h.Record("a", Point{X: 50, Y: 50}, day) // back again
+	h.Record("b", Point{X: -50, Y: 50}, day)
+
+	want := map[HeatCell]int{{0, 0}: 2, {1, 0}: 1, {-1, 0}: 1}
+	if got := h.Counts(day); !reflect.DeepEqual(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
+
+func TestHeatmap_CountsSincePrune(t *testing.T) {
+	h := NewHeatmap()
+	old := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	h.Record("a", Point{X: 50, Y: 50}, old)
+	h.Record("a", Point{X: 150, Y: 50}, recent)
+
+	if got := h.Counts(recent.Add(-time.Hour)); len(got) != 1 || got[HeatCell{1, 0}] != 1 {
+		t.Errorf("recent counts = %v, want only the cell visited on the recent day", got)
+	}
+	if got := h.Counts(old); len(got) != 2 {
+		t.Errorf("all counts = %v, want both cells", got)
+	}
+
+	h.Prune(recent)
+	if got := h.Counts(old); len(got) != 1 {
+		t.Errorf("counts after pruning = %v, want only the recent cell", got)
+	}
+}
+
+func TestHeatmap_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultHeatmapFile)
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	h := NewHeatmap()
+	h.Record("a", Point{X: 50, Y: 50}, day)
+	h.Record("a", Point{X: -150, Y: 250}, day)
+	if err := h.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadHeatmap(path)
+	if err != nil {
+		t.Fatalf("LoadHeatmap: %v", err)
+	}
+	if got, want := loaded.Counts(day), h.Counts(day); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded counts = %v, want %v", got, want)
+	}
+
+	if _, err := LoadHeatmap(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"days":{"2026-10-01":{"x":1}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHeatmap(path); err == nil {
+		t.Error("bad cell key accepted")
+	}
+}
+
+func TestStateTracker_HeatmapFromPositions(t *testing.T) {
+	st := NewStateTracker()
+	st.UpdateMap("a", &ValetudoMap{PixelSize: 5})
+	st.UpdatePosition("a", 30, 10, 0) // world (150, 50)
+
+	if got := st.Heatmap().Counts(time.Now().Add(-time.Hour)); got[HeatCell{1, 0}] != 1 {
+		t.Errorf("counts = %v, want a visit to cell (1, 0)", got)
+	}
+}
+
+func TestHeatGridLevels(t *testing.T) {
+	g := &HeatGrid{CellSize: 100, Counts: map[HeatCell]int{{0, 0}: 100, {1, 0}: 1, {2, 0}: 25}}
+	// Levels follow the square root: a quarter of the peak is halfway up
+	want := [heatLevels][]HeatCell{0: {{1, 0}}, 4: {{2, 0}}, 7: {{0, 0}}}
+	if got := g.levels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("levels = %v, want %v", got, want)
+	}
+
+	if c := heatColor(0); c.B != 255 || c.R != 0 {
+		t.Errorf("coldest color = %v, want blue", c)
+	}
+	if c := heatColor(heatLevels - 1); c.R != 255 || c.G != 0 {
+		t.Errorf("hottest color = %v, want red", c)
+	}
+}
@@ -0,0 +1,219 @@
+package mesh
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hint export formats.
+const (
+	HintsFormatText    = "text"
+	HintsFormatMapCard = "map-card"
+)
+
+// PlacementHint describes where one vacuum's map sits in the reference
+// frame, in terms other map viewers can reproduce.
+type PlacementHint struct {
+	VacuumID    string
+	DisplayName string
+	Reference   bool
+	RotationDeg float64 // rotation about the map origin, [0, 360); clockwise on screen since Valetudo's y axis points down
+	OffsetX     float64 // meters, applied after rotation; +x is right
+	OffsetY     float64 // meters, applied after rotation; +y is down
+	Scale       float64 // uniform scale factor (1 for rigid ICP transforms)
+	Transform   AffineMatrix
+
+	// CalibrationPoints pairs locations in the vacuum's own coordinates with
+	// the same locations in the reference frame, both in millimeters
+	CalibrationPoints []CalibrationPoint
+}
+
+// CalibrationPoint is one vacuum-to-map correspondence for map cards that
+// align robots from point pairs.
+type CalibrationPoint struct {
+	Vacuum Point
+	Map    Point
+}
+
+// hintCalibrationSpan is the distance (mm) between the calibration points
+// exported per vacuum. Three non-collinear points fix an affine transform.
+const hintCalibrationSpan = 1000.0
+
+// PlacementHints converts each vacuum's calibration transform into placement
+// hints, sorted with the reference vacuum first and then by ID. pixelSize is
+// the map grid resolution in mm (0 = Valetudo's default of 5mm); config
+// supplies display names and may be nil.
+func PlacementHints(cal *CalibrationData, config *Config, pixelSize float64) []PlacementHint {
+	if cal == nil {
+		return nil
+	}
+	if pixelSize <= 0 {
+		pixelSize = defaultPixelSize
+	}
+
+	hints := make([]PlacementHint, 0, len(cal.Vacuums))
+	for id, vc := range cal.Vacuums {
+		t := vc.Transform
+		h := PlacementHint{
+			VacuumID:    id,
+			Reference:   id == cal.ReferenceVacuum,
+			RotationDeg: roundTo(TransformRotation(t), 2),
+			OffsetX:     roundTo(t.Tx*pixelSize/1000, 3),
+			OffsetY:     roundTo(t.Ty*pixelSize/1000, 3),
+			Scale:       roundTo(math.Sqrt(math.Abs(t.Determinant())), 4),
+			Transform:   t,
+			DisplayName: config.DisplayName(id),
+		}
+		if h.RotationDeg == 360 {
+			h.RotationDeg = 0
+		}
+
+		for _, p := range []Point{{X: 0, Y: 0}, {X: hintCalibrationSpan, Y: 0}, {X: 0, Y: hintCalibrationSpan}} {
+			// Transforms operate on grid coordinates
+			tp := TransformPoint(Point{X: p.X / pixelSize, Y: p.Y / pixelSize}, t)
+			h.CalibrationPoints = append(h.CalibrationPoints, CalibrationPoint{
+				Vacuum: p,
+				Map:    Point{X: math.Round(tp.X * pixelSize), Y: math.Round(tp.Y * pixelSize)},
+			})
+		}
+		hints = append(hints, h)
+	}
+
+	sort.Slice(hints, func(i, j int) bool {
+		if hints[i].Reference != hints[j].Reference {
+			return hints[i].Reference
+		}
+		return hints[i].VacuumID < hints[j].VacuumID
+	})
+	return hints
+}
+
+// Instructions returns a one-paragraph description of how to place the
+// vacuum's map over the reference map.
+func (h PlacementHint) Instructions() string {
+	if h.Reference {
+		return "Reference map: display as-is; all other maps are aligned to it."
+	}
+
+	var steps []string
+	if h.RotationDeg != 0 {
+		steps = append(steps, fmt.Sprintf("rotate %s° clockwise about the map origin (0, 0)", formatHintNumber(h.RotationDeg)))
+	}
+	if h.Scale != 0 && math.Abs(h.Scale-1) > 0.001 {
+		steps = append(steps, fmt.Sprintf("scale by %s", formatHintNumber(h.Scale)))
+	}
+	if move := describeOffset(h.OffsetX, h.OffsetY); move != "" {
+		steps = append(steps, move)
+	}
+	if len(steps) == 0 {
+		return "Already aligned with the reference map: display as-is."
+	}
+	text := strings.Join(steps, ", then ")
+	return strings.ToUpper(text[:1]) + text[1:] + "."
+}
+
+// describeOffset phrases a translation in meters, e.g. "move 1.25 m right
+// and 0.4 m up".
+func describeOffset(x, y float64) string {
+	var parts []string
+	if x != 0 {
+		dir := "right"
+		if x < 0 {
+			dir = "left"
+		}
+		parts = append(parts, fmt.Sprintf("%s m %s", formatHintNumber(math.Abs(x)), dir))
+	}
+	if y != 0 {
+		dir := "down"
+		if y < 0 {
+			dir = "up"
+		}
+		parts = append(parts, fmt.Sprintf("%s m %s", formatHintNumber(math.Abs(y)), dir))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "move " + strings.Join(parts, " and ")
+}
+
+// WriteHintsText writes human-readable placement instructions.
+func WriteHintsText(w io.Writer, hints []PlacementHint) error {
+	for i, h := range hints {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		name := h.VacuumID
+		if h.DisplayName != "" && h.DisplayName != h.VacuumID {
+			name = fmt.Sprintf("%s (%s)", h.DisplayName, h.VacuumID)
+		}
+		lines := []string{
+			fmt.Sprintf("=== %s ===", name),
+			h.Instructions(),
+		}
+		if !h.Reference {
+			lines = append(lines,
+				fmt.Sprintf("  rotation: %s°", formatHintNumber(h.RotationDeg)),
+				fmt.Sprintf("  offset:   x=%s m, y=%s m", formatHintNumber(h.OffsetX), formatHintNumber(h.OffsetY)),
+			)
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteHintsMapCard writes a YAML snippet with calibration points for each
+// vacuum, in the calibration_source layout used by Home Assistant map cards.
+// "vacuum" points are in the robot's own coordinates and "map" points are
+// the same locations in the reference vacuum's coordinates, both in mm.
+func WriteHintsMapCard(w io.Writer, hints []PlacementHint) error {
+	var b strings.Builder
+	b.WriteString("# Generated by tudomesh: calibration points per vacuum.\n")
+	b.WriteString("# vacuum = the robot's own coordinates (mm); map = the same point in the\n")
+	b.WriteString("# reference map's coordinates (mm). Copy each block into that vacuum's card.\n")
+	for _, h := range hints {
+		fmt.Fprintf(&b, "%s:\n", yamlKey(h.VacuumID))
+		fmt.Fprintf(&b, "  # %s\n", h.Instructions())
+		b.WriteString("  calibration_source:\n")
+		b.WriteString("    calibration_points:\n")
+		for _, p := range h.CalibrationPoints {
+			fmt.Fprintf(&b, "      - vacuum: {x: %s, y: %s}\n", formatHintNumber(p.Vacuum.X), formatHintNumber(p.Vacuum.Y))
+			fmt.Fprintf(&b, "        map: {x: %s, y: %s}\n", formatHintNumber(p.Map.X), formatHintNumber(p.Map.Y))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlKey quotes a vacuum ID when it is not a plain YAML scalar.
+func yamlKey(s string) string {
+	for _, r := range s {
+		if !(r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// formatHintNumber formats a value without trailing zeros or negative zero.
+func formatHintNumber(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// roundTo rounds v to the given number of decimal places.
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
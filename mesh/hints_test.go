@@ -0,0 +1,120 @@
+package mesh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// PlacementHints
+// ---------------------------------------------------------------------------
+
+func hintsCalibration() *CalibrationData {
+	return &CalibrationData{
+		ReferenceVacuum: "ref",
+		Vacuums: map[string]VacuumCalibration{
+			"ref":   {Transform: Identity()},
+			"upper": {Transform: CreateRotationTranslation(90, 250, -80)},
+			"same":  {Transform: Identity()},
+		},
+	}
+}
+
+func TestPlacementHints(t *testing.T) {
+	config := &Config{Vacuums: []VacuumConfig{{ID: "upper", DisplayName: "Upstairs"}}}
+	hints := PlacementHints(hintsCalibration(), config, 5)
+
+	if len(hints) != 3 {
+		t.Fatalf("got %d hints, want 3", len(hints))
+	}
+	if hints[0].VacuumID != "ref" || !hints[0].Reference {
+		t.Errorf("first hint = %s, want the reference", hints[0].VacuumID)
+	}
+	if hints[1].VacuumID != "same" || hints[2].VacuumID != "upper" {
+		t.Errorf("hints not sorted by ID: %s, %s", hints[1].VacuumID, hints[2].VacuumID)
+	}
+
+	h := hints[2]
+	if h.RotationDeg != 90 || h.OffsetX != 1.25 || h.OffsetY != -0.4 || h.Scale != 1 {
+		t.Errorf("upper hint = rot %v offset (%v, %v) scale %v", h.RotationDeg, h.OffsetX, h.OffsetY, h.Scale)
+	}
+	if h.DisplayName != "Upstairs" {
+		t.Errorf("DisplayName = %q, want Upstairs", h.DisplayName)
+	}
+
+	// (1000, 0) mm is grid (200, 0); rotated 90° -> (0, 200), then offset
+	// (250, -80) -> (250, 120) grid = (1250, 600) mm
+	if got := h.CalibrationPoints[1]; got.Vacuum != (Point{X: 1000, Y: 0}) || got.Map != (Point{X: 1250, Y: 600}) {
+		t.Errorf("calibration point = %+v", got)
+	}
+}
+
+func TestPlacementHints_NilCalibration(t *testing.T) {
+	if hints := PlacementHints(nil, nil, 0); hints != nil {
+		t.Errorf("expected nil hints, got %v", hints)
+	}
+}
+
+func TestPlacementHint_Instructions(t *testing.T) {
+	tests := []struct {
+		hint PlacementHint
+		want string
+	}{
+		{PlacementHint{Reference: true}, "Reference map: display as-is; all other maps are aligned to it."},
+		{PlacementHint{Scale: 1}, "Already aligned with the reference map: display as-is."},
+		{PlacementHint{RotationDeg: 90, OffsetX: 1.25, OffsetY: -0.4, Scale: 1},
+			"Rotate 90° clockwise about the map origin (0, 0), then move 1.25 m right and 0.4 m up."},
+		{PlacementHint{OffsetX: -2, Scale: 1}, "Move 2 m left."},
+		{PlacementHint{Scale: 1.05}, "Scale by 1.05."},
+	}
+	for _, tt := range tests {
+		if got := tt.hint.Instructions(); got != tt.want {
+			t.Errorf("Instructions() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Writers
+// ---------------------------------------------------------------------------
+
+func TestWriteHintsText(t *testing.T) {
+	config := &Config{Vacuums: []VacuumConfig{{ID: "upper", DisplayName: "Upstairs"}}}
+	var buf bytes.Buffer
+	if err := WriteHintsText(&buf, PlacementHints(hintsCalibration(), config, 5)); err != nil {
+		t.Fatalf("WriteHintsText: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"=== ref ===",
+		"=== Upstairs (upper) ===",
+		"  rotation: 90°",
+		"  offset:   x=1.25 m, y=-0.4 m",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHintsMapCard(t *testing.T) {
+	cal := hintsCalibration()
+	cal.Vacuums["kitchen bot"] = VacuumCalibration{Transform: Identity()}
+
+	var buf bytes.Buffer
+	if err := WriteHintsMapCard(&buf, PlacementHints(cal, nil, 5)); err != nil {
+		t.Fatalf("WriteHintsMapCard: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"upper:\n",
+		"\"kitchen bot\":\n",
+		"  calibration_source:\n    calibration_points:\n",
+		"      - vacuum: {x: 1000, y: 0}\n        map: {x: 1250, y: 600}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// DefaultHoleConfidenceThreshold is the fraction of the vacuums observing a
+// floor that must also see an interior obstacle (a kitchen island, a
+// furniture cutout) for the unified floor to keep it as a hole.
+const DefaultHoleConfidenceThreshold = 0.5
+
+// observedHole is an interior ring of one vacuum's floor polygon.
+type observedHole struct {
+	ring     orb.Ring
+	area     float64
+	centroid orb.Point
+	observer string
+}
+
+// newObservedHole returns the hole for ring, or false when ring is too small
+// to enclose anything.
+func newObservedHole(ring orb.Ring, observer string) (observedHole, bool) {
+	if len(ring) < 4 {
+		return observedHole{}, false
+	}
+	centroid, area := planar.CentroidArea(ring)
+	if area == 0 {
+		return observedHole{}, false
+	}
+	return observedHole{ring: ring, area: math.Abs(area), centroid: centroid, observer: observer}, true
+}
+
+// polygonHoles returns the interior rings of poly. Vectorized floors may
+// start with degenerate rings, so the largest ring is taken as the outer
+// boundary and only rings centred inside it count as holes.
+func polygonHoles(poly orb.Polygon, observer string) []observedHole {
+	outer, outerArea := -1, 0.0
+	for i, ring := range poly {
+		if a := math.Abs(planar.Area(ring)); a > outerArea {
+			outer, outerArea = i, a
+		}
+	}
+	if outer < 0 {
+		return nil
+	}
+	var holes []observedHole
+	for i, ring := range poly {
+		if i == outer {
+			continue
+		}
+		if h, ok := newObservedHole(ring, observer); ok && planar.RingContains(poly[outer], h.centroid) {
+			holes = append(holes, h)
+		}
+	}
+	return holes
+}
+
+// sameHole reports whether two holes outline the same obstacle: the centroid
+// of either lies inside the other.
+func sameHole(a, b observedHole) bool {
+	return planar.RingContains(a.ring, b.centroid) || planar.RingContains(b.ring, a.centroid)
+}
+
+// consensusHoles returns the interior rings of a floor group that enough of
+// its observers agree on. Holes from different vacuums are matched when one
+// contains the other's centroid; a match seen by at least
+// DefaultHoleConfidenceThreshold of the group's observers and lying inside
+// outer is kept as its smallest ring, the area every observer agrees is
+// blocked. Entries without a vacuum ID count as separate observers.
+func consensusHoles(entries []*floorEntry, outer orb.Ring) []orb.Ring {
+	observers := make(map[string]struct{})
+	var holes []observedHole
+	for i, e := range entries {
+		observer := e.source.VacuumID
+		if observer == "" {
+			observer = fmt.Sprintf("#%d", i)
+		}
+		observers[observer] = struct{}{}
+
+		holes = append(holes, polygonHoles(orbPolygon(e.feature.Geometry), observer)...)
+	}
+	if len(holes) == 0 {
+		return nil
+	}
+
+	uf := newUnionFind(len(holes))
+	for i := range holes {
+		for j := i + 1; j < len(holes); j++ {
+			if sameHole(holes[i], holes[j]) {
+				uf.union(i, j)
+			}
+		}
+	}
+	groups := make(map[int][]observedHole)
+	for i, h := range holes {
+		root := uf.find(i)
+		groups[root] = append(groups[root], h)
+	}
+
+	var kept []observedHole
+	for _, group := range groups {
+		seenBy := make(map[string]struct{})
+		smallest := group[0]
+		for _, h := range group {
+			seenBy[h.observer] = struct{}{}
+			if h.area < smallest.area {
+				smallest = h
+			}
+		}
+		if float64(len(seenBy))/float64(len(observers)) < DefaultHoleConfidenceThreshold {
+			continue
+		}
+		if !planar.RingContains(outer, smallest.centroid) {
+			continue
+		}
+		kept = append(kept, smallest)
+	}
+
+	// Deterministic order for stable output
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].centroid[0] != kept[j].centroid[0] {
+			return kept[i].centroid[0] < kept[j].centroid[0]
+		}
+		return kept[i].centroid[1] < kept[j].centroid[1]
+	})
+	rings := make([]orb.Ring, len(kept))
+	for i, h := range kept {
+		rings[i] = h.ring
+	}
+	return rings
+}
+
+// blendHoles interpolates the interior rings of two polygons. Each new hole
+// is blended with the old hole outlining the same obstacle, if any; new
+// holes without a match are kept as they are and old holes the new polygon
+// no longer has are dropped.
+func blendHoles(oldHoles, newHoles []orb.Ring, weight float64) []orb.Ring {
+	var previous []observedHole
+	for _, ring := range oldHoles {
+		if h, ok := newObservedHole(ring, ""); ok {
+			previous = append(previous, h)
+		}
+	}
+
+	result := make([]orb.Ring, 0, len(newHoles))
+	used := make([]bool, len(previous))
+	for _, ring := range newHoles {
+		cur, ok := newObservedHole(ring, "")
+		if !ok {
+			result = append(result, ring)
+			continue
+		}
+		match := -1
+		for i, prev := range previous {
+			if !used[i] && sameHole(prev, cur) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			result = append(result, ring)
+			continue
+		}
+		used[match] = true
+		result = append(result, blendRings(previous[match].ring, ring, weight))
+	}
+	return result
+}
+
+// blendRings resamples two rings to the same number of points and
+// interpolates them, with weight (0-1) the share of newRing.
+func blendRings(oldRing, newRing orb.Ring, weight float64) orb.Ring {
+	n := max(len(oldRing), len(newRing))
+	if n > 200 {
+		n = 200
+	}
+
+	oldResampled := resampleRing(oldRing, n)
+	newResampled := resampleRing(newRing, n)
+
+	blended := make(orb.Ring, n)
+	oldWeight := 1.0 - weight
+	for i := 0; i < n; i++ {
+		blended[i] = orb.Point{
+			oldResampled[i][0]*oldWeight + newResampled[i][0]*weight,
+			oldResampled[i][1]*oldWeight + newResampled[i][1]*weight,
+		}
+	}
+	return blended
+}
@@ -0,0 +1,101 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// floorWithHole returns a floor polygon from 0 to size with a square hole
+// from h0 to h1, offset by dx.
+func floorWithHole(size, h0, h1, dx float64) *Feature {
+	rings := [][][2]float64{
+		{{dx, 0}, {size + dx, 0}, {size + dx, size}, {dx, size}, {dx, 0}},
+		{{h0 + dx, h0}, {h0 + dx, h1}, {h1 + dx, h1}, {h1 + dx, h0}, {h0 + dx, h0}},
+	}
+	return NewFeature(&Geometry{Type: GeometryPolygon, Coordinates: marshalCoordinate(rings)},
+		map[string]interface{}{"layerType": "floor"})
+}
+
+func plainFloor(size float64) *Feature {
+	return makePolygonFeature([][2]float64{{0, 0}, {size, 0}, {size, size}, {0, size}}, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Holes through UnifyFloors
+// ---------------------------------------------------------------------------
+
+func TestUnifyFloors_HoleSeenByAllKept(t *testing.T) {
+	features := []*Feature{
+		floorWithHole(4000, 1500, 2500, 0),
+		floorWithHole(4000, 1400, 2600, 30),
+	}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1)}
+
+	unified := UnifyFloors(features, sources, 2)
+	if len(unified) != 1 {
+		t.Fatalf("got %d floors, want 1", len(unified))
+	}
+	poly := orbPolygon(unified[0].Geometry)
+	if len(poly) != 2 {
+		t.Fatalf("got %d rings, want outer ring and one hole", len(poly))
+	}
+	// The smaller observation is kept: 1000x1000 mm
+	if area := planar.Area(poly[1]); area != 1e6 && area != -1e6 {
+		t.Errorf("hole area = %v, want 1e6", area)
+	}
+}
+
+func TestUnifyFloors_HoleSeenByFewDropped(t *testing.T) {
+	features := []*Feature{
+		floorWithHole(4000, 1500, 2500, 0),
+		plainFloor(4000),
+		plainFloor(4000),
+	}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1), makeSource("c", 1)}
+
+	unified := UnifyFloors(features, sources, 3)
+	if len(unified) != 1 {
+		t.Fatalf("got %d floors, want 1", len(unified))
+	}
+	if rings := len(orbPolygon(unified[0].Geometry)); rings != 1 {
+		t.Errorf("got %d rings, want the hole seen by 1 of 3 vacuums dropped", rings)
+	}
+}
+
+func TestPolygonHoles_DegenerateFirstRing(t *testing.T) {
+	// Vectorized floors can start with a zero-area ring
+	poly := orb.Polygon{
+		{{0, 0}, {1, 1}, {0, 0}},
+		{{0, 0}, {100, 0}, {100, 100}, {0, 100}, {0, 0}},
+		{{40, 40}, {40, 60}, {60, 60}, {60, 40}, {40, 40}},
+		{{200, 200}, {210, 200}, {210, 210}, {200, 210}, {200, 200}}, // separate island
+	}
+	holes := polygonHoles(poly, "a")
+	if len(holes) != 1 || holes[0].centroid != (orb.Point{50, 50}) {
+		t.Errorf("holes = %+v, want the one centred at (50, 50)", holes)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// blendHoles
+// ---------------------------------------------------------------------------
+
+func TestBlendPolygons_Holes(t *testing.T) {
+	old := orbPolygon(floorWithHole(1000, 400, 600, 0).Geometry)
+	cur := orbPolygon(floorWithHole(1000, 400, 600, 100).Geometry)
+	cur = append(cur, orb.Ring{{800, 800}, {800, 900}, {900, 900}, {900, 800}, {800, 800}})
+
+	blended := orbPolygon(blendPolygons(polygonToGeometry(old), polygonToGeometry(cur), 0.5))
+	if len(blended) != 3 {
+		t.Fatalf("got %d rings, want outer ring and two holes", len(blended))
+	}
+	// The matched hole moves halfway, the new one is kept as is
+	if c, _ := planar.CentroidArea(blended[1]); c[0] < 545 || c[0] > 555 {
+		t.Errorf("blended hole centroid x = %v, want ~550", c[0])
+	}
+	if c, _ := planar.CentroidArea(blended[2]); c != (orb.Point{850, 850}) {
+		t.Errorf("new hole centroid = %v, want (850, 850)", c)
+	}
+}
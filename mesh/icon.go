@@ -0,0 +1,219 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tdewolff/canvas"
+	"golang.org/x/image/draw"
+)
+
+// Built-in marker shapes for the vacuum icon config field.
+const (
+	IconCircle   = "circle"
+	IconSquare   = "square"
+	IconTriangle = "triangle"
+	IconDiamond  = "diamond"
+	IconVacuum   = "vacuum"
+)
+
+// builtinIcons lists the shapes accepted in place of a PNG path.
+var builtinIcons = []string{IconCircle, IconSquare, IconTriangle, IconDiamond, IconVacuum}
+
+// MarkerIcon is a robot marker: either a built-in shape drawn in the vacuum's
+// color or a PNG image drawn as-is.
+type MarkerIcon struct {
+	Shape string      // built-in shape name; empty when Image is set
+	Image image.Image // decoded PNG icon
+}
+
+// IsBuiltinIcon reports whether spec names a built-in marker shape.
+func IsBuiltinIcon(spec string) bool {
+	for _, name := range builtinIcons {
+		if spec == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateIconSpec checks that an icon config value is a built-in shape or a
+// PNG path. It does not open the file.
+func ValidateIconSpec(spec string) error {
+	if spec == "" || IsBuiltinIcon(spec) || strings.EqualFold(filepath.Ext(spec), ".png") {
+		return nil
+	}
+	return fmt.Errorf("icon %q must be a PNG path or one of %s", spec, strings.Join(builtinIcons, ", "))
+}
+
+// LoadMarkerIcon resolves an icon config value. Built-in shape names are
+// returned directly; anything else is read as a PNG file.
+func LoadMarkerIcon(spec string) (*MarkerIcon, error) {
+	if err := ValidateIconSpec(spec); err != nil {
+		return nil, err
+	}
+	if IsBuiltinIcon(spec) {
+		return &MarkerIcon{Shape: spec}, nil
+	}
+
+	f, err := os.Open(spec)
+	if err != nil {
+		return nil, fmt.Errorf("opening icon: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding icon %s: %w", spec, err)
+	}
+	return &MarkerIcon{Image: img}, nil
+}
+
+// LoadMarkerIcons loads the icons of all vacuums that configure one. Icons
+// that fail to load are skipped and reported in the returned error, so
+// callers can fall back to the default marker for those vacuums.
+func LoadMarkerIcons(config *Config) (map[string]*MarkerIcon, error) {
+	icons := make(map[string]*MarkerIcon)
+	if config == nil {
+		return icons, nil
+	}
+
+	var failed []string
+	for _, vc := range config.Vacuums {
+		if vc.Icon == "" {
+			continue
+		}
+		icon, err := LoadMarkerIcon(vc.Icon)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", vc.ID, err))
+			continue
+		}
+		icons[vc.ID] = icon
+	}
+
+	if len(failed) > 0 {
+		return icons, fmt.Errorf("loading vacuum icons: %s", strings.Join(failed, "; "))
+	}
+	return icons, nil
+}
+
+// drawMarkerIcon draws icon centered on (cx, cy) within a size x size box.
+// angleDeg orients the vacuum shape; other shapes are drawn upright.
+func drawMarkerIcon(img *image.RGBA, icon *MarkerIcon, cx, cy, size, angleDeg float64, c color.RGBA) {
+	if icon.Image != nil {
+		// Images snap to whole pixels
+		x0, y0 := int(math.Round(cx-size/2)), int(math.Round(cy-size/2))
+		side := int(math.Round(size))
+		dst := image.Rect(x0, y0, x0+side, y0+side)
+		draw.CatmullRom.Scale(img, dst, icon.Image, icon.Image.Bounds(), draw.Over, nil)
+		return
+	}
+
+	switch icon.Shape {
+	case IconSquare:
+		drawSquare(img, cx, cy, size, c)
+	case IconTriangle:
+		drawTriangle(img, cx, cy, size, c)
+	case IconDiamond:
+		drawDiamond(img, cx, cy, size, c)
+	case IconVacuum:
+		drawVacuumIcon(img, cx, cy, size, angleDeg, c)
+	default:
+		drawCircle(img, cx, cy, size/2, c)
+	}
+}
+
+// drawDiamond draws an antialiased filled square rotated 45 degrees
+func drawDiamond(img *image.RGBA, cx, cy, size float64, c color.RGBA) {
+	half := size / 2
+	fillPolygons(img, c, []Point{
+		{X: cx, Y: cy - half},
+		{X: cx + half, Y: cy},
+		{X: cx, Y: cy + half},
+		{X: cx - half, Y: cy},
+	})
+}
+
+// markerPath returns the outline of a built-in shape centered on the origin
+// for vector output.
+func markerPath(shape string, radius float64) *canvas.Path {
+	switch shape {
+	case IconSquare:
+		return canvas.Rectangle(2*radius, 2*radius).Translate(-radius, -radius)
+	case IconTriangle:
+		return canvas.RegularPolygon(3, radius, true)
+	case IconDiamond:
+		return canvas.RegularPolygon(4, radius, true)
+	default:
+		return canvas.Circle(radius)
+	}
+}
+
+// headingWedgePath returns the arrowhead showing which way a robot faces,
+// pointing along +X from the origin: its base sits inside a marker of the
+// given radius and its tip reaches length.
+func headingWedgePath(radius, length float64) *canvas.Path {
+	p := &canvas.Path{}
+	p.MoveTo(length, 0)
+	p.LineTo(radius*0.5, radius*0.6)
+	p.LineTo(radius*0.75, 0)
+	p.LineTo(radius*0.5, -radius*0.6)
+	p.Close()
+	return p
+}
+
+// chargerIconPaths returns the charger marker centered on the origin and
+// size across: a rounded dock and the lightning bolt drawn over it, so a
+// charger cannot be mistaken for a robot.
+func chargerIconPaths(size float64) (dock, bolt *canvas.Path) {
+	h := size / 2
+	dock = canvas.RoundedRectangle(size, size, size*0.2).Translate(-h, -h)
+	bolt = &canvas.Path{}
+	for i, pt := range [][2]float64{{0.15, -0.7}, {-0.4, 0.1}, {-0.02, 0.1}, {-0.15, 0.7}, {0.4, -0.1}, {0.02, -0.1}} {
+		if i == 0 {
+			bolt.MoveTo(pt[0]*h, pt[1]*h)
+		} else {
+			bolt.LineTo(pt[0]*h, pt[1]*h)
+		}
+	}
+	bolt.Close()
+	return dock, bolt
+}
+
+// renderCharger draws the charger icon size across centered on (cx, cy)
+// in the vacuum's color.
+func renderCharger(renderer canvasRenderer, cx, cy, size float64, c color.RGBA) {
+	dock, bolt := chargerIconPaths(size)
+
+	dockStyle := canvas.DefaultStyle
+	dockStyle.Fill = canvas.Paint{Color: c}
+	dockStyle.Stroke = canvas.Paint{Color: canvas.Black}
+	dockStyle.StrokeWidth = size * 0.05
+	dockStyle.StrokeJoiner = canvas.RoundJoiner{}
+	renderer.RenderPath(dock.Translate(cx, cy), dockStyle, canvas.Identity)
+
+	boltStyle := canvas.DefaultStyle
+	boltStyle.Fill = canvas.Paint{Color: canvas.White}
+	boltStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+	renderer.RenderPath(bolt.Translate(cx, cy), boltStyle, canvas.Identity)
+}
+
+// renderMarkerImage draws a PNG icon into a vector canvas, scaled to fit a
+// square of the given diameter centered on (cx, cy).
+func renderMarkerImage(renderer canvasRenderer, img image.Image, cx, cy, diameter float64) {
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return
+	}
+	scale := diameter / math.Max(float64(size.X), float64(size.Y))
+	m := canvas.Identity.
+		Translate(cx-float64(size.X)*scale/2, cy-float64(size.Y)*scale/2).
+		Scale(scale, scale)
+	renderer.RenderImage(img, m)
+}
@@ -0,0 +1,170 @@
+package mesh
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestIcon writes a solid-color PNG to dir and returns its path.
+func writeTestIcon(t *testing.T, dir string, c color.RGBA) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	path := filepath.Join(dir, "icon.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ---------------------------------------------------------------------------
+// Loading
+// ---------------------------------------------------------------------------
+
+func TestValidateIconSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"", false},
+		{"circle", false},
+		{"vacuum", false},
+		{"icons/roborock.png", false},
+		{"icons/ROBOROCK.PNG", false},
+		{"hexagon", true},
+		{"icons/roborock.jpg", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateIconSpec(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateIconSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadMarkerIcons(t *testing.T) {
+	path := writeTestIcon(t, t.TempDir(), color.RGBA{255, 0, 0, 255})
+	cfg := &Config{Vacuums: []VacuumConfig{
+		{ID: "a", Icon: "diamond"},
+		{ID: "b", Icon: path},
+		{ID: "c", Icon: filepath.Join(t.TempDir(), "missing.png")},
+		{ID: "d"},
+	}}
+
+	icons, err := LoadMarkerIcons(cfg)
+	if err == nil || !strings.Contains(err.Error(), "c:") {
+		t.Errorf("expected error naming vacuum c, got %v", err)
+	}
+	if icons["a"] == nil || icons["a"].Shape != IconDiamond {
+		t.Errorf("icon a = %+v, want diamond shape", icons["a"])
+	}
+	if icons["b"] == nil || icons["b"].Image == nil {
+		t.Errorf("icon b = %+v, want decoded image", icons["b"])
+	}
+	if _, ok := icons["c"]; ok {
+		t.Error("failed icon should be skipped")
+	}
+	if _, ok := icons["d"]; ok {
+		t.Error("vacuum without icon should have no entry")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Raster drawing
+// ---------------------------------------------------------------------------
+
+func TestDrawMarkerIcon_Image(t *testing.T) {
+	icon, err := LoadMarkerIcon(writeTestIcon(t, t.TempDir(), color.RGBA{0, 255, 0, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	drawMarkerIcon(img, icon, 20, 20, 16, 0, color.RGBA{255, 0, 0, 255})
+
+	if got := img.RGBAAt(20, 20); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("center pixel = %v, want icon color (green)", got)
+	}
+	if got := img.RGBAAt(2, 2); got != (color.RGBA{}) {
+		t.Errorf("pixel outside marker = %v, want untouched", got)
+	}
+}
+
+func TestDrawMarkerIcon_Diamond(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	drawMarkerIcon(img, &MarkerIcon{Shape: IconDiamond}, 20.5, 20.5, 10, 0, red)
+
+	if img.RGBAAt(20, 16) != red || img.RGBAAt(24, 20) != red {
+		t.Error("diamond tips not drawn")
+	}
+	if img.RGBAAt(24, 24) == red {
+		t.Error("diamond corner region should be empty")
+	}
+}
+
+func TestRender_UsesConfiguredIcon(t *testing.T) {
+	m := createMockMap([]int{0, 0, 100, 100}, nil)
+	m.Entities = []MapEntity{{Type: "robot_position", Points: []int{50, 50}}}
+	maps := map[string]*ValetudoMap{"vac1": m}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	renderer := NewCompositeRenderer(maps, transforms, "vac1")
+	renderer.Padding = 20
+	renderer.Legend.Hidden = true
+	renderer.Icons = map[string]*MarkerIcon{"vac1": {Shape: IconSquare}}
+
+	img := renderer.Render()
+	robot := color.RGBA(renderer.Colors["vac1"].Robot)
+
+	// The default circle (radius 6) leaves its bounding-box corners empty;
+	// a 12px square fills them.
+	cx, cy := 50+renderer.Padding, 50+renderer.Padding
+	if img.RGBAAt(cx+5, cy+5) != robot {
+		t.Errorf("square marker corner = %v, want robot color", img.RGBAAt(cx+5, cy+5))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Vector drawing
+// ---------------------------------------------------------------------------
+
+func TestRenderLiveToSVG_ImageIcon(t *testing.T) {
+	m := &ValetudoMap{
+		PixelSize: 5,
+		MetaData:  MapMetaData{TotalLayerArea: 100},
+		Layers:    []MapLayer{{Type: "floor", Pixels: []int{0, 0, 10, 10, 20, 20}}},
+	}
+	r := NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+
+	icon, err := LoadMarkerIcon(writeTestIcon(t, t.TempDir(), color.RGBA{0, 0, 255, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Icons = map[string]*MarkerIcon{"vac1": icon}
+
+	positions := map[string]*LivePosition{
+		"vac1": {VacuumID: "vac1", X: 10, Y: 10, Timestamp: time.Now(), Color: "#FF0000"},
+	}
+	var buf bytes.Buffer
+	if err := r.RenderLiveToSVG(&buf, positions); err != nil {
+		t.Fatalf("RenderLiveToSVG: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<image") {
+		t.Error("expected embedded <image> for PNG icon")
+	}
+}
@@ -7,15 +7,36 @@ import (
 	"time"
 )
 
+// ICPMetric selects the alignment error ICP minimizes.
+type ICPMetric string
+
+const (
+	// MetricPointToPoint minimizes the distance between matched points
+	MetricPointToPoint ICPMetric = "point-to-point"
+	// MetricPointToPlane minimizes the distance along the target's wall
+	// normals, so matches do not drag the source along long straight walls
+	MetricPointToPlane ICPMetric = "point-to-plane"
+)
+
 // ICPConfig holds configuration for the ICP algorithm
 type ICPConfig struct {
-	MaxIterations     int        // Maximum number of iterations
-	ConvergenceThresh float64    // Stop when error improvement is below this
-	MaxCorrespondDist float64    // Maximum distance for point correspondence
-	SamplePoints      int        // Number of feature points to use
-	OutlierPercentile float64    // Reject correspondences above this percentile (0-1)
-	TryRotations      bool       // Try multiple initial rotations (0°, 90°, 180°, 270°)
-	RNG               *rand.Rand // Random number generator for deterministic behavior
+	MaxIterations     int             // Maximum number of iterations
+	ConvergenceThresh float64         // Stop when error improvement is below this
+	MaxCorrespondDist float64         // Maximum distance for point correspondence
+	SamplePoints      int             // Number of feature points to use
+	OutlierPercentile float64         // Reject correspondences above this percentile (0-1)
+	TryRotations      bool            // Try multiple initial rotations (0°, 90°, 180°, 270°)
+	PreAlign          bool            // With TryRotations, estimate the rotation from wall directions first and only sweep when unsure
+	Metric            ICPMetric       // Error metric (default point-to-point)
+	Loss              RobustLoss      // Robust kernel weighting correspondences by residual
+	LossScale         float64         // Kernel scale in millimeters, converted with the target map's pixel size
+	MaxDuration       time.Duration   // Time budget for a whole alignment, sweep and refinement included (0 = unlimited)
+	Weights           *FeatureWeights // Share of each feature class in the sampled points (nil = built-in mix of SampleFeatures)
+	Init              ICPInit         // How the starting translation of each rotation is found (default sampled)
+	RNG               *rand.Rand      // Random number generator for deterministic behavior
+
+	pixelSize float64   // target grid resolution (mm per pixel); 0 means defaultPixelSize
+	deadline  time.Time // when MaxDuration runs out; zero means no limit
 }
 
 // DefaultICPConfig returns sensible defaults for ICP
@@ -27,10 +48,88 @@ func DefaultICPConfig() ICPConfig {
 		SamplePoints:      300,    // Use up to 300 feature points
 		OutlierPercentile: 0.8,    // Keep 80% closest correspondences
 		TryRotations:      true,   // Try all 4 rotations
+		PreAlign:          true,   // ...unless the wall directions settle it
+		Metric:            MetricPointToPoint,
+		Loss:              LossHuber,        // Down-weight spurious wall pixels
+		LossScale:         DefaultLossScale, // 50mm
+		Init:              InitSampled,
 		RNG:               rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
+// ICPConfigFromConfig returns DefaultICPConfig with the time and iteration
+// budget from config applied.
+func ICPConfigFromConfig(config *Config) ICPConfig {
+	c := DefaultICPConfig()
+	if config == nil {
+		return c
+	}
+	if d, err := config.ICP.Duration(); err == nil {
+		c.MaxDuration = d
+	}
+	if config.ICP.MaxIterations > 0 {
+		c.MaxIterations = config.ICP.MaxIterations
+	}
+	c.Weights = config.ICP.Weights.Weights()
+	if config.ICP.Init != "" {
+		c.Init = ICPInit(config.ICP.Init)
+	}
+	return c
+}
+
+// features extracts m's features, with the room and material borders when
+// Weights uses them.
+func (c ICPConfig) features(m *ValetudoMap) FeatureSet {
+	fs := ExtractFeatures(m)
+	if c.Weights != nil && c.Weights.semantic() {
+		fs.SegmentBorderPoints, fs.MaterialBorderPoints = extractBorders(m)
+	}
+	return fs
+}
+
+// sample reduces fs to the SamplePoints ICP fits and scores, mixed by
+// Weights.
+func (c ICPConfig) sample(fs FeatureSet) []Point {
+	if c.Weights == nil {
+		return SampleFeatures(fs, c.SamplePoints)
+	}
+	return SampleFeaturesWeighted(fs, c.SamplePoints, *c.Weights)
+}
+
+// lossScalePixels returns LossScale in grid units.
+func (c ICPConfig) lossScalePixels() float64 {
+	pixelSize := c.pixelSize
+	if pixelSize <= 0 {
+		pixelSize = defaultPixelSize
+	}
+	return c.LossScale / pixelSize
+}
+
+// withPixelSize returns c set up for aligning onto target. It also starts
+// the MaxDuration clock.
+func (c ICPConfig) withPixelSize(target *ValetudoMap) ICPConfig {
+	if target != nil && target.PixelSize > 0 {
+		c.pixelSize = float64(target.PixelSize)
+	}
+	if c.MaxDuration > 0 && c.deadline.IsZero() {
+		c.deadline = time.Now().Add(c.MaxDuration)
+	}
+	return c
+}
+
+// expired reports whether the MaxDuration budget has run out.
+func (c ICPConfig) expired() bool {
+	return !c.deadline.IsZero() && time.Now().After(c.deadline)
+}
+
+// passIterations caps the iterations of one ICP pass at MaxIterations.
+func (c ICPConfig) passIterations(n int) int {
+	if c.MaxIterations > 0 && c.MaxIterations < n {
+		return c.MaxIterations
+	}
+	return n
+}
+
 // ICPResult contains the result of ICP alignment
 type ICPResult struct {
 	Transform       AffineMatrix // The computed transformation
@@ -40,6 +139,17 @@ type ICPResult struct {
 	Iterations      int          // Number of iterations performed
 	Converged       bool         // Whether the algorithm converged
 	InitialRotation float64      // The initial rotation that worked best (degrees)
+	TimedOut        bool         // MaxDuration ran out; this is the best result found by then
+
+	// Covariance of the transform's rotation and translation, estimated from
+	// the final correspondences; nil when it could not be estimated
+	Covariance *TransformCovariance
+
+	// Alternatives are other rotations of the sweep that aligned about as
+	// well, unrefined; the rotation is ambiguous when there are any.
+	// Disambiguated is set when there were, but room borders picked this one
+	Alternatives  []RotationHypothesis
+	Disambiguated bool
 }
 
 // RotationErrors stores the error for each rotation tried (for debugging)
@@ -48,12 +158,13 @@ var RotationErrors map[float64]float64
 // AlignMapsWithRotationHint runs ICP alignment with a preferred rotation hint as starting point
 // This allows using rotation hints from config or CLI while still running full ICP refinement
 func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, rotationHint float64) ICPResult {
-	srcFeatures := ExtractFeatures(source)
-	tgtFeatures := ExtractFeatures(target)
+	config = config.withPixelSize(target)
+	srcFeatures := config.features(source)
+	tgtFeatures := config.features(target)
 
 	// Sample features for ICP
-	sourcePoints := SampleFeatures(srcFeatures, config.SamplePoints)
-	targetPoints := SampleFeatures(tgtFeatures, config.SamplePoints)
+	sourcePoints := config.sample(srcFeatures)
+	targetPoints := config.sample(tgtFeatures)
 
 	if len(sourcePoints) < 3 || len(targetPoints) < 3 {
 		return ICPResult{
@@ -64,7 +175,10 @@ func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, ro
 	}
 
 	// Build initial transform from rotation hint
-	initialTransform := buildInitialTransform(srcFeatures, tgtFeatures, rotationHint, config.RNG)
+	initialTransform, ok := config.phaseInitial(srcFeatures, tgtFeatures, rotationHint)
+	if !ok {
+		initialTransform = buildInitialTransform(srcFeatures, tgtFeatures, rotationHint, config.RNG)
+	}
 
 	// Run full multi-scale ICP refinement starting from the hint
 	result := runMultiScaleICP(sourcePoints, targetPoints, initialTransform, config)
@@ -77,7 +191,7 @@ func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, ro
 	result.InlierFraction = frac
 
 	// Wall-only refinement pass (same as AlignMaps)
-	if result.Score > 0.05 {
+	if result.Score > 0.05 && !config.expired() {
 		sourceWalls := srcFeatures.WallPoints
 		targetWalls := tgtFeatures.WallPoints
 
@@ -86,7 +200,7 @@ func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, ro
 
 		if len(sourceWalls) > 10 && len(targetWalls) > 10 {
 			refineConfig := config
-			refineConfig.MaxIterations = 50
+			refineConfig.MaxIterations = config.passIterations(50)
 			refineConfig.ConvergenceThresh = 0.5
 			refineConfig.MaxCorrespondDist = 200.0
 
@@ -97,10 +211,7 @@ func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, ro
 			result.Error = refinedResult.Error
 
 			// Micro-rotation and translation adjustments
-			result.Transform = FineTuneRotation(sourceWalls, targetWalls, result.Transform, tgtFeatures.Centroid, 2.0, 0.25)
-			result.Transform = FineTuneTranslation(sourceWalls, targetWalls, result.Transform, 2.0, 0.25)
-			result.Transform = FineTuneRotation(sourceWalls, targetWalls, result.Transform, tgtFeatures.Centroid, 1.0, 0.1)
-			result.Transform = FineTuneTranslation(sourceWalls, targetWalls, result.Transform, 0.5, 0.1)
+			result.Transform = fineTune(sourceWalls, targetWalls, result.Transform, tgtFeatures.Centroid, config)
 
 			// Recalculate final score
 			transformed := TransformPoints(sourcePoints, result.Transform)
@@ -110,12 +221,15 @@ func AlignMapsWithRotationHint(source, target *ValetudoMap, config ICPConfig, ro
 		}
 	}
 
+	result.Covariance = alignmentCovariance(srcFeatures, tgtFeatures, sourcePoints, targetPoints, result.Transform, config)
+	result.TimedOut = config.expired()
 	return result
 }
 
 // AlignMaps computes the affine transform to align source map to target map
 // Tries multiple initial rotations and picks the best result
 func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
+	config = config.withPixelSize(target)
 	bestResult := ICPResult{
 		Transform: Identity(),
 		Error:     math.MaxFloat64,
@@ -125,27 +239,50 @@ func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
 	RotationErrors = make(map[float64]float64)
 
 	// Extract features from both maps
-	sourceFeatures := ExtractFeatures(source)
-	targetFeatures := ExtractFeatures(target)
+	sourceFeatures := config.features(source)
+	targetFeatures := config.features(target)
 
 	// Sample features to limit computation
-	sourcePoints := SampleFeatures(sourceFeatures, config.SamplePoints)
-	targetPoints := SampleFeatures(targetFeatures, config.SamplePoints)
+	sourcePoints := config.sample(sourceFeatures)
+	targetPoints := config.sample(targetFeatures)
 
 	if len(sourcePoints) < 3 || len(targetPoints) < 3 {
 		return bestResult
 	}
 
-	// Rotations to try (in degrees)
+	// Rotations to try (in degrees). Pre-alignment narrows the sweep to one
+	// hypothesis when the wall directions are unambiguous, and turns it to
+	// match non-cardinal mounting when only the quarter turn is unclear.
 	rotations := []float64{0}
+	var sweep []float64
 	if config.TryRotations {
 		rotations = []float64{0, 90, 180, 270}
+		if config.PreAlign {
+			est := estimateRotation(source, target, sourceFeatures, targetFeatures)
+			if est.AngleTrusted() {
+				rotations = []float64{est.Offset, est.Offset + 90, est.Offset + 180, est.Offset + 270}
+			}
+			if est.Confident() {
+				rotations, sweep = []float64{est.Rotation}, rotations
+			}
+		}
 	}
 
-	// Try each initial rotation
-	for _, rotDeg := range rotations {
+	var tried []ICPResult
+
+	// Try each initial rotation; if a pre-aligned hypothesis aligns poorly,
+	// fall back to the sweep. Once the time budget is spent the best
+	// rotation so far is kept.
+	for i := 0; i < len(rotations); i++ {
+		if i > 0 && config.expired() {
+			break
+		}
+		rotDeg := rotations[i]
 		// Use robust initialization to find best translation for this rotation
-		initialTransform := findBestInitialAlignment(sourcePoints, targetPoints, sourceFeatures.Centroid, targetFeatures.Centroid, rotDeg, config.RNG)
+		initialTransform, ok := config.phaseInitial(sourceFeatures, targetFeatures, rotDeg)
+		if !ok {
+			initialTransform = findBestInitialAlignment(sourcePoints, targetPoints, sourceFeatures.WallCorners, targetFeatures.WallCorners, sourceFeatures.Centroid, targetFeatures.Centroid, rotDeg, config.RNG)
+		}
 
 		// Use multi-scale ICP for better coarse-to-fine convergence
 		result := runMultiScaleICP(sourcePoints, targetPoints, initialTransform, config)
@@ -160,15 +297,36 @@ func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
 		RotationErrors[rotDeg] = result.Error // Keep logging raw error for backward compat/debug
 
 		// Pick best by Score (Inlier-based), not raw Error (Average distance)
+		tried = append(tried, result)
 		if result.Score > bestResult.Score {
 			bestResult = result
 		}
+
+		if i == len(rotations)-1 && sweep != nil && bestResult.Score < preAlignMinScore {
+			for _, r := range sweep {
+				if r != rotDeg {
+					rotations = append(rotations, r)
+				}
+			}
+			sweep = nil
+		}
+	}
+
+	// Rotations that align about as well as the best, as in a symmetric
+	// room, are kept as alternatives unless room borders tell them apart
+	alternatives := alternativeRotations(tried, bestResult)
+	if len(alternatives) > 0 {
+		hypotheses := append([]ICPResult{bestResult}, alternatives...)
+		if i, ok := resolveBySegments(source, target, hypotheses); ok {
+			bestResult, alternatives = hypotheses[i], nil
+			bestResult.Disambiguated = true
+		}
 	}
 
 	// Refinement step: Wall-only alignment
 	// Floor coverage varies (robot path), but walls are static structure.
 	// Asymmetric floor coverage can bias the alignment. Refine using only wall points to "snap" the structure.
-	if bestResult.Score > 0.05 { // If we have a plausible meaningful overlap
+	if bestResult.Score > 0.05 && !config.expired() { // If we have a plausible meaningful overlap
 		sourceWalls := sourceFeatures.WallPoints
 		targetWalls := targetFeatures.WallPoints
 
@@ -179,7 +337,7 @@ func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
 		if len(sourceWalls) > 10 && len(targetWalls) > 10 {
 			// Tighter convergence for refinement
 			refineConfig := config
-			refineConfig.MaxIterations = 50
+			refineConfig.MaxIterations = config.passIterations(50)
 			refineConfig.ConvergenceThresh = 0.5   // Sub-pixel precision
 			refineConfig.MaxCorrespondDist = 200.0 // Don't drift too far from the coarse lock
 
@@ -192,24 +350,7 @@ func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
 			bestResult.Iterations += refinedResult.Iterations
 			bestResult.Error = refinedResult.Error
 
-			// Micro-rotation adjustment: ICP may not perfectly lock rotation
-			// Try small angle adjustments (±2° in 0.25° steps) to find optimal rotation
-			// Use target centroid as rotation pivot
-			bestResult.Transform = FineTuneRotation(sourceWalls, targetWalls, bestResult.Transform, targetFeatures.Centroid, 2.0, 0.25)
-
-			// Final Nudge: Hill-climbing optimization for "snapping"
-			// ICP minimizes sum of squared errors, which can settle in local minima (average fits).
-			// We want to maximize strict overlap/proximity (snapping).
-			// Nudge the translation slightly to find the peak InlierScore.
-			// Reduced min step from 0.5 to 0.25 for sub-half-pixel precision
-			bestResult.Transform = FineTuneTranslation(sourceWalls, targetWalls, bestResult.Transform, 2.0, 0.25)
-
-			// Second pass of rotation fine-tuning after translation adjustment
-			// Sometimes translation changes make a slight rotation adjustment beneficial
-			bestResult.Transform = FineTuneRotation(sourceWalls, targetWalls, bestResult.Transform, targetFeatures.Centroid, 1.0, 0.1)
-
-			// Final ultra-fine translation nudge
-			bestResult.Transform = FineTuneTranslation(sourceWalls, targetWalls, bestResult.Transform, 0.5, 0.1)
+			bestResult.Transform = fineTune(sourceWalls, targetWalls, bestResult.Transform, targetFeatures.Centroid, config)
 
 			// Recalculate robust score against standard points to ensure global consistency
 			transformed := TransformPoints(sourcePoints, bestResult.Transform)
@@ -219,9 +360,52 @@ func AlignMaps(source, target *ValetudoMap, config ICPConfig) ICPResult {
 		}
 	}
 
+	bestResult.Alternatives = rotationHypotheses(alternatives)
+	if bestResult.Score > 0 {
+		bestResult.Covariance = alignmentCovariance(sourceFeatures, targetFeatures, sourcePoints, targetPoints, bestResult.Transform, config)
+	}
+	bestResult.TimedOut = config.expired()
 	return bestResult
 }
 
+// fineTune snaps a refined transform onto the target walls with alternating
+// rotation and translation searches around centroid. It stops early when
+// the config's time budget runs out.
+func fineTune(sourceWalls, targetWalls []Point, transform AffineMatrix, centroid Point, config ICPConfig) AffineMatrix {
+	steps := []func(AffineMatrix) AffineMatrix{
+		// Micro-rotation adjustment: ICP may not perfectly lock rotation
+		// Try small angle adjustments (±2° in 0.25° steps) to find optimal rotation
+		// Use target centroid as rotation pivot
+		func(t AffineMatrix) AffineMatrix {
+			return FineTuneRotation(sourceWalls, targetWalls, t, centroid, 2.0, 0.25)
+		},
+		// Final Nudge: Hill-climbing optimization for "snapping"
+		// ICP minimizes sum of squared errors, which can settle in local minima (average fits).
+		// We want to maximize strict overlap/proximity (snapping).
+		// Nudge the translation slightly to find the peak InlierScore.
+		// Reduced min step from 0.5 to 0.25 for sub-half-pixel precision
+		func(t AffineMatrix) AffineMatrix {
+			return FineTuneTranslation(sourceWalls, targetWalls, t, 2.0, 0.25)
+		},
+		// Second pass of rotation fine-tuning after translation adjustment
+		// Sometimes translation changes make a slight rotation adjustment beneficial
+		func(t AffineMatrix) AffineMatrix {
+			return FineTuneRotation(sourceWalls, targetWalls, t, centroid, 1.0, 0.1)
+		},
+		// Final ultra-fine translation nudge
+		func(t AffineMatrix) AffineMatrix {
+			return FineTuneTranslation(sourceWalls, targetWalls, t, 0.5, 0.1)
+		},
+	}
+	for _, step := range steps {
+		if config.expired() {
+			break
+		}
+		transform = step(transform)
+	}
+	return transform
+}
+
 // FineTuneTranslation performs a hill-climbing search on translation (Tx, Ty)
 // to maximize the inlier score. It tests "nudges" in 8 directions (including diagonals).
 func FineTuneTranslation(source, target []Point, initial AffineMatrix, initialStep float64, minStep float64) AffineMatrix {
@@ -365,8 +549,11 @@ func CalculateInlierScore(source, target []Point, maxDist float64) (float64, flo
 }
 
 // findBestInitialAlignment tries multiple translations for a given rotation
-// to find the best initial overlap, robust to partial overlaps
-func findBestInitialAlignment(sourcePoints, targetPoints []Point, sourceCentroid, targetCentroid Point, rotationDeg float64, rng *rand.Rand) AffineMatrix {
+// to find the best initial overlap, robust to partial overlaps. Wall corners
+// of both maps, if any, are matched corner to corner: each pairing is a
+// candidate translation, and candidates score higher for every corner they
+// put on a corner.
+func findBestInitialAlignment(sourcePoints, targetPoints, sourceCorners, targetCorners []Point, sourceCentroid, targetCentroid Point, rotationDeg float64, rng *rand.Rand) AffineMatrix {
 	// 1. Base transform: Rotate around source centroid
 	// Translate source centroid to origin -> Rotate
 	toOrigin := Translation(-sourceCentroid.X, -sourceCentroid.Y)
@@ -409,6 +596,15 @@ func findBestInitialAlignment(sourcePoints, targetPoints []Point, sourceCentroid
 		candidates = append(candidates, Point{X: tx, Y: ty})
 	}
 
+	// Where walls meet is the surest match there is
+	rotatedCorners := TransformPoints(samplePointSlice(sourceCorners, maxInitialCorners), baseTransform)
+	targetCorners = samplePointSlice(targetCorners, maxInitialCorners)
+	for _, s := range rotatedCorners {
+		for _, t := range targetCorners {
+			candidates = append(candidates, Point{X: t.X - s.X, Y: t.Y - s.Y})
+		}
+	}
+
 	// Evaluate candidates
 	// Use a coarser subset for evaluation to be fast
 	evalSource := rotatedSource
@@ -455,6 +651,7 @@ func findBestInitialAlignment(sourcePoints, targetPoints []Point, sourceCentroid
 			score = score / float64(matchCount)
 			// Bonus for more matches
 			score -= float64(matchCount) * 0.1
+			score -= cornerMatchWeight * float64(matchedCorners(rotatedCorners, targetCorners, trans))
 		}
 
 		if score < bestScore {
@@ -466,6 +663,31 @@ func findBestInitialAlignment(sourcePoints, targetPoints []Point, sourceCentroid
 	return bestTransform
 }
 
+// Corner matching in initial alignment: at most maxInitialCorners corners of
+// each map are paired, and each source corner that lands within
+// cornerMatchDistance pixels of a target corner counts as much as
+// cornerMatchWeight pixels less average distance, twenty matched points.
+const (
+	maxInitialCorners   = 16
+	cornerMatchDistance = 8.0
+	cornerMatchWeight   = 2.0
+)
+
+// matchedCorners counts the source corners, moved by translation, that lie
+// on a target corner.
+func matchedCorners(source, target []Point, translation Point) int {
+	n := 0
+	for _, s := range source {
+		for _, t := range target {
+			if math.Hypot(s.X+translation.X-t.X, s.Y+translation.Y-t.Y) <= cornerMatchDistance {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
 // buildInitialTransform creates an initial transform using robust point matching
 // This ensures that even when forcing a rotation, we find the best translation
 func buildInitialTransform(source, target FeatureSet, rotationDeg float64, rng *rand.Rand) AffineMatrix {
@@ -483,7 +705,7 @@ func buildInitialTransform(source, target FeatureSet, rotationDeg float64, rng *
 		return MultiplyMatrices(toTarget, MultiplyMatrices(rotate, toOrigin))
 	}
 
-	return findBestInitialAlignment(sourcePoints, targetPoints, source.Centroid, target.Centroid, rotationDeg, rng)
+	return findBestInitialAlignment(sourcePoints, targetPoints, source.WallCorners, target.WallCorners, source.Centroid, target.Centroid, rotationDeg, rng)
 }
 
 // runICP performs ICP iterations starting from an initial transform
@@ -501,8 +723,13 @@ func runICP(sourcePoints, targetPoints []Point, initialTransform AffineMatrix, c
 	prevError = FeatureDistance(transformed, targetPoints)
 	result.Error = prevError
 	result.Transform = currentTransform
+	normals := correspondenceNormals(targetPoints, config.Metric)
 
 	for iter := 0; iter < config.MaxIterations; iter++ {
+		if config.expired() {
+			result.TimedOut = true
+			break
+		}
 		result.Iterations = iter + 1
 
 		// Transform source points with current estimate
@@ -515,17 +742,18 @@ func runICP(sourcePoints, targetPoints []Point, initialTransform AffineMatrix, c
 		}
 
 		// Reject outliers based on distance percentile
-		srcCorr, tgtCorr = rejectOutliers(srcCorr, tgtCorr, distances, config.OutlierPercentile)
+		srcCorr, tgtCorr, distances = rejectOutliers(srcCorr, tgtCorr, distances, config.OutlierPercentile)
 		if len(srcCorr) < 3 {
 			break
 		}
 
 		// Compute transform directly from transformed correspondences to target
 		// This gives us the incremental adjustment needed
-		incrementalTransform := CalculateRigidTransform(srcCorr, tgtCorr)
+		weights := robustWeights(distances, config.Loss, config.lossScalePixels())
+		increment := incrementalTransform(srcCorr, tgtCorr, weights, normals)
 
 		// Compose: new = incremental * current
-		newTransform := MultiplyMatrices(incrementalTransform, currentTransform)
+		newTransform := MultiplyMatrices(increment, currentTransform)
 
 		// Calculate alignment error with new transform
 		transformed = TransformPoints(sourcePoints, newTransform)
@@ -581,7 +809,7 @@ func runMultiScaleICP(sourcePoints, targetPoints []Point, initialTransform Affin
 	for _, scale := range scales {
 		scaleConfig := config
 		scaleConfig.MaxCorrespondDist = scale.maxDist
-		scaleConfig.MaxIterations = scale.iterations
+		scaleConfig.MaxIterations = config.passIterations(scale.iterations)
 		scaleConfig.ConvergenceThresh = scale.threshold
 
 		scaleResult := runICP(sourcePoints, targetPoints, currentTransform, scaleConfig)
@@ -593,6 +821,10 @@ func runMultiScaleICP(sourcePoints, targetPoints []Point, initialTransform Affin
 			result.Error = scaleResult.Error
 			result.Converged = scaleResult.Converged
 		}
+		if scaleResult.TimedOut {
+			result.TimedOut = true
+			break
+		}
 	}
 
 	result.Iterations = totalIterations
@@ -614,8 +846,13 @@ func runICPWithMutualNN(sourcePoints, targetPoints []Point, initialTransform Aff
 	prevError = FeatureDistance(transformed, targetPoints)
 	result.Error = prevError
 	result.Transform = currentTransform
+	normals := correspondenceNormals(targetPoints, config.Metric)
 
 	for iter := 0; iter < config.MaxIterations; iter++ {
+		if config.expired() {
+			result.TimedOut = true
+			break
+		}
 		result.Iterations = iter + 1
 
 		// Transform source points with current estimate
@@ -634,14 +871,15 @@ func runICPWithMutualNN(sourcePoints, targetPoints []Point, initialTransform Aff
 		}
 
 		// Reject outliers based on distance percentile
-		srcCorr, tgtCorr = rejectOutliers(srcCorr, tgtCorr, distances, config.OutlierPercentile)
+		srcCorr, tgtCorr, distances = rejectOutliers(srcCorr, tgtCorr, distances, config.OutlierPercentile)
 		if len(srcCorr) < 3 {
 			break
 		}
 
 		// Compute transform
-		incrementalTransform := CalculateRigidTransform(srcCorr, tgtCorr)
-		newTransform := MultiplyMatrices(incrementalTransform, currentTransform)
+		weights := robustWeights(distances, config.Loss, config.lossScalePixels())
+		increment := incrementalTransform(srcCorr, tgtCorr, weights, normals)
+		newTransform := MultiplyMatrices(increment, currentTransform)
 
 		// Calculate alignment error with new transform
 		transformed = TransformPoints(sourcePoints, newTransform)
@@ -745,9 +983,9 @@ func findMutualCorrespondences(source, target []Point, maxDist float64) (srcCorr
 }
 
 // rejectOutliers removes correspondences with distances above the given percentile
-func rejectOutliers(srcCorr, tgtCorr []Point, distances []float64, percentile float64) ([]Point, []Point) {
+func rejectOutliers(srcCorr, tgtCorr []Point, distances []float64, percentile float64) ([]Point, []Point, []float64) {
 	if len(distances) == 0 || percentile >= 1.0 {
-		return srcCorr, tgtCorr
+		return srcCorr, tgtCorr, distances
 	}
 
 	// Find threshold distance at percentile
@@ -763,14 +1001,16 @@ func rejectOutliers(srcCorr, tgtCorr []Point, distances []float64, percentile fl
 
 	// Filter correspondences
 	var filteredSrc, filteredTgt []Point
+	var filteredDist []float64
 	for i, d := range distances {
 		if d <= threshold {
 			filteredSrc = append(filteredSrc, srcCorr[i])
 			filteredTgt = append(filteredTgt, tgtCorr[i])
+			filteredDist = append(filteredDist, d)
 		}
 	}
 
-	return filteredSrc, filteredTgt
+	return filteredSrc, filteredTgt, filteredDist
 }
 
 // AlignToReference aligns a vacuum map to a reference vacuum's coordinate system
@@ -845,6 +1085,5 @@ func ValidateAlignment(transform AffineMatrix) bool {
 	}
 
 	// Check for reflection (determinant should be positive)
-	det := transform.A*transform.D - transform.B*transform.C
-	return det >= 0
+	return transform.Determinant() >= 0
 }
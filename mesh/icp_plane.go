@@ -0,0 +1,188 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+)
+
+// normalNeighbors is the number of nearest points used to fit the local wall
+// direction behind each normal.
+const normalNeighbors = 8
+
+// EstimateNormals returns a unit normal for each point, perpendicular to the
+// line fitted through its normalNeighbors nearest points. Points whose
+// neighbourhood is not line-like (corners, clutter, isolated samples) get a
+// zero normal.
+func EstimateNormals(points []Point) []Point {
+	normals := make([]Point, len(points))
+	if len(points) < 3 {
+		return normals
+	}
+
+	type neighbor struct {
+		idx  int
+		dist float64
+	}
+	k := min(normalNeighbors, len(points)-1)
+	nearest := make([]neighbor, 0, len(points))
+	for i, p := range points {
+		nearest = nearest[:0]
+		for j, q := range points {
+			if j != i {
+				nearest = append(nearest, neighbor{j, Distance(p, q)})
+			}
+		}
+		sort.Slice(nearest, func(a, b int) bool { return nearest[a].dist < nearest[b].dist })
+
+		// Principal direction of the point and its neighbours
+		n := float64(k + 1)
+		sx, sy := p.X, p.Y
+		for _, nb := range nearest[:k] {
+			sx += points[nb.idx].X
+			sy += points[nb.idx].Y
+		}
+		mx, my := sx/n, sy/n
+		cxx := (p.X - mx) * (p.X - mx)
+		cyy := (p.Y - my) * (p.Y - my)
+		cxy := (p.X - mx) * (p.Y - my)
+		for _, nb := range nearest[:k] {
+			dx, dy := points[nb.idx].X-mx, points[nb.idx].Y-my
+			cxx += dx * dx
+			cyy += dy * dy
+			cxy += dx * dy
+		}
+		trace := cxx + cyy
+		if trace <= 0 {
+			continue
+		}
+		if math.Sqrt((cxx-cyy)*(cxx-cyy)+4*cxy*cxy)/trace < wallOrientationCoherence {
+			continue
+		}
+		angle := 0.5 * math.Atan2(2*cxy, cxx-cyy)
+		normals[i] = Point{X: -math.Sin(angle), Y: math.Cos(angle)}
+	}
+	return normals
+}
+
+// CalculatePointToPlaneTransform computes the rigid transform that minimizes
+// the distance from each source point to the line through its target point
+// along the target normal. Unlike CalculateRigidTransform it does not pull
+// points along a wall, so long straight walls do not make the result slide.
+// Pairs with a zero normal fall back to the point-to-point distance. The
+// rotation is linearized, so this is meant for the small increments of an
+// ICP iteration; it returns Identity when the system is degenerate.
+func CalculatePointToPlaneTransform(source, target, normals []Point) AffineMatrix {
+	return calculateWeightedPointToPlane(source, target, normals, nil)
+}
+
+// calculateWeightedPointToPlane is CalculatePointToPlaneTransform with a
+// weight per pair; nil weights count every pair equally.
+func calculateWeightedPointToPlane(source, target, normals []Point, weights []float64) AffineMatrix {
+	n := len(source)
+	if n < 3 || n != len(target) || n != len(normals) || (weights != nil && len(weights) != n) {
+		return Identity()
+	}
+	c := Centroid(source)
+
+	// Normal equations for x = (theta, tx, ty) about the source centroid
+	var ata [3][3]float64
+	var atb [3]float64
+	addRow := func(p, q, normal Point, w float64) {
+		px, py := p.X-c.X, p.Y-c.Y
+		row := [3]float64{px*normal.Y - py*normal.X, normal.X, normal.Y}
+		r := (p.X-q.X)*normal.X + (p.Y-q.Y)*normal.Y
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				ata[i][j] += w * row[i] * row[j]
+			}
+			atb[i] -= w * row[i] * r
+		}
+	}
+	for i := range source {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		if normals[i] == (Point{}) {
+			addRow(source[i], target[i], Point{X: 1}, w)
+			addRow(source[i], target[i], Point{Y: 1}, w)
+			continue
+		}
+		addRow(source[i], target[i], normals[i], w)
+	}
+
+	// Light damping keeps unconstrained directions (e.g. along a corridor
+	// with no cross walls) where they are instead of letting them drift
+	for i := 0; i < 3; i++ {
+		ata[i][i] += 1e-6*ata[i][i] + 1e-9
+	}
+	x, ok := solve3(ata, atb)
+	if !ok {
+		return Identity()
+	}
+
+	rotate := MultiplyMatrices(Rotation(x[0]), Translation(-c.X, -c.Y))
+	return MultiplyMatrices(Translation(c.X+x[1], c.Y+x[2]), rotate)
+}
+
+// solve3 solves the 3x3 system a*x = b by Gaussian elimination with partial
+// pivoting.
+func solve3(a [3][3]float64, b [3]float64) ([3]float64, bool) {
+	var x [3]float64
+	for col := 0; col < 3; col++ {
+		pivot := col
+		for r := col + 1; r < 3; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for r := col + 1; r < 3; r++ {
+			f := a[r][col] / a[col][col]
+			for k := col; k < 3; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+	for r := 2; r >= 0; r-- {
+		s := b[r]
+		for k := r + 1; k < 3; k++ {
+			s -= a[r][k] * x[k]
+		}
+		x[r] = s / a[r][r]
+	}
+	return x, true
+}
+
+// correspondenceNormals indexes the normals of targetPoints by point for
+// point-to-plane ICP, or returns nil for point-to-point.
+func correspondenceNormals(targetPoints []Point, metric ICPMetric) map[Point]Point {
+	if metric != MetricPointToPlane {
+		return nil
+	}
+	normals := EstimateNormals(targetPoints)
+	byPoint := make(map[Point]Point, len(targetPoints))
+	for i, p := range targetPoints {
+		byPoint[p] = normals[i]
+	}
+	return byPoint
+}
+
+// incrementalTransform computes one ICP step from matched pairs with the
+// configured metric; weights comes from robustWeights (nil for equal
+// weights) and normals from correspondenceNormals.
+func incrementalTransform(srcCorr, tgtCorr []Point, weights []float64, normals map[Point]Point) AffineMatrix {
+	if normals == nil {
+		return CalculateWeightedRigidTransform(srcCorr, tgtCorr, weights)
+	}
+	matched := make([]Point, len(tgtCorr))
+	for i, q := range tgtCorr {
+		matched[i] = normals[q]
+	}
+	return calculateWeightedPointToPlane(srcCorr, tgtCorr, matched, weights)
+}
@@ -0,0 +1,137 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// roomWallPoints returns the wall pixels of rotatedRoom(0) as points.
+func roomWallPoints() []Point {
+	var pts []Point
+	walls := rotatedRoom(0).Layers[0].Pixels
+	for i := 0; i+1 < len(walls); i += 2 {
+		pts = append(pts, Point{X: float64(walls[i]), Y: float64(walls[i+1])})
+	}
+	return pts
+}
+
+// ---------------------------------------------------------------------------
+// EstimateNormals
+// ---------------------------------------------------------------------------
+
+func TestEstimateNormals(t *testing.T) {
+	var line []Point
+	for x := 0; x < 20; x++ {
+		line = append(line, Point{X: float64(x), Y: 5})
+	}
+	for i, n := range EstimateNormals(line) {
+		if math.Abs(n.X) > 1e-9 || math.Abs(math.Abs(n.Y)-1) > 1e-9 {
+			t.Errorf("normal[%d] = %+v, want (0, ±1)", i, n)
+		}
+	}
+
+	// A blob has no direction
+	var blob []Point
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			blob = append(blob, Point{X: float64(x), Y: float64(y)})
+		}
+	}
+	if n := EstimateNormals(blob)[4]; n != (Point{}) {
+		t.Errorf("blob center normal = %+v, want zero", n)
+	}
+
+	if got := EstimateNormals([]Point{{X: 1}, {X: 2}}); got[0] != (Point{}) || got[1] != (Point{}) {
+		t.Errorf("two points: normals = %v, want zero", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CalculatePointToPlaneTransform
+// ---------------------------------------------------------------------------
+
+func TestCalculatePointToPlaneTransform_SmallMotion(t *testing.T) {
+	source := roomWallPoints()
+	truth := CreateRotationTranslation(1.5, 6, -4)
+	target := TransformPoints(source, truth)
+
+	got := CalculatePointToPlaneTransform(source, target, EstimateNormals(target))
+	moved := TransformPoints(source, got)
+	worst := 0.0
+	for i := range moved {
+		worst = math.Max(worst, Distance(moved[i], target[i]))
+	}
+	if worst > 0.5 {
+		t.Errorf("worst residual = %.3f px, want < 0.5", worst)
+	}
+}
+
+func TestCalculatePointToPlaneTransform_DoesNotSlideAlongWall(t *testing.T) {
+	// Matches on a single wall that are offset along the wall, as nearest
+	// neighbours often are: only the 1px offset across the wall is real
+	var source, target, normals []Point
+	for x := 0; x < 100; x++ {
+		source = append(source, Point{X: float64(x), Y: 0})
+		target = append(target, Point{X: float64(x) + 5, Y: 1})
+		normals = append(normals, Point{Y: 1})
+	}
+
+	got := CalculatePointToPlaneTransform(source, target, normals)
+	if math.Abs(got.Tx) > 1e-3 || math.Abs(got.Ty-1) > 1e-3 || math.Abs(got.C) > 1e-6 {
+		t.Errorf("transform = %+v, want a pure 1px move across the wall", got)
+	}
+	if rigid := CalculateRigidTransform(source, target); math.Abs(rigid.Tx-5) > 1e-6 {
+		t.Errorf("point-to-point Tx = %.3f, expected it to slide 5px", rigid.Tx)
+	}
+}
+
+func TestCalculatePointToPlaneTransform_Degenerate(t *testing.T) {
+	if got := CalculatePointToPlaneTransform([]Point{{X: 1}}, []Point{{X: 2}}, []Point{{X: 1}}); got != Identity() {
+		t.Errorf("too few points: got %+v, want Identity", got)
+	}
+	if got := CalculatePointToPlaneTransform(make([]Point, 3), make([]Point, 2), make([]Point, 3)); got != Identity() {
+		t.Errorf("mismatched lengths: got %+v, want Identity", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ICP metric
+// ---------------------------------------------------------------------------
+
+func TestRunICP_PointToPlane(t *testing.T) {
+	target := roomWallPoints()
+	truth := CreateRotationTranslation(3, 12, -8)
+	source := TransformPoints(target, InvertMatrix(truth))
+
+	run := func(metric ICPMetric) ICPResult {
+		config := DefaultICPConfig()
+		config.Metric = metric
+		config.MaxCorrespondDist = 50
+		config.ConvergenceThresh = 0.01
+		return runICP(source, target, Identity(), config)
+	}
+	plane := run(MetricPointToPlane)
+	point := run(MetricPointToPoint)
+
+	angle := math.Atan2(plane.Transform.C, plane.Transform.A) * 180 / math.Pi
+	if math.Abs(angle-3) > 0.1 || plane.Error > 0.5 {
+		t.Errorf("point-to-plane: angle=%.3f error=%.3f, want 3° and < 0.5px", angle, plane.Error)
+	}
+	if plane.Error >= point.Error {
+		t.Errorf("point-to-plane error %.3f, want below point-to-point %.3f", plane.Error, point.Error)
+	}
+}
+
+func TestAlignMaps_PointToPlane(t *testing.T) {
+	config := DefaultICPConfig()
+	config.Metric = MetricPointToPlane
+	result := AlignMaps(rotatedRoom(0), rotatedRoom(33), config)
+
+	angle := math.Atan2(result.Transform.C, result.Transform.A) * 180 / math.Pi
+	if angleDiff(angle, 33) > 2 {
+		t.Errorf("aligned angle = %.2f, want 33", angle)
+	}
+	if result.Score < 0.5 {
+		t.Errorf("score = %.3f, want a good alignment", result.Score)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 // Helper to create a random point cloud (fully constrained, no sliding)
@@ -84,7 +85,7 @@ func TestICP_LargeTranslation_NeedsInit(t *testing.T) {
 	config := DefaultICPConfig()
 	config.SamplePoints = 2000
 	config.RNG = rng
-	initialTx := findBestInitialAlignment(original.WallPoints, targetPoints, srcCentroid, tgtCentroid, 0, rng)
+	initialTx := findBestInitialAlignment(original.WallPoints, targetPoints, nil, nil, srcCentroid, tgtCentroid, 0, rng)
 	result := runICP(original.WallPoints, targetPoints, initialTx, config)
 
 	if math.Abs(result.Transform.Tx-expectedTx) > 1.0 || math.Abs(result.Transform.Ty-expectedTy) > 1.0 {
@@ -101,7 +102,7 @@ func TestICP_Rotation(t *testing.T) {
 	srcCentroid := original.Centroid
 	tgtCentroid := TransformPoint(original.Centroid, rotation)
 
-	initialTx := findBestInitialAlignment(original.WallPoints, targetPoints, srcCentroid, tgtCentroid, 45, rng)
+	initialTx := findBestInitialAlignment(original.WallPoints, targetPoints, nil, nil, srcCentroid, tgtCentroid, 45, rng)
 
 	config := DefaultICPConfig()
 	config.RNG = rng
@@ -550,6 +551,23 @@ func TestAlignMapsWithRotationHint_90(t *testing.T) {
 		result.Score, angle)
 }
 
+func TestAlignMapsWithRotationHint_NonCardinal(t *testing.T) {
+	source := rotatedRoom(0)
+	target := rotatedRoom(37.5)
+
+	result := AlignMapsWithRotationHint(source, target, DefaultICPConfig(), 37.5)
+	if result.InitialRotation != 37.5 {
+		t.Errorf("InitialRotation = %g, want 37.5", result.InitialRotation)
+	}
+	angle := math.Atan2(result.Transform.C, result.Transform.A) * 180 / math.Pi
+	if angleDiff(angle, 37.5) > 2 {
+		t.Errorf("aligned angle = %.2f, want 37.5", angle)
+	}
+	if result.Score < 0.5 {
+		t.Errorf("score = %.3f, want a good alignment", result.Score)
+	}
+}
+
 func TestAlignMapsWithRotationHint_WrongHint(t *testing.T) {
 	// Test that even with wrong hint, refinement may recover
 	// (or at least produces a valid result)
@@ -802,6 +820,7 @@ func TestAlignMaps_RotationSearchBehavior(t *testing.T) {
 
 	config := DefaultICPConfig()
 	config.TryRotations = true
+	config.PreAlign = false // exercise the full sweep
 	result := AlignMaps(sourceMap, targetMap, config)
 
 	// Verify that rotation search was performed (RotationErrors should be populated)
@@ -878,3 +897,67 @@ func TestAlignMapsWithRotationHint_AllRotations(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Time and iteration budget
+// ---------------------------------------------------------------------------
+
+func TestICPConfigFromConfig(t *testing.T) {
+	config := ICPConfigFromConfig(&Config{ICP: ICPBudgetConfig{MaxDuration: "10s", MaxIterations: 20}})
+	if config.MaxDuration != 10*time.Second {
+		t.Errorf("MaxDuration = %v, want 10s", config.MaxDuration)
+	}
+	if config.MaxIterations != 20 {
+		t.Errorf("MaxIterations = %d, want 20", config.MaxIterations)
+	}
+
+	defaults := ICPConfigFromConfig(nil)
+	if defaults.MaxDuration != 0 || defaults.MaxIterations != DefaultICPConfig().MaxIterations {
+		t.Errorf("nil config = %v/%d, want defaults", defaults.MaxDuration, defaults.MaxIterations)
+	}
+}
+
+func TestAlignMaps_MaxDuration(t *testing.T) {
+	sourceWalls := createLShapeWalls(Point{X: 100, Y: 100}, 2.0)
+	targetWalls := TransformPoints(sourceWalls, Translation(30, -20))
+	sourceMap := createTestValetudoMap(sourceWalls, nil)
+	targetMap := createTestValetudoMap(targetWalls, nil)
+
+	config := DefaultICPConfig()
+	config.RNG = rand.New(rand.NewSource(1))
+	config.PreAlign = false // sweep all four rotations unless the budget stops it
+	config.MaxDuration = time.Nanosecond
+
+	result := AlignMaps(sourceMap, targetMap, config)
+	if !result.TimedOut {
+		t.Error("expected TimedOut with a 1ns budget")
+	}
+	if len(RotationErrors) != 1 {
+		t.Errorf("tried %d rotations, want only the first once the budget is spent", len(RotationErrors))
+	}
+	if result.Score < 0 {
+		t.Error("expected the best result found so far, got none")
+	}
+
+	config.MaxDuration = 0
+	if result := AlignMaps(sourceMap, targetMap, config); result.TimedOut {
+		t.Error("alignment without a budget should not time out")
+	}
+}
+
+func TestAlignMaps_MaxIterations(t *testing.T) {
+	sourceWalls := createLShapeWalls(Point{X: 100, Y: 100}, 2.0)
+	targetWalls := TransformPoints(sourceWalls, RotationDeg(90))
+	sourceMap := createTestValetudoMap(sourceWalls, nil)
+	targetMap := createTestValetudoMap(targetWalls, nil)
+
+	config := DefaultICPConfig()
+	config.RNG = rand.New(rand.NewSource(1))
+	config.MaxIterations = 1
+
+	result := AlignMaps(sourceMap, targetMap, config)
+	// Three multi-scale passes plus the wall refinement, one iteration each
+	if result.Iterations > 4 {
+		t.Errorf("Iterations = %d, want at most 4 with maxIterations 1", result.Iterations)
+	}
+}
@@ -0,0 +1,84 @@
+package mesh
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Layering controls how vacuum maps stack in composite renders. Vacuums with
+// a higher z-index are drawn on top; opacity fades a vacuum's floor and wall
+// layers so a lower-quality map can sit underneath a detailed one.
+type Layering struct {
+	Opacity map[string]float64 // 0.0-1.0, missing entries are fully opaque
+	ZIndex  map[string]int     // missing entries are 0
+}
+
+// LayeringFromConfig builds the layering for the vacuums in config.
+func LayeringFromConfig(config *Config) Layering {
+	l := Layering{
+		Opacity: make(map[string]float64),
+		ZIndex:  make(map[string]int),
+	}
+	if config == nil {
+		return l
+	}
+	for _, vc := range config.Vacuums {
+		if vc.Opacity != nil {
+			l.Opacity[vc.ID] = *vc.Opacity
+		}
+		if vc.ZIndex != 0 {
+			l.ZIndex[vc.ID] = vc.ZIndex
+		}
+	}
+	return l
+}
+
+// OpacityFor returns the opacity for a vacuum, clamped to 0.0-1.0.
+func (l Layering) OpacityFor(id string) float64 {
+	o, ok := l.Opacity[id]
+	if !ok {
+		return 1
+	}
+	return math.Max(0, math.Min(1, o))
+}
+
+// Levels groups map IDs by z-index, lowest first. IDs within a level are
+// sorted so output is deterministic.
+func (l Layering) Levels(maps map[string]*ValetudoMap) [][]string {
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		zi, zj := l.ZIndex[ids[i]], l.ZIndex[ids[j]]
+		if zi != zj {
+			return zi < zj
+		}
+		return ids[i] < ids[j]
+	})
+
+	var levels [][]string
+	for i, id := range ids {
+		if i == 0 || l.ZIndex[id] != l.ZIndex[ids[i-1]] {
+			levels = append(levels, nil)
+		}
+		levels[len(levels)-1] = append(levels[len(levels)-1], id)
+	}
+	return levels
+}
+
+// DrawOrder returns map IDs in the order they should be drawn (bottom first).
+func (l Layering) DrawOrder(maps map[string]*ValetudoMap) []string {
+	var order []string
+	for _, level := range l.Levels(maps) {
+		order = append(order, level...)
+	}
+	return order
+}
+
+// fade scales the alpha of c by opacity.
+func fade(c color.NRGBA, opacity float64) color.NRGBA {
+	c.A = uint8(math.Round(float64(c.A) * opacity))
+	return c
+}
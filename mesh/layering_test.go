@@ -0,0 +1,128 @@
+package mesh
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Layering
+// ---------------------------------------------------------------------------
+
+func TestLayeringFromConfig(t *testing.T) {
+	half := 0.5
+	cfg := &Config{Vacuums: []VacuumConfig{
+		{ID: "lidar", ZIndex: 10},
+		{ID: "gyro", Opacity: &half},
+	}}
+
+	l := LayeringFromConfig(cfg)
+	if l.ZIndex["lidar"] != 10 {
+		t.Errorf("lidar zIndex = %d, want 10", l.ZIndex["lidar"])
+	}
+	if got := l.OpacityFor("gyro"); got != 0.5 {
+		t.Errorf("gyro opacity = %v, want 0.5", got)
+	}
+	if got := l.OpacityFor("lidar"); got != 1 {
+		t.Errorf("unset opacity = %v, want 1", got)
+	}
+
+	if got := LayeringFromConfig(nil).OpacityFor("any"); got != 1 {
+		t.Errorf("nil config opacity = %v, want 1", got)
+	}
+}
+
+func TestLayering_Levels(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": {}, "b": {}, "c": {}, "d": {}}
+	l := Layering{ZIndex: map[string]int{"a": 5, "c": -1}}
+
+	want := [][]string{{"c"}, {"b", "d"}, {"a"}}
+	if got := l.Levels(maps); !reflect.DeepEqual(got, want) {
+		t.Errorf("Levels() = %v, want %v", got, want)
+	}
+	if got := l.DrawOrder(maps); !reflect.DeepEqual(got, []string{"c", "b", "d", "a"}) {
+		t.Errorf("DrawOrder() = %v", got)
+	}
+}
+
+func TestFade(t *testing.T) {
+	c := fade(color.NRGBA{10, 20, 30, 200}, 0.5)
+	if c != (color.NRGBA{10, 20, 30, 100}) {
+		t.Errorf("fade = %v, want alpha 100", c)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+func TestRender_ZIndexDrawsOnTop(t *testing.T) {
+	// Both vacuums have a wall at the same spot; the higher z-index wins
+	// regardless of ID ordering.
+	maps := map[string]*ValetudoMap{
+		"a": createMockMap([]int{0, 0, 10, 10}, nil),
+		"b": createMockMap([]int{0, 0, 10, 10}, nil),
+	}
+	transforms := map[string]AffineMatrix{"a": Identity(), "b": Identity()}
+
+	for _, top := range []string{"a", "b"} {
+		renderer := NewCompositeRenderer(maps, transforms, "a")
+		renderer.Padding = 0
+		renderer.Layering = Layering{ZIndex: map[string]int{top: 1}}
+
+		img := renderer.Render()
+		want := color.RGBA(renderer.Colors[top].Wall)
+		if got := img.RGBAAt(0, 0); got != want {
+			t.Errorf("top=%s: pixel = %v, want %v", top, got, want)
+		}
+	}
+}
+
+func TestRender_OpacityFadesWalls(t *testing.T) {
+	maps := map[string]*ValetudoMap{"vac1": createMockMap([]int{0, 0, 10, 10}, nil)}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	renderer := NewCompositeRenderer(maps, transforms, "vac1")
+	renderer.Padding = 0
+	renderer.Layering = Layering{Opacity: map[string]float64{"vac1": 0.5}}
+
+	img := renderer.Render()
+	wall := color.RGBA(renderer.Colors["vac1"].Wall)
+	got := img.RGBAAt(0, 0)
+	if got == wall {
+		t.Errorf("faded wall should blend with background, got solid %v", got)
+	}
+	if got.B <= wall.B {
+		t.Errorf("faded wall %v should be lighter than %v", got, wall)
+	}
+}
+
+func TestVectorRenderer_ZIndexOrdersSVG(t *testing.T) {
+	floor := []int{0, 0, 1, 0, 0, 1, 1, 1}
+	maps := map[string]*ValetudoMap{
+		"a": createMockMap(nil, floor),
+		"b": createMockMap(nil, floor),
+	}
+	transforms := map[string]AffineMatrix{"a": Identity(), "b": Identity()}
+
+	r := NewVectorRenderer(maps, transforms, "a")
+	r.Colors["a"] = VacuumColor{Floor: color.NRGBA{255, 0, 0, 255}}
+	r.Colors["b"] = VacuumColor{Floor: color.NRGBA{0, 0, 255, 255}}
+	r.Layering = Layering{ZIndex: map[string]int{"a": 1}}
+
+	var buf bytes.Buffer
+	if err := r.RenderToSVG(&buf); err != nil {
+		t.Fatalf("RenderToSVG: %v", err)
+	}
+	svg := buf.String()
+	red, blue := strings.Index(svg, "#f00"), strings.Index(svg, "#00f")
+	if red < 0 || blue < 0 {
+		t.Fatalf("expected both floor fills in SVG output")
+	}
+	if blue > red {
+		t.Errorf("vacuum a (zIndex 1) should be drawn after b")
+	}
+}
@@ -0,0 +1,161 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+// MaxLegendScale bounds the legend font scale factor.
+const MaxLegendScale = 8
+
+// LegendPosition is the corner of the image the legend is anchored to.
+type LegendPosition string
+
+const (
+	LegendTopLeft     LegendPosition = "top-left"
+	LegendTopRight    LegendPosition = "top-right"
+	LegendBottomLeft  LegendPosition = "bottom-left"
+	LegendBottomRight LegendPosition = "bottom-right"
+)
+
+// ParseLegendPosition validates a legend position. An empty string selects
+// the default top-left corner.
+func ParseLegendPosition(s string) (LegendPosition, error) {
+	switch p := LegendPosition(s); p {
+	case "":
+		return LegendTopLeft, nil
+	case LegendTopLeft, LegendTopRight, LegendBottomLeft, LegendBottomRight:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid legend position %q (must be top-left, top-right, bottom-left, or bottom-right)", s)
+	}
+}
+
+// LegendOptions controls whether and how a legend is drawn. The zero value
+// draws the legend top-left at normal size, labelled with vacuum IDs.
+type LegendOptions struct {
+	Hidden   bool
+	Position LegendPosition
+	Scale    int               // Font scale factor (0 or 1 = native font size)
+	Names    map[string]string // Display names keyed by vacuum ID
+}
+
+// LegendFromConfig builds legend options from the legend section and the
+// vacuums' display names.
+func LegendFromConfig(config *Config) LegendOptions {
+	opts := LegendOptions{Names: make(map[string]string)}
+	if config == nil {
+		return opts
+	}
+	if config.Legend.Enabled != nil && !*config.Legend.Enabled {
+		opts.Hidden = true
+	}
+	opts.Position, _ = ParseLegendPosition(config.Legend.Position)
+	opts.Scale = config.Legend.Scale
+	for _, vc := range config.Vacuums {
+		if vc.DisplayName != "" {
+			opts.Names[vc.ID] = vc.DisplayName
+		}
+	}
+	return opts
+}
+
+// Label returns the display name for a vacuum, falling back to its ID.
+func (o LegendOptions) Label(id string) string {
+	if name := o.Names[id]; name != "" {
+		return name
+	}
+	return id
+}
+
+// scale returns the effective font scale factor.
+func (o LegendOptions) scale() int {
+	if o.Scale < 1 {
+		return 1
+	}
+	return min(o.Scale, MaxLegendScale)
+}
+
+// legendEntry is one row of the legend: a color swatch and a label.
+type legendEntry struct {
+	label  string
+	swatch color.Color
+}
+
+// Legend layout at scale 1, in pixels.
+const (
+	legendMargin    = 10 // left/right inset
+	legendTopMargin = 4  // top/bottom inset
+	legendSwatch    = 12
+	legendTextGap   = 6
+	legendRowHeight = 18
+	legendOverhang  = 5 // glyph ascent above the first swatch
+)
+
+// drawLegendEntries draws the entries in the configured corner of img,
+// labelled in f. The layout grows with the text.
+func drawLegendEntries(img *image.RGBA, entries []legendEntry, opts LegendOptions, f *Font) {
+	if opts.Hidden || len(entries) == 0 {
+		return
+	}
+
+	s := opts.scale()
+	k := f.textScale(s)
+	px := func(v int) int { return int(math.Round(float64(v) * k)) }
+
+	textWidth := 0
+	for _, e := range entries {
+		w, _, _ := f.measure(e.label, s)
+		textWidth = max(textWidth, w)
+	}
+	blockW := px(legendSwatch+legendTextGap) + textWidth
+	blockH := px(legendOverhang + len(entries)*legendRowHeight - (legendRowHeight - legendSwatch))
+
+	bounds := img.Bounds()
+	ox := bounds.Min.X + legendMargin
+	oy := bounds.Min.Y + legendTopMargin
+	if opts.Position == LegendTopRight || opts.Position == LegendBottomRight {
+		ox = bounds.Max.X - legendMargin - blockW
+	}
+	if opts.Position == LegendBottomLeft || opts.Position == LegendBottomRight {
+		oy = bounds.Max.Y - legendTopMargin - blockH
+	}
+
+	for i, e := range entries {
+		top := oy + px(legendOverhang+i*legendRowHeight)
+		draw.Draw(img, image.Rect(ox, top, ox+px(legendSwatch), top+px(legendSwatch)), image.NewUniform(e.swatch), image.Point{}, draw.Src)
+
+		// Baseline sits at the swatch's vertical center, as at scale 1
+		baseline := top + px(legendSwatch/2)
+		f.drawString(img, ox+px(legendSwatch+legendTextGap), baseline, e.label, color.RGBA{0, 0, 0, 255}, s)
+	}
+}
+
+// drawScaledText draws text with the basic font enlarged by an integer
+// factor using nearest-neighbour scaling, keeping glyphs crisp.
+func drawScaledText(img *image.RGBA, x, y int, text string, c color.RGBA, scale int) {
+	if scale <= 1 {
+		drawText(img, x, y, text, c)
+		return
+	}
+
+	face := basicfont.Face7x13
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	w := font.MeasureString(face, text).Ceil()
+	if w == 0 {
+		return
+	}
+
+	glyphs := image.NewRGBA(image.Rect(0, 0, w, ascent+descent))
+	drawText(glyphs, 0, ascent, text, c)
+
+	dst := image.Rect(x, y-ascent*scale, x+w*scale, y+descent*scale)
+	draw.NearestNeighbor.Scale(img, dst, glyphs, glyphs.Bounds(), draw.Over, nil)
+}
@@ -0,0 +1,126 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Options
+// ---------------------------------------------------------------------------
+
+func TestParseLegendPosition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    LegendPosition
+		wantErr bool
+	}{
+		{"", LegendTopLeft, false},
+		{"top-right", LegendTopRight, false},
+		{"bottom-left", LegendBottomLeft, false},
+		{"middle", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLegendPosition(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLegendPosition(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLegendPosition(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLegendFromConfig(t *testing.T) {
+	off := false
+	cfg := &Config{
+		Legend: LegendConfig{Enabled: &off, Position: "bottom-right", Scale: 3},
+		Vacuums: []VacuumConfig{
+			{ID: "rockrobo", DisplayName: "Kitchen"},
+			{ID: "dreame"},
+		},
+	}
+
+	opts := LegendFromConfig(cfg)
+	if !opts.Hidden || opts.Position != LegendBottomRight || opts.Scale != 3 {
+		t.Errorf("LegendFromConfig() = %+v", opts)
+	}
+	if opts.Label("rockrobo") != "Kitchen" {
+		t.Errorf("Label(rockrobo) = %q, want display name", opts.Label("rockrobo"))
+	}
+	if opts.Label("dreame") != "dreame" {
+		t.Errorf("Label(dreame) = %q, want ID fallback", opts.Label("dreame"))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Drawing
+// ---------------------------------------------------------------------------
+
+var legendRed = color.RGBA{255, 0, 0, 255}
+
+func blankImage(w, h int) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, w, h))
+}
+
+func TestDrawLegendEntries_DefaultMatchesLegacyLayout(t *testing.T) {
+	img := blankImage(200, 100)
+	drawLegendEntries(img, []legendEntry{{label: "a", swatch: legendRed}}, LegendOptions{}, nil)
+
+	// Swatch covers x 10-21, y 9-20
+	if img.RGBAAt(10, 9) != legendRed || img.RGBAAt(21, 20) != legendRed {
+		t.Error("swatch not at legacy top-left position")
+	}
+	if img.RGBAAt(22, 9) == legendRed {
+		t.Error("swatch wider than 12px at scale 1")
+	}
+}
+
+func TestDrawLegendEntries_Hidden(t *testing.T) {
+	img := blankImage(200, 100)
+	drawLegendEntries(img, []legendEntry{{label: "a", swatch: legendRed}}, LegendOptions{Hidden: true}, nil)
+
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if img.RGBAAt(x, y) != (color.RGBA{}) {
+				t.Fatalf("hidden legend drew at (%d,%d)", x, y)
+			}
+		}
+	}
+}
+
+func TestDrawLegendEntries_PositionAndScale(t *testing.T) {
+	img := blankImage(300, 200)
+	drawLegendEntries(img, []legendEntry{{label: "a", swatch: legendRed}}, LegendOptions{
+		Position: LegendBottomRight,
+		Scale:    2,
+	}, nil)
+
+	if img.RGBAAt(10, 9) == legendRed {
+		t.Error("legend drawn top-left despite bottom-right position")
+	}
+
+	// Find the swatch's bounding box
+	minX, minY, maxX, maxY := 300, 200, -1, -1
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			if img.RGBAAt(x, y) == legendRed {
+				minX, minY = min(minX, x), min(minY, y)
+				maxX, maxY = max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if maxX < 0 {
+		t.Fatal("swatch not drawn")
+	}
+	if size := maxX - minX + 1; size != 24 {
+		t.Errorf("swatch width = %d, want 24 at scale 2", size)
+	}
+	if maxX < 150 || maxY < 100 {
+		t.Errorf("swatch at (%d,%d)-(%d,%d), want bottom-right quadrant", minX, minY, maxX, maxY)
+	}
+	if maxY != 200-legendTopMargin-1 {
+		t.Errorf("swatch bottom = %d, want %d", maxY, 200-legendTopMargin-1)
+	}
+}
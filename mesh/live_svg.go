@@ -0,0 +1,158 @@
+package mesh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/tdewolff/canvas/renderers/svg"
+)
+
+// LiveSVG is the live view split in two: the floor plan, rendered once, and
+// the chargers and robots, rendered on every request into <g id="chargers">
+// and <g id="robots"> groups appended to it. Unlike RenderLiveToSVG, the
+// viewport is fixed to the base map, so check Contains before reusing it.
+type LiveSVG struct {
+	r         *VectorRenderer
+	static    []byte // the floor plan SVG without its closing </svg>
+	pixelSize float64
+
+	minX, minY, maxX, maxY, centerX, centerY float64
+	width, height                            float64
+}
+
+// NewLiveSVG renders the floor plan of the live view.
+func (r *VectorRenderer) NewLiveSVG() (*LiveSVG, error) {
+	baseMap, baseTransform, err := r.liveBaseMap()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LiveSVG{r: r, pixelSize: float64(baseMap.PixelSize)}
+	l.minX, l.minY, l.maxX, l.maxY = liveBounds(baseMap, baseTransform)
+	l.centerX = (l.minX + l.maxX) / 2
+	l.centerY = (l.minY + l.maxY) / 2
+	l.width = (l.maxX - l.minX) + 2*r.Padding
+	l.height = (l.maxY - l.minY) + 2*r.Padding
+
+	var buf bytes.Buffer
+	svgRenderer := svg.New(&buf, l.width, l.height, nil)
+	r.renderLiveBase(svgRenderer, baseMap, baseTransform,
+		l.minX, l.minY, l.maxX, l.maxY, l.centerX, l.centerY, l.width, l.height)
+	if err := svgRenderer.Close(); err != nil {
+		return nil, err
+	}
+	static, ok := bytes.CutSuffix(buf.Bytes(), []byte("</svg>"))
+	if !ok {
+		return nil, fmt.Errorf("unexpected SVG ending")
+	}
+	l.static = static
+	return l, nil
+}
+
+// Contains reports whether every position lies within the base map, where
+// the fixed viewport shows it.
+func (l *LiveSVG) Contains(positions map[string]*LivePosition) bool {
+	for _, pos := range positions {
+		x, y := pos.X*l.pixelSize, pos.Y*l.pixelSize
+		if x < l.minX || x > l.maxX || y < l.minY || y > l.maxY {
+			return false
+		}
+	}
+	return true
+}
+
+// Render writes the floor plan with the chargers and positions drawn over
+// it.
+func (l *LiveSVG) Render(w io.Writer, positions map[string]*LivePosition) error {
+	if _, err := w.Write(l.static); err != nil {
+		return err
+	}
+	if err := l.writeGroup(w, "chargers", func(renderer canvasRenderer) {
+		l.r.renderChargers(renderer, positions, l.minX, l.minY, l.maxX, l.maxY, l.centerX, l.centerY)
+	}); err != nil {
+		return err
+	}
+	if err := l.writeGroup(w, "robots", func(renderer canvasRenderer) {
+		l.r.renderRobots(renderer, positions, l.pixelSize, l.minX, l.minY, l.maxX, l.maxY, l.centerX, l.centerY)
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</svg>")
+	return err
+}
+
+// writeGroup renders draw on a canvas the size of the floor plan and writes
+// its elements as a group with the given id.
+func (l *LiveSVG) writeGroup(w io.Writer, id string, draw func(canvasRenderer)) error {
+	var buf bytes.Buffer
+	svgRenderer := svg.New(&buf, l.width, l.height, nil)
+	draw(svgRenderer)
+	if err := svgRenderer.Close(); err != nil {
+		return err
+	}
+	// Keep what lies between the <svg ...> header and </svg>
+	_, body, ok := bytes.Cut(buf.Bytes(), []byte(">"))
+	inner, hasEnd := bytes.CutSuffix(body, []byte("</svg>"))
+	if !ok || !hasEnd {
+		return fmt.Errorf("unexpected SVG from the %s layer", id)
+	}
+	if _, err := fmt.Fprintf(w, `<g id="%s">`, id); err != nil {
+		return err
+	}
+	if _, err := w.Write(inner); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</g>")
+	return err
+}
+
+// LiveSVGCache keeps the floor plan of the live view until the maps,
+// transforms, rotation, grid or cleaning areas it was drawn from change.
+type LiveSVGCache struct {
+	mu   sync.Mutex
+	key  string
+	live *LiveSVG
+}
+
+// Get returns the floor plan for r, rendering it when r differs from the
+// renderer of the cached one.
+func (c *LiveSVGCache) Get(r *VectorRenderer) (*LiveSVG, error) {
+	key := liveSVGKey(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.live != nil && c.key == key {
+		// Robots are drawn with the current renderer's icons and colors
+		live := *c.live
+		live.r = r
+		return &live, nil
+	}
+	live, err := r.NewLiveSVG()
+	if err != nil {
+		return nil, err
+	}
+	c.key, c.live = key, live
+	return live, nil
+}
+
+// liveSVGKey identifies everything the live floor plan depends on. Maps are
+// compared by pointer: the state tracker replaces a map rather than
+// changing it.
+func liveSVGKey(r *VectorRenderer) string {
+	ids := make([]string, 0, len(r.Maps))
+	for id := range r.Maps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "rot=%g pad=%g grid=%g;", r.GlobalRotation, r.Padding, r.GridSpacing)
+	for _, id := range ids {
+		fmt.Fprintf(&b, "%s:%p:%v;", id, r.Maps[id], r.Transforms[id])
+	}
+	fmt.Fprintf(&b, "active=%v", r.Active)
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Live SVG with a cached floor plan
+// ---------------------------------------------------------------------------
+
+// liveTestRenderer returns a renderer for one 100x100-pixel map with a
+// charger in its middle.
+func liveTestRenderer() *VectorRenderer {
+	m := &ValetudoMap{
+		PixelSize: 5,
+		MetaData:  MapMetaData{TotalLayerArea: 100},
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: []int{0, 0, 100, 0, 100, 100, 0, 100}},
+			{Type: "wall", Pixels: []int{0, 0, 100, 0}},
+		},
+		Entities: []MapEntity{{Type: "charger_location", Points: []int{250, 250}}},
+	}
+	return NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+}
+
+func TestLiveSVG_Render(t *testing.T) {
+	live, err := liveTestRenderer().NewLiveSVG()
+	if err != nil {
+		t.Fatalf("NewLiveSVG: %v", err)
+	}
+
+	render := func(positions map[string]*LivePosition) string {
+		var buf bytes.Buffer
+		if err := live.Render(&buf, positions); err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		var v interface{}
+		if err := xml.Unmarshal(buf.Bytes(), &v); err != nil {
+			t.Fatalf("SVG is not valid XML: %v", err)
+		}
+		return buf.String()
+	}
+
+	empty := render(nil)
+	if !strings.Contains(empty, `<g id="chargers"><path`) {
+		t.Error("charger missing from the chargers group")
+	}
+	if !strings.Contains(empty, `<g id="robots"></g>`) {
+		t.Error("robots group missing or not empty without positions")
+	}
+
+	out := render(map[string]*LivePosition{"vac1": {X: 50, Y: 50, Angle: 90, Color: "#FF0000"}})
+	if !strings.HasPrefix(out, string(live.static)) {
+		t.Error("floor plan changed between renders")
+	}
+	_, robots, _ := strings.Cut(out, `<g id="robots">`)
+	if !strings.Contains(robots, "#f00") && !strings.Contains(strings.ToLower(robots), "#ff0000") {
+		t.Errorf("robot marker not in the robots group: %.200s", robots)
+	}
+	if strings.Count(out, "<svg") != 1 || !strings.HasSuffix(out, "</g></svg>") {
+		t.Errorf("groups not nested in a single SVG: ...%s", out[max(0, len(out)-80):])
+	}
+}
+
+func TestLiveSVG_Contains(t *testing.T) {
+	live, err := liveTestRenderer().NewLiveSVG()
+	if err != nil {
+		t.Fatalf("NewLiveSVG: %v", err)
+	}
+	if !live.Contains(map[string]*LivePosition{"vac1": {X: 50, Y: 50}}) {
+		t.Error("position on the map reported outside")
+	}
+	if live.Contains(map[string]*LivePosition{"vac1": {X: 50, Y: 50}, "vac2": {X: 500, Y: 50}}) {
+		t.Error("position off the map reported inside")
+	}
+}
+
+func TestLiveSVGCache(t *testing.T) {
+	var cache LiveSVGCache
+	r := liveTestRenderer()
+
+	a, err := cache.Get(r)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, _ := cache.Get(liveTestRenderer())
+	if &a.static[0] == &b.static[0] {
+		t.Error("floor plan reused for a different map")
+	}
+	c, _ := cache.Get(liveTestRenderer())
+	if &b.static[0] == &c.static[0] {
+		t.Error("floor plan reused although the maps were replaced")
+	}
+
+	// The same maps are reused, whatever renderer draws the robots
+	r2 := NewVectorRenderer(c.r.Maps, c.r.Transforms, "vac1")
+	d, _ := cache.Get(r2)
+	if &c.static[0] != &d.static[0] {
+		t.Error("floor plan rendered again for unchanged maps")
+	}
+	if d.r != r2 {
+		t.Error("cached floor plan does not draw robots with the current renderer")
+	}
+
+	r2.GlobalRotation = 90
+	if e, _ := cache.Get(r2); &d.static[0] == &e.static[0] {
+		t.Error("floor plan reused after the rotation changed")
+	}
+	r2.Active = map[string]ActiveArea{"vac1": {SegmentIDs: []string{"1"}}}
+	if e, _ := cache.Get(r2); &d.static[0] == &e.static[0] {
+		t.Error("floor plan reused after the cleaning areas changed")
+	}
+}
+
+func TestNewLiveSVG_NoMaps(t *testing.T) {
+	r := NewVectorRenderer(map[string]*ValetudoMap{}, nil, "")
+	if _, err := r.NewLiveSVG(); err == nil {
+		t.Error("NewLiveSVG without maps succeeded")
+	}
+}
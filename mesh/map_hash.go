@@ -0,0 +1,79 @@
+package mesh
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"sort"
+)
+
+// volatileEntities change while the map itself stays the same: where the
+// robot is, where it has been and where it is heading
+var volatileEntities = map[string]bool{
+	"robot_position": true,
+	"path":           true,
+	"predicted_path": true,
+	"go_to_target":   true,
+	"active_zone":    true,
+}
+
+// MapContentHash returns a hash of the structural content of m: its grid,
+// layer pixels and segment identities, and the static entities such as the
+// charger and virtual walls. Robot position, paths and which segments are
+// being cleaned are ignored, so republished maps with only those changes
+// hash the same.
+func MapContentHash(m *ValetudoMap) string {
+	if m == nil {
+		return ""
+	}
+	h := sha256.New()
+	writeInts(h, m.PixelSize, m.Size.X, m.Size.Y, len(m.Layers))
+	for _, layer := range m.Layers {
+		writeString(h, layer.Type)
+		writeString(h, layer.MetaData.SegmentID)
+		writeString(h, layer.MetaData.Name)
+		writeInts(h, len(layer.Pixels))
+		writeInts(h, layer.Pixels...)
+	}
+
+	// Entity order is not significant
+	var entities []string
+	for _, e := range m.Entities {
+		if volatileEntities[e.Type] {
+			continue
+		}
+		b, _ := json.Marshal(struct {
+			Type   string `json:"t"`
+			Points []int  `json:"p"`
+		}{e.Type, e.Points})
+		entities = append(entities, string(b))
+	}
+	sort.Strings(entities)
+	for _, e := range entities {
+		writeString(h, e)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeInts feeds ints to h as fixed-width values, a chunk at a time.
+func writeInts(h hash.Hash, values ...int) {
+	var buf [4096]byte
+	n := 0
+	for _, v := range values {
+		binary.LittleEndian.PutUint64(buf[n:], uint64(v))
+		n += 8
+		if n == len(buf) {
+			_, _ = h.Write(buf[:n])
+			n = 0
+		}
+	}
+	_, _ = h.Write(buf[:n])
+}
+
+// writeString feeds a length-prefixed string to h.
+func writeString(h hash.Hash, s string) {
+	writeInts(h, len(s))
+	_, _ = h.Write([]byte(s))
+}
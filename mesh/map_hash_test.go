@@ -0,0 +1,66 @@
+package mesh
+
+import "testing"
+
+// ---------------------------------------------------------------------------
+// MapContentHash
+// ---------------------------------------------------------------------------
+
+func hashTestMap(robotX int) *ValetudoMap {
+	return &ValetudoMap{
+		PixelSize: 5,
+		Size:      Size{X: 100, Y: 100},
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: []int{1, 1, 2, 1}},
+			{Type: "segment", Pixels: []int{1, 1}, MetaData: LayerMetaData{SegmentID: "1", Name: "Kitchen"}},
+		},
+		Entities: []MapEntity{
+			{Type: "charger_location", Points: []int{10, 10}},
+			{Type: "robot_position", Points: []int{robotX, 20}},
+			{Type: "path", Points: []int{0, 0, robotX, 20}},
+		},
+	}
+}
+
+func TestMapContentHash_IgnoresVolatileEntities(t *testing.T) {
+	a, b := hashTestMap(20), hashTestMap(400)
+	b.Layers[1].MetaData.Active = true
+	if MapContentHash(a) != MapContentHash(b) {
+		t.Error("robot movement and active segments should not change the hash")
+	}
+}
+
+func TestMapContentHash_DetectsStructuralChanges(t *testing.T) {
+	base := MapContentHash(hashTestMap(20))
+	tests := []struct {
+		name   string
+		change func(m *ValetudoMap)
+	}{
+		{"pixels", func(m *ValetudoMap) { m.Layers[0].Pixels = append(m.Layers[0].Pixels, 3, 1) }},
+		{"segment name", func(m *ValetudoMap) { m.Layers[1].MetaData.Name = "Hall" }},
+		{"charger", func(m *ValetudoMap) { m.Entities[0].Points = []int{15, 10} }},
+		{"virtual wall", func(m *ValetudoMap) {
+			m.Entities = append(m.Entities, MapEntity{Type: "virtual_wall", Points: []int{0, 0, 50, 0}})
+		}},
+		{"pixel size", func(m *ValetudoMap) { m.PixelSize = 10 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := hashTestMap(20)
+			tt.change(m)
+			if MapContentHash(m) == base {
+				t.Errorf("changing %s should change the hash", tt.name)
+			}
+		})
+	}
+}
+
+func TestMapContentHash_EntityOrder(t *testing.T) {
+	a := hashTestMap(20)
+	a.Entities = append(a.Entities, MapEntity{Type: "virtual_wall", Points: []int{0, 0, 50, 0}})
+	b := hashTestMap(20)
+	b.Entities = append([]MapEntity{{Type: "virtual_wall", Points: []int{0, 0, 50, 0}}}, b.Entities...)
+	if MapContentHash(a) != MapContentHash(b) {
+		t.Error("entity order should not change the hash")
+	}
+}
@@ -0,0 +1,92 @@
+package mesh
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultMapWriteInterval is the minimum time between two saves of the same
+// vacuum's map when storage.minWriteInterval is not set.
+const DefaultMapWriteInterval = 30 * time.Second
+
+// MapWriter coalesces map saves so frequent MQTT updates do not wear out SD
+// cards. Each vacuum's map is saved at most once per interval, in the
+// background; maps arriving in between replace the pending one, so the last
+// map always reaches the store.
+type MapWriter struct {
+	store    Store
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*ValetudoMap
+	timers  map[string]*time.Timer
+	last    map[string]time.Time
+}
+
+// NewMapWriter creates a MapWriter saving to store at most once per interval
+// per vacuum.
+func NewMapWriter(store Store, interval time.Duration) *MapWriter {
+	return &MapWriter{
+		store:    store,
+		interval: interval,
+		pending:  make(map[string]*ValetudoMap),
+		timers:   make(map[string]*time.Timer),
+		last:     make(map[string]time.Time),
+	}
+}
+
+// Save schedules m to be saved for vacuumID without blocking. A map already
+// waiting for the vacuum is replaced. A downsampled map is not saved, so the
+// stored map keeps its full resolution.
+func (w *MapWriter) Save(vacuumID string, m *ValetudoMap) {
+	if m.Downsample > 1 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending[vacuumID] = m
+	if _, scheduled := w.timers[vacuumID]; scheduled {
+		return
+	}
+	delay := time.Until(w.last[vacuumID].Add(w.interval))
+	w.timers[vacuumID] = time.AfterFunc(max(delay, 0), func() { w.write(vacuumID) })
+}
+
+// Flush saves every waiting map now, e.g. before shutdown.
+func (w *MapWriter) Flush() {
+	w.mu.Lock()
+	ids := make([]string, 0, len(w.pending))
+	for id, t := range w.timers {
+		t.Stop()
+		delete(w.timers, id)
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	for _, id := range ids {
+		w.write(id)
+	}
+}
+
+// write saves the vacuum's waiting map, if any.
+func (w *MapWriter) write(vacuumID string) {
+	w.mu.Lock()
+	m := w.pending[vacuumID]
+	delete(w.pending, vacuumID)
+	delete(w.timers, vacuumID)
+	if m != nil {
+		w.last[vacuumID] = time.Now()
+	}
+	w.mu.Unlock()
+
+	if m == nil {
+		return
+	}
+	if err := w.store.SaveMap(vacuumID, m); err != nil {
+		log.Printf("Error caching map for %s to %s: %v", vacuumID, w.store, err)
+		return
+	}
+	log.Printf("[DEBUG] Cached map for %s to %s", vacuumID, w.store)
+}
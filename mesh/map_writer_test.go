@@ -0,0 +1,128 @@
+package mesh
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// countingStore records SaveMap calls on top of a MemoryStore.
+type countingStore struct {
+	*MemoryStore
+	mu    sync.Mutex
+	saves map[string]int
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{MemoryStore: NewMemoryStore(), saves: make(map[string]int)}
+}
+
+func (s *countingStore) SaveMap(vacuumID string, m *ValetudoMap) error {
+	s.mu.Lock()
+	s.saves[vacuumID]++
+	s.mu.Unlock()
+	return s.MemoryStore.SaveMap(vacuumID, m)
+}
+
+func (s *countingStore) count(vacuumID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves[vacuumID]
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// MapWriter
+// ---------------------------------------------------------------------------
+
+func TestMapWriter_FirstSaveIsImmediate(t *testing.T) {
+	store := newCountingStore()
+	w := NewMapWriter(store, time.Hour)
+
+	w.Save("v1", storeTestMap())
+	waitFor(t, func() bool { return store.count("v1") == 1 })
+}
+
+func TestMapWriter_CoalescesWithinInterval(t *testing.T) {
+	store := newCountingStore()
+	w := NewMapWriter(store, time.Hour)
+
+	w.Save("v1", storeTestMap())
+	waitFor(t, func() bool { return store.count("v1") == 1 })
+
+	// Later updates wait for the interval and replace each other
+	for i := 1; i <= 5; i++ {
+		m := storeTestMap()
+		m.PixelSize = i
+		w.Save("v1", m)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := store.count("v1"); n != 1 {
+		t.Fatalf("saves within interval = %d, want 1", n)
+	}
+
+	w.Flush()
+	if n := store.count("v1"); n != 2 {
+		t.Fatalf("saves after Flush = %d, want 2", n)
+	}
+	maps, _ := store.LoadMaps()
+	if got := maps["v1"].PixelSize; got != 5 {
+		t.Errorf("saved map PixelSize = %d, want latest (5)", got)
+	}
+
+	// Nothing left to flush
+	w.Flush()
+	if n := store.count("v1"); n != 2 {
+		t.Errorf("second Flush saved again: %d saves", n)
+	}
+}
+
+func TestMapWriter_SkipsDownsampledMaps(t *testing.T) {
+	store := newCountingStore()
+	w := NewMapWriter(store, time.Hour)
+
+	m := storeTestMap()
+	m.Downsample = 2
+	w.Save("v1", m)
+	w.Flush()
+	if n := store.count("v1"); n != 0 {
+		t.Errorf("downsampled map saved %d times, want never", n)
+	}
+}
+
+func TestMapWriter_SavesAfterInterval(t *testing.T) {
+	store := newCountingStore()
+	w := NewMapWriter(store, 30*time.Millisecond)
+
+	w.Save("v1", storeTestMap())
+	waitFor(t, func() bool { return store.count("v1") == 1 })
+	w.Save("v1", storeTestMap())
+	waitFor(t, func() bool { return store.count("v1") == 2 })
+}
+
+func TestMapWriter_VacuumsIndependent(t *testing.T) {
+	store := newCountingStore()
+	w := NewMapWriter(store, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		w.Save(fmt.Sprintf("v%d", i), storeTestMap())
+	}
+	waitFor(t, func() bool {
+		return store.count("v0") == 1 && store.count("v1") == 1 && store.count("v2") == 1
+	})
+}
@@ -0,0 +1,106 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+)
+
+// Floor materials Valetudo reports in a segment's metaData. MaterialGeneric
+// means unknown and is not unified.
+const (
+	MaterialGeneric        = "generic"
+	MaterialCarpet         = "carpet"
+	MaterialTile           = "tile"
+	MaterialWood           = "wood"
+	MaterialWoodHorizontal = "wood_horizontal"
+	MaterialWoodVertical   = "wood_vertical"
+)
+
+// hasMaterial reports whether a layer's material is worth a region of its
+// own.
+func hasMaterial(material string) bool {
+	return material != "" && material != MaterialGeneric
+}
+
+// UnifyMaterials unifies floor regions by material: features are grouped by
+// their "material" property and each group is clustered and unioned as
+// UnifyFloors does, so the carpet one vacuum reports in a room and the
+// carpet another reports there become one region. Results carry only the
+// material and the merge statistics, not the rooms they came from, and are
+// ordered by material.
+func UnifyMaterials(features []*Feature, sources []FeatureSource, totalVacuums int) []*UnifiedFeature {
+	groups := make(map[string][]int)
+	for i, f := range features {
+		material, _ := f.Properties["material"].(string)
+		if hasMaterial(material) {
+			groups[material] = append(groups[material], i)
+		}
+	}
+	materials := make([]string, 0, len(groups))
+	for material := range groups {
+		materials = append(materials, material)
+	}
+	sort.Strings(materials)
+
+	var result []*UnifiedFeature
+	for _, material := range materials {
+		var groupFeatures []*Feature
+		var groupSources []FeatureSource
+		for _, i := range groups[material] {
+			groupFeatures = append(groupFeatures, features[i])
+			if i < len(sources) {
+				groupSources = append(groupSources, sources[i])
+			}
+		}
+		for _, uf := range UnifyFloors(groupFeatures, groupSources, totalVacuums) {
+			uf.Properties = map[string]interface{}{
+				"layerType":        "material",
+				"material":         material,
+				"observationCount": uf.ObservationCount,
+				"confidence":       uf.Confidence,
+			}
+			result = append(result, uf)
+		}
+	}
+	return result
+}
+
+// materialHatchSpacing is the distance between hatch lines, in mm.
+const materialHatchSpacing = 150.0
+
+// hatchFamily is a set of parallel hatch lines: their angle in degrees and
+// their spacing as a multiple of materialHatchSpacing.
+type hatchFamily struct {
+	angle, spacing float64
+}
+
+// materialHatch returns the lines drawn over floor of material: a fine
+// cross-hatch for carpet, a grid for tile and boards for wood.
+func materialHatch(material string) []hatchFamily {
+	switch material {
+	case MaterialCarpet:
+		return []hatchFamily{{45, 0.5}, {-45, 0.5}}
+	case MaterialTile:
+		return []hatchFamily{{0, 2}, {90, 2}}
+	case MaterialWood, MaterialWoodHorizontal:
+		return []hatchFamily{{0, 1}}
+	case MaterialWoodVertical:
+		return []hatchFamily{{90, 1}}
+	default:
+		return []hatchFamily{{45, 1}}
+	}
+}
+
+// onHatch reports whether the point (x, y) lies on one of the hatch lines,
+// rotated by angle degrees, spaced spacing apart and width thick.
+func onHatch(families []hatchFamily, x, y, angle, spacing, width float64) bool {
+	for _, f := range families {
+		rad := (f.angle + angle) * math.Pi / 180
+		step := f.spacing * spacing
+		u := -x*math.Sin(rad) + y*math.Cos(rad)
+		if m := math.Mod(u, step); m < 0 && m+step < width || m >= 0 && m < width {
+			return true
+		}
+	}
+	return false
+}
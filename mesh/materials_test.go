@@ -0,0 +1,99 @@
+package mesh
+
+import "testing"
+
+// materialRect returns a rectangular floor of material, as LayerToFeature
+// would have produced it.
+func materialRect(x0, y0, x1, y1 float64, material string) *Feature {
+	return makePolygonFeature([][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}, map[string]interface{}{
+		"layerType":   "segment",
+		"segmentName": "Living Room",
+		"material":    material,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// UnifyMaterials
+// ---------------------------------------------------------------------------
+
+func TestUnifyMaterials(t *testing.T) {
+	features := []*Feature{
+		materialRect(0, 0, 2000, 2000, MaterialCarpet),
+		materialRect(20, 0, 2020, 2000, MaterialCarpet),
+		materialRect(3000, 0, 5000, 2000, MaterialTile),
+		materialRect(6000, 0, 8000, 2000, MaterialGeneric),
+	}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1), makeSource("a", 1), makeSource("a", 1)}
+
+	unified := UnifyMaterials(features, sources, 2)
+	if len(unified) != 2 {
+		t.Fatalf("got %d regions, want carpet and tile", len(unified))
+	}
+	carpet, tile := unified[0], unified[1]
+	if carpet.Properties["material"] != MaterialCarpet || tile.Properties["material"] != MaterialTile {
+		t.Errorf("materials = %v, %v; want carpet, tile", carpet.Properties["material"], tile.Properties["material"])
+	}
+	if carpet.ObservationCount != 2 || carpet.Confidence != 1 {
+		t.Errorf("carpet observations = %d, confidence = %v; want 2 and 1", carpet.ObservationCount, carpet.Confidence)
+	}
+	if _, ok := carpet.Properties["segmentName"]; ok {
+		t.Error("material region kept the room's name")
+	}
+	if carpet.Properties["layerType"] != "material" {
+		t.Errorf("layerType = %v, want material", carpet.Properties["layerType"])
+	}
+}
+
+func TestLayerToFeature_Material(t *testing.T) {
+	tests := []struct {
+		material string
+		want     interface{}
+	}{
+		{MaterialCarpet, MaterialCarpet},
+		{MaterialGeneric, nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		layer := &MapLayer{Type: "segment", MetaData: LayerMetaData{Material: tt.material}}
+		paths := []Path{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+		f := LayerToFeature(layer, paths, "a", Identity(), 5)
+		if got := f.Properties["material"]; got != tt.want {
+			t.Errorf("material %q: property = %v, want %v", tt.material, got, tt.want)
+		}
+	}
+}
+
+func TestStateTracker_UnifiedMaterials(t *testing.T) {
+	st := NewStateTracker()
+	for _, id := range []string{"a", "b"} {
+		m := rectFloor(0, 0, 100, 100)
+		m.Layers[0].Type = "segment"
+		m.Layers[0].MetaData = LayerMetaData{SegmentID: "1", Name: "Lounge", Material: MaterialCarpet}
+		st.UpdateMap(id, m)
+	}
+	calib := &CalibrationData{ReferenceVacuum: "a", Vacuums: map[string]VacuumCalibration{
+		"a": {Transform: Identity()},
+		"b": {Transform: Identity()},
+	}}
+	if err := st.UpdateUnifiedMap(calib); err != nil {
+		t.Fatalf("UpdateUnifiedMap: %v", err)
+	}
+
+	um := st.GetUnifiedMap()
+	if len(um.Materials) != 1 {
+		t.Fatalf("got %d material regions, want 1", len(um.Materials))
+	}
+	if m := um.Materials[0]; m.Properties["material"] != MaterialCarpet || m.ObservationCount != 2 {
+		t.Errorf("region = %v seen by %d, want carpet seen by 2", m.Properties["material"], m.ObservationCount)
+	}
+
+	var found bool
+	for _, f := range um.ToFeatureCollection().Features {
+		if f.Properties["layerType"] == "material" && f.Properties["material"] == MaterialCarpet {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("GeoJSON has no carpet feature")
+	}
+}
@@ -0,0 +1,213 @@
+package mesh
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// DefaultMaxRenderDimension is the largest width or height (in pixels) a
+	// raster render may use when no memory budget is configured.
+	DefaultMaxRenderDimension = 4000
+
+	// MinRenderDimension is the floor applied when a memory budget forces the
+	// render resolution down. Below this the output stops being useful.
+	MinRenderDimension = 256
+
+	// renderHeadroomFraction is the share of the remaining budget a single
+	// raster image may occupy. The rest is left for point buffers, PNG
+	// encoding and concurrent MQTT decodes.
+	renderHeadroomFraction = 0.5
+)
+
+// MemoryBudget bounds how much memory the process should use. When a limit is
+// set, raster renders shrink to fit the remaining headroom instead of
+// allocating an image that would push the process over the edge.
+type MemoryBudget struct {
+	LimitBytes uint64
+}
+
+// heapInUse reports the bytes currently allocated on the heap.
+// It is a variable so tests can simulate memory pressure.
+var heapInUse = func() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// NewMemoryBudget builds a MemoryBudget from the config. A nil config or a
+// zero budget yields an unlimited budget.
+func NewMemoryBudget(cfg *MemoryConfig) MemoryBudget {
+	if cfg == nil || cfg.BudgetMB <= 0 {
+		return MemoryBudget{}
+	}
+	return MemoryBudget{LimitBytes: uint64(cfg.BudgetMB) << 20}
+}
+
+// Limited returns true when a memory limit is in effect.
+func (b MemoryBudget) Limited() bool {
+	return b.LimitBytes > 0
+}
+
+// Apply sets the Go runtime soft memory limit so the garbage collector works
+// harder before the process reaches the budget. It is a no-op when unlimited.
+func (b MemoryBudget) Apply() {
+	if !b.Limited() {
+		return
+	}
+	debug.SetMemoryLimit(int64(b.LimitBytes))
+}
+
+// MaxRenderDimension returns the largest width or height a raster render may
+// use given the current heap usage. Without a budget it returns
+// DefaultMaxRenderDimension; under pressure it degrades down to
+// MinRenderDimension rather than failing.
+func (b MemoryBudget) MaxRenderDimension() int {
+	if !b.Limited() {
+		return DefaultMaxRenderDimension
+	}
+
+	used := heapInUse()
+	if used >= b.LimitBytes {
+		return MinRenderDimension
+	}
+	headroom := float64(b.LimitBytes-used) * renderHeadroomFraction
+
+	// A square RGBA image of side d needs 4*d*d bytes.
+	dim := int(math.Sqrt(headroom / 4))
+	if dim > DefaultMaxRenderDimension {
+		return DefaultMaxRenderDimension
+	}
+	if dim < MinRenderDimension {
+		return MinRenderDimension
+	}
+	return dim
+}
+
+// pointBufferPool recycles Point slices used while iterating layer pixels so
+// that repeated renders do not churn the heap with short-lived buffers.
+var pointBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]Point, 0, 1024)
+		return &buf
+	},
+}
+
+// acquirePoints returns an empty Point buffer from the pool.
+func acquirePoints() *[]Point {
+	buf := pointBufferPool.Get().(*[]Point)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// releasePoints returns a buffer obtained from acquirePoints to the pool.
+func releasePoints(buf *[]Point) {
+	pointBufferPool.Put(buf)
+}
+
+// pixelsToPointsInto decodes a flat pixel array into buf, reusing its backing
+// storage. The returned slice is only valid until buf is reused.
+func pixelsToPointsInto(buf *[]Point, pixels []int) []Point {
+	points := (*buf)[:0]
+	for i := 0; i+1 < len(pixels); i += 2 {
+		points = append(points, Point{
+			X: float64(pixels[i]),
+			Y: float64(pixels[i+1]),
+		})
+	}
+	*buf = points
+	return points
+}
+
+// DownsamplePixels snaps pixel coordinates to a grid of the given factor and
+// drops duplicates. Thin features such as walls survive because every occupied
+// cell keeps one representative pixel. A factor of 0 or 1 returns the input.
+func DownsamplePixels(pixels []int, factor int) []int {
+	if factor <= 1 || len(pixels) < 2 {
+		return pixels
+	}
+
+	ds := newPixelDownsampler(factor, len(pixels)/factor)
+	for i := 0; i+1 < len(pixels); i += 2 {
+		ds.add(pixels[i], pixels[i+1])
+	}
+	return ds.out
+}
+
+// pixelDownsampler collects pixels one at a time, snapping each to the
+// factor grid and dropping duplicates as DownsamplePixels does. It lets a
+// streaming decoder downsample without holding the full pixel array.
+type pixelDownsampler struct {
+	factor int
+	seen   map[[2]int]struct{}
+	out    []int
+}
+
+// newPixelDownsampler returns a downsampler for factor with room for
+// capacity output values. A factor of 0 or 1 keeps every pixel.
+func newPixelDownsampler(factor, capacity int) *pixelDownsampler {
+	ds := &pixelDownsampler{factor: factor, out: make([]int, 0, capacity)}
+	if factor > 1 {
+		ds.seen = make(map[[2]int]struct{}, capacity/2)
+	}
+	return ds
+}
+
+// add records the pixel (x, y), unless its grid cell already has one.
+func (ds *pixelDownsampler) add(x, y int) {
+	if ds.factor <= 1 {
+		ds.out = append(ds.out, x, y)
+		return
+	}
+	cell := [2]int{floorDiv(x, ds.factor) * ds.factor, floorDiv(y, ds.factor) * ds.factor}
+	if _, ok := ds.seen[cell]; ok {
+		return
+	}
+	ds.seen[cell] = struct{}{}
+	ds.out = append(ds.out, cell[0], cell[1])
+}
+
+// DownsampleMap applies DownsamplePixels to every layer of the map in place
+// and records the factor in m.Downsample, so each remaining pixel is drawn
+// and measured as the factor x factor block of cells it stands for. A map
+// already downsampled is left as it is.
+func DownsampleMap(m *ValetudoMap, factor int) {
+	if m == nil || factor <= 1 || m.Downsample > 1 {
+		return
+	}
+	for i := range m.Layers {
+		m.Layers[i].Pixels = DownsamplePixels(m.Layers[i].Pixels, factor)
+	}
+	m.Downsample = factor
+}
+
+// CellBlock returns the side, in cells, of the square each of the map's
+// layer pixels stands for: its Downsample factor, or 1 at full resolution.
+func (m *ValetudoMap) CellBlock() int {
+	if m == nil || m.Downsample < 1 {
+		return 1
+	}
+	return m.Downsample
+}
+
+// forEachBlockCell calls fn with every cell of the block x block square
+// whose top-left cell is p, the cells a pixel of a downsampled map stands
+// for (see CellBlock).
+func forEachBlockCell(p Point, block int, fn func(Point)) {
+	for dy := 0; dy < block; dy++ {
+		for dx := 0; dx < block; dx++ {
+			fn(Point{X: p.X + float64(dx), Y: p.Y + float64(dy)})
+		}
+	}
+}
+
+// floorDiv performs integer division rounding towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,241 @@
+package mesh
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// withHeapInUse overrides the heap usage probe for the duration of a test.
+func withHeapInUse(t *testing.T, used uint64) {
+	t.Helper()
+	orig := heapInUse
+	heapInUse = func() uint64 { return used }
+	t.Cleanup(func() { heapInUse = orig })
+}
+
+// ---------------------------------------------------------------------------
+// MemoryBudget
+// ---------------------------------------------------------------------------
+
+func TestNewMemoryBudget(t *testing.T) {
+	if b := NewMemoryBudget(nil); b.Limited() {
+		t.Error("nil config should yield an unlimited budget")
+	}
+	if b := NewMemoryBudget(&MemoryConfig{}); b.Limited() {
+		t.Error("zero budget should yield an unlimited budget")
+	}
+	b := NewMemoryBudget(&MemoryConfig{BudgetMB: 64})
+	if b.LimitBytes != 64<<20 {
+		t.Errorf("LimitBytes = %d, want %d", b.LimitBytes, 64<<20)
+	}
+}
+
+func TestMemoryBudget_MaxRenderDimension(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget MemoryBudget
+		used   uint64
+		want   int
+	}{
+		{"unlimited", MemoryBudget{}, 1 << 40, DefaultMaxRenderDimension},
+		{"plenty of headroom", MemoryBudget{LimitBytes: 1 << 30}, 0, DefaultMaxRenderDimension},
+		{"over budget", MemoryBudget{LimitBytes: 64 << 20}, 80 << 20, MinRenderDimension},
+		{"tiny headroom", MemoryBudget{LimitBytes: 64 << 20}, (64 << 20) - 1024, MinRenderDimension},
+		// 32 MiB headroom * 0.5 / 4 bytes per pixel = 4 Mpx -> 2048x2048
+		{"partial headroom", MemoryBudget{LimitBytes: 64 << 20}, 32 << 20, 2048},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withHeapInUse(t, tt.used)
+			if got := tt.budget.MaxRenderDimension(); got != tt.want {
+				t.Errorf("MaxRenderDimension() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositeRenderer_RespectsMaxDimension(t *testing.T) {
+	m := &ValetudoMap{
+		PixelSize: 5,
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: []int{0, 0, 2000, 1000}},
+		},
+	}
+	r := NewCompositeRenderer(map[string]*ValetudoMap{"a": m}, map[string]AffineMatrix{"a": Identity()}, "a")
+	r.MaxDimension = 500
+
+	img := r.Render()
+	b := img.Bounds()
+	if b.Dx() > 500 || b.Dy() > 500 {
+		t.Errorf("image %dx%d exceeds MaxDimension 500", b.Dx(), b.Dy())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Downsampling
+// ---------------------------------------------------------------------------
+
+func TestDownsamplePixels(t *testing.T) {
+	pixels := []int{0, 0, 1, 1, 2, 2, 3, 3, -1, -1}
+
+	if got := DownsamplePixels(pixels, 1); len(got) != len(pixels) {
+		t.Errorf("factor 1 should be a no-op, got %v", got)
+	}
+
+	got := DownsamplePixels(pixels, 2)
+	want := []int{0, 0, 2, 2, -2, -2}
+	if len(got) != len(want) {
+		t.Fatalf("DownsamplePixels = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("DownsamplePixels = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDownsampleMap_DrawsAndMeasuresBlocks(t *testing.T) {
+	full, down := courtyardFloor(), courtyardFloor()
+	DownsampleMap(down, 2)
+	if down.Downsample != 2 || down.CellBlock() != 2 || full.CellBlock() != 1 {
+		t.Fatalf("Downsample = %d, CellBlock = %d; want the factor recorded", down.Downsample, down.CellBlock())
+	}
+
+	// Each kept pixel is drawn as its 2x2 block, not as a dot grid
+	drawn := func(m *ValetudoMap) int {
+		renderer := NewCompositeRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+		renderer.Legend.Hidden = true
+		img := renderer.Render()
+		background := color.RGBA{240, 240, 240, 255}
+		n := 0
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				if img.RGBAAt(x, y) != background {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	if got, want := drawn(down), drawn(full); math.Abs(float64(got-want)) > 0.05*float64(want) {
+		t.Errorf("downsampled map drew %d pixels, want about %d", got, want)
+	}
+
+	// Areas count the whole blocks
+	stats := func(m *ValetudoMap) CoverageStats {
+		return ComputeCoverageStats(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+	}
+	if got, want := stats(down).VacuumAreas["vac1"], stats(full).VacuumAreas["vac1"]; math.Abs(got-want) > 0.02*want {
+		t.Errorf("downsampled area = %.2f m², want about %.2f m²", got, want)
+	}
+
+	// A downsampled map is not downsampled again
+	pixels := len(down.Layers[1].Pixels)
+	DownsampleMap(down, 4)
+	if down.Downsample != 2 || len(down.Layers[1].Pixels) != pixels {
+		t.Error("downsampled map was downsampled again")
+	}
+}
+
+func TestParseMapReader_Downsample(t *testing.T) {
+	body := `{"pixelSize":5,"layers":[{"type":"floor","pixels":[0,0,1,0,2,0,3,0]}]}`
+
+	m, err := ParseMapReader(strings.NewReader(body), ParseOptions{Downsample: 2})
+	if err != nil {
+		t.Fatalf("ParseMapReader: %v", err)
+	}
+	if got := len(m.Layers[0].Pixels); got != 4 {
+		t.Errorf("pixel count after downsample = %d, want 4", got)
+	}
+	if m.Downsample != 2 {
+		t.Errorf("Downsample = %d, want 2", m.Downsample)
+	}
+}
+
+func TestParseMapReader_MatchesParseMapJSON(t *testing.T) {
+	body := `{"__class":"ValetudoMap","metaData":{"version":2,"nonce":"n","totalLayerArea":8},` +
+		`"size":{"x":100,"y":50},"pixelSize":5,"extra":{"ignored":[1,2]},` +
+		`"layers":[{"__class":"MapLayer","metaData":{"segmentId":"1","name":"Kitchen","area":4},"type":"segment","pixels":[1,2,3,4,5,6]},` +
+		`{"type":"wall","pixels":null}],` +
+		`"entities":[{"__class":"PointMapEntity","type":"robot_position","points":[10,20],"metaData":{"angle":90}}]}`
+
+	got, err := ParseMapReader(strings.NewReader(body), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseMapReader: %v", err)
+	}
+	want, err := ParseMapJSON([]byte(body))
+	if err != nil {
+		t.Fatalf("ParseMapJSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseMapReader = %+v, want %+v", got, want)
+	}
+	if got.Downsample != 0 {
+		t.Errorf("Downsample = %d, want 0 at full resolution", got.Downsample)
+	}
+
+	for _, bad := range []string{`[]`, `{"layers":{}}`, `{"layers":[{"pixels":[1,"x"]}]}`, `{"pixelSize":5`} {
+		if _, err := ParseMapReader(strings.NewReader(bad), ParseOptions{}); err == nil {
+			t.Errorf("ParseMapReader(%s): expected error", bad)
+		}
+	}
+}
+
+func TestDecodeMapDataWithOptions_Downsample(t *testing.T) {
+	body := []byte(`{"pixelSize":5,"layers":[{"type":"floor","pixels":[0,0,1,0,2,0,3,0]}]}`)
+
+	m, err := DecodeMapDataWithOptions(body, ParseOptions{Downsample: 2})
+	if err != nil {
+		t.Fatalf("DecodeMapDataWithOptions: %v", err)
+	}
+	if got := len(m.Layers[0].Pixels); got != 4 {
+		t.Errorf("pixel count after downsample = %d, want 4", got)
+	}
+	if m, _ := DecodeMapData(body); len(m.Layers[0].Pixels) != 8 {
+		t.Error("DecodeMapData downsampled without options")
+	}
+}
+
+func TestParseMapFileWithOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.json")
+	body := `{"pixelSize":5,"layers":[{"type":"wall","pixels":[10,10,11,11]}]}`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	m, err := ParseMapFileWithOptions(path, ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseMapFileWithOptions: %v", err)
+	}
+	if got := len(m.Layers[0].Pixels); got != 4 {
+		t.Errorf("pixel count = %d, want 4", got)
+	}
+
+	if _, err := ParseMapFileWithOptions(filepath.Join(t.TempDir(), "missing.json"), ParseOptions{}); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestPixelsToPointsInto_ReusesBuffer(t *testing.T) {
+	buf := acquirePoints()
+	defer releasePoints(buf)
+
+	first := pixelsToPointsInto(buf, []int{1, 2, 3, 4})
+	if len(first) != 2 || first[1] != (Point{X: 3, Y: 4}) {
+		t.Fatalf("unexpected points %v", first)
+	}
+	second := pixelsToPointsInto(buf, []int{5, 6})
+	if len(second) != 1 || second[0] != (Point{X: 5, Y: 6}) {
+		t.Fatalf("unexpected points %v", second)
+	}
+}
@@ -0,0 +1,79 @@
+package mesh
+
+import "fmt"
+
+// Mirror axes of VacuumConfig.Mirror: the coordinate that is flipped.
+const (
+	MirrorX = "x" // flip X: east and west swap
+	MirrorY = "y" // flip Y: north and south swap
+)
+
+// ValidateMirror checks a VacuumConfig.Mirror value.
+func ValidateMirror(axis string) error {
+	switch axis {
+	case "", MirrorX, MirrorY:
+		return nil
+	}
+	return fmt.Errorf("%q is invalid (must be x or y)", axis)
+}
+
+// MirrorTransform returns the reflection of m's pixel grid along axis about
+// the middle of its layers, which keeps the reflected map where it was. It
+// is the identity without an axis or layers.
+func MirrorTransform(m *ValetudoMap, axis string) AffineMatrix {
+	if m == nil || (axis != MirrorX && axis != MirrorY) {
+		return Identity()
+	}
+	minX, minY, maxX, maxY, ok := layerPixelBounds(m)
+	switch {
+	case !ok:
+		return Identity()
+	case axis == MirrorX:
+		return AffineMatrix{A: -1, Tx: float64(minX + maxX), D: 1}
+	}
+	return AffineMatrix{A: 1, D: -1, Ty: float64(minY + maxY)}
+}
+
+// MirrorMap returns m reflected along axis, and the reflection (see
+// MirrorTransform). ICP only finds rotations and translations, so a vacuum
+// that stores its map mirrored is aligned by its reflected map; the
+// calibrated transform is the ICP result composed with the reflection and
+// maps the vacuum's own coordinates like any other. A reflection undoes
+// itself, so composing the calibrated transform with it again gives back
+// the part ValidateAlignment can check. Without an axis m is returned as is.
+func MirrorMap(m *ValetudoMap, axis string) (*ValetudoMap, AffineMatrix) {
+	mirror := MirrorTransform(m, axis)
+	if mirror == Identity() {
+		return m, mirror
+	}
+
+	mirrored := *m
+	mirrored.Layers = make([]MapLayer, len(m.Layers))
+	for i, layer := range m.Layers {
+		pixels := make([]int, len(layer.Pixels))
+		for j := 0; j+1 < len(layer.Pixels); j += 2 {
+			p := TransformPoint(Point{X: float64(layer.Pixels[j]), Y: float64(layer.Pixels[j+1])}, mirror)
+			pixels[j], pixels[j+1] = int(p.X), int(p.Y)
+		}
+		layer.Pixels = pixels
+		mirrored.Layers[i] = layer
+	}
+	mirrored.Entities = transformEntities(m.Entities, m.PixelSize, mirror)
+	return &mirrored, mirror
+}
+
+// layerPixelBounds returns the bounding box of all layer pixels of m.
+func layerPixelBounds(m *ValetudoMap) (minX, minY, maxX, maxY int, ok bool) {
+	for _, layer := range m.Layers {
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			x, y := layer.Pixels[i], layer.Pixels[i+1]
+			if !ok {
+				minX, minY, maxX, maxY, ok = x, y, x, y, true
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	return minX, minY, maxX, maxY, ok
+}
@@ -0,0 +1,56 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMirrorMap_AlignsMirroredVacuum(t *testing.T) {
+	keepAll := func(int, int) bool { return true }
+	reference := cropMap(rotatedRoom(0), keepAll, 300, 400, 30)
+
+	// The same home from a robot that stores it flipped east to west, in a
+	// frame of its own: x is 1000-x, so the robot faces 180-30 degrees
+	flip := AffineMatrix{A: -1, Tx: 1000, D: 1}
+	mirrored := &ValetudoMap{PixelSize: reference.PixelSize, Entities: transformEntities(reference.Entities, reference.PixelSize, flip)}
+	for _, layer := range reference.Layers {
+		moved := layer
+		moved.Pixels = make([]int, len(layer.Pixels))
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			moved.Pixels[i], moved.Pixels[i+1] = 1000-layer.Pixels[i], layer.Pixels[i+1]
+		}
+		mirrored.Layers = append(mirrored.Layers, moved)
+	}
+
+	source, mirror := MirrorMap(mirrored, MirrorX)
+	if !IsMirrored(mirror) {
+		t.Fatalf("reflection %+v is not mirrored", mirror)
+	}
+	result := AlignMaps(source, reference, DefaultICPConfig())
+	if !ValidateAlignment(result.Transform) || result.Score < 0.9 {
+		t.Fatalf("reflected map did not align: score %.2f, transform %+v", result.Score, result.Transform)
+	}
+	transform := MultiplyMatrices(result.Transform, mirror)
+	if !IsMirrored(transform) {
+		t.Errorf("calibrated transform %+v does not include the reflection", transform)
+	}
+
+	pos, angle, _ := ExtractRobotPosition(mirrored)
+	world := TransformPoint(Point{X: pos.X / float64(mirrored.PixelSize), Y: pos.Y / float64(mirrored.PixelSize)}, transform)
+	if math.Hypot(world.X-300, world.Y-400) > 3 {
+		t.Errorf("robot at %v in the world, want (300, 400)", world)
+	}
+	if got := TransformAngle(angle, transform); math.Abs(signedDegrees(got-30)) > 2 {
+		t.Errorf("robot heading %.1f° in the world, want 30°", got)
+	}
+}
+
+func TestMirrorMap_WithoutAxis(t *testing.T) {
+	m := rotatedRoom(0)
+	if got, mirror := MirrorMap(m, ""); got != m || mirror != Identity() {
+		t.Error("MirrorMap without an axis changed the map")
+	}
+	if ValidateMirror("y") != nil || ValidateMirror("xy") == nil {
+		t.Error("ValidateMirror accepts the wrong axes")
+	}
+}
@@ -0,0 +1,352 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// 3D model defaults (mm)
+const (
+	DefaultWallHeight     = 2400.0
+	DefaultWallThickness  = 80.0
+	DefaultFloorThickness = 20.0
+	floorRowHeight        = 25.0 // scanline rows the floor slabs are cut into
+)
+
+// ModelOptions sets the dimensions of the extruded floorplan, in mm.
+type ModelOptions struct {
+	WallHeight     float64
+	WallThickness  float64
+	FloorThickness float64
+}
+
+// Options returns the model dimensions with defaults for unset fields.
+func (c Model3DConfig) Options() ModelOptions {
+	opts := ModelOptions{WallHeight: c.WallHeight, WallThickness: c.WallThickness, FloorThickness: c.FloorThickness}
+	if opts.WallHeight <= 0 {
+		opts.WallHeight = DefaultWallHeight
+	}
+	if opts.WallThickness <= 0 {
+		opts.WallThickness = DefaultWallThickness
+	}
+	if opts.FloorThickness <= 0 {
+		opts.FloorThickness = DefaultFloorThickness
+	}
+	return opts
+}
+
+// Vec3 is a model vertex in meters, Y up, as glTF and OBJ tools expect: X
+// is the map's X, Z the map's Y (which grows downwards in the top view)
+// and Y the height.
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// modelPoint converts a map point and a height, in mm, to model space.
+func modelPoint(p Point, height float64) Vec3 {
+	return Vec3{X: p.X / 1000, Y: height / 1000, Z: p.Y / 1000}
+}
+
+// ModelPart is one object of a model with its own color.
+type ModelPart struct {
+	Name      string
+	Color     [4]float64 // linear RGBA, 0-1
+	Vertices  []Vec3
+	Triangles [][3]uint32
+}
+
+// addTriangle adds a triangle wound counter-clockwise when seen from the
+// side normal points to, so it faces that way.
+func (p *ModelPart) addTriangle(a, b, c, normal Vec3) {
+	u := Vec3{b.X - a.X, b.Y - a.Y, b.Z - a.Z}
+	v := Vec3{c.X - a.X, c.Y - a.Y, c.Z - a.Z}
+	cross := Vec3{u.Y*v.Z - u.Z*v.Y, u.Z*v.X - u.X*v.Z, u.X*v.Y - u.Y*v.X}
+	if cross.X*normal.X+cross.Y*normal.Y+cross.Z*normal.Z < 0 {
+		b, c = c, b
+	}
+	n := uint32(len(p.Vertices))
+	p.Vertices = append(p.Vertices, a, b, c)
+	p.Triangles = append(p.Triangles, [3]uint32{n, n + 1, n + 2})
+}
+
+// addQuad adds the quad a, b, c, d (in order around it) facing normal.
+func (p *ModelPart) addQuad(a, b, c, d, normal Vec3) {
+	p.addTriangle(a, b, c, normal)
+	p.addTriangle(a, c, d, normal)
+}
+
+// Model is a floorplan extruded to 3D.
+type Model struct {
+	Parts []*ModelPart
+}
+
+// BuildModel extrudes the unified map: each wall line becomes a box of the
+// wall thickness and height standing on the floor, and the floors become
+// slabs of the floor thickness with their top at height 0. Floors are cut
+// into 25mm rows, so their outlines follow the map closely enough for a
+// room-scale view; holes in them stay open. Empty parts are left out.
+func BuildModel(um *UnifiedMap, opts ModelOptions) *Model {
+	model := &Model{}
+	if um == nil {
+		return model
+	}
+
+	floors := &ModelPart{Name: "floors", Color: [4]float64{0.8, 0.8, 0.78, 1}}
+	for _, f := range um.Floors {
+		addFloorSlab(floors, geometryPaths(f.Geometry), opts.FloorThickness)
+	}
+	walls := &ModelPart{Name: "walls", Color: [4]float64{0.35, 0.35, 0.38, 1}}
+	for _, f := range um.Walls {
+		for _, line := range geometryPaths(f.Geometry) {
+			for i := 0; i+1 < len(line); i++ {
+				addWallBox(walls, line[i], line[i+1], opts.WallThickness, opts.WallHeight)
+			}
+		}
+	}
+
+	for _, part := range []*ModelPart{floors, walls} {
+		if len(part.Triangles) > 0 {
+			model.Parts = append(model.Parts, part)
+		}
+	}
+	return model
+}
+
+// addWallBox adds a box around the wall segment a-b.
+func addWallBox(part *ModelPart, a, b Point, thickness, height float64) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	// Across and along the wall, in map units and as model normals
+	nx, ny := -dy/length*thickness/2, dx/length*thickness/2
+	across := Vec3{X: -dy / length, Z: dx / length}
+	along := Vec3{X: dx / length, Z: dy / length}
+
+	corners := [4]Point{
+		{X: a.X + nx, Y: a.Y + ny}, {X: b.X + nx, Y: b.Y + ny},
+		{X: b.X - nx, Y: b.Y - ny}, {X: a.X - nx, Y: a.Y - ny},
+	}
+	var lo, hi [4]Vec3
+	for i, c := range corners {
+		lo[i], hi[i] = modelPoint(c, 0), modelPoint(c, height)
+	}
+	neg := func(v Vec3) Vec3 { return Vec3{-v.X, -v.Y, -v.Z} }
+
+	part.addQuad(hi[0], hi[1], hi[2], hi[3], Vec3{Y: 1})
+	part.addQuad(lo[0], lo[1], lo[2], lo[3], Vec3{Y: -1})
+	part.addQuad(lo[0], lo[1], hi[1], hi[0], across)
+	part.addQuad(lo[3], lo[2], hi[2], hi[3], neg(across))
+	part.addQuad(lo[1], lo[2], hi[2], hi[1], along)
+	part.addQuad(lo[0], lo[3], hi[3], hi[0], neg(along))
+}
+
+// addFloorSlab adds a slab filling rings, the outline and holes of a floor,
+// by the even-odd rule.
+func addFloorSlab(part *ModelPart, rings [][]Point, thickness float64) {
+	if len(rings) == 0 {
+		return
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, ring := range rings {
+		for _, p := range ring {
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+
+	// Top and bottom faces, one quad per span of each row
+	for y0 := minY; y0 < maxY; y0 += floorRowHeight {
+		y1 := math.Min(y0+floorRowHeight, maxY)
+		xs := ringCrossings(rings, (y0+y1)/2)
+		for i := 0; i+1 < len(xs); i += 2 {
+			c := [4]Point{{X: xs[i], Y: y0}, {X: xs[i+1], Y: y0}, {X: xs[i+1], Y: y1}, {X: xs[i], Y: y1}}
+			part.addQuad(modelPoint(c[0], 0), modelPoint(c[1], 0), modelPoint(c[2], 0), modelPoint(c[3], 0), Vec3{Y: 1})
+			part.addQuad(modelPoint(c[0], -thickness), modelPoint(c[1], -thickness), modelPoint(c[2], -thickness), modelPoint(c[3], -thickness), Vec3{Y: -1})
+		}
+	}
+
+	// Edges, along the outlines themselves
+	for _, ring := range rings {
+		for i := range ring {
+			a, b := ring[i], ring[(i+1)%len(ring)]
+			if a == b {
+				continue
+			}
+			length := math.Hypot(b.X-a.X, b.Y-a.Y)
+			out := Vec3{X: (b.Y - a.Y) / length, Z: -(b.X - a.X) / length}
+			part.addQuad(modelPoint(a, -thickness), modelPoint(b, -thickness), modelPoint(b, 0), modelPoint(a, 0), out)
+		}
+	}
+}
+
+// ringCrossings returns the sorted X coordinates where the horizontal line
+// at y crosses the edges of rings.
+func ringCrossings(rings [][]Point, y float64) []float64 {
+	var xs []float64
+	for _, ring := range rings {
+		for i := range ring {
+			a, b := ring[i], ring[(i+1)%len(ring)]
+			if (a.Y <= y) != (b.Y <= y) {
+				xs = append(xs, a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+	}
+	sort.Float64s(xs)
+	return xs
+}
+
+// WriteOBJ writes the model as a Wavefront OBJ file, one object per part.
+func WriteOBJ(w io.Writer, model *Model) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# tudomesh floorplan; meters, Y up")
+	offset := uint32(1) // OBJ indices are 1-based and count across objects
+	for _, part := range model.Parts {
+		fmt.Fprintf(bw, "o %s\n", part.Name)
+		for _, v := range part.Vertices {
+			fmt.Fprintf(bw, "v %.4f %.4f %.4f\n", v.X, v.Y, v.Z)
+		}
+		for _, t := range part.Triangles {
+			fmt.Fprintf(bw, "f %d %d %d\n", t[0]+offset, t[1]+offset, t[2]+offset)
+		}
+		offset += uint32(len(part.Vertices))
+	}
+	return bw.Flush()
+}
+
+// glTF constants
+const (
+	gltfFloat         = 5126
+	gltfUnsignedInt   = 5125
+	gltfArrayBuffer   = 34962
+	gltfElementBuffer = 34963
+)
+
+type gltfDocument struct {
+	Asset       map[string]string `json:"asset"`
+	Scene       int               `json:"scene"`
+	Scenes      []gltfScene       `json:"scenes"`
+	Nodes       []gltfNode        `json:"nodes"`
+	Meshes      []gltfMesh        `json:"meshes"`
+	Materials   []gltfMaterial    `json:"materials"`
+	Accessors   []gltfAccessor    `json:"accessors"`
+	BufferViews []gltfBufferView  `json:"bufferViews"`
+	Buffers     []gltfBuffer      `json:"buffers"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+}
+
+type gltfMaterial struct {
+	Name        string `json:"name"`
+	DoubleSided bool   `json:"doubleSided"`
+	PBR         struct {
+		BaseColorFactor [4]float64 `json:"baseColorFactor"`
+		MetallicFactor  float64    `json:"metallicFactor"`
+		RoughnessFactor float64    `json:"roughnessFactor"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+// WriteGLTF writes the model as a self-contained glTF 2.0 file, its geometry
+// embedded as a base64 data URI: one node, mesh and material per part.
+func WriteGLTF(w io.Writer, model *Model) error {
+	doc := gltfDocument{
+		Asset:  map[string]string{"version": "2.0", "generator": "tudomesh"},
+		Scenes: []gltfScene{{Nodes: []int{}}},
+	}
+	var buf bytes.Buffer
+	for _, part := range model.Parts {
+		// Positions
+		minV := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+		maxV := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		start := buf.Len()
+		for _, v := range part.Vertices {
+			for i, c := range [3]float32{float32(v.X), float32(v.Y), float32(v.Z)} {
+				_ = binary.Write(&buf, binary.LittleEndian, c)
+				minV[i] = math.Min(minV[i], float64(c))
+				maxV[i] = math.Max(maxV[i], float64(c))
+			}
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: start, ByteLength: buf.Len() - start, Target: gltfArrayBuffer})
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView: len(doc.BufferViews) - 1, ComponentType: gltfFloat,
+			Count: len(part.Vertices), Type: "VEC3", Min: minV, Max: maxV,
+		})
+		positions := len(doc.Accessors) - 1
+
+		// Indices
+		start = buf.Len()
+		for _, t := range part.Triangles {
+			_ = binary.Write(&buf, binary.LittleEndian, t)
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: start, ByteLength: buf.Len() - start, Target: gltfElementBuffer})
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView: len(doc.BufferViews) - 1, ComponentType: gltfUnsignedInt,
+			Count: 3 * len(part.Triangles), Type: "SCALAR",
+		})
+
+		material := gltfMaterial{Name: part.Name, DoubleSided: true}
+		material.PBR.BaseColorFactor = part.Color
+		material.PBR.RoughnessFactor = 0.9
+		doc.Materials = append(doc.Materials, material)
+		doc.Meshes = append(doc.Meshes, gltfMesh{Name: part.Name, Primitives: []gltfPrimitive{{
+			Attributes: map[string]int{"POSITION": positions},
+			Indices:    len(doc.Accessors) - 1,
+			Material:   len(doc.Materials) - 1,
+		}}})
+		doc.Nodes = append(doc.Nodes, gltfNode{Name: part.Name, Mesh: len(doc.Meshes) - 1})
+		doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, len(doc.Nodes)-1)
+	}
+	doc.Buffers = []gltfBuffer{{
+		ByteLength: buf.Len(),
+		URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(doc)
+}
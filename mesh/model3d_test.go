@@ -0,0 +1,148 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// modelTestMap is a 4m x 3m room with a 1m square pillar hole in its floor
+// and one wall along its top edge.
+func modelTestMap() *UnifiedMap {
+	um := NewUnifiedMap(1, "vac")
+	um.Floors = []*UnifiedFeature{{Geometry: PathsToPolygon([]Path{
+		{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 3000}, {X: 0, Y: 3000}},
+		{{X: 1000, Y: 1000}, {X: 2000, Y: 1000}, {X: 2000, Y: 2000}, {X: 1000, Y: 2000}},
+	})}}
+	um.Walls = []*UnifiedFeature{{Geometry: PathToLineString(Path{{X: 0, Y: 0}, {X: 4000, Y: 0}})}}
+	return um
+}
+
+// faceArea sums the area of part's triangles whose normal points along dir.
+func faceArea(part *ModelPart, dir Vec3) float64 {
+	area := 0.0
+	for _, t := range part.Triangles {
+		a, b, c := part.Vertices[t[0]], part.Vertices[t[1]], part.Vertices[t[2]]
+		u := Vec3{b.X - a.X, b.Y - a.Y, b.Z - a.Z}
+		v := Vec3{c.X - a.X, c.Y - a.Y, c.Z - a.Z}
+		n := Vec3{u.Y*v.Z - u.Z*v.Y, u.Z*v.X - u.X*v.Z, u.X*v.Y - u.Y*v.X}
+		length := math.Sqrt(n.X*n.X + n.Y*n.Y + n.Z*n.Z)
+		if length > 0 && (n.X*dir.X+n.Y*dir.Y+n.Z*dir.Z)/length > 0.99 {
+			area += length / 2
+		}
+	}
+	return area
+}
+
+func TestBuildModel(t *testing.T) {
+	model := BuildModel(modelTestMap(), Model3DConfig{}.Options())
+	if len(model.Parts) != 2 || model.Parts[0].Name != "floors" || model.Parts[1].Name != "walls" {
+		t.Fatalf("parts = %+v, want floors and walls", model.Parts)
+	}
+	floors, walls := model.Parts[0], model.Parts[1]
+
+	// The floor's top is the room less the pillar: 12m² - 1m², facing up,
+	// and as much faces down
+	if got := faceArea(floors, Vec3{Y: 1}); math.Abs(got-11) > 0.01 {
+		t.Errorf("floor top = %.3f m², want 11", got)
+	}
+	if got := faceArea(floors, Vec3{Y: -1}); math.Abs(got-11) > 0.01 {
+		t.Errorf("floor bottom = %.3f m², want 11", got)
+	}
+
+	// The wall is 4m long, 2.4m high and 8cm thick
+	maxY := 0.0
+	for _, v := range walls.Vertices {
+		maxY = math.Max(maxY, v.Y)
+	}
+	if maxY != 2.4 {
+		t.Errorf("wall height = %.3f m, want 2.4", maxY)
+	}
+	if got := faceArea(walls, Vec3{Y: 1}); math.Abs(got-4*0.08) > 1e-6 {
+		t.Errorf("wall top = %.4f m², want 0.32", got)
+	}
+	// Its long sides face away from the wall line, along model Z (map Y)
+	if got := faceArea(walls, Vec3{Z: 1}) + faceArea(walls, Vec3{Z: -1}); math.Abs(got-2*4*2.4) > 1e-6 {
+		t.Errorf("wall sides = %.3f m², want 19.2", got)
+	}
+
+	custom := BuildModel(modelTestMap(), Model3DConfig{WallHeight: 1000}.Options())
+	for _, v := range custom.Parts[1].Vertices {
+		if v.Y > 1 {
+			t.Fatalf("wall vertex at %.3f m with wallHeight 1000", v.Y)
+		}
+	}
+
+	if got := BuildModel(nil, ModelOptions{}); len(got.Parts) != 0 {
+		t.Errorf("nil map built %d parts", len(got.Parts))
+	}
+}
+
+func TestWriteOBJ(t *testing.T) {
+	model := BuildModel(modelTestMap(), Model3DConfig{}.Options())
+	var buf bytes.Buffer
+	if err := WriteOBJ(&buf, model); err != nil {
+		t.Fatal(err)
+	}
+	vertices, faces := 0, 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		switch {
+		case strings.HasPrefix(line, "v "):
+			vertices++
+		case strings.HasPrefix(line, "f "):
+			faces++
+		}
+	}
+	wantV := len(model.Parts[0].Vertices) + len(model.Parts[1].Vertices)
+	wantF := len(model.Parts[0].Triangles) + len(model.Parts[1].Triangles)
+	if vertices != wantV || faces != wantF {
+		t.Errorf("OBJ has %d vertices and %d faces, want %d and %d", vertices, faces, wantV, wantF)
+	}
+	if !strings.Contains(buf.String(), "o walls\n") {
+		t.Error("OBJ has no walls object")
+	}
+	// The last face refers to the last vertex, counting across objects
+	if !strings.Contains(buf.String(), " "+strconv.Itoa(wantV)+"\n") {
+		t.Errorf("no face refers to vertex %d", wantV)
+	}
+}
+
+func TestWriteGLTF(t *testing.T) {
+	model := BuildModel(modelTestMap(), Model3DConfig{}.Options())
+	var buf bytes.Buffer
+	if err := WriteGLTF(&buf, model); err != nil {
+		t.Fatal(err)
+	}
+	var doc gltfDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.Asset["version"] != "2.0" {
+		t.Errorf("asset version = %q", doc.Asset["version"])
+	}
+	if len(doc.Meshes) != 2 || len(doc.Scenes[0].Nodes) != 2 || len(doc.Accessors) != 4 {
+		t.Fatalf("got %d meshes, %d nodes, %d accessors; want 2, 2, 4", len(doc.Meshes), len(doc.Scenes[0].Nodes), len(doc.Accessors))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(doc.Buffers[0].URI, "data:application/octet-stream;base64,"))
+	if err != nil {
+		t.Fatalf("buffer URI: %v", err)
+	}
+	if len(data) != doc.Buffers[0].ByteLength {
+		t.Errorf("buffer has %d bytes, byteLength says %d", len(data), doc.Buffers[0].ByteLength)
+	}
+	for _, view := range doc.BufferViews {
+		if view.ByteOffset+view.ByteLength > len(data) || view.ByteOffset%4 != 0 {
+			t.Errorf("buffer view %+v outside the buffer or misaligned", view)
+		}
+	}
+
+	walls := doc.Accessors[doc.Meshes[1].Primitives[0].Attributes["POSITION"]]
+	if walls.Count != len(model.Parts[1].Vertices) || math.Abs(walls.Max[1]-2.4) > 1e-6 {
+		t.Errorf("wall positions: count %d, max %v", walls.Count, walls.Max)
+	}
+}
@@ -1,9 +1,11 @@
 package mesh
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -15,6 +17,9 @@ import (
 // DockingHandler is called when a vacuum enters the 'docked' state
 type DockingHandler func(vacuumID string)
 
+// BatteryHandler is called with a vacuum's battery level, in percent
+type BatteryHandler func(vacuumID string, level int)
+
 // MQTTClientInterface defines the minimal set of MQTT operations we use.
 // This matches a subset of paho.mqtt.Client for easier mocking.
 type MQTTClientInterface interface {
@@ -31,7 +36,17 @@ type MQTTClient struct {
 	config         *Config
 	messageHandler MessageHandler
 	dockingHandler DockingHandler
+	batteryHandler BatteryHandler
+	recorder       *Recorder
+	connectHooks   []func(MQTTClientInterface)
 	isConnected    bool
+	lastPayload    map[string][sha256.Size]byte // hash of each vacuum's last map payload
+	broker         string                       // for logs
+	vacuums        []VacuumConfig               // subscribed here; nil means every vacuum without its own broker
+	children       []*MQTTClient                // connections to the vacuums' own brokers
+	output         *MQTTClient                  // connection to mqtt.output, if configured
+	stop           chan struct{}                // closed by Disconnect to end connectWithRetry
+	stopOnce       sync.Once
 	mu             sync.RWMutex
 }
 
@@ -46,7 +61,9 @@ var (
 )
 
 // InitMQTT initializes the global MQTT client with the provided configuration
-// If MQTT_BROKER env var is empty, MQTT is disabled and this returns nil
+// If MQTT_BROKER env var is empty, MQTT is disabled and this returns nil.
+// Vacuums with their own mqtt broker and mqtt.output get connections of
+// their own, managed by the returned client.
 func InitMQTT(config *Config, handler MessageHandler) (*MQTTClient, error) {
 	clientMu.Lock()
 	defer clientMu.Unlock()
@@ -66,15 +83,6 @@ func InitMQTT(config *Config, handler MessageHandler) (*MQTTClient, error) {
 		return nil, fmt.Errorf("MQTT enabled but no vacuum configuration provided")
 	}
 
-	client := &MQTTClient{
-		config:         config,
-		messageHandler: handler,
-	}
-
-	// Build MQTT client options
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(broker)
-
 	// Client ID
 	clientID := os.Getenv("MQTT_CLIENT_ID")
 	if clientID == "" && config.MQTT.ClientID != "" {
@@ -83,19 +91,70 @@ func InitMQTT(config *Config, handler MessageHandler) (*MQTTClient, error) {
 	if clientID == "" {
 		clientID = "tudomesh"
 	}
-	opts.SetClientID(clientID)
 
 	// Authentication
 	username := os.Getenv("MQTT_USERNAME")
 	if username == "" && config.MQTT.Username != "" {
 		username = config.MQTT.Username
 	}
+	password := ""
 	if username != "" {
-		opts.SetUsername(username)
-		password := os.Getenv("MQTT_PASSWORD")
+		password = os.Getenv("MQTT_PASSWORD")
 		if password == "" && config.MQTT.Password != "" {
 			password = config.MQTT.Password
 		}
+	}
+
+	client := &MQTTClient{
+		config:         config,
+		messageHandler: handler,
+		broker:         broker,
+	}
+	client.connect(clientID, username, password)
+
+	// Vacuums on their own brokers get one connection per broker
+	for _, group := range groupVacuumBrokers(config.Vacuums) {
+		b := group[0].MQTT
+		child := &MQTTClient{
+			config:         config,
+			messageHandler: handler,
+			broker:         b.Broker,
+			vacuums:        group,
+		}
+		id := b.ClientID
+		if id == "" {
+			id = clientID + "-" + group[0].ID
+		}
+		child.connect(id, b.Username, b.Password)
+		client.children = append(client.children, child)
+	}
+
+	if b := config.MQTT.Output; b != nil {
+		output := &MQTTClient{
+			config:  config,
+			broker:  b.Broker,
+			vacuums: []VacuumConfig{},
+		}
+		id := b.ClientID
+		if id == "" {
+			id = clientID + "-output"
+		}
+		output.connect(id, b.Username, b.Password)
+		client.output = output
+	}
+
+	globalClient = client
+	return client, nil
+}
+
+// connect creates the paho client for c.broker and connects in the
+// background, retrying until it succeeds.
+func (c *MQTTClient) connect(clientID, username, password string) {
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(c.broker)
+	opts.SetClientID(clientID)
+	if username != "" {
+		opts.SetUsername(username)
 		opts.SetPassword(password)
 	}
 
@@ -110,23 +169,44 @@ func InitMQTT(config *Config, handler MessageHandler) (*MQTTClient, error) {
 	opts.SetOrderMatters(false)           // Allow concurrent processing
 
 	// Callbacks
-	opts.SetOnConnectHandler(func(c mqtt.Client) {
-		client.onConnect(c)
+	opts.SetOnConnectHandler(func(mc mqtt.Client) {
+		c.onConnect(mc)
 	})
-	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
-		client.onConnectionLost(c, err)
+	opts.SetConnectionLostHandler(func(mc mqtt.Client, err error) {
+		c.onConnectionLost(mc, err)
 	})
-	opts.SetReconnectingHandler(func(c mqtt.Client, opts *mqtt.ClientOptions) {
-		client.onReconnecting(c, opts)
+	opts.SetReconnectingHandler(func(mc mqtt.Client, opts *mqtt.ClientOptions) {
+		c.onReconnecting(mc, opts)
 	})
 
-	client.client = mqtt.NewClient(opts)
+	c.client = mqtt.NewClient(opts)
 
 	// Connect asynchronously with retry
-	go client.connectWithRetry()
+	c.stop = make(chan struct{})
+	go c.connectWithRetry()
+}
 
-	globalClient = client
-	return client, nil
+// groupVacuumBrokers groups the vacuums that set their own broker by broker
+// and credentials, so vacuums sharing a broker share a connection. Groups
+// are in config order.
+func groupVacuumBrokers(vacuums []VacuumConfig) [][]VacuumConfig {
+	var groups [][]VacuumConfig
+	index := make(map[BrokerConfig]int)
+	for _, vc := range vacuums {
+		if vc.MQTT == nil {
+			continue
+		}
+		key := *vc.MQTT
+		key.PasswordFile = ""
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], vc)
+	}
+	return groups
 }
 
 // GetMQTTClient returns the global MQTT client instance
@@ -136,18 +216,24 @@ func GetMQTTClient() *MQTTClient {
 	return globalClient
 }
 
-// connectWithRetry attempts to connect to the MQTT broker with exponential backoff
+// connectWithRetry attempts to connect to the MQTT broker with exponential
+// backoff until it succeeds or Disconnect is called.
 func (c *MQTTClient) connectWithRetry() {
 	retryDelay := 1 * time.Second
 	maxRetryDelay := 60 * time.Second
 
 	for {
-		log.Println("Connecting to MQTT broker...")
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+		log.Printf("Connecting to MQTT broker %s...", c.broker)
 
 		token := c.client.Connect()
 		if token.WaitTimeout(10 * time.Second) {
 			if token.Error() == nil {
-				log.Println("Successfully connected to MQTT broker")
+				log.Printf("Successfully connected to MQTT broker %s", c.broker)
 				c.setConnected(true)
 				return
 			}
@@ -158,7 +244,11 @@ func (c *MQTTClient) connectWithRetry() {
 
 		// Exponential backoff
 		log.Printf("Retrying MQTT connection in %v...", retryDelay)
-		time.Sleep(retryDelay)
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(retryDelay):
+		}
 		retryDelay *= 2
 		if retryDelay > maxRetryDelay {
 			retryDelay = maxRetryDelay
@@ -171,8 +261,8 @@ func (c *MQTTClient) onConnect(client MQTTClientInterface) {
 	log.Println("MQTT connected, subscribing to vacuum topics...")
 	c.setConnected(true)
 
-	// Subscribe to all vacuum topics from config
-	for _, vacuum := range c.config.Vacuums {
+	// Subscribe to the topics of the vacuums on this broker
+	for _, vacuum := range c.subscriptions() {
 		if vacuum.Topic == "" {
 			log.Printf("Warning: vacuum %s has no topic configured", vacuum.ID)
 			continue
@@ -198,9 +288,55 @@ func (c *MQTTClient) onConnect(client MQTTClientInterface) {
 				log.Printf("Successfully subscribed to %s", stateTopic)
 			}
 		}
+
+		// Subscribe to the battery level for marker styling and alerts
+		if batteryTopic, ok := deriveBatteryTopic(vacuum.Topic); ok {
+			log.Printf("Subscribing to %s for vacuum %s battery", batteryTopic, vacuum.ID)
+			batteryToken := client.Subscribe(batteryTopic, 0, c.createBatteryMessageHandler(vacuum.ID))
+
+			if batteryToken.WaitTimeout(5*time.Second) && batteryToken.Error() != nil {
+				log.Printf("Error subscribing to %s: %v", batteryTopic, batteryToken.Error())
+			} else {
+				log.Printf("Successfully subscribed to %s", batteryTopic)
+			}
+		}
+	}
+
+	for _, hook := range c.getConnectHooks() {
+		hook(client)
 	}
 }
 
+// subscriptions returns the vacuums whose topics this connection subscribes
+// to.
+func (c *MQTTClient) subscriptions() []VacuumConfig {
+	if c.vacuums != nil {
+		return c.vacuums
+	}
+	var vacuums []VacuumConfig
+	for _, vc := range c.config.Vacuums {
+		if vc.MQTT == nil {
+			vacuums = append(vacuums, vc)
+		}
+	}
+	return vacuums
+}
+
+// AddConnectHandler registers a callback run after the vacuum subscriptions on
+// every (re)connect, for components that need their own subscriptions.
+func (c *MQTTClient) AddConnectHandler(hook func(MQTTClientInterface)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connectHooks = append(c.connectHooks, hook)
+}
+
+// getConnectHooks returns a copy of the registered connect hooks
+func (c *MQTTClient) getConnectHooks() []func(MQTTClientInterface) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]func(MQTTClientInterface){}, c.connectHooks...)
+}
+
 // onConnectionLost is called when the MQTT connection is lost
 // Auto-reconnect is enabled, so this is typically a transient event
 func (c *MQTTClient) onConnectionLost(client MQTTClientInterface, err error) {
@@ -216,12 +352,24 @@ func (c *MQTTClient) onReconnecting(client MQTTClientInterface, opts *mqtt.Clien
 // createMessageHandler creates a handler function for a specific vacuum's topic
 func (c *MQTTClient) createMessageHandler(vacuumID string) mqtt.MessageHandler {
 	return func(client mqtt.Client, msg mqtt.Message) {
+		defer RecoverPanic("map message for " + vacuumID)
 		payload := msg.Payload()
 		log.Printf("Received map data for %s (topic: %s, size: %d bytes)",
 			vacuumID, msg.Topic(), len(payload))
+		c.record(vacuumID, msg)
+
+		// Valetudo republishes identical maps; skip decoding them again
+		if c.payloadUnchanged(vacuumID, payload) {
+			log.Printf("Map data for %s unchanged, skipping", vacuumID)
+			return
+		}
+
+		trace := StartTrace("mqtt map " + vacuumID)
+		defer trace.End()
 
 		// Decode the map data (handles PNG with zTXt, raw JSON, or compressed JSON)
-		mapData, err := DecodeMapData(payload)
+		trace.Step("decode")
+		mapData, err := DecodeMapDataWithOptions(payload, c.config.ParseOptions())
 		if err != nil {
 			log.Printf("Error decoding map data for %s: %v", vacuumID, err)
 			if c.messageHandler != nil {
@@ -232,6 +380,7 @@ func (c *MQTTClient) createMessageHandler(vacuumID string) mqtt.MessageHandler {
 		}
 
 		// Call the user's message handler with raw payload and decoded data
+		trace.Step("handle")
 		if c.messageHandler != nil {
 			c.messageHandler(vacuumID, payload, mapData, nil)
 		}
@@ -241,8 +390,54 @@ func (c *MQTTClient) createMessageHandler(vacuumID string) mqtt.MessageHandler {
 // SetDockingHandler registers a callback that is invoked when a vacuum docks
 func (c *MQTTClient) SetDockingHandler(handler DockingHandler) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.dockingHandler = handler
+	c.mu.Unlock()
+	for _, child := range c.children {
+		child.SetDockingHandler(handler)
+	}
+}
+
+// SetBatteryHandler registers a callback that is invoked with every battery
+// level a vacuum reports
+func (c *MQTTClient) SetBatteryHandler(handler BatteryHandler) {
+	c.mu.Lock()
+	c.batteryHandler = handler
+	c.mu.Unlock()
+	for _, child := range c.children {
+		child.SetBatteryHandler(handler)
+	}
+}
+
+// getBatteryHandler returns the current battery handler in a thread-safe manner
+func (c *MQTTClient) getBatteryHandler() BatteryHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.batteryHandler
+}
+
+// SetRecorder archives every map and state message received from now on.
+// Pass nil to stop recording.
+func (c *MQTTClient) SetRecorder(r *Recorder) {
+	c.mu.Lock()
+	c.recorder = r
+	c.mu.Unlock()
+	for _, child := range c.children {
+		child.SetRecorder(r)
+	}
+}
+
+// record archives msg if a recorder is set
+func (c *MQTTClient) record(vacuumID string, msg mqtt.Message) {
+	c.mu.RLock()
+	r := c.recorder
+	c.mu.RUnlock()
+	if r == nil {
+		return
+	}
+	err := r.Record(RecordedMessage{Time: time.Now().UTC(), Topic: msg.Topic(), VacuumID: vacuumID, Payload: msg.Payload()})
+	if err != nil {
+		log.Printf("Error recording message for %s: %v", vacuumID, err)
+	}
 }
 
 // getDockingHandler returns the current docking handler in a thread-safe manner
@@ -276,9 +471,11 @@ type statePayload struct {
 // detects docking events and invokes the docking handler
 func (c *MQTTClient) createStateMessageHandler(vacuumID string) mqtt.MessageHandler {
 	return func(client mqtt.Client, msg mqtt.Message) {
+		defer RecoverPanic("state message for " + vacuumID)
 		payload := msg.Payload()
 		log.Printf("Received state update for %s (topic: %s, size: %d bytes)",
 			vacuumID, msg.Topic(), len(payload))
+		c.record(vacuumID, msg)
 
 		var stateValue string
 
@@ -314,6 +511,69 @@ func (c *MQTTClient) createStateMessageHandler(vacuumID string) mqtt.MessageHand
 	}
 }
 
+// deriveBatteryTopic converts a map data topic to a battery level topic.
+// Example: "valetudo/rocky7/MapData/map-data" -> "valetudo/rocky7/BatteryStateAttribute/level"
+func deriveBatteryTopic(mapDataTopic string) (string, bool) {
+	parts := strings.Split(mapDataTopic, "/")
+	if len(parts) < 4 {
+		return "", false
+	}
+	parts[len(parts)-2] = "BatteryStateAttribute"
+	parts[len(parts)-1] = "level"
+	return strings.Join(parts, "/"), true
+}
+
+// parseBatteryLevel reads a battery level payload: Valetudo publishes a plain
+// number such as "87", but a JSON object {"value": 87} is accepted too.
+func parseBatteryLevel(payload []byte) (int, bool) {
+	text := strings.TrimSpace(string(payload))
+	var level float64
+	if err := json.Unmarshal([]byte(text), &level); err != nil {
+		var obj struct {
+			Value *float64 `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(text), &obj); err != nil || obj.Value == nil {
+			return 0, false
+		}
+		level = *obj.Value
+	}
+	if level < 0 || level > 100 {
+		return 0, false
+	}
+	return int(math.Round(level)), true
+}
+
+// createBatteryMessageHandler creates a handler for battery level messages
+// that passes each valid level to the battery handler
+func (c *MQTTClient) createBatteryMessageHandler(vacuumID string) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		defer RecoverPanic("battery message for " + vacuumID)
+		c.record(vacuumID, msg)
+		level, ok := parseBatteryLevel(msg.Payload())
+		if !ok {
+			log.Printf("Ignoring battery payload for %s: %q", vacuumID, msg.Payload())
+			return
+		}
+		if handler := c.getBatteryHandler(); handler != nil {
+			handler(vacuumID, level)
+		}
+	}
+}
+
+// payloadUnchanged records the hash of a vacuum's map payload and reports
+// whether it matches the previous one.
+func (c *MQTTClient) payloadUnchanged(vacuumID string, payload []byte) bool {
+	sum := sha256.Sum256(payload)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastPayload == nil {
+		c.lastPayload = make(map[string][sha256.Size]byte)
+	}
+	prev, ok := c.lastPayload[vacuumID]
+	c.lastPayload[vacuumID] = sum
+	return ok && prev == sum
+}
+
 // IsConnected returns true if the MQTT client is connected
 func (c *MQTTClient) IsConnected() bool {
 	c.mu.RLock()
@@ -328,12 +588,28 @@ func (c *MQTTClient) setConnected(connected bool) {
 	c.isConnected = connected
 }
 
-// Disconnect gracefully closes the MQTT connection
+// Disconnect gracefully closes the MQTT connections and stops connection
+// attempts that are still retrying.
 func (c *MQTTClient) Disconnect() {
-	if c.client != nil && c.client.IsConnected() {
-		log.Println("Disconnecting from MQTT broker...")
+	for _, child := range c.children {
+		child.Disconnect()
+	}
+	if c.output != nil {
+		c.output.Disconnect()
+	}
+	if c.stop != nil {
+		c.stopOnce.Do(func() { close(c.stop) })
+	}
+	if c.client == nil {
+		return
+	}
+	if c.client.IsConnected() {
+		log.Printf("Disconnecting from MQTT broker %s...", c.broker)
 		c.client.Disconnect(250) // 250ms quiesce time
 		c.setConnected(false)
+	} else if c.stop != nil {
+		// Never connected: end the client's own connect retries too
+		c.client.Disconnect(0)
 	}
 }
 
@@ -352,6 +628,27 @@ func (c *MQTTClient) GetClient() MQTTClientInterface {
 	return c.client
 }
 
+// OutputClient returns the connection positions and map changes are
+// published on: mqtt.output when configured, otherwise the main broker.
+func (c *MQTTClient) OutputClient() MQTTClientInterface {
+	if c.output != nil {
+		return c.output.client
+	}
+	return c.client
+}
+
+// VacuumClients returns the connection of each vacuum that has its own
+// broker. Other vacuums use GetClient.
+func (c *MQTTClient) VacuumClients() map[string]MQTTClientInterface {
+	clients := make(map[string]MQTTClientInterface)
+	for _, child := range c.children {
+		for _, vc := range child.vacuums {
+			clients[vc.ID] = child.client
+		}
+	}
+	return clients
+}
+
 // newMQTTClientWithMock creates an MQTTClient with a provided MQTTClientInterface
 // This is used for testing with mock clients
 func newMQTTClientWithMock(client MQTTClientInterface, config *Config, handler MessageHandler) *MQTTClient {
@@ -205,6 +205,54 @@ func TestMQTTConfig_FromEnvAndConfig(t *testing.T) {
 	}
 }
 
+func TestMessageHandler_SkipsIdenticalPayload(t *testing.T) {
+	calls := 0
+	client := &MQTTClient{
+		config:         &Config{},
+		messageHandler: func(string, []byte, *ValetudoMap, error) { calls++ },
+	}
+	handler := client.createMessageHandler("vacuum1")
+	payload := []byte(`{"__class":"ValetudoMap","pixelSize":5,"layers":[],"entities":[]}`)
+	other := []byte(`{"__class":"ValetudoMap","pixelSize":5,"layers":[],"entities":[{"type":"robot_position","points":[1,2]}]}`)
+
+	handler(nil, &mockMessage{topic: "t", payload: payload})
+	handler(nil, &mockMessage{topic: "t", payload: payload})
+	if calls != 1 {
+		t.Errorf("handler called %d times for a repeated payload, want 1", calls)
+	}
+	handler(nil, &mockMessage{topic: "t", payload: other})
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2 after a new payload", calls)
+	}
+}
+
+func TestMessageHandler_RecoversFromPanic(t *testing.T) {
+	calls := 0
+	client := &MQTTClient{
+		config: &Config{},
+		messageHandler: func(string, []byte, *ValetudoMap, error) {
+			calls++
+			if calls == 1 {
+				panic("index out of range")
+			}
+		},
+	}
+	client.SetDockingHandler(func(string) { panic("docked") })
+	before := RecoveredPanics()
+
+	handler := client.createMessageHandler("vacuum1")
+	handler(nil, &mockMessage{topic: "t", payload: []byte(`{"__class":"ValetudoMap","pixelSize":5,"layers":[],"entities":[]}`)})
+	handler(nil, &mockMessage{topic: "t", payload: []byte(`{"__class":"ValetudoMap","pixelSize":4,"layers":[],"entities":[]}`)})
+	client.createStateMessageHandler("vacuum1")(nil, &mockMessage{topic: "s", payload: []byte(`{"value":"docked"}`)})
+
+	if calls != 2 {
+		t.Errorf("handler called %d times, want the message after the panic handled too", calls)
+	}
+	if got := RecoveredPanics() - before; got != 2 {
+		t.Errorf("recovered %d panics, want 2", got)
+	}
+}
+
 // Benchmark MQTT message handler creation
 func BenchmarkCreateMessageHandler(b *testing.B) {
 	config := &Config{
@@ -291,6 +339,89 @@ func TestInitMQTT_ReturnsImmediately(t *testing.T) {
 	}
 }
 
+func TestInitMQTT_MultiBroker(t *testing.T) {
+	config := &Config{
+		MQTT: MQTTConfig{
+			Broker: "mqtt://localhost:1883",
+			Output: &BrokerConfig{Broker: "mqtt://localhost:1884"},
+		},
+		Vacuums: []VacuumConfig{
+			{ID: "main", Topic: "valetudo/main/MapData/map-data"},
+			{ID: "iot1", Topic: "valetudo/iot1/MapData/map-data", MQTT: &BrokerConfig{Broker: "mqtt://localhost:1885"}},
+			{ID: "iot2", Topic: "valetudo/iot2/MapData/map-data", MQTT: &BrokerConfig{Broker: "mqtt://localhost:1885"}},
+		},
+	}
+
+	client, err := InitMQTT(config, func(string, []byte, *ValetudoMap, error) {})
+	if err != nil {
+		t.Fatalf("InitMQTT() error = %v", err)
+	}
+	defer client.Disconnect()
+
+	if len(client.children) != 1 || len(client.children[0].vacuums) != 2 {
+		t.Fatalf("children = %d, want one connection for iot1 and iot2", len(client.children))
+	}
+	if client.OutputClient() == client.GetClient() {
+		t.Error("OutputClient() should be the mqtt.output connection")
+	}
+	clients := client.VacuumClients()
+	if len(clients) != 2 || clients["iot1"] != client.children[0].GetClient() || clients["main"] != nil {
+		t.Errorf("VacuumClients() = %v, want iot1 and iot2 on the child connection", clients)
+	}
+
+	// None of the brokers is reachable; disconnecting ends every retry loop
+	client.Disconnect()
+	for _, c := range []*MQTTClient{client, client.children[0], client.output} {
+		select {
+		case <-c.stop:
+		default:
+			t.Errorf("connection to %s still retrying after Disconnect", c.broker)
+		}
+	}
+}
+
+func TestGroupVacuumBrokers(t *testing.T) {
+	iot := &BrokerConfig{Broker: "tcp://iot:1883", Username: "robots"}
+	vacuums := []VacuumConfig{
+		{ID: "a", MQTT: iot},
+		{ID: "b"},
+		{ID: "c", MQTT: &BrokerConfig{Broker: "tcp://iot:1883", Username: "robots"}},
+		{ID: "d", MQTT: &BrokerConfig{Broker: "tcp://iot:1883", Username: "other"}},
+	}
+	groups := groupVacuumBrokers(vacuums)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	if len(groups[0]) != 2 || groups[0][0].ID != "a" || groups[0][1].ID != "c" {
+		t.Errorf("first group = %v, want a and c", groups[0])
+	}
+	if len(groups[1]) != 1 || groups[1][0].ID != "d" {
+		t.Errorf("second group = %v, want d", groups[1])
+	}
+}
+
+func TestOnConnect_SkipsVacuumsWithOwnBroker(t *testing.T) {
+	mockClient := NewMockClient()
+	config := &Config{
+		Vacuums: []VacuumConfig{
+			{ID: "vacuum1", Topic: "valetudo/vacuum1/MapData/map-data"},
+			{ID: "vacuum2", Topic: "valetudo/vacuum2/MapData/map-data", MQTT: &BrokerConfig{Broker: "tcp://iot:1883"}},
+		},
+	}
+
+	client := newMQTTClientWithMock(mockClient, config, func(string, []byte, *ValetudoMap, error) {})
+	client.onConnect(mockClient)
+
+	mockClient.mu.RLock()
+	defer mockClient.mu.RUnlock()
+	if _, ok := mockClient.messageHandlers["valetudo/vacuum1/MapData/map-data"]; !ok {
+		t.Error("vacuum1 should be subscribed on the main broker")
+	}
+	if _, ok := mockClient.messageHandlers["valetudo/vacuum2/MapData/map-data"]; ok {
+		t.Error("vacuum2 has its own broker and should not be subscribed on the main one")
+	}
+}
+
 // --- Docking detection tests ---
 
 func TestDeriveStateTopic(t *testing.T) {
@@ -658,7 +789,7 @@ func TestOnConnect_SubscribesStateTopics(t *testing.T) {
 
 	client.onConnect(mockClient)
 
-	// Should have 4 subscriptions: 2 map data + 2 state topics
+	// Should have 6 subscriptions: 2 map data + 2 state + 2 battery topics
 	mockClient.mu.RLock()
 	handlers := len(mockClient.messageHandlers)
 	topics := make([]string, 0, len(mockClient.messageHandlers))
@@ -667,12 +798,14 @@ func TestOnConnect_SubscribesStateTopics(t *testing.T) {
 	}
 	mockClient.mu.RUnlock()
 
-	assert.Equal(t, 4, handlers, "Topics: %v", topics)
+	assert.Equal(t, 6, handlers, "Topics: %v", topics)
 
-	// Verify specific state topics are subscribed
+	// Verify specific state and battery topics are subscribed
 	expectedStateTopics := []string{
 		"valetudo/vacuum1/StatusStateAttribute/status",
 		"valetudo/vacuum2/StatusStateAttribute/status",
+		"valetudo/vacuum1/BatteryStateAttribute/level",
+		"valetudo/vacuum2/BatteryStateAttribute/level",
 	}
 
 	mockClient.mu.RLock()
@@ -749,3 +882,53 @@ func BenchmarkCreateStateMessageHandler(b *testing.B) {
 		_ = client.createStateMessageHandler("vacuum1")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Battery level
+// ---------------------------------------------------------------------------
+
+func TestDeriveBatteryTopic(t *testing.T) {
+	got, ok := deriveBatteryTopic("valetudo/rocky7/MapData/map-data")
+	if !ok || got != "valetudo/rocky7/BatteryStateAttribute/level" {
+		t.Errorf("deriveBatteryTopic() = (%q, %v)", got, ok)
+	}
+	if _, ok := deriveBatteryTopic("short/topic"); ok {
+		t.Error("a short topic should not derive a battery topic")
+	}
+}
+
+func TestBatteryMessageHandler(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    int
+		ok      bool
+	}{
+		{"87", 87, true},
+		{" 5\n", 5, true},
+		{`{"value":42}`, 42, true},
+		{"99.6", 100, true},
+		{"150", 0, false},
+		{"-1", 0, false},
+		{"charging", 0, false},
+		{`{"level":42}`, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			client := &MQTTClient{}
+			got, called := 0, false
+			client.SetBatteryHandler(func(vacuumID string, level int) {
+				got, called = level, true
+			})
+
+			mock := NewMockClient()
+			topic := "valetudo/vacuum1/BatteryStateAttribute/level"
+			mock.Subscribe(topic, 0, client.createBatteryMessageHandler("vacuum1"))
+			mock.SimulateMessage(topic, []byte(tt.payload))
+
+			if called != tt.ok || got != tt.want {
+				t.Errorf("handler called=%v level=%d, want called=%v level=%d", called, got, tt.ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ROS occupancy grid values, as map_server reads a PGM with negate 0
+const (
+	DefaultOccupancyResolution = 0.05 // meters per cell, ROS navigation's usual grid
+	OccupancyOccupied          = 0
+	OccupancyFree              = 254
+	OccupancyUnknown           = 205
+	occupancyOccupiedThresh    = 0.65
+	occupancyFreeThresh        = 0.196
+)
+
+// OccupancyGrid is the unified map rasterized for ROS navigation: walls are
+// occupied, floors free and everything else unknown.
+type OccupancyGrid struct {
+	Resolution float64 // meters per cell
+	Width      int
+	Height     int
+	Cells      []byte  // row-major, top row first as in the map's top view
+	OriginX    float64 // world mm of the grid's top-left corner
+	OriginY    float64
+}
+
+// BuildOccupancyGrid rasterizes the unified map's floors and walls at
+// resolution meters per cell, with a one-cell unknown border. Floors are
+// filled by the even-odd rule at each cell's center, so their holes stay
+// unknown; walls are drawn over them one cell wide. It returns nil when the
+// map has neither floors nor walls.
+func BuildOccupancyGrid(um *UnifiedMap, resolution float64) *OccupancyGrid {
+	if um == nil || resolution <= 0 {
+		return nil
+	}
+	cell := resolution * 1000
+
+	var floors, walls [][]Point
+	for _, f := range um.Floors {
+		floors = append(floors, geometryPaths(f.Geometry)...)
+	}
+	for _, f := range um.Walls {
+		walls = append(walls, geometryPaths(f.Geometry)...)
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, path := range append(append([][]Point{}, floors...), walls...) {
+		for _, p := range path {
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return nil
+	}
+
+	g := &OccupancyGrid{
+		Resolution: resolution,
+		Width:      int(math.Ceil((maxX-minX)/cell)) + 3,
+		Height:     int(math.Ceil((maxY-minY)/cell)) + 3,
+		OriginX:    minX - cell,
+		OriginY:    minY - cell,
+	}
+	g.Cells = make([]byte, g.Width*g.Height)
+	for i := range g.Cells {
+		g.Cells[i] = OccupancyUnknown
+	}
+
+	// Floors, one scanline through the centers of each row
+	for _, f := range um.Floors {
+		rings := geometryPaths(f.Geometry)
+		for row := 0; row < g.Height; row++ {
+			xs := ringCrossings(rings, g.OriginY+(float64(row)+0.5)*cell)
+			for i := 0; i+1 < len(xs); i += 2 {
+				from := int(math.Ceil((xs[i]-g.OriginX)/cell - 0.5))
+				to := int(math.Floor((xs[i+1]-g.OriginX)/cell - 0.5))
+				for col := max(from, 0); col <= min(to, g.Width-1); col++ {
+					g.Cells[row*g.Width+col] = OccupancyFree
+				}
+			}
+		}
+	}
+
+	// Walls, sampled every quarter cell along each segment
+	for _, line := range walls {
+		for i := 0; i+1 < len(line); i++ {
+			a, b := line[i], line[i+1]
+			steps := int(math.Ceil(math.Hypot(b.X-a.X, b.Y-a.Y)/(cell/4))) + 1
+			for s := 0; s <= steps; s++ {
+				t := float64(s) / float64(steps)
+				if idx, ok := g.index(a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t); ok {
+					g.Cells[idx] = OccupancyOccupied
+				}
+			}
+		}
+	}
+	return g
+}
+
+// index returns the index in Cells of the cell holding the world point
+// (x, y), in mm, and whether it lies on the grid.
+func (g *OccupancyGrid) index(x, y float64) (int, bool) {
+	cell := g.Resolution * 1000
+	col := int(math.Floor((x - g.OriginX) / cell))
+	row := int(math.Floor((y - g.OriginY) / cell))
+	if col < 0 || col >= g.Width || row < 0 || row >= g.Height {
+		return 0, false
+	}
+	return row*g.Width + col, true
+}
+
+// At returns the cell holding the world point (x, y), in mm, or
+// OccupancyUnknown outside the grid.
+func (g *OccupancyGrid) At(x, y float64) byte {
+	if i, ok := g.index(x, y); ok {
+		return g.Cells[i]
+	}
+	return OccupancyUnknown
+}
+
+// WritePGM writes the grid as a binary (P5) PGM, top row first.
+func (g *OccupancyGrid) WritePGM(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "P5\n# tudomesh occupancy grid, %g m/cell\n%d %d\n255\n", g.Resolution, g.Width, g.Height); err != nil {
+		return err
+	}
+	if _, err := bw.Write(g.Cells); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteYAML writes the map_server metadata for the grid saved as image.
+// ROS maps have Y pointing up where the top view's Y points down, so the
+// world point (x, y) in mm is (x/1000, -y/1000) in the ROS map frame and the
+// origin is the pose of the grid's lower-left corner.
+func (g *OccupancyGrid) WriteYAML(w io.Writer, image string) error {
+	cell := g.Resolution * 1000
+	originX := g.OriginX / 1000
+	originY := -(g.OriginY + float64(g.Height)*cell) / 1000
+	_, err := fmt.Fprintf(w, "image: %s\nresolution: %g\norigin: [%.4f, %.4f, 0.0]\nnegate: 0\noccupied_thresh: %g\nfree_thresh: %g\n",
+		image, g.Resolution, originX, originY, occupancyOccupiedThresh, occupancyFreeThresh)
+	return err
+}
@@ -0,0 +1,69 @@
+package mesh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildOccupancyGrid(t *testing.T) {
+	g := BuildOccupancyGrid(modelTestMap(), DefaultOccupancyResolution)
+	if g == nil {
+		t.Fatal("expected a grid")
+	}
+	// 4m x 3m at 5cm plus a one-cell border on each side
+	if g.Width != 83 || g.Height != 63 {
+		t.Errorf("grid = %dx%d, want 83x63", g.Width, g.Height)
+	}
+
+	tests := []struct {
+		name string
+		x, y float64
+		want byte
+	}{
+		{"floor", 3000, 2500, OccupancyFree},
+		{"pillar hole", 1500, 1500, OccupancyUnknown},
+		{"wall", 2000, 0, OccupancyOccupied},
+		{"outside", -40, 1500, OccupancyUnknown},
+		{"off the grid", 9000, 9000, OccupancyUnknown},
+	}
+	for _, tt := range tests {
+		if got := g.At(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s: At(%g, %g) = %d, want %d", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	if BuildOccupancyGrid(NewUnifiedMap(0, ""), DefaultOccupancyResolution) != nil {
+		t.Error("expected nil for an empty map")
+	}
+	if BuildOccupancyGrid(nil, DefaultOccupancyResolution) != nil {
+		t.Error("expected nil for a nil map")
+	}
+}
+
+func TestOccupancyGrid_WritePGMAndYAML(t *testing.T) {
+	g := BuildOccupancyGrid(modelTestMap(), 0.1)
+
+	var pgm bytes.Buffer
+	if err := g.WritePGM(&pgm); err != nil {
+		t.Fatalf("WritePGM: %v", err)
+	}
+	header := "P5\n# tudomesh occupancy grid, 0.1 m/cell\n43 33\n255\n"
+	if !strings.HasPrefix(pgm.String(), header) {
+		t.Errorf("PGM header = %q, want %q", pgm.String()[:len(header)], header)
+	}
+	if got := pgm.Len() - len(header); got != 43*33 {
+		t.Errorf("PGM has %d pixels, want %d", got, 43*33)
+	}
+
+	// The grid's lower-left corner is at (-100, 3200) mm: (-0.1, -3.2) m
+	// with ROS's Y pointing up
+	var yaml bytes.Buffer
+	if err := g.WriteYAML(&yaml, "house.pgm"); err != nil {
+		t.Fatalf("WriteYAML: %v", err)
+	}
+	want := "image: house.pgm\nresolution: 0.1\norigin: [-0.1000, -3.2000, 0.0]\nnegate: 0\noccupied_thresh: 0.65\nfree_thresh: 0.196\n"
+	if yaml.String() != want {
+		t.Errorf("YAML =\n%s\nwant\n%s", yaml.String(), want)
+	}
+}
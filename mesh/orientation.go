@@ -0,0 +1,129 @@
+package mesh
+
+import (
+	"math"
+)
+
+// northUpSearchRange is how far (in degrees) NorthUpRotation searches either
+// side of the coarse histogram estimate when refining the wall angle.
+const northUpSearchRange = 22.5
+
+// northUpSearchStep is the angular resolution of the refinement search.
+const northUpSearchStep = 0.5
+
+// NorthUpRotation computes a GlobalRotation (degrees CCW) that makes the
+// dominant walls of m axis-aligned with the longest straight wall horizontal.
+// The result is normalized to (-90, 90] so the image turns as little as
+// possible. Maps without enough wall data return 0.
+func NorthUpRotation(m *ValetudoMap) float64 {
+	if m == nil {
+		return 0
+	}
+
+	var walls []Point
+	for _, layer := range m.Layers {
+		if layer.Type == "wall" {
+			walls = append(walls, PixelsToPoints(layer.Pixels)...)
+		}
+	}
+	if len(walls) < 2 {
+		return 0
+	}
+
+	// Coarse estimate from the wall angle histogram, folded into [0, 90)
+	// since perpendicular walls share an alignment
+	hist := ExtractWallAngles(m)
+	coarse := 0.0
+	if dominant := hist.DominantAngles(1); len(dominant) > 0 {
+		coarse = math.Mod(dominant[0], 90)
+	}
+
+	// Refine: pick the rotation whose row/column projections are sharpest,
+	// i.e. the one that lines the most wall pixels up on shared rows and
+	// columns
+	best, bestScore := -coarse, -1.0
+	for offset := -northUpSearchRange; offset <= northUpSearchRange; offset += northUpSearchStep {
+		rot := -coarse + offset
+		if score := projectionSharpness(walls, rot); score > bestScore {
+			best, bestScore = rot, score
+		}
+	}
+
+	// Longest straight wall horizontal: if the longest line is vertical after
+	// alignment, turn a further quarter
+	h, v := longestLines(walls, best)
+	if v > h {
+		best += 90
+	}
+
+	return normalizeQuarterTurn(best)
+}
+
+// rotatePoints rotates points CCW by deg degrees about the origin and snaps
+// them to the pixel grid.
+func rotatePoints(points []Point, deg float64) []Point {
+	rad := deg * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[i] = Point{
+			X: math.Round(p.X*cos - p.Y*sin),
+			Y: math.Round(p.X*sin + p.Y*cos),
+		}
+	}
+	return out
+}
+
+// projectionSharpness scores how concentrated the row and column
+// projections of the rotated points are (sum of squared counts).
+func projectionSharpness(points []Point, deg float64) float64 {
+	rows := make(map[float64]int)
+	cols := make(map[float64]int)
+	for _, p := range rotatePoints(points, deg) {
+		rows[p.Y]++
+		cols[p.X]++
+	}
+	score := 0.0
+	for _, n := range rows {
+		score += float64(n * n)
+	}
+	for _, n := range cols {
+		score += float64(n * n)
+	}
+	return score
+}
+
+// longestLines returns the most wall pixels found along a single
+// horizontal and a single vertical line after rotating by deg. Lines are
+// three pixels wide to absorb rounding jitter, and gaps such as doorways are
+// not penalized, so a long wall broken by doors still counts as one line.
+func longestLines(points []Point, deg float64) (horizontal, vertical int) {
+	rows := make(map[float64]int)
+	cols := make(map[float64]int)
+	for _, p := range rotatePoints(points, deg) {
+		rows[p.Y]++
+		cols[p.X]++
+	}
+
+	band := func(counts map[float64]int) int {
+		best := 0
+		for k := range counts {
+			best = max(best, counts[k-1]+counts[k]+counts[k+1])
+		}
+		return best
+	}
+	return band(rows), band(cols)
+}
+
+// normalizeQuarterTurn maps an angle into (-90, 90]. Rotating a further 180°
+// keeps walls on the same axes, so the smallest equivalent turn is used.
+func normalizeQuarterTurn(deg float64) float64 {
+	deg = math.Mod(deg, 180)
+	if deg > 90 {
+		deg -= 180
+	} else if deg <= -90 {
+		deg += 180
+	}
+	// Avoid -0 and float noise on cardinal results
+	return math.Round(deg*10) / 10
+}
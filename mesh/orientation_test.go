@@ -0,0 +1,84 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// rectangleWalls returns a wall map outlining a w x h rectangle rotated CCW
+// by deg degrees about the origin.
+func rectangleWalls(w, h int, deg float64) *ValetudoMap {
+	var outline []Point
+	for x := 0; x <= w; x++ {
+		outline = append(outline, Point{X: float64(x), Y: 0}, Point{X: float64(x), Y: float64(h)})
+	}
+	for y := 1; y < h; y++ {
+		outline = append(outline, Point{X: 0, Y: float64(y)}, Point{X: float64(w), Y: float64(y)})
+	}
+
+	seen := make(map[Point]bool)
+	var pixels []int
+	for _, p := range rotatePoints(outline, deg) {
+		p = Point{X: p.X + 500, Y: p.Y + 500}
+		if !seen[p] {
+			seen[p] = true
+			pixels = append(pixels, int(p.X), int(p.Y))
+		}
+	}
+	return &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "wall", Pixels: pixels}}}
+}
+
+// ---------------------------------------------------------------------------
+// NorthUpRotation
+// ---------------------------------------------------------------------------
+
+func TestNorthUpRotation(t *testing.T) {
+	tests := []struct {
+		name  string
+		w, h  int
+		tilt  float64
+		want  float64
+		delta float64
+	}{
+		{"already aligned", 200, 80, 0, 0, 0.01},
+		{"tilted", 200, 80, 30, -30, 1},
+		{"tilted the other way", 200, 80, -12, 12, 1},
+		{"longest wall vertical", 80, 200, 0, 90, 0.01},
+		{"vertical and tilted", 80, 200, 20, 70, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NorthUpRotation(rectangleWalls(tt.w, tt.h, tt.tilt))
+			if math.Abs(got-tt.want) > tt.delta {
+				t.Errorf("NorthUpRotation() = %.1f, want %.1f ± %.1f", got, tt.want, tt.delta)
+			}
+		})
+	}
+}
+
+func TestNorthUpRotation_NoWalls(t *testing.T) {
+	if got := NorthUpRotation(nil); got != 0 {
+		t.Errorf("nil map = %v, want 0", got)
+	}
+	m := &ValetudoMap{Layers: []MapLayer{{Type: "floor", Pixels: []int{0, 0, 1, 1}}}}
+	if got := NorthUpRotation(m); got != 0 {
+		t.Errorf("map without walls = %v, want 0", got)
+	}
+}
+
+func TestNormalizeQuarterTurn(t *testing.T) {
+	tests := map[float64]float64{
+		0:    0,
+		90:   90,
+		-90:  90,
+		120:  -60,
+		-135: 45,
+		270:  90,
+	}
+	for in, want := range tests {
+		if got := normalizeQuarterTurn(in); got != want {
+			t.Errorf("normalizeQuarterTurn(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package mesh
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// DefaultGridSpacing is the metric grid spacing (mm) when none is configured.
+const DefaultGridSpacing = 1000.0
+
+// OverlayOptions controls the metric grid and scale bar drawn on raster
+// renders. The zero value draws neither.
+type OverlayOptions struct {
+	Grid        bool
+	GridSpacing float64 // mm between grid lines (0 = DefaultGridSpacing)
+	GridLabels  bool    // label grid lines in meters
+	ScaleBar    bool
+	Handoff     bool // hatch areas covered by more than one vacuum
+}
+
+// OverlayFromConfig builds overlay options from the overlay section and the
+// shared gridSpacing setting.
+func OverlayFromConfig(config *Config) OverlayOptions {
+	opts := OverlayOptions{GridLabels: true}
+	if config == nil {
+		return opts
+	}
+	opts.Grid = config.Overlay.Grid
+	opts.ScaleBar = config.Overlay.ScaleBar
+	opts.Handoff = config.Overlay.Handoff
+	opts.GridSpacing = config.GridSpacing
+	if config.Overlay.GridLabels != nil {
+		opts.GridLabels = *config.Overlay.GridLabels
+	}
+	return opts
+}
+
+// spacing returns the effective grid spacing in mm.
+func (o OverlayOptions) spacing() float64 {
+	if o.GridSpacing > 0 {
+		return o.GridSpacing
+	}
+	return DefaultGridSpacing
+}
+
+var (
+	gridColor     = color.NRGBA{90, 90, 90, 110}
+	gridTextColor = color.RGBA{90, 90, 90, 255}
+	scaleBarColor = color.RGBA{0, 0, 0, 255}
+)
+
+// imageToWorld returns the inverse of Render's world-to-image mapping: it
+// maps an output pixel position back to reference grid coordinates.
+func (r *CompositeRenderer) imageToWorld(minX, minY, centerX, centerY float64) func(x, y float64) Point {
+	rad := -r.GlobalRotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	return func(x, y float64) Point {
+		// Undo padding/scale, then rotate back around the center
+		rx := (x-float64(r.Padding))/r.Scale + minX - centerX
+		ry := (y-float64(r.Padding))/r.Scale + minY - centerY
+		return Point{X: rx*cos - ry*sin + centerX, Y: rx*sin + ry*cos + centerY}
+	}
+}
+
+// drawOverlay draws the handoff zones, grid and scale bar selected in
+// r.Overlay.
+func (r *CompositeRenderer) drawOverlay(img *image.RGBA, minX, minY, centerX, centerY float64) {
+	if r.Overlay.Handoff {
+		r.drawHandoff(img, minX, minY, centerX, centerY)
+	}
+	if r.Overlay.Grid {
+		r.drawGrid(img, minX, minY, centerX, centerY)
+	}
+	if r.Overlay.ScaleBar {
+		r.drawScaleBar(img)
+	}
+}
+
+// drawGrid draws lines at every multiple of the grid spacing in world
+// coordinates. Each output pixel is mapped back to the world so the grid
+// follows any global rotation; a pixel is on a line when the line passes
+// through it.
+func (r *CompositeRenderer) drawGrid(img *image.RGBA, minX, minY, centerX, centerY float64) {
+	pixelSize := referencePixelSize(r.Maps, r.Reference)
+	spacing := r.Overlay.spacing()
+	toWorld := r.imageToWorld(minX, minY, centerX, centerY)
+	halfPixel := 0.5 / r.Scale * pixelSize // mm
+
+	// First pixel of each line in scan order, used to place its label
+	type lineKey struct {
+		vertical bool
+		index    int
+	}
+	labels := make(map[lineKey]image.Point)
+	var order []lineKey
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			w := toWorld(float64(x)+0.5, float64(y)+0.5)
+			for _, line := range [2]struct {
+				vertical bool
+				v        float64
+			}{{true, w.X * pixelSize}, {false, w.Y * pixelSize}} {
+				k := math.Round(line.v / spacing)
+				if d := k*spacing - line.v; d < -halfPixel || d >= halfPixel {
+					continue
+				}
+				img.Set(x, y, blendColors(img.RGBAAt(x, y), gridColor))
+				key := lineKey{line.vertical, int(k)}
+				if _, seen := labels[key]; !seen {
+					labels[key] = image.Pt(x, y)
+					order = append(order, key)
+				}
+			}
+		}
+	}
+
+	if !r.Overlay.GridLabels {
+		return
+	}
+	for _, key := range order {
+		p := labels[key]
+		text := formatMeters(float64(key.index) * spacing)
+		textW, ascent, _ := r.Font.measure(text, 1)
+		x := min(p.X+2, bounds.Max.X-textW-1)
+		y := max(p.Y-2, bounds.Min.Y+ascent+1)
+		if !key.vertical {
+			y = min(p.Y+ascent+2, bounds.Max.Y-2)
+		}
+		r.Font.drawString(img, x, y, text, gridTextColor, 1)
+	}
+}
+
+// Scale bar layout in pixels.
+const (
+	scaleBarMargin   = 12
+	scaleBarHeight   = 4
+	scaleBarTick     = 6
+	scaleBarMaxWidth = 4 // bar is at most 1/scaleBarMaxWidth of the image width
+)
+
+// drawScaleBar draws a labelled bar of a round length in the bottom corner
+// not used by the legend.
+func (r *CompositeRenderer) drawScaleBar(img *image.RGBA) {
+	pxPerMM := r.Scale / referencePixelSize(r.Maps, r.Reference)
+	bounds := img.Bounds()
+	lengthMM := niceScaleLength(float64(bounds.Dx()/scaleBarMaxWidth) / pxPerMM)
+	if lengthMM <= 0 {
+		return
+	}
+	barW := int(math.Round(lengthMM * pxPerMM))
+	if barW < 2 {
+		return
+	}
+
+	x0 := bounds.Max.X - scaleBarMargin - barW
+	if r.Legend.Position == LegendBottomRight && !r.Legend.Hidden {
+		x0 = bounds.Min.X + scaleBarMargin
+	}
+	y1 := bounds.Max.Y - scaleBarMargin
+	y0 := y1 - scaleBarHeight
+
+	black := image.NewUniform(scaleBarColor)
+	draw.Draw(img, image.Rect(x0, y0, x0+barW, y1), black, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(x0, y1-scaleBarTick-scaleBarHeight, x0+1, y1), black, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(x0+barW-1, y1-scaleBarTick-scaleBarHeight, x0+barW, y1), black, image.Point{}, draw.Src)
+
+	r.Font.drawString(img, x0, y0-scaleBarTick, formatMeters(lengthMM), scaleBarColor, 1)
+}
+
+// niceScaleLength returns the largest 1, 2 or 5 x 10^n length not exceeding
+// maxMM, or 0 if maxMM is below 1mm.
+func niceScaleLength(maxMM float64) float64 {
+	if maxMM < 1 || math.IsInf(maxMM, 0) || math.IsNaN(maxMM) {
+		return 0
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(maxMM)))
+	for _, step := range []float64{5, 2, 1} {
+		if step*magnitude <= maxMM {
+			return step * magnitude
+		}
+	}
+	return magnitude
+}
+
+// formatMeters formats a length in mm as meters, e.g. 2500 -> "2.5 m".
+func formatMeters(mm float64) string {
+	return strconv.FormatFloat(mm/1000, 'f', -1, 64) + " m"
+}
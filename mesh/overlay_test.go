@@ -0,0 +1,142 @@
+package mesh
+
+import (
+	"image/color"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+func TestNiceScaleLength(t *testing.T) {
+	tests := []struct {
+		max, want float64
+	}{
+		{0.5, 0},
+		{1, 1},
+		{7, 5},
+		{19.9, 10},
+		{250, 200},
+		{999, 500},
+		{1000, 1000},
+		{4300, 2000},
+	}
+	for _, tt := range tests {
+		if got := niceScaleLength(tt.max); got != tt.want {
+			t.Errorf("niceScaleLength(%v) = %v, want %v", tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMeters(t *testing.T) {
+	tests := map[float64]string{
+		0:     "0 m",
+		500:   "0.5 m",
+		1000:  "1 m",
+		2500:  "2.5 m",
+		-3000: "-3 m",
+	}
+	for mm, want := range tests {
+		if got := formatMeters(mm); got != want {
+			t.Errorf("formatMeters(%v) = %q, want %q", mm, got, want)
+		}
+	}
+}
+
+func TestOverlayFromConfig(t *testing.T) {
+	if opts := OverlayFromConfig(nil); opts.Grid || opts.ScaleBar || !opts.GridLabels {
+		t.Errorf("nil config = %+v, want nothing drawn and labels on", opts)
+	}
+
+	off := false
+	opts := OverlayFromConfig(&Config{
+		GridSpacing: 500,
+		Overlay:     OverlayConfig{Grid: true, GridLabels: &off, ScaleBar: true},
+	})
+	if !opts.Grid || !opts.ScaleBar || opts.GridLabels || opts.GridSpacing != 500 {
+		t.Errorf("OverlayFromConfig = %+v", opts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+// overlayRenderer renders a 100x100 cell floor at 5mm per cell (500mm square)
+// with no padding or legend.
+func overlayRenderer() *CompositeRenderer {
+	var pixels []int
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			pixels = append(pixels, x, y)
+		}
+	}
+	maps := map[string]*ValetudoMap{"ref": {
+		PixelSize: 5,
+		Layers:    []MapLayer{{Type: "floor", Pixels: pixels}},
+	}}
+	r := NewCompositeRenderer(maps, map[string]AffineMatrix{"ref": Identity()}, "ref")
+	r.Padding = 0
+	r.Legend.Hidden = true
+	return r
+}
+
+func TestRender_GridLinesAtSpacing(t *testing.T) {
+	r := overlayRenderer()
+	r.Overlay = OverlayOptions{Grid: true, GridSpacing: 100} // every 20 cells
+
+	plain := overlayRenderer().Render()
+	img := r.Render()
+
+	// Sample below the label row, between horizontal lines
+	y := 50
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		changed := img.RGBAAt(x, y) != plain.RGBAAt(x, y)
+		onLine := x%20 == 0
+		if changed != onLine {
+			t.Errorf("x=%d: changed=%v, want %v", x, changed, onLine)
+		}
+	}
+}
+
+func TestRender_GridFollowsRotation(t *testing.T) {
+	r := overlayRenderer()
+	r.GlobalRotation = 30
+	r.Overlay = OverlayOptions{Grid: true, GridSpacing: 100}
+
+	plain := overlayRenderer()
+	plain.GlobalRotation = 30
+	base, img := plain.Render(), r.Render()
+
+	changed := 0
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			if img.RGBAAt(x, y) != base.RGBAAt(x, y) {
+				changed++
+			}
+		}
+	}
+	if changed == 0 {
+		t.Error("expected grid pixels on rotated render")
+	}
+}
+
+func TestRender_ScaleBar(t *testing.T) {
+	r := overlayRenderer()
+	r.Overlay = OverlayOptions{ScaleBar: true}
+	img := r.Render()
+
+	// 100px wide image at 5mm/px: bar is at most 125mm, so 100mm = 20px
+	black := color.RGBA{0, 0, 0, 255}
+	y := img.Bounds().Dy() - scaleBarMargin - 1
+	count := 0
+	for x := 0; x < img.Bounds().Dx(); x++ {
+		if img.RGBAAt(x, y) == black {
+			count++
+		}
+	}
+	if count != 20 {
+		t.Errorf("scale bar width = %d px, want 20", count)
+	}
+}
@@ -1,19 +1,170 @@
 package mesh
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
+// ParseOptions controls optional processing applied while parsing a map.
+type ParseOptions struct {
+	Downsample int // Layer pixel downsampling factor (0/1 = off)
+}
+
 // ParseMapFile reads and parses a Valetudo map JSON file
 func ParseMapFile(path string) (*ValetudoMap, error) {
-	data, err := os.ReadFile(path)
+	return ParseMapFileWithOptions(path, ParseOptions{})
+}
+
+// ParseMapFileWithOptions streams a Valetudo map JSON file from disk without
+// buffering the whole file, then applies the given parse options. Gzip
+// compressed files are decompressed on the fly.
+func ParseMapFileWithOptions(path string, opts ParseOptions) (*ValetudoMap, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading file: %w", err)
 	}
-	return ParseMapJSON(data)
+	defer func() { _ = f.Close() }()
+
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+		return ParseMapReader(zr, opts)
+	}
+	return ParseMapReader(br, opts)
+}
+
+// ParseMapReader decodes a Valetudo map from a stream. Layer pixels are read
+// one coordinate at a time and downsampled as they arrive, so neither the
+// raw JSON nor the full-resolution pixel arrays are held in memory.
+func ParseMapReader(r io.Reader, opts ParseOptions) (*ValetudoMap, error) {
+	dec := json.NewDecoder(r)
+	var m ValetudoMap
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "__class":
+			return dec.Decode(&m.Class)
+		case "metaData":
+			return dec.Decode(&m.MetaData)
+		case "size":
+			return dec.Decode(&m.Size)
+		case "pixelSize":
+			return dec.Decode(&m.PixelSize)
+		case "entities":
+			return dec.Decode(&m.Entities)
+		case "layers":
+			return decodeArray(dec, func() error {
+				layer, err := decodeLayer(dec, opts.Downsample)
+				m.Layers = append(m.Layers, layer)
+				return err
+			})
+		}
+		var skip json.RawMessage
+		return dec.Decode(&skip)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if opts.Downsample > 1 {
+		m.Downsample = opts.Downsample
+	}
+	return &m, nil
+}
+
+// decodeLayer reads one map layer object from dec, downsampling its pixels
+// by factor while they are read.
+func decodeLayer(dec *json.Decoder, factor int) (MapLayer, error) {
+	var layer MapLayer
+	err := decodeObject(dec, func(key string) error {
+		switch key {
+		case "__class":
+			return dec.Decode(&layer.Class)
+		case "metaData":
+			return dec.Decode(&layer.MetaData)
+		case "type":
+			return dec.Decode(&layer.Type)
+		case "pixels":
+			ds := newPixelDownsampler(factor, 0)
+			var x, n int
+			err := decodeArray(dec, func() error {
+				var v int
+				if err := dec.Decode(&v); err != nil {
+					return err
+				}
+				if n++; n%2 == 1 {
+					x = v
+				} else {
+					ds.add(x, v)
+				}
+				return nil
+			})
+			if n > 0 {
+				layer.Pixels = ds.out
+			}
+			return err
+		}
+		var skip json.RawMessage
+		return dec.Decode(&skip)
+	})
+	return layer, err
+}
+
+// decodeObject reads a JSON object from dec, calling field for each key with
+// the decoder positioned at the key's value. field must consume the value.
+// A null value is accepted as an empty object.
+func decodeObject(dec *json.Decoder, field func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(tok.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // closing brace
+	return err
+}
+
+// decodeArray reads a JSON array from dec, calling elem once per element
+// with the decoder positioned at it. elem must consume the element. A null
+// value is accepted as an empty array.
+func decodeArray(dec *json.Decoder, elem func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+	for dec.More() {
+		if err := elem(); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // closing bracket
+	return err
 }
 
 // ParseMapJSON parses Valetudo map JSON data
@@ -100,17 +251,17 @@ func PixelsToPoints(pixels []int) []Point {
 
 // MapSummary provides a summary of map contents
 type MapSummary struct {
-	Version         int
-	TotalLayerArea  int
-	Size            Size
-	PixelSize       int
-	RobotPosition   Point
-	RobotAngle      float64
-	ChargerPosition Point
-	SegmentCount    int
-	SegmentNames    []string
-	HasFloor        bool
-	HasWall         bool
+	Version         int      `json:"version"`
+	TotalLayerArea  int      `json:"totalLayerArea"`
+	Size            Size     `json:"size"`
+	PixelSize       int      `json:"pixelSize"`
+	RobotPosition   Point    `json:"robotPosition"`
+	RobotAngle      float64  `json:"robotAngle"`
+	ChargerPosition Point    `json:"chargerPosition"`
+	SegmentCount    int      `json:"segmentCount"`
+	SegmentNames    []string `json:"segmentNames"`
+	HasFloor        bool     `json:"hasFloor"`
+	HasWall         bool     `json:"hasWall"`
 }
 
 // Summarize extracts key information from a map
@@ -156,8 +307,19 @@ var (
 	ErrNoRobotPosition   = errors.New("map is missing robot_position entity")
 	ErrNoChargerLocation = errors.New("map is missing charger_location entity")
 	ErrAreaTooSmall      = errors.New("map area is too small compared to last known good map")
+	ErrZeroPixelSize     = errors.New("map has no pixel size")
+	ErrAreaDropped       = errors.New("map floor area dropped sharply from the previous map")
+	ErrRobotOutOfBounds  = errors.New("robot position is outside the map layers")
 )
 
+// MinSanityAreaRatio is the smallest share of the previous map's floor area
+// an incoming map may cover before CheckMapSanity treats it as truncated.
+const MinSanityAreaRatio = 0.2
+
+// sanityBoundsMargin is how many pixels the robot may stand outside the
+// layers' bounding box, e.g. on a dock beyond the last floor pixel.
+const sanityBoundsMargin = 20
+
 // ValidateMapForCalibration checks that a map has all required data for calibration.
 // It returns a descriptive error for the first validation failure found, or nil if valid.
 func ValidateMapForCalibration(m *ValetudoMap) error {
@@ -194,6 +356,66 @@ func IsMapComplete(newMap, lastKnownGood *ValetudoMap) bool {
 	return true
 }
 
+// CheckMapSanity reports why an incoming map looks corrupt or partial and
+// should not replace previous, or nil if it looks usable. Maps without
+// drawable pixels are lightweight position updates and only need a pixel
+// size; previous may be nil.
+func CheckMapSanity(m, previous *ValetudoMap) error {
+	if m == nil {
+		return ErrNilMap
+	}
+	if m.PixelSize <= 0 {
+		return ErrZeroPixelSize
+	}
+	if !HasDrawablePixels(m) {
+		return nil
+	}
+	if previous != nil && previous.PixelSize > 0 {
+		before, after := mapFloorArea(previous), mapFloorArea(m)
+		if before > 0 && after < before*MinSanityAreaRatio {
+			return fmt.Errorf("%w: %.1f m² to %.1f m²", ErrAreaDropped, before/1e6, after/1e6)
+		}
+	}
+	if pos, _, ok := ExtractRobotPosition(m); ok {
+		minX, minY, maxX, maxY, ok := layerBounds(m)
+		x, y := int(pos.X)/m.PixelSize, int(pos.Y)/m.PixelSize
+		if ok && (x < minX-sanityBoundsMargin || x > maxX+sanityBoundsMargin ||
+			y < minY-sanityBoundsMargin || y > maxY+sanityBoundsMargin) {
+			return fmt.Errorf("%w: pixel (%d,%d) not within (%d,%d)-(%d,%d)",
+				ErrRobotOutOfBounds, x, y, minX, minY, maxX, maxY)
+		}
+	}
+	return nil
+}
+
+// mapFloorArea returns the area in mm² covered by m's floor and segment layers.
+func mapFloorArea(m *ValetudoMap) float64 {
+	pixels := 0
+	for _, layer := range m.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			pixels += len(layer.Pixels) / 2
+		}
+	}
+	block := m.CellBlock()
+	return float64(pixels*block*block) * float64(m.PixelSize*m.PixelSize)
+}
+
+// layerBounds returns the bounding box, in pixels, of all of m's layers.
+func layerBounds(m *ValetudoMap) (minX, minY, maxX, maxY int, ok bool) {
+	for _, layer := range m.Layers {
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			x, y := layer.Pixels[i], layer.Pixels[i+1]
+			if !ok {
+				minX, minY, maxX, maxY, ok = x, y, x, y, true
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	return minX, minY, maxX, maxY, ok
+}
+
 // HasDrawablePixels returns true if the map contains any pixels in floor, wall or segment layers
 func HasDrawablePixels(m *ValetudoMap) bool {
 	if m == nil {
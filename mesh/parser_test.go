@@ -177,3 +177,44 @@ func TestIsMapComplete(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckMapSanity(t *testing.T) {
+	floor := func(n int) *ValetudoMap {
+		pixels := make([]int, 0, 2*n)
+		for i := 0; i < n; i++ {
+			pixels = append(pixels, i%100, i/100)
+		}
+		return &ValetudoMap{
+			PixelSize: 5,
+			Layers:    []MapLayer{{Type: "floor", Pixels: pixels}},
+			Entities:  []MapEntity{{Type: "robot_position", Points: []int{250, 25}}},
+		}
+	}
+	outside := floor(1000)
+	outside.Entities[0].Points = []int{5000, 25}
+	zeroPixelSize := floor(1000)
+	zeroPixelSize.PixelSize = 0
+
+	tests := []struct {
+		name     string
+		m        *ValetudoMap
+		previous *ValetudoMap
+		wantErr  error
+	}{
+		{"nil map", nil, nil, ErrNilMap},
+		{"no previous map", floor(1000), nil, nil},
+		{"similar area", floor(900), floor(1000), nil},
+		{"area at threshold", floor(200), floor(1000), nil},
+		{"area dropped", floor(199), floor(1000), ErrAreaDropped},
+		{"zero pixel size", zeroPixelSize, floor(1000), ErrZeroPixelSize},
+		{"robot outside layers", outside, nil, ErrRobotOutOfBounds},
+		{"position-only update", &ValetudoMap{PixelSize: 5}, floor(1000), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckMapSanity(tt.m, tt.previous); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckMapSanity() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
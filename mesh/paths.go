@@ -0,0 +1,116 @@
+package mesh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// AppDirName names the per-user data directory under the platform's data
+// location.
+const AppDirName = "tudomesh"
+
+// UserDataDir returns the per-user data directory for tudomesh:
+// %LOCALAPPDATA%\tudomesh on Windows (or %APPDATA% without it),
+// ~/Library/Application Support/tudomesh on macOS, and
+// $XDG_DATA_HOME/tudomesh (default ~/.local/share/tudomesh) elsewhere.
+func UserDataDir() (string, error) {
+	return userDataDir(runtime.GOOS, os.Getenv, os.UserHomeDir)
+}
+
+// userDataDir is UserDataDir for goos, reading the environment with getenv
+// and the home directory with home.
+func userDataDir(goos string, getenv func(string) string, home func() (string, error)) (string, error) {
+	switch goos {
+	case "windows":
+		for _, env := range []string{"LOCALAPPDATA", "APPDATA"} {
+			if dir := getenv(env); dir != "" {
+				return filepath.Join(dir, AppDirName), nil
+			}
+		}
+		return "", errors.New("neither %LOCALAPPDATA% nor %APPDATA% is set")
+	case "darwin":
+		h, err := home()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(h, "Library", "Application Support", AppDirName), nil
+	default:
+		// XDG requires an absolute path; a relative one is ignored
+		if dir := getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, AppDirName), nil
+		}
+		h, err := home()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(h, ".local", "share", AppDirName), nil
+	}
+}
+
+// ExpandHome replaces a leading ~ in path with the user's home directory.
+// Shells do not expand it inside --flag=~/dir, and Windows shells never
+// do. Paths without a leading ~, or with ~user, are returned unchanged.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// ResolvePath resolves path against dir: absolute and rooted paths (\state
+// on Windows) are kept, ~ is expanded, and anything else is joined to dir.
+// An empty path stays empty.
+func ResolvePath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	path = ExpandHome(path)
+	if isRooted(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// isRooted reports whether path is absolute, or on Windows starts at the
+// root of the current drive or names a drive, which filepath.IsAbs does
+// not count as absolute.
+func isRooted(path string) bool {
+	return filepath.IsAbs(path) || filepath.VolumeName(path) != "" ||
+		(path != "" && os.IsPathSeparator(path[0]))
+}
+
+// listDir returns the regular files in dir whose names match any of the
+// filepath.Match patterns, sorted by name. Unlike filepath.Glob on a joined
+// path, glob characters in dir itself, such as the brackets of a Windows
+// user folder, are taken literally.
+func listDir(dir string, patterns ...string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		for _, pattern := range patterns {
+			if ok, err := filepath.Match(pattern, e.Name()); err != nil {
+				return nil, err
+			} else if ok {
+				files = append(files, filepath.Join(dir, e.Name()))
+				break
+			}
+		}
+	}
+	return files, nil
+}
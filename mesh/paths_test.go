@@ -0,0 +1,103 @@
+package mesh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestUserDataDir(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	home := func() (string, error) { return filepath.Join("home", "ann"), nil }
+	noHome := func() (string, error) { return "", errors.New("no home") }
+
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		home func() (string, error)
+		want string
+	}{
+		{"windows local", "windows", map[string]string{"LOCALAPPDATA": "L", "APPDATA": "R"}, noHome, filepath.Join("L", AppDirName)},
+		{"windows roaming", "windows", map[string]string{"APPDATA": "R"}, noHome, filepath.Join("R", AppDirName)},
+		{"darwin", "darwin", nil, home, filepath.Join("home", "ann", "Library", "Application Support", AppDirName)},
+		{"xdg", "linux", map[string]string{"XDG_DATA_HOME": string(filepath.Separator) + "xdg"}, noHome, filepath.Join(string(filepath.Separator)+"xdg", AppDirName)},
+		{"xdg default", "linux", map[string]string{"XDG_DATA_HOME": "relative"}, home, filepath.Join("home", "ann", ".local", "share", AppDirName)},
+	}
+	for _, tt := range tests {
+		// An absolute XDG_DATA_HOME needs a volume name on Windows
+		if tt.name == "xdg" && runtime.GOOS == "windows" {
+			continue
+		}
+		got, err := userDataDir(tt.goos, env(tt.env), tt.home)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: userDataDir = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := userDataDir("windows", env(nil), home); err == nil {
+		t.Error("windows without %APPDATA%: expected error")
+	}
+	if _, err := userDataDir("linux", env(nil), noHome); err == nil {
+		t.Error("linux without home: expected error")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "state", "cal.json")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"", ""},
+		{".calibration-cache.json", filepath.Join(dir, ".calibration-cache.json")},
+		{filepath.Join("cache", "cal.json"), filepath.Join(dir, "cache", "cal.json")},
+		{abs, abs},
+		{string(filepath.Separator) + "cal.json", string(filepath.Separator) + "cal.json"},
+		{"~", home},
+		{"~/cal.json", filepath.Join(home, "cal.json")},
+		{"~ann/cal.json", filepath.Join(dir, "~ann", "cal.json")},
+	}
+	for _, tt := range tests {
+		if got := ResolvePath(dir, tt.path); got != tt.want {
+			t.Errorf("ResolvePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestListDir_GlobCharactersInDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "maps [old]")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ValetudoMapExport-b.json", "ValetudoMapExport-a.json.gz", "rocky.png", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "ValetudoMapExport-dir.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listDir(dir, "ValetudoMapExport-*.json", "ValetudoMapExport-*.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "ValetudoMapExport-a.json.gz"), filepath.Join(dir, "ValetudoMapExport-b.json")}
+	if len(files) != 2 || files[0] != want[0] || files[1] != want[1] {
+		t.Errorf("listDir = %v, want %v", files, want)
+	}
+
+	if files, err := listDir(filepath.Join(dir, "missing"), "*.png"); err != nil || files != nil {
+		t.Errorf("missing dir: listDir = %v, %v, want nothing", files, err)
+	}
+}
@@ -0,0 +1,192 @@
+package mesh
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// ICPInit selects how ICP's starting translation is found for each rotation
+// it tries.
+type ICPInit string
+
+const (
+	// InitSampled scores the translations that put random source points on
+	// random target points, and corners on corners (the default)
+	InitSampled ICPInit = "sampled"
+	// InitPhaseCorrelation rasterizes the walls of both maps and takes the
+	// translation from the peak of their phase correlation: deterministic,
+	// and faster than sampling for large maps
+	InitPhaseCorrelation ICPInit = "phase"
+)
+
+// Phase correlation grid: walls are rasterized into cells of at least
+// phaseMinCell pixels, coarse enough that both maps fit a grid of at most
+// phaseMaxGrid cells a side.
+const (
+	phaseMinCell = 2.0
+	phaseMaxGrid = 256
+)
+
+// phaseInitial returns the starting transform for rotationDeg found by phase
+// correlation when Init selects it; ok is false otherwise, and when either
+// map has no walls.
+func (c ICPConfig) phaseInitial(source, target FeatureSet, rotationDeg float64) (AffineMatrix, bool) {
+	if c.Init != InitPhaseCorrelation {
+		return Identity(), false
+	}
+	return phaseCorrelationAlignment(source, target, rotationDeg)
+}
+
+// phaseCorrelationAlignment rotates the source walls by rotationDeg about
+// their centroid, as findBestInitialAlignment does, and finds the
+// translation onto the target walls by phase correlation.
+func phaseCorrelationAlignment(source, target FeatureSet, rotationDeg float64) (AffineMatrix, bool) {
+	if len(source.WallPoints) == 0 || len(target.WallPoints) == 0 {
+		return Identity(), false
+	}
+	base := MultiplyMatrices(RotationDeg(rotationDeg), Translation(-source.Centroid.X, -source.Centroid.Y))
+	shift, ok := phaseCorrelate(TransformPoints(source.WallPoints, base), target.WallPoints)
+	if !ok {
+		return Identity(), false
+	}
+	return MultiplyMatrices(Translation(shift.X, shift.Y), base), true
+}
+
+// phaseCorrelate returns the translation that best moves source onto
+// target. Both are rasterized onto a common power-of-two grid, big enough
+// that shifts up to their extents do not wrap; the inverse transform of
+// their normalized cross-power spectrum peaks at the shift, which is refined
+// between cells by fitting a parabola through the peak and its neighbours.
+func phaseCorrelate(source, target []Point) (Point, bool) {
+	sMinX, sMinY, sMaxX, sMaxY := pointBounds(source)
+	tMinX, tMinY, tMaxX, tMaxY := pointBounds(target)
+	extent := math.Max(math.Max(sMaxX-sMinX, sMaxY-sMinY), math.Max(tMaxX-tMinX, tMaxY-tMinY)) + 1
+	cell := math.Max(phaseMinCell, 2*extent/phaseMaxGrid)
+	n := 1
+	for float64(n) < 2*extent/cell+2 {
+		n *= 2
+	}
+
+	src := rasterizePoints(source, sMinX, sMinY, cell, n)
+	tgt := rasterizePoints(target, tMinX, tMinY, cell, n)
+	fft2D(src, n, false)
+	fft2D(tgt, n, false)
+	for i := range tgt {
+		r := tgt[i] * cmplx.Conj(src[i])
+		if a := cmplx.Abs(r); a > 1e-12 {
+			r /= complex(a, 0)
+		}
+		tgt[i] = r
+	}
+	fft2D(tgt, n, true)
+
+	peak, best := 0, math.Inf(-1)
+	for i, v := range tgt {
+		if real(v) > best {
+			peak, best = i, real(v)
+		}
+	}
+	if best <= 0 {
+		return Point{}, false
+	}
+	at := func(x, y int) float64 { return real(tgt[((y+n)%n)*n+(x+n)%n]) }
+	px, py := peak%n, peak/n
+	dx := float64(px) + parabolicPeak(at(px-1, py), best, at(px+1, py))
+	dy := float64(py) + parabolicPeak(at(px, py-1), best, at(px, py+1))
+	if dx > float64(n)/2 {
+		dx -= float64(n)
+	}
+	if dy > float64(n)/2 {
+		dy -= float64(n)
+	}
+	return Point{X: dx*cell + tMinX - sMinX, Y: dy*cell + tMinY - sMinY}, true
+}
+
+// pointBounds returns the bounding box of points.
+func pointBounds(points []Point) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// rasterizePoints returns an n x n grid counting the points in each cell,
+// with (minX, minY) at the corner of the first.
+func rasterizePoints(points []Point, minX, minY, cell float64, n int) []complex128 {
+	grid := make([]complex128, n*n)
+	for _, p := range points {
+		x, y := int((p.X-minX)/cell), int((p.Y-minY)/cell)
+		if x >= 0 && y >= 0 && x < n && y < n {
+			grid[y*n+x]++
+		}
+	}
+	return grid
+}
+
+// parabolicPeak returns the offset, within half a cell, of the vertex of
+// the parabola through three samples around a peak at c.
+func parabolicPeak(l, c, r float64) float64 {
+	d := l - 2*c + r
+	if d >= 0 {
+		return 0
+	}
+	return math.Max(-0.5, math.Min(0.5, 0.5*(l-r)/d))
+}
+
+// fft2D transforms the n x n grid in place, rows then columns; n must be a
+// power of two. The inverse transform is scaled by 1/n².
+func fft2D(grid []complex128, n int, inverse bool) {
+	column := make([]complex128, n)
+	for y := 0; y < n; y++ {
+		fft(grid[y*n:(y+1)*n], inverse)
+	}
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			column[y] = grid[y*n+x]
+		}
+		fft(column, inverse)
+		for y := 0; y < n; y++ {
+			grid[y*n+x] = column[y]
+		}
+	}
+	if inverse {
+		scale := complex(1/float64(n*n), 0)
+		for i := range grid {
+			grid[i] *= scale
+		}
+	}
+}
+
+// fft is an iterative radix-2 Cooley-Tukey transform of a, in place; its
+// length must be a power of two. The inverse is not scaled.
+func fft(a []complex128, inverse bool) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u, v := a[start+k], a[start+k+size/2]*w
+				a[start+k], a[start+k+size/2] = u+v, u-v
+				w *= step
+			}
+		}
+	}
+}
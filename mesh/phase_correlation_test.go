@@ -0,0 +1,81 @@
+package mesh
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFT_RoundTrip(t *testing.T) {
+	n := 8
+	grid := make([]complex128, n*n)
+	for i := range grid {
+		grid[i] = complex(float64(i%7), float64(i%3))
+	}
+	want := append([]complex128(nil), grid...)
+	fft2D(grid, n, false)
+	if got := grid[0]; cmplx.Abs(got-sumComplex(want)) > 1e-9 {
+		t.Errorf("DC term = %v, want %v", got, sumComplex(want))
+	}
+	fft2D(grid, n, true)
+	for i := range grid {
+		if cmplx.Abs(grid[i]-want[i]) > 1e-9 {
+			t.Fatalf("round trip [%d] = %v, want %v", i, grid[i], want[i])
+		}
+	}
+}
+
+func sumComplex(a []complex128) complex128 {
+	var s complex128
+	for _, v := range a {
+		s += v
+	}
+	return s
+}
+
+func TestPhaseCorrelate_Translation(t *testing.T) {
+	source := ExtractFeatures(rotatedRoom(0)).WallPoints
+	target := TransformPoints(source, Translation(-41.5, 27))
+	shift, ok := phaseCorrelate(source, target)
+	if !ok {
+		t.Fatal("no correlation peak")
+	}
+	if math.Abs(shift.X+41.5) > 1.5 || math.Abs(shift.Y-27) > 1.5 {
+		t.Errorf("shift = (%.1f, %.1f), want (-41.5, 27)", shift.X, shift.Y)
+	}
+}
+
+func TestPhaseCorrelationAlignment_Rotated(t *testing.T) {
+	// rotatedRoom(30) turned back by 30° about its walls' centroid lands on
+	// rotatedRoom(0) once translated
+	source := ExtractFeatures(rotatedRoom(30))
+	target := ExtractFeatures(rotatedRoom(0))
+	tx, ok := phaseCorrelationAlignment(source, target, -30)
+	if !ok {
+		t.Fatal("no alignment")
+	}
+	truth := MultiplyMatrices(CreateRotationTranslation(0, 400, 400), InvertMatrix(CreateRotationTranslation(30, 400, 400)))
+	for _, p := range []Point{{220, 280}, {580, 520}, {400, 400}} {
+		got, want := TransformPoint(p, tx), TransformPoint(p, truth)
+		if d := math.Hypot(got.X-want.X, got.Y-want.Y); d > 4 {
+			t.Errorf("(%v) maps to (%.1f, %.1f), want (%.1f, %.1f)", p, got.X, got.Y, want.X, want.Y)
+		}
+	}
+}
+
+func TestPhaseCorrelationAlignment_NoWalls(t *testing.T) {
+	if _, ok := phaseCorrelationAlignment(FeatureSet{}, ExtractFeatures(rotatedRoom(0)), 0); ok {
+		t.Error("aligned a map without walls")
+	}
+}
+
+func TestAlignMaps_PhaseCorrelationInit(t *testing.T) {
+	config := DefaultICPConfig()
+	config.Init = InitPhaseCorrelation
+	result := AlignMaps(rotatedRoom(90), rotatedRoom(0), config)
+	truth := MultiplyMatrices(CreateRotationTranslation(0, 400, 400), InvertMatrix(CreateRotationTranslation(90, 400, 400)))
+	got, want := TransformPoint(Point{X: 300, Y: 300}, result.Transform), TransformPoint(Point{X: 300, Y: 300}, truth)
+	if d := math.Hypot(got.X-want.X, got.Y-want.Y); d > 3 {
+		t.Errorf("aligned off by %.1fpx (score %.2f)", d, result.Score)
+	}
+}
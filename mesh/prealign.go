@@ -0,0 +1,199 @@
+package mesh
+
+import (
+	"math"
+)
+
+// Pre-alignment parameters.
+const (
+	wallOrientationRadius    = 4   // grid cells around a wall pixel used to estimate its direction
+	wallOrientationCoherence = 0.6 // minimum line-likeness (0-1) for a pixel to vote
+	preAlignMinSharpness     = 0.5 // histogram correlation peak needed to trust the angle
+	preAlignMinMargin        = 0.2 // quarter-turn score margin needed to skip the sweep
+	preAlignMinScore         = 0.3 // ICP score below which a single hypothesis falls back to the sweep
+)
+
+// RotationEstimate is a rotation between two maps estimated from their wall
+// directions, without running ICP.
+type RotationEstimate struct {
+	Rotation   float64 // degrees in [0, 360) taking source to target
+	Offset     float64 // Rotation modulo 90, in [0, 90); the non-cardinal part
+	Sharpness  float64 // 0-1, how clearly the wall histograms agree on Offset
+	Margin     float64 // 0-1, how clearly Rotation beats the other quarter turns
+	Confidence float64 // min(Sharpness, Margin)
+}
+
+// AngleTrusted reports whether Offset can seed ICP: the maps share clear
+// wall directions, even if the quarter turn is ambiguous.
+func (e RotationEstimate) AngleTrusted() bool {
+	return e.Sharpness >= preAlignMinSharpness
+}
+
+// Confident reports whether Rotation alone can seed ICP.
+func (e RotationEstimate) Confident() bool {
+	return e.AngleTrusted() && e.Margin >= preAlignMinMargin
+}
+
+// EstimateRotation estimates the rotation that takes source onto target.
+// The wall direction histograms are cross-correlated at 1° resolution,
+// which fixes the rotation up to a quarter turn since most homes have
+// perpendicular walls; the quarter turn is then chosen by how well the
+// centroid-aligned feature points overlap.
+func EstimateRotation(source, target *ValetudoMap) RotationEstimate {
+	return estimateRotation(source, target, ExtractFeatures(source), ExtractFeatures(target))
+}
+
+// estimateRotation is EstimateRotation with precomputed features.
+func estimateRotation(source, target *ValetudoMap, srcFeatures, tgtFeatures FeatureSet) RotationEstimate {
+	var est RotationEstimate
+	srcHist := ExtractWallOrientations(source)
+	tgtHist := ExtractWallOrientations(target)
+	if srcHist.TotalEdges == 0 || tgtHist.TotalEdges == 0 {
+		return est
+	}
+	est.Offset, est.Sharpness = correlateOrientations(srcHist, tgtHist)
+
+	srcPoints := SampleFeatures(srcFeatures, 300)
+	tgtPoints := SampleFeatures(tgtFeatures, 300)
+	if len(srcPoints) < 3 || len(tgtPoints) < 3 {
+		est.Rotation = est.Offset
+		return est
+	}
+
+	// Score each quarter turn by the overlap of centroid-aligned points
+	srcCentroid, tgtCentroid := Centroid(srcPoints), Centroid(tgtPoints)
+	best, second := math.MaxFloat64, math.MaxFloat64
+	for k := 0; k < 4; k++ {
+		rot := est.Offset + float64(k)*90
+		transform := buildRotationTransform(srcCentroid, tgtCentroid, rot)
+		d := FeatureDistance(TransformPoints(srcPoints, transform), tgtPoints)
+		if d < best {
+			best, second = d, best
+			est.Rotation = rot
+		} else if d < second {
+			second = d
+		}
+	}
+	if second > 0 && second < math.MaxFloat64 {
+		est.Margin = (second - best) / second
+	}
+	est.Confidence = math.Min(est.Sharpness, est.Margin)
+	return est
+}
+
+// correlateOrientations cross-correlates two orientation histograms and
+// returns the best rotation modulo 90 (sub-degree, in [0, 90)) and the
+// sharpness of that peak: 1 when the correlation is concentrated at the
+// peak, 0 when it is flat.
+func correlateOrientations(source, target WallAngleHistogram) (offset, sharpness float64) {
+	// corr[k]: overlap when source directions are turned by k degrees.
+	// Orientations repeat every 180°, and perpendicular walls make k and
+	// k+90 equivalent, so fold onto 90 bins
+	var folded [90]float64
+	for k := 0; k < 180; k++ {
+		c := 0.0
+		for i := 0; i < 180; i++ {
+			c += source.Bins[i] * target.Bins[(i+k)%180]
+		}
+		folded[k%90] += c
+	}
+
+	peak, sum := 0, 0.0
+	for k, c := range folded {
+		sum += c
+		if c > folded[peak] {
+			peak = k
+		}
+	}
+	if folded[peak] <= 0 {
+		return 0, 0
+	}
+	mean := sum / 90
+	sharpness = (folded[peak] - mean) / folded[peak]
+
+	// Parabolic interpolation between the neighbouring bins
+	l, c, r := folded[(peak+89)%90], folded[peak], folded[(peak+1)%90]
+	shift := 0.0
+	if denom := l - 2*c + r; denom < 0 {
+		shift = 0.5 * (l - r) / denom
+	}
+	offset = math.Mod(float64(peak)+shift+90, 90)
+	return offset, sharpness
+}
+
+// ExtractWallOrientations is a finer ExtractWallAngles. ExtractWallAngles
+// only sees the eight neighbour directions, so a wall at 30° lands in the 0°
+// and 45° bins. Here each wall pixel votes for the principal direction of
+// the wall pixels within wallOrientationRadius, split between the two
+// nearest 1° bins and weighted by how line-like the neighbourhood is;
+// corners and clutter, which have no single direction, do not vote.
+func ExtractWallOrientations(m *ValetudoMap) WallAngleHistogram {
+	var hist WallAngleHistogram
+	var pixels []int
+	for _, layer := range m.Layers {
+		if layer.Type == "wall" {
+			pixels = append(pixels, layer.Pixels...)
+		}
+	}
+	if len(pixels) < 4 {
+		return hist
+	}
+	grid, minX, minY, width, height := pixelsToGrid(pixels, 1)
+	has := func(x, y int) bool {
+		return x >= 0 && x < width && y >= 0 && y < height && grid[y*width+x]
+	}
+
+	const r = wallOrientationRadius
+	var weights [180]float64
+	total := 0.0
+	for i := 0; i+1 < len(pixels); i += 2 {
+		px, py := pixels[i]-minX, pixels[i+1]-minY
+
+		var n, sx, sy, sxx, syy, sxy float64
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if dx*dx+dy*dy > r*r || !has(px+dx, py+dy) {
+					continue
+				}
+				x, y := float64(dx), float64(dy)
+				n++
+				sx += x
+				sy += y
+				sxx += x * x
+				syy += y * y
+				sxy += x * y
+			}
+		}
+		if n < 3 {
+			continue
+		}
+		cxx := sxx/n - (sx/n)*(sx/n)
+		cyy := syy/n - (sy/n)*(sy/n)
+		cxy := sxy/n - (sx/n)*(sy/n)
+		trace := cxx + cyy
+		if trace <= 0 {
+			continue
+		}
+		coherence := math.Sqrt((cxx-cyy)*(cxx-cyy)+4*cxy*cxy) / trace
+		if coherence < wallOrientationCoherence {
+			continue
+		}
+
+		angle := 0.5 * math.Atan2(2*cxy, cxx-cyy) * 180 / math.Pi
+		angle = math.Mod(angle+180, 180)
+		lo := int(math.Floor(angle))
+		frac := angle - float64(lo)
+		weights[lo%180] += coherence * (1 - frac)
+		weights[(lo+1)%180] += coherence * frac
+		hist.RawCounts[int(math.Round(angle))%180]++
+		hist.TotalEdges++
+		total += coherence
+	}
+
+	if total > 0 {
+		for i := range weights {
+			hist.Bins[i] = weights[i] / total
+		}
+	}
+	return hist
+}
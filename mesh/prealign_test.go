@@ -0,0 +1,121 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// rotatedRoom returns a 360x240 room with two interior walls and a partly
+// filled floor, rotated by deg about its center and moved to (400, 400).
+func rotatedRoom(deg float64) *ValetudoMap {
+	transform := CreateRotationTranslation(deg, 400, 400)
+	place := func(x, y float64) (int, int) {
+		p := TransformPoint(Point{X: 3*x - 180, Y: 3*y - 120}, transform)
+		return int(math.Round(p.X)), int(math.Round(p.Y))
+	}
+
+	seen := make(map[[2]int]bool)
+	var walls []int
+	line := func(x0, y0, x1, y1 float64) {
+		n := 3 * int(math.Hypot(x1-x0, y1-y0))
+		for i := 0; i <= n; i++ {
+			t := float64(i) / float64(n)
+			x, y := place(x0+(x1-x0)*t, y0+(y1-y0)*t)
+			if !seen[[2]int{x, y}] {
+				seen[[2]int{x, y}] = true
+				walls = append(walls, x, y)
+			}
+		}
+	}
+	line(0, 0, 120, 0)
+	line(120, 0, 120, 80)
+	line(120, 80, 0, 80)
+	line(0, 80, 0, 0)
+	line(50, 0, 50, 40)
+	line(0, 50, 30, 50)
+
+	var floor []int
+	for y := 2.0; y < 79; y += 2 {
+		for x := 2.0; x < 119; x += 2 {
+			if x < 50 && y < 50 || y > 40 {
+				px, py := place(x, y)
+				floor = append(floor, px, py)
+			}
+		}
+	}
+	return &ValetudoMap{PixelSize: 5, Layers: []MapLayer{
+		{Type: "wall", Pixels: walls},
+		{Type: "floor", Pixels: floor},
+	}}
+}
+
+// angleDiff returns the absolute difference between two angles in degrees.
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
+
+// ---------------------------------------------------------------------------
+// Wall orientations
+// ---------------------------------------------------------------------------
+
+func TestExtractWallOrientations_NonCardinal(t *testing.T) {
+	hist := ExtractWallOrientations(rotatedRoom(30))
+	dominant := hist.DominantAngles(2)
+	if len(dominant) != 2 {
+		t.Fatalf("dominant = %v, want two angles", dominant)
+	}
+	found30, found120 := false, false
+	for _, a := range dominant {
+		found30 = found30 || math.Abs(a-30) <= 1
+		found120 = found120 || math.Abs(a-120) <= 1
+	}
+	if !found30 || !found120 {
+		t.Errorf("dominant = %v, want 30 and 120", dominant)
+	}
+
+	if hist := ExtractWallOrientations(&ValetudoMap{}); hist.TotalEdges != 0 {
+		t.Errorf("empty map has %d edges", hist.TotalEdges)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// EstimateRotation
+// ---------------------------------------------------------------------------
+
+func TestEstimateRotation(t *testing.T) {
+	source := rotatedRoom(0)
+	for _, want := range []float64{0, 17, 90, 120, 200, 271} {
+		est := EstimateRotation(source, rotatedRoom(want))
+		if angleDiff(est.Rotation, want) > 2.5 {
+			t.Errorf("rotation %v: estimated %.2f", want, est.Rotation)
+		}
+		if !est.Confident() {
+			t.Errorf("rotation %v: not confident (%+v)", want, est)
+		}
+		if math.Abs(est.Offset-math.Mod(est.Rotation, 90)) > 1e-9 {
+			t.Errorf("rotation %v: offset %.2f does not match rotation %.2f", want, est.Offset, est.Rotation)
+		}
+	}
+
+	if est := EstimateRotation(source, &ValetudoMap{}); est.AngleTrusted() || est.Confident() {
+		t.Errorf("estimate without target walls = %+v, want untrusted", est)
+	}
+}
+
+func TestAlignMaps_PreAlignedNonCardinal(t *testing.T) {
+	source := rotatedRoom(0)
+	target := rotatedRoom(33)
+
+	result := AlignMaps(source, target, DefaultICPConfig())
+	if len(RotationErrors) != 1 {
+		t.Errorf("tried %d rotations, want a single pre-aligned hypothesis", len(RotationErrors))
+	}
+	angle := math.Atan2(result.Transform.C, result.Transform.A) * 180 / math.Pi
+	if angleDiff(angle, 33) > 2 {
+		t.Errorf("aligned angle = %.2f, want 33", angle)
+	}
+	if result.Score < 0.5 {
+		t.Errorf("score = %.3f, want a good alignment", result.Score)
+	}
+}
@@ -0,0 +1,60 @@
+package mesh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProfileConfig is a named composition of vacuums, e.g. one per floor or per
+// display, rendered with --profile or /profiles/{name}/composite-map.png.
+type ProfileConfig struct {
+	Name     string   `yaml:"name" json:"name"`
+	Vacuums  []string `yaml:"vacuums,omitempty" json:"vacuums,omitempty"`   // Vacuum IDs drawn (default: every vacuum)
+	Rotation *float64 `yaml:"rotation,omitempty" json:"rotation,omitempty"` // Rotate the composite by degrees, replacing --rotate-all
+}
+
+// Validate checks that the profile has a URL-safe name and only names
+// vacuums known to config.
+func (p ProfileConfig) Validate(config *Config) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if strings.ContainsAny(p.Name, "/?#% ") {
+		return fmt.Errorf("profile %s: name must not contain spaces or any of / ? # %%", p.Name)
+	}
+	for _, id := range p.Vacuums {
+		if config.GetVacuumByID(id) == nil {
+			return fmt.Errorf("profile %s: unknown vacuum %s", p.Name, id)
+		}
+	}
+	return nil
+}
+
+// Select returns the maps drawn by the profile. The map values are shared,
+// not copied.
+func (p ProfileConfig) Select(maps map[string]*ValetudoMap) map[string]*ValetudoMap {
+	if len(p.Vacuums) == 0 {
+		return maps
+	}
+	selected := make(map[string]*ValetudoMap, len(p.Vacuums))
+	for _, id := range p.Vacuums {
+		if m, ok := maps[id]; ok {
+			selected[id] = m
+		}
+	}
+	return selected
+}
+
+// GetProfile returns the profile with the given name, matched
+// case-insensitively, or nil.
+func (c *Config) GetProfile(name string) *ProfileConfig {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Profiles {
+		if strings.EqualFold(c.Profiles[i].Name, name) {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
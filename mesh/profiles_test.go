@@ -0,0 +1,62 @@
+package mesh
+
+import (
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// ProfileConfig
+// ---------------------------------------------------------------------------
+
+func TestProfileConfig_Validate(t *testing.T) {
+	config := &Config{Vacuums: []VacuumConfig{{ID: "a"}, {ID: "b"}}}
+
+	tests := []struct {
+		name    string
+		profile ProfileConfig
+		wantErr bool
+	}{
+		{"all vacuums", ProfileConfig{Name: "all"}, false},
+		{"subset", ProfileConfig{Name: "upstairs", Vacuums: []string{"a"}}, false},
+		{"missing name", ProfileConfig{Vacuums: []string{"a"}}, true},
+		{"slash in name", ProfileConfig{Name: "up/stairs"}, true},
+		{"space in name", ProfileConfig{Name: "up stairs"}, true},
+		{"unknown vacuum", ProfileConfig{Name: "x", Vacuums: []string{"c"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileConfig_Select(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": {}, "b": {}, "c": {}}
+
+	if got := (ProfileConfig{Name: "all"}).Select(maps); len(got) != 3 {
+		t.Errorf("empty vacuum list selected %d maps, want 3", len(got))
+	}
+
+	got := ProfileConfig{Name: "p", Vacuums: []string{"a", "c", "offline"}}.Select(maps)
+	if len(got) != 2 || got["a"] != maps["a"] || got["c"] != maps["c"] {
+		t.Errorf("Select = %v, want a and c", got)
+	}
+}
+
+func TestConfig_GetProfile(t *testing.T) {
+	config := &Config{Profiles: []ProfileConfig{{Name: "Upstairs"}, {Name: "all"}}}
+
+	if p := config.GetProfile("upstairs"); p == nil || p.Name != "Upstairs" {
+		t.Errorf("GetProfile(upstairs) = %v", p)
+	}
+	if p := config.GetProfile("basement"); p != nil {
+		t.Errorf("GetProfile(basement) = %v, want nil", p)
+	}
+	var nilConfig *Config
+	if p := nilConfig.GetProfile("all"); p != nil {
+		t.Errorf("nil config GetProfile = %v, want nil", p)
+	}
+}
@@ -9,13 +9,31 @@ import (
 	"time"
 )
 
+// Names of the topics the Publisher sends, for per-topic PublishOptions.
+const (
+	PublishTopicPosition     = "position"     // {prefix}/{vacuumID}
+	PublishTopicPositions    = "positions"    // {prefix}/positions
+	PublishTopicMapUpdated   = "mapUpdated"   // {prefix}/map/updated
+	PublishTopicMapSummary   = "mapSummary"   // {prefix}/map/summary
+	PublishTopicCleaning     = "cleaning"     // {prefix}/{vacuumID}/cleaning
+	PublishTopicBatteryAlert = "batteryAlert" // {prefix}/{vacuumID}/battery/alert
+)
+
+// PublishTopics lists the topic names PublishConfig.Topics accepts.
+var PublishTopics = []string{
+	PublishTopicPosition, PublishTopicPositions, PublishTopicMapUpdated,
+	PublishTopicMapSummary, PublishTopicCleaning, PublishTopicBatteryAlert,
+}
+
 // Publisher manages publishing transformed vacuum positions to MQTT
 type Publisher struct {
 	client        MQTTClientInterface
 	publishPrefix string
 	qos           byte
 	retain        bool
+	topics        map[string]PublishOptions // per-topic overrides of qos and retain
 	positions     map[string]*VacuumPosition
+	names         map[string]string // vacuum ID -> display name
 	mu            sync.RWMutex
 }
 
@@ -30,14 +48,23 @@ func NewPublisher(client MQTTClientInterface) *Publisher {
 	return &Publisher{
 		client:        client,
 		publishPrefix: prefix,
-		qos:           0,    // QoS 0 for position updates (fire and forget)
-		retain:        true, // Retain for latest position
+		qos:           0,    // QoS 0 by default (fire and forget)
+		retain:        true, // Retain so consumers get the latest state on connect
 		positions:     make(map[string]*VacuumPosition),
+		names:         make(map[string]string),
 	}
 }
 
-// PublishPosition publishes a single vacuum's transformed position to MQTT
-// Publishes to both individual topic and combined positions topic
+// SetDisplayName sets the friendly name included in a vacuum's position payloads
+func (p *Publisher) SetDisplayName(vacuumID, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names[vacuumID] = name
+}
+
+// PublishPosition publishes a single vacuum's transformed position to its
+// own topic. The combined positions topic is published separately, see
+// PublishCombinedPositions.
 func (p *Publisher) PublishPosition(vacuumID string, x, y, angle float64) error {
 	if p.client == nil || !p.client.IsConnected() {
 		return fmt.Errorf("MQTT client not connected")
@@ -51,8 +78,9 @@ func (p *Publisher) PublishPosition(vacuumID string, x, y, angle float64) error
 		Timestamp: time.Now().Unix(),
 	}
 
-	// Store position for combined message
+	// Store position for GetPosition
 	p.mu.Lock()
+	position.DisplayName = p.names[vacuumID]
 	p.positions[vacuumID] = position
 	p.mu.Unlock()
 
@@ -61,13 +89,6 @@ func (p *Publisher) PublishPosition(vacuumID string, x, y, angle float64) error
 		log.Printf("Error publishing individual position for %s: %v", vacuumID, err)
 		return err
 	}
-
-	// Publish to combined topic: tudomesh/positions
-	if err := p.publishCombined(); err != nil {
-		log.Printf("Error publishing combined positions: %v", err)
-		return err
-	}
-
 	return nil
 }
 
@@ -80,7 +101,8 @@ func (p *Publisher) publishIndividual(pos *VacuumPosition) error {
 		return fmt.Errorf("marshaling position: %w", err)
 	}
 
-	token := p.client.Publish(topic, p.qos, p.retain, payload)
+	qos, retain := p.options(PublishTopicPosition)
+	token := p.client.Publish(topic, qos, retain, payload)
 	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
 		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
 	}
@@ -90,37 +112,129 @@ func (p *Publisher) publishIndividual(pos *VacuumPosition) error {
 	return nil
 }
 
-// publishCombined publishes all vacuum positions to the combined topic
-func (p *Publisher) publishCombined() error {
-	p.mu.RLock()
-	positions := make([]*VacuumPosition, 0, len(p.positions))
-	for _, pos := range p.positions {
-		positions = append(positions, pos)
+// PublishCombinedPositions publishes the positions of all vacuums as one
+// document to {prefix}/positions.
+func (p *Publisher) PublishCombinedPositions(positions CombinedPositions) error {
+	if p.client == nil || !p.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
 	}
-	p.mu.RUnlock()
-
-	if len(positions) == 0 {
+	if len(positions.Vacuums) == 0 {
 		return nil
 	}
 
 	topic := fmt.Sprintf("%s/positions", p.publishPrefix)
+	payload, err := json.Marshal(positions)
+	if err != nil {
+		return fmt.Errorf("marshaling combined positions: %w", err)
+	}
+
+	qos, retain := p.options(PublishTopicPositions)
+	token := p.client.Publish(topic, qos, retain, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+
+	return nil
+}
 
-	// Create combined message
-	message := map[string]interface{}{
-		"vacuums":   positions,
-		"timestamp": time.Now().Unix(),
+// PublishMapChange announces a new unified map version on
+// {prefix}/map/updated. The message is retained so consumers that connect
+// later learn the current version.
+func (p *Publisher) PublishMapChange(change MapChange) error {
+	if p.client == nil || !p.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
 	}
 
-	payload, err := json.Marshal(message)
+	topic := fmt.Sprintf("%s/map/updated", p.publishPrefix)
+	payload, err := json.Marshal(change)
 	if err != nil {
-		return fmt.Errorf("marshaling combined positions: %w", err)
+		return fmt.Errorf("marshaling map change: %w", err)
+	}
+
+	qos, retain := p.options(PublishTopicMapUpdated)
+	token := p.client.Publish(topic, qos, retain, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+
+	log.Printf("Published unified map version %d", change.Version)
+	return nil
+}
+
+// PublishMapSummary publishes the summary of the unified map to
+// {prefix}/map/summary, retained so automations can list the rooms at any
+// time.
+func (p *Publisher) PublishMapSummary(summary *UnifiedMapSummary) error {
+	if p.client == nil || !p.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+	if summary == nil {
+		return fmt.Errorf("no unified map to summarize")
+	}
+
+	topic := fmt.Sprintf("%s/map/summary", p.publishPrefix)
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("marshaling map summary: %w", err)
+	}
+
+	qos, retain := p.options(PublishTopicMapSummary)
+	token := p.client.Publish(topic, qos, retain, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+
+	log.Printf("Published map summary: %d segment(s), version %d", len(summary.Segments), summary.Version)
+	return nil
+}
+
+// PublishCleaningTarget publishes which unified rooms a vacuum is cleaning
+// to {prefix}/{vacuumID}/cleaning. The message is retained so consumers
+// that connect mid-clean see the current target; an idle vacuum publishes
+// an inactive target.
+func (p *Publisher) PublishCleaningTarget(target CleaningTarget) error {
+	if p.client == nil || !p.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	topic := fmt.Sprintf("%s/%s/cleaning", p.publishPrefix, target.VacuumID)
+	payload, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("marshaling cleaning target: %w", err)
+	}
+
+	qos, retain := p.options(PublishTopicCleaning)
+	token := p.client.Publish(topic, qos, retain, payload)
+	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
+		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
+	}
+
+	log.Printf("Published cleaning target for %s: rooms=%v", target.VacuumID, target.Rooms)
+	return nil
+}
+
+// PublishBatteryAlert publishes a vacuum's low-battery state to
+// {prefix}/{vacuumID}/battery/alert. The message is retained so consumers
+// that connect later see whether the alert is still active; a cleared alert
+// publishes active=false.
+func (p *Publisher) PublishBatteryAlert(alert BatteryAlert) error {
+	if p.client == nil || !p.client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
 	}
 
-	token := p.client.Publish(topic, p.qos, p.retain, payload)
+	topic := fmt.Sprintf("%s/%s/battery/alert", p.publishPrefix, alert.VacuumID)
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("marshaling battery alert: %w", err)
+	}
+
+	qos, retain := p.options(PublishTopicBatteryAlert)
+	token := p.client.Publish(topic, qos, retain, payload)
 	if token.WaitTimeout(2*time.Second) && token.Error() != nil {
 		return fmt.Errorf("publishing to %s: %w", topic, token.Error())
 	}
 
+	log.Printf("Published battery alert for %s: active=%v battery=%d%%", alert.VacuumID, alert.Active, alert.Battery)
 	return nil
 }
 
@@ -156,15 +270,48 @@ func (p *Publisher) ClearPosition(vacuumID string) {
 // SetQoS sets the Quality of Service level for publishing (0, 1, or 2)
 func (p *Publisher) SetQoS(qos byte) {
 	if qos <= 2 {
+		p.mu.Lock()
 		p.qos = qos
+		p.mu.Unlock()
 	}
 }
 
 // SetRetain sets whether published messages should be retained by the broker
 func (p *Publisher) SetRetain(retain bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.retain = retain
 }
 
+// Configure applies the QoS level, retain flag and per-topic overrides of
+// config, which LoadConfig has validated.
+func (p *Publisher) Configure(config PublishConfig) {
+	p.SetQoS(byte(config.QoS))
+	if config.Retain != nil {
+		p.SetRetain(*config.Retain)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.topics = config.Topics
+}
+
+// options returns the QoS level and retain flag of the named topic: its
+// override where set, otherwise the publisher's defaults.
+func (p *Publisher) options(topic string) (byte, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	qos, retain := p.qos, p.retain
+	if o, ok := p.topics[topic]; ok {
+		if o.QoS != nil && *o.QoS >= 0 && *o.QoS <= 2 {
+			qos = byte(*o.QoS)
+		}
+		if o.Retain != nil {
+			retain = *o.Retain
+		}
+	}
+	return qos, retain
+}
+
 // PublishTransformedPosition applies a transform and publishes the result
 // This is a convenience function for the main service loop
 func (p *Publisher) PublishTransformedPosition(vacuumID string, localPos Point, localAngle float64, transform AffineMatrix) error {
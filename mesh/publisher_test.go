@@ -3,6 +3,7 @@ package mesh
 import (
 	"encoding/json"
 	"math"
+	"reflect"
 	"testing"
 )
 
@@ -216,52 +217,63 @@ func TestPublisher_PublishPositionFormat(t *testing.T) {
 	}
 }
 
-func TestPublisher_CombinedMessageFormat(t *testing.T) {
-	publisher := NewPublisher(nil)
-
-	// Add multiple positions
-	publisher.positions["vacuum1"] = &VacuumPosition{
-		VacuumID: "vacuum1",
-		X:        100.0,
-		Y:        200.0,
-		Angle:    0.0,
-	}
-	publisher.positions["vacuum2"] = &VacuumPosition{
-		VacuumID: "vacuum2",
-		X:        300.0,
-		Y:        400.0,
-		Angle:    180.0,
-	}
+func TestPublisher_DisplayName(t *testing.T) {
+	publisher := NewPublisher(NewMockClient())
+	publisher.SetDisplayName("vacuum1", "Upstairs")
 
-	// Build combined message (simulates publishCombined)
-	positions := publisher.GetAllPositions()
-	positionList := make([]*VacuumPosition, 0, len(positions))
-	for _, pos := range positions {
-		positionList = append(positionList, pos)
+	if err := publisher.PublishPosition("vacuum1", 1, 2, 90); err != nil {
+		t.Fatalf("PublishPosition() error = %v", err)
 	}
 
-	message := map[string]interface{}{
-		"vacuums":   positionList,
-		"timestamp": int64(1706140800),
+	pos, ok := publisher.GetPosition("vacuum1")
+	if !ok || pos.DisplayName != "Upstairs" {
+		t.Fatalf("position = %+v, want DisplayName Upstairs", pos)
 	}
-
-	// Verify JSON marshaling
-	jsonBytes, err := json.Marshal(message)
+	jsonBytes, err := json.Marshal(pos)
 	if err != nil {
 		t.Fatalf("json.Marshal() error = %v", err)
 	}
-
-	// Verify JSON can be decoded
 	var decoded map[string]interface{}
 	if err := json.Unmarshal(jsonBytes, &decoded); err != nil {
 		t.Fatalf("json.Unmarshal() error = %v", err)
 	}
+	if decoded["displayName"] != "Upstairs" {
+		t.Errorf("payload displayName = %v, want Upstairs", decoded["displayName"])
+	}
+}
 
-	if _, ok := decoded["vacuums"]; !ok {
-		t.Error("Combined message should have 'vacuums' field")
+func TestPublisher_PublishCombinedPositions(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+
+	if err := publisher.PublishCombinedPositions(CombinedPositions{Timestamp: 1706140800}); err != nil {
+		t.Fatalf("PublishCombinedPositions() without vacuums error = %v", err)
+	}
+	if n := len(mock.GetPublishedMessages()); n != 0 {
+		t.Fatalf("published %d messages without vacuums, want 0", n)
 	}
-	if _, ok := decoded["timestamp"]; !ok {
-		t.Error("Combined message should have 'timestamp' field")
+
+	positions := CombinedPositions{
+		Timestamp: 1706140800,
+		Vacuums: []CombinedPosition{
+			{VacuumID: "vacuum1", X: 500, Y: 1000, Angle: 90, Room: "Kitchen", Timestamp: 1706140799, Age: 1},
+			{VacuumID: "vacuum2", X: 3000, Y: 200, Timestamp: 1706140700, Age: 100},
+		},
+	}
+	if err := publisher.PublishCombinedPositions(positions); err != nil {
+		t.Fatalf("PublishCombinedPositions() error = %v", err)
+	}
+
+	messages := mock.GetPublishedMessages()
+	if len(messages) != 1 || messages[0].Topic != "tudomesh/positions" {
+		t.Fatalf("Published messages = %+v, want one to tudomesh/positions", messages)
+	}
+	var got CombinedPositions
+	if err := json.Unmarshal(messages[0].Payload, &got); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if !reflect.DeepEqual(got, positions) {
+		t.Errorf("payload = %+v, want %+v", got, positions)
 	}
 }
 
@@ -437,8 +449,178 @@ func TestPublisher_PublishWithMockClient(t *testing.T) {
 
 	// Verify MQTT messages were published
 	messages := mock.GetPublishedMessages()
-	if len(messages) != 2 {
-		t.Errorf("Published messages count = %d, want 2 (individual + combined)", len(messages))
+	if len(messages) != 1 || messages[0].Topic != "tudomesh/vacuum1" {
+		t.Errorf("Published messages = %+v, want one to tudomesh/vacuum1", messages)
+	}
+}
+
+func TestPublisher_PublishMapChange(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+
+	change := MapChange{Version: 3, PreviousVersion: 2, Floors: FeatureChanges{Changed: 1}}
+	if err := publisher.PublishMapChange(change); err != nil {
+		t.Fatalf("PublishMapChange() error = %v", err)
+	}
+
+	messages := mock.GetPublishedMessages()
+	if len(messages) != 1 {
+		t.Fatalf("Published messages count = %d, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "tudomesh/map/updated" || !msg.Retain {
+		t.Errorf("published to %s (retained %v), want retained tudomesh/map/updated", msg.Topic, msg.Retain)
+	}
+	var got MapChange
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if got != change {
+		t.Errorf("payload = %+v, want %+v", got, change)
+	}
+}
+
+func TestPublisher_PublishMapSummary(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+
+	summary := &UnifiedMapSummary{Version: 2, Segments: []SegmentSummary{{Name: "Kitchen", Area: 6, Vacuums: []string{"rocky"}}}, Vacuums: []VacuumCoverage{}}
+	if err := publisher.PublishMapSummary(summary); err != nil {
+		t.Fatalf("PublishMapSummary() error = %v", err)
+	}
+
+	messages := mock.GetPublishedMessages()
+	if len(messages) != 1 {
+		t.Fatalf("Published messages count = %d, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "tudomesh/map/summary" || !msg.Retain {
+		t.Errorf("published to %s (retained %v), want retained tudomesh/map/summary", msg.Topic, msg.Retain)
+	}
+	var got UnifiedMapSummary
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if !reflect.DeepEqual(&got, summary) {
+		t.Errorf("payload = %+v, want %+v", got, *summary)
+	}
+
+	if err := publisher.PublishMapSummary(nil); err == nil {
+		t.Error("expected an error for a nil summary")
+	}
+}
+
+func TestPublisher_PublishCleaningTarget(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+
+	target := CleaningTarget{VacuumID: "vacuum1", Active: true, Segments: []string{"Küche"}, Rooms: []string{"Kitchen"}}
+	if err := publisher.PublishCleaningTarget(target); err != nil {
+		t.Fatalf("PublishCleaningTarget() error = %v", err)
+	}
+
+	messages := mock.GetPublishedMessages()
+	if len(messages) != 1 {
+		t.Fatalf("Published messages count = %d, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "tudomesh/vacuum1/cleaning" || !msg.Retain {
+		t.Errorf("published to %s (retained %v), want retained tudomesh/vacuum1/cleaning", msg.Topic, msg.Retain)
+	}
+	var got CleaningTarget
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if !reflect.DeepEqual(got, target) {
+		t.Errorf("payload = %+v, want %+v", got, target)
+	}
+}
+
+func TestPublisher_PublishBatteryAlert(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+
+	alert := BatteryAlert{VacuumID: "vacuum1", Active: true, Battery: 12, Threshold: 20, X: 3000, Y: 1500, DockDistance: 2800, Timestamp: 1700000000}
+	if err := publisher.PublishBatteryAlert(alert); err != nil {
+		t.Fatalf("PublishBatteryAlert() error = %v", err)
+	}
+
+	messages := mock.GetPublishedMessages()
+	if len(messages) != 1 {
+		t.Fatalf("Published messages count = %d, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "tudomesh/vacuum1/battery/alert" || !msg.Retain {
+		t.Errorf("published to %s (retained %v), want retained tudomesh/vacuum1/battery/alert", msg.Topic, msg.Retain)
+	}
+	var got BatteryAlert
+	if err := json.Unmarshal(msg.Payload, &got); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if got != alert {
+		t.Errorf("payload = %+v, want %+v", got, alert)
+	}
+}
+
+func TestPublisher_Configure(t *testing.T) {
+	mock := NewMockClient()
+	publisher := NewPublisher(mock)
+	one, off := 1, false
+	publisher.Configure(PublishConfig{
+		QoS: 2,
+		Topics: map[string]PublishOptions{
+			PublishTopicPosition:  {QoS: new(int)},
+			PublishTopicPositions: {QoS: &one},
+			PublishTopicCleaning:  {Retain: &off},
+		},
+	})
+
+	if err := publisher.PublishPosition("vacuum1", 1, 2, 0); err != nil {
+		t.Fatalf("PublishPosition() error = %v", err)
+	}
+	if err := publisher.PublishCombinedPositions(CombinedPositions{Vacuums: []CombinedPosition{{VacuumID: "vacuum1"}}}); err != nil {
+		t.Fatalf("PublishCombinedPositions() error = %v", err)
+	}
+	if err := publisher.PublishCleaningTarget(CleaningTarget{VacuumID: "vacuum1"}); err != nil {
+		t.Fatalf("PublishCleaningTarget() error = %v", err)
+	}
+	if err := publisher.PublishMapChange(MapChange{Version: 1}); err != nil {
+		t.Fatalf("PublishMapChange() error = %v", err)
+	}
+
+	want := map[string]struct {
+		qos    byte
+		retain bool
+	}{
+		"tudomesh/vacuum1":          {0, true},
+		"tudomesh/positions":        {1, true},
+		"tudomesh/vacuum1/cleaning": {2, false},
+		"tudomesh/map/updated":      {2, true},
+	}
+	messages := mock.GetPublishedMessages()
+	if len(messages) != len(want) {
+		t.Fatalf("Published messages count = %d, want %d", len(messages), len(want))
+	}
+	for _, msg := range messages {
+		w, ok := want[msg.Topic]
+		if !ok {
+			t.Errorf("unexpected topic %s", msg.Topic)
+			continue
+		}
+		if msg.QoS != w.qos || msg.Retain != w.retain {
+			t.Errorf("%s: qos=%d retain=%v, want qos=%d retain=%v", msg.Topic, msg.QoS, msg.Retain, w.qos, w.retain)
+		}
+	}
+}
+
+func TestPublisher_ConfigureRetain(t *testing.T) {
+	publisher := NewPublisher(nil)
+	off := false
+	publisher.Configure(PublishConfig{Retain: &off})
+	for _, topic := range PublishTopics {
+		if qos, retain := publisher.options(topic); qos != 0 || retain {
+			t.Errorf("%s: qos=%d retain=%v, want 0 and not retained", topic, qos, retain)
+		}
 	}
 }
 
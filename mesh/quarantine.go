@@ -0,0 +1,70 @@
+package mesh
+
+import (
+	"sync"
+	"time"
+)
+
+// A map quarantined only for disagreeing with the last accepted map, such
+// as the first map after a map reset or remap, replaces it once
+// QuarantineAcceptCount consecutive quarantined maps agree with each other,
+// or once such maps have kept arriving for QuarantineAcceptAfter.
+const (
+	QuarantineAcceptCount = 3
+	QuarantineAcceptAfter = 10 * time.Minute
+)
+
+// MapQuarantine applies CheckMapSanity to each vacuum's incoming maps and
+// tracks the run of maps it rejected, so that a lasting change of map is
+// eventually accepted. The zero value is ready to use and safe for
+// concurrent use.
+type MapQuarantine struct {
+	mu      sync.Mutex
+	streaks map[string]*quarantineStreak
+}
+
+// quarantineStreak is a run of consecutive quarantined maps of one vacuum
+// that agree with each other.
+type quarantineStreak struct {
+	last  *ValetudoMap
+	count int
+	since time.Time
+}
+
+// Check returns why m, received from vacuumID at now, should not replace
+// previous, as CheckMapSanity does. accepted reports that m is taken
+// although it disagrees with previous, because the maps quarantined before
+// it agree with it; err is nil then. A map that is unusable on its own,
+// such as one without a pixel size, is never accepted.
+func (q *MapQuarantine) Check(vacuumID string, m, previous *ValetudoMap, now time.Time) (accepted bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err = CheckMapSanity(m, previous)
+	if err == nil {
+		// Position-only updates carry no floor to agree or disagree with
+		if HasDrawablePixels(m) {
+			delete(q.streaks, vacuumID)
+		}
+		return false, nil
+	}
+	if CheckMapSanity(m, nil) != nil {
+		return false, err
+	}
+
+	streak := q.streaks[vacuumID]
+	if streak == nil || CheckMapSanity(m, streak.last) != nil {
+		streak = &quarantineStreak{since: now}
+		if q.streaks == nil {
+			q.streaks = make(map[string]*quarantineStreak)
+		}
+		q.streaks[vacuumID] = streak
+	}
+	streak.last = m
+	streak.count++
+	if streak.count >= QuarantineAcceptCount || streak.count > 1 && now.Sub(streak.since) >= QuarantineAcceptAfter {
+		delete(q.streaks, vacuumID)
+		return true, nil
+	}
+	return false, err
+}
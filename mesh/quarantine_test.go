@@ -0,0 +1,68 @@
+package mesh
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMapQuarantine_AcceptsAgreeingMaps(t *testing.T) {
+	var q MapQuarantine
+	previous, reset := rectFloor(0, 0, 100, 10), rectFloor(0, 0, 100, 1)
+	now := time.Unix(1700000000, 0)
+
+	for i := 1; i < QuarantineAcceptCount; i++ {
+		if accepted, err := q.Check("rocky", reset, previous, now); accepted || !errors.Is(err, ErrAreaDropped) {
+			t.Fatalf("map %d: accepted=%v err=%v, want quarantined", i, accepted, err)
+		}
+		// Position-only updates in between do not break the run
+		if _, err := q.Check("rocky", &ValetudoMap{PixelSize: 5}, previous, now); err != nil {
+			t.Fatalf("position update: %v", err)
+		}
+	}
+	if accepted, err := q.Check("rocky", reset, previous, now); !accepted || err != nil {
+		t.Errorf("map %d: accepted=%v err=%v, want accepted", QuarantineAcceptCount, accepted, err)
+	}
+	// The run starts over
+	if accepted, _ := q.Check("rocky", reset, previous, now); accepted {
+		t.Error("accepted again straight away")
+	}
+}
+
+func TestMapQuarantine_DisagreeingMapsStartOver(t *testing.T) {
+	var q MapQuarantine
+	previous := rectFloor(0, 0, 100, 10)
+	now := time.Unix(1700000000, 0)
+	for _, n := range []int{100, 10, 100, 10} {
+		if accepted, _ := q.Check("rocky", rectFloor(0, 0, n, 1), previous, now); accepted {
+			t.Fatalf("accepted a %d pixel map among disagreeing ones", n)
+		}
+	}
+	// A usable map ends the run
+	q.Check("rocky", rectFloor(0, 0, 100, 1), previous, now)
+	q.Check("rocky", rectFloor(0, 0, 100, 9), previous, now)
+	if accepted, _ := q.Check("rocky", rectFloor(0, 0, 100, 1), previous, now); accepted {
+		t.Error("accepted after the run was broken by a usable map")
+	}
+}
+
+func TestMapQuarantine_AcceptsAfterWindow(t *testing.T) {
+	var q MapQuarantine
+	previous, reset := rectFloor(0, 0, 100, 10), rectFloor(0, 0, 100, 1)
+	now := time.Unix(1700000000, 0)
+	q.Check("rocky", reset, previous, now)
+	if accepted, _ := q.Check("rocky", reset, previous, now.Add(QuarantineAcceptAfter)); !accepted {
+		t.Error("not accepted after the window")
+	}
+}
+
+func TestMapQuarantine_NeverAcceptsBrokenMaps(t *testing.T) {
+	var q MapQuarantine
+	broken := rectFloor(0, 0, 100, 1)
+	broken.PixelSize = 0
+	for i := 0; i < 2*QuarantineAcceptCount; i++ {
+		if accepted, err := q.Check("rocky", broken, rectFloor(0, 0, 100, 10), time.Now()); accepted || !errors.Is(err, ErrZeroPixelSize) {
+			t.Fatalf("accepted=%v err=%v, want ErrZeroPixelSize", accepted, err)
+		}
+	}
+}
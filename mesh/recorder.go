@@ -0,0 +1,168 @@
+package mesh
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Recorder defaults.
+const (
+	DefaultRecordMaxBytes = 100 << 20 // per file, on disk
+	DefaultRecordFiles    = 5         // active file plus rotated ones
+)
+
+// Recorder archives received MQTT messages as a JSON Lines recording that
+// --replay can play back. Paths ending in .gz are gzip compressed. When the
+// active file reaches MaxBytes it is renamed to path.1 (path.1 to path.2,
+// and so on) and a new file is started; at most Files files are kept.
+type Recorder struct {
+	path     string
+	maxBytes int64
+	files    int
+
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	out  io.Writer
+	size int64 // bytes written to file
+}
+
+// NewRecorder creates a recorder writing to path. An existing recording at
+// path is rotated out of the way rather than overwritten.
+func NewRecorder(path string, maxBytes int64, files int) (*Recorder, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("record size limit must be positive")
+	}
+	if files < 1 {
+		return nil, fmt.Errorf("record file count must be at least 1")
+	}
+	r := &Recorder{path: path, maxBytes: maxBytes, files: files}
+	if _, err := os.Stat(path); err == nil {
+		if err := r.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends msg to the recording, rotating first if the active file is
+// full.
+func (r *Recorder) Record(msg RecordedMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encoding recorded message: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return fmt.Errorf("recorder is closed")
+	}
+	if r.size >= r.maxBytes {
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+		if err := r.rotate(); err != nil {
+			return err
+		}
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.out.Write(line); err != nil {
+		return fmt.Errorf("writing recording: %w", err)
+	}
+	// Flush each message so a crash leaves a readable file and the size
+	// check sees compressed bytes
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			return fmt.Errorf("writing recording: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close finishes the active file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.closeFile()
+}
+
+// open starts a new active file. Callers must hold r.mu or own r.
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("opening recording: %w", err)
+	}
+	r.file, r.size = f, 0
+	r.out = &countingWriter{w: f, n: &r.size}
+	r.gz = nil
+	if strings.HasSuffix(r.path, ".gz") {
+		r.gz = gzip.NewWriter(r.out)
+		r.out = r.gz
+	}
+	return nil
+}
+
+// closeFile flushes and closes the active file. Callers must hold r.mu.
+func (r *Recorder) closeFile() error {
+	var err error
+	if r.gz != nil {
+		err = r.gz.Close()
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	r.file, r.gz, r.out = nil, nil, nil
+	if err != nil {
+		return fmt.Errorf("closing recording: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest, and moves path to
+// path.1.
+func (r *Recorder) rotate() error {
+	if r.files == 1 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotating recording: %w", err)
+		}
+		return nil
+	}
+	for i := r.files - 1; i >= 1; i-- {
+		from := r.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", r.path, i-1)
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", r.path, i)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotating recording: %w", err)
+		}
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
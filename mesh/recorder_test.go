@@ -0,0 +1,99 @@
+package mesh
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestRecorder_RoundTripThroughReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.jsonl.gz")
+	rec, err := NewRecorder(path, DefaultRecordMaxBytes, DefaultRecordFiles)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+
+	// Messages received through the MQTT client are archived
+	config := &Config{Vacuums: []VacuumConfig{{ID: "rocky", Topic: "valetudo/rocky/MapData/map-data"}}}
+	mock := NewMockClient()
+	client := newMQTTClientWithMock(mock, config, nil)
+	client.onConnect(mock)
+	client.SetRecorder(rec)
+	mock.SimulateMessage("valetudo/rocky/MapData/map-data", []byte(`{"pixelSize": 5}`))
+	mock.SimulateMessage("valetudo/rocky/StatusStateAttribute/status", []byte(`{"value":"docked"}`))
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatal("recording is not gzip compressed")
+	}
+
+	var got []RecordedMessage
+	replay := NewReplayClient()
+	replay.Subscribe("#", 0, func(_ mqtt.Client, msg mqtt.Message) {
+		got = append(got, RecordedMessage{Topic: msg.Topic(), Payload: msg.Payload()})
+	})
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := replay.Play(context.Background(), f, 0); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if len(got) != 2 || got[0].Topic != "valetudo/rocky/MapData/map-data" || string(got[1].Payload) != `{"value":"docked"}` {
+		t.Errorf("replayed %+v", got)
+	}
+}
+
+func TestRecorder_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traffic.jsonl")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every message fills a file, so each one starts a new file
+	rec, err := NewRecorder(path, 10, 3)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		msg := RecordedMessage{Time: time.Unix(int64(i), 0).UTC(), Topic: "t", Payload: []byte{byte('a' + i)}}
+		if err := rec.Record(msg); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("got %d files, want 3", len(entries))
+	}
+	for name, want := range map[string]string{"traffic.jsonl": `"ZQ=="`, "traffic.jsonl.1": `"ZA=="`, "traffic.jsonl.2": `"Yw=="`} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, want payload %s", name, data, want)
+		}
+	}
+
+	if _, err := NewRecorder(path, 0, 1); err == nil {
+		t.Error("expected error for a zero size limit")
+	}
+}
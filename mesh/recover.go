@@ -0,0 +1,32 @@
+package mesh
+
+import (
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// recoveredPanics counts the panics caught since startup.
+var recoveredPanics atomic.Int64
+
+// RecordPanic logs a recovered panic value with the stack of the goroutine
+// that panicked and counts it. Call it from the deferred function that
+// recovered; what names the work that was cut short.
+func RecordPanic(what string, v interface{}) {
+	recoveredPanics.Add(1)
+	log.Printf("[PANIC] %s: %v\n%s", what, v, debug.Stack())
+}
+
+// RecoverPanic, deferred, keeps a panic in the work named by what from
+// taking down the process: the panic is recorded and the calling function
+// returns normally with its zero results.
+func RecoverPanic(what string) {
+	if v := recover(); v != nil {
+		RecordPanic(what, v)
+	}
+}
+
+// RecoveredPanics returns the number of panics recovered since startup.
+func RecoveredPanics() int64 {
+	return recoveredPanics.Load()
+}
@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"sort"
+	"strconv"
 	"time"
 
 	"golang.org/x/image/font"
@@ -55,9 +56,21 @@ type CompositeRenderer struct {
 	Transforms     map[string]AffineMatrix
 	Colors         map[string]VacuumColor
 	Reference      string
-	Scale          float64 // Pixels per map unit (default 0.1 = 10 map units per pixel)
-	Padding        int     // Padding around the image
-	GlobalRotation float64 // Rotate entire output (0, 90, 180, 270 degrees CCW)
+	Scale          float64                // Pixels per map unit (default 0.1 = 10 map units per pixel)
+	Padding        int                    // Padding around the image
+	GlobalRotation float64                // Rotate entire output (degrees CCW, any angle)
+	MaxDimension   int                    // Largest width/height in pixels (0 = DefaultMaxRenderDimension)
+	Layering       Layering               // Per-vacuum z-order and opacity
+	Legend         LegendOptions          // Legend visibility, placement and labels
+	Icons          map[string]*MarkerIcon // Robot marker icons by vacuum ID
+	Crop           *CropRegion            // Render only this world region (mm); nil renders everything
+	Overlay        OverlayOptions         // Metric grid and scale bar
+	Font           *Font                  // Text typeface (nil = built-in bitmap font)
+	Floorplan      *Floorplan             // Architectural drawing beneath the maps; nil draws none
+	Active         map[string]ActiveArea  // Areas being cleaned, tinted by RenderLive
+	FillFloors     bool                   // Draw floors by filling image pixels over floor cells instead of cell by cell
+
+	toWorld func(x, y float64) Point // image to world grid mapping of the last Render
 }
 
 // NewCompositeRenderer creates a renderer with default settings
@@ -100,6 +113,14 @@ func (r *CompositeRenderer) HasDrawableContent() bool {
 	return false
 }
 
+// maxDimension returns the configured size cap, falling back to the default.
+func (r *CompositeRenderer) maxDimension() int {
+	if r.MaxDimension > 0 {
+		return r.MaxDimension
+	}
+	return DefaultMaxRenderDimension
+}
+
 // applyGlobalRotation rotates a point around the center by the global rotation angle
 func (r *CompositeRenderer) applyGlobalRotation(p Point, centerX, centerY float64) Point {
 	if r.GlobalRotation == 0 {
@@ -122,32 +143,96 @@ func (r *CompositeRenderer) applyGlobalRotation(p Point, centerX, centerY float6
 	return Point{X: newX + centerX, Y: newY + centerY}
 }
 
-// CalculateBounds computes the bounding box of all transformed maps
+// isCardinalRotation reports whether deg is a multiple of 90 degrees, where
+// map cells land exactly on output pixels.
+func isCardinalRotation(deg float64) bool {
+	return math.Mod(deg, 90) == 0
+}
+
+// pixelCover returns a function that calls fn for every output pixel covered
+// by the unit map cell at world point p, using the same mapping as Render.
+// For cardinal rotations that is the single pixel p maps to. For arbitrary
+// angles each output pixel is inverse-mapped back into map space and
+// included when its center falls inside the cell, so rotated cells tile the
+// output without the holes that forward-mapping single points leaves. When
+// Scale < 1 most cells cover no pixel center; such a cell is drawn at the
+// pixel its own center maps to, so thin walls and sparse cells do not vanish.
+func (r *CompositeRenderer) pixelCover(minX, minY, centerX, centerY float64) func(p Point, fn func(x, y int)) {
+	toImageF := func(p Point) (float64, float64) {
+		rp := r.applyGlobalRotation(p, centerX, centerY)
+		return (rp.X-minX)*r.Scale + float64(r.Padding), (rp.Y-minY)*r.Scale + float64(r.Padding)
+	}
+
+	if isCardinalRotation(r.GlobalRotation) {
+		return func(p Point, fn func(x, y int)) {
+			rp := r.applyGlobalRotation(p, centerX, centerY)
+			fn(int((rp.X-minX)*r.Scale)+r.Padding, int((rp.Y-minY)*r.Scale)+r.Padding)
+		}
+	}
+
+	toWorld := r.imageToWorld(minX, minY, centerX, centerY)
+
+	return func(p Point, fn func(x, y int)) {
+		x0, y0 := math.Inf(1), math.Inf(1)
+		x1, y1 := math.Inf(-1), math.Inf(-1)
+		for _, c := range [4]Point{{p.X, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y + 1}, {p.X + 1, p.Y + 1}} {
+			x, y := toImageF(c)
+			x0, y0 = math.Min(x0, x), math.Min(y0, y)
+			x1, y1 = math.Max(x1, x), math.Max(y1, y)
+		}
+		covered := false
+		for iy := int(math.Floor(y0)); iy <= int(math.Ceil(y1)); iy++ {
+			for ix := int(math.Floor(x0)); ix <= int(math.Ceil(x1)); ix++ {
+				w := toWorld(float64(ix)+0.5, float64(iy)+0.5)
+				if w.X >= p.X && w.X < p.X+1 && w.Y >= p.Y && w.Y < p.Y+1 {
+					fn(ix, iy)
+					covered = true
+				}
+			}
+		}
+		if !covered && r.Scale < 1 {
+			x, y := toImageF(Point{X: p.X + 0.5, Y: p.Y + 0.5})
+			fn(int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+}
+
+// CalculateBounds computes the bounding box of all transformed maps, or of
+// the crop region when one is set
 func (r *CompositeRenderer) CalculateBounds() (minX, minY, maxX, maxY, centerX, centerY float64) {
+	if r.Crop != nil {
+		return r.cropBounds()
+	}
+
 	minX, minY = math.MaxFloat64, math.MaxFloat64
 	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
 
+	buf := acquirePoints()
+	defer releasePoints(buf)
+
 	// First pass: get bounds without global rotation to find center
 	for id, m := range r.Maps {
 		transform := r.Transforms[id]
 
 		for _, layer := range m.Layers {
 			if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
-				points := PixelsToPoints(layer.Pixels)
+				points := pixelsToPointsInto(buf, layer.Pixels)
 				for _, p := range points {
-					tp := TransformPoint(p, transform)
-					if tp.X < minX {
-						minX = tp.X
-					}
-					if tp.Y < minY {
-						minY = tp.Y
-					}
-					if tp.X > maxX {
-						maxX = tp.X
-					}
-					if tp.Y > maxY {
-						maxY = tp.Y
-					}
+					forEachBlockCell(p, m.CellBlock(), func(c Point) {
+						tp := TransformPoint(c, transform)
+						if tp.X < minX {
+							minX = tp.X
+						}
+						if tp.Y < minY {
+							minY = tp.Y
+						}
+						if tp.X > maxX {
+							maxX = tp.X
+						}
+						if tp.Y > maxY {
+							maxY = tp.Y
+						}
+					})
 				}
 			}
 		}
@@ -167,22 +252,24 @@ func (r *CompositeRenderer) CalculateBounds() (minX, minY, maxX, maxY, centerX,
 
 			for _, layer := range m.Layers {
 				if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
-					points := PixelsToPoints(layer.Pixels)
+					points := pixelsToPointsInto(buf, layer.Pixels)
 					for _, p := range points {
-						tp := TransformPoint(p, transform)
-						tp = r.applyGlobalRotation(tp, centerX, centerY)
-						if tp.X < minX {
-							minX = tp.X
-						}
-						if tp.Y < minY {
-							minY = tp.Y
-						}
-						if tp.X > maxX {
-							maxX = tp.X
-						}
-						if tp.Y > maxY {
-							maxY = tp.Y
-						}
+						forEachBlockCell(p, m.CellBlock(), func(c Point) {
+							tp := TransformPoint(c, transform)
+							tp = r.applyGlobalRotation(tp, centerX, centerY)
+							if tp.X < minX {
+								minX = tp.X
+							}
+							if tp.Y < minY {
+								minY = tp.Y
+							}
+							if tp.X > maxX {
+								maxX = tp.X
+							}
+							if tp.Y > maxY {
+								maxY = tp.Y
+							}
+						})
 					}
 				}
 			}
@@ -202,14 +289,15 @@ func (r *CompositeRenderer) Render() *image.RGBA {
 	height := int((maxY-minY)*r.Scale) + 2*r.Padding
 
 	// Limit size
-	if width > 4000 {
-		r.Scale *= float64(4000) / float64(width)
-		width = 4000
+	maxDim := r.maxDimension()
+	if width > maxDim {
+		r.Scale *= float64(maxDim) / float64(width)
+		width = maxDim
 		height = int((maxY-minY)*r.Scale) + 2*r.Padding
 	}
-	if height > 4000 {
-		r.Scale *= float64(4000) / float64(height)
-		height = 4000
+	if height > maxDim {
+		r.Scale *= float64(maxDim) / float64(height)
+		height = maxDim
 		width = int((maxX-minX)*r.Scale) + 2*r.Padding
 	}
 
@@ -228,6 +316,8 @@ func (r *CompositeRenderer) Render() *image.RGBA {
 		}
 	}
 
+	r.toWorld = r.imageToWorld(minX, minY, centerX, centerY)
+
 	// Create image with white background
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 	for y := 0; y < height; y++ {
@@ -235,66 +325,103 @@ func (r *CompositeRenderer) Render() *image.RGBA {
 			img.Set(x, y, color.RGBA{240, 240, 240, 255})
 		}
 	}
+	r.drawFloorplan(img, minX, minY, centerX, centerY)
 
-	// Helper to convert world coords to image coords (with global rotation)
-	toImage := func(p Point) (int, int) {
+	// Helper to convert world coords to subpixel image coords (with global
+	// rotation) for the markers
+	toImage := func(p Point) (float64, float64) {
 		// Apply global rotation around center
 		rp := r.applyGlobalRotation(p, centerX, centerY)
-		x := int((rp.X-minX)*r.Scale) + r.Padding
-		y := int((rp.Y-minY)*r.Scale) + r.Padding
+		x := (rp.X-minX)*r.Scale + float64(r.Padding)
+		y := (rp.Y-minY)*r.Scale + float64(r.Padding)
 		return x, y
 	}
 
-	// Render each vacuum's map
-	// First pass: floors/segments (semi-transparent)
-	for id, m := range r.Maps {
-		transform := r.Transforms[id]
-		vc := r.Colors[id]
+	cover := r.pixelCover(minX, minY, centerX, centerY)
 
-		for _, layer := range m.Layers {
-			if layer.Type == "floor" || layer.Type == "segment" {
-				points := PixelsToPoints(layer.Pixels)
-				for _, p := range points {
-					tp := TransformPoint(p, transform)
-					ix, iy := toImage(tp)
-					if ix >= 0 && ix < width && iy >= 0 && iy < height {
-						// Alpha blend with existing color
-						existing := img.RGBAAt(ix, iy)
-						blended := blendColors(existing, vc.Floor)
-						img.Set(ix, iy, blended)
+	buf := acquirePoints()
+	defer releasePoints(buf)
+
+	// Render each z-index level in turn so higher levels cover lower ones.
+	// Within a level: floors first (semi-transparent), then walls.
+	for _, level := range r.Layering.Levels(r.Maps) {
+		for _, id := range level {
+			m := r.Maps[id]
+			transform := r.Transforms[id]
+			floor := fade(r.Colors[id].Floor, r.Layering.OpacityFor(id))
+			if r.FillFloors && r.fillFloors(m, transform, r.toWorld, width, height, func(ix, iy int) {
+				img.Set(ix, iy, blendColors(img.RGBAAt(ix, iy), floor))
+			}) {
+				continue
+			}
+			block := m.CellBlock()
+			view := r.visibleCells(transform, r.toWorld, width, height, 0).forBlock(block)
+
+			for _, layer := range m.Layers {
+				if (layer.Type == "floor" || layer.Type == "segment") && view.overlaps(layer.Pixels) {
+					points := pixelsToPointsInto(buf, layer.Pixels)
+					for _, p := range points {
+						if !view.contains(p) {
+							continue
+						}
+						forEachBlockCell(p, block, func(c Point) {
+							cover(TransformPoint(c, transform), func(ix, iy int) {
+								if ix >= 0 && ix < width && iy >= 0 && iy < height {
+									// Alpha blend with existing color
+									existing := img.RGBAAt(ix, iy)
+									blended := blendColors(existing, floor)
+									img.Set(ix, iy, blended)
+								}
+							})
+						})
 					}
 				}
 			}
 		}
-	}
 
-	// Second pass: walls (opaque)
-	for id, m := range r.Maps {
-		transform := r.Transforms[id]
-		vc := r.Colors[id]
+		for _, id := range level {
+			m := r.Maps[id]
+			transform := r.Transforms[id]
+			wall := fade(r.Colors[id].Wall, r.Layering.OpacityFor(id))
+			block := m.CellBlock()
+			view := r.visibleCells(transform, r.toWorld, width, height, 1).forBlock(block)
 
-		for _, layer := range m.Layers {
-			if layer.Type == "wall" {
-				points := PixelsToPoints(layer.Pixels)
-				for _, p := range points {
-					tp := TransformPoint(p, transform)
-					ix, iy := toImage(tp)
-					// Draw wall as 2x2 block for visibility
-					for dx := -1; dx <= 1; dx++ {
-						for dy := -1; dy <= 1; dy++ {
-							px, py := ix+dx, iy+dy
-							if px >= 0 && px < width && py >= 0 && py < height {
-								img.Set(px, py, vc.Wall)
-							}
+			for _, layer := range m.Layers {
+				if layer.Type == "wall" && view.overlaps(layer.Pixels) {
+					points := pixelsToPointsInto(buf, layer.Pixels)
+					for _, p := range points {
+						if !view.contains(p) {
+							continue
 						}
+						forEachBlockCell(p, block, func(c Point) {
+							cover(TransformPoint(c, transform), func(ix, iy int) {
+								// Draw wall as 3x3 block for visibility
+								for dx := -1; dx <= 1; dx++ {
+									for dy := -1; dy <= 1; dy++ {
+										px, py := ix+dx, iy+dy
+										if px >= 0 && px < width && py >= 0 && py < height {
+											if wall.A == 255 {
+												img.Set(px, py, wall)
+											} else {
+												img.Set(px, py, blendColors(img.RGBAAt(px, py), wall))
+											}
+										}
+									}
+								}
+							})
+						})
 					}
 				}
 			}
 		}
 	}
 
-	// Third pass: chargers and robots
-	for id, m := range r.Maps {
+	// Grid and scale bar over the map, under the markers
+	r.drawOverlay(img, minX, minY, centerX, centerY)
+
+	// Chargers and robots on top of all map layers
+	for _, id := range r.Layering.DrawOrder(r.Maps) {
+		m := r.Maps[id]
 		transform := r.Transforms[id]
 		vc := r.Colors[id]
 
@@ -305,18 +432,22 @@ func (r *CompositeRenderer) Render() *image.RGBA {
 			drawSquare(img, ix, iy, 8, color.RGBA{255, 215, 0, 255}) // Gold charger
 		}
 
-		// Draw robot as circle
-		if robot, _, ok := ExtractRobotPosition(m); ok {
+		// Draw robot as circle, or the configured icon
+		if robot, angle, ok := ExtractRobotPosition(m); ok {
 			tr := TransformPoint(robot, transform)
 			ix, iy := toImage(tr)
 			// Convert NRGBA to RGBA for image rendering
 			robotRGBA := color.RGBA{vc.Robot.R, vc.Robot.G, vc.Robot.B, vc.Robot.A}
-			drawCircle(img, ix, iy, 6, robotRGBA)
+			if icon := r.Icons[id]; icon != nil {
+				drawMarkerIcon(img, icon, ix, iy, 12, TransformAngle(angle, transform)+r.GlobalRotation, robotRGBA)
+			} else {
+				drawCircle(img, ix, iy, 6, robotRGBA)
+			}
 		}
 	}
 
 	// Add legend
-	r.drawLegend(img, width, height)
+	r.drawLegend(img)
 
 	return img
 }
@@ -358,19 +489,21 @@ func RenderSingleMapWithRotation(m *ValetudoMap, outputPath string, floorColor,
 		if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
 			points := PixelsToPoints(layer.Pixels)
 			for _, p := range points {
-				tp := TransformPoint(p, transform)
-				if tp.X < minX {
-					minX = tp.X
-				}
-				if tp.Y < minY {
-					minY = tp.Y
-				}
-				if tp.X > maxX {
-					maxX = tp.X
-				}
-				if tp.Y > maxY {
-					maxY = tp.Y
-				}
+				forEachBlockCell(p, m.CellBlock(), func(c Point) {
+					tp := TransformPoint(c, transform)
+					if tp.X < minX {
+						minX = tp.X
+					}
+					if tp.Y < minY {
+						minY = tp.Y
+					}
+					if tp.X > maxX {
+						maxX = tp.X
+					}
+					if tp.Y > maxY {
+						maxY = tp.Y
+					}
+				})
 			}
 		}
 	}
@@ -400,10 +533,12 @@ func RenderSingleMapWithRotation(m *ValetudoMap, outputPath string, floorColor,
 		if layer.Type == "floor" || layer.Type == "segment" {
 			points := PixelsToPoints(layer.Pixels)
 			for _, p := range points {
-				ix, iy := toImage(p)
-				if ix >= 0 && ix < width && iy >= 0 && iy < height {
-					img.Set(ix, iy, floorColor)
-				}
+				forEachBlockCell(p, m.CellBlock(), func(c Point) {
+					ix, iy := toImage(c)
+					if ix >= 0 && ix < width && iy >= 0 && iy < height {
+						img.Set(ix, iy, floorColor)
+					}
+				})
 			}
 		}
 	}
@@ -413,33 +548,35 @@ func RenderSingleMapWithRotation(m *ValetudoMap, outputPath string, floorColor,
 		if layer.Type == "wall" {
 			points := PixelsToPoints(layer.Pixels)
 			for _, p := range points {
-				ix, iy := toImage(p)
-				for dx := -1; dx <= 1; dx++ {
-					for dy := -1; dy <= 1; dy++ {
-						px, py := ix+dx, iy+dy
-						if px >= 0 && px < width && py >= 0 && py < height {
-							img.Set(px, py, wallColor)
+				forEachBlockCell(p, m.CellBlock(), func(c Point) {
+					ix, iy := toImage(c)
+					for dx := -1; dx <= 1; dx++ {
+						for dy := -1; dy <= 1; dy++ {
+							px, py := ix+dx, iy+dy
+							if px >= 0 && px < width && py >= 0 && py < height {
+								img.Set(px, py, wallColor)
+							}
 						}
 					}
-				}
+				})
 			}
 		}
 	}
 
 	// Draw charger
 	if charger, ok := ExtractChargerPosition(m); ok {
-		ix, iy := toImage(charger)
-		drawSquare(img, ix, iy, 8, color.RGBA{255, 215, 0, 255})
+		x, y := pixelCenter(toImage(charger))
+		drawSquare(img, x, y, 8, color.RGBA{255, 215, 0, 255})
 	}
 
 	// Draw robot
 	if robot, _, ok := ExtractRobotPosition(m); ok {
-		ix, iy := toImage(robot)
-		drawCircle(img, ix, iy, 6, color.RGBA{255, 0, 0, 255})
+		x, y := pixelCenter(toImage(robot))
+		drawCircle(img, x, y, 6, color.RGBA{255, 0, 0, 255})
 	}
 
 	// Draw origin (0,0) as purple triangle
-	ox, oy := toImage(Point{X: 0, Y: 0})
+	ox, oy := pixelCenter(toImage(Point{X: 0, Y: 0}))
 	drawTriangle(img, ox, oy, 12, color.RGBA{128, 0, 128, 255}) // Purple
 
 	f, err := os.Create(outputPath)
@@ -484,51 +621,35 @@ func blendColors(bg color.RGBA, fg color.NRGBA) color.NRGBA {
 	}
 }
 
-// drawCircle draws a filled circle
-func drawCircle(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
-	for dy := -radius; dy <= radius; dy++ {
-		for dx := -radius; dx <= radius; dx++ {
-			if dx*dx+dy*dy <= radius*radius {
-				x, y := cx+dx, cy+dy
-				if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-					img.Set(x, y, c)
-				}
-			}
-		}
-	}
+// drawCircle draws an antialiased filled circle centered on (cx, cy)
+func drawCircle(img *image.RGBA, cx, cy, radius float64, c color.RGBA) {
+	fillPolygons(img, c, circlePolygon(cx, cy, radius))
 }
 
-// drawSquare draws a filled square
-func drawSquare(img *image.RGBA, cx, cy, size int, c color.RGBA) {
+// drawSquare draws an antialiased filled square centered on (cx, cy)
+func drawSquare(img *image.RGBA, cx, cy, size float64, c color.RGBA) {
 	half := size / 2
-	for dy := -half; dy <= half; dy++ {
-		for dx := -half; dx <= half; dx++ {
-			x, y := cx+dx, cy+dy
-			if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-				img.Set(x, y, c)
-			}
-		}
-	}
+	fillPolygons(img, c, []Point{
+		{X: cx - half, Y: cy - half},
+		{X: cx + half, Y: cy - half},
+		{X: cx + half, Y: cy + half},
+		{X: cx - half, Y: cy + half},
+	})
 }
 
-// drawTriangle draws a filled triangle pointing up
-func drawTriangle(img *image.RGBA, cx, cy, size int, c color.RGBA) {
+// drawTriangle draws an antialiased filled triangle pointing up, centered
+// on (cx, cy)
+func drawTriangle(img *image.RGBA, cx, cy, size float64, c color.RGBA) {
 	half := size / 2
-	for dy := -half; dy <= half; dy++ {
-		// Width of triangle at this row
-		progress := float64(dy+half) / float64(size)
-		width := int(progress * float64(half))
-		for dx := -width; dx <= width; dx++ {
-			x, y := cx+dx, cy+dy
-			if x >= 0 && x < img.Bounds().Max.X && y >= 0 && y < img.Bounds().Max.Y {
-				img.Set(x, y, c)
-			}
-		}
-	}
+	fillPolygons(img, c, []Point{
+		{X: cx, Y: cy - half},
+		{X: cx + half, Y: cy + half},
+		{X: cx - half, Y: cy + half},
+	})
 }
 
 // drawLegend adds a legend with text labels to the image
-func (r *CompositeRenderer) drawLegend(img *image.RGBA, width, height int) {
+func (r *CompositeRenderer) drawLegend(img *image.RGBA) {
 	// Sort vacuum IDs for consistent ordering
 	ids := make([]string, 0, len(r.Maps))
 	for id := range r.Maps {
@@ -536,22 +657,11 @@ func (r *CompositeRenderer) drawLegend(img *image.RGBA, width, height int) {
 	}
 	sort.Strings(ids)
 
-	// Legend in top-left corner
-	y := 15
+	entries := make([]legendEntry, 0, len(ids))
 	for _, id := range ids {
-		vc := r.Colors[id]
-
-		// Draw color swatch (12x12 square)
-		for dy := 0; dy < 12; dy++ {
-			for dx := 0; dx < 12; dx++ {
-				img.Set(10+dx, y+dy-6, vc.Wall)
-			}
-		}
-
-		drawText(img, 28, y, id, color.RGBA{0, 0, 0, 255})
-
-		y += 18
+		entries = append(entries, legendEntry{label: r.Legend.Label(id), swatch: r.Colors[id].Wall})
 	}
+	drawLegendEntries(img, entries, r.Legend, r.Font)
 }
 
 // drawText renders text onto an image at the specified position
@@ -689,17 +799,27 @@ func RenderWithForcedRotations(maps map[string]*ValetudoMap, forcedRotations map
 	return RenderWithCalibration(maps, forcedRotations, nil, outputPath, referenceOverride, globalRotation)
 }
 
-// RenderRotationComparison renders 4 images showing all rotation options for a vacuum
-func RenderRotationComparison(maps map[string]*ValetudoMap, vacuumID string, outputPrefix string, referenceOverride string, globalRotation float64) error {
-	rotations := []float64{0, 90, 180, 270}
+// CardinalRotations are the rotations compared when no angles are given.
+var CardinalRotations = []float64{0, 90, 180, 270}
 
+// RenderRotationComparison renders one image per rotation option for a
+// vacuum, named outputPrefix_DEGREES.png (e.g. rotation_vac_37.5.png), and
+// returns their paths. Rotations may be any angle; an empty list compares
+// CardinalRotations.
+func RenderRotationComparison(maps map[string]*ValetudoMap, vacuumID string, outputPrefix string, referenceOverride string, globalRotation float64, rotations []float64) ([]string, error) {
+	if len(rotations) == 0 {
+		rotations = CardinalRotations
+	}
+
+	paths := make([]string, 0, len(rotations))
 	for _, rot := range rotations {
-		outputPath := fmt.Sprintf("%s_%d.png", outputPrefix, int(rot))
+		outputPath := fmt.Sprintf("%s_%s.png", outputPrefix, strconv.FormatFloat(rot, 'f', -1, 64))
 		if err := RenderWithForcedRotation(maps, vacuumID, rot, outputPath, referenceOverride, globalRotation); err != nil {
-			return err
+			return paths, err
 		}
+		paths = append(paths, outputPath)
 	}
-	return nil
+	return paths, nil
 }
 
 // Greyscale colors for floorplan rendering
@@ -707,6 +827,8 @@ var (
 	GreyscaleFloor = color.NRGBA{200, 200, 200, 255} // Light grey for floor
 	GreyscaleWall  = color.NRGBA{60, 60, 60, 255}    // Dark grey for walls
 	GreyscaleBG    = color.NRGBA{240, 240, 240, 255} // Background
+
+	GreyscaleMaterial = color.NRGBA{150, 150, 150, 255} // Floor material hatching
 )
 
 // RenderGreyscale creates a greyscale composite image without color coding or legend
@@ -719,14 +841,15 @@ func (r *CompositeRenderer) RenderGreyscale() *image.RGBA {
 	height := int((maxY-minY)*r.Scale) + 2*r.Padding
 
 	// Limit size
-	if width > 4000 {
-		r.Scale *= float64(4000) / float64(width)
-		width = 4000
+	maxDim := r.maxDimension()
+	if width > maxDim {
+		r.Scale *= float64(maxDim) / float64(width)
+		width = maxDim
 		height = int((maxY-minY)*r.Scale) + 2*r.Padding
 	}
-	if height > 4000 {
-		r.Scale *= float64(4000) / float64(height)
-		height = 4000
+	if height > maxDim {
+		r.Scale *= float64(maxDim) / float64(height)
+		height = maxDim
 		width = int((maxX-minX)*r.Scale) + 2*r.Padding
 	}
 
@@ -752,28 +875,40 @@ func (r *CompositeRenderer) RenderGreyscale() *image.RGBA {
 			img.Set(x, y, GreyscaleBG)
 		}
 	}
+	r.drawFloorplan(img, minX, minY, centerX, centerY)
 
-	// Helper to convert world coords to image coords (with global rotation)
-	toImage := func(p Point) (int, int) {
-		rp := r.applyGlobalRotation(p, centerX, centerY)
-		x := int((rp.X-minX)*r.Scale) + r.Padding
-		y := int((rp.Y-minY)*r.Scale) + r.Padding
-		return x, y
-	}
+	// Helper to map world cells to image pixels (with global rotation)
+	cover := r.pixelCover(minX, minY, centerX, centerY)
+	toWorld := r.imageToWorld(minX, minY, centerX, centerY)
+
+	buf := acquirePoints()
+	defer releasePoints(buf)
 
 	// First pass: floors/segments (greyscale)
 	for id, m := range r.Maps {
 		transform := r.Transforms[id]
+		if r.FillFloors && r.fillFloors(m, transform, toWorld, width, height, func(ix, iy int) {
+			img.Set(ix, iy, GreyscaleFloor)
+		}) {
+			continue
+		}
+		block := m.CellBlock()
+		view := r.visibleCells(transform, toWorld, width, height, 0).forBlock(block)
 
 		for _, layer := range m.Layers {
-			if layer.Type == "floor" || layer.Type == "segment" {
-				points := PixelsToPoints(layer.Pixels)
+			if (layer.Type == "floor" || layer.Type == "segment") && view.overlaps(layer.Pixels) {
+				points := pixelsToPointsInto(buf, layer.Pixels)
 				for _, p := range points {
-					tp := TransformPoint(p, transform)
-					ix, iy := toImage(tp)
-					if ix >= 0 && ix < width && iy >= 0 && iy < height {
-						img.Set(ix, iy, GreyscaleFloor)
+					if !view.contains(p) {
+						continue
 					}
+					forEachBlockCell(p, block, func(c Point) {
+						cover(TransformPoint(c, transform), func(ix, iy int) {
+							if ix >= 0 && ix < width && iy >= 0 && iy < height {
+								img.Set(ix, iy, GreyscaleFloor)
+							}
+						})
+					})
 				}
 			}
 		}
@@ -782,22 +917,29 @@ func (r *CompositeRenderer) RenderGreyscale() *image.RGBA {
 	// Second pass: walls (dark grey)
 	for id, m := range r.Maps {
 		transform := r.Transforms[id]
+		block := m.CellBlock()
+		view := r.visibleCells(transform, toWorld, width, height, 1).forBlock(block)
 
 		for _, layer := range m.Layers {
-			if layer.Type == "wall" {
-				points := PixelsToPoints(layer.Pixels)
+			if layer.Type == "wall" && view.overlaps(layer.Pixels) {
+				points := pixelsToPointsInto(buf, layer.Pixels)
 				for _, p := range points {
-					tp := TransformPoint(p, transform)
-					ix, iy := toImage(tp)
-					// Draw wall as 3x3 block for visibility
-					for dx := -1; dx <= 1; dx++ {
-						for dy := -1; dy <= 1; dy++ {
-							px, py := ix+dx, iy+dy
-							if px >= 0 && px < width && py >= 0 && py < height {
-								img.Set(px, py, GreyscaleWall)
-							}
-						}
+					if !view.contains(p) {
+						continue
 					}
+					forEachBlockCell(p, block, func(c Point) {
+						cover(TransformPoint(c, transform), func(ix, iy int) {
+							// Draw wall as 3x3 block for visibility
+							for dx := -1; dx <= 1; dx++ {
+								for dy := -1; dy <= 1; dy++ {
+									px, py := ix+dx, iy+dy
+									if px >= 0 && px < width && py >= 0 && py < height {
+										img.Set(px, py, GreyscaleWall)
+									}
+								}
+							}
+						})
+					})
 				}
 			}
 		}
@@ -812,103 +954,49 @@ func (r *CompositeRenderer) RenderGreyscale() *image.RGBA {
 // angleDeg: direction in degrees (0 = East/right, 90 = South/down in image coords)
 // c: fill color for the robot
 // Note: In image coordinates, Y increases downward, so 90 degrees points down
-func drawVacuumIcon(img *image.RGBA, cx, cy, size int, angleDeg float64, c color.RGBA) {
-	bounds := img.Bounds()
+func drawVacuumIcon(img *image.RGBA, cx, cy, size, angleDeg float64, c color.RGBA) {
 	angleRad := angleDeg * math.Pi / 180.0
+	cos, sin := math.Cos(angleRad), math.Sin(angleRad)
 
 	// Robot body radius (main circle)
-	radius := float64(size) / 2.0
+	radius := size / 2.0
 
 	// Colors
 	outlineColor := color.RGBA{40, 40, 40, 255}   // Dark outline for visibility
 	bumperColor := color.RGBA{60, 60, 60, 255}    // Dark grey for front bumper
 	sensorColor := color.RGBA{200, 200, 200, 255} // Light grey for sensor area
 
-	// Helper to check if point is in bounds and set pixel
-	setPixel := func(x, y int, col color.RGBA) {
-		if x >= bounds.Min.X && x < bounds.Max.X && y >= bounds.Min.Y && y < bounds.Max.Y {
-			img.Set(x, y, col)
-		}
-	}
-
-	// Helper to rotate a point around center
-	rotatePoint := func(px, py float64) (float64, float64) {
-		cos := math.Cos(angleRad)
-		sin := math.Sin(angleRad)
-		return px*cos - py*sin, px*sin + py*cos
-	}
-
-	// Draw outline circle (slightly larger)
-	outlineRadius := radius + 2
-	for dy := -int(outlineRadius) - 1; dy <= int(outlineRadius)+1; dy++ {
-		for dx := -int(outlineRadius) - 1; dx <= int(outlineRadius)+1; dx++ {
-			dist := math.Sqrt(float64(dx*dx + dy*dy))
-			if dist <= outlineRadius && dist > radius {
-				setPixel(cx+dx, cy+dy, outlineColor)
-			}
-		}
+	// toImage maps robot-local coordinates (+X forward) to the image
+	toImage := func(lx, ly float64) Point {
+		return Point{X: cx + lx*cos - ly*sin, Y: cy + lx*sin + ly*cos}
 	}
 
-	// Draw main body circle with fill color
-	for dy := -int(radius) - 1; dy <= int(radius)+1; dy++ {
-		for dx := -int(radius) - 1; dx <= int(radius)+1; dx++ {
-			dist := math.Sqrt(float64(dx*dx + dy*dy))
-			if dist <= radius {
-				setPixel(cx+dx, cy+dy, c)
-			}
-		}
-	}
+	// Outline ring, then the body over its center
+	drawCircle(img, cx, cy, radius+2, outlineColor)
+	drawCircle(img, cx, cy, radius, c)
 
-	// Draw front bumper/direction indicator (arc at the front)
-	// The bumper is a crescent shape at the front of the robot
+	// Front bumper: the slice of the body ahead of a chord a quarter of the
+	// radius behind the front edge
 	bumperDepth := radius * 0.25
-	bumperWidth := radius * 0.7
-	for dy := -int(radius); dy <= int(radius); dy++ {
-		for dx := -int(radius); dx <= int(radius); dx++ {
-			// Rotate point to robot's local coordinates (unrotated)
-			localX, localY := rotatePoint(float64(dx), float64(dy))
-
-			// Check if in front bumper area
-			dist := math.Sqrt(float64(dx*dx + dy*dy))
-			if dist <= radius && localX > radius-bumperDepth && math.Abs(localY) < bumperWidth {
-				setPixel(cx+dx, cy+dy, bumperColor)
-			}
-		}
+	edge := math.Acos((radius - bumperDepth) / radius)
+	const bumperSteps = 12
+	bumper := make([]Point, 0, bumperSteps+1)
+	for i := 0; i <= bumperSteps; i++ {
+		a := -edge + 2*edge*float64(i)/bumperSteps
+		bumper = append(bumper, toImage(radius*math.Cos(a), radius*math.Sin(a)))
 	}
+	fillPolygons(img, bumperColor, bumper)
 
-	// Draw sensor/LiDAR turret (small circle slightly offset from center toward front)
+	// Sensor/LiDAR turret (small circle slightly offset from center toward front)
 	sensorRadius := radius * 0.25
-	sensorOffsetX, sensorOffsetY := rotatePoint(radius*0.15, 0)
-	sensorCX := float64(cx) + sensorOffsetX
-	sensorCY := float64(cy) + sensorOffsetY
-
-	for dy := -int(sensorRadius) - 1; dy <= int(sensorRadius)+1; dy++ {
-		for dx := -int(sensorRadius) - 1; dx <= int(sensorRadius)+1; dx++ {
-			dist := math.Sqrt(float64(dx*dx + dy*dy))
-			if dist <= sensorRadius {
-				setPixel(int(sensorCX)+dx, int(sensorCY)+dy, sensorColor)
-			}
-		}
-	}
-
-	// Draw direction indicator line (small line extending from center toward front)
-	// This makes the direction more obvious
-	lineLength := radius * 0.6
-	lineStartX, lineStartY := rotatePoint(sensorRadius+1, 0)
-	for t := 0.0; t <= 1.0; t += 0.05 {
-		lx := lineStartX + t*(lineLength-lineStartX)
-		ly := lineStartY
-		rx, ry := lx, ly // Already in rotated space, just offset from sensor
-		// Actually need to compute from rotated offset
-		px := float64(cx) + lx*math.Cos(angleRad) - ly*math.Sin(angleRad)
-		py := float64(cy) + lx*math.Sin(angleRad) + ly*math.Cos(angleRad)
-		_ = rx
-		_ = ry
-		setPixel(int(px), int(py), outlineColor)
-		// Make line thicker
-		setPixel(int(px)+1, int(py), outlineColor)
-		setPixel(int(px), int(py)+1, outlineColor)
-	}
+	sensor := toImage(radius*0.15, 0)
+	drawCircle(img, sensor.X, sensor.Y, sensorRadius, sensorColor)
+
+	// Direction indicator line from the turret toward the front, which makes
+	// the heading more obvious
+	lineStart := toImage(radius*0.15+sensorRadius+1, 0)
+	lineEnd := toImage(radius*0.6, 0)
+	drawLine(img, lineStart.X, lineStart.Y, lineEnd.X, lineEnd.Y, 2, outlineColor)
 }
 
 // RenderLive creates a greyscale map with live position triangles
@@ -916,23 +1004,25 @@ func (r *CompositeRenderer) RenderLive(positions map[string]*LivePosition) *imag
 	// Start with greyscale base
 	img := r.RenderGreyscale()
 
+	// Calculate bounds for coordinate conversion
+	minX, minY, _, _, centerX, centerY := r.CalculateBounds()
+	r.drawActive(img, minX, minY, centerX, centerY)
+	r.drawOverlay(img, minX, minY, centerX, centerY)
+
 	if len(positions) == 0 {
 		return img
 	}
 
-	// Calculate bounds for coordinate conversion
-	minX, minY, _, _, centerX, centerY := r.CalculateBounds()
-
-	// Helper to convert grid coords to image coords
-	toImage := func(p Point) (int, int) {
+	// Helper to convert grid coords to subpixel image coords
+	toImage := func(p Point) (float64, float64) {
 		rp := r.applyGlobalRotation(p, centerX, centerY)
-		x := int((rp.X-minX)*r.Scale) + r.Padding
-		y := int((rp.Y-minY)*r.Scale) + r.Padding
+		x := (rp.X-minX)*r.Scale + float64(r.Padding)
+		y := (rp.Y-minY)*r.Scale + float64(r.Padding)
 		return x, y
 	}
 
 	// Draw position triangles for each vacuum
-	for _, pos := range positions {
+	for id, pos := range positions {
 		// Convert grid position to image coordinates
 		// (positions are stored in grid coords to match map rendering)
 		gridPoint := Point{X: pos.X, Y: pos.Y}
@@ -946,21 +1036,21 @@ func (r *CompositeRenderer) RenderLive(positions map[string]*LivePosition) *imag
 		displayAngle := pos.Angle + r.GlobalRotation
 
 		// Draw vacuum robot icon (size 22 pixels for good visibility)
-		drawVacuumIcon(img, ix, iy, 22, displayAngle, robotColor)
+		if icon := r.Icons[id]; icon != nil {
+			drawMarkerIcon(img, icon, ix, iy, 22, displayAngle, robotColor)
+		} else {
+			drawVacuumIcon(img, ix, iy, 22, displayAngle, robotColor)
+		}
 	}
 
 	// Add legend with vacuum IDs and colors
-	drawLiveLegend(img, positions)
+	r.drawLiveLegend(img, positions)
 
 	return img
 }
 
 // drawLiveLegend adds a legend with vacuum IDs and colors to the live position image
-func drawLiveLegend(img *image.RGBA, positions map[string]*LivePosition) {
-	if len(positions) == 0 {
-		return
-	}
-
+func (r *CompositeRenderer) drawLiveLegend(img *image.RGBA, positions map[string]*LivePosition) {
 	// Sort vacuum IDs for consistent ordering
 	ids := make([]string, 0, len(positions))
 	for id := range positions {
@@ -968,23 +1058,11 @@ func drawLiveLegend(img *image.RGBA, positions map[string]*LivePosition) {
 	}
 	sort.Strings(ids)
 
-	// Legend in top-left corner
-	y := 15
+	entries := make([]legendEntry, 0, len(ids))
 	for _, id := range ids {
-		pos := positions[id]
-		vc := parseHexColor(pos.Color)
-
-		// Draw color swatch (12x12 square)
-		for dy := 0; dy < 12; dy++ {
-			for dx := 0; dx < 12; dx++ {
-				img.Set(10+dx, y+dy-6, vc)
-			}
-		}
-
-		drawText(img, 28, y, id, color.RGBA{0, 0, 0, 255})
-
-		y += 18
+		entries = append(entries, legendEntry{label: r.Legend.Label(id), swatch: parseHexColor(positions[id].Color)})
 	}
+	drawLegendEntries(img, entries, r.Legend, r.Font)
 }
 
 // parseHexColor parses a hex color string like "#FF6B6B" to color.RGBA
@@ -2,6 +2,8 @@ package mesh
 
 import (
 	"image/color"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -30,6 +32,17 @@ func createMockMap(wallPixels []int, floorPixels []int) *ValetudoMap {
 	}
 }
 
+// rectFloor returns a map whose floor fills the cells x0..x1-1, y0..y1-1.
+func rectFloor(x0, y0, x1, y1 int) *ValetudoMap {
+	var pixels []int
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			pixels = append(pixels, x, y)
+		}
+	}
+	return &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: pixels}}}
+}
+
 func TestHasDrawableContent(t *testing.T) {
 	// Case 1: no maps
 	r := &CompositeRenderer{Maps: map[string]*ValetudoMap{}}
@@ -318,3 +331,96 @@ func TestRender_Entities(t *testing.T) {
 		t.Errorf("Expected opaque pixel at robot loc, got alpha %d", c2.A)
 	}
 }
+
+func TestRender_ArbitraryRotationHasNoHoles(t *testing.T) {
+	// A solid 60x60 floor rotated by 37 degrees should stay solid
+	var floor []int
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			floor = append(floor, x, y)
+		}
+	}
+	maps := map[string]*ValetudoMap{"vac1": createMockMap(nil, floor)}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	for _, rot := range []float64{37, -12.5} {
+		renderer := NewCompositeRenderer(maps, transforms, "vac1")
+		renderer.Padding = 5
+		renderer.GlobalRotation = rot
+		renderer.Legend.Hidden = true
+
+		img := renderer.Render()
+		background := color.RGBA{240, 240, 240, 255}
+
+		// Everything within 25px of the image center lies inside the square
+		b := img.Bounds()
+		cx, cy := b.Dx()/2, b.Dy()/2
+		holes := 0
+		for y := cy - 25; y <= cy+25; y++ {
+			for x := cx - 25; x <= cx+25; x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= 25*25 && img.RGBAAt(x, y) == background {
+					holes++
+				}
+			}
+		}
+		if holes > 0 {
+			t.Errorf("rotation %v: %d background pixels inside the floor", rot, holes)
+		}
+	}
+}
+
+func TestRenderRotationComparison_CustomAngles(t *testing.T) {
+	maps := map[string]*ValetudoMap{"ref": rotatedRoom(0), "vac": rotatedRoom(37.5)}
+	prefix := filepath.Join(t.TempDir(), "rotation_vac")
+
+	paths, err := RenderRotationComparison(maps, "vac", prefix, "ref", 0, []float64{0, 37.5, -10})
+	if err != nil {
+		t.Fatalf("RenderRotationComparison: %v", err)
+	}
+	want := []string{prefix + "_0.png", prefix + "_37.5.png", prefix + "_-10.png"}
+	if len(paths) != len(want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	for i, p := range paths {
+		if p != want[i] {
+			t.Errorf("paths[%d] = %s, want %s", i, p, want[i])
+		}
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s not written: %v", p, err)
+		}
+	}
+
+	paths, err = RenderRotationComparison(maps, "vac", prefix, "ref", 0, nil)
+	if err != nil {
+		t.Fatalf("RenderRotationComparison: %v", err)
+	}
+	if len(paths) != 4 || paths[1] != prefix+"_90.png" {
+		t.Errorf("default paths = %v, want the four cardinal rotations", paths)
+	}
+}
+
+func TestPixelCover_CardinalMatchesSinglePixel(t *testing.T) {
+	renderer := &CompositeRenderer{Scale: 1, Padding: 3, GlobalRotation: 90}
+	cover := renderer.pixelCover(0, 0, 10, 10)
+
+	var hits [][2]int
+	cover(Point{X: 4, Y: 7}, func(x, y int) { hits = append(hits, [2]int{x, y}) })
+	if len(hits) != 1 {
+		t.Fatalf("cardinal rotation covered %d pixels, want 1", len(hits))
+	}
+}
+
+func TestPixelCover_DownscaledKeepsEveryCell(t *testing.T) {
+	renderer := &CompositeRenderer{Scale: 0.25, Padding: 3, GlobalRotation: 30}
+	cover := renderer.pixelCover(0, 0, 50, 50)
+
+	// A one-cell-thick wall: at a quarter scale most cells cover no pixel
+	// center but each must still draw something
+	for x := 0; x < 100; x++ {
+		hits := 0
+		cover(Point{X: float64(x), Y: 40}, func(int, int) { hits++ })
+		if hits == 0 {
+			t.Fatalf("cell (%d,40) covered no pixel", x)
+		}
+	}
+}
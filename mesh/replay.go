@@ -0,0 +1,186 @@
+package mesh
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// maxRecordedLine bounds one line of a recording; map payloads can be
+// several megabytes once base64 encoded.
+const maxRecordedLine = 64 << 20
+
+// RecordedMessage is one line of an MQTT recording (JSON Lines). Payload is
+// base64 encoded in the file so PNG map payloads survive intact.
+type RecordedMessage struct {
+	Time     time.Time `json:"time"`
+	Topic    string    `json:"topic"`
+	VacuumID string    `json:"vacuumId,omitempty"` // informational; replay routes by topic
+	Payload  []byte    `json:"payload"`
+}
+
+// ReplayClient is an MQTTClientInterface that delivers recorded messages
+// instead of talking to a broker. Subscriptions behave as on a broker,
+// including + and # wildcards; published messages are counted and dropped.
+type ReplayClient struct {
+	mu        sync.RWMutex
+	handlers  map[string]mqtt.MessageHandler // keyed by topic filter
+	published int
+}
+
+// NewReplayClient creates a replay client with no subscriptions.
+func NewReplayClient() *ReplayClient {
+	return &ReplayClient{handlers: make(map[string]mqtt.MessageHandler)}
+}
+
+// NewReplayMQTT returns an MQTTClient driven by client, subscribed to the
+// configured vacuum topics as if it had just connected to a broker.
+func NewReplayMQTT(config *Config, handler MessageHandler, client *ReplayClient) *MQTTClient {
+	c := newMQTTClientWithMock(client, config, handler)
+	c.vacuums = config.Vacuums // one recording covers every broker
+	c.onConnect(client)
+	return c
+}
+
+func (r *ReplayClient) Connect() mqtt.Token { return replayToken{} }
+func (r *ReplayClient) Disconnect(uint)     {}
+func (r *ReplayClient) IsConnected() bool   { return true }
+
+func (r *ReplayClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.published++
+	return replayToken{}
+}
+
+func (r *ReplayClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[topic] = callback
+	return replayToken{}
+}
+
+// Published returns the number of messages the pipeline has published.
+func (r *ReplayClient) Published() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.published
+}
+
+// Deliver hands a message to every subscription whose filter matches its
+// topic and reports whether any did.
+func (r *ReplayClient) Deliver(msg RecordedMessage) bool {
+	r.mu.RLock()
+	var matched []mqtt.MessageHandler
+	for filter, h := range r.handlers {
+		if topicMatches(filter, msg.Topic) {
+			matched = append(matched, h)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, h := range matched {
+		h(nil, &mockMessage{topic: msg.Topic, payload: msg.Payload})
+	}
+	return len(matched) > 0
+}
+
+// Play reads a recording from src, plain or gzip compressed, and delivers
+// its messages in order. With speed 1 the original gaps between messages are
+// kept, 10 plays ten times faster and 0 plays without waiting. Messages on
+// topics nobody subscribes to are skipped. It returns the number of messages
+// delivered.
+func (r *ReplayClient) Play(ctx context.Context, src io.Reader, speed float64) (int, error) {
+	buffered := bufio.NewReader(src)
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return 0, fmt.Errorf("opening compressed recording: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		src = gz
+	} else {
+		src = buffered
+	}
+
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordedLine)
+
+	delivered, line := 0, 0
+	var last time.Time
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var msg RecordedMessage
+		if err := json.Unmarshal([]byte(text), &msg); err != nil {
+			return delivered, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if speed > 0 && !last.IsZero() && msg.Time.After(last) {
+			wait := time.Duration(float64(msg.Time.Sub(last)) / speed)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return delivered, ctx.Err()
+			}
+		}
+		if !msg.Time.IsZero() {
+			last = msg.Time
+		}
+		if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+
+		if r.Deliver(msg) {
+			delivered++
+		} else {
+			log.Printf("[REPLAY] line %d: no subscription for %s, skipping", line, msg.Topic)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return delivered, fmt.Errorf("line %d: %w", line+1, err)
+	}
+	return delivered, nil
+}
+
+// topicMatches reports whether an MQTT topic filter matches topic.
+func topicMatches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// replayToken is an mqtt.Token that has already completed successfully.
+type replayToken struct{}
+
+func (replayToken) Wait() bool                     { return true }
+func (replayToken) WaitTimeout(time.Duration) bool { return true }
+func (replayToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+func (replayToken) Error() error { return nil }
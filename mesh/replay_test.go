@@ -0,0 +1,115 @@
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// recording encodes messages as a JSON Lines recording.
+func recording(t *testing.T, msgs ...RecordedMessage) string {
+	t.Helper()
+	var b strings.Builder
+	for _, m := range msgs {
+		line, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"valetudo/a/MapData/map-data", "valetudo/a/MapData/map-data", true},
+		{"valetudo/a/MapData/map-data", "valetudo/b/MapData/map-data", false},
+		{"valetudo/+/MapData/map-data", "valetudo/b/MapData/map-data", true},
+		{"tudomesh/cluster/#", "tudomesh/cluster/leader", true},
+		{"tudomesh/#", "tudomesh", true}, // # also matches the parent level
+		{"valetudo/+", "valetudo/a/b", false},
+		{"valetudo/a/b", "valetudo/a", false},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestReplay_DrivesMessageAndDockingHandlers(t *testing.T) {
+	config := &Config{Vacuums: []VacuumConfig{{ID: "rocky", Topic: "valetudo/rocky/MapData/map-data"}}}
+	var maps []*ValetudoMap
+	docked := ""
+
+	client := NewReplayClient()
+	mqttClient := NewReplayMQTT(config, func(id string, raw []byte, m *ValetudoMap, err error) {
+		if err != nil {
+			t.Errorf("handler error for %s: %v", id, err)
+			return
+		}
+		maps = append(maps, m)
+	}, client)
+	mqttClient.SetDockingHandler(func(id string) { docked = id })
+
+	rec := recording(t,
+		RecordedMessage{Topic: "valetudo/rocky/MapData/map-data", Payload: []byte(`{"pixelSize": 5, "layers": [], "entities": []}`)},
+		RecordedMessage{Topic: "valetudo/other/MapData/map-data", Payload: []byte(`{}`)},
+		RecordedMessage{Topic: "valetudo/rocky/StatusStateAttribute/status", Payload: []byte(`{"value":"docked"}`)},
+	)
+	n, err := client.Play(context.Background(), strings.NewReader(rec), 0)
+	if err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("delivered %d messages, want 2 (one topic is not subscribed)", n)
+	}
+	if len(maps) != 1 || maps[0].PixelSize != 5 {
+		t.Errorf("maps = %+v, want one map with pixelSize 5", maps)
+	}
+	if docked != "rocky" {
+		t.Errorf("docked = %q, want rocky", docked)
+	}
+
+	NewPublisher(client).PublishPosition("rocky", 1, 2, 0)
+	if client.Published() == 0 {
+		t.Error("Published() did not count the position message")
+	}
+}
+
+func TestReplay_Timing(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := recording(t,
+		RecordedMessage{Time: start, Topic: "a"},
+		RecordedMessage{Time: start.Add(2 * time.Second), Topic: "a"},
+	)
+
+	client := NewReplayClient()
+	client.Subscribe("a", 0, func(mqtt.Client, mqtt.Message) {})
+
+	began := time.Now()
+	if _, err := client.Play(context.Background(), strings.NewReader(rec), 40); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if elapsed := time.Since(began); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("2s at speed 40 took %v, want about 50ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Play(ctx, strings.NewReader(rec), 1); err != context.Canceled {
+		t.Errorf("cancelled Play = %v, want context.Canceled", err)
+	}
+
+	if _, err := client.Play(context.Background(), strings.NewReader("{not json\n"), 0); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("bad line = %v, want error naming line 1", err)
+	}
+}
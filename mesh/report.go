@@ -0,0 +1,304 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AlignmentReport collects everything needed to judge how well the vacuums
+// were aligned to the reference: overlays before and after ICP, the ICP
+// metrics, the rotation candidates and the wall angle histograms.
+type AlignmentReport struct {
+	Reference      string
+	Generated      time.Time
+	ReferenceWalls WallAngleHistogram
+	Vacuums        []VacuumAlignmentReport // sorted by vacuum ID
+	Coverage       CoverageStats
+	Composite      []byte // PNG of every map aligned
+}
+
+// VacuumAlignmentReport is the alignment of one vacuum onto the reference.
+type VacuumAlignmentReport struct {
+	VacuumID       string
+	ICP            ICPResult
+	Valid          bool                // ValidateAlignment accepted the transform
+	Rotation       float64             // final rotation in degrees, 0-360
+	RotationErrors map[float64]float64 // ICP error of each initial rotation tried
+	Detection      RotationAnalysis    // wall feature rotation scores
+	Walls          WallAngleHistogram
+	Before         []byte // PNG of the raw map over the reference
+	After          []byte // PNG of the aligned map over the reference
+}
+
+// BuildAlignmentReport aligns every map onto the reference with config and
+// renders the overlays. globalRotation rotates every image like --rotate-all.
+func BuildAlignmentReport(maps map[string]*ValetudoMap, reference string, globalRotation float64, config ICPConfig) (*AlignmentReport, error) {
+	refMap, ok := maps[reference]
+	if !ok {
+		return nil, fmt.Errorf("reference vacuum %q not found", reference)
+	}
+
+	report := &AlignmentReport{
+		Reference:      reference,
+		Generated:      time.Now(),
+		ReferenceWalls: ExtractWallAngles(refMap),
+	}
+
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		if id != reference {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	transforms := map[string]AffineMatrix{reference: Identity()}
+	for _, id := range ids {
+		m := maps[id]
+		result := AlignMaps(m, refMap, config)
+		transforms[id] = result.Transform
+
+		rotation := TransformRotation(result.Transform)
+		rotationErrors := make(map[float64]float64, len(RotationErrors))
+		for rot, e := range RotationErrors {
+			rotationErrors[rot] = e
+		}
+
+		pair := map[string]*ValetudoMap{reference: refMap, id: m}
+		before, err := renderReportPNG(pair, map[string]AffineMatrix{reference: Identity(), id: Identity()}, reference, globalRotation)
+		if err != nil {
+			return nil, err
+		}
+		after, err := renderReportPNG(pair, map[string]AffineMatrix{reference: Identity(), id: result.Transform}, reference, globalRotation)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Vacuums = append(report.Vacuums, VacuumAlignmentReport{
+			VacuumID:       id,
+			ICP:            result,
+			Valid:          ValidateAlignment(result.Transform),
+			Rotation:       rotation,
+			RotationErrors: rotationErrors,
+			Detection:      DetectRotationWithFeatures(m, refMap),
+			Walls:          ExtractWallAngles(m),
+			Before:         before,
+			After:          after,
+		})
+	}
+
+	report.Coverage = ComputeCoverageStats(maps, transforms, reference)
+	composite, err := renderReportPNG(maps, transforms, reference, globalRotation)
+	if err != nil {
+		return nil, err
+	}
+	report.Composite = composite
+	return report, nil
+}
+
+// renderReportPNG renders maps with transforms and encodes the image.
+func renderReportPNG(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference string, globalRotation float64) ([]byte, error) {
+	renderer := NewCompositeRenderer(maps, transforms, reference)
+	renderer.GlobalRotation = globalRotation
+	return encodeReportPNG(renderer.Render())
+}
+
+// encodeReportPNG encodes img as PNG.
+func encodeReportPNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encoding report image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pngDataURL embeds a PNG in the page so the report is a single file.
+func pngDataURL(data []byte) template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// histogramHeight is the height of the wall angle chart in SVG user units.
+// The chart is one unit wide per degree bin.
+const histogramHeight = 60
+
+// histogramPolyline returns SVG polyline points for h, scaled so the
+// highest bin in either histogram reaches the top of the chart.
+func histogramPolyline(h WallAngleHistogram, peak float64) string {
+	if peak <= 0 {
+		peak = 1
+	}
+	var b strings.Builder
+	for i, v := range h.Bins {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%d,%.2f", i, histogramHeight-v/peak*histogramHeight)
+	}
+	return b.String()
+}
+
+// histogramPeak returns the highest bin of the histograms.
+func histogramPeak(hs ...WallAngleHistogram) float64 {
+	peak := 0.0
+	for _, h := range hs {
+		for _, v := range h.Bins {
+			peak = max(peak, v)
+		}
+	}
+	return peak
+}
+
+var alignmentReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"f1":  func(f float64) string { return fmt.Sprintf("%.1f", f) },
+	"f2":  func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"f4":  func(f float64) string { return fmt.Sprintf("%.4f", f) },
+	"deg": func(f float64) string { return fmt.Sprintf("%g°", f) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Alignment report</title>
+<style>
+body { font-family: sans-serif; margin: 1.5em; }
+.pair { display: flex; flex-wrap: wrap; gap: 1em; }
+figure { margin: 0; border: 1px solid #ccc; padding: 0.5em; }
+figure img { max-width: 480px; display: block; }
+figcaption { text-align: center; margin-top: 0.3em; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+th { background: #f0f0f0; }
+td.best { font-weight: bold; }
+.bad { color: #b00; }
+svg.hist { width: 540px; height: 180px; border: 1px solid #ccc; }
+.legend span { display: inline-block; width: 1em; height: 0.3em; margin: 0 0.3em 0.15em 1em; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Alignment report</h1>
+<p>Reference vacuum <b>{{.Reference}}</b> &middot; generated {{.Generated}}</p>
+<p>Coverage: {{f2 .Coverage.TotalArea}} m² total, {{pct .Coverage.CoverageOverlap}} seen by more than one vacuum</p>
+{{range .Vacuums}}
+<h2 id="{{.ID}}">{{.ID}}</h2>
+<div class="pair">
+<figure><img src="{{.Before}}" alt="{{.ID}} before alignment"><figcaption>Before</figcaption></figure>
+<figure><img src="{{.After}}" alt="{{.ID}} after alignment"><figcaption>After</figcaption></figure>
+</div>
+<h3>ICP</h3>
+<table>
+<tr><th>Iterations</th><th>Error</th><th>Score</th><th>Inliers</th><th>Converged</th><th>Valid</th><th>Initial rotation</th><th>Final rotation</th><th>Translation</th></tr>
+<tr><td>{{.ICP.Iterations}}</td><td>{{f2 .ICP.Error}}</td><td>{{f4 .ICP.Score}}</td><td>{{pct .ICP.InlierFraction}}</td><td>{{.ICP.Converged}}</td><td{{if not .Valid}} class="bad"{{end}}>{{.Valid}}</td><td>{{deg .ICP.InitialRotation}}</td><td>{{f1 .Rotation}}°</td><td>({{f1 .ICP.Transform.Tx}}, {{f1 .ICP.Transform.Ty}})</td></tr>
+</table>
+<h3>Rotation candidates</h3>
+<table>
+<tr><th>Rotation</th><th>ICP error</th><th>Wall feature score</th></tr>
+{{- range .Candidates}}
+<tr><td>{{deg .Rotation}}</td><td{{if .BestICP}} class="best"{{end}}>{{if .HasError}}{{f2 .Error}}{{else}}&ndash;{{end}}</td><td{{if .BestDetection}} class="best"{{end}}>{{if .HasScore}}{{f4 .Score}}{{else}}&ndash;{{end}}</td></tr>
+{{- end}}
+</table>
+<p>Wall features suggest {{deg .DetectedRotation}} ({{pct .Confidence}} confidence).</p>
+<h3>Wall angles</h3>
+<svg class="hist" viewBox="0 0 180 60" preserveAspectRatio="none">
+<polyline points="{{.ReferenceWalls}}" fill="none" stroke="#2a6fdb" stroke-width="0.6" vector-effect="non-scaling-stroke"/>
+<polyline points="{{.Walls}}" fill="none" stroke="#e07b00" stroke-width="0.6" vector-effect="non-scaling-stroke"/>
+</svg>
+<p class="legend"><span style="background:#2a6fdb"></span>{{$.Reference}} ({{.ReferenceEdges}} edges)<span style="background:#e07b00"></span>{{.ID}} ({{.Edges}} edges) &middot; 0°&ndash;179°</p>
+{{end}}
+<h2>Composite</h2>
+<figure><img src="{{.Composite}}" alt="Composite of all vacuums"><figcaption>All vacuums aligned</figcaption></figure>
+</body>
+</html>
+`))
+
+// WriteAlignmentReport writes the report as a standalone HTML page with the
+// images embedded.
+func WriteAlignmentReport(w io.Writer, report *AlignmentReport) error {
+	type candidate struct {
+		Rotation               float64
+		Error, Score           float64
+		HasError, HasScore     bool
+		BestICP, BestDetection bool
+	}
+	type vacuum struct {
+		ID                    string
+		Before, After         template.URL
+		ICP                   ICPResult
+		Valid                 bool
+		Rotation              float64
+		Candidates            []candidate
+		DetectedRotation      float64
+		Confidence            float64
+		ReferenceWalls, Walls string
+		ReferenceEdges, Edges int
+	}
+	data := struct {
+		Reference string
+		Generated string
+		Coverage  CoverageStats
+		Vacuums   []vacuum
+		Composite template.URL
+	}{
+		Reference: report.Reference,
+		Generated: report.Generated.Format(time.RFC1123),
+		Coverage:  report.Coverage,
+		Composite: pngDataURL(report.Composite),
+	}
+
+	for _, v := range report.Vacuums {
+		peak := histogramPeak(report.ReferenceWalls, v.Walls)
+		view := vacuum{
+			ID:               v.VacuumID,
+			Before:           pngDataURL(v.Before),
+			After:            pngDataURL(v.After),
+			ICP:              v.ICP,
+			Valid:            v.Valid,
+			Rotation:         v.Rotation,
+			DetectedRotation: v.Detection.BestRotation,
+			Confidence:       v.Detection.Confidence,
+			ReferenceWalls:   histogramPolyline(report.ReferenceWalls, peak),
+			Walls:            histogramPolyline(v.Walls, peak),
+			ReferenceEdges:   report.ReferenceWalls.TotalEdges,
+			Edges:            v.Walls.TotalEdges,
+		}
+
+		// One row per rotation either method tried, lowest ICP error and
+		// highest feature score highlighted
+		rotations := make(map[float64]bool)
+		for rot := range v.RotationErrors {
+			rotations[rot] = true
+		}
+		for rot := range v.Detection.Scores {
+			rotations[rot] = true
+		}
+		bestError, bestScore := math.Inf(1), math.Inf(-1)
+		for rot := range rotations {
+			if e, ok := v.RotationErrors[rot]; ok {
+				bestError = min(bestError, e)
+			}
+			if s, ok := v.Detection.Scores[rot]; ok {
+				bestScore = max(bestScore, s)
+			}
+		}
+		for rot := range rotations {
+			c := candidate{Rotation: rot}
+			c.Error, c.HasError = v.RotationErrors[rot]
+			c.Score, c.HasScore = v.Detection.Scores[rot]
+			c.BestICP = c.HasError && c.Error == bestError
+			c.BestDetection = c.HasScore && c.Score == bestScore
+			view.Candidates = append(view.Candidates, c)
+		}
+		sort.Slice(view.Candidates, func(i, j int) bool { return view.Candidates[i].Rotation < view.Candidates[j].Rotation })
+
+		data.Vacuums = append(data.Vacuums, view)
+	}
+	return alignmentReportTemplate.Execute(w, data)
+}
@@ -0,0 +1,93 @@
+package mesh
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Alignment report
+// ---------------------------------------------------------------------------
+
+func TestBuildAlignmentReport(t *testing.T) {
+	maps := map[string]*ValetudoMap{
+		"ref":   rotatedRoom(0),
+		"other": rotatedRoom(90),
+	}
+	config := DefaultICPConfig()
+	config.RNG = rand.New(rand.NewSource(1))
+
+	report, err := BuildAlignmentReport(maps, "ref", 0, config)
+	if err != nil {
+		t.Fatalf("BuildAlignmentReport: %v", err)
+	}
+	if report.Reference != "ref" || len(report.Vacuums) != 1 {
+		t.Fatalf("report = %s with %d vacuums, want ref with 1", report.Reference, len(report.Vacuums))
+	}
+	v := report.Vacuums[0]
+	if v.VacuumID != "other" {
+		t.Errorf("VacuumID = %q, want other", v.VacuumID)
+	}
+	if len(v.Before) == 0 || len(v.After) == 0 || len(report.Composite) == 0 {
+		t.Error("report images should be rendered")
+	}
+	if len(v.Detection.Scores) == 0 {
+		t.Error("rotation candidates should be scored")
+	}
+	if v.Walls.TotalEdges == 0 || report.ReferenceWalls.TotalEdges == 0 {
+		t.Error("wall angle histograms should be filled")
+	}
+}
+
+func TestBuildAlignmentReport_UnknownReference(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": rotatedRoom(0)}
+	if _, err := BuildAlignmentReport(maps, "missing", 0, DefaultICPConfig()); err == nil {
+		t.Error("expected an error for a missing reference")
+	}
+}
+
+func TestWriteAlignmentReport(t *testing.T) {
+	report := &AlignmentReport{
+		Reference: "ref",
+		Vacuums: []VacuumAlignmentReport{{
+			VacuumID:       "<vac>",
+			ICP:            ICPResult{Iterations: 12, Error: 1.5, InlierFraction: 0.75},
+			Valid:          true,
+			Rotation:       90,
+			RotationErrors: map[float64]float64{0: 9.5, 90: 1.5},
+			Detection:      RotationAnalysis{BestRotation: 90, Scores: map[float64]float64{0: 0.2, 90: 0.9}},
+			Before:         []byte("before"),
+			After:          []byte("after"),
+		}},
+		Composite: []byte("composite"),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAlignmentReport(&buf, report); err != nil {
+		t.Fatalf("WriteAlignmentReport: %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"<b>ref</b>",
+		"&lt;vac&gt;",
+		"<td>12</td>",
+		"75.0%",
+		`<td class="best">1.50</td>`,
+		`<td class="best">0.9000</td>`,
+		`src="data:image/png;base64,`,
+		"<polyline points=",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<vac>") {
+		t.Error("vacuum ID should be HTML-escaped")
+	}
+	if strings.Contains(html, "ZgotmplZ") {
+		t.Error("embedded images were rejected by the template")
+	}
+}
@@ -0,0 +1,132 @@
+package mesh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPruneInterval is how often the service cleans up the data directory
+// when retention.interval is not set.
+const DefaultPruneInterval = time.Hour
+
+// RetentionPolicy decides which cached files in the data directory are
+// removed. Zero values disable the corresponding limit.
+type RetentionPolicy struct {
+	MaxFiles int           // files kept per vacuum and kind
+	MaxAge   time.Duration // files last modified longer ago are removed
+}
+
+// RetentionPolicyFromConfig returns the retention limits from config. A nil
+// config yields a policy that keeps everything.
+func RetentionPolicyFromConfig(config *Config) RetentionPolicy {
+	if config == nil {
+		return RetentionPolicy{}
+	}
+	p := RetentionPolicy{MaxFiles: config.Retention.MaxFiles}
+	if d, err := config.Retention.Age(); err == nil {
+		p.MaxAge = d
+	}
+	return p
+}
+
+// Enabled reports whether the policy removes anything at all.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxFiles > 0 || p.MaxAge > 0
+}
+
+// PrunedFile describes a file removed by PruneDataDir.
+type PrunedFile struct {
+	Path     string
+	VacuumID string
+	Size     int64
+}
+
+// retainedFile is a candidate for pruning.
+type retainedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// PruneDataDir removes map exports (ValetudoMapExport-*.json and .json.gz)
+// and raw PNGs ({vacuumID}.png, {vacuumID}-{timestamp}.png) from dir that
+// fall outside the policy. Files are grouped per vacuum and kind, and the
+// newest file of each group is always kept so a vacuum's floorplan survives
+// however old it is. Raw PNGs are only considered for the given vacuum IDs,
+// so renders and other images are never touched.
+func PruneDataDir(dir string, vacuumIDs []string, policy RetentionPolicy, now time.Time) ([]PrunedFile, error) {
+	if !policy.Enabled() {
+		return nil, nil
+	}
+
+	groups := make(map[string][]retainedFile) // "kind/vacuumID" -> files
+	add := func(kind, vacuumID, path string) {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		key := kind + "/" + vacuumID
+		groups[key] = append(groups[key], retainedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	files, err := listDir(dir, mapExportPrefix+"*.json", mapExportPrefix+"*.json.gz")
+	if err != nil {
+		return nil, fmt.Errorf("listing map exports: %w", err)
+	}
+	for _, f := range files {
+		if id, ok := VacuumIDFromExportFilename(f); ok {
+			add("export", id, f)
+		}
+	}
+
+	known := make(map[string]bool, len(vacuumIDs))
+	for _, id := range vacuumIDs {
+		known[id] = true
+	}
+	pngs, err := listDir(dir, "*.png")
+	if err != nil {
+		return nil, fmt.Errorf("listing raw PNGs: %w", err)
+	}
+	for _, f := range pngs {
+		id := exportTimestamp.ReplaceAllString(strings.TrimSuffix(filepath.Base(f), ".png"), "")
+		if known[id] {
+			add("png", id, f)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pruned []PrunedFile
+	for _, key := range keys {
+		files := groups[key]
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+		_, vacuumID, _ := strings.Cut(key, "/")
+
+		for i, f := range files {
+			if i == 0 {
+				continue // newest is always kept
+			}
+			tooMany := policy.MaxFiles > 0 && i >= policy.MaxFiles
+			tooOld := policy.MaxAge > 0 && now.Sub(f.modTime) > policy.MaxAge
+			if !tooMany && !tooOld {
+				continue
+			}
+			if err := os.Remove(f.path); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return pruned, fmt.Errorf("removing %s: %w", f.path, err)
+			}
+			pruned = append(pruned, PrunedFile{Path: f.path, VacuumID: vacuumID, Size: f.size})
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,138 @@
+package mesh
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// writeAged creates name in dir with a modification time age before now.
+func writeAged(t *testing.T, dir, name string, now time.Time, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	mt := now.Add(-age)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatalf("chtimes %s: %v", name, err)
+	}
+}
+
+func remainingFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// PruneDataDir
+// ---------------------------------------------------------------------------
+
+func TestPruneDataDir_MaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeAged(t, dir, "ValetudoMapExport-v1.json", now, 0)
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-03.json", now, time.Hour)
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-02.json", now, 2*time.Hour)
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-01.json.gz", now, 3*time.Hour)
+	writeAged(t, dir, "ValetudoMapExport-v2-2024-01-01.json", now, 5*time.Hour)
+
+	pruned, err := PruneDataDir(dir, nil, RetentionPolicy{MaxFiles: 2}, now)
+	if err != nil {
+		t.Fatalf("PruneDataDir: %v", err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("pruned %d files, want 2: %+v", len(pruned), pruned)
+	}
+	for _, p := range pruned {
+		if p.VacuumID != "v1" || p.Size != 1 {
+			t.Errorf("unexpected pruned file %+v", p)
+		}
+	}
+
+	want := []string{
+		"ValetudoMapExport-v1-2024-01-03.json",
+		"ValetudoMapExport-v1.json",
+		"ValetudoMapExport-v2-2024-01-01.json",
+	}
+	if got := remainingFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining = %v, want %v", got, want)
+	}
+}
+
+func TestPruneDataDir_MaxAgeKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-02.json", now, 48*time.Hour)
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-01.json", now, 72*time.Hour)
+	writeAged(t, dir, "ValetudoMapExport-v2.json", now, time.Hour)
+
+	pruned, err := PruneDataDir(dir, nil, RetentionPolicy{MaxAge: 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("PruneDataDir: %v", err)
+	}
+	if len(pruned) != 1 {
+		t.Fatalf("pruned %d files, want 1: %+v", len(pruned), pruned)
+	}
+
+	// v1's newest export is past maxAge but still kept as its floorplan
+	want := []string{"ValetudoMapExport-v1-2024-01-02.json", "ValetudoMapExport-v2.json"}
+	if got := remainingFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining = %v, want %v", got, want)
+	}
+}
+
+func TestPruneDataDir_RawPNGs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeAged(t, dir, "v1.png", now, 0)
+	writeAged(t, dir, "v1-20240101.png", now, time.Hour)
+	writeAged(t, dir, "composite-map.png", now, 2*time.Hour)
+	writeAged(t, dir, "composite-map-20240101.png", now, 3*time.Hour)
+
+	pruned, err := PruneDataDir(dir, []string{"v1"}, RetentionPolicy{MaxFiles: 1}, now)
+	if err != nil {
+		t.Fatalf("PruneDataDir: %v", err)
+	}
+	if len(pruned) != 1 || filepath.Base(pruned[0].Path) != "v1-20240101.png" {
+		t.Fatalf("pruned = %+v, want only v1-20240101.png", pruned)
+	}
+}
+
+func TestPruneDataDir_Disabled(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeAged(t, dir, "ValetudoMapExport-v1.json", now, 0)
+	writeAged(t, dir, "ValetudoMapExport-v1-2024-01-01.json", now, 1000*time.Hour)
+
+	pruned, err := PruneDataDir(dir, nil, RetentionPolicy{}, now)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("disabled policy pruned %v, err %v", pruned, err)
+	}
+}
+
+func TestRetentionPolicyFromConfig(t *testing.T) {
+	if RetentionPolicyFromConfig(nil).Enabled() {
+		t.Error("nil config should keep everything")
+	}
+	p := RetentionPolicyFromConfig(&Config{Retention: RetentionConfig{MaxFiles: 3, MaxAge: "720h"}})
+	if p.MaxFiles != 3 || p.MaxAge != 720*time.Hour || !p.Enabled() {
+		t.Errorf("policy = %+v", p)
+	}
+}
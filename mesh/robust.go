@@ -0,0 +1,70 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+)
+
+// RobustLoss selects the kernel that weights ICP correspondences by their
+// residual, so spurious wall pixels (common on gyro-navigation maps) pull
+// less on the alignment than well-matched walls.
+type RobustLoss string
+
+const (
+	LossNone   RobustLoss = "none"   // every correspondence counts fully
+	LossHuber  RobustLoss = "huber"  // full weight within the scale, then 1/r
+	LossCauchy RobustLoss = "cauchy" // 1/(1+r²); smooth, never zero
+	LossTukey  RobustLoss = "tukey"  // (1-r²)² within the scale, zero beyond
+)
+
+// DefaultLossScale is the robust kernel scale in millimeters: residuals up
+// to about this size are treated as ordinary noise.
+const DefaultLossScale = 50.0
+
+// ParseRobustLoss parses a kernel name; the empty string means LossNone.
+func ParseRobustLoss(s string) (RobustLoss, error) {
+	switch loss := RobustLoss(s); loss {
+	case "":
+		return LossNone, nil
+	case LossNone, LossHuber, LossCauchy, LossTukey:
+		return loss, nil
+	}
+	return "", fmt.Errorf("unknown robust loss %q (must be none, huber, cauchy, or tukey)", s)
+}
+
+// RobustWeight returns the IRLS weight (0-1) of a correspondence with the
+// given residual under loss; residual and scale share units.
+func RobustWeight(loss RobustLoss, residual, scale float64) float64 {
+	if scale <= 0 {
+		return 1
+	}
+	r := math.Abs(residual) / scale
+	switch loss {
+	case LossHuber:
+		if r <= 1 {
+			return 1
+		}
+		return 1 / r
+	case LossCauchy:
+		return 1 / (1 + r*r)
+	case LossTukey:
+		if r >= 1 {
+			return 0
+		}
+		return (1 - r*r) * (1 - r*r)
+	}
+	return 1
+}
+
+// robustWeights returns the weight of each residual, or nil when every
+// correspondence counts fully.
+func robustWeights(residuals []float64, loss RobustLoss, scale float64) []float64 {
+	if loss == "" || loss == LossNone || scale <= 0 {
+		return nil
+	}
+	weights := make([]float64, len(residuals))
+	for i, d := range residuals {
+		weights[i] = RobustWeight(loss, d, scale)
+	}
+	return weights
+}
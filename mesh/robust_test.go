@@ -0,0 +1,103 @@
+package mesh
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Robust kernels
+// ---------------------------------------------------------------------------
+
+func TestRobustWeight(t *testing.T) {
+	tests := []struct {
+		loss     RobustLoss
+		residual float64
+		want     float64
+	}{
+		{LossNone, 100, 1},
+		{LossHuber, 5, 1},
+		{LossHuber, 10, 1},
+		{LossHuber, 40, 0.25},
+		{LossCauchy, 0, 1},
+		{LossCauchy, 10, 0.5},
+		{LossCauchy, -10, 0.5},
+		{LossTukey, 0, 1},
+		{LossTukey, 5, 0.5625},
+		{LossTukey, 10, 0},
+		{LossTukey, 30, 0},
+	}
+	for _, tt := range tests {
+		if got := RobustWeight(tt.loss, tt.residual, 10); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("RobustWeight(%s, %g, 10) = %g, want %g", tt.loss, tt.residual, got, tt.want)
+		}
+	}
+	if got := RobustWeight(LossTukey, 50, 0); got != 1 {
+		t.Errorf("zero scale: got %g, want 1", got)
+	}
+}
+
+func TestParseRobustLoss(t *testing.T) {
+	for in, want := range map[string]RobustLoss{"": LossNone, "none": LossNone, "huber": LossHuber, "cauchy": LossCauchy, "tukey": LossTukey} {
+		got, err := ParseRobustLoss(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRobustLoss(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseRobustLoss("l1"); err == nil {
+		t.Error("expected error for unknown loss")
+	}
+}
+
+func TestRobustWeights_None(t *testing.T) {
+	if w := robustWeights([]float64{1, 2}, LossNone, 10); w != nil {
+		t.Errorf("LossNone weights = %v, want nil", w)
+	}
+	if w := robustWeights([]float64{1, 2}, LossHuber, 0); w != nil {
+		t.Errorf("zero scale weights = %v, want nil", w)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ICP with robust loss
+// ---------------------------------------------------------------------------
+
+func TestRunICP_RobustLossIgnoresClutter(t *testing.T) {
+	// Walls plus a cloud of spurious wall pixels on one side of the room,
+	// as gyro robots leave around furniture
+	walls := roomWallPoints()
+	rng := rand.New(rand.NewSource(7))
+	source := append([]Point(nil), walls...)
+	for i := 0; i < len(walls)/2; i++ {
+		source = append(source, Point{X: 230 + rng.Float64()*60, Y: 300 + rng.Float64()*200})
+	}
+	truth := MultiplyMatrices(Translation(406, 403), MultiplyMatrices(RotationDeg(1), Translation(-400, -400)))
+	target := TransformPoints(walls, truth)
+
+	run := func(loss RobustLoss) float64 {
+		config := DefaultICPConfig()
+		config.Loss = loss
+		config.MaxCorrespondDist = 60
+		config.OutlierPercentile = 1
+		config.ConvergenceThresh = 0.01
+		result := runICP(source, target, Identity(), config)
+
+		worst := 0.0
+		moved, want := TransformPoints(walls, result.Transform), target
+		for i := range moved {
+			worst = math.Max(worst, Distance(moved[i], want[i]))
+		}
+		return worst
+	}
+
+	plain := run(LossNone)
+	for _, loss := range []RobustLoss{LossHuber, LossCauchy, LossTukey} {
+		if robust := run(loss); robust >= plain {
+			t.Errorf("%s wall error %.2f px, want below unweighted %.2f px", loss, robust, plain)
+		}
+	}
+	if robust := run(LossTukey); robust > 2 {
+		t.Errorf("tukey wall error %.2f px, want < 2", robust)
+	}
+}
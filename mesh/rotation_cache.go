@@ -0,0 +1,25 @@
+package mesh
+
+// AlignMapsReusingRotation aligns source onto target like AlignMaps, but
+// when cal holds a rotation for vacuumID found on maps with the same
+// content it starts ICP from that rotation instead of sweeping all four.
+// A reused rotation that no longer aligns well falls back to the sweep, as
+// does an ambiguous one, so its alternatives are found again.
+// It returns the rotation to store with the vacuum's calibration and
+// whether the cached one was reused.
+func AlignMapsReusingRotation(vacuumID string, source, target *ValetudoMap, config ICPConfig, cal *CalibrationData) (ICPResult, *CachedRotation, bool) {
+	rotation := &CachedRotation{SourceHash: MapContentHash(source), TargetHash: MapContentHash(target)}
+
+	if vc := cal.GetVacuumCalibration(vacuumID); vc != nil && vc.Rotation != nil && len(vc.Alternatives) == 0 &&
+		vc.Rotation.SourceHash == rotation.SourceHash && vc.Rotation.TargetHash == rotation.TargetHash {
+		result := AlignMapsWithRotationHint(source, target, config, vc.Rotation.Rotation)
+		if result.Score >= preAlignMinScore {
+			rotation.Rotation = vc.Rotation.Rotation
+			return result, rotation, true
+		}
+	}
+
+	result := AlignMaps(source, target, config)
+	rotation.Rotation = result.InitialRotation
+	return result, rotation, false
+}
@@ -0,0 +1,47 @@
+package mesh
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAlignMapsReusingRotation(t *testing.T) {
+	ref, vac := rotatedRoom(0), rotatedRoom(90)
+	config := DefaultICPConfig()
+
+	result, rotation, reused := AlignMapsReusingRotation("vac", vac, ref, config, nil)
+	if reused {
+		t.Fatal("reused a rotation without a cache")
+	}
+	if rotation.SourceHash != MapContentHash(vac) || rotation.TargetHash != MapContentHash(ref) {
+		t.Errorf("rotation hashes %+v do not match the maps", rotation)
+	}
+	if rotation.Rotation != result.InitialRotation {
+		t.Errorf("cached rotation = %v, want the sweep's %v", rotation.Rotation, result.InitialRotation)
+	}
+
+	// Survives the cache file
+	path := filepath.Join(t.TempDir(), "calibration.json")
+	cal := &CalibrationData{ReferenceVacuum: "ref"}
+	cal.UpdateVacuumCalibration("vac", VacuumCalibration{Transform: result.Transform, Rotation: rotation})
+	if err := SaveCalibration(path, cal); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := LoadCalibration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, rotation2, reused := AlignMapsReusingRotation("vac", vac, ref, config, cal)
+	if !reused || rotation2.Rotation != rotation.Rotation {
+		t.Errorf("unchanged maps: reused=%v rotation=%v, want the cached %v", reused, rotation2.Rotation, rotation.Rotation)
+	}
+	if again.Score < preAlignMinScore {
+		t.Errorf("reused rotation score = %v", again.Score)
+	}
+
+	// A changed map is swept again
+	if _, _, reused := AlignMapsReusingRotation("vac", rotatedRoom(180), ref, config, cal); reused {
+		t.Error("reused the rotation of a different map")
+	}
+}
@@ -0,0 +1,142 @@
+package mesh
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// ErrNotComparable is returned by RotationVariants for a vacuum that has no
+// map or is the reference, which never rotates.
+var ErrNotComparable = errors.New("vacuum cannot be compared against the reference")
+
+// RotationVariant is one option of a rotation comparison: every vacuum's
+// transform with the compared vacuum turned by Rotation, and how well that
+// lines it up with the reference.
+type RotationVariant struct {
+	Rotation   float64
+	Score      float64                 // Inlier score as in ICPResult.Score (higher is better)
+	Transforms map[string]AffineMatrix // Onto the reference, for a CompositeRenderer
+}
+
+// RotationVariants turns vacuumID by each rotation, placed at the best
+// initial translation as RenderWithForcedRotation does, and scores the
+// result against the reference. The other vacuums keep their transforms.
+// An empty list compares CardinalRotations.
+func RotationVariants(maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference, vacuumID string, rotations []float64) ([]RotationVariant, error) {
+	source, target := maps[vacuumID], maps[reference]
+	if source == nil || target == nil || vacuumID == reference {
+		return nil, fmt.Errorf("%w: %q", ErrNotComparable, vacuumID)
+	}
+	if len(rotations) == 0 {
+		rotations = CardinalRotations
+	}
+
+	srcFeatures := ExtractFeatures(source)
+	tgtFeatures := ExtractFeatures(target)
+	srcPoints := SampleFeatures(srcFeatures, 300)
+	tgtPoints := SampleFeatures(tgtFeatures, 300)
+
+	// The forced transform maps onto the reference map; the reference's own
+	// transform (e.g. a floorplan snap) carries it the rest of the way
+	refTransform, ok := transforms[reference]
+	if !ok {
+		refTransform = Identity()
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	variants := make([]RotationVariant, 0, len(rotations))
+	for _, rot := range rotations {
+		forced := buildInitialTransform(srcFeatures, tgtFeatures, rot, rng)
+		score, _, _ := CalculateInlierScore(TransformPoints(srcPoints, forced), tgtPoints, 50.0) // 50px tolerance, as in AlignMaps
+
+		variant := RotationVariant{Rotation: rot, Score: score, Transforms: make(map[string]AffineMatrix, len(maps))}
+		for id := range maps {
+			if t, ok := transforms[id]; ok {
+				variant.Transforms[id] = t
+			} else {
+				variant.Transforms[id] = Identity()
+			}
+		}
+		variant.Transforms[vacuumID] = MultiplyMatrices(refTransform, forced)
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// Rotation grid layout in pixels.
+const (
+	rotationGridGap     = 10
+	rotationGridColumns = 2
+)
+
+var (
+	rotationGridBG       = color.RGBA{255, 255, 255, 255}
+	rotationGridText     = color.RGBA{0, 0, 0, 255}
+	rotationGridBestText = color.RGBA{0, 128, 0, 255}
+)
+
+// RenderRotationGrid draws each variant with render and lays the images out
+// two per row, each captioned with its rotation and score. The best scoring
+// variant's caption is green. Text is drawn in f (nil = bitmap font).
+func RenderRotationGrid(variants []RotationVariant, render func(RotationVariant) *image.RGBA, f *Font) *image.RGBA {
+	if len(variants) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	best := 0
+	for i, v := range variants {
+		if v.Score > variants[best].Score {
+			best = i
+		}
+	}
+
+	images := make([]*image.RGBA, len(variants))
+	captions := make([]string, len(variants))
+	cellW, cellH := 0, 0
+	captionH := 0
+	for i, v := range variants {
+		images[i] = render(v)
+		cellW = max(cellW, images[i].Bounds().Dx())
+		cellH = max(cellH, images[i].Bounds().Dy())
+
+		captions[i] = fmt.Sprintf("%s°  score %.2f", strconv.FormatFloat(v.Rotation, 'f', -1, 64), v.Score)
+		if i == best {
+			captions[i] += "  (best)"
+		}
+		w, ascent, descent := f.measure(captions[i], 1)
+		cellW = max(cellW, w)
+		captionH = max(captionH, ascent+descent)
+	}
+	captionH += rotationGridGap / 2
+
+	cols := min(rotationGridColumns, len(variants))
+	rows := (len(variants) + cols - 1) / cols
+	width := cols*cellW + (cols+1)*rotationGridGap
+	height := rows*(captionH+cellH) + (rows+1)*rotationGridGap
+	grid := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(grid, grid.Bounds(), image.NewUniform(rotationGridBG), image.Point{}, draw.Src)
+
+	for i, img := range images {
+		x := rotationGridGap + (i%cols)*(cellW+rotationGridGap)
+		y := rotationGridGap + (i/cols)*(captionH+cellH+rotationGridGap)
+
+		c := rotationGridText
+		if i == best {
+			c = rotationGridBestText
+		}
+		_, ascent, _ := f.measure(captions[i], 1)
+		f.drawString(grid, x, y+ascent, captions[i], c, 1)
+
+		// Center the image in its cell below the caption
+		b := img.Bounds()
+		at := image.Pt(x+(cellW-b.Dx())/2, y+captionH+(cellH-b.Dy())/2)
+		draw.Draw(grid, b.Sub(b.Min).Add(at), img, b.Min, draw.Src)
+	}
+	return grid
+}
@@ -0,0 +1,80 @@
+package mesh
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRotationVariants(t *testing.T) {
+	maps := map[string]*ValetudoMap{"ref": rotatedRoom(0), "vac": rotatedRoom(90)}
+	transforms := map[string]AffineMatrix{"ref": Identity()}
+
+	variants, err := RotationVariants(maps, transforms, "ref", "vac", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != len(CardinalRotations) {
+		t.Fatalf("got %d variants, want the %d cardinal rotations", len(variants), len(CardinalRotations))
+	}
+
+	best := variants[0]
+	for i, v := range variants {
+		if v.Rotation != CardinalRotations[i] {
+			t.Errorf("variant %d rotation = %v, want %v", i, v.Rotation, CardinalRotations[i])
+		}
+		if v.Transforms["ref"] != Identity() {
+			t.Errorf("variant %v moved the reference", v.Rotation)
+		}
+		if v.Score > best.Score {
+			best = v
+		}
+	}
+	// Undoing the quarter turn lines the room up
+	if best.Rotation != 270 {
+		t.Errorf("best rotation = %v (scores %v), want 270", best.Rotation, variants)
+	}
+}
+
+func TestRotationVariants_NotComparable(t *testing.T) {
+	maps := map[string]*ValetudoMap{"ref": rotatedRoom(0), "vac": rotatedRoom(90)}
+	for _, id := range []string{"ref", "missing"} {
+		if _, err := RotationVariants(maps, nil, "ref", id, nil); !errors.Is(err, ErrNotComparable) {
+			t.Errorf("%s: err = %v, want ErrNotComparable", id, err)
+		}
+	}
+}
+
+func TestRenderRotationGrid(t *testing.T) {
+	variants := []RotationVariant{{Rotation: 0, Score: 0.2}, {Rotation: 90, Score: 0.9}, {Rotation: 180}, {Rotation: 270}}
+	cell := func(RotationVariant) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+		for i := range img.Pix {
+			img.Pix[i] = 0x80
+		}
+		return img
+	}
+	grid := RenderRotationGrid(variants, cell, nil)
+
+	b := grid.Bounds()
+	if b.Dx() < 400 || b.Dx() > 450 || b.Dy() < 200 || b.Dy() > 300 {
+		t.Fatalf("grid is %dx%d, want two 200x100 cells per row and two rows", b.Dx(), b.Dy())
+	}
+
+	// The best variant, top right, has a green caption
+	green := false
+	for y := 0; y < 30; y++ {
+		for x := b.Dx() / 2; x < b.Dx(); x++ {
+			if grid.RGBAAt(x, y) == rotationGridBestText {
+				green = true
+			}
+		}
+	}
+	if !green {
+		t.Error("best variant caption not green")
+	}
+	if got := grid.RGBAAt(b.Dx()-20, b.Dy()-20); got != (color.RGBA{0x80, 0x80, 0x80, 0x80}) {
+		t.Errorf("bottom right cell pixel = %v, want the rendered image", got)
+	}
+}
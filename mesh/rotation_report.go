@@ -0,0 +1,89 @@
+package mesh
+
+import "sort"
+
+// RotationReport is the rotation detection of every vacuum against the
+// reference, the data --detect-rotation prints.
+type RotationReport struct {
+	ReferenceVacuum string                 `json:"referenceVacuum"`
+	Reference       WallAngleSummary       `json:"reference"`
+	Vacuums         []VacuumRotationReport `json:"vacuums"` // sorted by ID, reference excluded
+}
+
+// WallAngleSummary is a map's dominant wall angles.
+type WallAngleSummary struct {
+	DominantAngles []float64 `json:"dominantAngles"` // Degrees, strongest first
+	Edges          int       `json:"edges"`          // Wall edges analyzed
+}
+
+// VacuumRotationReport is the rotation detection of one vacuum.
+type VacuumRotationReport struct {
+	VacuumID string `json:"vacuumId"`
+	WallAngleSummary
+	Scores       []RotationScore `json:"scores"`       // One per cardinal rotation, in order
+	BestRotation float64         `json:"bestRotation"` // Degrees
+	Confidence   float64         `json:"confidence"`   // 0-1
+}
+
+// RotationScore is the similarity score of one candidate rotation.
+type RotationScore struct {
+	Rotation float64 `json:"rotation"` // Degrees
+	Score    float64 `json:"score"`    // Higher is better
+}
+
+// AnalyzeRotations runs DetectRotationWithFeatures for every vacuum against
+// the reference map. Vacuums are sorted by ID; the report has no vacuums if
+// the reference has no map.
+func AnalyzeRotations(maps map[string]*ValetudoMap, reference string) RotationReport {
+	report := RotationReport{ReferenceVacuum: reference, Vacuums: []VacuumRotationReport{}}
+	refMap := maps[reference]
+	if refMap == nil {
+		return report
+	}
+	report.Reference = summarizeWallAngles(refMap)
+
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		if id != reference && maps[id] != nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		analysis := DetectRotationWithFeatures(maps[id], refMap)
+		vr := VacuumRotationReport{
+			VacuumID:         id,
+			WallAngleSummary: summarizeWallAngles(maps[id]),
+			Scores:           make([]RotationScore, 0, len(CardinalRotations)),
+			BestRotation:     analysis.BestRotation,
+			Confidence:       analysis.Confidence,
+		}
+		for _, rot := range CardinalRotations {
+			vr.Scores = append(vr.Scores, RotationScore{Rotation: rot, Score: analysis.Scores[rot]})
+		}
+		report.Vacuums = append(report.Vacuums, vr)
+	}
+	return report
+}
+
+// Suggestions returns the best rotation of each vacuum in the report.
+func (r RotationReport) Suggestions() []RotationSuggestion {
+	suggestions := make([]RotationSuggestion, 0, len(r.Vacuums))
+	for _, v := range r.Vacuums {
+		s := RotationSuggestion{VacuumID: v.VacuumID, Rotation: v.BestRotation, Confidence: v.Confidence}
+		for _, score := range v.Scores {
+			if score.Rotation == v.BestRotation {
+				s.Score = score.Score
+			}
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions
+}
+
+// summarizeWallAngles returns the four dominant wall angles of m.
+func summarizeWallAngles(m *ValetudoMap) WallAngleSummary {
+	hist := ExtractWallAngles(m)
+	return WallAngleSummary{DominantAngles: hist.DominantAngles(4), Edges: hist.TotalEdges}
+}
@@ -0,0 +1,41 @@
+package mesh
+
+import "testing"
+
+func TestAnalyzeRotations(t *testing.T) {
+	maps := map[string]*ValetudoMap{"ref": rotatedRoom(0), "b": rotatedRoom(90), "a": rotatedRoom(0)}
+
+	report := AnalyzeRotations(maps, "ref")
+	if report.ReferenceVacuum != "ref" || report.Reference.Edges == 0 {
+		t.Errorf("reference = %q with %d edges, want ref with its walls", report.ReferenceVacuum, report.Reference.Edges)
+	}
+	if len(report.Vacuums) != 2 || report.Vacuums[0].VacuumID != "a" || report.Vacuums[1].VacuumID != "b" {
+		t.Fatalf("vacuums = %+v, want a and b in order without the reference", report.Vacuums)
+	}
+	for _, v := range report.Vacuums {
+		if len(v.Scores) != len(CardinalRotations) {
+			t.Errorf("%s: %d scores, want one per cardinal rotation", v.VacuumID, len(v.Scores))
+		}
+		if len(v.DominantAngles) == 0 {
+			t.Errorf("%s: no dominant angles", v.VacuumID)
+		}
+	}
+	if got := report.Vacuums[0].BestRotation; got != 0 {
+		t.Errorf("unrotated vacuum best rotation = %v, want 0", got)
+	}
+
+	suggestions := report.Suggestions()
+	if len(suggestions) != 2 || suggestions[1].VacuumID != "b" || suggestions[1].Rotation != report.Vacuums[1].BestRotation {
+		t.Fatalf("suggestions = %+v, want each vacuum's best rotation", suggestions)
+	}
+	if suggestions[1].Score == 0 {
+		t.Error("suggestion has no score")
+	}
+}
+
+func TestAnalyzeRotations_NoReference(t *testing.T) {
+	report := AnalyzeRotations(map[string]*ValetudoMap{"a": rotatedRoom(0)}, "missing")
+	if report.Vacuums == nil || len(report.Vacuums) != 0 {
+		t.Errorf("vacuums = %#v, want an empty list", report.Vacuums)
+	}
+}
@@ -0,0 +1,175 @@
+package mesh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RotationSuggestion is a detected rotation for one vacuum, as
+// --detect-rotation reports it.
+type RotationSuggestion struct {
+	VacuumID   string
+	Rotation   float64 // Degrees
+	Score      float64 // Similarity score of the rotation
+	Confidence float64 // 0-1
+}
+
+// FormatRotationSnippet returns the suggestions as a vacuums section to
+// paste into config.yaml, each rotation commented with its score and
+// confidence.
+func FormatRotationSnippet(suggestions []RotationSuggestion) string {
+	var b strings.Builder
+	b.WriteString("vacuums:\n")
+	for _, s := range suggestions {
+		fmt.Fprintf(&b, "  - id: %s\n", yamlScalar(s.VacuumID))
+		fmt.Fprintf(&b, "    rotation: %s  # score %.4f, confidence %.1f%%\n",
+			strconv.FormatFloat(s.Rotation, 'f', -1, 64), s.Score, s.Confidence*100)
+	}
+	return b.String()
+}
+
+// yamlScalar returns s encoded as a YAML scalar, quoted only when needed.
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
+
+// ApplyRotationSuggestions sets the rotation of each suggested vacuum in
+// the config file at path, keeping its other settings and comments;
+// indentation is normalized to two spaces. The file is left unchanged if
+// any suggested vacuum is not configured in it.
+func ApplyRotationSuggestions(path string, suggestions []RotationSuggestion) error {
+	hints := make([]CalibrationHint, len(suggestions))
+	for i, s := range suggestions {
+		hints[i] = CalibrationHint{VacuumID: s.VacuumID, Rotation: s.Rotation}
+	}
+	return ApplyCalibrationHints(path, hints)
+}
+
+// CalibrationHint is a vacuum's rotation hint and, when Translation is set,
+// its manual translation in pixels, as written to config.yaml.
+type CalibrationHint struct {
+	VacuumID    string
+	Rotation    float64            // Degrees
+	Translation *TranslationOffset // nil leaves the configured translation as it is
+}
+
+// ApplyCalibrationHints sets the rotation, and translation where given, of
+// each hinted vacuum in the config file at path, as ApplyRotationSuggestions
+// does. A zero translation removes the vacuum's translation.
+func ApplyCalibrationHints(path string, hints []CalibrationHint) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("parsing config YAML: %w", err)
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML mapping", path)
+	}
+
+	// Vacuum entries by ID
+	entries := make(map[string]*yaml.Node)
+	if vacuums := mappingValue(root.Content[0], "vacuums"); vacuums != nil && vacuums.Kind == yaml.SequenceNode {
+		for _, entry := range vacuums.Content {
+			if entry.Kind != yaml.MappingNode {
+				continue
+			}
+			if id := mappingValue(entry, "id"); id != nil {
+				entries[id.Value] = entry
+			}
+		}
+	}
+
+	var missing []string
+	for _, h := range hints {
+		if entries[h.VacuumID] == nil {
+			missing = append(missing, h.VacuumID)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("vacuums not in %s: %s", path, strings.Join(missing, ", "))
+	}
+
+	for _, h := range hints {
+		entry := entries[h.VacuumID]
+		setMappingValue(entry, "rotation", floatNode(h.Rotation))
+		switch {
+		case h.Translation == nil:
+		case *h.Translation == (TranslationOffset{}):
+			deleteMappingValue(entry, "translation")
+		default:
+			setMappingValue(entry, "translation", &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "x"}, floatNode(h.Translation.X),
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "y"}, floatNode(h.Translation.Y),
+			}})
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return fmt.Errorf("marshaling config YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("marshaling config YAML: %w", err)
+	}
+	// Keep the file's mode: it may hold credentials
+	if err := WriteFileAtomic(path, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("writing config file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key in a YAML mapping node to value, in place when
+// the key exists so its comments stay, or appended otherwise.
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			value.HeadComment, value.LineComment = m.Content[i+1].HeadComment, m.Content[i+1].LineComment
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// deleteMappingValue removes key from a YAML mapping node.
+func deleteMappingValue(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// floatNode returns f as a YAML float scalar.
+func floatNode(f float64) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(f, 'f', -1, 64)}
+}
@@ -0,0 +1,121 @@
+package mesh
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFormatRotationSnippet(t *testing.T) {
+	got := FormatRotationSnippet([]RotationSuggestion{
+		{VacuumID: "dusty", Rotation: 180, Score: 0.91234, Confidence: 0.876},
+		{VacuumID: "yes", Rotation: 37.5},
+	})
+	want := "vacuums:\n" +
+		"  - id: dusty\n" +
+		"    rotation: 180  # score 0.9123, confidence 87.6%\n" +
+		"  - id: \"yes\"\n" +
+		"    rotation: 37.5  # score 0.0000, confidence 0.0%\n"
+	if got != want {
+		t.Errorf("snippet =\n%s\nwant\n%s", got, want)
+	}
+}
+
+const applyTestConfig = `# Home setup
+mqtt:
+  broker: tcp://localhost:1883
+vacuums:
+  - id: rocky # upstairs
+    topic: t/rocky
+  - id: dusty
+    topic: t/dusty
+    rotation: 90
+`
+
+func writeApplyTestConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(applyTestConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyRotationSuggestions(t *testing.T) {
+	path := writeApplyTestConfig(t)
+	err := ApplyRotationSuggestions(path, []RotationSuggestion{
+		{VacuumID: "rocky", Rotation: 270},
+		{VacuumID: "dusty", Rotation: 180},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("applied config does not load: %v", err)
+	}
+	for id, want := range map[string]float64{"rocky": 270, "dusty": 180} {
+		if got := config.GetVacuumByID(id).Rotation; got == nil || *got != want {
+			t.Errorf("%s rotation = %v, want %v", id, got, want)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, comment := range []string{"# Home setup", "# upstairs"} {
+		if !strings.Contains(string(data), comment) {
+			t.Errorf("comment %q lost:\n%s", comment, data)
+		}
+	}
+	// Windows has no Unix permission bits to keep
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 && runtime.GOOS != "windows" {
+		t.Errorf("mode = %v, want 0600 kept", info.Mode().Perm())
+	}
+}
+
+func TestApplyRotationSuggestions_UnknownVacuum(t *testing.T) {
+	path := writeApplyTestConfig(t)
+	err := ApplyRotationSuggestions(path, []RotationSuggestion{
+		{VacuumID: "rocky", Rotation: 270},
+		{VacuumID: "ghost", Rotation: 90},
+	})
+	if err == nil || !strings.Contains(err.Error(), "ghost") {
+		t.Fatalf("err = %v, want the unknown vacuum named", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != applyTestConfig {
+		t.Errorf("config changed despite the error:\n%s", data)
+	}
+}
+
+func TestApplyCalibrationHints(t *testing.T) {
+	path := writeApplyTestConfig(t)
+	err := ApplyCalibrationHints(path, []CalibrationHint{
+		{VacuumID: "rocky", Rotation: 37.5, Translation: &TranslationOffset{X: 12, Y: -4.5}},
+		{VacuumID: "dusty", Rotation: 180},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("applied config does not load: %v", err)
+	}
+	rocky := config.GetVacuumByID("rocky")
+	if rocky.Rotation == nil || *rocky.Rotation != 37.5 || rocky.GetTranslation() != (TranslationOffset{X: 12, Y: -4.5}) {
+		t.Errorf("rocky = rotation %v, translation %+v", rocky.Rotation, rocky.Translation)
+	}
+	if dusty := config.GetVacuumByID("dusty"); dusty.Translation != nil {
+		t.Errorf("dusty translation = %+v, want none", dusty.Translation)
+	}
+
+	// A zero translation removes it
+	if err := ApplyCalibrationHints(path, []CalibrationHint{{VacuumID: "rocky", Rotation: 40, Translation: &TranslationOffset{}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "translation") || !strings.Contains(string(data), "# upstairs") {
+		t.Errorf("config =\n%s\nwant the translation removed and comments kept", data)
+	}
+}
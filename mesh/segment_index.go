@@ -0,0 +1,121 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// segmentIndexCells is the most grid cells a SegmentIndex spreads its
+// segments over along the longer side of the map.
+const segmentIndexCells = 64
+
+// minSegmentIndexCell keeps grid cells from shrinking below 25cm on small
+// maps, where finer cells only cost memory.
+const minSegmentIndexCell = 250.0
+
+// SegmentIndex answers which unified segment contains a point. It is built
+// once per unified map: each segment's outline is decoded up front and
+// filed under the grid cells its bounding box touches, so a lookup tests
+// only the few segments near the point.
+type SegmentIndex struct {
+	segments []indexedSegment
+	bound    orb.Bound
+	cell     float64 // mm per grid cell
+	cols     int
+	cells    [][]int // grid cell -> indexes into segments
+}
+
+// indexedSegment is a unified segment prepared for hit-testing.
+type indexedSegment struct {
+	poly    orb.Polygon
+	bound   orb.Bound
+	summary SegmentSummary
+}
+
+// NewSegmentIndex prepares the segments of um for SegmentAt. A nil map
+// yields an empty index.
+func NewSegmentIndex(um *UnifiedMap) *SegmentIndex {
+	idx := &SegmentIndex{}
+	if um == nil {
+		return idx
+	}
+	for _, seg := range um.Segments {
+		poly := orbPolygon(seg.Geometry)
+		if len(poly) == 0 || len(poly[0]) < 3 {
+			continue
+		}
+		s := indexedSegment{poly: poly, bound: poly.Bound(), summary: summarizeSegment(seg, poly)}
+		if len(idx.segments) == 0 {
+			idx.bound = s.bound
+		} else {
+			idx.bound = idx.bound.Union(s.bound)
+		}
+		idx.segments = append(idx.segments, s)
+	}
+	if len(idx.segments) == 0 {
+		return idx
+	}
+
+	span := math.Max(idx.bound.Max[0]-idx.bound.Min[0], idx.bound.Max[1]-idx.bound.Min[1])
+	idx.cell = math.Max(span/segmentIndexCells, minSegmentIndexCell)
+	idx.cols = idx.col(idx.bound.Max[0]) + 1
+	rows := idx.row(idx.bound.Max[1]) + 1
+	idx.cells = make([][]int, idx.cols*rows)
+	for i, s := range idx.segments {
+		for r := idx.row(s.bound.Min[1]); r <= idx.row(s.bound.Max[1]); r++ {
+			for c := idx.col(s.bound.Min[0]); c <= idx.col(s.bound.Max[0]); c++ {
+				idx.cells[r*idx.cols+c] = append(idx.cells[r*idx.cols+c], i)
+			}
+		}
+	}
+	return idx
+}
+
+func (idx *SegmentIndex) col(x float64) int {
+	return int((x - idx.bound.Min[0]) / idx.cell)
+}
+
+func (idx *SegmentIndex) row(y float64) int {
+	return int((y - idx.bound.Min[1]) / idx.cell)
+}
+
+// Len returns the number of segments in the index.
+func (idx *SegmentIndex) Len() int {
+	return len(idx.segments)
+}
+
+// SegmentAt returns the segment containing p, in world coordinates (mm).
+// Where merged segments overlap, the smallest one wins, since it is the
+// most specific room. It reports false when p is outside every segment.
+func (idx *SegmentIndex) SegmentAt(p Point) (SegmentSummary, bool) {
+	if idx == nil || len(idx.segments) == 0 {
+		return SegmentSummary{}, false
+	}
+	pt := orb.Point{p.X, p.Y}
+	if !idx.bound.Contains(pt) {
+		return SegmentSummary{}, false
+	}
+
+	best := -1
+	for _, i := range idx.cells[idx.row(p.Y)*idx.cols+idx.col(p.X)] {
+		s := idx.segments[i]
+		if !s.bound.Contains(pt) || !planar.PolygonContains(s.poly, pt) {
+			continue
+		}
+		if best < 0 || s.summary.Area < idx.segments[best].summary.Area {
+			best = i
+		}
+	}
+	if best < 0 {
+		return SegmentSummary{}, false
+	}
+	return idx.segments[best].summary, true
+}
+
+// SegmentAt returns the unified segment containing p. Callers looking up
+// many points should build a SegmentIndex once instead.
+func (um *UnifiedMap) SegmentAt(p Point) (SegmentSummary, bool) {
+	return NewSegmentIndex(um).SegmentAt(p)
+}
@@ -0,0 +1,102 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// ---------------------------------------------------------------------------
+// Segment hit-testing
+// ---------------------------------------------------------------------------
+
+func TestSegmentIndex_SegmentAt(t *testing.T) {
+	um := &UnifiedMap{Segments: []*UnifiedFeature{
+		summarySegment("Kitchen", 0, 0, 3000, 2000, "rocky"),
+		summarySegment("Bath", 3000, 0, 2000, 2000, "dusty"),
+		summarySegment("Pantry", 500, 500, 500, 500, "rocky"), // inside Kitchen
+		summarySegment("", 0, 4000, 1000, 1000),
+	}}
+	idx := NewSegmentIndex(um)
+	if idx.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", idx.Len())
+	}
+
+	tests := []struct {
+		name string
+		p    Point
+		want string
+		ok   bool
+	}{
+		{"kitchen", Point{X: 2000, Y: 1500}, "Kitchen", true},
+		{"bath", Point{X: 4000, Y: 1000}, "Bath", true},
+		{"smallest overlapping segment wins", Point{X: 700, Y: 700}, "Pantry", true},
+		{"unnamed segment", Point{X: 500, Y: 4500}, "", true},
+		{"between segments", Point{X: 2000, Y: 3000}, "", false},
+		{"outside the map", Point{X: -100, Y: 100}, "", false},
+		{"far away", Point{X: 1e9, Y: 1e9}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seg, ok := idx.SegmentAt(tt.p)
+			if ok != tt.ok || seg.Name != tt.want {
+				t.Errorf("SegmentAt(%v) = %q, %v; want %q, %v", tt.p, seg.Name, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	seg, _ := idx.SegmentAt(Point{X: 4000, Y: 1000})
+	if seg.Area != 4 || seg.Centroid != (Point{X: 4000, Y: 1000}) || len(seg.Vacuums) != 1 || seg.Vacuums[0] != "dusty" {
+		t.Errorf("bath = %+v, want 4 m² at (4000, 1000) seen by dusty", seg)
+	}
+}
+
+func TestSegmentIndex_NonConvex(t *testing.T) {
+	// An L-shaped hall: its bounding box covers the notch, the polygon does not
+	ring := orb.Ring{{0, 0}, {4000, 0}, {4000, 1000}, {1000, 1000}, {1000, 4000}, {0, 4000}, {0, 0}}
+	um := &UnifiedMap{Segments: []*UnifiedFeature{{
+		Geometry:   polygonToGeometry(orb.Polygon{ring}),
+		Properties: map[string]interface{}{"segmentName": "Hall"},
+	}}}
+	idx := NewSegmentIndex(um)
+
+	if seg, ok := idx.SegmentAt(Point{X: 500, Y: 3500}); !ok || seg.Name != "Hall" {
+		t.Errorf("SegmentAt inside the L = %q, %v; want Hall", seg.Name, ok)
+	}
+	if _, ok := idx.SegmentAt(Point{X: 3000, Y: 3000}); ok {
+		t.Error("SegmentAt in the notch found a segment")
+	}
+}
+
+func TestSegmentIndex_Empty(t *testing.T) {
+	for _, um := range []*UnifiedMap{nil, {}} {
+		if _, ok := NewSegmentIndex(um).SegmentAt(Point{}); ok {
+			t.Errorf("SegmentAt on %v found a segment", um)
+		}
+	}
+	var idx *SegmentIndex
+	if _, ok := idx.SegmentAt(Point{}); ok {
+		t.Error("nil index found a segment")
+	}
+}
+
+func TestStateTracker_SegmentIndex(t *testing.T) {
+	st := NewStateTracker()
+	if st.SegmentIndex().Len() != 0 {
+		t.Fatal("index of a tracker without a unified map is not empty")
+	}
+
+	st.SetUnifiedMap(&UnifiedMap{Segments: []*UnifiedFeature{summarySegment("Kitchen", 0, 0, 1000, 1000)}})
+	idx := st.SegmentIndex()
+	if seg, ok := idx.SegmentAt(Point{X: 500, Y: 500}); !ok || seg.Name != "Kitchen" {
+		t.Errorf("SegmentAt = %q, %v; want Kitchen", seg.Name, ok)
+	}
+	if st.SegmentIndex() != idx {
+		t.Error("index rebuilt although the unified map did not change")
+	}
+
+	st.SetUnifiedMap(&UnifiedMap{Segments: []*UnifiedFeature{summarySegment("Bath", 0, 0, 1000, 1000)}})
+	if seg, _ := st.SegmentIndex().SegmentAt(Point{X: 500, Y: 500}); seg.Name != "Bath" {
+		t.Errorf("after a map change SegmentAt = %q, want Bath", seg.Name)
+	}
+}
@@ -0,0 +1,197 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// DefaultSegmentContainment is the fraction of a segment that must lie
+// inside a larger one for the two to be merged.
+const DefaultSegmentContainment = 0.7
+
+// containmentSamples is the number of grid samples per axis used to
+// estimate how much of a segment lies inside another.
+const containmentSamples = 24
+
+// MergeContainedSegments merges named segments that are mostly inside a
+// larger named segment, as happens when one vacuum splits a room in two
+// that another sees as one. Segments are visited largest first; a segment
+// with at least config's containment fraction inside a larger one is
+// unioned into it and the larger segment's name is kept. Absorbed names are
+// listed in the "mergedSegments" property. Unnamed floors are left alone.
+func MergeContainedSegments(features []*UnifiedFeature, totalVacuums int, config SegmentMergeConfig) []*UnifiedFeature {
+	if !config.enabled() || len(features) < 2 {
+		return features
+	}
+	minContainment := config.minContainment()
+
+	type candidate struct {
+		feature *UnifiedFeature
+		polygon orb.Polygon
+		area    float64
+	}
+	var named []*candidate
+	for _, uf := range features {
+		name, _ := uf.Properties["segmentName"].(string)
+		polygon := orbPolygon(uf.Geometry)
+		if name == "" || len(polygon) == 0 {
+			continue
+		}
+		named = append(named, &candidate{feature: uf, polygon: polygon, area: math.Abs(planar.Area(polygon))})
+	}
+	sort.SliceStable(named, func(i, j int) bool { return named[i].area > named[j].area })
+
+	replaced := make(map[*UnifiedFeature]*UnifiedFeature)
+	for i, big := range named {
+		if _, gone := replaced[big.feature]; gone {
+			continue
+		}
+		var merged []*UnifiedFeature
+		for _, small := range named[i+1:] {
+			if _, gone := replaced[small.feature]; gone {
+				continue
+			}
+			if containment(small.polygon, big.polygon) < minContainment {
+				continue
+			}
+			replaced[small.feature] = nil
+			merged = append(merged, small.feature)
+		}
+		if len(merged) > 0 {
+			replaced[big.feature] = mergeSegmentFeatures(big.feature, merged, totalVacuums)
+		}
+	}
+	if len(replaced) == 0 {
+		return features
+	}
+
+	// Keep the input order, dropping absorbed segments
+	result := make([]*UnifiedFeature, 0, len(features))
+	for _, uf := range features {
+		if r, ok := replaced[uf]; !ok {
+			result = append(result, uf)
+		} else if r != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// containment estimates the fraction of inner's area that lies inside outer
+// by sampling inner on a regular grid.
+func containment(inner, outer orb.Polygon) float64 {
+	bound := inner.Bound()
+	dx := (bound.Max[0] - bound.Min[0]) / containmentSamples
+	dy := (bound.Max[1] - bound.Min[1]) / containmentSamples
+	if dx <= 0 || dy <= 0 || !bound.Intersects(outer.Bound()) {
+		return 0
+	}
+	total, inside := 0, 0
+	for i := 0; i < containmentSamples; i++ {
+		for j := 0; j < containmentSamples; j++ {
+			p := orb.Point{bound.Min[0] + (float64(i)+0.5)*dx, bound.Min[1] + (float64(j)+0.5)*dy}
+			if !planar.PolygonContains(inner, p) {
+				continue
+			}
+			total++
+			if planar.PolygonContains(outer, p) {
+				inside++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(inside) / float64(total)
+}
+
+// mergeSegmentFeatures unions merged into base, keeping base's name and
+// properties and combining the sources of all of them.
+func mergeSegmentFeatures(base *UnifiedFeature, merged []*UnifiedFeature, totalVacuums int) *UnifiedFeature {
+	geoms := []*Geometry{base.Geometry}
+	sources := append([]FeatureSource(nil), base.Sources...)
+	props := make(map[string]interface{}, len(base.Properties)+1)
+	for k, v := range base.Properties {
+		props[k] = v
+	}
+	var names []string
+	if prev, ok := props["mergedSegments"].([]string); ok {
+		names = append(names, prev...)
+	}
+	for _, uf := range merged {
+		geoms = append(geoms, uf.Geometry)
+		sources = append(sources, uf.Sources...)
+		if name, _ := uf.Properties["segmentName"].(string); name != "" && name != props["segmentName"] {
+			names = append(names, name)
+		}
+	}
+
+	geom := UnionPolygons(geoms)
+	if geom == nil {
+		geom = base.Geometry
+	} else if hull := orbPolygon(geom); len(hull) > 0 {
+		// Holes already passed consensus when the segments were unified;
+		// keep one ring per obstacle so even-odd filling leaves it empty
+		var holes []observedHole
+		for _, g := range geoms {
+		rings:
+			for _, h := range polygonHoles(orbPolygon(g), "") {
+				if !planar.RingContains(hull[0], h.centroid) {
+					continue
+				}
+				for _, prev := range holes {
+					if sameHole(prev, h) {
+						continue rings
+					}
+				}
+				holes = append(holes, h)
+				hull = append(hull, h.ring)
+			}
+		}
+		geom = polygonToGeometry(hull)
+	}
+
+	vacuums := make(map[string]struct{})
+	for _, s := range sources {
+		if s.VacuumID != "" {
+			vacuums[s.VacuumID] = struct{}{}
+		}
+	}
+	observations := len(vacuums)
+	confidence := base.Confidence
+	if totalVacuums > 0 {
+		confidence = float64(observations) / float64(totalVacuums)
+	}
+
+	if len(names) > 0 {
+		sort.Strings(names)
+		props["mergedSegments"] = names
+	}
+	props["observationCount"] = observations
+	props["confidence"] = confidence
+
+	return &UnifiedFeature{
+		Geometry:         geom,
+		Properties:       props,
+		Sources:          sources,
+		Confidence:       confidence,
+		ObservationCount: observations,
+	}
+}
+
+// enabled reports whether segment merging is on (the default).
+func (c SegmentMergeConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// minContainment returns the configured threshold or
+// DefaultSegmentContainment.
+func (c SegmentMergeConfig) minContainment() float64 {
+	if c.MinContainment > 0 {
+		return c.MinContainment
+	}
+	return DefaultSegmentContainment
+}
@@ -0,0 +1,118 @@
+package mesh
+
+import (
+	"reflect"
+	"testing"
+)
+
+// segmentRect returns a rectangular segment named name as UnifyFloors would
+// have produced it from vacuumID alone.
+func segmentRect(x0, y0, x1, y1 float64, name, vacuumID string) *Feature {
+	return makePolygonFeature([][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}, map[string]interface{}{
+		"layerType":   "segment",
+		"segmentName": name,
+		"area":        (x1 - x0) * (y1 - y0),
+	})
+}
+
+// splitRoom unifies a 4x2 m room seen whole by "a" and split in two halves
+// by "b".
+func splitRoom(t *testing.T) []*UnifiedFeature {
+	t.Helper()
+	features := []*Feature{
+		segmentRect(0, 0, 4000, 2000, "Living Room", "a"),
+		segmentRect(0, 0, 2000, 2000, "Lounge", "b"),
+		segmentRect(2000, 0, 4000, 2000, "Dining", "b"),
+		segmentRect(5000, 0, 7000, 2000, "Kitchen", "a"),
+	}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1), makeSource("b", 1), makeSource("a", 1)}
+	unified := UnifyFloors(features, sources, 2)
+	if len(unified) != 4 {
+		t.Fatalf("UnifyFloors returned %d features, want 4", len(unified))
+	}
+	return unified
+}
+
+func segmentNames(features []*UnifiedFeature) []string {
+	var names []string
+	for _, f := range features {
+		name, _ := f.Properties["segmentName"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+// ---------------------------------------------------------------------------
+// MergeContainedSegments
+// ---------------------------------------------------------------------------
+
+func TestMergeContainedSegments_SplitRoom(t *testing.T) {
+	merged := MergeContainedSegments(splitRoom(t), 2, SegmentMergeConfig{})
+
+	if got, want := segmentNames(merged), []string{"Living Room", "Kitchen"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("segments = %v, want %v", got, want)
+	}
+	living := merged[0]
+	if got, want := living.Properties["mergedSegments"], []string{"Dining", "Lounge"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergedSegments = %v, want %v", got, want)
+	}
+	if living.ObservationCount != 2 || living.Confidence != 1 {
+		t.Errorf("observations = %d, confidence = %v, want 2 and 1", living.ObservationCount, living.Confidence)
+	}
+	if len(living.Sources) != 3 {
+		t.Errorf("sources = %d, want 3", len(living.Sources))
+	}
+	if living.Properties["observationCount"] != 2 {
+		t.Errorf("observationCount property = %v, want 2", living.Properties["observationCount"])
+	}
+	if merged[1].Properties["mergedSegments"] != nil {
+		t.Error("Kitchen should be untouched")
+	}
+}
+
+func TestMergeContainedSegments_Threshold(t *testing.T) {
+	// Bedroom is 50% inside Hall: merged at 0.5, kept at the 0.7 default
+	features := []*Feature{
+		segmentRect(0, 0, 4000, 2000, "Hall", "a"),
+		segmentRect(3000, 0, 5000, 2000, "Bedroom", "b"),
+	}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1)}
+	unified := UnifyFloors(features, sources, 2)
+
+	tests := []struct {
+		name   string
+		config SegmentMergeConfig
+		want   []string
+	}{
+		{"default", SegmentMergeConfig{}, []string{"Hall", "Bedroom"}},
+		{"lower threshold", SegmentMergeConfig{MinContainment: 0.5}, []string{"Hall"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := segmentNames(MergeContainedSegments(unified, 2, tt.config))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeContainedSegments_Disabled(t *testing.T) {
+	disabled := false
+	unified := splitRoom(t)
+	merged := MergeContainedSegments(unified, 2, SegmentMergeConfig{Enabled: &disabled})
+	if len(merged) != len(unified) {
+		t.Errorf("got %d features with merging disabled, want %d", len(merged), len(unified))
+	}
+}
+
+func TestMergeContainedSegments_UnnamedFloorsKept(t *testing.T) {
+	floor := makePolygonFeature([][2]float64{{0, 0}, {8000, 0}, {8000, 3000}, {0, 3000}}, nil)
+	features := []*Feature{floor, segmentRect(0, 0, 2000, 2000, "Lounge", "b")}
+	sources := []FeatureSource{makeSource("a", 1), makeSource("b", 1)}
+	unified := UnifyFloors(features, sources, 2)
+
+	if merged := MergeContainedSegments(unified, 2, SegmentMergeConfig{}); len(merged) != 2 {
+		t.Errorf("got %d features, want the floor and the segment kept apart", len(merged))
+	}
+}
@@ -0,0 +1,243 @@
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Simulation defaults.
+const (
+	MaxSimulatedVacuums        = 8
+	DefaultSimulationInterval  = 2 * time.Second
+	simulatedPixelSize         = 5   // mm per grid cell, as most Valetudo robots
+	simulatedOrigin            = 300 // grid offset of the home, as in real maps
+	simulatedStripe            = 8   // cells between cleaning passes (40cm)
+	simulatedStep              = 4   // cells moved per report (20cm)
+	simulatedRoomMargin        = 6   // cells kept clear of the walls
+	simulatedBatteryPerCharged = 70  // battery percent used before the robot recharges
+)
+
+// simulatedRoom is a room of the simulated home in grid cells, relative to
+// simulatedOrigin: x in [x0, x1), y in [y0, y1).
+type simulatedRoom struct {
+	id, name       string
+	x0, y0, x1, y1 int
+}
+
+// simulatedRooms is the simulated home: a 10m x 6m flat with a living room
+// along the west side and a kitchen and bedroom to the east, joined by
+// doorways.
+var simulatedRooms = []simulatedRoom{
+	{"1", "Living room", 0, 0, 100, 120},
+	{"2", "Kitchen", 101, 0, 200, 60},
+	{"3", "Bedroom", 101, 61, 200, 120},
+}
+
+// simulatedColors are the marker colors of the simulated vacuums in order.
+var simulatedColors = []string{"#6495ED", "#FF6347", "#3CB371", "#FFD700", "#BA55D3", "#40E0D0", "#FFA500", "#A0522D"}
+
+// Simulator stands in for real robots in demos and development: n vacuums
+// cleaning one synthetic home, each reporting its map with a moving robot
+// position and its battery level on the topics Valetudo would use. The
+// vacuums share one map frame, so they need no calibration.
+type Simulator struct {
+	vacuums []VacuumConfig
+	layers  []MapLayer
+	paths   [][]Point // cleaning path of each vacuum in grid cells, out and back
+}
+
+// NewSimulator creates a simulator of n vacuums, 1 to MaxSimulatedVacuums.
+func NewSimulator(n int) (*Simulator, error) {
+	if n < 1 || n > MaxSimulatedVacuums {
+		return nil, fmt.Errorf("simulated vacuums must be 1 to %d, got %d", MaxSimulatedVacuums, n)
+	}
+	s := &Simulator{layers: simulatedLayers()}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("sim%d", i+1)
+		s.vacuums = append(s.vacuums, VacuumConfig{
+			ID:          id,
+			Topic:       "valetudo/" + id + "/MapData/map-data",
+			Color:       simulatedColors[i],
+			DisplayName: fmt.Sprintf("Simulated %d", i+1),
+		})
+		s.paths = append(s.paths, lawnmowerPath(simulatedRooms[i%len(simulatedRooms)]))
+	}
+	return s, nil
+}
+
+// Vacuums returns the config of the simulated vacuums, named sim1 to simN.
+func (s *Simulator) Vacuums() []VacuumConfig {
+	return append([]VacuumConfig(nil), s.vacuums...)
+}
+
+// Calibration returns identity transforms for the simulated vacuums, which
+// all map the home in the same frame, with sim1 as reference.
+func (s *Simulator) Calibration() *CalibrationData {
+	cal := &CalibrationData{ReferenceVacuum: s.vacuums[0].ID, Vacuums: make(map[string]VacuumCalibration, len(s.vacuums))}
+	for _, vc := range s.vacuums {
+		cal.Vacuums[vc.ID] = VacuumCalibration{Transform: Identity(), ICPScore: 1}
+	}
+	return cal
+}
+
+// Messages returns the messages every simulated vacuum sends at the given
+// step of the simulation: its map with the robot's position and its battery
+// level.
+func (s *Simulator) Messages(step int) ([]RecordedMessage, error) {
+	now := time.Now().UTC()
+	var msgs []RecordedMessage
+	for i, vc := range s.vacuums {
+		path := s.paths[i]
+		// Stagger the vacuums so they are not all in step
+		k := step + i*len(path)/len(s.vacuums)
+		pos := path[k%len(path)]
+		next := path[(k+1)%len(path)]
+		angle := 0.0
+		if next != pos {
+			angle = NormalizeAngle(math.Atan2(next.Y-pos.Y, next.X-pos.X) * 180 / math.Pi)
+		}
+
+		payload, err := json.Marshal(s.mapAt(path[0], pos, angle))
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, RecordedMessage{Time: now, Topic: vc.Topic, VacuumID: vc.ID, Payload: payload})
+
+		if topic, ok := deriveBatteryTopic(vc.Topic); ok {
+			battery := 100 - k%simulatedBatteryPerCharged
+			msgs = append(msgs, RecordedMessage{Time: now, Topic: topic, VacuumID: vc.ID, Payload: []byte(strconv.Itoa(battery))})
+		}
+	}
+	return msgs, nil
+}
+
+// Run sends the messages of each step to publish every interval until ctx
+// is cancelled.
+func (s *Simulator) Run(ctx context.Context, interval time.Duration, publish func(RecordedMessage)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for step := 0; ; step++ {
+		msgs, err := s.Messages(step)
+		if err != nil {
+			log.Printf("[SIMULATE] step %d: %v", step, err)
+		}
+		for _, msg := range msgs {
+			publish(msg)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// mapAt returns a vacuum's map with its charger at dock and the robot at
+// pos, facing angle degrees.
+func (s *Simulator) mapAt(dock, pos Point, angle float64) *ValetudoMap {
+	mm := func(p Point) []int {
+		return []int{int(p.X+simulatedOrigin) * simulatedPixelSize, int(p.Y+simulatedOrigin) * simulatedPixelSize}
+	}
+	area := 0
+	for _, l := range s.layers {
+		if l.Type != "wall" {
+			area += l.MetaData.Area
+		}
+	}
+	return &ValetudoMap{
+		Class:     "ValetudoMap",
+		MetaData:  MapMetaData{Version: 2, TotalLayerArea: area},
+		Size:      Size{X: (simulatedOrigin*2 + 200) * simulatedPixelSize, Y: (simulatedOrigin*2 + 120) * simulatedPixelSize},
+		PixelSize: simulatedPixelSize,
+		Layers:    s.layers,
+		Entities: []MapEntity{
+			{Class: "PointMapEntity", Type: "charger_location", Points: mm(dock), MetaData: map[string]interface{}{}},
+			{Class: "PointMapEntity", Type: "robot_position", Points: mm(pos), MetaData: map[string]interface{}{"angle": angle}},
+		},
+	}
+}
+
+// simulatedLayers returns the segment and wall layers of the simulated
+// home. The doorways in the interior walls belong to the room west or north
+// of them.
+func simulatedLayers() []MapLayer {
+	isWall := func(x, y int) bool {
+		switch {
+		case x == 100:
+			return !(y >= 20 && y < 36) && !(y >= 80 && y < 96)
+		case y == 60 && x > 100:
+			return !(x >= 140 && x < 156)
+		}
+		return false
+	}
+	roomOf := func(x, y int) int {
+		switch {
+		case x <= 100:
+			return 0
+		case y <= 60:
+			return 1
+		}
+		return 2
+	}
+
+	segments := make([][]int, len(simulatedRooms))
+	var walls []int
+	for y := -1; y <= 120; y++ {
+		for x := -1; x <= 200; x++ {
+			px, py := x+simulatedOrigin, y+simulatedOrigin
+			if x < 0 || x >= 200 || y < 0 || y >= 120 || isWall(x, y) {
+				walls = append(walls, px, py)
+				continue
+			}
+			r := roomOf(x, y)
+			segments[r] = append(segments[r], px, py)
+		}
+	}
+
+	var layers []MapLayer
+	for i, room := range simulatedRooms {
+		layers = append(layers, MapLayer{
+			Class:    "MapLayer",
+			Type:     "segment",
+			Pixels:   segments[i],
+			MetaData: LayerMetaData{SegmentID: room.id, Name: room.name, Area: len(segments[i]) / 2 * simulatedPixelSize * simulatedPixelSize, PixelCount: len(segments[i]) / 2},
+		})
+	}
+	layers = append(layers, MapLayer{
+		Class:    "MapLayer",
+		Type:     "wall",
+		Pixels:   walls,
+		MetaData: LayerMetaData{PixelCount: len(walls) / 2},
+	})
+	return layers
+}
+
+// lawnmowerPath returns the cells a robot passes cleaning room in stripes,
+// from its dock in the north-west corner and back the same way, so the path
+// can be followed in a loop.
+func lawnmowerPath(room simulatedRoom) []Point {
+	var xs []int
+	for x := room.x0 + simulatedRoomMargin; x < room.x1-simulatedRoomMargin; x += simulatedStep {
+		xs = append(xs, x)
+	}
+	var out []Point
+	for i, y := 0, room.y0+simulatedRoomMargin; y < room.y1-simulatedRoomMargin; i, y = i+1, y+simulatedStripe {
+		for j := range xs {
+			x := xs[j]
+			if i%2 == 1 {
+				x = xs[len(xs)-1-j]
+			}
+			out = append(out, Point{X: float64(x), Y: float64(y)})
+		}
+	}
+	path := append([]Point(nil), out...)
+	for i := len(out) - 2; i > 0; i-- {
+		path = append(path, out[i])
+	}
+	return path
+}
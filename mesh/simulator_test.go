@@ -0,0 +1,119 @@
+package mesh
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSimulator_Bounds(t *testing.T) {
+	for _, n := range []int{0, -1, MaxSimulatedVacuums + 1} {
+		if _, err := NewSimulator(n); err == nil {
+			t.Errorf("NewSimulator(%d) succeeded", n)
+		}
+	}
+	s, err := NewSimulator(MaxSimulatedVacuums)
+	if err != nil {
+		t.Fatalf("NewSimulator(%d): %v", MaxSimulatedVacuums, err)
+	}
+	seen := make(map[string]bool)
+	for _, vc := range s.Vacuums() {
+		if seen[vc.ID] || seen[vc.Topic] || vc.Color == "" {
+			t.Errorf("vacuum %+v repeats an ID or topic or has no color", vc)
+		}
+		seen[vc.ID], seen[vc.Topic] = true, true
+	}
+}
+
+func TestSimulator_MessagesAreSaneMovingMaps(t *testing.T) {
+	s, err := NewSimulator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var previous [3]Point
+	for step := 0; step < 3; step++ {
+		msgs, err := s.Messages(step)
+		if err != nil {
+			t.Fatalf("Messages(%d): %v", step, err)
+		}
+		if len(msgs) != 6 {
+			t.Fatalf("step %d: %d messages, want a map and a battery level per vacuum", step, len(msgs))
+		}
+		maps := 0
+		for _, msg := range msgs {
+			if strings.HasSuffix(msg.Topic, "/BatteryStateAttribute/level") {
+				if level, ok := parseBatteryLevel(msg.Payload); !ok || level < 30 || level > 100 {
+					t.Errorf("battery payload %q", msg.Payload)
+				}
+				continue
+			}
+			m, err := DecodeMapData(msg.Payload)
+			if err != nil {
+				t.Fatalf("decoding %s: %v", msg.Topic, err)
+			}
+			if err := CheckMapSanity(m, m); err != nil {
+				t.Errorf("%s: map not sane: %v", msg.VacuumID, err)
+			}
+			if segments := ExtractSegments(m); len(segments) != 3 {
+				t.Errorf("%s: %d segments, want 3", msg.VacuumID, len(segments))
+			}
+			pos, _, ok := ExtractRobotPosition(m)
+			if !ok {
+				t.Fatalf("%s: no robot position", msg.VacuumID)
+			}
+			if step > 0 && pos == previous[maps] {
+				t.Errorf("%s: robot did not move at step %d", msg.VacuumID, step)
+			}
+			previous[maps] = pos
+			maps++
+		}
+	}
+}
+
+func TestSimulator_RunPublishesUntilCancelled(t *testing.T) {
+	s, err := NewSimulator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var topics []string
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, time.Millisecond, func(msg RecordedMessage) {
+			mu.Lock()
+			defer mu.Unlock()
+			topics = append(topics, msg.Topic)
+			if len(topics) == 4 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	if topics[0] != "valetudo/sim1/MapData/map-data" {
+		t.Errorf("first topic = %s", topics[0])
+	}
+}
+
+func TestLawnmowerPath_Loops(t *testing.T) {
+	for _, room := range simulatedRooms {
+		path := lawnmowerPath(room)
+		for i, p := range path {
+			next := path[(i+1)%len(path)]
+			if d := (next.X-p.X)*(next.X-p.X) + (next.Y-p.Y)*(next.Y-p.Y); d > simulatedStripe*simulatedStripe {
+				t.Fatalf("%s: jump from %v to %v", room.name, p, next)
+			}
+			if int(p.X) < room.x0 || int(p.X) >= room.x1 || int(p.Y) < room.y0 || int(p.Y) >= room.y1 {
+				t.Fatalf("%s: %v outside the room", room.name, p)
+			}
+		}
+	}
+}
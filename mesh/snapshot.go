@@ -0,0 +1,142 @@
+package mesh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// DefaultStateFile is the name of the StateTracker snapshot FileStore keeps
+// in the data directory.
+const DefaultStateFile = "state.json"
+
+// StateSnapshot is the live state of a StateTracker kept across restarts:
+// what the robots last reported rather than what can be rebuilt from the
+// stored maps, which the Store keeps.
+type StateSnapshot struct {
+	SavedAt   time.Time                `json:"savedAt"`
+	Positions map[string]*LivePosition `json:"positions,omitempty"`
+	Tracks    map[string][]TrackPoint  `json:"tracks,omitempty"`
+	Batteries map[string]int           `json:"batteries,omitempty"`
+	Active    map[string]ActiveArea    `json:"active,omitempty"`
+	Colors    map[string]string        `json:"colors,omitempty"`
+	Names     map[string]string        `json:"names,omitempty"`
+	LastSeen  map[string]time.Time     `json:"lastSeen,omitempty"` // last message per vacuum, for /health
+}
+
+// Snapshot returns a copy of the tracker's live state.
+func (st *StateTracker) Snapshot() *StateSnapshot {
+	s := &StateSnapshot{
+		SavedAt:   time.Now().UTC(),
+		Positions: st.GetPositions(),
+		Tracks:    st.GetTracks(time.Time{}),
+		Active:    st.GetActiveAreas(),
+		LastSeen:  st.health.LastSeen(),
+	}
+
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	s.Batteries = make(map[string]int, len(st.batteries))
+	for id, level := range st.batteries {
+		s.Batteries[id] = level
+	}
+	s.Colors = make(map[string]string, len(st.colors))
+	for id, c := range st.colors {
+		s.Colors[id] = c
+	}
+	s.Names = make(map[string]string, len(st.names))
+	for id, name := range st.names {
+		s.Names[id] = name
+	}
+	return s
+}
+
+// Restore loads a snapshot taken by Snapshot. Positions, tracks, battery
+// levels and active areas are replaced by the snapshot's, which is newer
+// than positions derived from stored maps at startup. Colors and display
+// names only fill in vacuums that have none, so the config wins.
+func (st *StateTracker) Restore(s *StateSnapshot) {
+	if s == nil {
+		return
+	}
+	for id, t := range s.LastSeen {
+		st.health.RecordMessage(id, t)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, c := range s.Colors {
+		if st.colors[id] == "" {
+			st.colors[id] = c
+		}
+	}
+	for id, name := range s.Names {
+		if st.names[id] == "" {
+			st.names[id] = name
+		}
+	}
+	for id, level := range s.Batteries {
+		st.batteries[id] = level
+	}
+	for id, area := range s.Active {
+		if !area.Empty() {
+			st.active[id] = area
+		}
+	}
+	for id, track := range s.Tracks {
+		if len(track) > DefaultTrackLength {
+			track = track[len(track)-DefaultTrackLength:]
+		}
+		st.tracks[id] = append([]TrackPoint(nil), track...)
+	}
+	for id, pos := range s.Positions {
+		if pos == nil {
+			continue
+		}
+		p := *pos
+		p.VacuumID = id
+		if c := st.colors[id]; c != "" {
+			p.Color = c
+		}
+		p.DisplayName = st.names[id]
+		p.Battery = nil
+		if level, ok := st.batteries[id]; ok {
+			p.Battery = &level
+		}
+		st.positions[id] = &p
+	}
+}
+
+// SaveStateSnapshot writes the tracker's live state to path.
+func (st *StateTracker) SaveStateSnapshot(path string) error {
+	return st.Snapshot().Save(path)
+}
+
+// Save writes the snapshot to path as JSON.
+func (s *StateSnapshot) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal state snapshot: %w", err)
+	}
+	return WriteFileAtomic(path, data, 0o644)
+}
+
+// LoadStateSnapshot reads a snapshot written by Save. A missing
+// file yields nil and no error.
+func LoadStateSnapshot(path string) (*StateSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s StateSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,64 @@
+package mesh
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateSnapshot_SaveAndRestore(t *testing.T) {
+	st := NewStateTracker()
+	st.SetColor("vac1", "#00FF00")
+	st.SetDisplayName("vac1", "Rocky")
+	st.UpdatePosition("vac1", 10, 20, 90)
+	st.UpdatePosition("vac1", 11, 20, 90)
+	st.UpdateBattery("vac1", 42)
+	st.SetActiveArea("vac1", ActiveArea{SegmentIDs: []string{"3"}})
+	seen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.Health().RecordMessage("vac1", seen)
+
+	path := filepath.Join(t.TempDir(), DefaultStateFile)
+	if err := st.SaveStateSnapshot(path); err != nil {
+		t.Fatalf("SaveStateSnapshot: %v", err)
+	}
+	snapshot, err := LoadStateSnapshot(path)
+	if err != nil || snapshot == nil {
+		t.Fatalf("LoadStateSnapshot = %v, %v", snapshot, err)
+	}
+
+	// The config of the restarted service names the vacuum differently
+	restored := NewStateTracker()
+	restored.SetDisplayName("vac1", "Rocky 2")
+	restored.Restore(snapshot)
+
+	pos := restored.GetPositions()["vac1"]
+	if pos == nil {
+		t.Fatal("position not restored")
+	}
+	if pos.X != 11 || pos.Y != 20 || pos.Angle != 90 || pos.Color != "#00FF00" {
+		t.Errorf("position = %+v, want (11, 20) at 90° in #00FF00", pos)
+	}
+	if pos.DisplayName != "Rocky 2" {
+		t.Errorf("display name = %q, want the configured Rocky 2", pos.DisplayName)
+	}
+	if pos.Battery == nil || *pos.Battery != 42 {
+		t.Errorf("battery = %v, want 42", pos.Battery)
+	}
+	if track := restored.GetTracks(time.Time{})["vac1"]; len(track) != 2 {
+		t.Errorf("track has %d points, want 2", len(track))
+	}
+	if area := restored.GetActiveAreas()["vac1"]; len(area.SegmentIDs) != 1 || area.SegmentIDs[0] != "3" {
+		t.Errorf("active area = %+v, want segment 3", area)
+	}
+	if got := restored.Health().LastSeen()["vac1"]; !got.Equal(seen) {
+		t.Errorf("last seen = %v, want %v", got, seen)
+	}
+}
+
+func TestLoadStateSnapshot_Missing(t *testing.T) {
+	snapshot, err := LoadStateSnapshot(filepath.Join(t.TempDir(), DefaultStateFile))
+	if err != nil || snapshot != nil {
+		t.Errorf("LoadStateSnapshot of a missing file = %v, %v; want nil, nil", snapshot, err)
+	}
+	NewStateTracker().Restore(nil)
+}
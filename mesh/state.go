@@ -6,36 +6,61 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
 // LivePosition represents a vacuum's current position in world coordinates
 type LivePosition struct {
-	VacuumID  string    `json:"vacuumId"`
-	X         float64   `json:"x"`
-	Y         float64   `json:"y"`
-	Angle     float64   `json:"angle"` // degrees, 0 = East, CCW
-	Timestamp time.Time `json:"timestamp"`
-	Color     string    `json:"color"` // hex color for this vacuum
+	VacuumID    string    `json:"vacuumId"`
+	X           float64   `json:"x"`
+	Y           float64   `json:"y"`
+	Angle       float64   `json:"angle"` // degrees, 0 = East, CCW
+	Timestamp   time.Time `json:"timestamp"`
+	Color       string    `json:"color"` // hex color for this vacuum
+	DisplayName string    `json:"displayName,omitempty"`
+	Battery     *int      `json:"battery,omitempty"` // percent; nil until the vacuum reports it
 }
 
 // StateTracker tracks live vacuum positions for HTTP endpoints
 type StateTracker struct {
 	mu         sync.RWMutex
 	positions  map[string]*LivePosition
+	tracks     map[string][]TrackPoint
+	batteries  map[string]int // vacuum ID -> battery percent
+	heatmap    *Heatmap
+	health     *HealthMonitor
+	active     map[string]ActiveArea
 	maps       map[string]*ValetudoMap
+	mapHashes  map[string]string // vacuum ID -> MapContentHash of the stored map
 	colors     map[string]string // vacuum ID -> hex color
+	names      map[string]string // vacuum ID -> display name
 	unifiedMap *UnifiedMap
-	cachePath  string // path to .unified-map.json cache file; empty disables persistence
+	store      Store // persists the unified map; nil disables persistence
+
+	segments   *SegmentIndex // of segmentsOf, built on first use
+	segmentsOf *UnifiedMap
+
+	segmentMerge SegmentMergeConfig
+	doubleWalls  DoubleWallConfig
+	changes      *ChangeFeed
 }
 
 // NewStateTracker creates a new state tracker
 func NewStateTracker() *StateTracker {
 	return &StateTracker{
 		positions: make(map[string]*LivePosition),
+		tracks:    make(map[string][]TrackPoint),
+		batteries: make(map[string]int),
+		heatmap:   NewHeatmap(),
+		health:    NewHealthMonitor(),
+		active:    make(map[string]ActiveArea),
 		maps:      make(map[string]*ValetudoMap),
+		mapHashes: make(map[string]string),
 		colors:    make(map[string]string),
+		names:     make(map[string]string),
+		changes:   NewChangeFeed(),
 	}
 }
 
@@ -43,20 +68,33 @@ func NewStateTracker() *StateTracker {
 // to the given cache file path. If the file exists, the cached unified map is
 // loaded on creation.
 func NewStateTrackerWithCache(cachePath string) *StateTracker {
-	st := &StateTracker{
-		positions: make(map[string]*LivePosition),
-		maps:      make(map[string]*ValetudoMap),
-		colors:    make(map[string]string),
-		cachePath: cachePath,
-	}
+	st := NewStateTracker()
 	if cachePath != "" {
-		if um, err := LoadUnifiedMap(cachePath); err == nil {
-			st.unifiedMap = um
-		}
+		st.SetStore(&FileStore{UnifiedMapPath: cachePath})
 	}
 	return st
 }
 
+// SetStore attaches a storage backend used to persist the unified map. If the
+// tracker has no unified map yet, the stored one (if any) is loaded.
+func (st *StateTracker) SetStore(store Store) {
+	var stored *UnifiedMap
+	if store != nil {
+		um, err := store.LoadUnifiedMap()
+		if err != nil {
+			log.Printf("warning: failed to load unified map from %s: %v", store, err)
+		}
+		stored = um
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.store = store
+	if st.unifiedMap == nil {
+		st.unifiedMap = stored
+	}
+}
+
 // SetColor sets the color for a vacuum
 func (st *StateTracker) SetColor(vacuumID, hexColor string) {
 	st.mu.Lock()
@@ -64,6 +102,29 @@ func (st *StateTracker) SetColor(vacuumID, hexColor string) {
 	st.colors[vacuumID] = hexColor
 }
 
+// SetSegmentMerge sets how UpdateUnifiedMap merges room segments that one
+// vacuum splits and another sees whole
+func (st *StateTracker) SetSegmentMerge(config SegmentMergeConfig) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.segmentMerge = config
+}
+
+// SetDoubleWalls sets how UpdateUnifiedMap collapses the two faces of a wall
+// seen from opposite sides.
+func (st *StateTracker) SetDoubleWalls(config DoubleWallConfig) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.doubleWalls = config
+}
+
+// SetDisplayName sets the friendly name reported with a vacuum's position
+func (st *StateTracker) SetDisplayName(vacuumID, name string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.names[vacuumID] = name
+}
+
 // UpdatePosition updates a vacuum's position
 func (st *StateTracker) UpdatePosition(vacuumID string, x, y, angle float64) {
 	st.mu.Lock()
@@ -74,21 +135,108 @@ func (st *StateTracker) UpdatePosition(vacuumID string, x, y, angle float64) {
 		color = "#FF0000" // default red
 	}
 
+	now := time.Now()
 	st.positions[vacuumID] = &LivePosition{
-		VacuumID:  vacuumID,
-		X:         x,
-		Y:         y,
-		Angle:     angle,
-		Timestamp: time.Now(),
-		Color:     color,
+		VacuumID:    vacuumID,
+		X:           x,
+		Y:           y,
+		Angle:       angle,
+		Timestamp:   now,
+		Color:       color,
+		DisplayName: st.names[vacuumID],
 	}
+	if level, ok := st.batteries[vacuumID]; ok {
+		st.positions[vacuumID].Battery = &level
+	}
+	st.tracks[vacuumID] = recordTrack(st.tracks[vacuumID], TrackPoint{X: x, Y: y, Angle: angle, Timestamp: now})
+
+	// Grid coordinates are scaled to world mm as for tracks
+	pixelSize := 5.0
+	if m := st.maps[vacuumID]; m != nil && m.PixelSize > 0 {
+		pixelSize = float64(m.PixelSize)
+	}
+	st.heatmap.Record(vacuumID, Point{X: x * pixelSize, Y: y * pixelSize}, now)
+}
+
+// UpdateBattery records a vacuum's battery level, in percent, and reports it
+// with the vacuum's position from now on
+func (st *StateTracker) UpdateBattery(vacuumID string, level int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.batteries[vacuumID] = level
+	if pos := st.positions[vacuumID]; pos != nil {
+		pos.Battery = &level
+	}
+}
+
+// SetHeatmap replaces the heatmap positions are counted in, such as with
+// one loaded from disk.
+func (st *StateTracker) SetHeatmap(h *Heatmap) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.heatmap = h
+}
+
+// Heatmap returns the heatmap of visited cells.
+func (st *StateTracker) Heatmap() *Heatmap {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.heatmap
+}
+
+// Health returns the monitor of messages and decoding failures per vacuum.
+func (st *StateTracker) Health() *HealthMonitor {
+	return st.health
 }
 
 // UpdateMap stores the latest map data for a vacuum
 func (st *StateTracker) UpdateMap(vacuumID string, m *ValetudoMap) {
+	hash := MapContentHash(m)
 	st.mu.Lock()
 	defer st.mu.Unlock()
 	st.maps[vacuumID] = m
+	st.mapHashes[vacuumID] = hash
+}
+
+// UpdateMapIfChanged stores a vacuum's map unless the stored one has the
+// same structural content (see MapContentHash), and reports whether it did.
+func (st *StateTracker) UpdateMapIfChanged(vacuumID string, m *ValetudoMap) bool {
+	hash := MapContentHash(m)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.maps[vacuumID]; ok && st.mapHashes[vacuumID] == hash {
+		return false
+	}
+	st.maps[vacuumID] = m
+	st.mapHashes[vacuumID] = hash
+	return true
+}
+
+// SetActiveArea records what a vacuum is cleaning and reports whether it
+// differs from what was recorded before.
+func (st *StateTracker) SetActiveArea(vacuumID string, area ActiveArea) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	prev, ok := st.active[vacuumID]
+	if area.Empty() {
+		delete(st.active, vacuumID)
+	} else {
+		st.active[vacuumID] = area
+	}
+	return !ok && !area.Empty() || ok && !prev.Equal(area)
+}
+
+// GetActiveAreas returns the areas vacuums are currently cleaning; vacuums
+// cleaning no particular area are omitted.
+func (st *StateTracker) GetActiveAreas() map[string]ActiveArea {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	result := make(map[string]ActiveArea, len(st.active))
+	for k, v := range st.active {
+		result[k] = v
+	}
+	return result
 }
 
 // GetPositions returns all current positions
@@ -104,6 +252,29 @@ func (st *StateTracker) GetPositions() map[string]*LivePosition {
 	return result
 }
 
+// GetTracks returns each vacuum's recorded positions at or after since,
+// oldest first. A zero since returns the whole track.
+func (st *StateTracker) GetTracks(since time.Time) map[string][]TrackPoint {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	result := make(map[string][]TrackPoint)
+	for id, track := range st.tracks {
+		start := sort.Search(len(track), func(i int) bool { return !track[i].Timestamp.Before(since) })
+		if start < len(track) {
+			result[id] = append([]TrackPoint(nil), track[start:]...)
+		}
+	}
+	return result
+}
+
+// GetMap returns the current map of vacuumID, or nil if there is none.
+func (st *StateTracker) GetMap(vacuumID string) *ValetudoMap {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.maps[vacuumID]
+}
+
 // GetMaps returns all current maps
 func (st *StateTracker) GetMaps() map[string]*ValetudoMap {
 	st.mu.RLock()
@@ -130,6 +301,53 @@ func (st *StateTracker) GetUnifiedMap() *UnifiedMap {
 	return st.unifiedMap
 }
 
+// SegmentIndex returns the hit-testing index of the current unified map's
+// segments, rebuilding it the first time it is needed after the map
+// changes.
+func (st *StateTracker) SegmentIndex() *SegmentIndex {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.segments == nil || st.segmentsOf != st.unifiedMap {
+		st.segments = NewSegmentIndex(st.unifiedMap)
+		st.segmentsOf = st.unifiedMap
+	}
+	return st.segments
+}
+
+// Changes returns the feed of unified map versions.
+func (st *StateTracker) Changes() *ChangeFeed {
+	return st.changes
+}
+
+// SetUnifiedMap replaces the unified map, e.g. with one replicated from
+// another instance, and persists it when a store is attached. The map keeps
+// the version it was published with; a newer version is announced on the
+// change feed.
+func (st *StateTracker) SetUnifiedMap(um *UnifiedMap) {
+	st.mu.Lock()
+	prev := st.unifiedMap
+	st.unifiedMap = um
+	store := st.store
+	st.mu.Unlock()
+
+	if um != nil {
+		change := DiffUnifiedMaps(prev, um)
+		change.Version = um.Metadata.Version
+		if prev != nil {
+			change.PreviousVersion = prev.Metadata.Version
+		}
+		if change.Version > change.PreviousVersion {
+			st.changes.Publish(change)
+		}
+	}
+
+	if store != nil && um != nil {
+		if err := store.SaveUnifiedMap(um); err != nil {
+			log.Printf("warning: failed to save unified map cache: %v", err)
+		}
+	}
+}
+
 // UpdateUnifiedMap rebuilds the unified map from all stored vacuum maps using
 // the provided calibration data. Each vacuum's map is vectorized and
 // transformed to world coordinates, then walls, floors, and segments are
@@ -138,20 +356,24 @@ func (st *StateTracker) GetUnifiedMap() *UnifiedMap {
 // If a previous unified map exists, incremental refinement is applied via
 // weighted averaging of geometry coordinates.
 //
-// The resulting unified map is persisted to the cache file when a cache path
-// is configured.
+// The resulting unified map is persisted when a store is attached.
 func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 	if calibData == nil {
 		return fmt.Errorf("calibration data is nil")
 	}
 
+	trace := StartTrace("unify")
+	defer trace.End()
+
 	st.mu.RLock()
 	maps := make(map[string]*ValetudoMap, len(st.maps))
 	for k, v := range st.maps {
 		maps[k] = v
 	}
+	segmentMerge := st.segmentMerge
+	doubleWalls := st.doubleWalls
 	previousMap := st.unifiedMap
-	cachePath := st.cachePath
+	store := st.store
 	st.mu.RUnlock()
 
 	if len(maps) == 0 {
@@ -161,10 +383,14 @@ func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 	totalVacuums := len(maps)
 
 	// Extract and transform features from each vacuum map into world coordinates.
+	trace.Step("features")
+	transforms := make(map[string]AffineMatrix, len(maps))
 	var allWallFeatures []*Feature
 	var allWallSources []FeatureSource
 	var allFloorFeatures []*Feature
 	var allFloorSources []FeatureSource
+	var allMaterialFeatures []*Feature
+	var allMaterialSources []FeatureSource
 
 	for vacuumID, vMap := range maps {
 		vc, ok := calibData.Vacuums[vacuumID]
@@ -172,6 +398,7 @@ func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 			// No calibration for this vacuum; use identity transform.
 			vc = VacuumCalibration{Transform: Identity()}
 		}
+		transforms[vacuumID] = vc.Transform
 
 		// Convert the vacuum map to a GeoJSON feature collection in world coordinates.
 		fc := MapToFeatureCollection(vMap, vacuumID, vc.Transform, 5.0)
@@ -194,29 +421,47 @@ func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 			case "floor", "segment":
 				allFloorFeatures = append(allFloorFeatures, f)
 				allFloorSources = append(allFloorSources, featureSrc)
+				if _, ok := f.Properties["material"]; ok {
+					allMaterialFeatures = append(allMaterialFeatures, f)
+					allMaterialSources = append(allMaterialSources, featureSrc)
+				}
 			}
 		}
 	}
 
 	// Unify walls.
+	trace.Step("walls")
 	unifiedWalls := UnifyWalls(
 		extractWallFeatures(allWallFeatures),
 		allWallSources,
 		totalVacuums,
 	)
+	unifiedWalls = CollapseDoubleWalls(unifiedWalls, totalVacuums, doubleWalls)
 
 	// Unify floors/segments.
+	trace.Step("floors")
 	unifiedFloors := UnifyFloors(
 		extractFloorFeatures(allFloorFeatures),
 		allFloorSources,
 		totalVacuums,
 	)
+	unifiedFloors = MergeContainedSegments(unifiedFloors, totalVacuums, segmentMerge)
+
+	// Unify floor materials.
+	trace.Step("materials")
+	unifiedMaterials := UnifyMaterials(
+		extractFloorFeatures(allMaterialFeatures),
+		allMaterialSources,
+		totalVacuums,
+	)
 
 	// Apply outlier detection.
+	trace.Step("outliers")
 	outlierCfg := DefaultOutlierConfig(totalVacuums)
 
 	retainedWalls, _ := DetectOutliers(unifiedWalls, outlierCfg)
 	retainedFloors, _ := DetectOutliers(unifiedFloors, outlierCfg)
+	retainedMaterials, _ := DetectOutliers(unifiedMaterials, outlierCfg)
 
 	// Separate floors from segments by checking properties.
 	var floors, segments []*UnifiedFeature
@@ -229,14 +474,20 @@ func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 		}
 	}
 
+	trace.Step("coverage")
+	coverage := ComputeCoverageStats(maps, transforms, calibData.ReferenceVacuum)
 	newMap := &UnifiedMap{
-		Walls:    retainedWalls,
-		Floors:   floors,
-		Segments: segments,
+		Walls:     retainedWalls,
+		Floors:    floors,
+		Segments:  segments,
+		Materials: retainedMaterials,
 		Metadata: UnifiedMetadata{
 			VacuumCount:     totalVacuums,
 			ReferenceVacuum: calibData.ReferenceVacuum,
 			LastUpdated:     time.Now().Unix(),
+			TotalArea:       coverage.TotalArea,
+			CoverageOverlap: coverage.CoverageOverlap,
+			Overlaps:        coverage.Overlaps,
 		},
 	}
 
@@ -250,27 +501,50 @@ func (st *StateTracker) UpdateUnifiedMap(calibData *CalibrationData) error {
 	if newMap.Segments == nil {
 		newMap.Segments = make([]*UnifiedFeature, 0)
 	}
+	if newMap.Materials == nil {
+		newMap.Materials = make([]*UnifiedFeature, 0)
+	}
 
-	// Incremental refinement: blend with previous map if available.
-	if previousMap != nil {
+	// Incremental refinement: blend with previous map if available. A map
+	// built against another reference is in another frame and is replaced.
+	trace.Step("refine")
+	if previousMap != nil && previousMap.Metadata.ReferenceVacuum == calibData.ReferenceVacuum {
 		newMap.Walls = refineFeatures(previousMap.Walls, newMap.Walls)
 		newMap.Floors = refineFeatures(previousMap.Floors, newMap.Floors)
 		newMap.Segments = refineFeatures(previousMap.Segments, newMap.Segments)
+		newMap.Materials = refineFeatures(previousMap.Materials, newMap.Materials)
 	}
 
 	// Apply geometry simplification.
+	trace.Step("simplify")
 	simplifyUnifiedFeatures(newMap.Walls, DefaultWallSimplifyTolerance)
 	simplifyUnifiedFeatures(newMap.Floors, DefaultFloorSimplifyTolerance)
 	simplifyUnifiedFeatures(newMap.Segments, DefaultFloorSimplifyTolerance)
+	simplifyUnifiedFeatures(newMap.Materials, DefaultFloorSimplifyTolerance)
 
-	// Store the unified map.
+	// Store the unified map, with a new version only if the geometry
+	// materially changed.
+	trace.Step("store")
 	st.mu.Lock()
+	change := DiffUnifiedMaps(st.unifiedMap, newMap)
+	if st.unifiedMap != nil {
+		change.PreviousVersion = st.unifiedMap.Metadata.Version
+	}
+	change.Version = change.PreviousVersion
+	if change.Material() {
+		change.Version++
+	}
+	newMap.Metadata.Version = change.Version
 	st.unifiedMap = newMap
 	st.mu.Unlock()
 
-	// Persist to cache.
-	if cachePath != "" {
-		if err := SaveUnifiedMap(newMap, cachePath); err != nil {
+	if change.Version > change.PreviousVersion {
+		st.changes.Publish(change)
+	}
+
+	// Persist to store.
+	if store != nil {
+		if err := store.SaveUnifiedMap(newMap); err != nil {
 			log.Printf("warning: failed to save unified map cache: %v", err)
 		}
 	}
@@ -288,7 +562,7 @@ func SaveUnifiedMap(um *UnifiedMap, path string) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("create cache directory: %w", err)
 	}
-	if err := os.WriteFile(path, data, 0o644); err != nil {
+	if err := WriteFileAtomic(path, data, 0o644); err != nil {
 		return fmt.Errorf("write unified map cache: %w", err)
 	}
 	return nil
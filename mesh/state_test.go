@@ -47,6 +47,46 @@ func TestStateTracker_SetColor(t *testing.T) {
 	}
 }
 
+func TestStateTracker_SetDisplayName(t *testing.T) {
+	st := NewStateTracker()
+
+	st.SetDisplayName("vac-a", "Kitchen")
+	st.UpdatePosition("vac-a", 10, 20, 45)
+	st.UpdatePosition("vac-b", 10, 20, 45)
+
+	positions := st.GetPositions()
+	if got := positions["vac-a"].DisplayName; got != "Kitchen" {
+		t.Errorf("DisplayName = %q, want %q", got, "Kitchen")
+	}
+	if got := positions["vac-b"].DisplayName; got != "" {
+		t.Errorf("DisplayName without config = %q, want empty", got)
+	}
+}
+
+func TestStateTracker_UpdateBattery(t *testing.T) {
+	st := NewStateTracker()
+
+	st.UpdateBattery("vac1", 80)
+	st.UpdatePosition("vac1", 1, 2, 0)
+	if pos := st.GetPositions()["vac1"]; pos.Battery == nil || *pos.Battery != 80 {
+		t.Fatalf("Battery = %v, want 80 reported with the position", pos.Battery)
+	}
+
+	before := st.GetPositions()["vac1"]
+	st.UpdateBattery("vac1", 15)
+	if pos := st.GetPositions()["vac1"]; *pos.Battery != 15 {
+		t.Errorf("Battery after update = %d, want 15", *pos.Battery)
+	}
+	if *before.Battery != 80 {
+		t.Errorf("earlier copy changed to %d", *before.Battery)
+	}
+
+	st.UpdatePosition("vac2", 0, 0, 0)
+	if pos := st.GetPositions()["vac2"]; pos.Battery != nil {
+		t.Errorf("Battery of a vacuum that never reported = %d, want nil", *pos.Battery)
+	}
+}
+
 func TestStateTracker_UpdatePosition(t *testing.T) {
 	st := NewStateTracker()
 
@@ -126,6 +166,34 @@ func TestStateTracker_UpdateMap(t *testing.T) {
 	}
 }
 
+func TestStateTracker_UpdateMapIfChanged(t *testing.T) {
+	st := NewStateTracker()
+	m := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: []int{1, 1}}}}
+	if !st.UpdateMapIfChanged("vac-a", m) {
+		t.Error("first map should be stored")
+	}
+
+	moved := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: []int{1, 1}}},
+		Entities: []MapEntity{{Type: "robot_position", Points: []int{50, 50}}}}
+	if st.UpdateMapIfChanged("vac-a", moved) {
+		t.Error("a map that only moved the robot should be reported unchanged")
+	}
+	if st.GetMaps()["vac-a"] != m {
+		t.Error("unchanged map should not replace the stored one")
+	}
+
+	grown := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: []int{1, 1, 2, 1}}}}
+	if !st.UpdateMapIfChanged("vac-a", grown) || st.GetMaps()["vac-a"] != grown {
+		t.Error("a map with new floor should be stored")
+	}
+
+	// UpdateMap records the hash too
+	st.UpdateMap("vac-b", m)
+	if st.UpdateMapIfChanged("vac-b", moved) {
+		t.Error("map loaded with UpdateMap should dedupe later updates")
+	}
+}
+
 func TestStateTracker_GetMaps(t *testing.T) {
 	st := NewStateTracker()
 
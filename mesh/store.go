@@ -0,0 +1,431 @@
+package mesh
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Storage backend names accepted in config.yaml.
+const (
+	StorageBackendFile   = "file"
+	StorageBackendSQLite = "sqlite"
+	StorageBackendMemory = "memory"
+)
+
+// DefaultUnifiedMapFile is the file name used by FileStore for the unified map.
+const DefaultUnifiedMapFile = ".unified-map.json"
+
+// DefaultSQLiteFile is the database file name used when no sqlite path is configured.
+const DefaultSQLiteFile = "tudomesh.db"
+
+// mapExportPrefix is the file name prefix of Valetudo map exports on disk.
+const mapExportPrefix = "ValetudoMapExport-"
+
+// Store persists calibration data, per-vacuum maps and the unified map.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// LoadCalibration returns the stored calibration, or nil if none exists yet.
+	LoadCalibration() (*CalibrationData, error)
+	// SaveCalibration stores the calibration stamped with the current time
+	// as its LastUpdated, leaving cal itself unchanged.
+	SaveCalibration(cal *CalibrationData) error
+	// LoadMaps returns all stored vacuum maps keyed by vacuum ID.
+	LoadMaps() (map[string]*ValetudoMap, error)
+	// SaveMap stores the latest map for a vacuum.
+	SaveMap(vacuumID string, m *ValetudoMap) error
+	// LoadUnifiedMap returns the stored unified map, or nil if none exists yet.
+	LoadUnifiedMap() (*UnifiedMap, error)
+	// SaveUnifiedMap stores the unified map.
+	SaveUnifiedMap(um *UnifiedMap) error
+	// LoadHeatmap returns the stored heatmap, or nil if none exists yet.
+	LoadHeatmap() (*Heatmap, error)
+	// SaveHeatmap stores the heatmap.
+	SaveHeatmap(h *Heatmap) error
+	// LoadState returns the stored StateTracker snapshot, or nil if none
+	// exists yet.
+	LoadState() (*StateSnapshot, error)
+	// SaveState stores a StateTracker snapshot.
+	SaveState(s *StateSnapshot) error
+	// Close releases any resources held by the store.
+	Close() error
+	// String describes the store for logging.
+	String() string
+}
+
+// OpenStore creates the storage backend selected in the config. The file
+// backend keeps the historical layout: map exports in dataDir and the
+// calibration cache at calibrationPath.
+func OpenStore(cfg StorageConfig, dataDir, calibrationPath string) (Store, error) {
+	switch cfg.Backend {
+	case "", StorageBackendFile:
+		fs := NewFileStore(dataDir, calibrationPath)
+		fs.Compress = cfg.Compress
+		fs.CalibrationHistory = cfg.CalibrationHistory
+		return fs, nil
+	case StorageBackendMemory:
+		return NewMemoryStore(), nil
+	case StorageBackendSQLite:
+		path := cfg.Path
+		if path == "" {
+			path = filepath.Join(dataDir, DefaultSQLiteFile)
+		}
+		return NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (must be file, sqlite, or memory)", cfg.Backend)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FileStore
+// ---------------------------------------------------------------------------
+
+// FileStore keeps state as JSON files on the local filesystem. This is the
+// default backend and matches the layout used before storage was pluggable.
+type FileStore struct {
+	MapDir             string         // directory for ValetudoMapExport-*.json; empty disables map persistence
+	CalibrationPath    string         // calibration cache file; empty disables calibration persistence
+	UnifiedMapPath     string         // unified map cache file; empty disables unified map persistence
+	HeatmapPath        string         // heatmap file; empty disables heatmap persistence
+	StatePath          string         // StateTracker snapshot file; empty disables state persistence
+	Compress           bool           // save maps gzip compressed as ValetudoMapExport-*.json.gz
+	CalibrationHistory int            // previous calibration caches kept as backups (0 = DefaultCalibrationHistory, negative = none)
+	Exports            *ExportPattern // recognizes exports named by other tools; nil accepts only ValetudoMapExport-*
+}
+
+// NewFileStore creates a FileStore rooted at dataDir. The unified map, the
+// heatmap and the state snapshot are kept next to the maps in dataDir.
+func NewFileStore(dataDir, calibrationPath string) *FileStore {
+	fs := &FileStore{
+		MapDir:          dataDir,
+		CalibrationPath: calibrationPath,
+	}
+	if dataDir != "" {
+		fs.UnifiedMapPath = filepath.Join(dataDir, DefaultUnifiedMapFile)
+		fs.HeatmapPath = filepath.Join(dataDir, DefaultHeatmapFile)
+		fs.StatePath = filepath.Join(dataDir, DefaultStateFile)
+	}
+	return fs
+}
+
+// LoadCalibration reads the calibration cache file.
+func (s *FileStore) LoadCalibration() (*CalibrationData, error) {
+	if s.CalibrationPath == "" {
+		return nil, nil
+	}
+	return LoadCalibration(s.CalibrationPath)
+}
+
+// SaveCalibration writes the calibration cache file, keeping previous
+// versions as backups per CalibrationHistory.
+func (s *FileStore) SaveCalibration(cal *CalibrationData) error {
+	if s.CalibrationPath == "" {
+		return nil
+	}
+	return SaveCalibrationHistory(s.CalibrationPath, cal, s.CalibrationBackups())
+}
+
+// CalibrationBackups returns how many previous calibration caches are kept.
+func (s *FileStore) CalibrationBackups() int {
+	switch {
+	case s.CalibrationHistory == 0:
+		return DefaultCalibrationHistory
+	case s.CalibrationHistory < 0:
+		return 0
+	}
+	return s.CalibrationHistory
+}
+
+// LoadMaps parses every map export in MapDir: ValetudoMapExport-*.json and
+// *.json.gz, plus files matching Exports. Files that fail to parse are
+// logged and skipped.
+func (s *FileStore) LoadMaps() (map[string]*ValetudoMap, error) {
+	maps := make(map[string]*ValetudoMap)
+	if s.MapDir == "" {
+		return maps, nil
+	}
+
+	files, err := s.Exports.Find(s.MapDir)
+	if err != nil && !os.IsNotExist(err) {
+		return maps, fmt.Errorf("listing map exports: %w", err)
+	}
+
+	for _, file := range files {
+		name, _ := s.Exports.VacuumID(file)
+		m, err := ParseMapFile(file)
+		if err != nil {
+			log.Printf("Warning: Failed to load %s: %v", name, err)
+			continue
+		}
+		maps[name] = m
+	}
+	return maps, nil
+}
+
+// SaveMap atomically writes the map to MapDir/ValetudoMapExport-{vacuumID}.json,
+// or to .json.gz with Compress. The export in the other format is removed so
+// a stale copy is never loaded.
+func (s *FileStore) SaveMap(vacuumID string, m *ValetudoMap) error {
+	if s.MapDir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling map for %s: %w", vacuumID, err)
+	}
+	path := filepath.Join(s.MapDir, fmt.Sprintf("%s%s.json", mapExportPrefix, vacuumID))
+	stale := path + ".gz"
+	if s.Compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("compressing map for %s: %w", vacuumID, err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compressing map for %s: %w", vacuumID, err)
+		}
+		data = buf.Bytes()
+		path, stale = stale, path
+	}
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("writing map for %s: %w", vacuumID, err)
+	}
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove old map export %s: %v", stale, err)
+	}
+	return nil
+}
+
+// LoadUnifiedMap reads the unified map cache file.
+func (s *FileStore) LoadUnifiedMap() (*UnifiedMap, error) {
+	if s.UnifiedMapPath == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(s.UnifiedMapPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return LoadUnifiedMap(s.UnifiedMapPath)
+}
+
+// SaveUnifiedMap writes the unified map cache file.
+func (s *FileStore) SaveUnifiedMap(um *UnifiedMap) error {
+	if s.UnifiedMapPath == "" {
+		return nil
+	}
+	return SaveUnifiedMap(um, s.UnifiedMapPath)
+}
+
+// LoadHeatmap reads the heatmap file.
+func (s *FileStore) LoadHeatmap() (*Heatmap, error) {
+	if s.HeatmapPath == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(s.HeatmapPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return LoadHeatmap(s.HeatmapPath)
+}
+
+// SaveHeatmap writes the heatmap file.
+func (s *FileStore) SaveHeatmap(h *Heatmap) error {
+	if s.HeatmapPath == "" {
+		return nil
+	}
+	return h.Save(s.HeatmapPath)
+}
+
+// LoadState reads the state snapshot file.
+func (s *FileStore) LoadState() (*StateSnapshot, error) {
+	if s.StatePath == "" {
+		return nil, nil
+	}
+	return LoadStateSnapshot(s.StatePath)
+}
+
+// SaveState writes the state snapshot file.
+func (s *FileStore) SaveState(snapshot *StateSnapshot) error {
+	if s.StatePath == "" {
+		return nil
+	}
+	return snapshot.Save(s.StatePath)
+}
+
+// Close is a no-op for the filesystem backend.
+func (s *FileStore) Close() error { return nil }
+
+// String implements fmt.Stringer.
+func (s *FileStore) String() string {
+	return fmt.Sprintf("file(maps=%s, calibration=%s)", s.MapDir, s.CalibrationPath)
+}
+
+// ---------------------------------------------------------------------------
+// MemoryStore
+// ---------------------------------------------------------------------------
+
+// MemoryStore keeps state in process memory only. Nothing survives a restart,
+// which makes it suitable for read-only deployments and tests.
+// Values are stored as JSON so callers cannot mutate stored state through
+// shared pointers.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	calibration []byte
+	maps        map[string][]byte
+	unifiedMap  []byte
+	heatmap     []byte
+	state       []byte
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{maps: make(map[string][]byte)}
+}
+
+// LoadCalibration returns a copy of the stored calibration.
+func (s *MemoryStore) LoadCalibration() (*CalibrationData, error) {
+	s.mu.RLock()
+	data := s.calibration
+	s.mu.RUnlock()
+	if data == nil {
+		return nil, nil
+	}
+	var cal CalibrationData
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return nil, fmt.Errorf("parsing calibration: %w", err)
+	}
+	return &cal, nil
+}
+
+// SaveCalibration stores a copy of the calibration.
+func (s *MemoryStore) SaveCalibration(cal *CalibrationData) error {
+	stamped := *cal
+	stamped.LastUpdated = time.Now().Unix()
+	data, err := json.Marshal(&stamped)
+	if err != nil {
+		return fmt.Errorf("marshaling calibration data: %w", err)
+	}
+	s.mu.Lock()
+	s.calibration = data
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadMaps returns copies of all stored maps.
+func (s *MemoryStore) LoadMaps() (map[string]*ValetudoMap, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	maps := make(map[string]*ValetudoMap, len(s.maps))
+	for id, data := range s.maps {
+		m, err := ParseMapJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("map %s: %w", id, err)
+		}
+		maps[id] = m
+	}
+	return maps, nil
+}
+
+// SaveMap stores a copy of the map.
+func (s *MemoryStore) SaveMap(vacuumID string, m *ValetudoMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshaling map for %s: %w", vacuumID, err)
+	}
+	s.mu.Lock()
+	s.maps[vacuumID] = data
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadUnifiedMap returns a copy of the stored unified map.
+func (s *MemoryStore) LoadUnifiedMap() (*UnifiedMap, error) {
+	s.mu.RLock()
+	data := s.unifiedMap
+	s.mu.RUnlock()
+	if data == nil {
+		return nil, nil
+	}
+	var um UnifiedMap
+	if err := json.Unmarshal(data, &um); err != nil {
+		return nil, fmt.Errorf("unmarshal unified map: %w", err)
+	}
+	return &um, nil
+}
+
+// SaveUnifiedMap stores a copy of the unified map.
+func (s *MemoryStore) SaveUnifiedMap(um *UnifiedMap) error {
+	data, err := json.Marshal(um)
+	if err != nil {
+		return fmt.Errorf("marshal unified map: %w", err)
+	}
+	s.mu.Lock()
+	s.unifiedMap = data
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadHeatmap returns a copy of the stored heatmap.
+func (s *MemoryStore) LoadHeatmap() (*Heatmap, error) {
+	s.mu.RLock()
+	data := s.heatmap
+	s.mu.RUnlock()
+	if data == nil {
+		return nil, nil
+	}
+	h := NewHeatmap()
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("parsing heatmap: %w", err)
+	}
+	return h, nil
+}
+
+// SaveHeatmap stores a copy of the heatmap.
+func (s *MemoryStore) SaveHeatmap(h *Heatmap) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("marshaling heatmap: %w", err)
+	}
+	s.mu.Lock()
+	s.heatmap = data
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadState returns a copy of the stored state snapshot.
+func (s *MemoryStore) LoadState() (*StateSnapshot, error) {
+	s.mu.RLock()
+	data := s.state
+	s.mu.RUnlock()
+	if data == nil {
+		return nil, nil
+	}
+	var snapshot StateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parsing state snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// SaveState stores a copy of the state snapshot.
+func (s *MemoryStore) SaveState(snapshot *StateSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal state snapshot: %w", err)
+	}
+	s.mu.Lock()
+	s.state = data
+	s.mu.Unlock()
+	return nil
+}
+
+// Close is a no-op for the in-memory backend.
+func (s *MemoryStore) Close() error { return nil }
+
+// String implements fmt.Stringer.
+func (s *MemoryStore) String() string { return "memory" }
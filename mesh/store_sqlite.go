@@ -0,0 +1,223 @@
+package mesh
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, keeps CGO_ENABLED=0 builds working
+)
+
+// Keys used by SQLiteStore in its key/value table.
+const (
+	sqliteKeyCalibration = "calibration"
+	sqliteKeyUnifiedMap  = "unified-map"
+	sqliteKeyHeatmap     = "heatmap"
+	sqliteKeyState       = "state"
+	sqliteKeyMapPrefix   = "map/"
+)
+
+// SQLiteStore keeps state in a single SQLite database file. This lets
+// tudomesh run in a read-only container with only the database on a
+// writable mount.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// NewSQLiteStore opens (creating if needed) the SQLite database at path.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("creating sqlite directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite store %s: %w", path, err)
+	}
+	// SQLite serialises writers; a single connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	const schema = `CREATE TABLE IF NOT EXISTS state (
+		key        TEXT PRIMARY KEY,
+		value      BLOB NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initializing sqlite store %s: %w", path, err)
+	}
+
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// get returns the value stored under key, or nil if absent.
+func (s *SQLiteStore) get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM state WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// put upserts value under key.
+func (s *SQLiteStore) put(key string, value []byte) error {
+	_, err := s.db.Exec(
+		`INSERT INTO state (key, value, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	return nil
+}
+
+// LoadCalibration reads the calibration row.
+func (s *SQLiteStore) LoadCalibration() (*CalibrationData, error) {
+	data, err := s.get(sqliteKeyCalibration)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var cal CalibrationData
+	if err := json.Unmarshal(data, &cal); err != nil {
+		return nil, fmt.Errorf("parsing calibration: %w", err)
+	}
+	return &cal, nil
+}
+
+// SaveCalibration writes the calibration row.
+func (s *SQLiteStore) SaveCalibration(cal *CalibrationData) error {
+	stamped := *cal
+	stamped.LastUpdated = time.Now().Unix()
+	data, err := json.Marshal(&stamped)
+	if err != nil {
+		return fmt.Errorf("marshaling calibration data: %w", err)
+	}
+	return s.put(sqliteKeyCalibration, data)
+}
+
+// LoadMaps reads every stored vacuum map, skipping rows that cannot be
+// read or parsed.
+func (s *SQLiteStore) LoadMaps() (map[string]*ValetudoMap, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM state WHERE key LIKE ?`, sqliteKeyMapPrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("listing maps: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	maps := make(map[string]*ValetudoMap)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			log.Printf("Warning: Failed to read map row: %v", err)
+			continue
+		}
+		id := strings.TrimPrefix(key, sqliteKeyMapPrefix)
+		m, err := ParseMapJSON(value)
+		if err != nil {
+			log.Printf("Warning: Failed to load %s: %v", id, err)
+			continue
+		}
+		maps[id] = m
+	}
+	return maps, rows.Err()
+}
+
+// SaveMap writes the map row for a vacuum.
+func (s *SQLiteStore) SaveMap(vacuumID string, m *ValetudoMap) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("marshaling map for %s: %w", vacuumID, err)
+	}
+	return s.put(sqliteKeyMapPrefix+vacuumID, data)
+}
+
+// LoadUnifiedMap reads the unified map row.
+func (s *SQLiteStore) LoadUnifiedMap() (*UnifiedMap, error) {
+	data, err := s.get(sqliteKeyUnifiedMap)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var um UnifiedMap
+	if err := json.Unmarshal(data, &um); err != nil {
+		return nil, fmt.Errorf("unmarshal unified map: %w", err)
+	}
+	return &um, nil
+}
+
+// SaveUnifiedMap writes the unified map row.
+func (s *SQLiteStore) SaveUnifiedMap(um *UnifiedMap) error {
+	data, err := json.Marshal(um)
+	if err != nil {
+		return fmt.Errorf("marshal unified map: %w", err)
+	}
+	return s.put(sqliteKeyUnifiedMap, data)
+}
+
+// LoadHeatmap reads the heatmap row.
+func (s *SQLiteStore) LoadHeatmap() (*Heatmap, error) {
+	data, err := s.get(sqliteKeyHeatmap)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	h := NewHeatmap()
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("parsing heatmap: %w", err)
+	}
+	return h, nil
+}
+
+// SaveHeatmap writes the heatmap row.
+func (s *SQLiteStore) SaveHeatmap(h *Heatmap) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("marshaling heatmap: %w", err)
+	}
+	return s.put(sqliteKeyHeatmap, data)
+}
+
+// LoadState reads the state snapshot row.
+func (s *SQLiteStore) LoadState() (*StateSnapshot, error) {
+	data, err := s.get(sqliteKeyState)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var snapshot StateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parsing state snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// SaveState writes the state snapshot row.
+func (s *SQLiteStore) SaveState(snapshot *StateSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal state snapshot: %w", err)
+	}
+	return s.put(sqliteKeyState, data)
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// String implements fmt.Stringer.
+func (s *SQLiteStore) String() string {
+	return fmt.Sprintf("sqlite(%s)", s.path)
+}
@@ -0,0 +1,326 @@
+package mesh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// storeFactories returns a constructor for each backend rooted in a fresh temp dir.
+func storeFactories(t *testing.T) map[string]func() Store {
+	t.Helper()
+	return map[string]func() Store{
+		"file": func() Store {
+			dir := t.TempDir()
+			return NewFileStore(dir, filepath.Join(dir, ".calibration-cache.json"))
+		},
+		"memory": func() Store { return NewMemoryStore() },
+		"sqlite": func() Store {
+			s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "state", "tudomesh.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteStore: %v", err)
+			}
+			return s
+		},
+	}
+}
+
+func storeTestMap() *ValetudoMap {
+	return &ValetudoMap{
+		PixelSize: 5,
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: []int{0, 0, 1, 0, 2, 0}},
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Round trips
+// ---------------------------------------------------------------------------
+
+func TestStore_RoundTrip(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			defer func() { _ = s.Close() }()
+
+			// Empty store reports nothing rather than erroring
+			if cal, err := s.LoadCalibration(); err != nil || cal != nil {
+				t.Fatalf("empty LoadCalibration = %v, %v; want nil, nil", cal, err)
+			}
+			if um, err := s.LoadUnifiedMap(); err != nil || um != nil {
+				t.Fatalf("empty LoadUnifiedMap = %v, %v; want nil, nil", um, err)
+			}
+			if h, err := s.LoadHeatmap(); err != nil || h != nil {
+				t.Fatalf("empty LoadHeatmap = %v, %v; want nil, nil", h, err)
+			}
+			if snapshot, err := s.LoadState(); err != nil || snapshot != nil {
+				t.Fatalf("empty LoadState = %v, %v; want nil, nil", snapshot, err)
+			}
+
+			cal := &CalibrationData{
+				ReferenceVacuum: "a",
+				Vacuums:         map[string]VacuumCalibration{"b": {Transform: Identity()}},
+			}
+			if err := s.SaveCalibration(cal); err != nil {
+				t.Fatalf("SaveCalibration: %v", err)
+			}
+			loaded, err := s.LoadCalibration()
+			if err != nil || loaded == nil {
+				t.Fatalf("LoadCalibration = %v, %v", loaded, err)
+			}
+			if loaded.ReferenceVacuum != "a" || len(loaded.Vacuums) != 1 {
+				t.Errorf("calibration mismatch: %+v", loaded)
+			}
+			if loaded.LastUpdated == 0 {
+				t.Error("SaveCalibration should set LastUpdated")
+			}
+
+			if err := s.SaveMap("rocky7", storeTestMap()); err != nil {
+				t.Fatalf("SaveMap: %v", err)
+			}
+			maps, err := s.LoadMaps()
+			if err != nil {
+				t.Fatalf("LoadMaps: %v", err)
+			}
+			m, ok := maps["rocky7"]
+			if !ok {
+				t.Fatalf("LoadMaps missing rocky7, got %d maps", len(maps))
+			}
+			if len(m.Layers) != 1 || len(m.Layers[0].Pixels) != 6 {
+				t.Errorf("map layers mismatch: %+v", m.Layers)
+			}
+
+			um := &UnifiedMap{Metadata: UnifiedMetadata{ReferenceVacuum: "a"}}
+			if err := s.SaveUnifiedMap(um); err != nil {
+				t.Fatalf("SaveUnifiedMap: %v", err)
+			}
+			loadedUM, err := s.LoadUnifiedMap()
+			if err != nil || loadedUM == nil {
+				t.Fatalf("LoadUnifiedMap = %v, %v", loadedUM, err)
+			}
+			if loadedUM.Metadata.ReferenceVacuum != "a" {
+				t.Errorf("unified map reference = %q, want a", loadedUM.Metadata.ReferenceVacuum)
+			}
+
+			heatmap := NewHeatmap()
+			heatmap.Record("a", Point{X: 150, Y: 250}, time.Now())
+			if err := s.SaveHeatmap(heatmap); err != nil {
+				t.Fatalf("SaveHeatmap: %v", err)
+			}
+			loadedHeatmap, err := s.LoadHeatmap()
+			if err != nil || loadedHeatmap == nil {
+				t.Fatalf("LoadHeatmap = %v, %v", loadedHeatmap, err)
+			}
+			if counts := loadedHeatmap.Counts(time.Time{}); counts[HeatCell{X: 1, Y: 2}] != 1 || len(counts) != 1 {
+				t.Errorf("heatmap counts = %v, want one visit to cell 1,2", counts)
+			}
+
+			if err := s.SaveState(&StateSnapshot{Batteries: map[string]int{"a": 42}}); err != nil {
+				t.Fatalf("SaveState: %v", err)
+			}
+			snapshot, err := s.LoadState()
+			if err != nil || snapshot == nil {
+				t.Fatalf("LoadState = %v, %v", snapshot, err)
+			}
+			if snapshot.Batteries["a"] != 42 {
+				t.Errorf("state batteries = %v, want a at 42", snapshot.Batteries)
+			}
+
+			if s.String() == "" {
+				t.Error("String() should describe the store")
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tudomesh.db")
+
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap: %v", err)
+	}
+	// Overwrite to exercise the upsert path
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap (overwrite): %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	maps, err := s.LoadMaps()
+	if err != nil {
+		t.Fatalf("LoadMaps: %v", err)
+	}
+	if len(maps) != 1 {
+		t.Errorf("expected 1 map after reopen, got %d", len(maps))
+	}
+}
+
+func TestSQLiteStore_SkipsInvalidMap(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tudomesh.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap: %v", err)
+	}
+	if err := s.put(sqliteKeyMapPrefix+"broken", []byte("{not json")); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	maps, err := s.LoadMaps()
+	if err != nil {
+		t.Fatalf("LoadMaps: %v", err)
+	}
+	if len(maps) != 1 || maps["v1"] == nil {
+		t.Errorf("expected invalid row to be skipped, got %d maps", len(maps))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FileStore
+// ---------------------------------------------------------------------------
+
+func TestFileStore_LayoutAndSkipsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFileStore(dir, "")
+
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ValetudoMapExport-v1.json")); err != nil {
+		t.Errorf("expected export file in data dir: %v", err)
+	}
+
+	bad := filepath.Join(dir, "ValetudoMapExport-broken.json")
+	if err := os.WriteFile(bad, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	maps, err := s.LoadMaps()
+	if err != nil {
+		t.Fatalf("LoadMaps: %v", err)
+	}
+	if len(maps) != 1 {
+		t.Errorf("expected invalid export to be skipped, got %d maps", len(maps))
+	}
+
+	// No calibration path configured: saving is a no-op
+	if err := s.SaveCalibration(&CalibrationData{}); err != nil {
+		t.Errorf("SaveCalibration without path: %v", err)
+	}
+}
+
+func TestFileStore_Compress(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFileStore(dir, "")
+
+	// A plain export from before compression was enabled
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap: %v", err)
+	}
+
+	s.Compress = true
+	if err := s.SaveMap("v1", storeTestMap()); err != nil {
+		t.Fatalf("SaveMap compressed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "ValetudoMapExport-v1.json.gz"))
+	if err != nil {
+		t.Fatalf("expected compressed export: %v", err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Error("export is not gzip compressed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ValetudoMapExport-v1.json")); !os.IsNotExist(err) {
+		t.Errorf("stale plain export should be removed, stat err = %v", err)
+	}
+
+	maps, err := s.LoadMaps()
+	if err != nil {
+		t.Fatalf("LoadMaps: %v", err)
+	}
+	if m := maps["v1"]; m == nil || len(m.Layers) != 1 || m.PixelSize != 5 {
+		t.Errorf("compressed export did not round trip: %+v", maps)
+	}
+
+	// Leaves no temp files behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the export in %s, got %d entries", dir, len(entries))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// OpenStore
+// ---------------------------------------------------------------------------
+
+func TestOpenStore(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		backend string
+		want    string
+	}{
+		{"", "*mesh.FileStore"},
+		{StorageBackendFile, "*mesh.FileStore"},
+		{StorageBackendMemory, "*mesh.MemoryStore"},
+		{StorageBackendSQLite, "*mesh.SQLiteStore"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			s, err := OpenStore(StorageConfig{Backend: tt.backend}, dir, filepath.Join(dir, "cal.json"))
+			if err != nil {
+				t.Fatalf("OpenStore: %v", err)
+			}
+			defer func() { _ = s.Close() }()
+			if got := fmt.Sprintf("%T", s); got != tt.want {
+				t.Errorf("OpenStore(%q) = %s, want %s", tt.backend, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, DefaultSQLiteFile)); err != nil {
+		t.Errorf("sqlite backend should default to data dir: %v", err)
+	}
+
+	if _, err := OpenStore(StorageConfig{Backend: "redis"}, dir, ""); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// StateTracker integration
+// ---------------------------------------------------------------------------
+
+func TestStateTracker_SetStoreLoadsUnifiedMap(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.SaveUnifiedMap(&UnifiedMap{Metadata: UnifiedMetadata{ReferenceVacuum: "ref"}}); err != nil {
+		t.Fatalf("SaveUnifiedMap: %v", err)
+	}
+
+	st := NewStateTracker()
+	st.SetStore(s)
+	um := st.GetUnifiedMap()
+	if um == nil || um.Metadata.ReferenceVacuum != "ref" {
+		t.Errorf("expected unified map loaded from store, got %+v", um)
+	}
+}
@@ -0,0 +1,168 @@
+package mesh
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowThreshold is how long a traced operation may take before its
+// stage timings are logged.
+const DefaultSlowThreshold = time.Second
+
+// slowThreshold is read by every Trace.End; see SetSlowThreshold.
+var slowThreshold atomic.Int64
+
+func init() {
+	slowThreshold.Store(int64(DefaultSlowThreshold))
+}
+
+// SetSlowThreshold sets how long a traced operation may take before End logs
+// its stage timings. 0 logs every traced operation.
+func SetSlowThreshold(d time.Duration) {
+	slowThreshold.Store(int64(d))
+}
+
+// Trace times the stages of one operation, such as an HTTP render, an MQTT
+// map message, a calibration or a unified map rebuild. Step starts the next
+// stage and ends the previous one; End ends the last stage and logs the
+// breakdown when the operation was slow. Stages with the same name, such as
+// ICP run against two targets, are added up.
+//
+// A nil *Trace is valid and records nothing, so code can call
+// TraceFrom(ctx).Step without checking for a trace.
+type Trace struct {
+	name  string
+	start time.Time
+
+	mu        sync.Mutex
+	stages    []traceStage
+	stage     string // current stage, empty before the first Step
+	stageFrom time.Time
+	ended     bool
+}
+
+type traceStage struct {
+	name     string
+	duration time.Duration
+}
+
+// StartTrace starts timing the operation name, e.g. "GET /composite-map.png".
+func StartTrace(name string) *Trace {
+	now := time.Now()
+	return &Trace{name: name, start: now, stageFrom: now}
+}
+
+// Step ends the current stage and starts stage name.
+func (t *Trace) Step(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return
+	}
+	t.endStage(time.Now())
+	t.stage = name
+}
+
+// endStage adds the time since the current stage started to its total.
+// Callers must hold t.mu.
+func (t *Trace) endStage(now time.Time) {
+	if t.stage != "" {
+		d := now.Sub(t.stageFrom)
+		found := false
+		for i := range t.stages {
+			if t.stages[i].name == t.stage {
+				t.stages[i].duration += d
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.stages = append(t.stages, traceStage{t.stage, d})
+		}
+	}
+	t.stageFrom = now
+}
+
+// End ends the last stage and returns the operation's duration. The stage
+// timings are logged as [SLOW] when it took at least the slow threshold,
+// or as [TRACE] with a threshold of 0. Later calls do nothing.
+func (t *Trace) End() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	total := now.Sub(t.start)
+	if t.ended {
+		return total
+	}
+	t.ended = true
+	t.endStage(now)
+
+	threshold := time.Duration(slowThreshold.Load())
+	switch {
+	case threshold == 0:
+		log.Printf("[TRACE] %s", t.summary(total))
+	case total >= threshold:
+		log.Printf("[SLOW] %s", t.summary(total))
+	}
+	return total
+}
+
+// summary formats the total and stage durations as key=value pairs.
+// Callers must hold t.mu.
+func (t *Trace) summary(total time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s total=%s", t.name, roundDuration(total))
+	for _, s := range t.stages {
+		fmt.Fprintf(&b, " %s=%s", s.name, roundDuration(s.duration))
+	}
+	return b.String()
+}
+
+// ServerTiming returns the stages ended so far as a Server-Timing header
+// value, in milliseconds, so browser developer tools show where a request
+// spent its time.
+func (t *Trace) ServerTiming() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parts := make([]string, len(t.stages))
+	for i, s := range t.stages {
+		parts[i] = fmt.Sprintf("%s;dur=%.1f", s.name, float64(s.duration.Microseconds())/1000)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// roundDuration keeps log lines short: milliseconds above one, microseconds
+// below.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Millisecond {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+type traceKey struct{}
+
+// WithTrace returns a copy of ctx carrying t.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFrom returns the trace carried by ctx, or nil.
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
@@ -0,0 +1,106 @@
+package mesh
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// traceLog captures what traces log while fn runs with the given threshold.
+func traceLog(t *testing.T, threshold time.Duration, fn func()) string {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	SetSlowThreshold(threshold)
+	defer func() {
+		log.SetOutput(prev)
+		SetSlowThreshold(DefaultSlowThreshold)
+	}()
+	fn()
+	return buf.String()
+}
+
+func TestTrace_Stages(t *testing.T) {
+	out := traceLog(t, 0, func() {
+		trace := StartTrace("GET /composite-map.png")
+		trace.Step("draw")
+		time.Sleep(2 * time.Millisecond)
+		trace.Step("encode")
+		trace.Step("draw") // repeated stages are added up
+		time.Sleep(2 * time.Millisecond)
+		if total := trace.End(); total < 4*time.Millisecond {
+			t.Errorf("End = %v, want at least 4ms", total)
+		}
+		trace.End()
+		trace.Step("late")
+	})
+
+	if strings.Count(out, "[TRACE]") != 1 {
+		t.Fatalf("want one [TRACE] line, got:\n%s", out)
+	}
+	re := regexp.MustCompile(`\[TRACE\] GET /composite-map.png total=\S+ draw=(\S+) encode=\S+\n`)
+	m := re.FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("unexpected log line:\n%s", out)
+	}
+	if d, err := time.ParseDuration(m[1]); err != nil || d < 4*time.Millisecond {
+		t.Errorf("draw = %s, want at least 4ms", m[1])
+	}
+}
+
+func TestTrace_SlowThreshold(t *testing.T) {
+	out := traceLog(t, time.Hour, func() {
+		StartTrace("fast").End()
+	})
+	if out != "" {
+		t.Errorf("fast operation logged:\n%s", out)
+	}
+
+	out = traceLog(t, time.Nanosecond, func() {
+		trace := StartTrace("calibration vac1")
+		trace.Step("icp")
+		time.Sleep(time.Millisecond)
+		trace.End()
+	})
+	if !strings.Contains(out, "[SLOW] calibration vac1 total=") || !strings.Contains(out, " icp=") {
+		t.Errorf("slow operation not logged:\n%s", out)
+	}
+}
+
+func TestTrace_Context(t *testing.T) {
+	if trace := TraceFrom(context.Background()); trace != nil {
+		t.Fatalf("TraceFrom(empty) = %v, want nil", trace)
+	}
+	// A nil trace records nothing
+	var none *Trace
+	none.Step("draw")
+	if none.End() != 0 || none.ServerTiming() != "" {
+		t.Error("nil trace recorded timings")
+	}
+
+	trace := StartTrace("GET /live.png")
+	ctx := WithTrace(context.Background(), trace)
+	TraceFrom(ctx).Step("queue")
+	TraceFrom(ctx).Step("draw")
+	timing := trace.ServerTiming()
+	if !regexp.MustCompile(`^queue;dur=\d+\.\d$`).MatchString(timing) {
+		t.Errorf("ServerTiming = %q, want only the ended queue stage", timing)
+	}
+}
+
+func TestTracingConfig_Threshold(t *testing.T) {
+	if d, err := (TracingConfig{}).Threshold(); err != nil || d != DefaultSlowThreshold {
+		t.Errorf("default = %v, %v", d, err)
+	}
+	if d, err := (TracingConfig{SlowThreshold: "0s"}).Threshold(); err != nil || d != 0 {
+		t.Errorf("0s = %v, %v", d, err)
+	}
+	if _, err := (TracingConfig{SlowThreshold: "-1s"}).Threshold(); err == nil {
+		t.Error("negative threshold: expected error")
+	}
+}
@@ -0,0 +1,76 @@
+package mesh
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// DefaultTrackLength is the number of positions kept per vacuum for its
+// track; older positions are dropped first.
+const DefaultTrackLength = 3600
+
+// TrackPoint is one recorded position of a vacuum, in the same grid
+// coordinates as LivePosition.
+type TrackPoint struct {
+	X         float64   `json:"x"`
+	Y         float64   `json:"y"`
+	Angle     float64   `json:"angle"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// recordTrack appends a position to a track, skipping it when the vacuum has
+// not moved since the last point, and trims the track to DefaultTrackLength.
+func recordTrack(track []TrackPoint, p TrackPoint) []TrackPoint {
+	if n := len(track); n > 0 && track[n-1].X == p.X && track[n-1].Y == p.Y {
+		return track
+	}
+	track = append(track, p)
+	if len(track) > DefaultTrackLength {
+		track = append(track[:0:0], track[len(track)-DefaultTrackLength:]...)
+	}
+	return track
+}
+
+// TracksFeatureCollection converts vacuum tracks into GeoJSON, one
+// LineString per vacuum in world coordinates (mm). Grid coordinates are
+// scaled by the pixel size of each vacuum's map (5 when it has none). The
+// "coordTimes" property lists the RFC 3339 timestamp of every coordinate.
+// Tracks with fewer than two points are omitted. config supplies display
+// names and may be nil.
+func TracksFeatureCollection(tracks map[string][]TrackPoint, maps map[string]*ValetudoMap, config *Config) *FeatureCollection {
+	ids := make([]string, 0, len(tracks))
+	for id := range tracks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fc := NewFeatureCollection()
+	for _, id := range ids {
+		track := tracks[id]
+		if len(track) < 2 {
+			continue
+		}
+		pixelSize := 5.0
+		if m := maps[id]; m != nil && m.PixelSize > 0 {
+			pixelSize = float64(m.PixelSize)
+		}
+
+		coords := make([][2]float64, len(track))
+		times := make([]string, len(track))
+		for i, p := range track {
+			coords[i] = [2]float64{p.X * pixelSize, p.Y * pixelSize}
+			times[i] = p.Timestamp.UTC().Format(time.RFC3339Nano)
+		}
+		coordsJSON, _ := json.Marshal(coords)
+
+		fc.AddFeature(NewFeature(&Geometry{Type: GeometryLineString, Coordinates: coordsJSON}, map[string]interface{}{
+			"vacuumId":    id,
+			"displayName": config.DisplayName(id),
+			"start":       times[0],
+			"end":         times[len(times)-1],
+			"coordTimes":  times,
+		}))
+	}
+	return fc
+}
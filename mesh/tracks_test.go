@@ -0,0 +1,100 @@
+package mesh
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// recordTrack
+// ---------------------------------------------------------------------------
+
+func TestRecordTrack(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var track []TrackPoint
+	track = recordTrack(track, TrackPoint{X: 1, Y: 1, Timestamp: start})
+	track = recordTrack(track, TrackPoint{X: 1, Y: 1, Timestamp: start.Add(time.Second)})
+	if len(track) != 1 {
+		t.Fatalf("stationary position recorded twice: %d points", len(track))
+	}
+
+	for i := 0; i < DefaultTrackLength+10; i++ {
+		track = recordTrack(track, TrackPoint{X: float64(i + 2), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	if len(track) != DefaultTrackLength {
+		t.Fatalf("len = %d, want %d", len(track), DefaultTrackLength)
+	}
+	if last := track[len(track)-1].X; last != float64(DefaultTrackLength+11) {
+		t.Errorf("last X = %v, want newest position kept", last)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// StateTracker.GetTracks
+// ---------------------------------------------------------------------------
+
+func TestStateTracker_GetTracks(t *testing.T) {
+	st := NewStateTracker()
+	st.UpdatePosition("vac1", 1, 1, 0)
+	st.UpdatePosition("vac1", 2, 1, 0)
+	st.UpdatePosition("vac2", 5, 5, 0)
+
+	tracks := st.GetTracks(time.Time{})
+	if len(tracks["vac1"]) != 2 || len(tracks["vac2"]) != 1 {
+		t.Fatalf("tracks = %v, want 2 and 1 points", tracks)
+	}
+
+	// The copy is independent of the tracker
+	tracks["vac1"][0].X = 99
+	if st.GetTracks(time.Time{})["vac1"][0].X != 1 {
+		t.Error("GetTracks returned the tracker's own slice")
+	}
+
+	if got := st.GetTracks(time.Now().Add(time.Minute)); len(got) != 0 {
+		t.Errorf("future since = %v, want no tracks", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TracksFeatureCollection
+// ---------------------------------------------------------------------------
+
+func TestTracksFeatureCollection(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracks := map[string][]TrackPoint{
+		"vac2": {{X: 0, Y: 0, Timestamp: start}, {X: 10, Y: 0, Timestamp: start.Add(time.Second)}},
+		"vac1": {{X: 1, Y: 2, Timestamp: start}, {X: 1, Y: 3, Timestamp: start.Add(2 * time.Second)}},
+		"vac3": {{X: 4, Y: 4, Timestamp: start}},
+	}
+	maps := map[string]*ValetudoMap{"vac2": {PixelSize: 10}}
+	cfg := &Config{Vacuums: []VacuumConfig{{ID: "vac1", DisplayName: "Upstairs"}}}
+
+	fc := TracksFeatureCollection(tracks, maps, cfg)
+	if len(fc.Features) != 2 {
+		t.Fatalf("features = %d, want 2 (single-point track omitted)", len(fc.Features))
+	}
+
+	first := fc.Features[0]
+	if first.Properties["vacuumId"] != "vac1" || first.Properties["displayName"] != "Upstairs" {
+		t.Errorf("first feature properties = %v, want vac1/Upstairs", first.Properties)
+	}
+	var coords [][2]float64
+	if err := json.Unmarshal(first.Geometry.Coordinates, &coords); err != nil {
+		t.Fatal(err)
+	}
+	// vac1 has no map, so the default pixel size of 5 applies
+	if coords[0] != [2]float64{5, 10} || coords[1] != [2]float64{5, 15} {
+		t.Errorf("vac1 coordinates = %v", coords)
+	}
+	if first.Properties["start"] != "2024-05-01T12:00:00Z" || first.Properties["end"] != "2024-05-01T12:00:02Z" {
+		t.Errorf("start/end = %v/%v", first.Properties["start"], first.Properties["end"])
+	}
+
+	if err := json.Unmarshal(fc.Features[1].Geometry.Coordinates, &coords); err != nil {
+		t.Fatal(err)
+	}
+	if coords[1] != [2]float64{100, 0} {
+		t.Errorf("vac2 coordinates = %v, want scaled by its pixel size", coords)
+	}
+}
@@ -30,12 +30,29 @@ func NormalizeAngle(degrees float64) float64 {
 	return degrees
 }
 
-// TransformAngle applies the rotation component of an affine transform to a local angle (in degrees).
-// The rotation is extracted from the transform matrix via atan2(C, A).
+// TransformRotation returns the rotation of an affine transform in degrees,
+// normalized to [0, 360). It is the direction the local X axis points to
+// after the transform, atan2(C, A).
+func TransformRotation(transform AffineMatrix) float64 {
+	return NormalizeAngle(math.Atan2(transform.C, transform.A) * 180 / math.Pi)
+}
+
+// IsMirrored reports whether the transform flips handedness (negative
+// determinant), e.g. a vacuum whose map is stored mirrored.
+func IsMirrored(transform AffineMatrix) bool {
+	return transform.Determinant() < 0
+}
+
+// TransformAngle maps a local heading (in degrees) through an affine
+// transform. A rotation adds its angle; a mirrored transform reflects the
+// heading about the local X axis first, so headings turn the other way.
+// Exact for rigid and similarity transforms, the kind calibration produces.
 // Returns the transformed angle normalized to [0, 360).
 func TransformAngle(localAngle float64, transform AffineMatrix) float64 {
-	transformRotation := math.Atan2(transform.C, transform.A) * 180 / math.Pi
-	return NormalizeAngle(localAngle + transformRotation)
+	if IsMirrored(transform) {
+		localAngle = -localAngle
+	}
+	return NormalizeAngle(localAngle + math.Atan2(transform.C, transform.A)*180/math.Pi)
 }
 
 // MultiplyMatrices composes two affine transforms: result = m1 * m2
@@ -54,8 +71,8 @@ func MultiplyMatrices(m1, m2 AffineMatrix) AffineMatrix {
 // InvertMatrix computes the inverse of an affine transform
 // Returns identity if matrix is singular (determinant ~= 0)
 func InvertMatrix(m AffineMatrix) AffineMatrix {
-	det := m.A*m.D - m.B*m.C
-	if math.Abs(det) < 1e-10 {
+	det := m.Determinant()
+	if math.Abs(det) < singularDeterminant {
 		return Identity()
 	}
 
@@ -70,6 +87,112 @@ func InvertMatrix(m AffineMatrix) AffineMatrix {
 	}
 }
 
+// singularDeterminant is the determinant below which a transform is
+// treated as singular and not inverted.
+const singularDeterminant = 1e-10
+
+// Determinant returns the determinant of the linear part: the factor areas
+// are scaled by, negative for a mirrored transform.
+func (m AffineMatrix) Determinant() float64 {
+	return m.A*m.D - m.B*m.C
+}
+
+// Inverse returns the inverse transform. ok is false, and the result the
+// identity, when m is singular.
+func (m AffineMatrix) Inverse() (inverse AffineMatrix, ok bool) {
+	if math.Abs(m.Determinant()) < singularDeterminant {
+		return Identity(), false
+	}
+	return InvertMatrix(m), true
+}
+
+// ApproxEqual reports whether every coefficient of m is within tolerance of
+// other's.
+func (m AffineMatrix) ApproxEqual(other AffineMatrix, tolerance float64) bool {
+	return math.Abs(m.A-other.A) <= tolerance && math.Abs(m.B-other.B) <= tolerance &&
+		math.Abs(m.C-other.C) <= tolerance && math.Abs(m.D-other.D) <= tolerance &&
+		math.Abs(m.Tx-other.Tx) <= tolerance && math.Abs(m.Ty-other.Ty) <= tolerance
+}
+
+// Compose returns the transform that applies transforms in turn, the first
+// one first: Compose(a, b) is MultiplyMatrices(b, a). Without arguments it
+// returns the identity.
+func Compose(transforms ...AffineMatrix) AffineMatrix {
+	result := Identity()
+	for _, t := range transforms {
+		result = MultiplyMatrices(t, result)
+	}
+	return result
+}
+
+// Decomposition is an affine transform split into the parameters it is
+// built from: a scale, then a shear, then a rotation, then a translation.
+type Decomposition struct {
+	Rotation float64 // degrees, in (-180, 180]
+	ScaleX   float64
+	ScaleY   float64 // negative for a mirrored transform
+	Shear    float64 // X offset per unit of Y, applied after scaling
+	Tx, Ty   float64
+}
+
+// Decompose splits m into rotation, scale, shear and translation, so that
+// Decompose().Matrix() gives m back. For the rigid transforms calibration
+// produces the scales are 1 and the shear 0. A transform that collapses
+// the X axis takes its rotation from the Y axis instead.
+func (m AffineMatrix) Decompose() Decomposition {
+	d := Decomposition{Tx: m.Tx, Ty: m.Ty}
+	d.ScaleX = math.Hypot(m.A, m.C)
+	if d.ScaleX == 0 {
+		d.Rotation = signedDegrees(math.Atan2(-m.B, m.D) * 180 / math.Pi)
+		d.ScaleY = math.Hypot(m.B, m.D)
+		return d
+	}
+	d.Rotation = signedDegrees(math.Atan2(m.C, m.A) * 180 / math.Pi)
+	d.ScaleY = m.Determinant() / d.ScaleX
+	if d.ScaleY != 0 {
+		d.Shear = (m.A*m.B + m.C*m.D) / (d.ScaleX * d.ScaleY)
+	}
+	return d
+}
+
+// Matrix rebuilds the transform d describes.
+func (d Decomposition) Matrix() AffineMatrix {
+	rad := d.Rotation * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	// Rotation · [[ScaleX, Shear·ScaleY], [0, ScaleY]]
+	b := d.Shear * d.ScaleY
+	return AffineMatrix{
+		A: cos * d.ScaleX, B: cos*b - sin*d.ScaleY, Tx: d.Tx,
+		C: sin * d.ScaleX, D: sin*b + cos*d.ScaleY, Ty: d.Ty,
+	}
+}
+
+// Lerp interpolates between transforms a (t = 0) and b (t = 1) for smooth
+// previews: rotation turns the short way round, and scale, shear and
+// translation change linearly, so halfway between two rigid transforms is
+// rigid too rather than the shrunken average of their coefficients.
+func Lerp(a, b AffineMatrix, t float64) AffineMatrix {
+	da, db := a.Decompose(), b.Decompose()
+	lerp := func(x, y float64) float64 { return x + t*(y-x) }
+	return Decomposition{
+		Rotation: da.Rotation + t*signedDegrees(db.Rotation-da.Rotation),
+		ScaleX:   lerp(da.ScaleX, db.ScaleX),
+		ScaleY:   lerp(da.ScaleY, db.ScaleY),
+		Shear:    lerp(da.Shear, db.Shear),
+		Tx:       lerp(da.Tx, db.Tx),
+		Ty:       lerp(da.Ty, db.Ty),
+	}.Matrix()
+}
+
+// signedDegrees normalizes an angle in degrees to (-180, 180].
+func signedDegrees(degrees float64) float64 {
+	degrees = NormalizeAngle(degrees)
+	if degrees > 180 {
+		degrees -= 360
+	}
+	return degrees
+}
+
 // Translation creates a translation-only transform
 func Translation(tx, ty float64) AffineMatrix {
 	return AffineMatrix{A: 1, B: 0, Tx: tx, C: 0, D: 1, Ty: ty}
@@ -256,43 +379,59 @@ func Centroid(points []Point) Point {
 // CalculateRigidTransform computes the best rigid transform (rotation + translation only, no scale)
 // using Procrustes analysis. This is more robust for map alignment where scale is known.
 func CalculateRigidTransform(source, target []Point) AffineMatrix {
+	return CalculateWeightedRigidTransform(source, target, nil)
+}
+
+// CalculateWeightedRigidTransform is CalculateRigidTransform with a weight
+// per correspondence, e.g. from RobustWeight; nil weights count every pair
+// equally. It returns Identity when the pairs carry no weight.
+func CalculateWeightedRigidTransform(source, target []Point, weights []float64) AffineMatrix {
 	n := len(source)
-	if n < 2 || n != len(target) {
+	if n < 2 || n != len(target) || (weights != nil && len(weights) != n) {
 		return Identity()
 	}
+	weight := func(i int) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
 
-	// Compute centroids
-	srcCentroid := Centroid(source)
-	tgtCentroid := Centroid(target)
-
-	// Center the point sets
-	var srcCentered, tgtCentered []Point
+	// Compute weighted centroids
+	var srcCentroid, tgtCentroid Point
+	total := 0.0
 	for i := range source {
-		srcCentered = append(srcCentered, Point{
-			X: source[i].X - srcCentroid.X,
-			Y: source[i].Y - srcCentroid.Y,
-		})
-		tgtCentered = append(tgtCentered, Point{
-			X: target[i].X - tgtCentroid.X,
-			Y: target[i].Y - tgtCentroid.Y,
-		})
+		w := weight(i)
+		total += w
+		srcCentroid.X += w * source[i].X
+		srcCentroid.Y += w * source[i].Y
+		tgtCentroid.X += w * target[i].X
+		tgtCentroid.Y += w * target[i].Y
 	}
+	if total <= 0 {
+		return Identity()
+	}
+	srcCentroid = Point{X: srcCentroid.X / total, Y: srcCentroid.Y / total}
+	tgtCentroid = Point{X: tgtCentroid.X / total, Y: tgtCentroid.Y / total}
 
-	// Compute cross-covariance matrix H = src^T * tgt
+	// Compute cross-covariance matrix H = src^T * W * tgt of the centered sets
 	// H = [h11 h12]
 	//     [h21 h22]
 	var h11, h12, h21, h22 float64
-	for i := range srcCentered {
-		h11 += srcCentered[i].X * tgtCentered[i].X
-		h12 += srcCentered[i].X * tgtCentered[i].Y
-		h21 += srcCentered[i].Y * tgtCentered[i].X
-		h22 += srcCentered[i].Y * tgtCentered[i].Y
+	for i := range source {
+		w := weight(i)
+		sx, sy := source[i].X-srcCentroid.X, source[i].Y-srcCentroid.Y
+		tx, ty := target[i].X-tgtCentroid.X, target[i].Y-tgtCentroid.Y
+		h11 += w * sx * tx
+		h12 += w * sx * ty
+		h21 += w * sy * tx
+		h22 += w * sy * ty
 	}
 
 	// For 2D, we can directly compute the rotation angle using atan2
 	// The optimal rotation minimizes sum of squared distances
-	// theta = atan2(h21 - h12, h11 + h22)
-	theta := math.Atan2(h21-h12, h11+h22)
+	// theta = atan2(h12 - h21, h11 + h22)
+	theta := math.Atan2(h12-h21, h11+h22)
 
 	cos := math.Cos(theta)
 	sin := math.Sin(theta)
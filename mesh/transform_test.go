@@ -2,6 +2,7 @@ package mesh
 
 import (
 	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -775,6 +776,12 @@ func TestCalculateRigidTransform(t *testing.T) {
 			},
 			shouldBeExact: true,
 		},
+		{
+			name:          "rotation by 30 degrees - asymmetric",
+			source:        []Point{{X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 5}, {X: 3, Y: 12}},
+			target:        TransformPoints([]Point{{X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 5}, {X: 3, Y: 12}}, CreateRotationTranslation(30, 7, -4)),
+			shouldBeExact: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -836,6 +843,29 @@ func TestCalculateRigidTransform(t *testing.T) {
 	}
 }
 
+func TestCalculateWeightedRigidTransform(t *testing.T) {
+	source := []Point{{X: 0, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 5}, {X: 3, Y: 12}, {X: 50, Y: 50}}
+	target := TransformPoints(source, CreateRotationTranslation(-15, 3, 8))
+	target[4] = Point{X: -200, Y: 300} // a wild outlier
+
+	m := CalculateWeightedRigidTransform(source, target, []float64{1, 1, 1, 1, 0})
+	for i := 0; i < 4; i++ {
+		if d := Distance(TransformPoint(source[i], m), target[i]); d > 1e-6 {
+			t.Errorf("point %d off by %g with the outlier weighted out", i, d)
+		}
+	}
+	if m := CalculateRigidTransform(source, target); Distance(TransformPoint(source[0], m), target[0]) < 1 {
+		t.Error("expected the unweighted transform to be pulled by the outlier")
+	}
+
+	if m := CalculateWeightedRigidTransform(source, target, make([]float64, 5)); m != Identity() {
+		t.Errorf("zero weights: got %+v, want Identity", m)
+	}
+	if m := CalculateWeightedRigidTransform(source, target, []float64{1}); m != Identity() {
+		t.Errorf("mismatched weights: got %+v, want Identity", m)
+	}
+}
+
 // Benchmarks for critical paths
 
 func BenchmarkMultiplyMatrices(b *testing.B) {
@@ -1080,6 +1110,109 @@ func TestTransformAngle(t *testing.T) {
 	}
 }
 
+// randomSimilarity returns a random rotation, uniform scale and translation,
+// mirrored about the X axis when mirror is set.
+func randomSimilarity(rng *rand.Rand, mirror bool) AffineMatrix {
+	s := 0.5 + rng.Float64()*1.5
+	m := MultiplyMatrices(
+		CreateRotationTranslation(rng.Float64()*720-360, rng.Float64()*2000-1000, rng.Float64()*2000-1000),
+		Scale(s, s),
+	)
+	if mirror {
+		m = MultiplyMatrices(m, Scale(1, -1))
+	}
+	return m
+}
+
+func TestTransformAngle_MirroredTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		localAngle float64
+		transform  AffineMatrix
+		expected   float64
+	}{
+		{"flip Y keeps east", 0, Scale(1, -1), 0},
+		{"flip Y turns north to south", 90, Scale(1, -1), 270},
+		{"flip X turns east to west", 0, Scale(-1, 1), 180},
+		{"flip X keeps north", 90, Scale(-1, 1), 90},
+		{"flip Y then rotate 90", 30, MultiplyMatrices(RotationDeg(90), Scale(1, -1)), 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsMirrored(tt.transform) {
+				t.Fatal("expected transform to be mirrored")
+			}
+			if got := TransformAngle(tt.localAngle, tt.transform); angleDiff(got, tt.expected) > 1e-9 {
+				t.Errorf("TransformAngle(%v) = %v, want %v", tt.localAngle, got, tt.expected)
+			}
+		})
+	}
+
+	if IsMirrored(RotationDeg(180)) || IsMirrored(Identity()) {
+		t.Error("rotations must not be reported as mirrored")
+	}
+}
+
+func TestTransformRotation(t *testing.T) {
+	tests := []struct {
+		transform AffineMatrix
+		expected  float64
+	}{
+		{Identity(), 0},
+		{RotationDeg(90), 90},
+		{RotationDeg(-90), 270},
+		{CreateRotationTranslation(37.5, 100, -20), 37.5},
+		{MultiplyMatrices(RotationDeg(200), Scale(2, 2)), 200},
+	}
+	for _, tt := range tests {
+		if got := TransformRotation(tt.transform); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("TransformRotation(%+v) = %v, want %v", tt.transform, got, tt.expected)
+		}
+	}
+}
+
+// Property: the heading of a transformed direction is TransformAngle of the
+// heading, for rotations and mirrors alike.
+func TestTransformAngle_MatchesTransformedDirection(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		m := randomSimilarity(rng, i%2 == 1)
+		angle := rng.Float64()*720 - 360
+		rad := angle * math.Pi / 180
+
+		p0 := Point{X: rng.Float64() * 100, Y: rng.Float64() * 100}
+		p1 := Point{X: p0.X + math.Cos(rad), Y: p0.Y + math.Sin(rad)}
+		q0, q1 := TransformPoint(p0, m), TransformPoint(p1, m)
+		want := math.Atan2(q1.Y-q0.Y, q1.X-q0.X) * 180 / math.Pi
+
+		if got := TransformAngle(angle, m); angleDiff(got, want) > 1e-6 {
+			t.Fatalf("case %d: TransformAngle(%v, %+v) = %v, transformed direction is %v", i, angle, m, got, want)
+		}
+	}
+}
+
+// Property: composing transforms composes angles, and the inverse transform
+// maps the angle back.
+func TestTransformAngle_Composition(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 500; i++ {
+		m1 := randomSimilarity(rng, rng.Intn(2) == 1)
+		m2 := randomSimilarity(rng, rng.Intn(2) == 1)
+		angle := rng.Float64()*720 - 360
+
+		composed := TransformAngle(angle, MultiplyMatrices(m1, m2))
+		stepwise := TransformAngle(TransformAngle(angle, m2), m1)
+		if angleDiff(composed, stepwise) > 1e-6 {
+			t.Fatalf("case %d: composed %v != stepwise %v", i, composed, stepwise)
+		}
+
+		back := TransformAngle(TransformAngle(angle, m1), InvertMatrix(m1))
+		if angleDiff(back, angle) > 1e-6 {
+			t.Fatalf("case %d: inverse gave %v, want %v", i, back, NormalizeAngle(angle))
+		}
+	}
+}
+
 func BenchmarkTransformAngle(b *testing.B) {
 	transform := RotationDeg(270)
 	b.ResetTimer()
@@ -1087,3 +1220,114 @@ func BenchmarkTransformAngle(b *testing.B) {
 		_ = TransformAngle(float64(i%360), transform)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// AffineMatrix helpers
+// ---------------------------------------------------------------------------
+
+func TestAffineMatrix_Inverse(t *testing.T) {
+	m := CreateRotationTranslation(30, 5, -7)
+	inv, ok := m.Inverse()
+	if !ok {
+		t.Fatal("Inverse reported a rigid transform as singular")
+	}
+	if !matricesEqual(MultiplyMatrices(m, inv), Identity()) {
+		t.Errorf("M * M.Inverse() = %+v, want identity", MultiplyMatrices(m, inv))
+	}
+
+	if inv, ok := (AffineMatrix{A: 1, B: 2, C: 2, D: 4}).Inverse(); ok || inv != Identity() {
+		t.Errorf("singular Inverse() = %+v, %v; want identity, false", inv, ok)
+	}
+}
+
+func TestAffineMatrix_ApproxEqual(t *testing.T) {
+	m := CreateRotationTranslation(10, 3, 4)
+	near := m
+	near.Tx += 0.05
+	if !m.ApproxEqual(near, 0.1) {
+		t.Error("matrices 0.05 apart not equal within 0.1")
+	}
+	if m.ApproxEqual(near, 0.01) {
+		t.Error("matrices 0.05 apart equal within 0.01")
+	}
+	far := m
+	far.B += 0.5
+	if m.ApproxEqual(far, 0.1) {
+		t.Error("a linear coefficient difference was ignored")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	if got := Compose(); got != Identity() {
+		t.Errorf("Compose() = %+v, want identity", got)
+	}
+
+	// Rotate about the origin, then move: the first transform applies first
+	rotate, move := RotationDeg(90), Translation(10, 0)
+	got := TransformPoint(Point{X: 1, Y: 0}, Compose(rotate, move))
+	if !pointsEqual(got, Point{X: 10, Y: 1}) {
+		t.Errorf("Compose(rotate, move) maps (1,0) to %v, want (10,1)", got)
+	}
+	if !matricesEqual(Compose(rotate, move, Scale(2, 2)), MultiplyMatrices(Scale(2, 2), MultiplyMatrices(move, rotate))) {
+		t.Error("Compose of three transforms differs from the nested product")
+	}
+}
+
+func TestAffineMatrix_Decompose(t *testing.T) {
+	tests := []struct {
+		name string
+		m    AffineMatrix
+		want Decomposition
+	}{
+		{"identity", Identity(), Decomposition{ScaleX: 1, ScaleY: 1}},
+		{"rigid", CreateRotationTranslation(-30, 12, -4), Decomposition{Rotation: -30, ScaleX: 1, ScaleY: 1, Tx: 12, Ty: -4}},
+		{"half turn", RotationDeg(180), Decomposition{Rotation: 180, ScaleX: 1, ScaleY: 1}},
+		{"scaled", MultiplyMatrices(RotationDeg(45), Scale(2, 3)), Decomposition{Rotation: 45, ScaleX: 2, ScaleY: 3}},
+		{"mirrored", Scale(1, -1), Decomposition{ScaleX: 1, ScaleY: -1}},
+		{"sheared", AffineMatrix{A: 1, B: 0.5, D: 1}, Decomposition{ScaleX: 1, ScaleY: 1, Shear: 0.5}},
+		{"collapsed X", AffineMatrix{B: -2}, Decomposition{Rotation: 90, ScaleY: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.m.Decompose()
+			if !almostEqual(got.Rotation, tt.want.Rotation) || !almostEqual(got.ScaleX, tt.want.ScaleX) ||
+				!almostEqual(got.ScaleY, tt.want.ScaleY) || !almostEqual(got.Shear, tt.want.Shear) ||
+				got.Tx != tt.want.Tx || got.Ty != tt.want.Ty {
+				t.Errorf("Decompose() = %+v, want %+v", got, tt.want)
+			}
+			if back := got.Matrix(); !matricesEqual(back, tt.m) {
+				t.Errorf("Decompose().Matrix() = %+v, want %+v", back, tt.m)
+			}
+		})
+	}
+
+	// Random affine transforms survive the round trip
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		m := AffineMatrix{A: rng.NormFloat64(), B: rng.NormFloat64(), Tx: rng.NormFloat64(), C: rng.NormFloat64(), D: rng.NormFloat64(), Ty: rng.NormFloat64()}
+		if back := m.Decompose().Matrix(); !m.ApproxEqual(back, 1e-9) {
+			t.Fatalf("round trip of %+v gave %+v", m, back)
+		}
+	}
+}
+
+func TestLerp(t *testing.T) {
+	a := CreateRotationTranslation(350, 0, 0)
+	b := CreateRotationTranslation(30, 100, -20)
+
+	if !Lerp(a, b, 0).ApproxEqual(a, 1e-9) || !Lerp(a, b, 1).ApproxEqual(b, 1e-9) {
+		t.Error("Lerp does not return its endpoints at t=0 and t=1")
+	}
+
+	// Halfway turns the short way, through 10°, and stays rigid
+	mid := Lerp(a, b, 0.5).Decompose()
+	if !almostEqual(mid.Rotation, 10) {
+		t.Errorf("halfway rotation = %v, want 10", mid.Rotation)
+	}
+	if !almostEqual(mid.ScaleX, 1) || !almostEqual(mid.ScaleY, 1) {
+		t.Errorf("halfway scale = (%v, %v), want (1, 1)", mid.ScaleX, mid.ScaleY)
+	}
+	if !almostEqual(mid.Tx, 50) || !almostEqual(mid.Ty, -10) {
+		t.Errorf("halfway translation = (%v, %v), want (50, -10)", mid.Tx, mid.Ty)
+	}
+}
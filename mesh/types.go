@@ -10,6 +10,10 @@ type ValetudoMap struct {
 	PixelSize int         `json:"pixelSize"`
 	Layers    []MapLayer  `json:"layers"`
 	Entities  []MapEntity `json:"entities"`
+
+	// Downsample is the factor the layers were downsampled by while parsing
+	// (see DownsampleMap), 0 at full resolution. Such a map is never saved.
+	Downsample int `json:"-"`
 }
 
 // MapMetaData contains map metadata
@@ -42,6 +46,7 @@ type LayerMetaData struct {
 	Active     bool   `json:"active,omitempty"`
 	Source     string `json:"source,omitempty"`
 	PixelCount int    `json:"pixelCount,omitempty"`
+	Material   string `json:"material,omitempty"` // segment floor material, e.g. "carpet" or "tile"
 }
 
 // MapEntity represents a map entity (robot position, charger, path)
@@ -75,11 +80,12 @@ func Identity() AffineMatrix {
 
 // VacuumPosition represents a vacuum's position in world coordinates
 type VacuumPosition struct {
-	VacuumID  string  `json:"vacuumId"`
-	X         float64 `json:"x"`
-	Y         float64 `json:"y"`
-	Angle     float64 `json:"angle"`
-	Timestamp int64   `json:"timestamp"`
+	VacuumID    string  `json:"vacuumId"`
+	X           float64 `json:"x"`
+	Y           float64 `json:"y"`
+	Angle       float64 `json:"angle"`
+	Timestamp   int64   `json:"timestamp"`
+	DisplayName string  `json:"displayName,omitempty"`
 }
 
 // VacuumState tracks full state for a vacuum
@@ -106,27 +112,262 @@ type VacuumConfig struct {
 	ID          string             `yaml:"id" json:"id"`
 	Topic       string             `yaml:"topic" json:"topic"`
 	Color       string             `yaml:"color" json:"color"`
-	Rotation    *float64           `yaml:"rotation,omitempty" json:"rotation,omitempty"`       // Optional rotation hint/override (0, 90, 180, 270)
+	DisplayName string             `yaml:"displayName,omitempty" json:"displayName,omitempty"` // Optional friendly name for legends, logs and APIs
+	Icon        string             `yaml:"icon,omitempty" json:"icon,omitempty"`               // Optional robot marker: PNG path or circle, square, triangle, diamond, vacuum
+	Rotation    *float64           `yaml:"rotation,omitempty" json:"rotation,omitempty"`       // Optional rotation hint/override in degrees; any angle, e.g. 37.5
 	Translation *TranslationOffset `yaml:"translation,omitempty" json:"translation,omitempty"` // Optional manual translation override
-	ApiURL      *string            `yaml:"apiUrl,omitempty" json:"apiUrl,omitempty"`             // Optional API URL for fetching map data
+	Mirror      string             `yaml:"mirror,omitempty" json:"mirror,omitempty"`           // Optional axis the map is stored flipped along, x or y; reflected before ICP
+	ApiURL      *string            `yaml:"apiUrl,omitempty" json:"apiUrl,omitempty"`           // Optional API URL for fetching map data
+	Opacity     *float64           `yaml:"opacity,omitempty" json:"opacity,omitempty"`         // Optional map opacity in composite renders (0.0-1.0, default 1.0)
+	ZIndex      int                `yaml:"zIndex,omitempty" json:"zIndex,omitempty"`           // Optional stacking order; higher draws on top (default 0)
+	MQTT        *BrokerConfig      `yaml:"mqtt,omitempty" json:"mqtt,omitempty"`               // Optional broker for this vacuum's topics (default: mqtt.broker)
+	Frozen      bool               `yaml:"frozen,omitempty" json:"frozen,omitempty"`           // Keep the cached transform; automatic ICP never replaces it
+	Accumulate  bool               `yaml:"accumulate,omitempty" json:"accumulate,omitempty"`   // Merge each run into a per-vacuum map, for robots that map part of the home per run
 }
 
 // Config represents the full configuration file
 type Config struct {
-	MQTT             MQTTConfig     `yaml:"mqtt" json:"mqtt"`
-	Reference        string         `yaml:"reference,omitempty" json:"reference,omitempty"` // Optional reference vacuum ID
-	Vacuums          []VacuumConfig `yaml:"vacuums" json:"vacuums"`
-	GridSpacing      float64        `yaml:"gridSpacing,omitempty" json:"gridSpacing,omitempty"`           // Grid line spacing in mm (default 1000)
-	VectorResolution float64        `yaml:"vectorResolution,omitempty" json:"vectorResolution,omitempty"` // Vector PNG DPI (default 300)
+	MQTT             MQTTConfig      `yaml:"mqtt" json:"mqtt"`
+	Reference        string          `yaml:"reference,omitempty" json:"reference,omitempty"` // Optional reference vacuum ID
+	Vacuums          []VacuumConfig  `yaml:"vacuums" json:"vacuums"`
+	GridSpacing      float64         `yaml:"gridSpacing,omitempty" json:"gridSpacing,omitempty"`           // Grid line spacing in mm (default 1000)
+	VectorResolution float64         `yaml:"vectorResolution,omitempty" json:"vectorResolution,omitempty"` // Vector PNG DPI (default 300)
+	Memory           MemoryConfig    `yaml:"memory,omitempty" json:"memory,omitempty"`                     // Optional memory limits for constrained devices
+	Storage          StorageConfig   `yaml:"storage,omitempty" json:"storage,omitempty"`                   // Optional storage backend (default: files in data-dir)
+	Cluster          ClusterConfig   `yaml:"cluster,omitempty" json:"cluster,omitempty"`                   // Optional multi-instance coordination
+	HTTP             HTTPConfig      `yaml:"http,omitempty" json:"http,omitempty"`                         // Optional HTTP server limits
+	Legend           LegendConfig    `yaml:"legend,omitempty" json:"legend,omitempty"`                     // Optional legend placement and styling
+	Overlay          OverlayConfig   `yaml:"overlay,omitempty" json:"overlay,omitempty"`                   // Optional metric grid and scale bar on raster renders
+	Font             FontConfig      `yaml:"font,omitempty" json:"font,omitempty"`                         // Optional typeface of the text on raster renders
+	Floorplan        FloorplanConfig `yaml:"floorplan,omitempty" json:"floorplan,omitempty"`               // Optional architectural drawing beneath raster renders
+	Zones            []ZoneConfig    `yaml:"zones,omitempty" json:"zones,omitempty"`                       // Optional named cleaning zones in world coordinates
+	Unify            UnifyConfig     `yaml:"unify,omitempty" json:"unify,omitempty"`                       // Optional tuning of the unified vector map
+	ICP              ICPBudgetConfig `yaml:"icp,omitempty" json:"icp,omitempty"`                           // Optional limits on calibration time
+	Retention        RetentionConfig `yaml:"retention,omitempty" json:"retention,omitempty"`               // Optional cleanup of old files in the data directory
+	Profiles         []ProfileConfig `yaml:"profiles,omitempty" json:"profiles,omitempty"`                 // Optional named compositions of a subset of vacuums
+	ExportPattern    string          `yaml:"exportPattern,omitempty" json:"exportPattern,omitempty"`       // Optional regexp with (?P<id>...) for export files named by other tools
+	Battery          BatteryConfig   `yaml:"battery,omitempty" json:"battery,omitempty"`                   // Optional low-battery alerts
+	Health           HealthConfig    `yaml:"health,omitempty" json:"health,omitempty"`                     // Optional thresholds of the /health vacuum status
+	Tracing          TracingConfig   `yaml:"tracing,omitempty" json:"tracing,omitempty"`                   // Optional logging of slow operations
+	Model3D          Model3DConfig   `yaml:"model3d,omitempty" json:"model3d,omitempty"`                   // Optional dimensions of the extruded 3D floorplan
 }
 
 // MQTTConfig holds MQTT connection settings
 type MQTTConfig struct {
-	Broker        string `yaml:"broker" json:"broker"`
-	PublishPrefix string `yaml:"publishPrefix" json:"publishPrefix"`
-	ClientID      string `yaml:"clientId" json:"clientId"`
-	Username      string `yaml:"username,omitempty" json:"username,omitempty"`
-	Password      string `yaml:"password,omitempty" json:"password,omitempty"`
+	Broker          string        `yaml:"broker" json:"broker"`
+	PublishPrefix   string        `yaml:"publishPrefix" json:"publishPrefix"`
+	ClientID        string        `yaml:"clientId" json:"clientId"`
+	Username        string        `yaml:"username,omitempty" json:"username,omitempty"`
+	Password        string        `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile    string        `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty"`       // Read the password from this file when password is unset, e.g. a Docker secret
+	Output          *BrokerConfig `yaml:"output,omitempty" json:"output,omitempty"`                   // Optional broker for positions and map changes (default: this broker)
+	SummaryInterval string        `yaml:"summaryInterval,omitempty" json:"summaryInterval,omitempty"` // Go duration between republishing {prefix}/map/summary (default 5m, 0s = only on change)
+	Publish         PublishConfig `yaml:"publish,omitempty" json:"publish,omitempty"`                 // Optional QoS and retain flags of published messages
+}
+
+// PublishConfig sets the QoS level and retain flag of the messages the
+// Publisher sends, for all topics and per topic. Topic names are those in
+// PublishTopics.
+type PublishConfig struct {
+	QoS    int                       `yaml:"qos,omitempty" json:"qos,omitempty"`       // 0, 1 or 2 (default 0)
+	Retain *bool                     `yaml:"retain,omitempty" json:"retain,omitempty"` // Retain messages on the broker (default true)
+	Topics map[string]PublishOptions `yaml:"topics,omitempty" json:"topics,omitempty"` // Overrides by topic name, e.g. positions
+}
+
+// PublishOptions overrides the QoS level or retain flag of one topic. Unset
+// fields fall back to the PublishConfig defaults.
+type PublishOptions struct {
+	QoS    *int  `yaml:"qos,omitempty" json:"qos,omitempty"`
+	Retain *bool `yaml:"retain,omitempty" json:"retain,omitempty"`
+}
+
+// BrokerConfig is a connection to an MQTT broker besides mqtt.broker: the
+// broker of an isolated group of vacuums, or the output broker that Home
+// Assistant reads.
+type BrokerConfig struct {
+	Broker       string `yaml:"broker" json:"broker"`
+	ClientID     string `yaml:"clientId,omitempty" json:"clientId,omitempty"` // Default: mqtt.clientId with the vacuum ID or "output" appended
+	Username     string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password     string `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile string `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty"` // Read the password from this file when password is unset
+}
+
+// MemoryConfig holds memory and CPU tuning options for constrained devices
+type MemoryConfig struct {
+	BudgetMB   int  `yaml:"budgetMB,omitempty" json:"budgetMB,omitempty"`     // Soft memory limit in MiB (0 = unlimited)
+	Downsample int  `yaml:"downsample,omitempty" json:"downsample,omitempty"` // Layer pixel downsampling factor applied at parse time (0/1 = off)
+	FillFloors bool `yaml:"fillFloors,omitempty" json:"fillFloors,omitempty"` // Render floors as filled areas instead of cell by cell
+}
+
+// StorageConfig selects where calibration and map state is persisted
+type StorageConfig struct {
+	Backend            string `yaml:"backend,omitempty" json:"backend,omitempty"`                       // file (default), sqlite, or memory
+	Path               string `yaml:"path,omitempty" json:"path,omitempty"`                             // SQLite database path (default: {data-dir}/tudomesh.db)
+	Compress           bool   `yaml:"compress,omitempty" json:"compress,omitempty"`                     // gzip map exports (file backend)
+	MinWriteInterval   string `yaml:"minWriteInterval,omitempty" json:"minWriteInterval,omitempty"`     // Go duration between saves of one vacuum's map (default 30s)
+	CalibrationHistory int    `yaml:"calibrationHistory,omitempty" json:"calibrationHistory,omitempty"` // previous calibration caches kept as backups (file backend; default 5, -1 = none)
+}
+
+// RetentionConfig limits how many cached files are kept in the data directory
+type RetentionConfig struct {
+	MaxFiles int    `yaml:"maxFiles,omitempty" json:"maxFiles,omitempty"` // Map exports and raw PNGs kept per vacuum (0 = unlimited)
+	MaxAge   string `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`     // Go duration; older files are removed, e.g. 720h (empty = unlimited)
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"` // Go duration between cleanups in service mode (default 1h)
+}
+
+// ClusterConfig enables leader election between redundant instances sharing a broker
+type ClusterConfig struct {
+	Enabled          bool   `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	InstanceID       string `yaml:"instanceId,omitempty" json:"instanceId,omitempty"`             // Unique per instance (default: hostname)
+	HeartbeatSeconds int    `yaml:"heartbeatSeconds,omitempty" json:"heartbeatSeconds,omitempty"` // Leader lock refresh interval (default 10)
+	LeaseSeconds     int    `yaml:"leaseSeconds,omitempty" json:"leaseSeconds,omitempty"`         // Lock expiry without heartbeat (default 30)
+}
+
+// TracingConfig controls the stage timings logged for slow renders, MQTT
+// map messages, calibrations and unified map rebuilds
+type TracingConfig struct {
+	SlowThreshold string `yaml:"slowThreshold,omitempty" json:"slowThreshold,omitempty"` // Go duration; slower operations log their stage timings (default 1s, 0s = log every operation)
+}
+
+// Model3DConfig sets the dimensions of the 3D floorplan served as glTF and
+// OBJ, in mm.
+type Model3DConfig struct {
+	WallHeight     float64 `yaml:"wallHeight,omitempty" json:"wallHeight,omitempty"`         // Height of the extruded walls (default 2400)
+	WallThickness  float64 `yaml:"wallThickness,omitempty" json:"wallThickness,omitempty"`   // Thickness of the walls (default 80)
+	FloorThickness float64 `yaml:"floorThickness,omitempty" json:"floorThickness,omitempty"` // Thickness of the floor slabs (default 20)
+}
+
+// HTTPConfig holds HTTP server protection settings
+type HTTPConfig struct {
+	MaxConcurrentRenders int             `yaml:"maxConcurrentRenders,omitempty" json:"maxConcurrentRenders,omitempty"` // Renders running at once (default 2)
+	RenderQueueSeconds   int             `yaml:"renderQueueSeconds,omitempty" json:"renderQueueSeconds,omitempty"`     // Max wait for a render slot before 503 (default 5)
+	RateLimit            RateLimitConfig `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+	CORS                 CORSConfig      `yaml:"cors,omitempty" json:"cors,omitempty"`
+	Auth                 AuthConfig      `yaml:"auth,omitempty" json:"auth,omitempty"`
+}
+
+// Token roles: read tokens may call GET endpoints, admin tokens every endpoint
+const (
+	TokenRoleRead  = "read"
+	TokenRoleAdmin = "admin"
+)
+
+// AuthConfig enables bearer-token authentication. Without any token every
+// endpoint is open.
+type AuthConfig struct {
+	AdminToken   string     `yaml:"adminToken,omitempty" json:"adminToken,omitempty"`     // Token for every endpoint, including calibration and commands
+	ReadToken    string     `yaml:"readToken,omitempty" json:"readToken,omitempty"`       // Token for read-only endpoints
+	Tokens       []APIToken `yaml:"tokens,omitempty" json:"tokens,omitempty"`             // Additional named tokens
+	ProtectReads bool       `yaml:"protectReads,omitempty" json:"protectReads,omitempty"` // Also require a token for maps and other GET endpoints (/health stays open)
+}
+
+// APIToken is a named bearer token with a role
+type APIToken struct {
+	Name  string `yaml:"name" json:"name"`
+	Token string `yaml:"token" json:"token"`
+	Role  string `yaml:"role" json:"role"` // read or admin
+}
+
+// Enabled reports whether any token is configured.
+func (c *AuthConfig) Enabled() bool {
+	return c.AdminToken != "" || c.ReadToken != "" || len(c.Tokens) > 0
+}
+
+// CORSConfig controls cross-origin access for browser dashboards
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins,omitempty" json:"allowedOrigins,omitempty"` // Origins allowed to fetch; "*" allows any (empty = CORS disabled)
+	AllowedMethods []string `yaml:"allowedMethods,omitempty" json:"allowedMethods,omitempty"` // Default: GET, HEAD, OPTIONS
+	AllowedHeaders []string `yaml:"allowedHeaders,omitempty" json:"allowedHeaders,omitempty"` // Request headers allowed in preflight (default: echo requested)
+	MaxAgeSeconds  int      `yaml:"maxAgeSeconds,omitempty" json:"maxAgeSeconds,omitempty"`   // Preflight cache lifetime (default 600)
+}
+
+// RateLimitConfig is a per-client token bucket for render endpoints
+type RateLimitConfig struct {
+	RequestsPerMinute int `yaml:"requestsPerMinute,omitempty" json:"requestsPerMinute,omitempty"` // Sustained rate per client IP (0 = unlimited)
+	Burst             int `yaml:"burst,omitempty" json:"burst,omitempty"`                         // Requests allowed in a burst (default: requestsPerMinute/6, min 1)
+}
+
+// LegendConfig controls the legend drawn on raster renders
+type LegendConfig struct {
+	Enabled  *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"`   // Draw the legend (default true)
+	Position string `yaml:"position,omitempty" json:"position,omitempty"` // top-left (default), top-right, bottom-left, bottom-right
+	Scale    int    `yaml:"scale,omitempty" json:"scale,omitempty"`       // Integer font scale for high-resolution exports (default 1)
+}
+
+// OverlayConfig controls the metric grid, scale bar and handoff zones drawn
+// on raster renders. Grid spacing comes from the top-level gridSpacing
+// setting.
+type OverlayConfig struct {
+	Grid       bool  `yaml:"grid,omitempty" json:"grid,omitempty"`             // Draw grid lines every gridSpacing mm
+	GridLabels *bool `yaml:"gridLabels,omitempty" json:"gridLabels,omitempty"` // Label grid lines in meters (default true)
+	ScaleBar   bool  `yaml:"scaleBar,omitempty" json:"scaleBar,omitempty"`     // Draw a scale bar
+	Handoff    bool  `yaml:"handoff,omitempty" json:"handoff,omitempty"`       // Hatch areas covered by more than one vacuum
+}
+
+// FontConfig selects a scalable typeface for the legend, grid labels and
+// scale bar of raster renders. Without either field the built-in 7x13
+// bitmap font is used.
+type FontConfig struct {
+	Path string  `yaml:"path,omitempty" json:"path,omitempty"` // TrueType or OpenType file (default: embedded Go Regular)
+	Size float64 `yaml:"size,omitempty" json:"size,omitempty"` // Text height in pixels at legend scale 1 (default 13)
+}
+
+// FloorplanConfig places an architectural drawing in the reference map's
+// coordinates. The image's top-left corner sits at (offsetX, offsetY).
+type FloorplanConfig struct {
+	Image      string   `yaml:"image,omitempty" json:"image,omitempty"`           // PNG or JPEG path
+	MMPerPixel float64  `yaml:"mmPerPixel,omitempty" json:"mmPerPixel,omitempty"` // Drawing scale (required with image)
+	OffsetX    float64  `yaml:"offsetX,omitempty" json:"offsetX,omitempty"`       // mm
+	OffsetY    float64  `yaml:"offsetY,omitempty" json:"offsetY,omitempty"`       // mm
+	Rotation   float64  `yaml:"rotation,omitempty" json:"rotation,omitempty"`     // Degrees about the top-left corner
+	Opacity    *float64 `yaml:"opacity,omitempty" json:"opacity,omitempty"`       // 0.0-1.0 (default 0.5)
+	Align      bool     `yaml:"align,omitempty" json:"align,omitempty"`           // Snap robot maps to the drawing's walls
+}
+
+// UnifyConfig tunes how vacuum features are merged into the unified map
+type UnifyConfig struct {
+	SegmentMerge SegmentMergeConfig `yaml:"segmentMerge,omitempty" json:"segmentMerge,omitempty"`
+	DoubleWalls  DoubleWallConfig   `yaml:"doubleWalls,omitempty" json:"doubleWalls,omitempty"`
+}
+
+// SegmentMergeConfig controls merging of room segments that one vacuum splits
+// and another sees whole
+type SegmentMergeConfig struct {
+	Enabled        *bool   `yaml:"enabled,omitempty" json:"enabled,omitempty"`               // Merge contained segments (default true)
+	MinContainment float64 `yaml:"minContainment,omitempty" json:"minContainment,omitempty"` // 0-1, fraction of a segment inside a larger one (default 0.7)
+}
+
+// DoubleWallConfig controls collapsing of the two faces of a wall, seen by
+// vacuums on either side of it, into one
+type DoubleWallConfig struct {
+	Enabled      *bool   `yaml:"enabled,omitempty" json:"enabled,omitempty"`           // Collapse double walls (default true)
+	MaxThickness float64 `yaml:"maxThickness,omitempty" json:"maxThickness,omitempty"` // mm, farthest apart the two faces may be (default 250)
+	MinOverlap   float64 `yaml:"minOverlap,omitempty" json:"minOverlap,omitempty"`     // 0-1, fraction of the shorter face alongside the longer (default 0.6)
+}
+
+// BatteryConfig controls the alert published when a robot runs low on
+// battery away from its charger
+type BatteryConfig struct {
+	Alerts     *bool   `yaml:"alerts,omitempty" json:"alerts,omitempty"`         // Publish low-battery alerts (default true)
+	AlertBelow int     `yaml:"alertBelow,omitempty" json:"alertBelow,omitempty"` // Percent below which a robot away from its charger raises an alert (default 20)
+	DockRadius float64 `yaml:"dockRadius,omitempty" json:"dockRadius,omitempty"` // mm from its charger within which a robot counts as docked (default 500)
+}
+
+// HealthConfig sets when /health reports a vacuum as degraded
+type HealthConfig struct {
+	StaleAfter  string  `yaml:"staleAfter,omitempty" json:"staleAfter,omitempty"`   // Go duration without messages after which a vacuum is stale (default 24h)
+	MinICPScore float64 `yaml:"minICPScore,omitempty" json:"minICPScore,omitempty"` // Alignment score below which calibration is poor (default 0.3)
+}
+
+// ICPBudgetConfig bounds how long one map alignment may take, so calibration
+// on slow hardware does not hold up message handling, and tunes what it
+// aligns by
+type ICPBudgetConfig struct {
+	MaxDuration   string               `yaml:"maxDuration,omitempty" json:"maxDuration,omitempty"`     // Go duration (e.g. "10s") for the rotation sweep and refinement together (default unlimited)
+	MaxIterations int                  `yaml:"maxIterations,omitempty" json:"maxIterations,omitempty"` // Iterations per ICP pass (default 50)
+	Target        string               `yaml:"target,omitempty" json:"target,omitempty"`               // Align docked vacuums against "unified" (default) or "reference"
+	Weights       FeatureWeightsConfig `yaml:"weights,omitempty" json:"weights,omitempty"`             // Optional share of each feature class in the points ICP fits and scores
+	Init          string               `yaml:"init,omitempty" json:"init,omitempty"`                   // Starting translation per rotation: "sampled" (default) or "phase" correlation
 }
 
 // GetVacuumByID returns the vacuum config for the given ID
@@ -139,6 +380,48 @@ func (c *Config) GetVacuumByID(id string) *VacuumConfig {
 	return nil
 }
 
+// DisplayName returns the configured display name for a vacuum, falling
+// back to its ID
+func (c *Config) DisplayName(id string) string {
+	if c != nil {
+		if vc := c.GetVacuumByID(id); vc != nil && vc.DisplayName != "" {
+			return vc.DisplayName
+		}
+	}
+	return id
+}
+
+// IsFrozen reports whether the vacuum's cached transform is frozen, so
+// automatic calibration must leave it alone
+func (c *Config) IsFrozen(id string) bool {
+	if c == nil {
+		return false
+	}
+	vc := c.GetVacuumByID(id)
+	return vc != nil && vc.Frozen
+}
+
+// MirrorAxis returns the axis the vacuum's map is configured as mirrored
+// along, or "" if it is not
+func (c *Config) MirrorAxis(id string) string {
+	if c == nil {
+		return ""
+	}
+	if vc := c.GetVacuumByID(id); vc != nil {
+		return vc.Mirror
+	}
+	return ""
+}
+
+// ParseOptions returns the options maps are parsed with: the memory
+// downsampling factor
+func (c *Config) ParseOptions() ParseOptions {
+	if c == nil {
+		return ParseOptions{}
+	}
+	return ParseOptions{Downsample: c.Memory.Downsample}
+}
+
 // GetReference returns the reference vacuum ID from config or empty string
 func (c *Config) GetReference() string {
 	return c.Reference
@@ -167,9 +450,29 @@ func (vc *VacuumConfig) GetTranslation() TranslationOffset {
 
 // VacuumCalibration stores per-vacuum calibration metadata alongside the transform.
 type VacuumCalibration struct {
-	Transform            AffineMatrix `json:"transform"`
-	LastUpdated          int64        `json:"lastUpdated"`
-	MapAreaAtCalibration int          `json:"mapAreaAtCalibration"`
+	Transform            AffineMatrix         `json:"transform"`
+	LastUpdated          int64                `json:"lastUpdated"`
+	MapAreaAtCalibration int                  `json:"mapAreaAtCalibration"`
+	ICPScore             float64              `json:"icpScore,omitempty"`     // inlier fraction of the alignment; 0 when unknown
+	Rotation             *CachedRotation      `json:"rotation,omitempty"`     // initial rotation the alignment found, reused while the maps are unchanged
+	Covariance           *TransformCovariance `json:"covariance,omitempty"`   // uncertainty of the transform (see ICPResult.Covariance); nil when unknown
+	Alternatives         []RotationHypothesis `json:"alternatives,omitempty"` // other rotations that aligned about as well; the rotation is ambiguous
+}
+
+// RotationHypothesis is a candidate transform from a different rotation
+// that aligned about as well as the calibrated one, as in a symmetric room.
+type RotationHypothesis struct {
+	Transform AffineMatrix `json:"transform"`
+	Rotation  float64      `json:"rotation"` // Degrees, of the transform
+	Score     float64      `json:"score"`
+}
+
+// CachedRotation is the initial rotation an alignment settled on, with the
+// content hashes (see MapContentHash) of the two maps it compared.
+type CachedRotation struct {
+	SourceHash string  `json:"sourceHash"`
+	TargetHash string  `json:"targetHash"`
+	Rotation   float64 `json:"rotation"` // Degrees
 }
 
 // CalibrationData stores calibration matrices for all vacuums.
@@ -187,9 +490,9 @@ type CalibrationData struct {
 func (c *CalibrationData) UnmarshalJSON(data []byte) error {
 	// Step 1: Unmarshal the envelope with raw vacuum entries.
 	var envelope struct {
-		ReferenceVacuum string                        `json:"referenceVacuum"`
-		Vacuums         map[string]json.RawMessage    `json:"vacuums"`
-		LastUpdated     int64                         `json:"lastUpdated"`
+		ReferenceVacuum string                     `json:"referenceVacuum"`
+		Vacuums         map[string]json.RawMessage `json:"vacuums"`
+		LastUpdated     int64                      `json:"lastUpdated"`
 	}
 	if err := json.Unmarshal(data, &envelope); err != nil {
 		return err
@@ -0,0 +1,74 @@
+package mesh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// UnifiedFeatureTypes are the feature lists of a UnifiedMap by JSON name.
+var UnifiedFeatureTypes = []string{"walls", "floors", "segments", "materials"}
+
+// UnifiedMapFilter selects features of a UnifiedMap.
+type UnifiedMapFilter struct {
+	MinConfidence float64  // Keep features at or above this confidence
+	Types         []string // Feature lists to keep (see UnifiedFeatureTypes); empty keeps all
+	VacuumID      string   // Keep features this vacuum observed; empty keeps all
+}
+
+// ParseUnifiedFeatureTypes splits a comma-separated list of feature types,
+// rejecting names not in UnifiedFeatureTypes.
+func ParseUnifiedFeatureTypes(s string) ([]string, error) {
+	var types []string
+	for _, part := range strings.Split(s, ",") {
+		t := strings.ToLower(strings.TrimSpace(part))
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(UnifiedFeatureTypes, t) {
+			return nil, fmt.Errorf("unknown feature type %q (want %s)", part, strings.Join(UnifiedFeatureTypes, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// Filter returns a copy of um holding only the features that pass f, with
+// their sources and the map's metadata unchanged. Feature lists left out
+// by f.Types are empty rather than nil.
+func (um *UnifiedMap) Filter(f UnifiedMapFilter) *UnifiedMap {
+	keep := func(name string, features []*UnifiedFeature) []*UnifiedFeature {
+		out := []*UnifiedFeature{}
+		if len(f.Types) > 0 && !slices.Contains(f.Types, name) {
+			return out
+		}
+		for _, uf := range features {
+			if uf.Confidence < f.MinConfidence {
+				continue
+			}
+			if f.VacuumID != "" && !observedBy(uf, f.VacuumID) {
+				continue
+			}
+			out = append(out, uf)
+		}
+		return out
+	}
+
+	return &UnifiedMap{
+		Walls:     keep("walls", um.Walls),
+		Floors:    keep("floors", um.Floors),
+		Segments:  keep("segments", um.Segments),
+		Materials: keep("materials", um.Materials),
+		Metadata:  um.Metadata,
+	}
+}
+
+// observedBy reports whether vacuumID is one of the feature's sources.
+func observedBy(uf *UnifiedFeature, vacuumID string) bool {
+	for _, s := range uf.Sources {
+		if s.VacuumID == vacuumID {
+			return true
+		}
+	}
+	return false
+}
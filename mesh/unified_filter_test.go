@@ -0,0 +1,50 @@
+package mesh
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUnifiedFeatureTypes(t *testing.T) {
+	got, err := ParseUnifiedFeatureTypes("Walls, segments,")
+	if err != nil || !reflect.DeepEqual(got, []string{"walls", "segments"}) {
+		t.Errorf("got %v, %v; want [walls segments]", got, err)
+	}
+	if _, err := ParseUnifiedFeatureTypes("walls,doors"); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+func TestUnifiedMapFilter(t *testing.T) {
+	feature := func(confidence float64, vacuums ...string) *UnifiedFeature {
+		uf := &UnifiedFeature{Confidence: confidence}
+		for _, id := range vacuums {
+			uf.Sources = append(uf.Sources, FeatureSource{VacuumID: id, ICPScore: 0.9})
+		}
+		return uf
+	}
+	um := &UnifiedMap{
+		Walls:    []*UnifiedFeature{feature(1, "lion", "tiger"), feature(0.5, "tiger")},
+		Floors:   []*UnifiedFeature{feature(1, "lion")},
+		Segments: []*UnifiedFeature{feature(0.7, "lion"), feature(0.4, "lion")},
+		Metadata: UnifiedMetadata{Version: 3},
+	}
+
+	got := um.Filter(UnifiedMapFilter{MinConfidence: 0.6, Types: []string{"walls", "segments"}, VacuumID: "lion"})
+	if len(got.Walls) != 1 || got.Walls[0] != um.Walls[0] {
+		t.Errorf("walls = %v, want the confident wall lion saw", got.Walls)
+	}
+	if len(got.Segments) != 1 || got.Segments[0] != um.Segments[0] {
+		t.Errorf("segments = %v, want the confident segment", got.Segments)
+	}
+	if got.Floors == nil || len(got.Floors) != 0 || got.Materials == nil {
+		t.Errorf("floors/materials = %v/%v, want empty lists", got.Floors, got.Materials)
+	}
+	if len(got.Walls[0].Sources) != 2 || got.Metadata.Version != 3 {
+		t.Error("sources or metadata not kept")
+	}
+
+	if all := um.Filter(UnifiedMapFilter{}); len(all.Walls) != 2 || len(all.Floors) != 1 || len(all.Segments) != 2 {
+		t.Errorf("empty filter dropped features: %+v", all)
+	}
+}
@@ -0,0 +1,284 @@
+package mesh
+
+import (
+	"encoding/json"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+)
+
+// Defaults for UnifiedRenderer.
+const (
+	DefaultUnifiedMMPerPixel = 25.0 // twice the detail of a typical 5cm Valetudo grid
+	DefaultUnifiedWallWidth  = 75.0 // mm
+)
+
+// UnifiedRenderer draws the unified map as a greyscale floor plan. Unlike
+// CompositeRenderer.RenderGreyscale, which overlays every vacuum's raw
+// pixels and doubles walls wherever alignment is off by a few centimeters,
+// it draws the consensus floors and walls once, like an architect's plan.
+type UnifiedRenderer struct {
+	Map            *UnifiedMap
+	GlobalRotation float64   // degrees, about the center of the map
+	MMPerPixel     float64   // resolution; coarsened to fit MaxDimension
+	WallWidth      float64   // mm
+	Padding        int       // pixels around the plan
+	MaxDimension   int       // largest width or height; 0 uses DefaultMaxRenderDimension
+	Heat           *HeatGrid // visit counts drawn over the floors; nil draws none
+}
+
+// NewUnifiedRenderer creates a renderer for um with default settings.
+func NewUnifiedRenderer(um *UnifiedMap) *UnifiedRenderer {
+	return &UnifiedRenderer{
+		Map:        um,
+		MMPerPixel: DefaultUnifiedMMPerPixel,
+		WallWidth:  DefaultUnifiedWallWidth,
+		Padding:    30,
+	}
+}
+
+// HasDrawableContent reports whether the unified map has any floor or wall.
+func (r *UnifiedRenderer) HasDrawableContent() bool {
+	if r.Map == nil {
+		return false
+	}
+	for _, features := range [][]*UnifiedFeature{r.Map.Floors, r.Map.Segments, r.Map.Walls} {
+		for _, f := range features {
+			if len(geometryPaths(f.Geometry)) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Render draws the unified floors and segments in GreyscaleFloor, hatches
+// each material region with its pattern (see materialHatch), and draws the
+// walls over them in GreyscaleWall, on GreyscaleBG. Image Y grows with
+// world Y, as in the other raster renders.
+func (r *UnifiedRenderer) Render() *image.RGBA {
+	var floors, walls [][]Point
+	var materials []string
+	var materialPaths [][][]Point
+	if r.Map != nil {
+		for _, f := range r.Map.Floors {
+			floors = append(floors, geometryPaths(f.Geometry)...)
+		}
+		for _, f := range r.Map.Segments {
+			floors = append(floors, geometryPaths(f.Geometry)...)
+		}
+		for _, f := range r.Map.Walls {
+			walls = append(walls, geometryPaths(f.Geometry)...)
+		}
+		for _, f := range r.Map.Materials {
+			material, _ := f.Properties["material"].(string)
+			if paths := geometryPaths(f.Geometry); len(paths) > 0 {
+				materials = append(materials, material)
+				materialPaths = append(materialPaths, paths)
+			}
+		}
+	}
+
+	// Rotate about the center of the unrotated plan, then frame the result
+	minX, minY, maxX, maxY := pathsBounds(append(floors, walls...))
+	centerX, centerY := (minX+maxX)/2, (minY+maxY)/2
+	rotate := func(p Point) Point {
+		if r.GlobalRotation == 0 {
+			return p
+		}
+		rad := r.GlobalRotation * math.Pi / 180
+		x, y := p.X-centerX, p.Y-centerY
+		return Point{X: x*math.Cos(rad) - y*math.Sin(rad) + centerX, Y: x*math.Sin(rad) + y*math.Cos(rad) + centerY}
+	}
+	for _, paths := range append([][][]Point{floors, walls}, materialPaths...) {
+		for _, path := range paths {
+			for i, p := range path {
+				path[i] = rotate(p)
+			}
+		}
+	}
+	minX, minY, maxX, maxY = pathsBounds(append(floors, walls...))
+
+	mmPerPixel := r.MMPerPixel
+	if mmPerPixel <= 0 {
+		mmPerPixel = DefaultUnifiedMMPerPixel
+	}
+	maxDim := r.MaxDimension
+	if maxDim <= 0 {
+		maxDim = DefaultMaxRenderDimension
+	}
+	span := math.Max(maxX-minX, maxY-minY)
+	if avail := float64(maxDim - 2*r.Padding); span > 0 && avail > 0 && span/mmPerPixel > avail {
+		mmPerPixel = span / avail
+	}
+
+	width, height := 2*r.Padding+1, 2*r.Padding+1
+	if span > 0 {
+		width = int(math.Ceil((maxX-minX)/mmPerPixel)) + 2*r.Padding
+		height = int(math.Ceil((maxY-minY)/mmPerPixel)) + 2*r.Padding
+	}
+	img := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: GreyscaleBG}, image.Point{}, draw.Src)
+	if span <= 0 {
+		return img
+	}
+
+	// One canvas unit per pixel. The canvas Y axis points up, so world Y is
+	// flipped to keep north (lower Y) at the top of the image.
+	toCanvas := func(p Point) (float64, float64) {
+		return (p.X-minX)/mmPerPixel + float64(r.Padding), float64(height) - ((p.Y-minY)/mmPerPixel + float64(r.Padding))
+	}
+	ras := rasterizer.FromImage(img, canvas.DPMM(1), canvas.DefaultColorSpace)
+
+	floorStyle := canvas.DefaultStyle
+	floorStyle.Fill = canvas.Paint{Color: nrgbaToRGBA(GreyscaleFloor)}
+	floorStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+	floorStyle.FillRule = canvas.EvenOdd
+	ras.RenderPath(canvasPath(floors, toCanvas, true), floorStyle, canvas.Identity)
+
+	// Hatch each material region through a mask of it: the lines are
+	// computed per pixel rather than clipped as paths, which the canvas
+	// package's path intersection does not always survive
+	spacing := math.Max(materialHatchSpacing/mmPerPixel, 3)
+	for i, material := range materials {
+		mask := image.NewRGBA(img.Bounds())
+		maskRas := rasterizer.FromImage(mask, canvas.DPMM(1), canvas.DefaultColorSpace)
+		maskStyle := canvas.DefaultStyle
+		maskStyle.Fill = canvas.Paint{Color: canvas.White}
+		maskStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+		maskStyle.FillRule = canvas.EvenOdd
+		maskRas.RenderPath(canvasPath(materialPaths[i], toCanvas, true), maskStyle, canvas.Identity)
+		maskRas.Close()
+
+		families := materialHatch(material)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				// Image Y points down, so angles turn the other way
+				if mask.RGBAAt(x, y).A >= 128 && onHatch(families, float64(x), float64(y), -r.GlobalRotation, spacing, 1) {
+					img.SetRGBA(x, y, nrgbaToRGBA(GreyscaleMaterial))
+				}
+			}
+		}
+	}
+
+	// Heat cells, least visited first, each level as one path
+	if r.Heat != nil && r.Heat.CellSize > 0 {
+		size := r.Heat.CellSize
+		for level, cells := range r.Heat.levels() {
+			if len(cells) == 0 {
+				continue
+			}
+			squares := make([][]Point, len(cells))
+			for i, c := range cells {
+				x0, y0 := float64(c.X)*size, float64(c.Y)*size
+				squares[i] = []Point{
+					rotate(Point{X: x0, Y: y0}), rotate(Point{X: x0 + size, Y: y0}),
+					rotate(Point{X: x0 + size, Y: y0 + size}), rotate(Point{X: x0, Y: y0 + size}),
+				}
+			}
+			heatStyle := canvas.DefaultStyle
+			heatStyle.Fill = canvas.Paint{Color: nrgbaToRGBA(heatColor(level))}
+			heatStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+			heatStyle.FillRule = canvas.NonZero
+			ras.RenderPath(canvasPath(squares, toCanvas, true), heatStyle, canvas.Identity)
+		}
+	}
+
+	wallStyle := canvas.DefaultStyle
+	wallStyle.Fill = canvas.Paint{Color: canvas.Transparent}
+	wallStyle.Stroke = canvas.Paint{Color: nrgbaToRGBA(GreyscaleWall)}
+	wallStyle.StrokeWidth = math.Max(r.WallWidth/mmPerPixel, 1)
+	wallStyle.StrokeCapper = canvas.SquareCapper{}
+	wallStyle.StrokeJoiner = canvas.MiterJoiner{GapJoiner: canvas.BevelJoiner{}, Limit: 4}
+	ras.RenderPath(canvasPath(walls, toCanvas, false), wallStyle, canvas.Identity)
+	ras.Close()
+
+	return img
+}
+
+// canvasPath joins paths into one canvas path, closing each when closed is
+// set.
+func canvasPath(paths [][]Point, project func(Point) (float64, float64), closed bool) *canvas.Path {
+	cp := &canvas.Path{}
+	for _, path := range paths {
+		if len(path) < 2 {
+			continue
+		}
+		for i, pt := range path {
+			x, y := project(pt)
+			if i == 0 {
+				cp.MoveTo(x, y)
+			} else {
+				cp.LineTo(x, y)
+			}
+		}
+		if closed {
+			cp.Close()
+		}
+	}
+	return cp
+}
+
+// pathsBounds returns the bounding box of paths, all zero when they are
+// empty.
+func pathsBounds(paths [][]Point) (minX, minY, maxX, maxY float64) {
+	first := true
+	for _, path := range paths {
+		for _, p := range path {
+			if first {
+				minX, minY, maxX, maxY = p.X, p.Y, p.X, p.Y
+				first = false
+				continue
+			}
+			minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+			maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
+// geometryPaths returns the coordinates of geom as paths: the line of a
+// LineString, the lines of a MultiLineString, or the rings of a Polygon or
+// MultiPolygon. Points and malformed geometries yield nothing.
+func geometryPaths(geom *Geometry) [][]Point {
+	if geom == nil {
+		return nil
+	}
+	var lines [][][2]float64
+	switch geom.Type {
+	case GeometryLineString:
+		var line [][2]float64
+		if json.Unmarshal(geom.Coordinates, &line) != nil {
+			return nil
+		}
+		lines = [][][2]float64{line}
+	case GeometryMultiLineString, GeometryPolygon:
+		if json.Unmarshal(geom.Coordinates, &lines) != nil {
+			return nil
+		}
+	case GeometryMultiPolygon:
+		var polys [][][][2]float64
+		if json.Unmarshal(geom.Coordinates, &polys) != nil {
+			return nil
+		}
+		for _, rings := range polys {
+			lines = append(lines, rings...)
+		}
+	}
+
+	paths := make([][]Point, 0, len(lines))
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		path := make([]Point, len(line))
+		for i, c := range line {
+			path[i] = Point{X: c[0], Y: c[1]}
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
@@ -0,0 +1,140 @@
+package mesh
+
+import (
+	"image/color"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Unified floor plan rendering
+// ---------------------------------------------------------------------------
+
+// unifiedPlan returns a 4m x 2m room with a wall along its top (Y=0) edge.
+func unifiedPlan() *UnifiedMap {
+	return &UnifiedMap{
+		Floors: []*UnifiedFeature{{Geometry: PathToPolygon(Path{{X: 0, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 2000}, {X: 0, Y: 2000}})}},
+		Walls:  []*UnifiedFeature{{Geometry: PathToLineString(Path{{X: 0, Y: 0}, {X: 4000, Y: 0}})}},
+	}
+}
+
+func TestUnifiedRenderer_Render(t *testing.T) {
+	r := NewUnifiedRenderer(unifiedPlan())
+	r.Padding = 10
+	img := r.Render()
+
+	// 4000 x 2000 mm at 25 mm per pixel, plus padding
+	if b := img.Bounds(); b.Dx() != 180 || b.Dy() != 100 {
+		t.Fatalf("image is %dx%d, want 180x100", b.Dx(), b.Dy())
+	}
+
+	at := func(x, y int) color.RGBA { return img.RGBAAt(x, y) }
+	if c := at(2, 2); c != nrgbaToRGBA(GreyscaleBG) {
+		t.Errorf("padding = %v, want background", c)
+	}
+	if c := at(90, 60); c != nrgbaToRGBA(GreyscaleFloor) {
+		t.Errorf("room interior = %v, want floor", c)
+	}
+	// The wall at Y=0 is at the top of the image, 3 pixels wide
+	if c := at(90, 10); c != nrgbaToRGBA(GreyscaleWall) {
+		t.Errorf("top edge = %v, want wall", c)
+	}
+	if c := at(90, 89); c == nrgbaToRGBA(GreyscaleWall) {
+		t.Error("wall drawn along the bottom edge")
+	}
+}
+
+func TestUnifiedRenderer_Rotation(t *testing.T) {
+	r := NewUnifiedRenderer(unifiedPlan())
+	r.Padding = 10
+	r.GlobalRotation = 90
+	img := r.Render()
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 180 {
+		t.Fatalf("rotated image is %dx%d, want 100x180", b.Dx(), b.Dy())
+	}
+}
+
+func TestUnifiedRenderer_MaxDimension(t *testing.T) {
+	r := NewUnifiedRenderer(unifiedPlan())
+	r.Padding = 10
+	r.MaxDimension = 100
+	img := r.Render()
+	if b := img.Bounds(); b.Dx() > 100 || b.Dy() > 100 {
+		t.Errorf("image is %dx%d, want at most 100 pixels", b.Dx(), b.Dy())
+	}
+}
+
+func TestUnifiedRenderer_Empty(t *testing.T) {
+	for _, um := range []*UnifiedMap{nil, {}} {
+		r := NewUnifiedRenderer(um)
+		if r.HasDrawableContent() {
+			t.Errorf("HasDrawableContent(%v) = true", um)
+		}
+		if b := r.Render().Bounds(); b.Dx() != 61 || b.Dy() != 61 {
+			t.Errorf("empty render is %dx%d, want 61x61", b.Dx(), b.Dy())
+		}
+	}
+	if !NewUnifiedRenderer(unifiedPlan()).HasDrawableContent() {
+		t.Error("HasDrawableContent = false for a plan with a room")
+	}
+}
+
+func TestGeometryPaths(t *testing.T) {
+	multi := PathsToMultiLineString([]Path{{{X: 0, Y: 0}, {X: 1, Y: 0}}, {{X: 2, Y: 2}, {X: 3, Y: 3}}})
+	if got := geometryPaths(multi); len(got) != 2 || got[1][1] != (Point{X: 3, Y: 3}) {
+		t.Errorf("MultiLineString paths = %v", got)
+	}
+	if got := geometryPaths(&Geometry{Type: GeometryPolygon, Coordinates: []byte("not json")}); got != nil {
+		t.Errorf("malformed polygon paths = %v, want none", got)
+	}
+	if got := geometryPaths(nil); got != nil {
+		t.Errorf("nil geometry paths = %v", got)
+	}
+}
+
+func TestUnifiedRenderer_MaterialHatch(t *testing.T) {
+	um := unifiedPlan()
+	um.Materials = []*UnifiedFeature{{
+		Geometry:   PathToPolygon(Path{{X: 2000, Y: 0}, {X: 4000, Y: 0}, {X: 4000, Y: 2000}, {X: 2000, Y: 2000}}),
+		Properties: map[string]interface{}{"material": MaterialCarpet},
+	}}
+	r := NewUnifiedRenderer(um)
+	r.Padding = 10
+	img := r.Render()
+
+	// Count pixels off the plain floor color in each half of the room
+	hatched := func(x0, x1 int) int {
+		n := 0
+		for y := 20; y < 90; y++ {
+			for x := x0; x < x1; x++ {
+				if img.RGBAAt(x, y) != nrgbaToRGBA(GreyscaleFloor) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	if n := hatched(20, 80); n != 0 {
+		t.Errorf("%d hatched pixels outside the carpet", n)
+	}
+	if n := hatched(100, 160); n == 0 {
+		t.Error("carpet not hatched")
+	}
+}
+
+func TestUnifiedRenderer_Heat(t *testing.T) {
+	r := NewUnifiedRenderer(unifiedPlan())
+	r.Padding = 10
+	r.Heat = &HeatGrid{CellSize: 100, Counts: map[HeatCell]int{{10, 10}: 100, {20, 10}: 1}}
+	img := r.Render()
+
+	// Cells are 4 pixels wide at 25mm per pixel
+	if c := img.RGBAAt(10+41, 10+41); c.R < 200 || c.G > 100 {
+		t.Errorf("most visited cell = %v, want red", c)
+	}
+	if c := img.RGBAAt(10+81, 10+41); c.B < c.R {
+		t.Errorf("rarely visited cell = %v, want blue", c)
+	}
+	if c := img.RGBAAt(10+61, 10+41); c != nrgbaToRGBA(GreyscaleFloor) {
+		t.Errorf("unvisited floor = %v, want floor", c)
+	}
+}
@@ -0,0 +1,124 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// DefaultSummaryInterval is how often the map summary is republished when
+// mqtt.summaryInterval is not set, so it survives a broker that lost its
+// retained messages.
+const DefaultSummaryInterval = 5 * time.Minute
+
+// UnifiedMapSummary is a compact description of the unified map for
+// automations: its rooms with their size and position, and which vacuums
+// see them.
+type UnifiedMapSummary struct {
+	Version         int64            `json:"version"`
+	Timestamp       int64            `json:"timestamp"` // Unix seconds, the map's LastUpdated
+	ReferenceVacuum string           `json:"referenceVacuum"`
+	TotalArea       float64          `json:"totalArea"`       // m²
+	CoverageOverlap float64          `json:"coverageOverlap"` // 0-1, fraction of TotalArea seen by two or more vacuums
+	Segments        []SegmentSummary `json:"segments"`
+	Vacuums         []VacuumCoverage `json:"vacuums"`
+}
+
+// SegmentSummary is one room of the unified map.
+type SegmentSummary struct {
+	Name       string   `json:"name,omitempty"`
+	Area       float64  `json:"area"`     // m², rounded to 0.01
+	Centroid   Point    `json:"centroid"` // world mm, rounded
+	Vacuums    []string `json:"vacuums"`  // vacuums that observed it, sorted
+	Confidence float64  `json:"confidence"`
+}
+
+// VacuumCoverage is how much of the unified map one vacuum observed.
+type VacuumCoverage struct {
+	VacuumID string  `json:"vacuumId"`
+	Segments int     `json:"segments"` // rooms it observed
+	Area     float64 `json:"area"`     // m² of those rooms
+}
+
+// SummarizeUnifiedMap builds the summary of um. Segments are sorted by name,
+// unnamed ones last in order of position. It returns nil for a nil map.
+func SummarizeUnifiedMap(um *UnifiedMap) *UnifiedMapSummary {
+	if um == nil {
+		return nil
+	}
+	summary := &UnifiedMapSummary{
+		Version:         um.Metadata.Version,
+		Timestamp:       um.Metadata.LastUpdated,
+		ReferenceVacuum: um.Metadata.ReferenceVacuum,
+		TotalArea:       um.Metadata.TotalArea,
+		CoverageOverlap: um.Metadata.CoverageOverlap,
+		Segments:        []SegmentSummary{},
+		Vacuums:         []VacuumCoverage{},
+	}
+
+	coverage := make(map[string]*VacuumCoverage)
+	for _, seg := range um.Segments {
+		poly := orbPolygon(seg.Geometry)
+		if len(poly) == 0 {
+			continue
+		}
+		s := summarizeSegment(seg, poly)
+		for _, id := range s.Vacuums {
+			c := coverage[id]
+			if c == nil {
+				c = &VacuumCoverage{VacuumID: id}
+				coverage[id] = c
+			}
+			c.Segments++
+			c.Area += s.Area
+		}
+		summary.Segments = append(summary.Segments, s)
+	}
+
+	sort.SliceStable(summary.Segments, func(i, j int) bool {
+		a, b := summary.Segments[i], summary.Segments[j]
+		if (a.Name == "") != (b.Name == "") {
+			return a.Name != ""
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Centroid.Y != b.Centroid.Y {
+			return a.Centroid.Y < b.Centroid.Y
+		}
+		return a.Centroid.X < b.Centroid.X
+	})
+
+	for _, c := range coverage {
+		c.Area = math.Round(c.Area*100) / 100
+		summary.Vacuums = append(summary.Vacuums, *c)
+	}
+	sort.Slice(summary.Vacuums, func(i, j int) bool { return summary.Vacuums[i].VacuumID < summary.Vacuums[j].VacuumID })
+	return summary
+}
+
+// summarizeSegment describes the unified segment seg with outline poly.
+func summarizeSegment(seg *UnifiedFeature, poly orb.Polygon) SegmentSummary {
+	centroid, area := planar.CentroidArea(poly)
+	s := SegmentSummary{
+		Area:       math.Round(math.Abs(area)/1e4) / 100, // mm² -> m²
+		Centroid:   Point{X: math.Round(centroid[0]), Y: math.Round(centroid[1])},
+		Vacuums:    []string{},
+		Confidence: seg.Confidence,
+	}
+	s.Name, _ = seg.Properties["segmentName"].(string)
+
+	seen := make(map[string]bool)
+	for _, src := range seg.Sources {
+		if src.VacuumID == "" || seen[src.VacuumID] {
+			continue
+		}
+		seen[src.VacuumID] = true
+		s.Vacuums = append(s.Vacuums, src.VacuumID)
+	}
+	sort.Strings(s.Vacuums)
+	return s
+}
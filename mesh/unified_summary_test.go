@@ -0,0 +1,81 @@
+package mesh
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// ---------------------------------------------------------------------------
+// Unified map summary
+// ---------------------------------------------------------------------------
+
+// summarySegment returns a w x h mm segment at (x, y) observed by vacuums.
+func summarySegment(name string, x, y, w, h float64, vacuums ...string) *UnifiedFeature {
+	poly := orb.Polygon{orb.Ring{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}, {x, y}}}
+	f := &UnifiedFeature{
+		Geometry:   polygonToGeometry(poly),
+		Properties: map[string]interface{}{},
+		Confidence: 1,
+	}
+	if name != "" {
+		f.Properties["segmentName"] = name
+	}
+	for _, id := range vacuums {
+		f.Sources = append(f.Sources, FeatureSource{VacuumID: id})
+	}
+	return f
+}
+
+func TestSummarizeUnifiedMap(t *testing.T) {
+	um := &UnifiedMap{
+		Segments: []*UnifiedFeature{
+			summarySegment("Kitchen", 0, 0, 3000, 2000, "rocky", "dusty", "rocky"),
+			summarySegment("", 5000, 0, 1000, 1000, "dusty"),
+			summarySegment("Bath", 3000, 0, 2000, 2000, "rocky"),
+		},
+		Metadata: UnifiedMetadata{Version: 4, LastUpdated: 1700000000, ReferenceVacuum: "rocky", TotalArea: 11, CoverageOverlap: 0.5},
+	}
+
+	s := SummarizeUnifiedMap(um)
+	if s.Version != 4 || s.Timestamp != 1700000000 || s.ReferenceVacuum != "rocky" || s.TotalArea != 11 {
+		t.Errorf("metadata = %+v", s)
+	}
+	if len(s.Segments) != 3 {
+		t.Fatalf("got %d segments, want 3", len(s.Segments))
+	}
+	names := []string{s.Segments[0].Name, s.Segments[1].Name, s.Segments[2].Name}
+	if names[0] != "Bath" || names[1] != "Kitchen" || names[2] != "" {
+		t.Errorf("segment order = %q, want Bath, Kitchen, unnamed", names)
+	}
+	kitchen := s.Segments[1]
+	if kitchen.Area != 6 || kitchen.Centroid != (Point{X: 1500, Y: 1000}) {
+		t.Errorf("kitchen area %g centroid %v, want 6 m² at (1500, 1000)", kitchen.Area, kitchen.Centroid)
+	}
+	if len(kitchen.Vacuums) != 2 || kitchen.Vacuums[0] != "dusty" || kitchen.Vacuums[1] != "rocky" {
+		t.Errorf("kitchen vacuums = %v, want [dusty rocky]", kitchen.Vacuums)
+	}
+
+	want := []VacuumCoverage{{VacuumID: "dusty", Segments: 2, Area: 7}, {VacuumID: "rocky", Segments: 2, Area: 10}}
+	if len(s.Vacuums) != 2 || s.Vacuums[0] != want[0] || s.Vacuums[1] != want[1] {
+		t.Errorf("vacuums = %+v, want %+v", s.Vacuums, want)
+	}
+}
+
+func TestSummarizeUnifiedMap_Empty(t *testing.T) {
+	if SummarizeUnifiedMap(nil) != nil {
+		t.Error("nil map should have no summary")
+	}
+	data, err := json.Marshal(SummarizeUnifiedMap(&UnifiedMap{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if segs, ok := got["segments"].([]interface{}); !ok || len(segs) != 0 {
+		t.Errorf("segments = %v, want an empty list rather than null", got["segments"])
+	}
+}
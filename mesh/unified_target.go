@@ -0,0 +1,168 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+)
+
+// ICP alignment targets for icp.target.
+const (
+	ICPTargetUnified   = "unified"   // the unified map's consensus, once it exists
+	ICPTargetReference = "reference" // the reference vacuum's own map
+)
+
+// alignUnified reports whether docked vacuums are aligned against the
+// unified map (the default).
+func (c ICPBudgetConfig) alignUnified() bool {
+	return c.Target != ICPTargetReference
+}
+
+// minUnifiedTargetWalls is the fewest wall pixels a unified target needs;
+// with less the consensus is too thin to align against.
+const minUnifiedTargetWalls = 50
+
+// UnifiedTargetMap rasterizes the unified floors, segments and walls onto a
+// grid of pixelSize mm per pixel, the reference vacuum's grid, so a
+// vacuum's map can be aligned against the consensus of all robots with
+// AlignMaps instead of against the reference vacuum alone. The transform
+// found maps onto the reference frame as one against the reference map
+// would. Features observed only by exclude, usually the vacuum being
+// aligned, are left out so its previous alignment does not pull the new
+// one. It returns nil when too few walls remain.
+func UnifiedTargetMap(um *UnifiedMap, pixelSize int, exclude string) *ValetudoMap {
+	if um == nil {
+		return nil
+	}
+	if pixelSize <= 0 {
+		pixelSize = defaultPixelSize
+	}
+	scale := 1 / float64(pixelSize)
+
+	var floorRings [][]Point
+	for _, features := range [][]*UnifiedFeature{um.Floors, um.Segments} {
+		for _, f := range features {
+			if onlyObservedBy(f, exclude) {
+				continue
+			}
+			floorRings = append(floorRings, scalePaths(geometryPaths(f.Geometry), scale)...)
+		}
+	}
+	walls := make(map[[2]int]struct{})
+	for _, f := range um.Walls {
+		if onlyObservedBy(f, exclude) {
+			continue
+		}
+		for _, path := range scalePaths(geometryPaths(f.Geometry), scale) {
+			traceCells(path, walls)
+		}
+	}
+	if len(walls) < minUnifiedTargetWalls {
+		return nil
+	}
+
+	floorPixels := cellPixels(fillRingCells(floorRings))
+	wallPixels := cellPixels(walls)
+	return &ValetudoMap{
+		Class:     "ValetudoMap",
+		PixelSize: pixelSize,
+		MetaData:  MapMetaData{TotalLayerArea: (len(floorPixels) + len(wallPixels)) / 2},
+		Layers: []MapLayer{
+			{Type: "floor", Pixels: floorPixels},
+			{Type: "wall", Pixels: wallPixels},
+		},
+	}
+}
+
+// onlyObservedBy reports whether every source of f is vacuumID.
+func onlyObservedBy(f *UnifiedFeature, vacuumID string) bool {
+	if vacuumID == "" || len(f.Sources) == 0 {
+		return false
+	}
+	for _, s := range f.Sources {
+		if s.VacuumID != vacuumID {
+			return false
+		}
+	}
+	return true
+}
+
+// scalePaths returns copies of paths with every coordinate multiplied by
+// scale.
+func scalePaths(paths [][]Point, scale float64) [][]Point {
+	scaled := make([][]Point, len(paths))
+	for i, path := range paths {
+		scaled[i] = make([]Point, len(path))
+		for j, p := range path {
+			scaled[i][j] = Point{X: p.X * scale, Y: p.Y * scale}
+		}
+	}
+	return scaled
+}
+
+// traceCells adds the grid cells path passes through to cells, sampling
+// every half cell.
+func traceCells(path []Point, cells map[[2]int]struct{}) {
+	for i := range path {
+		a, b := path[i], path[i]
+		if i+1 < len(path) {
+			b = path[i+1]
+		}
+		steps := int(math.Ceil(2 * math.Hypot(b.X-a.X, b.Y-a.Y)))
+		for s := 0; s <= steps; s++ {
+			f := 0.0
+			if steps > 0 {
+				f = float64(s) / float64(steps)
+			}
+			x, y := a.X+f*(b.X-a.X), a.Y+f*(b.Y-a.Y)
+			cells[[2]int{int(math.Floor(x)), int(math.Floor(y))}] = struct{}{}
+		}
+	}
+}
+
+// fillRingCells returns the grid cells whose centers lie inside the rings,
+// by the even-odd rule, so holes stay empty.
+func fillRingCells(rings [][]Point) map[[2]int]struct{} {
+	cells := make(map[[2]int]struct{})
+	minX, minY, maxX, maxY := pathsBounds(rings)
+	var crossings []float64
+	for y := int(math.Floor(minY)); y <= int(math.Ceil(maxY)); y++ {
+		cy := float64(y) + 0.5
+		crossings = crossings[:0]
+		for _, ring := range rings {
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%len(ring)]
+				if (a.Y <= cy) != (b.Y <= cy) {
+					crossings = append(crossings, a.X+(cy-a.Y)/(b.Y-a.Y)*(b.X-a.X))
+				}
+			}
+		}
+		sort.Float64s(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			x0 := max(int(math.Ceil(crossings[i]-0.5)), int(math.Floor(minX)))
+			x1 := min(int(math.Floor(crossings[i+1]-0.5)), int(math.Ceil(maxX)))
+			for x := x0; x <= x1; x++ {
+				cells[[2]int{x, y}] = struct{}{}
+			}
+		}
+	}
+	return cells
+}
+
+// cellPixels flattens cells into a layer's pixel list, sorted by row.
+func cellPixels(cells map[[2]int]struct{}) []int {
+	sorted := make([][2]int, 0, len(cells))
+	for c := range cells {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][1] != sorted[j][1] {
+			return sorted[i][1] < sorted[j][1]
+		}
+		return sorted[i][0] < sorted[j][0]
+	})
+	pixels := make([]int, 0, 2*len(sorted))
+	for _, c := range sorted {
+		pixels = append(pixels, c[0], c[1])
+	}
+	return pixels
+}
@@ -0,0 +1,112 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// unifiedLShape returns a unified map walled with the outline of
+// createLShapeWalls(Point{X: 100, Y: 100}, 2) at 5 mm per pixel, observed by
+// source.
+func unifiedLShape(source string) *UnifiedMap {
+	outline := Path{
+		{X: 500, Y: 500}, {X: 1500, Y: 500}, {X: 1500, Y: 1000}, {X: 1000, Y: 1000},
+		{X: 1000, Y: 1500}, {X: 500, Y: 1500}, {X: 500, Y: 500},
+	}
+	um := unifiedRoom([][4]float64{{500, 500, 1500, 1000}, {500, 1000, 1000, 1500}}, outline)
+	for _, features := range [][]*UnifiedFeature{um.Segments, um.Walls} {
+		for _, f := range features {
+			f.Sources = []FeatureSource{{VacuumID: source}}
+		}
+	}
+	return um
+}
+
+// ---------------------------------------------------------------------------
+// UnifiedTargetMap
+// ---------------------------------------------------------------------------
+
+func TestUnifiedTargetMap_Rasterizes(t *testing.T) {
+	m := UnifiedTargetMap(unifiedLShape("a"), 5, "")
+	if m == nil {
+		t.Fatal("UnifiedTargetMap returned nil")
+	}
+	if m.PixelSize != 5 {
+		t.Errorf("PixelSize = %d, want 5", m.PixelSize)
+	}
+
+	floors := make(map[[2]int]bool)
+	walls := make(map[[2]int]bool)
+	for _, layer := range m.Layers {
+		for i := 0; i+1 < len(layer.Pixels); i += 2 {
+			c := [2]int{layer.Pixels[i], layer.Pixels[i+1]}
+			switch layer.Type {
+			case "floor":
+				floors[c] = true
+			case "wall":
+				walls[c] = true
+			}
+		}
+	}
+
+	// Both rectangles are filled, the notch of the L is not
+	for _, c := range [][2]int{{150, 150}, {250, 150}, {150, 250}} {
+		if !floors[c] {
+			t.Errorf("floor missing cell %v", c)
+		}
+	}
+	if floors[[2]int{250, 250}] {
+		t.Error("floor covers the notch of the L")
+	}
+
+	// Walls follow the outline, with no gaps along an edge
+	for x := 100; x < 300; x++ {
+		if !walls[[2]int{x, 100}] {
+			t.Fatalf("wall missing cell (%d,100)", x)
+		}
+	}
+	if walls[[2]int{150, 150}] {
+		t.Error("wall inside the room")
+	}
+}
+
+func TestUnifiedTargetMap_ExcludesOwnFeatures(t *testing.T) {
+	um := unifiedLShape("a")
+	if m := UnifiedTargetMap(um, 5, "a"); m != nil {
+		t.Errorf("map observed only by the excluded vacuum yielded a target")
+	}
+
+	// A feature shared with another vacuum stays
+	for _, f := range um.Walls {
+		f.Sources = append(f.Sources, FeatureSource{VacuumID: "b"})
+	}
+	if m := UnifiedTargetMap(um, 5, "a"); m == nil {
+		t.Error("shared walls were excluded")
+	}
+}
+
+func TestUnifiedTargetMap_TooThin(t *testing.T) {
+	if m := UnifiedTargetMap(nil, 5, ""); m != nil {
+		t.Error("nil unified map yielded a target")
+	}
+	um := unifiedRoom(nil, Path{{X: 0, Y: 0}, {X: 100, Y: 0}})
+	if m := UnifiedTargetMap(um, 5, ""); m != nil {
+		t.Error("a 2 cm wall yielded a target")
+	}
+}
+
+func TestUnifiedTargetMap_AlignsOffsetMap(t *testing.T) {
+	target := UnifiedTargetMap(unifiedLShape("a"), 5, "b")
+	if target == nil {
+		t.Fatal("UnifiedTargetMap returned nil")
+	}
+	source := createTestValetudoMap(createLShapeWalls(Point{X: 110, Y: 95}, 2), nil)
+
+	result := AlignMaps(source, target, DefaultICPConfig())
+	if math.Abs(result.Transform.Tx+10) > 3 || math.Abs(result.Transform.Ty-5) > 3 {
+		t.Errorf("translation = (%.1f, %.1f), want about (-10, 5)", result.Transform.Tx, result.Transform.Ty)
+	}
+	if result.Score < preAlignMinScore {
+		t.Errorf("score = %.2f, want at least %.2f", result.Score, preAlignMinScore)
+	}
+}
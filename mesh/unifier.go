@@ -11,13 +11,15 @@ import (
 )
 
 // UnifiedMap represents a composite map built from multiple vacuum observations.
-// Each feature category (walls, floors, segments) contains consensus features
-// derived by clustering and merging observations from individual vacuums.
+// Each feature category (walls, floors, segments, materials) contains
+// consensus features derived by clustering and merging observations from
+// individual vacuums.
 type UnifiedMap struct {
-	Walls    []*UnifiedFeature `json:"walls"`
-	Floors   []*UnifiedFeature `json:"floors"`
-	Segments []*UnifiedFeature `json:"segments"`
-	Metadata UnifiedMetadata   `json:"metadata"`
+	Walls     []*UnifiedFeature `json:"walls"`
+	Floors    []*UnifiedFeature `json:"floors"`
+	Segments  []*UnifiedFeature `json:"segments"`
+	Materials []*UnifiedFeature `json:"materials"` // floor regions by "material" property, such as carpet
+	Metadata  UnifiedMetadata   `json:"metadata"`
 }
 
 // UnifiedFeature is a feature derived from multiple vacuum observations.
@@ -41,11 +43,13 @@ type FeatureSource struct {
 
 // UnifiedMetadata provides provenance information for a UnifiedMap.
 type UnifiedMetadata struct {
-	VacuumCount     int     `json:"vacuumCount"`
-	ReferenceVacuum string  `json:"referenceVacuum"`
-	LastUpdated     int64   `json:"lastUpdated"`
-	TotalArea       float64 `json:"totalArea"`
-	CoverageOverlap float64 `json:"coverageOverlap"`
+	Version         int64             `json:"version"` // increases whenever the geometry materially changes
+	VacuumCount     int               `json:"vacuumCount"`
+	ReferenceVacuum string            `json:"referenceVacuum"`
+	LastUpdated     int64             `json:"lastUpdated"`
+	TotalArea       float64           `json:"totalArea"`          // m², union of all vacuums' floors
+	CoverageOverlap float64           `json:"coverageOverlap"`    // 0-1, fraction of TotalArea seen by two or more vacuums
+	Overlaps        []CoverageOverlap `json:"overlaps,omitempty"` // floor shared by each pair of vacuums
 }
 
 // DefaultWallClusterDistance is the maximum distance (in mm) between wall
@@ -299,9 +303,10 @@ func mergeProperties(cluster []*Feature, sources []FeatureSource) map[string]int
 // NewUnifiedMap creates an empty UnifiedMap with initialized slices and metadata.
 func NewUnifiedMap(vacuumCount int, referenceVacuum string) *UnifiedMap {
 	return &UnifiedMap{
-		Walls:    make([]*UnifiedFeature, 0),
-		Floors:   make([]*UnifiedFeature, 0),
-		Segments: make([]*UnifiedFeature, 0),
+		Walls:     make([]*UnifiedFeature, 0),
+		Floors:    make([]*UnifiedFeature, 0),
+		Segments:  make([]*UnifiedFeature, 0),
+		Materials: make([]*UnifiedFeature, 0),
 		Metadata: UnifiedMetadata{
 			VacuumCount:     vacuumCount,
 			ReferenceVacuum: referenceVacuum,
@@ -312,7 +317,8 @@ func NewUnifiedMap(vacuumCount int, referenceVacuum string) *UnifiedMap {
 
 // ToFeatureCollection converts the UnifiedMap into a GeoJSON FeatureCollection.
 // Each unified feature becomes a GeoJSON Feature with confidence and source
-// count stored in properties.
+// count stored in properties; the map's metadata, including its coverage,
+// goes in the collection's properties.
 func (um *UnifiedMap) ToFeatureCollection() *FeatureCollection {
 	fc := NewFeatureCollection()
 
@@ -335,6 +341,15 @@ func (um *UnifiedMap) ToFeatureCollection() *FeatureCollection {
 	addFeatures(um.Walls, "wall")
 	addFeatures(um.Floors, "floor")
 	addFeatures(um.Segments, "segment")
+	addFeatures(um.Materials, "material")
+
+	fc.Properties = map[string]interface{}{
+		"vacuumCount":     um.Metadata.VacuumCount,
+		"referenceVacuum": um.Metadata.ReferenceVacuum,
+		"lastUpdated":     um.Metadata.LastUpdated,
+		"totalArea":       um.Metadata.TotalArea,
+		"coverageOverlap": um.Metadata.CoverageOverlap,
+	}
 
 	return fc
 }
@@ -544,8 +559,9 @@ type floorEntry struct {
 
 // mergeFloorGroup unions the polygons from a group of floor entries and
 // produces a single UnifiedFeature. The segment name is resolved using
-// resolveSegmentName (highest area wins). The confidence score reflects
-// how many distinct vacuums observed the floor region.
+// resolveSegmentName (highest area wins). Interior rings survive the union
+// when enough vacuums see them (see consensusHoles). The confidence score
+// reflects how many distinct vacuums observed the floor region.
 func mergeFloorGroup(entries []*floorEntry, name string, totalVacuums int) *UnifiedFeature {
 	if len(entries) == 0 {
 		return nil
@@ -561,12 +577,18 @@ func mergeFloorGroup(entries []*floorEntry, name string, totalVacuums int) *Unif
 		clusterFeatures = append(clusterFeatures, e.feature)
 	}
 
-	// Union all polygons into one.
+	// Union all polygons into one, keeping the interior obstacles enough
+	// vacuums agree on.
 	var mergedGeom *Geometry
 	if len(geoms) == 1 {
 		mergedGeom = geoms[0]
 	} else {
 		mergedGeom = UnionPolygons(geoms)
+		if hull := orbPolygon(mergedGeom); len(hull) > 0 {
+			if holes := consensusHoles(entries, hull[0]); len(holes) > 0 {
+				mergedGeom = polygonToGeometry(append(hull, holes...))
+			}
+		}
 	}
 	if mergedGeom == nil {
 		return nil
@@ -1072,8 +1094,8 @@ func blendLineStrings(old, new_ *Geometry, weight float64) *Geometry {
 }
 
 // blendPolygons interpolates between two Polygon geometries by blending
-// the outer ring vertices. Only the outer ring is blended; inner rings
-// are taken from the new geometry.
+// the outer ring vertices. Inner rings are blended with the old hole around
+// the same obstacle when there is one (see blendHoles).
 func blendPolygons(old, new_ *Geometry, weight float64) *Geometry {
 	oldPoly := orbPolygon(old)
 	newPoly := orbPolygon(new_)
@@ -1087,30 +1109,10 @@ func blendPolygons(old, new_ *Geometry, weight float64) *Geometry {
 		return new_
 	}
 
-	// Resample both outer rings to the same number of points.
-	n := max(len(oldRing), len(newRing))
-	if n > 200 {
-		n = 200
-	}
-
-	oldResampled := resampleRing(oldRing, n)
-	newResampled := resampleRing(newRing, n)
-
-	blendedRing := make(orb.Ring, n)
-	oldWeight := 1.0 - weight
-	for i := 0; i < n; i++ {
-		blendedRing[i] = orb.Point{
-			oldResampled[i][0]*oldWeight + newResampled[i][0]*weight,
-			oldResampled[i][1]*oldWeight + newResampled[i][1]*weight,
-		}
-	}
-
-	// Build result polygon with blended outer ring and new inner rings.
+	// Build result polygon with blended outer and inner rings.
 	result := make(orb.Polygon, 0, len(newPoly))
-	result = append(result, blendedRing)
-	if len(newPoly) > 1 {
-		result = append(result, newPoly[1:]...)
-	}
+	result = append(result, blendRings(oldRing, newRing, weight))
+	result = append(result, blendHoles(oldPoly[1:], newPoly[1:], weight)...)
 
 	return polygonToGeometry(result)
 }
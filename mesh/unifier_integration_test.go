@@ -150,6 +150,14 @@ func TestStateTracker_UpdateUnifiedMap_MultipleVacuums(t *testing.T) {
 		t.Errorf("VacuumCount = %d, want 2", um.Metadata.VacuumCount)
 	}
 
+	// 5 distinct floor columns, 3 of them covered by both vacuums
+	if um.Metadata.CoverageOverlap != 0.6 {
+		t.Errorf("CoverageOverlap = %v, want 0.6", um.Metadata.CoverageOverlap)
+	}
+	if len(um.Metadata.Overlaps) != 1 || um.Metadata.Overlaps[0].Fraction != 0.75 {
+		t.Errorf("Overlaps = %+v, want vac-1/vac-2 sharing 3 of 4 columns", um.Metadata.Overlaps)
+	}
+
 	t.Logf("Walls: %d, Floors: %d, Segments: %d", len(um.Walls), len(um.Floors), len(um.Segments))
 }
 
@@ -208,6 +216,57 @@ func TestStateTracker_IncrementalRefinement(t *testing.T) {
 	t.Logf("After refinement - Walls: %d, Floors: %d", len(um2.Walls), len(um2.Floors))
 }
 
+func TestStateTracker_RebasedReferenceReplacesMap(t *testing.T) {
+	floor := []int{
+		10, 10, 11, 10, 12, 10,
+		10, 11, 11, 11, 12, 11,
+		10, 12, 11, 12, 12, 12,
+	}
+	wall := []int{
+		9, 9, 10, 9, 11, 9, 12, 9, 13, 9,
+		9, 10, 9, 11, 9, 12,
+		13, 10, 13, 11, 13, 12,
+	}
+	rebased := &CalibrationData{
+		ReferenceVacuum: "vac-2",
+		Vacuums: map[string]VacuumCalibration{
+			"vac-1": {Transform: Translation(20, 0)},
+		},
+	}
+
+	// A map built against the old reference is not blended into the new
+	// frame: the result matches a tracker that never saw it
+	st := NewStateTracker()
+	st.UpdateMap("vac-1", makeTestMap(5, floor, wall, nil, ""))
+	if err := st.UpdateUnifiedMap(&CalibrationData{
+		ReferenceVacuum: "vac-1",
+		Vacuums:         map[string]VacuumCalibration{"vac-1": {Transform: Identity()}},
+	}); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+	if err := st.UpdateUnifiedMap(rebased); err != nil {
+		t.Fatalf("rebased update failed: %v", err)
+	}
+
+	fresh := NewStateTracker()
+	fresh.UpdateMap("vac-1", makeTestMap(5, floor, wall, nil, ""))
+	if err := fresh.UpdateUnifiedMap(rebased); err != nil {
+		t.Fatalf("fresh update failed: %v", err)
+	}
+
+	got, want := st.GetUnifiedMap().Walls, fresh.GetUnifiedMap().Walls
+	if len(got) != len(want) {
+		t.Fatalf("%d walls after rebase, want %d", len(got), len(want))
+	}
+	for i := range got {
+		g, _ := json.Marshal(got[i].Geometry)
+		w, _ := json.Marshal(want[i].Geometry)
+		if string(g) != string(w) {
+			t.Errorf("wall %d after rebase = %s, want %s", i, g, w)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Test: Persistence - Save and Load unified map cache
 // ---------------------------------------------------------------------------
@@ -574,6 +574,13 @@ func TestToFeatureCollection(t *testing.T) {
 	if len(vacuums) != 2 {
 		t.Errorf("Expected 2 source vacuums, got %d", len(vacuums))
 	}
+
+	um.Metadata.TotalArea = 42.5
+	um.Metadata.CoverageOverlap = 0.25
+	fc = um.ToFeatureCollection()
+	if fc.Properties["totalArea"] != 42.5 || fc.Properties["coverageOverlap"] != 0.25 || fc.Properties["referenceVacuum"] != "ref" {
+		t.Errorf("collection properties = %v", fc.Properties)
+	}
 }
 
 // --- sourceVacuumIDs tests ---
@@ -2,6 +2,7 @@ package mesh
 
 import (
 	"fmt"
+	"image"
 	"image/color"
 	"image/png"
 	"io"
@@ -41,8 +42,12 @@ type VectorRenderer struct {
 	Scale          float64 // Scale factor for rendering
 	Padding        float64 // Padding in world units
 	GlobalRotation float64
-	Resolution     canvas.Resolution // Resolution for PNG output (default: 300 DPI)
-	GridSpacing    float64           // Grid line spacing in millimeters
+	Resolution     canvas.Resolution      // Resolution for PNG output (default: 300 DPI)
+	GridSpacing    float64                // Grid line spacing in millimeters
+	Layering       Layering               // Per-vacuum z-order and opacity
+	Icons          map[string]*MarkerIcon // Robot marker icons by vacuum ID
+	Active         map[string]ActiveArea  // Areas being cleaned, tinted by RenderLiveToSVG
+	Frontiers      []Frontier             // Unexplored floor edges, drawn dashed by RenderToSVG
 }
 
 // NewVectorRenderer creates a vector renderer with default settings
@@ -76,6 +81,7 @@ func NewVectorRenderer(maps map[string]*ValetudoMap, transforms map[string]Affin
 // canvasRenderer is an interface that both svg and rasterizer renderers implement
 type canvasRenderer interface {
 	RenderPath(path *canvas.Path, style canvas.Style, m canvas.Matrix)
+	RenderImage(img image.Image, m canvas.Matrix)
 }
 
 // RenderToSVG writes the map as an SVG to the provided writer
@@ -134,53 +140,46 @@ func (r *VectorRenderer) renderToCanvas(renderer canvasRenderer, minX, minY, max
 		return tx, ty
 	}
 
-	// Trace and draw each map
-	for id, m := range r.Maps {
+	// Trace and draw each map, lowest z-index first
+	for _, id := range r.Layering.DrawOrder(r.Maps) {
+		m := r.Maps[id]
 		transform := r.Transforms[id]
 		vc := r.Colors[id]
+		opacity := r.Layering.OpacityFor(id)
 
 		// Render Floor/Segments first (filled)
 		floorStyle := canvas.DefaultStyle
-		floorStyle.Fill = canvas.Paint{Color: nrgbaToRGBA(vc.Floor)}
+		floorStyle.Fill = canvas.Paint{Color: nrgbaToRGBA(fade(vc.Floor, opacity))}
 		floorStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+		floorStyle.FillRule = canvas.EvenOdd
 
 		for _, layer := range m.Layers {
 			if layer.Type == "floor" || layer.Type == "segment" {
-				paths := VectorizeLayer(&layer, m.PixelSize, 5.0)
-				for _, p := range paths {
-					cp := &canvas.Path{}
-					for i, pt := range p {
-						// Apply transform to pixel coordinates first
-						transformedPt := TransformPoint(pt, transform)
-						// Then scale to world coordinates
-						worldPt := Point{
-							X: transformedPt.X * float64(m.PixelSize),
-							Y: transformedPt.Y * float64(m.PixelSize),
-						}
-						cx, cy := toCanvas(worldPt)
-						if i == 0 {
-							cp.MoveTo(cx, cy)
-						} else {
-							cp.LineTo(cx, cy)
-						}
-					}
-					cp.Close()
-					renderer.RenderPath(cp, floorStyle, canvas.Identity)
-				}
+				paths := VectorizeMapLayer(m, &layer, 5.0)
+				cp := floorCanvasPath(paths, func(pt Point) (float64, float64) {
+					// Apply transform to pixel coordinates first, then
+					// scale to world coordinates
+					transformedPt := TransformPoint(pt, transform)
+					return toCanvas(Point{
+						X: transformedPt.X * float64(m.PixelSize),
+						Y: transformedPt.Y * float64(m.PixelSize),
+					})
+				})
+				renderer.RenderPath(cp, floorStyle, canvas.Identity)
 			}
 		}
 
 		// Render Walls (stroked)
 		wallStyle := canvas.DefaultStyle
 		wallStyle.Fill = canvas.Paint{Color: canvas.Transparent}
-		wallStyle.Stroke = canvas.Paint{Color: nrgbaToRGBA(vc.Wall)}
+		wallStyle.Stroke = canvas.Paint{Color: nrgbaToRGBA(fade(vc.Wall, opacity))}
 		wallStyle.StrokeWidth = 3.0 // 3mm thick walls
 		wallStyle.StrokeCapper = canvas.RoundCapper{}
 		wallStyle.StrokeJoiner = canvas.RoundJoiner{}
 
 		for _, layer := range m.Layers {
 			if layer.Type == "wall" {
-				paths := VectorizeLayer(&layer, m.PixelSize, 2.0)
+				paths := VectorizeMapLayer(m, &layer, 2.0)
 				for _, p := range paths {
 					cp := &canvas.Path{}
 					for i, pt := range p {
@@ -204,6 +203,28 @@ func (r *VectorRenderer) renderToCanvas(renderer canvasRenderer, minX, minY, max
 		}
 	}
 
+	// Frontiers, dashed where the floor runs on into unexplored space
+	frontierStyle := canvas.DefaultStyle
+	frontierStyle.Fill = canvas.Paint{Color: canvas.Transparent}
+	frontierStyle.Stroke = canvas.Paint{Color: nrgbaToRGBA(FrontierColor)}
+	frontierStyle.StrokeWidth = 20.0
+	frontierStyle.Dashes = []float64{80.0, 60.0}
+	for _, f := range r.Frontiers {
+		if len(f.Path) < 2 {
+			continue
+		}
+		cp := &canvas.Path{}
+		for i, pt := range f.Path {
+			cx, cy := toCanvas(pt)
+			if i == 0 {
+				cp.MoveTo(cx, cy)
+			} else {
+				cp.LineTo(cx, cy)
+			}
+		}
+		renderer.RenderPath(cp, frontierStyle, canvas.Identity)
+	}
+
 	// 5. Render grid lines
 	if r.GridSpacing > 0 {
 		gridStyle := canvas.DefaultStyle
@@ -248,17 +269,8 @@ func (r *VectorRenderer) renderToCanvas(renderer canvasRenderer, minX, minY, max
 			}
 			cx, cy := toCanvas(worldPt)
 
-			// Render as circle with vacuum's wall color
-			chargerStyle := canvas.DefaultStyle
-			chargerStyle.Fill = canvas.Paint{Color: nrgbaToRGBA(vc.Wall)}
-			chargerStyle.Stroke = canvas.Paint{Color: canvas.Black}
-			chargerStyle.StrokeWidth = 2.0
-			chargerStyle.StrokeCapper = canvas.RoundCapper{}
-			chargerStyle.StrokeJoiner = canvas.RoundJoiner{}
-
-			chargerPath := canvas.Circle(100.0)
-			chargerPath = chargerPath.Translate(cx, cy)
-			renderer.RenderPath(chargerPath, chargerStyle, canvas.Identity)
+			// Dock with a lightning bolt in the vacuum's wall color
+			renderCharger(renderer, cx, cy, 200.0, nrgbaToRGBA(vc.Wall))
 		}
 	}
 
@@ -277,35 +289,59 @@ func (r *VectorRenderer) renderToCanvas(renderer canvasRenderer, minX, minY, max
 	}
 }
 
+// floorCanvasPath joins a layer's contours into one path of subpaths, so
+// that filled with canvas.EvenOdd the contours around obstacles (a kitchen
+// island, furniture) leave holes instead of being painted over the floor.
+func floorCanvasPath(paths []Path, project func(Point) (float64, float64)) *canvas.Path {
+	cp := &canvas.Path{}
+	for _, p := range paths {
+		for i, pt := range p {
+			cx, cy := project(pt)
+			if i == 0 {
+				cp.MoveTo(cx, cy)
+			} else {
+				cp.LineTo(cx, cy)
+			}
+		}
+		cp.Close()
+	}
+	return cp
+}
+
 func (r *VectorRenderer) calculateWorldBounds() (minX, minY, maxX, maxY, centerX, centerY float64) {
 	minX, minY = math.MaxFloat64, math.MaxFloat64
 	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
 
+	buf := acquirePoints()
+	defer releasePoints(buf)
+
 	for id, m := range r.Maps {
 		transform := r.Transforms[id]
 		for _, layer := range m.Layers {
 			if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
-				points := PixelsToPoints(layer.Pixels)
+				points := pixelsToPointsInto(buf, layer.Pixels)
 				for _, p := range points {
-					// Apply transform to pixel coordinates first (ICP operates at pixel scale)
-					tp := TransformPoint(p, transform)
-					// Then scale to world coordinates
-					worldP := Point{
-						X: tp.X * float64(m.PixelSize),
-						Y: tp.Y * float64(m.PixelSize),
-					}
-					if worldP.X < minX {
-						minX = worldP.X
-					}
-					if worldP.Y < minY {
-						minY = worldP.Y
-					}
-					if worldP.X > maxX {
-						maxX = worldP.X
-					}
-					if worldP.Y > maxY {
-						maxY = worldP.Y
-					}
+					forEachBlockCell(p, m.CellBlock(), func(c Point) {
+						// Apply transform to pixel coordinates first (ICP operates at pixel scale)
+						tp := TransformPoint(c, transform)
+						// Then scale to world coordinates
+						worldP := Point{
+							X: tp.X * float64(m.PixelSize),
+							Y: tp.Y * float64(m.PixelSize),
+						}
+						if worldP.X < minX {
+							minX = worldP.X
+						}
+						if worldP.Y < minY {
+							minY = worldP.Y
+						}
+						if worldP.X > maxX {
+							maxX = worldP.X
+						}
+						if worldP.Y > maxY {
+							maxY = worldP.Y
+						}
+					})
 				}
 			}
 		}
@@ -332,70 +368,27 @@ func (r *VectorRenderer) applyGlobalRotation(p Point, centerX, centerY float64)
 // RenderLiveToSVG renders a live view SVG with a single greyscale base map
 // and colored vacuum position overlays. The base map is selected using
 // SelectReferenceVacuum (largest total layer area). Each vacuum position is
-// drawn as a colored circle with the vacuum ID as a label.
+// drawn as a colored marker with a wedge pointing along its heading, and
+// each charger as a dock icon.
 //
 // The positions parameter maps vacuum IDs to their current LivePosition.
 // Positions with coordinates outside the base map bounds are still rendered
 // (the SVG viewport is expanded to include them).
 func (r *VectorRenderer) RenderLiveToSVG(w io.Writer, positions map[string]*LivePosition) error {
-	if len(r.Maps) == 0 {
-		return fmt.Errorf("no maps available for live rendering")
-	}
-
-	// Select the base map (largest area).
-	baseID := SelectReferenceVacuum(r.Maps, nil)
-	baseMap := r.Maps[baseID]
-	baseTransform := r.Transforms[baseID]
-
-	// Calculate world-space bounds from the base map only.
-	minX, minY := math.MaxFloat64, math.MaxFloat64
-	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
-
-	for _, layer := range baseMap.Layers {
-		if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
-			points := PixelsToPoints(layer.Pixels)
-			for _, p := range points {
-				tp := TransformPoint(p, baseTransform)
-				worldP := Point{
-					X: tp.X * float64(baseMap.PixelSize),
-					Y: tp.Y * float64(baseMap.PixelSize),
-				}
-				if worldP.X < minX {
-					minX = worldP.X
-				}
-				if worldP.Y < minY {
-					minY = worldP.Y
-				}
-				if worldP.X > maxX {
-					maxX = worldP.X
-				}
-				if worldP.Y > maxY {
-					maxY = worldP.Y
-				}
-			}
-		}
+	baseMap, baseTransform, err := r.liveBaseMap()
+	if err != nil {
+		return err
 	}
 
-	// Expand bounds to include all vacuum positions.
-	// Positions are in grid coordinates (pixels) and must be scaled to world
-	// coordinates (millimeters) using the base map's PixelSize, matching the
-	// coordinate system used for the map geometry above.
+	// Calculate world-space bounds from the base map, expanded to include
+	// all vacuum positions.
+	minX, minY, maxX, maxY := liveBounds(baseMap, baseTransform)
 	pixelSize := float64(baseMap.PixelSize)
 	for _, pos := range positions {
-		wx := pos.X * pixelSize
-		wy := pos.Y * pixelSize
-		if wx < minX {
-			minX = wx
-		}
-		if wy < minY {
-			minY = wy
-		}
-		if wx > maxX {
-			maxX = wx
-		}
-		if wy > maxY {
-			maxY = wy
-		}
+		minX = math.Min(minX, pos.X*pixelSize)
+		minY = math.Min(minY, pos.Y*pixelSize)
+		maxX = math.Max(maxX, pos.X*pixelSize)
+		maxY = math.Max(maxY, pos.Y*pixelSize)
 	}
 
 	centerX := (minX + maxX) / 2
@@ -412,9 +405,51 @@ func (r *VectorRenderer) RenderLiveToSVG(w io.Writer, positions map[string]*Live
 	return svgRenderer.Close()
 }
 
+// liveBaseMap selects the base map of the live view: the one with the
+// largest area.
+func (r *VectorRenderer) liveBaseMap() (*ValetudoMap, AffineMatrix, error) {
+	if len(r.Maps) == 0 {
+		return nil, AffineMatrix{}, fmt.Errorf("no maps available for live rendering")
+	}
+	baseID := SelectReferenceVacuum(r.Maps, nil)
+	baseMap, ok := r.Maps[baseID]
+	if !ok {
+		return nil, AffineMatrix{}, fmt.Errorf("no map with floor area for live rendering")
+	}
+	return baseMap, r.Transforms[baseID], nil
+}
+
+// liveBounds returns the world-space bounds (mm) of the floor, segment and
+// wall layers of the live view's base map.
+func liveBounds(baseMap *ValetudoMap, baseTransform AffineMatrix) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.MaxFloat64, math.MaxFloat64
+	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
+
+	for _, layer := range baseMap.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" || layer.Type == "wall" {
+			points := PixelsToPoints(layer.Pixels)
+			for _, p := range points {
+				forEachBlockCell(p, baseMap.CellBlock(), func(c Point) {
+					tp := TransformPoint(c, baseTransform)
+					worldP := Point{
+						X: tp.X * float64(baseMap.PixelSize),
+						Y: tp.Y * float64(baseMap.PixelSize),
+					}
+					minX = math.Min(minX, worldP.X)
+					minY = math.Min(minY, worldP.Y)
+					maxX = math.Max(maxX, worldP.X)
+					maxY = math.Max(maxY, worldP.Y)
+				})
+			}
+		}
+	}
+	return minX, minY, maxX, maxY
+}
+
 // renderLiveToCanvas draws the live view onto a canvas renderer. It renders
-// the base map in greyscale, overlays grid lines, then draws each vacuum
-// position as a colored circle with a text label.
+// the base map in greyscale, overlays grid lines, then draws the chargers and
+// each vacuum position as a colored marker with a heading wedge and an
+// identifier tag.
 func (r *VectorRenderer) renderLiveToCanvas(
 	renderer canvasRenderer,
 	baseMap *ValetudoMap,
@@ -422,20 +457,52 @@ func (r *VectorRenderer) renderLiveToCanvas(
 	positions map[string]*LivePosition,
 	minX, minY, maxX, maxY, centerX, centerY, width, height float64,
 ) {
-	// White background.
-	bgStyle := canvas.DefaultStyle
-	bgStyle.Fill = canvas.Paint{Color: canvas.White}
-	renderer.RenderPath(canvas.Rectangle(width, height), bgStyle, canvas.Identity)
+	r.renderLiveBase(renderer, baseMap, baseTransform, minX, minY, maxX, maxY, centerX, centerY, width, height)
+	pixelSize := float64(baseMap.PixelSize)
+	r.renderChargers(renderer, positions, minX, minY, maxX, maxY, centerX, centerY)
+	r.renderRobots(renderer, positions, pixelSize, minX, minY, maxX, maxY, centerX, centerY)
+}
 
-	toCanvas := func(p Point) (float64, float64) {
+// liveToCanvas returns the projection from world coordinates (mm) to the
+// live view's canvas.
+func (r *VectorRenderer) liveToCanvas(minX, minY, centerX, centerY float64) func(Point) (float64, float64) {
+	return func(p Point) (float64, float64) {
 		rp := r.applyGlobalRotation(p, centerX, centerY)
 		tx := (rp.X - minX) + r.Padding
 		ty := (rp.Y - minY) + r.Padding
 		return tx, ty
 	}
+}
 
-	// pixelSize converts grid coordinates (pixels) to world coordinates (mm).
-	pixelSize := float64(baseMap.PixelSize)
+// liveMarkerRadius derives the robot marker radius from the map extent so
+// markers scale with the floor plan. It uses the shorter axis to keep
+// proportions consistent across aspect ratios: ~0.4% of it, which for a
+// 5 000 mm floor plan gives a 20 mm radius -- visible but unobtrusive.
+func liveMarkerRadius(minX, minY, maxX, maxY float64) float64 {
+	mapSpan := maxX - minX
+	if h := maxY - minY; h < mapSpan {
+		mapSpan = h
+	}
+	if mapSpan < 1 {
+		mapSpan = 1 // guard against degenerate maps
+	}
+	return mapSpan * 0.004
+}
+
+// renderLiveBase draws the parts of the live view that do not move: the
+// greyscale base map, the areas being cleaned and the grid.
+func (r *VectorRenderer) renderLiveBase(
+	renderer canvasRenderer,
+	baseMap *ValetudoMap,
+	baseTransform AffineMatrix,
+	minX, minY, maxX, maxY, centerX, centerY, width, height float64,
+) {
+	// White background.
+	bgStyle := canvas.DefaultStyle
+	bgStyle.Fill = canvas.Paint{Color: canvas.White}
+	renderer.RenderPath(canvas.Rectangle(width, height), bgStyle, canvas.Identity)
+
+	toCanvas := r.liveToCanvas(minX, minY, centerX, centerY)
 
 	// Greyscale colours for the base map.
 	greyFloor := color.RGBA{R: 200, G: 200, B: 200, A: 255}
@@ -445,31 +512,24 @@ func (r *VectorRenderer) renderLiveToCanvas(
 	floorStyle := canvas.DefaultStyle
 	floorStyle.Fill = canvas.Paint{Color: greyFloor}
 	floorStyle.Stroke = canvas.Paint{Color: canvas.Transparent}
+	floorStyle.FillRule = canvas.EvenOdd
 
 	for _, layer := range baseMap.Layers {
 		if layer.Type == "floor" || layer.Type == "segment" {
-			paths := VectorizeLayer(&layer, baseMap.PixelSize, 5.0)
-			for _, p := range paths {
-				cp := &canvas.Path{}
-				for i, pt := range p {
-					transformedPt := TransformPoint(pt, baseTransform)
-					worldPt := Point{
-						X: transformedPt.X * float64(baseMap.PixelSize),
-						Y: transformedPt.Y * float64(baseMap.PixelSize),
-					}
-					cx, cy := toCanvas(worldPt)
-					if i == 0 {
-						cp.MoveTo(cx, cy)
-					} else {
-						cp.LineTo(cx, cy)
-					}
-				}
-				cp.Close()
-				renderer.RenderPath(cp, floorStyle, canvas.Identity)
-			}
+			paths := VectorizeMapLayer(baseMap, &layer, 5.0)
+			cp := floorCanvasPath(paths, func(pt Point) (float64, float64) {
+				transformedPt := TransformPoint(pt, baseTransform)
+				return toCanvas(Point{
+					X: transformedPt.X * float64(baseMap.PixelSize),
+					Y: transformedPt.Y * float64(baseMap.PixelSize),
+				})
+			})
+			renderer.RenderPath(cp, floorStyle, canvas.Identity)
 		}
 	}
 
+	r.renderActive(renderer, toCanvas)
+
 	// Render wall layers (stroked, greyscale).
 	wallStyle := canvas.DefaultStyle
 	wallStyle.Fill = canvas.Paint{Color: canvas.Transparent}
@@ -480,7 +540,7 @@ func (r *VectorRenderer) renderLiveToCanvas(
 
 	for _, layer := range baseMap.Layers {
 		if layer.Type == "wall" {
-			paths := VectorizeLayer(&layer, baseMap.PixelSize, 2.0)
+			paths := VectorizeMapLayer(baseMap, &layer, 2.0)
 			for _, p := range paths {
 				cp := &canvas.Path{}
 				for i, pt := range p {
@@ -528,7 +588,53 @@ func (r *VectorRenderer) renderLiveToCanvas(
 		}
 	}
 
-	// Render vacuum positions as colored circles.
+}
+
+// renderChargers draws each map's charger as a dock icon in the color of
+// its vacuum.
+func (r *VectorRenderer) renderChargers(
+	renderer canvasRenderer,
+	positions map[string]*LivePosition,
+	minX, minY, maxX, maxY, centerX, centerY float64,
+) {
+	toCanvas := r.liveToCanvas(minX, minY, centerX, centerY)
+	vacRadius := liveMarkerRadius(minX, minY, maxX, maxY)
+
+	mapIDs := make([]string, 0, len(r.Maps))
+	for id := range r.Maps {
+		mapIDs = append(mapIDs, id)
+	}
+	sort.Strings(mapIDs)
+	for _, id := range mapIDs {
+		m := r.Maps[id]
+		chargerPt, ok := ExtractChargerPosition(m)
+		if !ok {
+			continue
+		}
+		tp := TransformPoint(chargerPt, r.Transforms[id])
+		cx, cy := toCanvas(Point{X: tp.X * float64(m.PixelSize), Y: tp.Y * float64(m.PixelSize)})
+		chargerColor := parseHexColor("")
+		if pos := positions[id]; pos != nil {
+			chargerColor = parseHexColor(pos.Color)
+		} else if vc, ok := r.Colors[id]; ok {
+			chargerColor = nrgbaToRGBA(vc.Robot)
+		}
+		renderCharger(renderer, cx, cy, 1.6*vacRadius, chargerColor)
+	}
+}
+
+// renderRobots draws each vacuum position as a colored marker with a
+// heading wedge and an identifier tag, ringed in BatteryColor when the
+// vacuum reports its battery. Positions are in the base map's grid
+// coordinates; pixelSize scales them to mm.
+func (r *VectorRenderer) renderRobots(
+	renderer canvasRenderer,
+	positions map[string]*LivePosition,
+	pixelSize float64,
+	minX, minY, maxX, maxY, centerX, centerY float64,
+) {
+	toCanvas := r.liveToCanvas(minX, minY, centerX, centerY)
+
 	// Sort by vacuum ID for deterministic rendering order.
 	vacIDs := make([]string, 0, len(positions))
 	for id := range positions {
@@ -536,18 +642,7 @@ func (r *VectorRenderer) renderLiveToCanvas(
 	}
 	sort.Strings(vacIDs)
 
-	// Derive indicator sizes from the map extent so they scale with the floor plan.
-	// Use the shorter axis to keep proportions consistent across aspect ratios.
-	mapSpan := maxX - minX
-	if h := maxY - minY; h < mapSpan {
-		mapSpan = h
-	}
-	if mapSpan < 1 {
-		mapSpan = 1 // guard against degenerate maps
-	}
-	// The circle radius is ~0.4% of the shorter map dimension.
-	// For a 5 000 mm floor plan this gives a 20 mm radius -- visible but unobtrusive.
-	vacRadius := mapSpan * 0.004
+	vacRadius := liveMarkerRadius(minX, minY, maxX, maxY)
 	vacDirLen := vacRadius * 1.75
 	vacStroke := vacRadius * 0.075
 	vacTagW := vacRadius * 1.25
@@ -558,30 +653,51 @@ func (r *VectorRenderer) renderLiveToCanvas(
 		cx, cy := toCanvas(Point{X: pos.X * pixelSize, Y: pos.Y * pixelSize})
 		vacColor := parseHexColor(pos.Color)
 
-		// Outer circle (border).
-		outerStyle := canvas.DefaultStyle
-		outerStyle.Fill = canvas.Paint{Color: vacColor}
-		outerStyle.Stroke = canvas.Paint{Color: canvas.Black}
-		outerStyle.StrokeWidth = vacStroke
+		// Battery ring around the marker, colored by charge
+		if pos.Battery != nil {
+			ringStyle := canvas.DefaultStyle
+			ringStyle.Fill = canvas.Paint{Color: canvas.Transparent}
+			ringStyle.Stroke = canvas.Paint{Color: nrgbaToRGBA(BatteryColor(*pos.Battery))}
+			ringStyle.StrokeWidth = vacRadius * 0.3
+			renderer.RenderPath(canvas.Circle(vacRadius*1.35).Translate(cx, cy), ringStyle, canvas.Identity)
+		}
 
-		outerPath := canvas.Circle(vacRadius)
-		outerPath = outerPath.Translate(cx, cy)
-		renderer.RenderPath(outerPath, outerStyle, canvas.Identity)
+		// Outer circle (border), or the configured icon.
+		icon := r.Icons[id]
+		if icon != nil && icon.Image != nil {
+			renderMarkerImage(renderer, icon.Image, cx, cy, 2*vacRadius)
+		} else {
+			outerStyle := canvas.DefaultStyle
+			outerStyle.Fill = canvas.Paint{Color: vacColor}
+			outerStyle.Stroke = canvas.Paint{Color: canvas.Black}
+			outerStyle.StrokeWidth = vacStroke
+
+			shape := IconCircle
+			if icon != nil {
+				shape = icon.Shape
+			}
+			outerPath := markerPath(shape, vacRadius)
+			outerPath = outerPath.Translate(cx, cy)
+			renderer.RenderPath(outerPath, outerStyle, canvas.Identity)
+		}
 
-		// Direction indicator: a small line from center in the heading direction.
+		// Heading wedge. Its tip is placed in world space and projected like
+		// the map, so global rotation turns it with everything else.
 		rad := pos.Angle * math.Pi / 180
-		dx := vacDirLen * math.Cos(rad)
-		dy := vacDirLen * math.Sin(rad)
+		tx, ty := toCanvas(Point{
+			X: pos.X*pixelSize + vacDirLen*math.Cos(rad),
+			Y: pos.Y*pixelSize + vacDirLen*math.Sin(rad),
+		})
+		heading := math.Atan2(ty-cy, tx-cx) * 180 / math.Pi
 
 		dirStyle := canvas.DefaultStyle
-		dirStyle.Fill = canvas.Paint{Color: canvas.Transparent}
-		dirStyle.Stroke = canvas.Paint{Color: vacColor}
-		dirStyle.StrokeWidth = vacStroke * 1.5
-
-		dirPath := &canvas.Path{}
-		dirPath.MoveTo(cx, cy)
-		dirPath.LineTo(cx+dx, cy+dy)
-		renderer.RenderPath(dirPath, dirStyle, canvas.Identity)
+		dirStyle.Fill = canvas.Paint{Color: vacColor}
+		dirStyle.Stroke = canvas.Paint{Color: canvas.Black}
+		dirStyle.StrokeWidth = vacStroke
+		dirStyle.StrokeJoiner = canvas.RoundJoiner{}
+
+		wedge := headingWedgePath(vacRadius, vacDirLen)
+		renderer.RenderPath(wedge, dirStyle, canvas.Identity.Translate(cx, cy).Rotate(heading))
 
 		// Label: render vacuum ID as a simple marker below the circle.
 		// Full text rendering requires font loading in tdewolff/canvas.
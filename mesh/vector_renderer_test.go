@@ -3,8 +3,10 @@ package mesh
 import (
 	"bytes"
 	"encoding/xml"
+	"image"
 	"image/color"
 	"image/png"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -311,6 +313,30 @@ func TestVectorRenderer_GridAndCharger(t *testing.T) {
 	t.Logf("Generated SVG with grid and charger: %d bytes", len(svgContent))
 }
 
+func TestVectorRenderer_Frontiers(t *testing.T) {
+	maps := map[string]*ValetudoMap{"vac1": rectFloor(0, 0, 100, 100)}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+
+	r := NewVectorRenderer(maps, transforms, "vac1")
+	r.GridSpacing = 0 // the grid is dashed too
+	var plain bytes.Buffer
+	if err := r.RenderToSVG(&plain); err != nil {
+		t.Fatalf("RenderToSVG: %v", err)
+	}
+	if bytes.Contains(plain.Bytes(), []byte("stroke-dasharray")) {
+		t.Fatal("dashed lines without grid or frontiers")
+	}
+
+	r.Frontiers = []Frontier{{Path: []Point{{X: 500, Y: 0}, {X: 500, Y: 500}}}}
+	var buf bytes.Buffer
+	if err := r.RenderToSVG(&buf); err != nil {
+		t.Fatalf("RenderToSVG: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("stroke-dasharray")) {
+		t.Error("frontier not drawn dashed")
+	}
+}
+
 // TestCalculateWorldBounds verifies that calculateWorldBounds scales pixel coordinates
 // to world coordinates (by pixelSize) before calculating bounds
 func TestCalculateWorldBounds(t *testing.T) {
@@ -652,3 +678,182 @@ func TestRenderLiveToSVG_GridOverlay(t *testing.T) {
 
 	t.Logf("Live SVG with grid: %d bytes", len(svgContent))
 }
+
+// floorWithIsland returns a 1x1 m floor with an empty 40x40 cm island in
+// the middle.
+func floorWithIsland() *ValetudoMap {
+	var pixels []int
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			if x >= 60 && x < 140 && y >= 60 && y < 140 {
+				continue
+			}
+			pixels = append(pixels, x, y)
+		}
+	}
+	return &ValetudoMap{PixelSize: 5, Layers: []MapLayer{{Type: "floor", Pixels: pixels}}}
+}
+
+func TestVectorRenderer_FloorHolesEvenOdd(t *testing.T) {
+	maps := map[string]*ValetudoMap{"vac1": floorWithIsland()}
+	transforms := map[string]AffineMatrix{"vac1": Identity()}
+	r := NewVectorRenderer(maps, transforms, "vac1")
+	r.Resolution = 0.2 // px per mm, keeps the PNG small
+
+	var svgBuf bytes.Buffer
+	if err := r.RenderToSVG(&svgBuf); err != nil {
+		t.Fatalf("RenderToSVG: %v", err)
+	}
+	if !strings.Contains(svgBuf.String(), "evenodd") {
+		t.Error("SVG floor should be filled with the even-odd rule")
+	}
+
+	var pngBuf bytes.Buffer
+	if err := r.RenderToPNG(&pngBuf); err != nil {
+		t.Fatalf("RenderToPNG: %v", err)
+	}
+	img, err := png.Decode(&pngBuf)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	b := img.Bounds()
+	background := img.At(b.Min.X, b.Min.Y)
+	if got := img.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()/2); got != background {
+		t.Errorf("island centre = %v, want background %v", got, background)
+	}
+	// 500 mm padding around 1 m of floor: 30% down is 10 cm into the floor
+	if got := img.At(b.Min.X+b.Dx()/2, b.Min.Y+b.Dy()*3/10); got == background {
+		t.Error("floor around the island should be filled")
+	}
+}
+
+// recordingRenderer captures the paths a canvas renderer is asked to draw.
+type recordingRenderer struct {
+	paths  []*canvas.Path
+	styles []canvas.Style
+	ms     []canvas.Matrix
+}
+
+func (r *recordingRenderer) RenderPath(p *canvas.Path, style canvas.Style, m canvas.Matrix) {
+	r.paths = append(r.paths, p)
+	r.styles = append(r.styles, style)
+	r.ms = append(r.ms, m)
+}
+
+func (r *recordingRenderer) RenderImage(image.Image, canvas.Matrix) {}
+
+// countFills returns how many recorded paths are filled with c.
+func (r *recordingRenderer) countFills(c color.RGBA) int {
+	n := 0
+	for _, s := range r.styles {
+		if s.Fill.Color == c {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRenderLive_HeadingWedge(t *testing.T) {
+	m := &ValetudoMap{
+		PixelSize: 5,
+		Layers:    []MapLayer{{Type: "floor", Pixels: []int{0, 0, 100, 0, 100, 100, 0, 100}}},
+	}
+	red := color.RGBA{255, 0, 0, 255}
+
+	tests := []struct {
+		angle, globalRotation, want float64
+	}{
+		{0, 0, 0},
+		{90, 0, 90},
+		{0, 90, 90},
+		{180, 45, 225},
+	}
+	for _, tt := range tests {
+		r := NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+		r.GlobalRotation = tt.globalRotation
+		positions := map[string]*LivePosition{"vac1": {X: 50, Y: 50, Angle: tt.angle, Color: "#FF0000"}}
+
+		rec := &recordingRenderer{}
+		r.renderLiveToCanvas(rec, m, Identity(), positions, 0, 0, 500, 500, 250, 250, 1500, 1500)
+
+		found := false
+		for i, style := range rec.styles {
+			// The wedge is the only robot-colored path drawn through a rotation
+			if style.Fill.Color != red || rec.ms[i] == canvas.Identity {
+				continue
+			}
+			found = true
+			base := rec.ms[i].Dot(canvas.Point{})
+			tip := rec.ms[i].Dot(canvas.Point{X: 1})
+			got := math.Atan2(tip.Y-base.Y, tip.X-base.X) * 180 / math.Pi
+			if d := math.Mod(got-tt.want+720, 360); d > 1e-6 && d < 360-1e-6 {
+				t.Errorf("angle %g rotation %g: wedge points at %g°, want %g°", tt.angle, tt.globalRotation, got, tt.want)
+			}
+		}
+		if !found {
+			t.Errorf("angle %g rotation %g: no heading wedge drawn", tt.angle, tt.globalRotation)
+		}
+	}
+}
+
+func TestRenderLive_Charger(t *testing.T) {
+	render := func(entities []MapEntity) *recordingRenderer {
+		m := &ValetudoMap{
+			PixelSize: 5,
+			Layers:    []MapLayer{{Type: "floor", Pixels: []int{0, 0, 100, 0, 100, 100, 0, 100}}},
+			Entities:  entities,
+		}
+		r := NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+		rec := &recordingRenderer{}
+		r.renderLiveToCanvas(rec, m, Identity(), nil, 0, 0, 500, 500, 250, 250, 1500, 1500)
+		return rec
+	}
+
+	without := render(nil)
+	with := render([]MapEntity{{Type: "charger_location", Points: []int{250, 250}}})
+	// Dock in the vacuum's color plus its white lightning bolt
+	if got := len(with.paths) - len(without.paths); got != 2 {
+		t.Errorf("charger added %d paths, want 2", got)
+	}
+	if with.countFills(canvas.White) != without.countFills(canvas.White)+1 {
+		t.Error("charger drawn without its lightning bolt")
+	}
+}
+
+func TestRenderLive_BatteryRing(t *testing.T) {
+	m := &ValetudoMap{
+		PixelSize: 5,
+		Layers:    []MapLayer{{Type: "floor", Pixels: []int{0, 0, 100, 0, 100, 100, 0, 100}}},
+	}
+	r := NewVectorRenderer(map[string]*ValetudoMap{"vac1": m}, map[string]AffineMatrix{"vac1": Identity()}, "vac1")
+	rings := func(battery *int) (n int) {
+		rec := &recordingRenderer{}
+		positions := map[string]*LivePosition{"vac1": {X: 50, Y: 50, Color: "#0000FF", Battery: battery}}
+		r.renderLiveToCanvas(rec, m, Identity(), positions, 0, 0, 500, 500, 250, 250, 1500, 1500)
+		for _, s := range rec.styles {
+			if s.Stroke.Color == nrgbaToRGBA(BatteryColor(10)) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if got := rings(nil); got != 0 {
+		t.Errorf("marker without battery drew %d rings", got)
+	}
+	low := 10
+	if got := rings(&low); got != 1 {
+		t.Errorf("marker at 10%% drew %d red rings, want 1", got)
+	}
+}
+
+func TestHeadingWedgePath(t *testing.T) {
+	p := headingWedgePath(10, 20)
+	b := p.Bounds()
+	if b.X1 != 20 {
+		t.Errorf("wedge tip at x=%g, want 20", b.X1)
+	}
+	if b.X0 < 0 || b.Y0 != -6 || b.Y1 != 6 {
+		t.Errorf("wedge bounds = %+v, want its base in front of the center, 12 wide", b)
+	}
+}
@@ -7,6 +7,11 @@ import (
 // Path represents a sequential list of points
 type Path []Point
 
+// minHoleArea is the smallest empty region (mm²) inside a floor or segment
+// that is traced as a hole, such as a kitchen island; smaller gaps are
+// noise.
+const minHoleArea = 40000
+
 // VisitKey uniquely identifies an edge visit
 type VisitKey struct {
 	Idx int
@@ -16,15 +21,32 @@ type VisitKey struct {
 // VectorizeLayer converts a map layer into a set of simplified vector paths
 // It uses contour tracing and RDP to simplify them
 func VectorizeLayer(layer *MapLayer, pixelSize int, tolerance float64) []Path {
+	return vectorizeLayer(layer, pixelSize, 1, tolerance)
+}
+
+// VectorizeMapLayer is VectorizeLayer for a layer of m, tracing each pixel of
+// a downsampled map as the block of cells it stands for.
+func VectorizeMapLayer(m *ValetudoMap, layer *MapLayer, tolerance float64) []Path {
+	return vectorizeLayer(layer, m.PixelSize, m.CellBlock(), tolerance)
+}
+
+// vectorizeLayer traces layer with each pixel filling a block x block square
+// of cells.
+func vectorizeLayer(layer *MapLayer, pixelSize, block int, tolerance float64) []Path {
 	if layer == nil || len(layer.Pixels) == 0 {
 		return nil
 	}
 
 	// 1. Reconstruct dense grid from sparse pixels
-	grid, minX, minY, width, height := pixelsToGrid(layer.Pixels, pixelSize)
+	grid, minX, minY, width, height := pixelsToGrid(layer.Pixels, block)
 
-	// 2. Trace contours
+	// 2. Trace contours, plus the holes inside floors. The tracer follows
+	// outer boundaries only
 	contours := traceContours(grid, width, height)
+	if layer.Type == "floor" || layer.Type == "segment" {
+		minPixels := int(math.Ceil(minHoleArea / float64(pixelSize*pixelSize)))
+		contours = append(contours, traceHoles(grid, width, height, minPixels)...)
+	}
 
 	// 3. Transform back to pixel coordinates and simplify
 	var result []Path
@@ -51,55 +73,33 @@ func VectorizeLayer(layer *MapLayer, pixelSize int, tolerance float64) []Path {
 	return result
 }
 
-// pixelsToGrid converts flat pixel array to a 2D boolean grid
-func pixelsToGrid(pixels []int, pixelSize int) (grid []bool, minX, minY, width, height int) {
-	if len(pixels) == 0 {
+// pixelsToGrid converts flat pixel array to a 2D boolean grid, padded by one
+// cell. Each pixel sets the block x block square of cells whose top-left cell
+// it is, the cells a pixel of a downsampled map stands for (see CellBlock).
+func pixelsToGrid(pixels []int, block int) (grid []bool, minX, minY, width, height int) {
+	if len(pixels) < 2 {
 		return nil, 0, 0, 0, 0
 	}
+	block = max(block, 1)
 
 	// Calculate bounds in grid coordinates
-	minX, minY = math.MaxInt, math.MaxInt
-	maxX, maxY := math.MinInt, math.MinInt
-
-	points := make([]Point, 0, len(pixels)/2)
-	for i := 0; i+1 < len(pixels); i += 2 {
-		px := pixels[i]
-		py := pixels[i+1]
-
-		gx := px
-		gy := py
-
-		if gx < minX {
-			minX = gx
-		}
-		if gy < minY {
-			minY = gy
-		}
-		if gx > maxX {
-			maxX = gx
-		}
-		if gy > maxY {
-			maxY = gy
-		}
-
-		points = append(points, Point{X: float64(gx), Y: float64(gy)})
-	}
-
-	width = maxX - minX + 1
-	height = maxY - minY + 1
+	minX, minY, maxX, maxY, _ := pixelBounds(pixels)
+	maxX, maxY = maxX+block-1, maxY+block-1
 
 	// Create grid with 1px padding
 	pad := 1
-	gridWidth := width + 2*pad
-	gridHeight := height + 2*pad
+	gridWidth := maxX - minX + 1 + 2*pad
+	gridHeight := maxY - minY + 1 + 2*pad
 	grid = make([]bool, gridWidth*gridHeight)
 
-	for _, p := range points {
-		x := int(p.X) - minX + pad
-		y := int(p.Y) - minY + pad
-		idx := y*gridWidth + x
-		if idx >= 0 && idx < len(grid) {
-			grid[idx] = true
+	for i := 0; i+1 < len(pixels); i += 2 {
+		x := pixels[i] - minX + pad
+		y := pixels[i+1] - minY + pad
+		for dy := 0; dy < block; dy++ {
+			row := (y + dy) * gridWidth
+			for dx := 0; dx < block; dx++ {
+				grid[row+x+dx] = true
+			}
 		}
 	}
 
@@ -187,6 +187,77 @@ func traceContours(grid []bool, width, height int) []Path {
 	return paths
 }
 
+// traceHoles returns the outline of each empty region of at least minPixels
+// cells that is enclosed by set pixels. The grid from pixelsToGrid has an
+// empty border, so every other empty cell connects to the outside.
+func traceHoles(grid []bool, width, height, minPixels int) []Path {
+	label := make([]int, len(grid)) // 0 unvisited, -1 outside, >0 hole id
+	neighbors := func(i int) []int {
+		x, y := i%width, i/width
+		var n []int
+		if x > 0 {
+			n = append(n, i-1)
+		}
+		if x+1 < width {
+			n = append(n, i+1)
+		}
+		if y > 0 {
+			n = append(n, i-width)
+		}
+		if y+1 < height {
+			n = append(n, i+width)
+		}
+		return n
+	}
+	fill := func(start, id int) []int {
+		cells := []int{start}
+		label[start] = id
+		for k := 0; k < len(cells); k++ {
+			for _, j := range neighbors(cells[k]) {
+				if !grid[j] && label[j] == 0 {
+					label[j] = id
+					cells = append(cells, j)
+				}
+			}
+		}
+		return cells
+	}
+	if len(grid) == 0 || grid[0] {
+		return nil
+	}
+	fill(0, -1)
+
+	var holes []Path
+	id := 0
+	for i := range grid {
+		if grid[i] || label[i] != 0 {
+			continue
+		}
+		id++
+		cells := fill(i, id)
+		if len(cells) < minPixels {
+			continue
+		}
+
+		// Trace the region as if it were set and keep its largest outline
+		mask := make([]bool, len(grid))
+		for _, c := range cells {
+			mask[c] = true
+		}
+		var best Path
+		bestArea := 0.0
+		for _, p := range traceContours(mask, width, height) {
+			if area := math.Abs(ringArea(p)); area > bestArea {
+				best, bestArea = p, area
+			}
+		}
+		if best != nil {
+			holes = append(holes, best)
+		}
+	}
+	return holes
+}
+
 // traceBoundary follows the edge using Moore-Neighbor tracing with right-hand rule
 // startFacing: direction we're initially FACING (0=N, 1=E, 2=S, 3=W)
 func traceBoundary(startX, startY, startFacing int, grid []bool, width, height int, seen map[VisitKey]bool) Path {
@@ -1,6 +1,7 @@
 package mesh
 
 import (
+	"math"
 	"testing"
 )
 
@@ -253,3 +254,34 @@ func TestTraceContoursSimple(t *testing.T) {
 		})
 	}
 }
+
+func TestVectorizeLayer_FloorHoles(t *testing.T) {
+	m := floorWithIsland()
+
+	// The 40x40 cm island is traced as a hole besides the outer boundary
+	var holes int
+	for _, p := range VectorizeLayer(&m.Layers[0], m.PixelSize, 5.0) {
+		area := math.Abs(ringArea(p))
+		if area > 70*70 && area < 90*90 && pointInRing(Point{X: 100, Y: 100}, p) {
+			holes++
+		}
+	}
+	if holes != 1 {
+		t.Errorf("found %d island outlines, want 1", holes)
+	}
+
+	// Regions smaller than the minimum are noise
+	grid, _, _, width, height := pixelsToGrid(m.Layers[0].Pixels, 1)
+	if got := len(traceHoles(grid, width, height, 80*80)); got != 1 {
+		t.Errorf("traceHoles found %d holes, want 1", got)
+	}
+	if got := len(traceHoles(grid, width, height, 80*80+1)); got != 0 {
+		t.Errorf("traceHoles with a larger minimum found %d holes, want 0", got)
+	}
+
+	// Walls enclosing a room are not holes
+	wall := &MapLayer{Type: "wall", Pixels: m.Layers[0].Pixels}
+	if got, want := len(VectorizeLayer(wall, m.PixelSize, 5.0)), len(VectorizeLayer(&m.Layers[0], m.PixelSize, 5.0))-1; got != want {
+		t.Errorf("wall layer has %d paths, want %d", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package mesh
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce is how long a map export must stay unchanged before it
+// is parsed. Copies over scp arrive as several writes.
+const DefaultWatchDebounce = 500 * time.Millisecond
+
+// MapWatcher watches a directory for new or updated Valetudo map exports.
+type MapWatcher struct {
+	Dir      string
+	Debounce time.Duration // quiet period before parsing (0 = DefaultWatchDebounce)
+	Options  ParseOptions
+	Exports  *ExportPattern // recognizes exports named by other tools; nil accepts only ValetudoMapExport-*
+
+	mu   sync.Mutex
+	seen map[string][sha256.Size]byte // content hash of the last parsed version
+}
+
+// NewMapWatcher creates a watcher for map exports in dir.
+func NewMapWatcher(dir string) *MapWatcher {
+	return &MapWatcher{
+		Dir:  dir,
+		seen: make(map[string][sha256.Size]byte),
+	}
+}
+
+// Prime records the contents of the exports already in the directory so that
+// rewriting one without changes is not reported.
+func (w *MapWatcher) Prime() {
+	files, _ := w.Exports.Find(w.Dir)
+	for _, file := range files {
+		if sum, err := hashFile(file); err == nil {
+			w.record(file, sum)
+		}
+	}
+}
+
+// Watch blocks until ctx is done, calling onChange for every export that is
+// created or whose content changes. Files that fail to parse, such as a copy
+// still in progress, are retried on their next write.
+func (w *MapWatcher) Watch(ctx context.Context, onChange func(vacuumID string, m *ValetudoMap)) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	defer func() { _ = fw.Close() }()
+
+	if err := fw.Add(w.Dir); err != nil {
+		return fmt.Errorf("watching %s: %w", w.Dir, err)
+	}
+
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+
+	// Each export gets a timer that is pushed back by every write; when it
+	// fires the file has been quiet for the debounce period
+	pending := make(map[string]*time.Timer)
+	ready := make(chan string)
+	defer func() {
+		for _, t := range pending {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if !w.isMapExport(ev.Name) || !(ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write)) {
+				continue
+			}
+			if t, ok := pending[ev.Name]; ok {
+				t.Reset(debounce)
+				continue
+			}
+			path := ev.Name
+			pending[path] = time.AfterFunc(debounce, func() {
+				select {
+				case ready <- path:
+				case <-ctx.Done():
+				}
+			})
+
+		case path := <-ready:
+			delete(pending, path)
+			w.load(path, onChange)
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Warning: watching %s: %v", w.Dir, err)
+		}
+	}
+}
+
+// load parses an export and reports it if its content changed. The file is
+// hashed and parsed as streams so large exports are never held in memory
+// as raw JSON.
+func (w *MapWatcher) load(path string, onChange func(vacuumID string, m *ValetudoMap)) {
+	sum, err := hashFile(path)
+	if err != nil {
+		log.Printf("Warning: reading %s: %v", path, err)
+		return
+	}
+	if w.unchanged(path, sum) {
+		return
+	}
+
+	m, err := ParseMapFileWithOptions(path, w.Options)
+	if err != nil {
+		log.Printf("Warning: %s not loaded (waiting for next write): %v", filepath.Base(path), err)
+		return
+	}
+	w.record(path, sum)
+	id, _ := w.Exports.VacuumID(path)
+	onChange(id, m)
+}
+
+// unchanged reports whether sum matches the last parsed version of path.
+func (w *MapWatcher) unchanged(path string, sum [sha256.Size]byte) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prev, ok := w.seen[path]
+	return ok && prev == sum
+}
+
+// record stores sum as the current version of path.
+func (w *MapWatcher) record(path string, sum [sha256.Size]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seen[path] = sum
+}
+
+// hashFile returns the SHA-256 of a file's contents.
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// isMapExport reports whether path names a map export. Hidden files, such
+// as the temporary files of an atomic write, are not.
+func (w *MapWatcher) isMapExport(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return false
+	}
+	_, ok := w.Exports.VacuumID(path)
+	return ok
+}
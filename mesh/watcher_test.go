@@ -0,0 +1,144 @@
+package mesh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// MapWatcher
+// ---------------------------------------------------------------------------
+
+const watchedMapJSON = `{"__class":"ValetudoMap","pixelSize":5,"metaData":{"nonce":"%s"},"layers":[{"type":"floor","pixels":[1,1,2,2]}]}`
+
+type watchEvent struct {
+	id string
+	m  *ValetudoMap
+}
+
+// startWatcher runs a watcher on a fresh directory and returns the directory
+// and a channel of reported maps.
+func startWatcher(t *testing.T, setup func(dir string)) (string, <-chan watchEvent) {
+	t.Helper()
+	dir := t.TempDir()
+	if setup != nil {
+		setup(dir)
+	}
+
+	w := NewMapWatcher(dir)
+	w.Debounce = 20 * time.Millisecond
+	w.Prime()
+
+	events := make(chan watchEvent, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := w.Watch(ctx, func(id string, m *ValetudoMap) { events <- watchEvent{id, m} }); err != nil {
+			t.Errorf("Watch: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Give the watcher time to register the directory
+	time.Sleep(50 * time.Millisecond)
+	return dir, events
+}
+
+func writeExport(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan watchEvent) watchEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for map change")
+		return watchEvent{}
+	}
+}
+
+func expectNoEvent(t *testing.T, events <-chan watchEvent) {
+	t.Helper()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected map change for %s", ev.id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestMapWatcher_ReportsNewAndChangedExports(t *testing.T) {
+	dir, events := startWatcher(t, nil)
+	path := filepath.Join(dir, "ValetudoMapExport-rocky7-2024-05-01.json")
+
+	writeExport(t, path, fmt.Sprintf(watchedMapJSON, "a"))
+	ev := expectEvent(t, events)
+	if ev.id != "rocky7" || ev.m.MetaData.Nonce != "a" {
+		t.Errorf("got %s nonce %q, want rocky7 nonce a", ev.id, ev.m.MetaData.Nonce)
+	}
+
+	// Rewriting identical content is not a change
+	writeExport(t, path, fmt.Sprintf(watchedMapJSON, "a"))
+	expectNoEvent(t, events)
+
+	writeExport(t, path, fmt.Sprintf(watchedMapJSON, "b"))
+	if ev := expectEvent(t, events); ev.m.MetaData.Nonce != "b" {
+		t.Errorf("nonce = %q, want b", ev.m.MetaData.Nonce)
+	}
+}
+
+func TestMapWatcher_RetriesIncompleteWrites(t *testing.T) {
+	dir, events := startWatcher(t, nil)
+	path := filepath.Join(dir, "ValetudoMapExport-vac.json")
+
+	writeExport(t, path, `{"__class":"ValetudoMap","layers":[`)
+	expectNoEvent(t, events)
+
+	writeExport(t, path, fmt.Sprintf(watchedMapJSON, "done"))
+	if ev := expectEvent(t, events); ev.id != "vac" {
+		t.Errorf("id = %q, want vac", ev.id)
+	}
+}
+
+func TestMapWatcher_IgnoresOtherFilesAndPrimedExports(t *testing.T) {
+	var primed string
+	dir, events := startWatcher(t, func(dir string) {
+		primed = filepath.Join(dir, "ValetudoMapExport-old.json")
+		writeExport(t, primed, fmt.Sprintf(watchedMapJSON, "old"))
+	})
+
+	writeExport(t, filepath.Join(dir, "composite-map.png"), "png")
+	writeExport(t, filepath.Join(dir, "ValetudoMapExport-notes.txt"), "text")
+	writeExport(t, primed, fmt.Sprintf(watchedMapJSON, "old"))
+	expectNoEvent(t, events)
+}
+
+func TestIsMapExport(t *testing.T) {
+	tests := map[string]bool{
+		"/data/ValetudoMapExport-rocky7.json":            true,
+		"ValetudoMapExport-rocky7-2024-01-01.json":       true,
+		"/data/ValetudoMapExport-rocky7.json.tmp":        false,
+		"/data/.calibration-cache.json":                  false,
+		"/data/ValetudoMapExport-dir/something-else.png": false,
+		"/data/ValetudoMapExport-rocky7.json.gz":         true,
+		"/data/.ValetudoMapExport-rocky7.json.tmp-123":   false,
+	}
+	w := NewMapWatcher("/data")
+	for path, want := range tests {
+		if got := w.isMapExport(path); got != want {
+			t.Errorf("isMapExport(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package mesh
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkQueue runs slow jobs such as calibration one at a time on a background
+// goroutine, so MQTT message handlers hand work off instead of blocking the
+// client while it runs. Jobs are keyed: queuing a key that is still waiting
+// replaces its job, so a burst of updates for one vacuum runs once with the
+// latest data.
+type WorkQueue struct {
+	mu      sync.Mutex
+	order   []string          // waiting keys, oldest first
+	pending map[string]func() // waiting job per key
+	wake    chan struct{}
+}
+
+// NewWorkQueue creates an empty queue. Jobs run once Run is started.
+func NewWorkQueue() *WorkQueue {
+	return &WorkQueue{
+		pending: make(map[string]func()),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues job under key without waiting for it to run. It returns
+// false when a job for key was already waiting and has been replaced.
+func (q *WorkQueue) Enqueue(key string, job func()) bool {
+	q.mu.Lock()
+	_, waiting := q.pending[key]
+	q.pending[key] = job
+	if !waiting {
+		q.order = append(q.order, key)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return !waiting
+}
+
+// Len returns the number of jobs waiting to run.
+func (q *WorkQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.order)
+}
+
+// Run executes queued jobs in order until ctx is cancelled. Jobs still
+// waiting at that point are dropped. A job that panics is logged and counted
+// and the queue moves on to the next.
+func (q *WorkQueue) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if key, job := q.next(); job != nil {
+			runJob(key, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// runJob runs job, recovering from a panic in it.
+func runJob(key string, job func()) {
+	defer RecoverPanic("job " + key)
+	job()
+}
+
+// next removes and returns the oldest waiting job and its key, or nil.
+func (q *WorkQueue) next() (string, func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.order) == 0 {
+		return "", nil
+	}
+	key := q.order[0]
+	q.order = q.order[1:]
+	job := q.pending[key]
+	delete(q.pending, key)
+	return key, job
+}
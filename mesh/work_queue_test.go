@@ -0,0 +1,122 @@
+package mesh
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// WorkQueue
+// ---------------------------------------------------------------------------
+
+func TestWorkQueue_RunsInOrder(t *testing.T) {
+	q := NewWorkQueue()
+	var mu sync.Mutex
+	var ran []string
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		q.Enqueue(key, func() {
+			mu.Lock()
+			ran = append(ran, key)
+			mu.Unlock()
+			wg.Done()
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+	wg.Wait()
+
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestWorkQueue_CoalescesWaitingKey(t *testing.T) {
+	q := NewWorkQueue()
+	var ran []int
+	if !q.Enqueue("vac", func() { ran = append(ran, 1) }) {
+		t.Error("first Enqueue should report a new job")
+	}
+	if q.Enqueue("vac", func() { ran = append(ran, 2) }) {
+		t.Error("second Enqueue for a waiting key should report a replacement")
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", q.Len())
+	}
+
+	for _, job := q.next(); job != nil; _, job = q.next() {
+		job()
+	}
+	if !reflect.DeepEqual(ran, []int{2}) {
+		t.Errorf("ran %v, want only the latest job", ran)
+	}
+}
+
+func TestWorkQueue_EnqueueDoesNotBlock(t *testing.T) {
+	q := NewWorkQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Enqueue("slow", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		q.Enqueue("other", func() {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue blocked while a job was running")
+	}
+	close(release)
+}
+
+func TestWorkQueue_StopsOnCancel(t *testing.T) {
+	q := NewWorkQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(stopped)
+	}()
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestWorkQueue_SurvivesPanickingJob(t *testing.T) {
+	q := NewWorkQueue()
+	done := make(chan struct{})
+	q.Enqueue("bad", func() { panic("malformed map") })
+	q.Enqueue("good", func() { close(done) })
+
+	before := RecoveredPanics()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the job after a panicking one did not run")
+	}
+	if got := RecoveredPanics() - before; got != 1 {
+		t.Errorf("recovered %d panics, want 1", got)
+	}
+}
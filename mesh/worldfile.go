@@ -0,0 +1,61 @@
+package mesh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// WorldTransform returns the affine transform from pixel coordinates of the
+// image the last Render produced to world millimeters in the reference
+// map's frame, with the global rotation, crop and size limit of that render
+// undone. The second result is false before the first Render.
+func (r *CompositeRenderer) WorldTransform() (AffineMatrix, bool) {
+	if r.toWorld == nil {
+		return Identity(), false
+	}
+	ps := referencePixelSize(r.Maps, r.Reference)
+	o := r.toWorld(0, 0)
+	ex := r.toWorld(1, 0)
+	ey := r.toWorld(0, 1)
+	return AffineMatrix{
+		A: (ex.X - o.X) * ps, B: (ey.X - o.X) * ps, Tx: o.X * ps,
+		C: (ex.Y - o.Y) * ps, D: (ey.Y - o.Y) * ps, Ty: o.Y * ps,
+	}, true
+}
+
+// WorldFilePath returns the path of the world file that goes with an
+// image: the image's extension shortened to its first and last letter plus
+// "w", as GIS tools look for (map.png → map.pgw, map.tif → map.tfw), or
+// ".wld" for other names.
+func WorldFilePath(imagePath string) string {
+	ext := filepath.Ext(imagePath)
+	base := strings.TrimSuffix(imagePath, ext)
+	if len(ext) < 3 {
+		return base + ".wld"
+	}
+	return base + "." + ext[1:2] + ext[len(ext)-1:] + "w"
+}
+
+// WriteWorldFile writes an ESRI world file for an image whose pixels map to
+// world millimeters by pixelToWorld (see WorldTransform), so GIS and CAD
+// tools place the image at its real scale and origin. Map Y grows downwards
+// like image rows while GIS Y grows upwards, so Y is negated: the image
+// shows the right way up, at the negated map Y.
+func WriteWorldFile(path string, pixelToWorld AffineMatrix) error {
+	m := pixelToWorld
+	// The reference point is the center of the upper-left pixel
+	cx := 0.5*m.A + 0.5*m.B + m.Tx
+	cy := 0.5*m.C + 0.5*m.D + m.Ty
+
+	var b strings.Builder
+	for _, v := range []float64{m.A, -m.C, m.B, -m.D, cx, -cy} {
+		b.WriteString(strconv.FormatFloat(v+0, 'f', -1, 64)) // +0 turns -0 into 0
+		b.WriteByte('\n')
+	}
+	if err := WriteFileAtomic(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("writing world file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package mesh
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWorldTransform(t *testing.T) {
+	m := &ValetudoMap{PixelSize: 5, Layers: []MapLayer{
+		{Type: "floor", Pixels: []int{100, 200, 140, 200, 100, 230, 140, 230}},
+	}}
+	maps := map[string]*ValetudoMap{"ref": m}
+
+	for _, rot := range []float64{0, 90, 30} {
+		r := NewCompositeRenderer(maps, map[string]AffineMatrix{"ref": Identity()}, "ref")
+		r.GlobalRotation = rot
+		r.Legend.Hidden = true
+		if _, ok := r.WorldTransform(); ok {
+			t.Fatal("WorldTransform before Render")
+		}
+		img := r.Render()
+		tr, ok := r.WorldTransform()
+		if !ok {
+			t.Fatal("no WorldTransform after Render")
+		}
+		// Every pixel drawn for a cell maps back into that cell's
+		// millimeters, give or take the map pixel cardinal rotations
+		// round by
+		drawn := 0
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if img.RGBAAt(x, y) == (color.RGBA{240, 240, 240, 255}) {
+					continue
+				}
+				drawn++
+				w := TransformPoint(Point{X: float64(x) + 0.5, Y: float64(y) + 0.5}, tr)
+				inCell := false
+				for i := 0; i+1 < len(m.Layers[0].Pixels); i += 2 {
+					cx, cy := float64(m.Layers[0].Pixels[i]*5), float64(m.Layers[0].Pixels[i+1]*5)
+					if w.X >= cx-5 && w.X <= cx+10 && w.Y >= cy-5 && w.Y <= cy+10 {
+						inCell = true
+					}
+				}
+				if !inCell {
+					t.Errorf("rotation %.0f: pixel (%d, %d) maps to (%.1f, %.1f)mm, outside every cell", rot, x, y, w.X, w.Y)
+				}
+			}
+		}
+		if drawn == 0 {
+			t.Errorf("rotation %.0f: nothing drawn", rot)
+		}
+		// One pixel spans one map pixel of 5mm at scale 1
+		if scale := math.Hypot(tr.A, tr.C); math.Abs(scale-5) > 1e-9 {
+			t.Errorf("rotation %.0f: pixel size %.3fmm, want 5", rot, scale)
+		}
+	}
+}
+
+func TestWriteWorldFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "map.pgw")
+	if err := WriteWorldFile(path, AffineMatrix{A: 5, D: 5, Tx: 1000, Ty: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for _, line := range strings.Fields(string(data)) {
+		v, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		got = append(got, v)
+	}
+	// Y grows upwards in GIS; the upper-left pixel center is at (2.5, 2.5)
+	// pixels' worth of millimeters from the origin
+	want := []float64{5, 0, 0, -5, 1002.5, -2002.5}
+	if len(got) != len(want) {
+		t.Fatalf("world file has %d lines, want %d:\n%s", len(got), len(want), data)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+	if strings.Contains(string(data), "-0\n") {
+		t.Errorf("world file has negative zeros:\n%s", data)
+	}
+}
+
+func TestWorldFilePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"composite-map.png": "composite-map.pgw",
+		"out/map.tif":       "out/map.tfw",
+		"map.jpeg":          "map.jgw",
+		"map":               "map.wld",
+	} {
+		if got := WorldFilePath(in); got != want {
+			t.Errorf("WorldFilePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
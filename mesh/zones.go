@@ -0,0 +1,295 @@
+package mesh
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaxCleanZones is the most rectangles sent to one vacuum for a single zone.
+// Valetudo cleans zones as axis-aligned rectangles in the robot's own frame,
+// so a zone that is rotated relative to a robot is approximated by the
+// largest rectangles that fit inside it.
+const MaxCleanZones = 10
+
+// ZoneConfig is a named cleaning zone in the reference map's world
+// coordinates (mm).
+type ZoneConfig struct {
+	Name       string   `yaml:"name" json:"name"`
+	Points     []Point  `yaml:"points" json:"points"`                             // Polygon vertices; two points are opposite corners of a rectangle
+	Iterations int      `yaml:"iterations,omitempty" json:"iterations,omitempty"` // Cleaning passes (default 1)
+	Vacuums    []string `yaml:"vacuums,omitempty" json:"vacuums,omitempty"`       // Only these vacuums clean the zone (default: every vacuum that covers it)
+}
+
+// Validate checks that the zone has a name and a non-degenerate outline.
+func (z ZoneConfig) Validate() error {
+	if strings.TrimSpace(z.Name) == "" {
+		return fmt.Errorf("zone name is required")
+	}
+	if z.Iterations < 0 {
+		return fmt.Errorf("zone %s: iterations must not be negative", z.Name)
+	}
+	if len(z.Points) < 2 {
+		return fmt.Errorf("zone %s: at least two points are required", z.Name)
+	}
+	if ringArea(z.Polygon()) == 0 {
+		return fmt.Errorf("zone %s has zero area", z.Name)
+	}
+	return nil
+}
+
+// Polygon returns the zone outline in mm. Two points expand to the
+// rectangle they span.
+func (z ZoneConfig) Polygon() Path {
+	if len(z.Points) != 2 {
+		return Path(z.Points)
+	}
+	a, b := z.Points[0], z.Points[1]
+	return Path{{X: a.X, Y: a.Y}, {X: b.X, Y: a.Y}, {X: b.X, Y: b.Y}, {X: a.X, Y: b.Y}}
+}
+
+// iterations returns the number of cleaning passes.
+func (z ZoneConfig) iterations() int {
+	return max(z.Iterations, 1)
+}
+
+// ZoneSet holds the named zones available for cleaning. It is seeded from
+// config and can be changed at runtime; runtime changes are not saved.
+type ZoneSet struct {
+	mu    sync.RWMutex
+	zones map[string]ZoneConfig // keyed by lower-case name
+}
+
+// NewZoneSet creates a zone set holding zones.
+func NewZoneSet(zones []ZoneConfig) *ZoneSet {
+	s := &ZoneSet{zones: make(map[string]ZoneConfig, len(zones))}
+	for _, z := range zones {
+		s.zones[strings.ToLower(z.Name)] = z
+	}
+	return s
+}
+
+// List returns all zones sorted by name.
+func (s *ZoneSet) List() []ZoneConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ZoneConfig, 0, len(s.zones))
+	for _, z := range s.zones {
+		out = append(out, z)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Get returns the zone named name (case-insensitive).
+func (s *ZoneSet) Get(name string) (ZoneConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	z, ok := s.zones[strings.ToLower(name)]
+	return z, ok
+}
+
+// Put validates z and adds it, replacing any zone with the same name.
+func (s *ZoneSet) Put(z ZoneConfig) error {
+	if err := z.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zones[strings.ToLower(z.Name)] = z
+	return nil
+}
+
+// Delete removes the zone named name and reports whether it existed.
+func (s *ZoneSet) Delete(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	_, ok := s.zones[key]
+	delete(s.zones, key)
+	return ok
+}
+
+// ValetudoZone is one rectangle of a Valetudo zone-cleaning command, in the
+// robot's own map coordinates.
+type ValetudoZone struct {
+	Points ValetudoZonePoints `json:"points"`
+}
+
+// ValetudoZonePoints are the corners of a zone, clockwise from top-left.
+type ValetudoZonePoints struct {
+	PA ValetudoPoint `json:"pA"`
+	PB ValetudoPoint `json:"pB"`
+	PC ValetudoPoint `json:"pC"`
+	PD ValetudoPoint `json:"pD"`
+}
+
+// ValetudoPoint is an integer map coordinate as Valetudo expects it.
+type ValetudoPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// ZoneCleanPlan is the zone-cleaning command for one vacuum.
+type ZoneCleanPlan struct {
+	VacuumID   string         `json:"vacuumId"`
+	Zones      []ValetudoZone `json:"zones"`
+	Iterations int            `json:"iterations"`
+	Coverage   float64        `json:"coverage"` // fraction of the vacuum's part of the zone inside Zones
+}
+
+// PlanZoneClean translates a world zone into each vacuum's own coordinates.
+// Every floor cell of a vacuum is mapped into the reference frame and kept
+// if it falls inside the zone, so each vacuum only cleans the part of the
+// zone it knows. The kept cells are split into at most MaxCleanZones
+// axis-aligned rectangles. Vacuums that do not cover the zone, or are not
+// listed in zone.Vacuums, are omitted. Plans are sorted by vacuum ID.
+func PlanZoneClean(zone ZoneConfig, maps map[string]*ValetudoMap, transforms map[string]AffineMatrix, reference string) []ZoneCleanPlan {
+	refPixelSize := referencePixelSize(maps, reference)
+	outline := zone.Polygon()
+	ring := make(Path, len(outline))
+	for i, p := range outline {
+		ring[i] = Point{X: p.X / refPixelSize, Y: p.Y / refPixelSize}
+	}
+
+	ids := make([]string, 0, len(maps))
+	for id := range maps {
+		if len(zone.Vacuums) == 0 || containsFold(zone.Vacuums, id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var plans []ZoneCleanPlan
+	for _, id := range ids {
+		transform, ok := transforms[id]
+		if !ok {
+			transform = Identity()
+		}
+		mask := zoneMask(maps[id], transform, ring)
+		if mask.count == 0 {
+			continue
+		}
+
+		pixelSize := float64(defaultPixelSize)
+		if maps[id].PixelSize > 0 {
+			pixelSize = float64(maps[id].PixelSize)
+		}
+		rects, covered := mask.rectangles(MaxCleanZones)
+		plan := ZoneCleanPlan{
+			VacuumID:   id,
+			Iterations: zone.iterations(),
+			Coverage:   roundTo(float64(covered)/float64(mask.count), 3),
+		}
+		for _, r := range rects {
+			x0, y0 := int(math.Round(float64(r.Min.X)*pixelSize)), int(math.Round(float64(r.Min.Y)*pixelSize))
+			x1, y1 := int(math.Round(float64(r.Max.X)*pixelSize)), int(math.Round(float64(r.Max.Y)*pixelSize))
+			plan.Zones = append(plan.Zones, ValetudoZone{Points: ValetudoZonePoints{
+				PA: ValetudoPoint{x0, y0}, PB: ValetudoPoint{x1, y0},
+				PC: ValetudoPoint{x1, y1}, PD: ValetudoPoint{x0, y1},
+			}})
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// cellMask is a set of cells in a vacuum's own grid.
+type cellMask struct {
+	coverageGrid
+	count int
+}
+
+// zoneMask returns the vacuum's floor cells whose position in the reference
+// frame lies inside ring (reference grid units).
+func zoneMask(m *ValetudoMap, transform AffineMatrix, ring Path) cellMask {
+	var pixels []int
+	for _, layer := range m.Layers {
+		if layer.Type == "floor" || layer.Type == "segment" {
+			pixels = append(pixels, layer.Pixels...)
+		}
+	}
+	if len(pixels) < 2 {
+		return cellMask{}
+	}
+	grid, minX, minY, width, height := pixelsToGrid(pixels, m.CellBlock())
+
+	mask := cellMask{coverageGrid: coverageGrid{minX: minX, minY: minY, width: width, height: height}}
+	mask.cells = make([]bool, len(grid))
+	for i, set := range grid {
+		if !set {
+			continue
+		}
+		// Test the cell center
+		p := Point{X: float64(minX+i%width) + 0.5, Y: float64(minY+i/width) + 0.5}
+		if pointInRing(TransformPoint(p, transform), ring) {
+			mask.cells[i] = true
+			mask.count++
+		}
+	}
+	return mask
+}
+
+// rectangles splits the mask into rectangles greedily, each grown right
+// and then down as far as it stays inside the mask, and returns the largest
+// limit of them along with the number of cells they cover.
+func (m cellMask) rectangles(limit int) ([]image.Rectangle, int) {
+	taken := make([]bool, len(m.cells))
+	free := func(x, y int) bool {
+		i := y*m.width + x
+		return m.cells[i] && !taken[i]
+	}
+
+	var rects []image.Rectangle
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if !free(x, y) {
+				continue
+			}
+			x1 := x + 1
+			for x1 < m.width && free(x1, y) {
+				x1++
+			}
+			y1 := y + 1
+		grow:
+			for y1 < m.height {
+				for cx := x; cx < x1; cx++ {
+					if !free(cx, y1) {
+						break grow
+					}
+				}
+				y1++
+			}
+			for cy := y; cy < y1; cy++ {
+				for cx := x; cx < x1; cx++ {
+					taken[cy*m.width+cx] = true
+				}
+			}
+			rects = append(rects, image.Rect(m.minX+x, m.minY+y, m.minX+x1, m.minY+y1))
+		}
+	}
+
+	area := func(r image.Rectangle) int { return r.Dx() * r.Dy() }
+	sort.SliceStable(rects, func(i, j int) bool { return area(rects[i]) > area(rects[j]) })
+	if len(rects) > limit {
+		rects = rects[:limit]
+	}
+	covered := 0
+	for _, r := range rects {
+		covered += area(r)
+	}
+	return rects, covered
+}
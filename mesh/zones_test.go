@@ -0,0 +1,139 @@
+package mesh
+
+import (
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// ZoneConfig
+// ---------------------------------------------------------------------------
+
+func TestZoneConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		zone ZoneConfig
+		ok   bool
+	}{
+		{"rectangle", ZoneConfig{Name: "hall", Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 50}}}, true},
+		{"polygon", ZoneConfig{Name: "hall", Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 0, Y: 100}}}, true},
+		{"no name", ZoneConfig{Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 50}}}, false},
+		{"one point", ZoneConfig{Name: "hall", Points: []Point{{X: 0, Y: 0}}}, false},
+		{"zero area", ZoneConfig{Name: "hall", Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 0}}}, false},
+		{"negative iterations", ZoneConfig{Name: "hall", Points: []Point{{X: 0, Y: 0}, {X: 100, Y: 50}}, Iterations: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.zone.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestZoneSet(t *testing.T) {
+	s := NewZoneSet([]ZoneConfig{{Name: "Kitchen", Points: []Point{{X: 0, Y: 0}, {X: 10, Y: 10}}}})
+
+	if _, ok := s.Get("kitchen"); !ok {
+		t.Error("Get is not case-insensitive")
+	}
+	if err := s.Put(ZoneConfig{Name: "Hall"}); err == nil {
+		t.Error("Put accepted an invalid zone")
+	}
+	if err := s.Put(ZoneConfig{Name: "Hall", Points: []Point{{X: 0, Y: 0}, {X: 5, Y: 5}}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if list := s.List(); len(list) != 2 || list[0].Name != "Hall" || list[1].Name != "Kitchen" {
+		t.Errorf("List = %+v, want Hall, Kitchen", list)
+	}
+	if !s.Delete("HALL") || s.Delete("hall") {
+		t.Error("Delete should succeed once")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PlanZoneClean
+// ---------------------------------------------------------------------------
+
+func TestPlanZoneClean_AlignedRectangle(t *testing.T) {
+	maps := map[string]*ValetudoMap{"a": rectFloor(0, 0, 100, 100)}
+	zone := ZoneConfig{Name: "z", Points: []Point{{X: 100, Y: 50}, {X: 300, Y: 250}}}
+
+	plans := PlanZoneClean(zone, maps, map[string]AffineMatrix{"a": Identity()}, "a")
+	if len(plans) != 1 {
+		t.Fatalf("got %d plans, want 1", len(plans))
+	}
+	p := plans[0]
+	if p.VacuumID != "a" || p.Iterations != 1 || p.Coverage != 1 || len(p.Zones) != 1 {
+		t.Fatalf("plan = %+v", p)
+	}
+	want := ValetudoZonePoints{
+		PA: ValetudoPoint{100, 50}, PB: ValetudoPoint{300, 50},
+		PC: ValetudoPoint{300, 250}, PD: ValetudoPoint{100, 250},
+	}
+	if p.Zones[0].Points != want {
+		t.Errorf("zone = %+v, want %+v", p.Zones[0].Points, want)
+	}
+}
+
+func TestPlanZoneClean_InverseTransformAndClipping(t *testing.T) {
+	// b's map is stored rotated: its local cell (x, y) is world (40-y, x)
+	// once rotated 90° and shifted. It covers world x 20..40, y 0..40.
+	maps := map[string]*ValetudoMap{
+		"a": rectFloor(0, 0, 40, 40),
+		"b": rectFloor(0, 0, 40, 20),
+	}
+	transforms := map[string]AffineMatrix{"a": Identity(), "b": CreateRotationTranslation(90, 40, 0)}
+
+	// World zone x 25..35, y 10..20 cells (5mm each), inside both maps
+	zone := ZoneConfig{Name: "z", Points: []Point{{X: 125, Y: 50}, {X: 175, Y: 100}}, Iterations: 2}
+	plans := PlanZoneClean(zone, maps, transforms, "a")
+	if len(plans) != 2 {
+		t.Fatalf("got %d plans, want 2", len(plans))
+	}
+
+	b := plans[1]
+	if b.VacuumID != "b" || b.Iterations != 2 || len(b.Zones) != 1 {
+		t.Fatalf("plan = %+v", b)
+	}
+	// In b's frame the zone spans local x 10..20 and y 5..15 cells
+	want := ValetudoZonePoints{
+		PA: ValetudoPoint{50, 25}, PB: ValetudoPoint{100, 25},
+		PC: ValetudoPoint{100, 75}, PD: ValetudoPoint{50, 75},
+	}
+	if b.Zones[0].Points != want {
+		t.Errorf("b zone = %+v, want %+v", b.Zones[0].Points, want)
+	}
+
+	// A zone over world x 0..15 is outside b's coverage
+	zone.Points = []Point{{X: 0, Y: 0}, {X: 75, Y: 75}}
+	plans = PlanZoneClean(zone, maps, transforms, "a")
+	if len(plans) != 1 || plans[0].VacuumID != "a" {
+		t.Errorf("plans = %+v, want only a", plans)
+	}
+
+	// Restricting the zone to b leaves nothing to clean
+	zone.Vacuums = []string{"B"}
+	if plans := PlanZoneClean(zone, maps, transforms, "a"); len(plans) != 0 {
+		t.Errorf("plans = %+v, want none", plans)
+	}
+}
+
+func TestCellMask_RectanglesLimit(t *testing.T) {
+	// A staircase of 4 steps needs 4 rectangles
+	m := cellMask{coverageGrid: coverageGrid{width: 4, height: 4, cells: make([]bool, 16)}}
+	for y := 0; y < 4; y++ {
+		for x := 0; x <= y; x++ {
+			m.cells[y*4+x] = true
+			m.count++
+		}
+	}
+	rects, covered := m.rectangles(10)
+	if len(rects) != 4 || covered != 10 {
+		t.Errorf("got %d rectangles covering %d cells, want 4 covering 10", len(rects), covered)
+	}
+
+	rects, covered = m.rectangles(2)
+	if len(rects) != 2 || covered != 7 {
+		t.Errorf("limited to 2: got %d rectangles covering %d cells, want 2 covering 7", len(rects), covered)
+	}
+}
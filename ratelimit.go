@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+const (
+	// defaultMaxConcurrentRenders caps simultaneous renders when not configured.
+	defaultMaxConcurrentRenders = 2
+
+	// defaultRenderQueueTimeout is how long a request waits for a render slot.
+	defaultRenderQueueTimeout = 5 * time.Second
+
+	// clientIdleTimeout is how long an idle client's bucket is kept.
+	clientIdleTimeout = 10 * time.Minute
+)
+
+// tokenBucket tracks one client's request allowance.
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// renderLimiter protects expensive render endpoints with a concurrency
+// semaphore and an optional per-client token bucket.
+type renderLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+
+	ratePerSec float64 // 0 disables per-client limiting
+	burst      float64
+
+	mu          sync.Mutex
+	clients     map[string]*tokenBucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+// newRenderLimiter builds a limiter from the HTTP config. A nil config uses
+// the defaults: a small concurrency cap and no per-client rate limit.
+func newRenderLimiter(cfg *mesh.HTTPConfig) *renderLimiter {
+	var c mesh.HTTPConfig
+	if cfg != nil {
+		c = *cfg
+	}
+
+	maxRenders := c.MaxConcurrentRenders
+	if maxRenders <= 0 {
+		maxRenders = defaultMaxConcurrentRenders
+	}
+	queueTimeout := defaultRenderQueueTimeout
+	if c.RenderQueueSeconds > 0 {
+		queueTimeout = time.Duration(c.RenderQueueSeconds) * time.Second
+	}
+
+	l := &renderLimiter{
+		slots:        make(chan struct{}, maxRenders),
+		queueTimeout: queueTimeout,
+		clients:      make(map[string]*tokenBucket),
+		now:          time.Now,
+	}
+
+	if rpm := c.RateLimit.RequestsPerMinute; rpm > 0 {
+		l.ratePerSec = float64(rpm) / 60
+		burst := c.RateLimit.Burst
+		if burst <= 0 {
+			burst = max(rpm/6, 1)
+		}
+		l.burst = float64(burst)
+	}
+	return l
+}
+
+// wrap applies rate limiting and the concurrency cap to a render handler.
+// Clients over their rate get 429; requests that cannot get a render slot
+// within the queue timeout get 503. Both set Retry-After. Each request is
+// traced from here, starting with its wait for a render slot.
+func (l *renderLimiter) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientKey(r)
+		trace := mesh.StartTrace(r.Method + " " + r.URL.Path)
+		defer trace.End()
+		r = r.WithContext(mesh.WithTrace(r.Context(), trace))
+
+		if wait, ok := l.allow(client); !ok {
+			retry := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", retry))
+			log.Printf("[HTTP] Rate limit exceeded for %s on %s", client, r.URL.Path)
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		trace.Step("queue")
+		if !l.acquire(r.Context()) {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(l.queueTimeout.Seconds())))
+			log.Printf("[HTTP] Render capacity exhausted, rejecting %s from %s", r.URL.Path, client)
+			http.Error(w, "Render capacity exhausted, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		defer l.release()
+
+		trace.Step("prepare")
+		next(w, r)
+	}
+}
+
+// allow consumes a token for the client. When none are left it returns
+// false and the time until the next token is available.
+func (l *renderLimiter) allow(client string) (time.Duration, bool) {
+	if l.ratePerSec == 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.ratePerSec)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.ratePerSec * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// cleanup drops buckets for clients that have been idle for a while so the
+// map does not grow without bound. Callers must hold l.mu.
+func (l *renderLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < clientIdleTimeout {
+		return
+	}
+	for client, b := range l.clients {
+		if now.Sub(b.lastSeen) > clientIdleTimeout {
+			delete(l.clients, client)
+		}
+	}
+	l.lastCleanup = now
+}
+
+// acquire waits for a render slot until the queue timeout or the request
+// is cancelled.
+func (l *renderLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a render slot.
+func (l *renderLimiter) release() {
+	<-l.slots
+}
+
+// clientKey identifies the client by remote IP (without port).
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
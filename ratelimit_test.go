@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func requestFrom(addr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/composite-map.png", nil)
+	r.RemoteAddr = addr
+	return r
+}
+
+// ---------------------------------------------------------------------------
+// Per-client rate limit
+// ---------------------------------------------------------------------------
+
+func TestRenderLimiter_RateLimitPerClient(t *testing.T) {
+	l := newRenderLimiter(&mesh.HTTPConfig{
+		RateLimit: mesh.RateLimitConfig{RequestsPerMinute: 60, Burst: 2},
+	})
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	handler := l.wrap(okHandler)
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, requestFrom("10.0.0.1:1234"))
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("429 response should set Retry-After")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want [200 200 429]", codes)
+	}
+
+	// A different client has its own bucket
+	rec := httptest.NewRecorder()
+	handler(rec, requestFrom("10.0.0.2:1234"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("other client status = %d, want 200", rec.Code)
+	}
+
+	// Tokens refill over time (1 per second at 60/min)
+	now = now.Add(time.Second)
+	rec = httptest.NewRecorder()
+	handler(rec, requestFrom("10.0.0.1:5678"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status after refill = %d, want 200", rec.Code)
+	}
+}
+
+func TestRenderLimiter_NoRateLimitByDefault(t *testing.T) {
+	handler := newRenderLimiter(nil).wrap(okHandler)
+	for i := 0; i < 50; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, requestFrom("10.0.0.1:1234"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, rec.Code)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Concurrency cap
+// ---------------------------------------------------------------------------
+
+func TestRenderLimiter_ConcurrencyCap(t *testing.T) {
+	l := newRenderLimiter(&mesh.HTTPConfig{MaxConcurrentRenders: 1})
+	l.queueTimeout = 20 * time.Millisecond
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	slow := l.wrap(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slow(httptest.NewRecorder(), requestFrom("10.0.0.1:1"))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	l.wrap(okHandler)(rec, requestFrom("10.0.0.2:1"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while saturated = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("503 response should set Retry-After")
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	rec = httptest.NewRecorder()
+	l.wrap(okHandler)(rec, requestFrom("10.0.0.2:1"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status after slot freed = %d, want 200", rec.Code)
+	}
+}
+
+func TestNewHTTPServer_RateLimitsRenderEndpoints(t *testing.T) {
+	config := &mesh.Config{HTTP: mesh.HTTPConfig{
+		RateLimit: mesh.RateLimitConfig{RequestsPerMinute: 1, Burst: 1},
+	}}
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker(), Config: config})
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, requestFrom("10.0.0.1:1"))
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, requestFrom("10.0.0.1:1"))
+	if second.Code != http.StatusTooManyRequests {
+		t.Errorf("second render status = %d, want 429", second.Code)
+	}
+
+	// Health is not rate limited
+	health := httptest.NewRecorder()
+	handler.ServeHTTP(health, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if health.Code != http.StatusOK {
+		t.Errorf("/health status = %d, want 200", health.Code)
+	}
+}
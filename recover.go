@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// recoverMiddleware turns a panic in an endpoint into a 500 response. The
+// panic is logged with its stack trace and counted in /health, and the
+// server carries on with other requests. http.ErrAbortHandler, which a
+// handler panics with to abort a response on purpose, is passed on.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &headerTracker{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			mesh.RecordPanic(r.Method+" "+r.URL.Path, v)
+			if rw.wroteHeader {
+				// Too late for a status; cut the response short instead
+				panic(http.ErrAbortHandler)
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// headerTracker records whether a response has started.
+type headerTracker struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (t *headerTracker) WriteHeader(code int) {
+	t.wroteHeader = true
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *headerTracker) Write(b []byte) (int, error) {
+	t.wroteHeader = true
+	return t.ResponseWriter.Write(b)
+}
+
+// Flush passes on to the underlying writer, for streamed responses.
+func (t *headerTracker) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		t.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (t *headerTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// grpcRecoverInterceptors returns server options turning a panic in a call
+// into an Internal error, logged and counted like a panicking HTTP endpoint.
+func grpcRecoverInterceptors() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(grpcRecoverInterceptor),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					mesh.RecordPanic(info.FullMethod, v)
+					err = status.Error(codes.Internal, "internal error")
+				}
+			}()
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// grpcRecoverInterceptor recovers from a panic in a unary call.
+func grpcRecoverInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			mesh.RecordPanic(info.FullMethod, v)
+			resp, err = nil, status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
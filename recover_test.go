@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/boom" {
+			var features []mesh.Point
+			_ = features[3]
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	before := mesh.RecoveredPanics()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("panicking endpoint status = %d, want 500", rec.Code)
+	}
+	if got := mesh.RecoveredPanics() - before; got != 1 {
+		t.Errorf("recovered %d panics, want 1", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fine", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status after a panic = %d, want 204", rec.Code)
+	}
+}
+
+func TestRecoverMiddleware_AbortsStartedResponse(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic("mid-render")
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("panic after the response started = %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestHealth_ReportsRecoveredPanics(t *testing.T) {
+	mesh.RecordPanic("test", "recorded")
+	handler := newHTTPServer(httpServerOptions{StateTracker: emptyTracker()})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Panics int64 `json:"panics"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&health); err != nil {
+		t.Fatalf("decoding /health: %v", err)
+	}
+	if health.Panics < 1 || health.Panics != mesh.RecoveredPanics() {
+		t.Errorf("panics = %d, want %d", health.Panics, mesh.RecoveredPanics())
+	}
+}
+
+func TestGRPCRecoverInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/tudomesh.v1.TudoMesh/GetUnifiedMap"}
+	_, err := grpcRecoverInterceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		panic("nil map")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("code = %v, want Internal", status.Code(err))
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// Commands accepted by --remote
+const (
+	remoteRender    = "render"
+	remoteCalibrate = "calibrate"
+	remoteStats     = "stats"
+)
+
+// remoteTimeout bounds each request to the service. Calibration fetches a
+// map from every robot, so it can take a while.
+const remoteTimeout = 2 * time.Minute
+
+// remoteTokenEnv names the environment variable holding the bearer token for
+// a service with http.auth enabled. It is not a flag so the token stays out
+// of process listings and shell history.
+const remoteTokenEnv = "TUDOMESH_TOKEN"
+
+// remoteClient runs CLI commands against a running tudomesh service over
+// its REST API instead of reading local files.
+type remoteClient struct {
+	base   *url.URL
+	client *http.Client
+	out    io.Writer
+	json   bool   // print calibration and stats as the service's JSON
+	token  string // bearer token sent with every request, from $TUDOMESH_TOKEN
+}
+
+// newRemoteClient validates the --remote base URL.
+func newRemoteClient(base string, out io.Writer) (*remoteClient, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid --remote %q (want http://host:port)", base)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &remoteClient{base: u, client: &http.Client{Timeout: remoteTimeout}, out: out}, nil
+}
+
+// do sends a request to path on the service and returns the response body
+// of a successful request.
+func (c *remoteClient) do(method, path string) ([]byte, error) {
+	u := *c.base
+	u.Path += path
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: reading response: %w", method, path, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// render downloads the composite map in the requested format, naming the
+// files like a local --render.
+func (c *remoteClient) render(format, vectorFormat, output string) error {
+	if format != "raster" && format != "vector" && format != "both" {
+		return fmt.Errorf("invalid format: %s (must be raster, vector, or both)", format)
+	}
+	if format != "raster" && vectorFormat != "svg" {
+		return fmt.Errorf("PNG vector format not yet implemented (use --vector-format=svg)")
+	}
+
+	if format == "raster" || format == "both" {
+		path := output
+		if format == "both" && !strings.HasSuffix(path, ".png") {
+			path = strings.TrimSuffix(path, filepath.Ext(path)) + ".png"
+		}
+		if err := c.download("/composite-map.png", path); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(c.out, "Created raster: %s\n", path)
+	}
+	if format == "vector" || format == "both" {
+		path := strings.TrimSuffix(output, filepath.Ext(output)) + ".svg"
+		if err := c.download("/composite-map.svg", path); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(c.out, "Created vector SVG: %s\n", path)
+	}
+	return nil
+}
+
+// download saves the response to GET path in file.
+func (c *remoteClient) download(path, file string) error {
+	body, err := c.do(http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", file, err)
+	}
+	return nil
+}
+
+// calibrate asks the service to recalibrate every vacuum and prints the
+// vacuums it queued. The calibrations run in the background on the leader.
+func (c *remoteClient) calibrate() error {
+	body, err := c.do(http.MethodPost, "/calibrate")
+	if err != nil {
+		return err
+	}
+	var queued calibrationQueued
+	if err := json.Unmarshal(body, &queued); err != nil {
+		return fmt.Errorf("decoding calibration response: %w", err)
+	}
+	if c.json {
+		return writeIndentedJSON(c.out, body)
+	}
+
+	for _, id := range queued.Queued {
+		_, _ = fmt.Fprintf(c.out, "%-25s: calibration queued\n", id)
+	}
+	_, _ = fmt.Fprintln(c.out, "New transforms appear in /calibration.json once aligned")
+	return nil
+}
+
+// stats prints the service's coverage statistics.
+func (c *remoteClient) stats() error {
+	body, err := c.do(http.MethodGet, "/stats.json")
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeIndentedJSON(c.out, body)
+	}
+	var stats struct {
+		ReferenceVacuum string `json:"referenceVacuum"`
+		mesh.CoverageStats
+		UnifiedMap *mesh.UnifiedMetadata `json:"unifiedMap"`
+	}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return fmt.Errorf("decoding stats: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(c.out, "Reference vacuum: %s\n", stats.ReferenceVacuum)
+	printCoverage(c.out, stats.CoverageStats)
+	if um := stats.UnifiedMap; um != nil {
+		_, _ = fmt.Fprintf(c.out, "Unified map: version %d, %d vacuums, updated %s\n",
+			um.Version, um.VacuumCount, time.Unix(um.LastUpdated, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// writeIndentedJSON writes a JSON response body to w, indented like the
+// local --json output.
+func writeIndentedJSON(w io.Writer, body []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
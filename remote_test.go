@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// remoteTestServer serves the HTTP API for two overlapping 1 m² floors.
+func remoteTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	square := func(x0 int) []int {
+		var pixels []int
+		for y := 0; y < 200; y++ {
+			for x := x0; x < x0+200; x++ {
+				pixels = append(pixels, x, y)
+			}
+		}
+		return pixels
+	}
+	st := mesh.NewStateTracker()
+	st.UpdateMap("vac1", &mesh.ValetudoMap{PixelSize: 5, MetaData: mesh.MapMetaData{TotalLayerArea: 40000}, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(0)}}})
+	st.UpdateMap("vac2", &mesh.ValetudoMap{PixelSize: 5, MetaData: mesh.MapMetaData{TotalLayerArea: 40000}, Layers: []mesh.MapLayer{{Type: "floor", Pixels: square(100)}}})
+	srv := httptest.NewServer(newHTTPServer(httpServerOptions{StateTracker: st, RefID: "vac1"}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// ---------------------------------------------------------------------------
+// newRemoteClient
+// ---------------------------------------------------------------------------
+
+func TestNewRemoteClient(t *testing.T) {
+	for _, base := range []string{"http://host:8080", "https://host/tudomesh/"} {
+		if _, err := newRemoteClient(base, &bytes.Buffer{}); err != nil {
+			t.Errorf("newRemoteClient(%q): %v", base, err)
+		}
+	}
+	for _, base := range []string{"", "host:8080", "ftp://host", "http://"} {
+		if _, err := newRemoteClient(base, &bytes.Buffer{}); err == nil {
+			t.Errorf("newRemoteClient(%q): expected error", base)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Remote commands
+// ---------------------------------------------------------------------------
+
+func TestRemoteRender(t *testing.T) {
+	srv := remoteTestServer(t)
+	var out bytes.Buffer
+	client, err := newRemoteClient(srv.URL, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "composite-map.png")
+	if err := client.render("both", "svg", output); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	png, err := os.ReadFile(output)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("raster output is not a PNG (err %v)", err)
+	}
+	svg, err := os.ReadFile(strings.TrimSuffix(output, ".png") + ".svg")
+	if err != nil || !bytes.Contains(svg, []byte("<svg")) {
+		t.Errorf("vector output is not an SVG (err %v)", err)
+	}
+
+	if err := client.render("vector", "png", output); err == nil {
+		t.Error("expected error for vector PNG")
+	}
+}
+
+func TestRemoteStats(t *testing.T) {
+	srv := remoteTestServer(t)
+	var out bytes.Buffer
+	client, err := newRemoteClient(srv.URL+"/", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.stats(); err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	for _, want := range []string{"Reference vacuum: vac1", "Coverage: 1.50 m² total, 33.3% seen by more than one vacuum", "vac1 / vac2: 0.50 m² shared"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRemoteStats_JSON(t *testing.T) {
+	var out bytes.Buffer
+	client, err := newRemoteClient(remoteTestServer(t).URL, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.json = true
+	if err := client.stats(); err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	var stats struct {
+		ReferenceVacuum string  `json:"referenceVacuum"`
+		TotalArea       float64 `json:"totalArea"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &stats); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if stats.ReferenceVacuum != "vac1" || stats.TotalArea != 1.5 {
+		t.Errorf("stats = %+v, want vac1 and 1.5 m²", stats)
+	}
+}
+
+func TestRemoteCalibrate(t *testing.T) {
+	// Without --mqtt the service cannot calibrate
+	var out bytes.Buffer
+	client, err := newRemoteClient(remoteTestServer(t).URL, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.calibrate(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("calibrate without calibrator: err = %v, want 503", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/tudomesh/calibrate" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, calibrationQueued{Queued: []string{"vac1", "vac2"}})
+	}))
+	defer srv.Close()
+
+	out.Reset()
+	client, err = newRemoteClient(srv.URL+"/tudomesh", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.calibrate(); err != nil {
+		t.Fatalf("calibrate: %v", err)
+	}
+	for _, want := range []string{"vac1", "vac2", "calibration queued", "/calibration.json"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRemoteClient_Token(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := newRemoteClient(srv.URL, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.do(http.MethodGet, "/stats.json"); err != nil || got != "" {
+		t.Errorf("without token: Authorization %q, err %v", got, err)
+	}
+	client.token = "s3cret"
+	if _, err := client.do(http.MethodPost, "/calibrate"); err != nil || got != "Bearer s3cret" {
+		t.Errorf("with token: Authorization %q, err %v, want Bearer s3cret", got, err)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// Renderer draws one output format from the current maps. It returns the
+// encoded image and its media type.
+type Renderer interface {
+	Render(ctx context.Context, opts RenderOptions) ([]byte, string, error)
+}
+
+// RenderOptions is what a Renderer draws for one request.
+type RenderOptions struct {
+	Maps       map[string]*mesh.ValetudoMap
+	Transforms map[string]mesh.AffineMatrix // onto the reference, snapped to the floorplan
+	Reference  string
+	Rotation   float64                       // global rotation in degrees
+	Query      url.Values                    // request parameters, e.g. legend and overlay
+	Positions  map[string]*mesh.LivePosition // live renders only
+	Active     map[string]mesh.ActiveArea    // live renders only
+	Unified    *mesh.UnifiedMap              // nil until built
+	Format     string                        // raster encoding, see requestedFormat (empty is PNG); vector renders ignore it
+}
+
+// Render errors the HTTP server answers with 503; any other error is a 500,
+// or a 400 for an invalidOptionError.
+var (
+	errNoMaps            = errors.New("No maps available")
+	errNoDrawableContent = errors.New("No drawable map content")
+)
+
+// invalidOptionError is a request parameter a Renderer cannot use.
+type invalidOptionError struct {
+	err error
+}
+
+func (e invalidOptionError) Error() string { return e.err.Error() }
+
+// renderEnv is what the renderers of one HTTP server share: the config and
+// everything loaded from it once.
+type renderEnv struct {
+	config    *mesh.Config
+	icons     map[string]*mesh.MarkerIcon
+	font      *mesh.Font
+	floorplan *mesh.Floorplan
+	budget    mesh.MemoryBudget
+	rotation  rotationFunc
+}
+
+// rasterOptions checks the format and parses the legend and overlay
+// parameters of opts.Query.
+func (env *renderEnv) rasterOptions(opts RenderOptions) (mesh.LegendOptions, mesh.OverlayOptions, error) {
+	if err := checkFormat(opts.Format); err != nil {
+		return mesh.LegendOptions{}, mesh.OverlayOptions{}, err
+	}
+	legend, err := legendOptions(env.config, opts.Query)
+	if err != nil {
+		return legend, mesh.OverlayOptions{}, invalidOptionError{err}
+	}
+	overlay, err := overlayOptions(env.config, opts.Query)
+	if err != nil {
+		return legend, overlay, invalidOptionError{err}
+	}
+	return legend, overlay, nil
+}
+
+// vectorRenderer returns a vector renderer of opts set up from the config.
+func (env *renderEnv) vectorRenderer(opts RenderOptions) *mesh.VectorRenderer {
+	renderer := mesh.NewVectorRenderer(opts.Maps, opts.Transforms, opts.Reference)
+	renderer.GlobalRotation = opts.Rotation
+	renderer.Layering = mesh.LayeringFromConfig(env.config)
+	renderer.Icons = env.icons
+	if env.config != nil && env.config.GridSpacing > 0 {
+		renderer.Padding = env.config.GridSpacing / 2
+	}
+	return renderer
+}
+
+// compositePNG is the color-coded composite of all maps.
+type compositePNG struct{ env *renderEnv }
+
+func (c compositePNG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	legend, overlay, err := c.env.rasterOptions(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := mesh.NewCompositeRenderer(opts.Maps, opts.Transforms, opts.Reference)
+	renderer.GlobalRotation = opts.Rotation
+	renderer.MaxDimension = c.env.budget.MaxRenderDimension()
+	renderer.FillFloors = c.env.config != nil && c.env.config.Memory.FillFloors
+	applyConfigColors(renderer, c.env.config)
+	renderer.Legend = legend
+	renderer.Overlay = overlay
+	renderer.Icons = c.env.icons
+	renderer.Font = c.env.font
+	renderer.Floorplan = c.env.floorplan
+
+	// An image without content would be invalid
+	if !renderer.HasDrawableContent() {
+		return nil, "", errNoDrawableContent
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return drawAndEncode(ctx, renderer.Render, opts.Format)
+}
+
+// livePNG is the greyscale floor plan with the robots' live positions.
+type livePNG struct{ env *renderEnv }
+
+func (l livePNG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	legend, overlay, err := l.env.rasterOptions(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := mesh.NewCompositeRenderer(opts.Maps, opts.Transforms, opts.Reference)
+	renderer.GlobalRotation = opts.Rotation
+	renderer.MaxDimension = l.env.budget.MaxRenderDimension()
+	renderer.FillFloors = l.env.config != nil && l.env.config.Memory.FillFloors
+	renderer.Legend = legend
+	renderer.Overlay = overlay
+	renderer.Icons = l.env.icons
+	renderer.Font = l.env.font
+	renderer.Floorplan = l.env.floorplan
+	renderer.Active = opts.Active
+
+	// Without drawable content the positions are drawn on a blank map
+	if !renderer.HasDrawableContent() {
+		for id, m := range opts.Maps {
+			layerTypes := make([]string, len(m.Layers))
+			for i, layer := range m.Layers {
+				layerTypes[i] = layer.Type
+			}
+			log.Printf("[DEBUG] renderer: map %s has no drawable pixels. Layers found: [%s]", id, strings.Join(layerTypes, ", "))
+		}
+		log.Printf("[DEBUG] renderer: no drawable content for /live.png, rendering positions only")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return drawAndEncode(ctx, func() *image.RGBA { return renderer.RenderLive(opts.Positions) }, opts.Format)
+}
+
+// floorplanPNG is the greyscale unified floor plan, or the vacuums' own
+// floors and walls overlaid until the unified map is built.
+type floorplanPNG struct{ env *renderEnv }
+
+func (f floorplanPNG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	if err := checkFormat(opts.Format); err != nil {
+		return nil, "", err
+	}
+	if unified := mesh.NewUnifiedRenderer(opts.Unified); unified.HasDrawableContent() {
+		unified.GlobalRotation = f.env.rotation(opts.Maps, opts.Unified.Metadata.ReferenceVacuum)
+		unified.MaxDimension = f.env.budget.MaxRenderDimension()
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		return drawAndEncode(ctx, unified.Render, opts.Format)
+	}
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := mesh.NewCompositeRenderer(opts.Maps, opts.Transforms, opts.Reference)
+	renderer.GlobalRotation = opts.Rotation
+	renderer.MaxDimension = f.env.budget.MaxRenderDimension()
+	renderer.FillFloors = f.env.config != nil && f.env.config.Memory.FillFloors
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return drawAndEncode(ctx, renderer.RenderGreyscale, opts.Format)
+}
+
+// drawAndEncode draws an image and encodes it as format, timing both stages
+// in the request's trace.
+func drawAndEncode(ctx context.Context, draw func() *image.RGBA, format string) ([]byte, string, error) {
+	trace := mesh.TraceFrom(ctx)
+	trace.Step("draw")
+	img := draw()
+	trace.Step("encode")
+	return encodeImage(img, format)
+}
+
+// compositeSVG is the color-coded composite as SVG, with frontiers dashed.
+type compositeSVG struct{ env *renderEnv }
+
+func (c compositeSVG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := c.env.vectorRenderer(opts)
+	mesh.TraceFrom(ctx).Step("frontiers")
+	renderer.Frontiers = mesh.MapsFrontiers(opts.Maps, opts.Transforms)
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	mesh.TraceFrom(ctx).Step("draw")
+	var buf bytes.Buffer
+	if err := renderer.RenderToSVG(&buf); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/svg+xml", nil
+}
+
+// floorplanSVG is the greyscale floor plan as SVG, without positions.
+type floorplanSVG struct{ env *renderEnv }
+
+func (f floorplanSVG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := f.env.vectorRenderer(opts)
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	mesh.TraceFrom(ctx).Step("draw")
+	var buf bytes.Buffer
+	if err := renderer.RenderToSVG(&buf); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/svg+xml", nil
+}
+
+// liveSVG is the floor plan as SVG with the robots' live positions. The
+// floor plan is rendered once per map change and only the robots per
+// request.
+type liveSVG struct {
+	env   *renderEnv
+	cache *mesh.LiveSVGCache
+}
+
+func (l liveSVG) Render(ctx context.Context, opts RenderOptions) ([]byte, string, error) {
+	if len(opts.Maps) == 0 {
+		return nil, "", errNoMaps
+	}
+	renderer := l.env.vectorRenderer(opts)
+	renderer.Active = opts.Active
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// A full render is needed when a robot is off the cached viewport
+	mesh.TraceFrom(ctx).Step("draw")
+	var buf bytes.Buffer
+	live, err := l.cache.Get(renderer)
+	if err == nil && live.Contains(opts.Positions) {
+		err = live.Render(&buf, opts.Positions)
+	} else {
+		err = renderer.RenderLiveToSVG(&buf, opts.Positions)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/svg+xml", nil
+}
+
+// rendererEntry is a Renderer and the endpoint that serves it.
+type rendererEntry struct {
+	endpoint endpoint
+	renderer Renderer
+}
+
+// rendererRegistry lists the image endpoints of the HTTP server in
+// registration order. Adding an output format means registering its
+// Renderer here; the server, API docs and --doctor pick it up.
+type rendererRegistry struct {
+	entries []rendererEntry
+}
+
+// register adds renderer, served at ep.Path.
+func (r *rendererRegistry) register(ep endpoint, renderer Renderer) {
+	r.entries = append(r.entries, rendererEntry{endpoint: ep, renderer: renderer})
+}
+
+// lookup returns the renderer served at path.
+func (r *rendererRegistry) lookup(path string) (Renderer, bool) {
+	for _, e := range r.entries {
+		if e.endpoint.Path == path {
+			return e.renderer, true
+		}
+	}
+	return nil, false
+}
+
+// newRendererRegistry registers the built-in renderers, sharing env.
+func newRendererRegistry(env *renderEnv) *rendererRegistry {
+	errs := []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	rasterErrs := append([]int{http.StatusBadRequest}, errs...)
+
+	r := &rendererRegistry{}
+	r.register(endpoint{
+		Path:        "/composite-map.png",
+		Summary:     "Color-coded composite of all vacuum maps",
+		Tag:         "maps",
+		ContentType: "image/png",
+		Params:      rasterParams,
+		Errors:      rasterErrs,
+	}, compositePNG{env})
+	r.register(endpoint{
+		Path:        "/live.png",
+		Summary:     "Greyscale floor plan with live vacuum positions",
+		Tag:         "live",
+		ContentType: "image/png",
+		Params:      rasterParams,
+		Errors:      rasterErrs,
+	}, livePNG{env})
+	r.register(endpoint{
+		Path:        "/floorplan.png",
+		Summary:     "Greyscale floor plan from the unified map",
+		Description: "Draws the unified (consensus) floors and walls, so walls that vacuums see a few centimeters apart appear once. Until the unified map has been built, the vacuums' own floors and walls are overlaid instead.",
+		Tag:         "maps",
+		ContentType: "image/png",
+		Params:      []endpointParam{formatParam},
+		Errors:      rasterErrs,
+	}, floorplanPNG{env})
+	r.register(endpoint{
+		Path:        "/composite-map.svg",
+		Summary:     "Color-coded composite of all vacuum maps (vector)",
+		Description: "Frontiers, edges of the floor that no wall closes off, are drawn dashed in orange.",
+		Tag:         "maps",
+		ContentType: "image/svg+xml",
+		Errors:      errs,
+	}, compositeSVG{env})
+	r.register(endpoint{
+		Path:        "/floorplan.svg",
+		Summary:     "Greyscale unified floor plan without positions",
+		Tag:         "maps",
+		ContentType: "image/svg+xml",
+		Errors:      errs,
+	}, floorplanSVG{env})
+	r.register(endpoint{
+		Path:        "/live.svg",
+		Summary:     "Greyscale floor plan with live vacuum positions (vector)",
+		Description: "The floor plan is rendered once per map change; chargers and robots are drawn per request into <g id=\"chargers\"> and <g id=\"robots\"> groups, so dashboards can restyle or animate them.",
+		Tag:         "live",
+		ContentType: "image/svg+xml",
+		Errors:      errs,
+	}, liveSVG{env: env, cache: &mesh.LiveSVGCache{}})
+	return r
+}
+
+// writeRender renders opts with renderer and writes the image, or the
+// error with its status code.
+func writeRender(w http.ResponseWriter, r *http.Request, renderer Renderer, opts RenderOptions) {
+	data, contentType, err := renderer.Render(r.Context(), opts)
+	var invalid invalidOptionError
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errNoMaps), errors.Is(err, errNoDrawableContent):
+		if errors.Is(err, errNoDrawableContent) {
+			log.Printf("Warning: maps present but no drawable content; endpoint=%s", r.URL.Path)
+		}
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, context.Canceled):
+		return // the client went away
+	case err != nil:
+		log.Printf("Error rendering %s: %v", r.URL.Path, err)
+		http.Error(w, fmt.Sprintf("Error rendering %s", r.URL.Path), http.StatusInternalServerError)
+		return
+	}
+	trace := mesh.TraceFrom(r.Context())
+	trace.Step("write")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Accept")
+	if timing := trace.ServerTiming(); timing != "" {
+		w.Header().Set("Server-Timing", timing)
+	}
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing %s: %v", r.URL.Path, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+func TestRendererRegistry_RenderEach(t *testing.T) {
+	maps := map[string]*mesh.ValetudoMap{"vac1": minimalMap()}
+	opts := RenderOptions{
+		Maps:       maps,
+		Transforms: map[string]mesh.AffineMatrix{"vac1": mesh.Identity()},
+		Reference:  "vac1",
+		Query:      url.Values{},
+	}
+	registry := newRendererRegistry(&renderEnv{rotation: fixedRotation(0)})
+	if len(registry.entries) == 0 {
+		t.Fatal("no renderers registered")
+	}
+	for _, e := range registry.entries {
+		data, contentType, err := e.renderer.Render(context.Background(), opts)
+		if err != nil {
+			t.Errorf("%s: %v", e.endpoint.Path, err)
+			continue
+		}
+		if contentType != e.endpoint.ContentType || len(data) == 0 {
+			t.Errorf("%s: %d bytes of %q, want %q", e.endpoint.Path, len(data), contentType, e.endpoint.ContentType)
+		}
+	}
+}
+
+func TestRendererRegistry_Lookup(t *testing.T) {
+	registry := newRendererRegistry(&renderEnv{})
+	if _, ok := registry.lookup("/composite-map.png"); !ok {
+		t.Error("/composite-map.png not registered")
+	}
+	if _, ok := registry.lookup("/nope.png"); ok {
+		t.Error("lookup found an unregistered path")
+	}
+}
+
+// stubRenderer returns a fixed result.
+type stubRenderer struct {
+	data []byte
+	err  error
+}
+
+func (s stubRenderer) Render(context.Context, RenderOptions) ([]byte, string, error) {
+	return s.data, "image/test", s.err
+}
+
+func TestWriteRender_Status(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"ok", nil, http.StatusOK},
+		{"invalid option", invalidOptionError{errors.New("bad legend")}, http.StatusBadRequest},
+		{"no maps", errNoMaps, http.StatusServiceUnavailable},
+		{"no drawable content", errNoDrawableContent, http.StatusServiceUnavailable},
+		{"other", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeRender(rec, httptest.NewRequest(http.MethodGet, "/x.png", nil), stubRenderer{data: []byte("img"), err: tt.err}, RenderOptions{})
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.err == nil && (rec.Header().Get("Content-Type") != "image/test" || rec.Body.String() != "img") {
+				t.Errorf("response = %q of %q", rec.Body.String(), rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// Calibration wizard translation steps in pixels.
+const (
+	wizardStep    = 10.0
+	wizardMinStep = 1.0
+	wizardMaxStep = 160.0
+)
+
+// errWizardInput is returned when the input ends before the wizard is
+// done; nothing has been written then.
+var errWizardInput = errors.New("input ended before the calibration wizard finished; nothing was written")
+
+// wizardCandidate is one rotation the wizard offers for a vacuum.
+type wizardCandidate struct {
+	Rotation float64        // Degrees, the rotation hint ICP starts from
+	Result   mesh.ICPResult // Onto the reference, mirror included
+}
+
+// wizardChoice is what the wizard settled on for a vacuum.
+type wizardChoice struct {
+	Result mesh.ICPResult
+	Hint   *mesh.CalibrationHint // nil when the automatic alignment was kept
+	Frozen bool                  // the cached transform is kept
+}
+
+// RunCalibrationWizard walks through the vacuums on the terminal: see
+// calibrationWizard.
+func (a *App) RunCalibrationWizard() error {
+	return a.calibrationWizard(os.Stdin, os.Stdout)
+}
+
+// calibrationWizard aligns each non-reference vacuum, writes a render of
+// every candidate rotation and asks which one to keep, or for an
+// angle of its own. The chosen alignment's translation can then be nudged,
+// each step rewriting a preview render. At the end the rotations and
+// translations are written to the config file as hints, and the transforms
+// to the calibration in the configured storage backend. Renders are not
+// turned by --rotate-all, so up in them is up in the map.
+func (a *App) calibrationWizard(in io.Reader, out io.Writer) error {
+	var failed failures
+	maps, err := a.loadExports(true, &failed)
+	if err != nil {
+		return err
+	}
+	if len(maps) < 2 {
+		return errors.New("need at least 2 maps for calibration")
+	}
+
+	// The hints are written to the config, so it must load
+	config, err := mesh.LoadConfig(a.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("loading config file %s: %w", a.ConfigFile, err)
+	}
+	// The calibration goes where the service would read it
+	store, err := mesh.OpenStore(config.Storage, a.DataDir, a.CalibrationCache)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+	previous, err := store.LoadCalibration()
+	if err != nil {
+		log.Printf("Warning: Failed to load calibration from %s: %v", store, err)
+	}
+
+	// The same reference as --render, which applies the hints
+	refID := a.ReferenceVacuum
+	if refID == "" {
+		refID = mesh.GetEffectiveReference(config, previous, maps)
+	}
+	if refID == "" {
+		refID = mesh.SelectReferenceVacuum(maps, nil)
+	}
+	refMap := maps[refID]
+	fmt.Fprintf(out, "Reference vacuum: %s\n", refID)
+
+	input := bufio.NewScanner(in)
+	icpConfig := mesh.ICPConfigFromConfig(config)
+	choices := make(map[string]wizardChoice, len(maps))
+	var hints []mesh.CalibrationHint
+	for _, id := range sortedKeys(maps) {
+		if id == refID {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s\n%s\n", id, strings.Repeat("-", 60))
+		if vc := previous.GetVacuumCalibration(id); vc != nil && previous.ReferenceVacuum == refID && config.IsFrozen(id) {
+			fmt.Fprintf(out, "Frozen in config; keeping the cached transform\n")
+			choices[id] = wizardChoice{Frozen: true}
+			continue
+		}
+
+		choice, err := a.wizardVacuum(input, out, maps, refID, id, config, icpConfig)
+		if err != nil {
+			return err
+		}
+		choices[id] = choice
+		if choice.Hint == nil {
+			continue
+		}
+		if config.GetVacuumByID(id) == nil {
+			fmt.Fprintf(out, "%s is not in %s; its alignment goes to the calibration cache only\n", id, a.ConfigFile)
+			continue
+		}
+		hints = append(hints, *choice.Hint)
+	}
+
+	fmt.Fprintf(out, "\n%s\n", strings.Repeat("=", 60))
+	for _, h := range hints {
+		fmt.Fprintf(out, "%s: rotation %g°, translation (%g, %g)\n", h.VacuumID, h.Rotation, h.Translation.X, h.Translation.Y)
+	}
+	answer, err := wizardPrompt(input, out, "Write %d hint(s) to %s and the calibration to %s? [Y/n] ", len(hints), a.ConfigFile, store)
+	if err != nil {
+		return err
+	}
+	if answer != "" && !strings.HasPrefix(strings.ToLower(answer), "y") {
+		fmt.Fprintln(out, "Nothing written")
+		return failed.err()
+	}
+
+	if len(hints) > 0 {
+		if err := mesh.ApplyCalibrationHints(a.ConfigFile, hints); err != nil {
+			return fmt.Errorf("writing hints: %w", err)
+		}
+		fmt.Fprintf(out, "Wrote hints to %s\n", a.ConfigFile)
+	}
+
+	now := time.Now().Unix()
+	cache := mesh.CalibrationData{
+		ReferenceVacuum: refID,
+		Vacuums: map[string]mesh.VacuumCalibration{
+			refID: {Transform: mesh.Identity(), LastUpdated: now, MapAreaAtCalibration: refMap.MetaData.TotalLayerArea},
+		},
+	}
+	for id, choice := range choices {
+		if choice.Frozen {
+			cache.Vacuums[id] = previous.Vacuums[id]
+			continue
+		}
+		cache.Vacuums[id] = mesh.VacuumCalibration{
+			Transform:            choice.Result.Transform,
+			LastUpdated:          now,
+			MapAreaAtCalibration: maps[id].MetaData.TotalLayerArea,
+			ICPScore:             choice.Result.Score,
+			Covariance:           choice.Result.Covariance,
+			Alternatives:         choice.Result.Alternatives,
+		}
+	}
+	if err := store.SaveCalibration(&cache); err != nil {
+		return fmt.Errorf("saving calibration to %s: %w", store, err)
+	}
+	fmt.Fprintf(out, "Saved calibration to %s\n", store)
+	return failed.err()
+}
+
+// wizardVacuum offers the candidate rotations of one vacuum, then the
+// nudging of the chosen one, and returns the choice.
+func (a *App) wizardVacuum(input *bufio.Scanner, out io.Writer, maps map[string]*mesh.ValetudoMap, refID, id string, config *mesh.Config, icpConfig mesh.ICPConfig) (wizardChoice, error) {
+	src, mirror := mesh.MirrorMap(maps[id], config.MirrorAxis(id))
+	candidates := wizardCandidates(src, maps[refID], mirror, icpConfig)
+
+	path := fmt.Sprintf("calibrate_%s.png", id)
+	if err := writeCandidateGrid(path, maps, refID, id, candidates); err != nil {
+		log.Printf("Warning: %v", err)
+	} else {
+		fmt.Fprintf(out, "Candidates rendered to %s\n", path)
+	}
+	for i, c := range candidates {
+		note := ""
+		if i == 0 {
+			note = "  (best)"
+		}
+		fmt.Fprintf(out, "  %d) rotation %5.1f°  score %.4f%s\n", i+1, c.Rotation, c.Result.Score, note)
+	}
+	vc := config.GetVacuumByID(id)
+	if vc != nil && vc.Rotation != nil {
+		t := vc.GetTranslation()
+		fmt.Fprintf(out, "  Configured: rotation %g°, translation (%g, %g)\n", *vc.Rotation, t.X, t.Y)
+	}
+
+	var chosen wizardCandidate
+	for {
+		answer, err := wizardPrompt(input, out, "Pick 1-%d, an angle such as 37.5°, or s to keep the automatic alignment [1]: ", len(candidates))
+		if err != nil {
+			return wizardChoice{}, err
+		}
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer == "s" {
+			return wizardChoice{Result: candidates[0].Result}, nil
+		}
+		if answer == "" {
+			answer = "1"
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(candidates) {
+			chosen = candidates[n-1]
+			break
+		}
+		degrees, err := strconv.ParseFloat(strings.TrimSuffix(answer, "°"), 64)
+		if err != nil || math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+			fmt.Fprintf(out, "Not a candidate or an angle: %q\n", answer)
+			continue
+		}
+		rotation := wizardDegrees(degrees)
+		result := mesh.AlignMapsWithRotationHint(src, maps[refID], icpConfig, rotation).ComposedWith(mirror)
+		chosen = wizardCandidate{Rotation: rotation, Result: result}
+		fmt.Fprintf(out, "Aligned from %g°: score %.4f\n", rotation, result.Score)
+		break
+	}
+
+	// Pick up a configured translation when the rotation is kept
+	var offset mesh.TranslationOffset
+	if vc != nil && vc.Rotation != nil && math.Abs(wizardDegrees(*vc.Rotation)-chosen.Rotation) < 0.05 {
+		offset = vc.GetTranslation()
+	}
+	offset, err := a.wizardNudge(input, out, maps, refID, id, chosen.Result.Transform, offset)
+	if err != nil {
+		return wizardChoice{}, err
+	}
+
+	result := chosen.Result
+	result.Transform = mesh.MultiplyMatrices(mesh.Translation(offset.X, offset.Y), result.Transform)
+	result.Alternatives = nil // settled by hand
+	return wizardChoice{
+		Result: result,
+		Hint:   &mesh.CalibrationHint{VacuumID: id, Rotation: chosen.Rotation, Translation: &offset},
+	}, nil
+}
+
+// wizardNudge moves the transform by keys read a line at a time until an
+// empty line, rewriting a preview render after each, and returns the
+// translation added.
+func (a *App) wizardNudge(input *bufio.Scanner, out io.Writer, maps map[string]*mesh.ValetudoMap, refID, id string, transform mesh.AffineMatrix, offset mesh.TranslationOffset) (mesh.TranslationOffset, error) {
+	path := fmt.Sprintf("calibrate_%s_preview.png", id)
+	step := wizardStep
+	for {
+		nudged := mesh.MultiplyMatrices(mesh.Translation(offset.X, offset.Y), transform)
+		if err := writeWizardPreview(path, maps, refID, id, nudged); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		answer, err := wizardPrompt(input, out, "Translation (%g, %g), preview in %s. w/a/s/d move %g px up/left/down/right, +/- change the step, 0 resets, Enter keeps it: ",
+			offset.X, offset.Y, path, step)
+		if err != nil {
+			return offset, err
+		}
+		if answer == "" {
+			return offset, nil
+		}
+		for _, key := range strings.ToLower(answer) {
+			switch key {
+			case 'w':
+				offset.Y -= step
+			case 's':
+				offset.Y += step
+			case 'a':
+				offset.X -= step
+			case 'd':
+				offset.X += step
+			case '+':
+				step = math.Min(step*2, wizardMaxStep)
+			case '-':
+				step = math.Max(step/2, wizardMinStep)
+			case '0':
+				offset = mesh.TranslationOffset{}
+			case ' ':
+			default:
+				fmt.Fprintf(out, "Ignored %q\n", key)
+			}
+		}
+	}
+}
+
+// wizardCandidates aligns source onto target and returns the result, then
+// the ambiguous rotations ICP kept and the other quarter turns from it,
+// each aligned from its rotation. Results are composed with mirror, as in
+// the calibration cache; the rotations are hints for the mirrored source.
+func wizardCandidates(source, target *mesh.ValetudoMap, mirror mesh.AffineMatrix, config mesh.ICPConfig) []wizardCandidate {
+	best := mesh.AlignMaps(source, target, config)
+	candidates := []wizardCandidate{{Rotation: wizardDegrees(mesh.TransformRotation(best.Transform)), Result: best.ComposedWith(mirror)}}
+
+	rotations := make([]float64, 0, len(best.Alternatives)+3)
+	for _, alt := range best.Alternatives {
+		rotations = append(rotations, alt.Rotation)
+	}
+	for turn := 90.0; turn < 360; turn += 90 {
+		rotations = append(rotations, candidates[0].Rotation+turn)
+	}
+	for _, rotation := range rotations {
+		rotation = wizardDegrees(rotation)
+		distinct := true
+		for _, c := range candidates {
+			if d := math.Abs(math.Remainder(rotation-c.Rotation, 360)); d < 10 {
+				distinct = false
+				break
+			}
+		}
+		if distinct {
+			result := mesh.AlignMapsWithRotationHint(source, target, config, rotation).ComposedWith(mirror)
+			candidates = append(candidates, wizardCandidate{Rotation: rotation, Result: result})
+		}
+	}
+	return candidates
+}
+
+// wizardDegrees returns degrees in [0, 360), rounded to a tenth as it is
+// written to the config.
+func wizardDegrees(degrees float64) float64 {
+	degrees = math.Round(math.Mod(degrees, 360)*10) / 10
+	if degrees < 0 {
+		degrees += 360
+	}
+	if degrees >= 360 {
+		degrees -= 360
+	}
+	return degrees
+}
+
+// writeCandidateGrid renders each candidate of vacuum id over the reference
+// in a captioned grid, as --compare-grid does, and writes it to path.
+func writeCandidateGrid(path string, maps map[string]*mesh.ValetudoMap, refID, id string, candidates []wizardCandidate) error {
+	pair := map[string]*mesh.ValetudoMap{refID: maps[refID], id: maps[id]}
+	variants := make([]mesh.RotationVariant, len(candidates))
+	for i, c := range candidates {
+		variants[i] = mesh.RotationVariant{
+			Rotation:   c.Rotation,
+			Score:      c.Result.Score,
+			Transforms: map[string]mesh.AffineMatrix{refID: mesh.Identity(), id: c.Result.Transform},
+		}
+	}
+	grid := mesh.RenderRotationGrid(variants, func(v mesh.RotationVariant) *image.RGBA {
+		return mesh.NewCompositeRenderer(pair, v.Transforms, refID).Render()
+	}, nil)
+	return writeWizardImage(path, grid)
+}
+
+// writeWizardPreview renders vacuum id with transform over the reference
+// and writes it to path.
+func writeWizardPreview(path string, maps map[string]*mesh.ValetudoMap, refID, id string, transform mesh.AffineMatrix) error {
+	pair := map[string]*mesh.ValetudoMap{refID: maps[refID], id: maps[id]}
+	img := mesh.NewCompositeRenderer(pair, map[string]mesh.AffineMatrix{refID: mesh.Identity(), id: transform}, refID).Render()
+	return writeWizardImage(path, img)
+}
+
+func writeWizardImage(path string, img image.Image) error {
+	data, _, err := encodeImage(img, formatPNG)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// wizardPrompt prints a prompt and returns the next line of input, trimmed.
+func wizardPrompt(input *bufio.Scanner, out io.Writer, format string, args ...any) (string, error) {
+	fmt.Fprintf(out, format, args...)
+	if !input.Scan() {
+		fmt.Fprintln(out)
+		if err := input.Err(); err != nil {
+			return "", fmt.Errorf("reading input: %w", err)
+		}
+		return "", errWizardInput
+	}
+	return strings.TrimSpace(input.Text()), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kwv/tudomesh/mesh"
+)
+
+// wizardRoom returns an L-shaped room, which only one rotation lines up,
+// turned a quarter when turned is set.
+func wizardRoom(turned bool) *mesh.ValetudoMap {
+	var walls, floor []int
+	add := func(pixels *[]int, x, y int) {
+		if turned {
+			x, y = 200-y, x
+		}
+		*pixels = append(*pixels, x, y)
+	}
+	inside := func(x, y int) bool { return x >= 0 && y >= 0 && x <= 120 && y <= 80 && (x <= 60 || y <= 40) }
+	for y := -1; y <= 81; y++ {
+		for x := -1; x <= 121; x++ {
+			switch {
+			case inside(x, y) && !inside(x-1, y) || inside(x, y) && !inside(x+1, y) || inside(x, y) && !inside(x, y-1) || inside(x, y) && !inside(x, y+1):
+				add(&walls, x, y)
+			case inside(x, y):
+				add(&floor, x, y)
+			}
+		}
+	}
+	return &mesh.ValetudoMap{
+		Size:      mesh.Size{X: 1000, Y: 1000},
+		PixelSize: 5,
+		Layers: []mesh.MapLayer{
+			{Type: "wall", Pixels: walls},
+			{Type: "floor", Pixels: floor, MetaData: mesh.LayerMetaData{Area: len(floor) / 2 * 25}},
+		},
+		MetaData: mesh.MapMetaData{Version: 1, TotalLayerArea: len(floor) / 2 * 25},
+	}
+}
+
+// setupWizard writes the maps and a config for both vacuums to a temporary
+// directory, which becomes the working directory, and returns an App on it.
+func setupWizard(t *testing.T) *App {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	config := "# rocky is the reference\nreference: rocky\nmqtt:\n  broker: tcp://localhost:1883\nvacuums:\n  - id: rocky\n    topic: t/rocky\n  - id: dusty\n    topic: t/dusty\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for id, m := range map[string]*mesh.ValetudoMap{"rocky": wizardRoom(false), "dusty": wizardRoom(true)} {
+		if err := saveTestMapToFile(m, filepath.Join(dir, "ValetudoMapExport-"+id+".json")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := NewApp()
+	app.ApplyOptions(AppOptions{DataDir: dir, ConfigFile: filepath.Join(dir, "config.yaml"), CalibrationCache: ".calibration-cache.json"})
+	return app
+}
+
+func TestCalibrationWizard(t *testing.T) {
+	app := setupWizard(t)
+
+	// Keep the best rotation, nudge it right twice and down once, and write
+	var out bytes.Buffer
+	if err := app.calibrationWizard(strings.NewReader("\ndd\ns\n\ny\n"), &out); err != nil {
+		t.Fatalf("wizard: %v\n%s", err, out.String())
+	}
+	for _, path := range []string{"calibrate_dusty.png", "calibrate_dusty_preview.png"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("render %s not written: %v", path, err)
+		}
+	}
+
+	config, err := mesh.LoadConfig(app.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dusty := config.GetVacuumByID("dusty")
+	if dusty.Rotation == nil || dusty.GetTranslation() != (mesh.TranslationOffset{X: 20, Y: 10}) {
+		t.Fatalf("dusty hints = rotation %v, translation %+v; want a rotation and (20, 10)\n%s", dusty.Rotation, dusty.Translation, out.String())
+	}
+	if r := math.Abs(math.Remainder(*dusty.Rotation-270, 360)); r > 5 {
+		t.Errorf("rotation hint = %g°, want about 270°", *dusty.Rotation)
+	}
+	if data, _ := os.ReadFile(app.ConfigFile); !strings.Contains(string(data), "# rocky is the reference") {
+		t.Errorf("config comments lost:\n%s", data)
+	}
+
+	cache, err := mesh.LoadCalibration(app.CalibrationCache)
+	if err != nil || cache == nil {
+		t.Fatalf("calibration cache: %v", err)
+	}
+	vc := cache.GetVacuumCalibration("dusty")
+	if cache.ReferenceVacuum != "rocky" || vc == nil {
+		t.Fatalf("cache = %+v", cache)
+	}
+	// The room corner lands where the nudge put it
+	corner := mesh.TransformPoint(mesh.Point{X: 200, Y: 0}, vc.Transform)
+	if math.Abs(corner.X-20) > 3 || math.Abs(corner.Y-10) > 3 {
+		t.Errorf("corner at (%.1f, %.1f), want about (20, 10)", corner.X, corner.Y)
+	}
+}
+
+func TestCalibrationWizard_Storage(t *testing.T) {
+	app := setupWizard(t)
+	f, err := os.OpenFile(app.ConfigFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("storage:\n  backend: sqlite\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	var out bytes.Buffer
+	if err := app.calibrationWizard(strings.NewReader("\n\ny\n"), &out); err != nil {
+		t.Fatalf("wizard: %v\n%s", err, out.String())
+	}
+	if _, err := os.Stat(app.CalibrationCache); err == nil {
+		t.Error("calibration cache file written despite the sqlite backend")
+	}
+	store, err := mesh.OpenStore(mesh.StorageConfig{Backend: mesh.StorageBackendSQLite}, app.DataDir, app.CalibrationCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+	if cal, err := store.LoadCalibration(); err != nil || cal.GetVacuumCalibration("dusty") == nil {
+		t.Errorf("calibration in sqlite = %+v, %v; want dusty's", cal, err)
+	}
+}
+
+func TestCalibrationWizard_Declined(t *testing.T) {
+	app := setupWizard(t)
+	before, _ := os.ReadFile(app.ConfigFile)
+
+	for _, input := range []string{"\n\nn\n", "\n"} {
+		var out bytes.Buffer
+		err := app.calibrationWizard(strings.NewReader(input), &out)
+		if input == "\n" && !errors.Is(err, errWizardInput) {
+			t.Errorf("input ending early: err = %v, want errWizardInput", err)
+		}
+		if input != "\n" && err != nil {
+			t.Errorf("declined: %v", err)
+		}
+		if after, _ := os.ReadFile(app.ConfigFile); !bytes.Equal(before, after) {
+			t.Errorf("config written for input %q:\n%s", input, after)
+		}
+		if _, err := os.Stat(app.CalibrationCache); err == nil {
+			t.Errorf("calibration cache written for input %q", input)
+		}
+	}
+}
+
+func TestWizardDegrees(t *testing.T) {
+	for in, want := range map[float64]float64{0: 0, 37.54: 37.5, -90: 270, 450: 90, 359.97: 0, -0.01: 0} {
+		if got := wizardDegrees(in); got != want {
+			t.Errorf("wizardDegrees(%g) = %g, want %g", in, got, want)
+		}
+	}
+}